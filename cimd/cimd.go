@@ -0,0 +1,462 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package cimd provides the CIMD2 message type and conversions to and from
+// its binary form, and to and from SMS TPDUs.
+//
+// The operations supported are login, logout, submit, deliver message,
+// deliver status report and alive, and their responses, as defined in the
+// Nokia CIMD2 Interface Specification.
+package cimd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Message represents all CIMD2 messages, both operations and responses.
+//
+// As with the TPDU, the one type covers all operations, with the operation
+// specific fields contained in the Params.
+type Message struct {
+	// OC is the operation code.
+	OC OpCode
+
+	// PN is the packet number, 0-255, used to correlate operations and
+	// responses.
+	//
+	// By convention the client uses odd packet numbers and the SMSC even.
+	PN int
+
+	// Params contains the parameters of the message, in the order they are
+	// encoded.
+	Params Params
+}
+
+// OpCode identifies the CIMD2 operation.
+type OpCode int
+
+// CIMD2 operation codes.
+const (
+	// OpLogin is the login operation.
+	OpLogin OpCode = 1
+
+	// OpLogout is the logout operation.
+	OpLogout OpCode = 2
+
+	// OpSubmit is the submit operation.
+	OpSubmit OpCode = 3
+
+	// OpDeliverMessage is the deliver message operation.
+	OpDeliverMessage OpCode = 20
+
+	// OpDeliverStatusReport is the deliver status report operation.
+	OpDeliverStatusReport OpCode = 23
+
+	// OpAlive is the alive operation.
+	OpAlive OpCode = 40
+
+	// OpLoginResp is the login response operation.
+	OpLoginResp OpCode = 51
+
+	// OpLogoutResp is the logout response operation.
+	OpLogoutResp OpCode = 52
+
+	// OpSubmitResp is the submit response operation.
+	OpSubmitResp OpCode = 53
+
+	// OpDeliverMessageResp is the deliver message response operation.
+	OpDeliverMessageResp OpCode = 70
+
+	// OpDeliverStatusReportResp is the deliver status report response
+	// operation.
+	OpDeliverStatusReportResp OpCode = 73
+
+	// OpAliveResp is the alive response operation.
+	OpAliveResp OpCode = 90
+
+	// OpGeneralErrorResp is returned for operations that cannot be
+	// identified.
+	OpGeneralErrorResp OpCode = 98
+
+	// OpNack indicates a message was received with a checksum or packet
+	// number error.
+	OpNack OpCode = 99
+)
+
+// IsResponse indicates if the OpCode is a response.
+func (o OpCode) IsResponse() bool {
+	return o >= 50
+}
+
+// Response returns the OpCode of the response to the operation.
+func (o OpCode) Response() OpCode {
+	if o.IsResponse() {
+		return o
+	}
+	return o + 50
+}
+
+var opNames = map[OpCode]string{
+	OpLogin:                   "login",
+	OpLogout:                  "logout",
+	OpSubmit:                  "submit",
+	OpDeliverMessage:          "deliver_message",
+	OpDeliverStatusReport:     "deliver_status_report",
+	OpAlive:                   "alive",
+	OpLoginResp:               "login_resp",
+	OpLogoutResp:              "logout_resp",
+	OpSubmitResp:              "submit_resp",
+	OpDeliverMessageResp:      "deliver_message_resp",
+	OpDeliverStatusReportResp: "deliver_status_report_resp",
+	OpAliveResp:               "alive_resp",
+	OpGeneralErrorResp:        "general_error_resp",
+	OpNack:                    "nack",
+}
+
+func (o OpCode) String() string {
+	if n, ok := opNames[o]; ok {
+		return n
+	}
+	return fmt.Sprintf("%02d", int(o))
+}
+
+// ParamCode identifies a CIMD2 parameter.
+type ParamCode int
+
+// CIMD2 parameter codes.
+const (
+	// ParamUserIdentity is the user identity parameter.
+	ParamUserIdentity ParamCode = 10
+
+	// ParamPassword is the password parameter.
+	ParamPassword ParamCode = 11
+
+	// ParamDestinationAddress is the destination address parameter.
+	ParamDestinationAddress ParamCode = 21
+
+	// ParamOriginatingAddress is the originating address parameter.
+	ParamOriginatingAddress ParamCode = 23
+
+	// ParamAlphanumericOrigAddress is the alphanumeric originating address
+	// parameter.
+	ParamAlphanumericOrigAddress ParamCode = 27
+
+	// ParamDataCodingScheme is the data coding scheme parameter.
+	ParamDataCodingScheme ParamCode = 30
+
+	// ParamUserDataHeader is the hex encoded user data header parameter.
+	ParamUserDataHeader ParamCode = 32
+
+	// ParamUserData is the user data parameter, containing text.
+	ParamUserData ParamCode = 33
+
+	// ParamUserDataBinary is the user data binary parameter, containing hex
+	// encoded data.
+	ParamUserDataBinary ParamCode = 34
+
+	// ParamMoreMessagesToSend is the more messages to send parameter.
+	ParamMoreMessagesToSend ParamCode = 44
+
+	// ParamValidityPeriodRelative is the relative validity period parameter.
+	ParamValidityPeriodRelative ParamCode = 50
+
+	// ParamValidityPeriodAbsolute is the absolute validity period parameter.
+	ParamValidityPeriodAbsolute ParamCode = 51
+
+	// ParamProtocolIdentifier is the protocol identifier parameter.
+	ParamProtocolIdentifier ParamCode = 52
+
+	// ParamReplyPath is the reply path parameter.
+	ParamReplyPath ParamCode = 55
+
+	// ParamStatusReportRequest is the status report request parameter.
+	ParamStatusReportRequest ParamCode = 56
+
+	// ParamServiceCentreTimeStamp is the service centre time stamp
+	// parameter.
+	ParamServiceCentreTimeStamp ParamCode = 60
+
+	// ParamStatusCode is the status code parameter.
+	ParamStatusCode ParamCode = 61
+
+	// ParamStatusErrorCode is the status error code parameter.
+	ParamStatusErrorCode ParamCode = 62
+
+	// ParamDischargeTime is the discharge time parameter.
+	ParamDischargeTime ParamCode = 63
+
+	// ParamServiceCentreAddress is the service centre address parameter.
+	ParamServiceCentreAddress ParamCode = 69
+
+	// ParamErrorCode is the error code parameter.
+	ParamErrorCode ParamCode = 900
+
+	// ParamErrorText is the error text parameter.
+	ParamErrorText ParamCode = 901
+)
+
+const (
+	stx             = 0x02
+	etx             = 0x03
+	paramSeparator  = ':'
+	paramTerminator = '\t'
+
+	// headerLen is the length of the OO:NNN<TAB> header.
+	headerLen = 7
+
+	// minMessageLen is the length of a message with no parameters or
+	// checksum.
+	minMessageLen = headerLen + 2
+
+	maxMessageLen   = 65536
+	maxPacketNumber = 255
+	maxParamCode    = 999
+	paramCodeLen    = 3
+	checksumLen     = 2
+)
+
+// CIMD2 error codes, returned in the ParamErrorCode of a response.
+const (
+	// ErrorCodeUnexpectedOperation indicates the operation was not expected.
+	ErrorCodeUnexpectedOperation = 1
+
+	// ErrorCodeSyntax indicates a syntax error.
+	ErrorCodeSyntax = 2
+
+	// ErrorCodeUnsupportedParam indicates an unsupported parameter.
+	ErrorCodeUnsupportedParam = 3
+
+	// ErrorCodeGeneralSystem indicates a general system error.
+	ErrorCodeGeneralSystem = 6
+
+	// ErrorCodeParamFormatting indicates a parameter formatting error.
+	ErrorCodeParamFormatting = 8
+
+	// ErrorCodeOperationFailed indicates the requested operation failed.
+	ErrorCodeOperationFailed = 9
+
+	// ErrorCodeInvalidLogin indicates an invalid login.
+	ErrorCodeInvalidLogin = 100
+
+	// ErrorCodeIncorrectAccessType indicates an incorrect access type.
+	ErrorCodeIncorrectAccessType = 101
+
+	// ErrorCodeDestAddress indicates an incorrect destination address.
+	ErrorCodeDestAddress = 300
+)
+
+// Param is a single CIMD2 parameter.
+type Param struct {
+	Code  ParamCode
+	Value string
+}
+
+// Params is an ordered collection of parameters.
+type Params []Param
+
+// Get returns the value of the first parameter with the code, and a flag
+// indicating if it was found.
+func (pp Params) Get(c ParamCode) (string, bool) {
+	for _, p := range pp {
+		if p.Code == c {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set replaces the value of the parameter with the code, or appends the
+// parameter if it is not already present.
+func (pp *Params) Set(c ParamCode, v string) {
+	pp.Del(c)
+	*pp = append(*pp, Param{c, v})
+}
+
+// Del removes any parameters with the code.
+func (pp *Params) Del(c ParamCode) {
+	n := (*pp)[:0]
+	for _, p := range *pp {
+		if p.Code != c {
+			n = append(n, p)
+		}
+	}
+	*pp = n
+}
+
+// Response returns a response for the operation, with the error code
+// provided.
+//
+// An error code of zero indicates a positive response, else the error code
+// is returned in the ParamErrorCode.  Any other response parameters must be
+// filled in by the caller.
+func (m *Message) Response(ec int) *Message {
+	r := Message{OC: m.OC.Response(), PN: m.PN}
+	if ec != 0 {
+		r.Params.Set(ParamErrorCode, strconv.Itoa(ec))
+	}
+	return &r
+}
+
+// MarshalBinary marshals the Message into its binary form, including the
+// STX, checksum and ETX.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if m.OC < 0 || m.OC > 99 {
+		return nil, tpdu.EncodeError("oc", tpdu.ErrInvalid)
+	}
+	if m.PN < 0 || m.PN > maxPacketNumber {
+		return nil, tpdu.EncodeError("pn", tpdu.ErrInvalid)
+	}
+	var b bytes.Buffer
+	b.WriteByte(stx)
+	fmt.Fprintf(&b, "%02d:%03d\t", int(m.OC), m.PN)
+	for _, p := range m.Params {
+		if p.Code < 0 || p.Code > maxParamCode {
+			return nil, tpdu.EncodeError("param", tpdu.ErrInvalid)
+		}
+		if strings.ContainsAny(p.Value, "\t\x02\x03") {
+			return nil, tpdu.EncodeError(fmt.Sprintf("%03d", int(p.Code)), tpdu.ErrInvalid)
+		}
+		fmt.Fprintf(&b, "%03d:%s\t", int(p.Code), p.Value)
+	}
+	fmt.Fprintf(&b, "%02X", checksum(b.Bytes()))
+	b.WriteByte(etx)
+	if b.Len() > maxMessageLen {
+		return nil, tpdu.EncodeError("message", tpdu.ErrOverlength)
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary unmarshals a Message from its binary form, including the
+// STX and ETX.
+//
+// The checksum is optional, but if present must be correct.
+func (m *Message) UnmarshalBinary(src []byte) error {
+	if len(src) < minMessageLen {
+		return tpdu.NewDecodeError("message", 0, tpdu.ErrUnderflow)
+	}
+	if src[0] != stx {
+		return tpdu.NewDecodeError("stx", 0, tpdu.ErrInvalid)
+	}
+	if src[len(src)-1] != etx {
+		return tpdu.NewDecodeError("etx", len(src)-1, tpdu.ErrInvalid)
+	}
+	end := bytes.LastIndexByte(src, paramTerminator)
+	if end < 0 {
+		return tpdu.NewDecodeError("header", 1, tpdu.ErrInvalid)
+	}
+	end++
+	switch len(src) - 1 - end {
+	case 0:
+	case checksumLen:
+		cs := string(src[end : end+checksumLen])
+		if fmt.Sprintf("%02X", checksum(src[:end])) != strings.ToUpper(cs) {
+			return tpdu.NewDecodeError("checksum", end, ErrInvalidChecksum(cs))
+		}
+	default:
+		return tpdu.NewDecodeError("checksum", end, tpdu.ErrInvalid)
+	}
+	hdr := string(src[1:end])
+	if len(hdr) < headerLen || hdr[2] != ':' || hdr[6] != paramTerminator {
+		return tpdu.NewDecodeError("header", 1, tpdu.ErrInvalid)
+	}
+	oc, err := parseDigits(hdr[:2])
+	if err != nil {
+		return tpdu.NewDecodeError("oc", 1, err)
+	}
+	pn, err := parseDigits(hdr[3:6])
+	if err != nil || pn > maxPacketNumber {
+		return tpdu.NewDecodeError("pn", 4, tpdu.ErrInvalid)
+	}
+	msg := Message{OC: OpCode(oc), PN: pn}
+	ri := 1 + headerLen
+	for ri < end {
+		l := bytes.IndexByte(src[ri:end], paramTerminator)
+		p := string(src[ri : ri+l])
+		if len(p) <= paramCodeLen || p[paramCodeLen] != paramSeparator {
+			return tpdu.NewDecodeError("param", ri, tpdu.ErrInvalid)
+		}
+		c, err := parseDigits(p[:paramCodeLen])
+		if err != nil {
+			return tpdu.NewDecodeError("param", ri, err)
+		}
+		msg.Params = append(msg.Params, Param{ParamCode(c), p[paramCodeLen+1:]})
+		ri += l + 1
+	}
+	*m = msg
+	return nil
+}
+
+// ReadMessage reads a single Message from the reader.
+//
+// Any bytes preceding the STX are discarded.
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == stx {
+			break
+		}
+	}
+	b := []byte{stx}
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		b = append(b, c)
+		if c == etx {
+			break
+		}
+		if len(b) > maxMessageLen {
+			return nil, tpdu.NewDecodeError("message", 0, tpdu.ErrOverlength)
+		}
+	}
+	m := Message{}
+	err := m.UnmarshalBinary(b)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// WriteMessage marshals the Message and writes it to the writer.
+func WriteMessage(w io.Writer, m *Message) error {
+	b, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// checksum returns the CIMD2 checksum of the bytes, being the sum of the
+// bytes modulo 256.
+func checksum(b []byte) byte {
+	var cs byte
+	for _, c := range b {
+		cs += c
+	}
+	return cs
+}
+
+func parseDigits(s string) (int, error) {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, tpdu.ErrInvalid
+		}
+	}
+	return strconv.Atoi(s)
+}
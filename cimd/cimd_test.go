@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cimd_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/cimd"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+var messagePatterns = []struct {
+	name string
+	in   cimd.Message
+	out  []byte
+}{
+	{
+		"login",
+		cimd.Message{
+			OC: cimd.OpLogin,
+			PN: 1,
+			Params: cimd.Params{
+				{cimd.ParamUserIdentity, "user"},
+				{cimd.ParamPassword, "pass"},
+			},
+		},
+		[]byte("\x0201:001\t010:user\t011:pass\t56\x03"),
+	},
+	{
+		"login resp",
+		cimd.Message{OC: cimd.OpLoginResp, PN: 1},
+		[]byte("\x0251:001\t3C\x03"),
+	},
+	{
+		"submit resp error",
+		cimd.Message{
+			OC:     cimd.OpSubmitResp,
+			PN:     3,
+			Params: cimd.Params{{cimd.ParamErrorCode, "2"}},
+		},
+		[]byte("\x0253:003\t900:2\t4E\x03"),
+	},
+	{
+		"alive",
+		cimd.Message{OC: cimd.OpAlive, PN: 5},
+		[]byte("\x0240:005\t3E\x03"),
+	},
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, p := range messagePatterns {
+		f := func(t *testing.T) {
+			b, err := p.in.MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, p.out, b)
+		}
+		t.Run(p.name, f)
+	}
+
+	errPatterns := []struct {
+		name string
+		in   cimd.Message
+		err  error
+	}{
+		{"oc", cimd.Message{OC: 100}, tpdu.EncodeError("oc", tpdu.ErrInvalid)},
+		{"pn", cimd.Message{OC: cimd.OpAlive, PN: 256}, tpdu.EncodeError("pn", tpdu.ErrInvalid)},
+		{"param code", cimd.Message{OC: cimd.OpAlive, Params: cimd.Params{{1000, ""}}}, tpdu.EncodeError("param", tpdu.ErrInvalid)},
+		{"param value", cimd.Message{OC: cimd.OpAlive, Params: cimd.Params{{cimd.ParamUserData, "a\tb"}}}, tpdu.EncodeError("033", tpdu.ErrInvalid)},
+	}
+	for _, p := range errPatterns {
+		f := func(t *testing.T) {
+			b, err := p.in.MarshalBinary()
+			assert.Equal(t, p.err, err)
+			assert.Nil(t, b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	for _, p := range messagePatterns {
+		f := func(t *testing.T) {
+			m := cimd.Message{}
+			err := m.UnmarshalBinary(p.out)
+			require.Nil(t, err)
+			assert.Equal(t, p.in, m)
+		}
+		t.Run(p.name, f)
+	}
+
+	// checksum is optional
+	m := cimd.Message{}
+	err := m.UnmarshalBinary([]byte("\x0201:001\t010:user\t011:pass\t\x03"))
+	require.Nil(t, err)
+	assert.Equal(t, messagePatterns[0].in, m)
+
+	errPatterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{"short", "\x0240:00\x03", tpdu.NewDecodeError("message", 0, tpdu.ErrUnderflow)},
+		{"stx", "40:005\t3E\x03\x03", tpdu.NewDecodeError("stx", 0, tpdu.ErrInvalid)},
+		{"etx", "\x0240:005\t3E\x02", tpdu.NewDecodeError("etx", 10, tpdu.ErrInvalid)},
+		{"no tab", "\x0240:005:3E\x03", tpdu.NewDecodeError("header", 1, tpdu.ErrInvalid)},
+		{"checksum", "\x0240:005\t3F\x03", tpdu.NewDecodeError("checksum", 8, cimd.ErrInvalidChecksum("3F"))},
+		{"checksum length", "\x0240:005\t3E0\x03", tpdu.NewDecodeError("checksum", 8, tpdu.ErrInvalid)},
+		{"header", "\x024:0050\t\x03", tpdu.NewDecodeError("header", 1, tpdu.ErrInvalid)},
+		{"oc", "\x02x0:005\t\x03", tpdu.NewDecodeError("oc", 1, tpdu.ErrInvalid)},
+		{"pn", "\x0240:256\t\x03", tpdu.NewDecodeError("pn", 4, tpdu.ErrInvalid)},
+		{"param", "\x0240:005\t01:x\t\x03", tpdu.NewDecodeError("param", 8, tpdu.ErrInvalid)},
+		{"param code", "\x0240:005\t0x1:x\t\x03", tpdu.NewDecodeError("param", 8, tpdu.ErrInvalid)},
+	}
+	for _, p := range errPatterns {
+		f := func(t *testing.T) {
+			m := cimd.Message{}
+			err := m.UnmarshalBinary([]byte(p.in))
+			assert.Equal(t, p.err, err)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestOpCode(t *testing.T) {
+	assert.False(t, cimd.OpSubmit.IsResponse())
+	assert.True(t, cimd.OpSubmitResp.IsResponse())
+	assert.Equal(t, cimd.OpSubmitResp, cimd.OpSubmit.Response())
+	assert.Equal(t, cimd.OpNack, cimd.OpNack.Response())
+	assert.Equal(t, "deliver_status_report", cimd.OpDeliverStatusReport.String())
+	assert.Equal(t, "41", cimd.OpCode(41).String())
+}
+
+func TestParams(t *testing.T) {
+	var pp cimd.Params
+	_, ok := pp.Get(cimd.ParamUserData)
+	assert.False(t, ok)
+	pp.Set(cimd.ParamUserData, "hello")
+	pp.Set(cimd.ParamDestinationAddress, "1234")
+	pp.Set(cimd.ParamUserData, "world")
+	assert.Equal(t, cimd.Params{
+		{cimd.ParamDestinationAddress, "1234"},
+		{cimd.ParamUserData, "world"},
+	}, pp)
+	v, ok := pp.Get(cimd.ParamUserData)
+	assert.True(t, ok)
+	assert.Equal(t, "world", v)
+	pp.Del(cimd.ParamDestinationAddress)
+	assert.Equal(t, cimd.Params{{cimd.ParamUserData, "world"}}, pp)
+}
+
+func TestResponse(t *testing.T) {
+	m := cimd.Message{OC: cimd.OpSubmit, PN: 7, Params: cimd.Params{{cimd.ParamUserData, "hello"}}}
+	assert.Equal(t, &cimd.Message{OC: cimd.OpSubmitResp, PN: 7}, m.Response(0))
+	assert.Equal(t, &cimd.Message{
+		OC:     cimd.OpSubmitResp,
+		PN:     7,
+		Params: cimd.Params{{cimd.ParamErrorCode, "300"}},
+	}, m.Response(cimd.ErrorCodeDestAddress))
+}
+
+func TestReadWriteMessage(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString("noise")
+	for _, p := range messagePatterns {
+		err := cimd.WriteMessage(&b, &p.in)
+		require.Nil(t, err)
+	}
+	r := bufio.NewReader(&b)
+	for _, p := range messagePatterns {
+		m, err := cimd.ReadMessage(r)
+		require.Nil(t, err)
+		assert.Equal(t, &p.in, m)
+	}
+	_, err := cimd.ReadMessage(r)
+	assert.Equal(t, io.EOF, err)
+
+	r = bufio.NewReader(bytes.NewReader([]byte("\x0240:0")))
+	_, err = cimd.ReadMessage(r)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	r = bufio.NewReader(bytes.NewReader([]byte("\x0240:005\t3F\x03")))
+	_, err = cimd.ReadMessage(r)
+	assert.Equal(t, tpdu.NewDecodeError("checksum", 8, cimd.ErrInvalidChecksum("3F")), err)
+
+	err = cimd.WriteMessage(&b, &cimd.Message{OC: 100})
+	assert.Equal(t, tpdu.EncodeError("oc", tpdu.ErrInvalid), err)
+}
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cimd
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedOpCode indicates the operation code of the message is not
+// supported.
+type ErrUnsupportedOpCode OpCode
+
+func (e ErrUnsupportedOpCode) Error() string {
+	return fmt.Sprintf("cimd: unsupported operation: %s", OpCode(e))
+}
+
+// ErrInvalidChecksum indicates the checksum of a message does not match its
+// contents.
+type ErrInvalidChecksum string
+
+func (e ErrInvalidChecksum) Error() string {
+	return fmt.Sprintf("cimd: invalid checksum: '%s'", string(e))
+}
+
+// ErrInvalidTime indicates a time parameter is not in the CIMD2 time format.
+type ErrInvalidTime string
+
+func (e ErrInvalidTime) Error() string {
+	return fmt.Sprintf("cimd: invalid time: '%s'", string(e))
+}
+
+// ErrInvalidCombination indicates the user data contains an unrecognised
+// special character combination.
+type ErrInvalidCombination string
+
+func (e ErrInvalidCombination) Error() string {
+	return fmt.Sprintf("cimd: invalid special combination: '%s'", string(e))
+}
+
+var (
+	// ErrUnsupportedSmsType indicates the TPDU cannot be converted to or from
+	// a CIMD2 message.
+	ErrUnsupportedSmsType = errors.New("cimd: unsupported SMS type")
+)
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cimd
+
+import (
+	"strings"
+
+	"github.com/warthog618/sms/encoding/gsm7"
+)
+
+// combinations maps GSM7 default alphabet septets to the CIMD2 special
+// character combinations used to represent them in the user data.
+//
+// Septets not listed are represented by the equivalent ASCII character.
+var combinations = map[byte]string{
+	0x00: "_Oa",  // @
+	0x01: "_L-",  // £
+	0x03: "_Y-",  // ¥
+	0x04: "_e`",  // è
+	0x05: "_e'",  // é
+	0x06: "_u`",  // ù
+	0x07: "_i`",  // ì
+	0x08: "_o`",  // ò
+	0x09: "_C,",  // Ç
+	0x0b: "_O/",  // Ø
+	0x0c: "_o/",  // ø
+	0x0e: "_A*",  // Å
+	0x0f: "_a*",  // å
+	0x10: "_gd",  // Δ
+	0x11: "_--",  // _
+	0x12: "_gf",  // Φ
+	0x13: "_gg",  // Γ
+	0x14: "_gl",  // Λ
+	0x15: "_go",  // Ω
+	0x16: "_gp",  // Π
+	0x17: "_gi",  // Ψ
+	0x18: "_gs",  // Σ
+	0x19: "_gt",  // Θ
+	0x1a: "_gx",  // Ξ
+	0x1b: "_XX",  // escape
+	0x1c: "_AE",  // Æ
+	0x1d: "_ae",  // æ
+	0x1e: "_ss",  // ß
+	0x1f: "_E'",  // É
+	0x22: "_qq",  // "
+	0x24: "_ox",  // ¤
+	0x40: "_!!",  // ¡
+	0x5b: "_A\"", // Ä
+	0x5c: "_O\"", // Ö
+	0x5d: "_N~",  // Ñ
+	0x5e: "_U\"", // Ü
+	0x5f: "_so",  // §
+	0x60: "_??",  // ¿
+	0x7b: "_a\"", // ä
+	0x7c: "_o\"", // ö
+	0x7d: "_n~",  // ñ
+	0x7e: "_u\"", // ü
+	0x7f: "_a`",  // à
+}
+
+// septets is the reverse of combinations.
+var septets = func() map[string]byte {
+	m := make(map[string]byte, len(combinations))
+	for k, v := range combinations {
+		m[v] = k
+	}
+	return m
+}()
+
+// EncodeUserData converts GSM7 septets, one per octet, into the text form
+// used in the CIMD2 user data parameter.
+//
+// Septets that do not correspond to an ASCII character are converted to the
+// equivalent special character combination.  The escape to the extension
+// table is encoded as "_XX", followed by the extension septet encoded as per
+// the default alphabet.
+func EncodeUserData(ud []byte) (string, error) {
+	var b strings.Builder
+	for _, s := range ud {
+		if s > 0x7f {
+			return "", gsm7.ErrInvalidSeptet(s)
+		}
+		if c, ok := combinations[s]; ok {
+			b.WriteString(c)
+			continue
+		}
+		// the remaining septets map to ASCII
+		if s == 0x02 {
+			b.WriteByte('$')
+			continue
+		}
+		b.WriteByte(s)
+	}
+	return b.String(), nil
+}
+
+// DecodeUserData converts the text form used in the CIMD2 user data
+// parameter into GSM7 septets, one per octet.
+//
+// Special character combinations are converted to the corresponding
+// septets.  Other characters are converted to their GSM7 equivalent,
+// including characters in the extension table, which are converted to an
+// escape followed by the extension septet.
+func DecodeUserData(s string) ([]byte, error) {
+	var ud []byte
+	for i := 0; i < len(s); {
+		if s[i] == '_' {
+			if i+3 > len(s) {
+				return nil, ErrInvalidCombination(s[i:])
+			}
+			sep, ok := septets[s[i:i+3]]
+			if !ok {
+				return nil, ErrInvalidCombination(s[i : i+3])
+			}
+			ud = append(ud, sep)
+			i += 3
+			continue
+		}
+		j := i + 1
+		for j < len(s) && s[j] != '_' {
+			j++
+		}
+		u, err := gsm7.Encode([]byte(s[i:j]))
+		if err != nil {
+			return nil, err
+		}
+		ud = append(ud, u...)
+		i = j
+	}
+	return ud, nil
+}
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cimd_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/cimd"
+	"github.com/warthog618/sms/encoding/gsm7"
+)
+
+func TestUserData(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string // UTF-8
+		out  string // CIMD2
+	}{
+		{"ascii", "Hello, world!", "Hello, world!"},
+		{"empty", "", ""},
+		{"at and dollar", "a@b$c", "a_Oab$c"},
+		{"underscore", "snake_case", "snake_--case"},
+		{"greek", "ΔΦΓΛΩΠΨΣΘΞ", "_gd_gf_gg_gl_go_gp_gi_gs_gt_gx"},
+		{"latin", "£¥èéùìòÇØøÅåÆæßÉ¤¡ÄÖÑÜ§¿äöñüà", "_L-_Y-_e`_e'_u`_i`_o`_C,_O/_o/_A*_a*_AE_ae_ss_E'_ox_!!_A\"_O\"_N~_U\"_so_??_a\"_o\"_n~_u\"_a`"},
+		{"quote", "say \"hi\"", "say _qqhi_qq"},
+		{"newline", "a\r\nb", "a\r\nb"},
+		{"extension", "{€}[~]\\|^", "_XX(_XXe_XX)_XX<_XX=_XX>_XX/_XX_!!_XX_gl"},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			ud, err := gsm7.Encode([]byte(p.in))
+			require.Nil(t, err)
+			s, err := cimd.EncodeUserData(ud)
+			require.Nil(t, err)
+			assert.Equal(t, p.out, s)
+			dud, err := cimd.DecodeUserData(s)
+			require.Nil(t, err)
+			assert.Equal(t, ud, dud)
+		}
+		t.Run(p.name, f)
+	}
+
+	// extension characters may also be provided directly
+	ud, err := cimd.DecodeUserData("{€} and \"")
+	require.Nil(t, err)
+	assert.Equal(t, []byte{0x1b, 0x28, 0x1b, 0x65, 0x1b, 0x29, ' ', 'a', 'n', 'd', ' ', 0x22}, ud)
+
+	_, err = cimd.EncodeUserData([]byte{0x80})
+	assert.Equal(t, gsm7.ErrInvalidSeptet(0x80), err)
+	_, err = cimd.DecodeUserData("abc_Z")
+	assert.Equal(t, cimd.ErrInvalidCombination("_Z"), err)
+	_, err = cimd.DecodeUserData("_ZZa")
+	assert.Equal(t, cimd.ErrInvalidCombination("_ZZ"), err)
+	_, err = cimd.DecodeUserData("😁")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,372 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cimd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// CIMD2 status codes, as returned in the ParamStatusCode of a deliver status
+// report.
+const (
+	// StatusInProcess indicates the message is still being delivered.
+	StatusInProcess = 1
+
+	// StatusValidityPeriodExpired indicates the message expired before it
+	// could be delivered.
+	StatusValidityPeriodExpired = 2
+
+	// StatusDeliveryFailed indicates the message could not be delivered.
+	StatusDeliveryFailed = 3
+
+	// StatusDeliverySuccessful indicates the message was delivered.
+	StatusDeliverySuccessful = 4
+
+	// StatusNoResponse indicates the recipient did not respond, and delivery
+	// will be retried.
+	StatusNoResponse = 5
+
+	// StatusLastNoResponse indicates the recipient did not respond, and
+	// delivery will not be retried.
+	StatusLastNoResponse = 6
+
+	// StatusMessageCancelled indicates the message was cancelled.
+	StatusMessageCancelled = 7
+
+	// StatusMessageDeleted indicates the message was deleted by the
+	// operator.
+	StatusMessageDeleted = 8
+
+	// StatusMessageDeletedByCancel indicates the message was deleted by a
+	// cancel operation.
+	StatusMessageDeletedByCancel = 9
+)
+
+// srrFinal is the status report request requesting reports for all final
+// states, i.e. validity period expired, delivery failed, delivery
+// successful, message cancelled and message deleted.
+const srrFinal = 62
+
+// timeFormat is the format of CIMD2 time parameters, yymmddhhmmss.
+const timeFormat = "060102150405"
+
+// FormatTime returns the time in the CIMD2 time format.
+//
+// The time is converted to UTC, as CIMD2 times do not specify a timezone.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// ParseTime parses a CIMD2 time.
+//
+// The time is taken to be in UTC, as CIMD2 times do not specify a timezone.
+func ParseTime(s string) (time.Time, error) {
+	if len(s) != len(timeFormat) {
+		return time.Time{}, ErrInvalidTime(s)
+	}
+	t, err := time.ParseInLocation(timeFormat, s, time.UTC)
+	if err != nil {
+		return time.Time{}, ErrInvalidTime(s)
+	}
+	return t, nil
+}
+
+// FromTPDU creates the CIMD2 operation corresponding to the TPDU.
+//
+// SMS-SUBMIT TPDUs are converted to submit operations, SMS-DELIVER TPDUs to
+// deliver message operations, and SMS-STATUS-REPORT TPDUs to deliver status
+// report operations.
+//
+// GSM7 UD is provided as text in the user data parameter, and other UD as
+// hex in the user data binary parameter.  The UDH, if any, is provided in the
+// user data header parameter.
+//
+// The destination address of a deliver message is not contained in the TPDU
+// and must be set by the caller, as must the PN.
+func FromTPDU(t *tpdu.TPDU) (*Message, error) {
+	m := Message{}
+	switch t.SmsType() {
+	case tpdu.SmsSubmit:
+		m.OC = OpSubmit
+		m.Params.Set(ParamDestinationAddress, t.DA.Number())
+		switch t.VP.Format {
+		case tpdu.VpfNotPresent:
+		case tpdu.VpfAbsolute:
+			m.Params.Set(ParamValidityPeriodAbsolute, FormatTime(t.VP.Time.Time))
+		default:
+			vp := tpdu.ValidityPeriod{}
+			vp.SetRelative(t.VP.Duration)
+			b, _ := vp.MarshalBinary()
+			m.Params.Set(ParamValidityPeriodRelative, strconv.Itoa(int(b[0])))
+		}
+		if t.FirstOctet.SRR() {
+			m.Params.Set(ParamStatusReportRequest, strconv.Itoa(srrFinal))
+		}
+	case tpdu.SmsDeliver:
+		m.OC = OpDeliverMessage
+		if t.OA.TypeOfNumber() == tpdu.TonAlphanumeric {
+			m.Params.Set(ParamAlphanumericOrigAddress, t.OA.Addr)
+		} else {
+			m.Params.Set(ParamOriginatingAddress, t.OA.Number())
+		}
+		m.Params.Set(ParamServiceCentreTimeStamp, FormatTime(t.SCTS.Time))
+	case tpdu.SmsStatusReport:
+		m.OC = OpDeliverStatusReport
+		m.Params.Set(ParamDestinationAddress, t.RA.Number())
+		m.Params.Set(ParamServiceCentreTimeStamp, FormatTime(t.SCTS.Time))
+		m.Params.Set(ParamStatusCode, strconv.Itoa(statusCodeFromST(t.ST)))
+		m.Params.Set(ParamDischargeTime, FormatTime(t.DT.Time))
+		return &m, nil
+	default:
+		return nil, ErrUnsupportedSmsType
+	}
+	if t.PID != 0 {
+		m.Params.Set(ParamProtocolIdentifier, strconv.Itoa(int(t.PID)))
+	}
+	if t.FirstOctet.RP() {
+		m.Params.Set(ParamReplyPath, "1")
+	}
+	if t.DCS != 0 {
+		m.Params.Set(ParamDataCodingScheme, strconv.Itoa(int(t.DCS)))
+	}
+	if len(t.UDH) > 0 {
+		udh, err := t.UDH.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		m.Params.Set(ParamUserDataHeader, strings.ToUpper(hex.EncodeToString(udh)))
+	}
+	alpha, err := t.DCS.Alphabet()
+	if err != nil {
+		return nil, tpdu.EncodeError("dcs", err)
+	}
+	if alpha == tpdu.Alpha7Bit {
+		ud, err := EncodeUserData(t.UD)
+		if err != nil {
+			return nil, tpdu.EncodeError("ud", err)
+		}
+		m.Params.Set(ParamUserData, ud)
+	} else {
+		m.Params.Set(ParamUserDataBinary, strings.ToUpper(hex.EncodeToString(t.UD)))
+	}
+	return &m, nil
+}
+
+// TPDU creates the TPDU corresponding to a submit, deliver message or
+// deliver status report operation.
+//
+// This is the reverse of FromTPDU.
+// User data provided as text is converted to GSM7, and user data provided as
+// binary is taken to be 8bit unless a DCS is provided.
+func (m *Message) TPDU() (*tpdu.TPDU, error) {
+	var t *tpdu.TPDU
+	switch m.OC {
+	case OpSubmit:
+		t, _ = tpdu.NewSubmit()
+		t.DA = addressFromCIMD(m.param(ParamDestinationAddress))
+		if v, ok := m.Params.Get(ParamValidityPeriodAbsolute); ok {
+			vpt, err := ParseTime(v)
+			if err != nil {
+				return nil, paramError(ParamValidityPeriodAbsolute, err)
+			}
+			vp := tpdu.ValidityPeriod{}
+			vp.SetAbsolute(tpdu.Timestamp{Time: vpt})
+			t.SetVP(vp)
+		} else if v, ok := m.Params.Get(ParamValidityPeriodRelative); ok {
+			r, err := strconv.ParseUint(v, 10, 8)
+			if err != nil {
+				return nil, paramError(ParamValidityPeriodRelative, tpdu.ErrInvalid)
+			}
+			vp := tpdu.ValidityPeriod{}
+			vp.UnmarshalBinary([]byte{byte(r)}, tpdu.VpfRelative)
+			t.SetVP(vp)
+		}
+		if v, ok := m.Params.Get(ParamStatusReportRequest); ok {
+			srr, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, paramError(ParamStatusReportRequest, tpdu.ErrInvalid)
+			}
+			if srr != 0 {
+				t.FirstOctet |= tpdu.FoSRR
+			}
+		}
+	case OpDeliverMessage:
+		t, _ = tpdu.NewDeliver()
+		if v, ok := m.Params.Get(ParamAlphanumericOrigAddress); ok {
+			t.OA = tpdu.NewAddress()
+			t.OA.SetTypeOfNumber(tpdu.TonAlphanumeric)
+			t.OA.Addr = v
+		} else {
+			t.OA = addressFromCIMD(m.param(ParamOriginatingAddress))
+		}
+		scts, err := ParseTime(m.param(ParamServiceCentreTimeStamp))
+		if err != nil {
+			return nil, paramError(ParamServiceCentreTimeStamp, err)
+		}
+		t.SCTS = tpdu.Timestamp{Time: scts}
+	case OpDeliverStatusReport:
+		return m.statusReport()
+	default:
+		return nil, ErrUnsupportedOpCode(m.OC)
+	}
+	if v, ok := m.Params.Get(ParamProtocolIdentifier); ok {
+		pid, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return nil, paramError(ParamProtocolIdentifier, tpdu.ErrInvalid)
+		}
+		t.PID = byte(pid)
+	}
+	if m.param(ParamReplyPath) == "1" {
+		t.FirstOctet |= tpdu.FoRP
+	}
+	dcs, hasDCS := m.Params.Get(ParamDataCodingScheme)
+	if hasDCS {
+		d, err := strconv.ParseUint(dcs, 10, 8)
+		if err != nil {
+			return nil, paramError(ParamDataCodingScheme, tpdu.ErrInvalid)
+		}
+		t.DCS = tpdu.DCS(d)
+	}
+	if v, ok := m.Params.Get(ParamUserDataHeader); ok {
+		b, err := hex.DecodeString(v)
+		if err != nil {
+			return nil, paramError(ParamUserDataHeader, tpdu.ErrInvalid)
+		}
+		var udh tpdu.UserDataHeader
+		if _, err := udh.UnmarshalBinary(b); err != nil {
+			return nil, paramError(ParamUserDataHeader, err)
+		}
+		t.SetUDH(udh)
+	}
+	var ud []byte
+	if v, ok := m.Params.Get(ParamUserDataBinary); ok {
+		b, err := hex.DecodeString(v)
+		if err != nil {
+			return nil, paramError(ParamUserDataBinary, tpdu.ErrInvalid)
+		}
+		if !hasDCS {
+			t.DCS = tpdu.Dcs8BitData
+		}
+		alpha, _ := t.DCS.Alphabet()
+		if alpha == tpdu.AlphaUCS2 && len(b)&0x01 == 0x01 {
+			return nil, paramError(ParamUserDataBinary, tpdu.ErrOddUCS2Length)
+		}
+		ud = b
+	} else if v, ok := m.Params.Get(ParamUserData); ok {
+		b, err := DecodeUserData(v)
+		if err != nil {
+			return nil, paramError(ParamUserData, err)
+		}
+		ud = b
+	}
+	if len(ud) > 0 {
+		t.UD = ud
+	}
+	return t, nil
+}
+
+// param returns the value of the parameter, or an empty string if it is not
+// present.
+func (m *Message) param(c ParamCode) string {
+	v, _ := m.Params.Get(c)
+	return v
+}
+
+// paramError returns a DecodeError identifying the parameter.
+func paramError(c ParamCode, err error) error {
+	return tpdu.NewDecodeError(fmt.Sprintf("%03d", int(c)), 0, err)
+}
+
+// statusReport returns the SMS-STATUS-REPORT corresponding to the deliver
+// status report operation.
+func (m *Message) statusReport() (*tpdu.TPDU, error) {
+	t, _ := tpdu.New(tpdu.SmsStatusReport)
+	t.RA = addressFromCIMD(m.param(ParamDestinationAddress))
+	scts, err := ParseTime(m.param(ParamServiceCentreTimeStamp))
+	if err != nil {
+		return nil, paramError(ParamServiceCentreTimeStamp, err)
+	}
+	t.SCTS = tpdu.Timestamp{Time: scts}
+	dt, err := ParseTime(m.param(ParamDischargeTime))
+	if err != nil {
+		return nil, paramError(ParamDischargeTime, err)
+	}
+	t.DT = tpdu.Timestamp{Time: dt}
+	sc, err := strconv.Atoi(m.param(ParamStatusCode))
+	if err != nil {
+		return nil, paramError(ParamStatusCode, tpdu.ErrInvalid)
+	}
+	st, ok := stFromStatusCode(sc)
+	if !ok {
+		return nil, paramError(ParamStatusCode, tpdu.ErrInvalid)
+	}
+	t.ST = st
+	return t, nil
+}
+
+// addressFromCIMD returns the TPDU Address corresponding to the CIMD2
+// address, which is international if prefixed with '+'.
+func addressFromCIMD(addr string) tpdu.Address {
+	if strings.HasPrefix(addr, "+") {
+		return tpdu.NewAddress(tpdu.FromNumber(addr))
+	}
+	a := tpdu.NewAddress()
+	a.SetNumberingPlan(tpdu.NpISDN)
+	a.Addr = addr
+	return a
+}
+
+// statusCodeFromST returns the CIMD2 status code corresponding to a TP-ST, as
+// defined in 3GPP TS 23.040 Section 9.2.3.15.
+func statusCodeFromST(st byte) int {
+	switch {
+	case st < 0x20:
+		return StatusDeliverySuccessful
+	case st == 0x22:
+		return StatusNoResponse
+	case st < 0x40:
+		return StatusInProcess
+	case st == 0x46:
+		return StatusValidityPeriodExpired
+	case st == 0x47:
+		return StatusMessageCancelled
+	case st == 0x48:
+		return StatusMessageDeleted
+	case st == 0x62:
+		return StatusLastNoResponse
+	default:
+		return StatusDeliveryFailed
+	}
+}
+
+// stFromStatusCode returns the TP-ST best corresponding to a CIMD2 status
+// code.
+func stFromStatusCode(sc int) (byte, bool) {
+	switch sc {
+	case StatusInProcess:
+		return 0x20, true // congestion
+	case StatusValidityPeriodExpired:
+		return 0x46, true // validity period expired
+	case StatusDeliveryFailed:
+		return 0x40, true // remote procedure error
+	case StatusDeliverySuccessful:
+		return 0x00, true // received by the SME
+	case StatusNoResponse:
+		return 0x22, true // no response from SME, SC still trying
+	case StatusLastNoResponse:
+		return 0x62, true // no response from SME, SC no longer trying
+	case StatusMessageCancelled, StatusMessageDeletedByCancel:
+		return 0x47, true // deleted by originating SME
+	case StatusMessageDeleted:
+		return 0x48, true // deleted by SC administration
+	}
+	return 0, false
+}
@@ -0,0 +1,332 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cimd_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/cimd"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+func TestTime(t *testing.T) {
+	tm := time.Date(2020, time.April, 12, 10, 30, 15, 0, time.UTC)
+	assert.Equal(t, "200412103015", cimd.FormatTime(tm))
+	pt, err := cimd.ParseTime("200412103015")
+	require.Nil(t, err)
+	assert.Equal(t, tm, pt)
+	_, err = cimd.ParseTime("2004121030")
+	assert.Equal(t, cimd.ErrInvalidTime("2004121030"), err)
+	_, err = cimd.ParseTime("201312103015")
+	assert.Equal(t, cimd.ErrInvalidTime("201312103015"), err)
+}
+
+func TestFromTPDU(t *testing.T) {
+	scts := tpdu.Timestamp{Time: time.Date(2020, time.April, 12, 10, 30, 15, 0, time.UTC)}
+	patterns := []struct {
+		name string
+		in   func() *tpdu.TPDU
+		out  *cimd.Message
+	}{
+		{
+			"submit",
+			func() *tpdu.TPDU {
+				s, _ := tpdu.NewSubmit()
+				s.DA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				s.FirstOctet |= tpdu.FoSRR | tpdu.FoRP
+				vp := tpdu.ValidityPeriod{}
+				vp.SetRelative(time.Hour)
+				s.SetVP(vp)
+				s.PID = 0x41
+				s.UD = []byte("hello_")
+				s.UD[5] = 0x11
+				return s
+			},
+			&cimd.Message{
+				OC: cimd.OpSubmit,
+				Params: cimd.Params{
+					{cimd.ParamDestinationAddress, "+1234"},
+					{cimd.ParamValidityPeriodRelative, "11"},
+					{cimd.ParamStatusReportRequest, "62"},
+					{cimd.ParamProtocolIdentifier, "65"},
+					{cimd.ParamReplyPath, "1"},
+					{cimd.ParamUserData, "hello_--"},
+				},
+			},
+		},
+		{
+			"submit absolute",
+			func() *tpdu.TPDU {
+				s, _ := tpdu.NewSubmit()
+				s.DA = tpdu.NewAddress()
+				s.DA.SetNumberingPlan(tpdu.NpISDN)
+				s.DA.Addr = "1234"
+				vp := tpdu.ValidityPeriod{}
+				vp.SetAbsolute(scts)
+				s.SetVP(vp)
+				s.DCS = tpdu.DcsUCS2Data
+				s.SetUDH(tpdu.UserDataHeader{{ID: 0, Data: []byte{0x2a, 0x02, 0x01}}})
+				s.UD = []byte{0x00, 0x68, 0x00, 0x69}
+				return s
+			},
+			&cimd.Message{
+				OC: cimd.OpSubmit,
+				Params: cimd.Params{
+					{cimd.ParamDestinationAddress, "1234"},
+					{cimd.ParamValidityPeriodAbsolute, "200412103015"},
+					{cimd.ParamDataCodingScheme, "8"},
+					{cimd.ParamUserDataHeader, "0500032A0201"},
+					{cimd.ParamUserDataBinary, "00680069"},
+				},
+			},
+		},
+		{
+			"deliver",
+			func() *tpdu.TPDU {
+				d, _ := tpdu.NewDeliver()
+				d.OA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				d.SCTS = scts
+				d.DCS = 0x10
+				d.UD = []byte("hello")
+				return d
+			},
+			&cimd.Message{
+				OC: cimd.OpDeliverMessage,
+				Params: cimd.Params{
+					{cimd.ParamOriginatingAddress, "+1234"},
+					{cimd.ParamServiceCentreTimeStamp, "200412103015"},
+					{cimd.ParamDataCodingScheme, "16"},
+					{cimd.ParamUserData, "hello"},
+				},
+			},
+		},
+		{
+			"alphanumeric",
+			func() *tpdu.TPDU {
+				d, _ := tpdu.NewDeliver()
+				d.OA = tpdu.NewAddress()
+				d.OA.SetTypeOfNumber(tpdu.TonAlphanumeric)
+				d.OA.Addr = "ALPHA"
+				d.SCTS = scts
+				d.DCS = tpdu.Dcs8BitData
+				d.UD = []byte{1, 2, 3}
+				return d
+			},
+			&cimd.Message{
+				OC: cimd.OpDeliverMessage,
+				Params: cimd.Params{
+					{cimd.ParamAlphanumericOrigAddress, "ALPHA"},
+					{cimd.ParamServiceCentreTimeStamp, "200412103015"},
+					{cimd.ParamDataCodingScheme, "4"},
+					{cimd.ParamUserDataBinary, "010203"},
+				},
+			},
+		},
+		{
+			"status report",
+			func() *tpdu.TPDU {
+				r, _ := tpdu.New(tpdu.SmsStatusReport)
+				r.RA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				r.SCTS = scts
+				r.DT = tpdu.Timestamp{Time: scts.Add(time.Minute)}
+				r.ST = 0x46
+				return r
+			},
+			&cimd.Message{
+				OC: cimd.OpDeliverStatusReport,
+				Params: cimd.Params{
+					{cimd.ParamDestinationAddress, "+1234"},
+					{cimd.ParamServiceCentreTimeStamp, "200412103015"},
+					{cimd.ParamStatusCode, "2"},
+					{cimd.ParamDischargeTime, "200412103115"},
+				},
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			in := p.in()
+			m, err := cimd.FromTPDU(in)
+			require.Nil(t, err)
+			assert.Equal(t, p.out, m)
+
+			// and back again
+			b, err := m.MarshalBinary()
+			require.Nil(t, err)
+			rm := cimd.Message{}
+			require.Nil(t, rm.UnmarshalBinary(b))
+			rt, err := rm.TPDU()
+			require.Nil(t, err)
+			assert.Equal(t, in, rt)
+		}
+		t.Run(p.name, f)
+	}
+
+	_, err := cimd.FromTPDU(&tpdu.TPDU{FirstOctet: 0x03})
+	assert.Equal(t, cimd.ErrUnsupportedSmsType, err)
+}
+
+func TestTimeZone(t *testing.T) {
+	loc := time.FixedZone("SCTS", 8*3600)
+	s, _ := tpdu.NewSubmit()
+	s.DA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+	vp := tpdu.ValidityPeriod{}
+	vp.SetAbsolute(tpdu.Timestamp{Time: time.Date(2020, time.May, 18, 8, 2, 50, 0, loc)})
+	s.SetVP(vp)
+	m, err := cimd.FromTPDU(s)
+	require.Nil(t, err)
+	v, _ := m.Params.Get(cimd.ParamValidityPeriodAbsolute)
+	assert.Equal(t, "200518000250", v)
+	rt, err := m.TPDU()
+	require.Nil(t, err)
+	assert.True(t, s.VP.Time.Equal(rt.VP.Time.Time))
+
+	d, _ := tpdu.NewDeliver()
+	d.OA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+	d.SCTS = tpdu.Timestamp{Time: time.Date(2020, time.May, 18, 7, 2, 50, 0, loc)}
+	d.UD = []byte("hello")
+	m, err = cimd.FromTPDU(d)
+	require.Nil(t, err)
+	v, _ = m.Params.Get(cimd.ParamServiceCentreTimeStamp)
+	assert.Equal(t, "200517230250", v)
+	rt, err = m.TPDU()
+	require.Nil(t, err)
+	assert.True(t, d.SCTS.Equal(rt.SCTS.Time))
+
+	r, _ := tpdu.New(tpdu.SmsStatusReport)
+	r.RA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+	r.SCTS = d.SCTS
+	r.DT = tpdu.Timestamp{Time: time.Date(2020, time.May, 18, 7, 3, 10, 0, loc)}
+	m, err = cimd.FromTPDU(r)
+	require.Nil(t, err)
+	v, _ = m.Params.Get(cimd.ParamDischargeTime)
+	assert.Equal(t, "200517230310", v)
+	rt, err = m.TPDU()
+	require.Nil(t, err)
+	assert.True(t, r.SCTS.Equal(rt.SCTS.Time))
+	assert.True(t, r.DT.Equal(rt.DT.Time))
+}
+
+func TestStatusCodes(t *testing.T) {
+	patterns := []struct {
+		st byte
+		sc int
+		rt byte
+	}{
+		{0x00, cimd.StatusDeliverySuccessful, 0x00},
+		{0x02, cimd.StatusDeliverySuccessful, 0x00},
+		{0x20, cimd.StatusInProcess, 0x20},
+		{0x22, cimd.StatusNoResponse, 0x22},
+		{0x40, cimd.StatusDeliveryFailed, 0x40},
+		{0x46, cimd.StatusValidityPeriodExpired, 0x46},
+		{0x47, cimd.StatusMessageCancelled, 0x47},
+		{0x48, cimd.StatusMessageDeleted, 0x48},
+		{0x62, cimd.StatusLastNoResponse, 0x62},
+	}
+	for _, p := range patterns {
+		r, _ := tpdu.New(tpdu.SmsStatusReport)
+		r.ST = p.st
+		m, err := cimd.FromTPDU(r)
+		require.Nil(t, err)
+		v, _ := m.Params.Get(cimd.ParamStatusCode)
+		assert.Equal(t, strconv.Itoa(p.sc), v)
+		rt, err := m.TPDU()
+		require.Nil(t, err)
+		assert.Equal(t, p.rt, rt.ST)
+	}
+}
+
+func TestTPDU(t *testing.T) {
+	m := cimd.Message{
+		OC: cimd.OpSubmit,
+		Params: cimd.Params{
+			{cimd.ParamDestinationAddress, "1234"},
+			{cimd.ParamUserDataBinary, "0102"},
+		},
+	}
+	tp, err := m.TPDU()
+	require.Nil(t, err)
+	assert.Equal(t, tpdu.Dcs8BitData, tp.DCS)
+	assert.Equal(t, tpdu.UserData{1, 2}, tp.UD)
+
+	sr := func(sc string) cimd.Message {
+		return cimd.Message{
+			OC: cimd.OpDeliverStatusReport,
+			Params: cimd.Params{
+				{cimd.ParamServiceCentreTimeStamp, "200412103015"},
+				{cimd.ParamDischargeTime, "200412103015"},
+				{cimd.ParamStatusCode, sc},
+			},
+		}
+	}
+	submit := func(pp ...cimd.Param) cimd.Message {
+		return cimd.Message{OC: cimd.OpSubmit, Params: pp}
+	}
+	errPatterns := []struct {
+		name string
+		in   cimd.Message
+		err  error
+	}{
+		{"op", cimd.Message{OC: cimd.OpAlive}, cimd.ErrUnsupportedOpCode(cimd.OpAlive)},
+		{"vp absolute", submit(cimd.Param{cimd.ParamValidityPeriodAbsolute, "1"}), tpdu.NewDecodeError("051", 0, cimd.ErrInvalidTime("1"))},
+		{"vp relative", submit(cimd.Param{cimd.ParamValidityPeriodRelative, "256"}), tpdu.NewDecodeError("050", 0, tpdu.ErrInvalid)},
+		{"srr", submit(cimd.Param{cimd.ParamStatusReportRequest, "x"}), tpdu.NewDecodeError("056", 0, tpdu.ErrInvalid)},
+		{"scts", cimd.Message{OC: cimd.OpDeliverMessage}, tpdu.NewDecodeError("060", 0, cimd.ErrInvalidTime(""))},
+		{"pid", submit(cimd.Param{cimd.ParamProtocolIdentifier, "256"}), tpdu.NewDecodeError("052", 0, tpdu.ErrInvalid)},
+		{"dcs", submit(cimd.Param{cimd.ParamDataCodingScheme, "x"}), tpdu.NewDecodeError("030", 0, tpdu.ErrInvalid)},
+		{"udh hex", submit(cimd.Param{cimd.ParamUserDataHeader, "x"}), tpdu.NewDecodeError("032", 0, tpdu.ErrInvalid)},
+		{"udh", submit(cimd.Param{cimd.ParamUserDataHeader, "05"}), tpdu.NewDecodeError("032", 0, tpdu.NewDecodeError("ie", 1, tpdu.ErrUnderflow))},
+		{"ud binary", submit(cimd.Param{cimd.ParamUserDataBinary, "x"}), tpdu.NewDecodeError("034", 0, tpdu.ErrInvalid)},
+		{"ucs2", submit(cimd.Param{cimd.ParamDataCodingScheme, "8"}, cimd.Param{cimd.ParamUserDataBinary, "01"}), tpdu.NewDecodeError("034", 0, tpdu.ErrOddUCS2Length)},
+		{"ud", submit(cimd.Param{cimd.ParamUserData, "_"}), tpdu.NewDecodeError("033", 0, cimd.ErrInvalidCombination("_"))},
+		{"dt", cimd.Message{OC: cimd.OpDeliverStatusReport, Params: cimd.Params{{cimd.ParamServiceCentreTimeStamp, "200412103015"}}}, tpdu.NewDecodeError("063", 0, cimd.ErrInvalidTime(""))},
+		{"status code", sr("x"), tpdu.NewDecodeError("061", 0, tpdu.ErrInvalid)},
+		{"unknown status code", sr("10"), tpdu.NewDecodeError("061", 0, tpdu.ErrInvalid)},
+	}
+	for _, p := range errPatterns {
+		f := func(t *testing.T) {
+			tp, err := p.in.TPDU()
+			assert.Equal(t, p.err, err)
+			assert.Nil(t, tp)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestEncoderCollector(t *testing.T) {
+	msgs := []string{
+		"this is a very long message that does not fit in a single SMS message, with {braces} and_underscores, at least it will if I keep adding more to it as 160 characters is more than you might think",
+		"a long message with emoji 😁 that will be encoded as UCS2 and so requires less text to need segmentation, so this will do",
+	}
+	for _, msg := range msgs {
+		e := sms.NewEncoder(sms.AsDeliver, sms.From("1234"))
+		tpdus, err := e.Encode([]byte(msg))
+		require.Nil(t, err)
+		require.True(t, len(tpdus) > 1)
+		c := sms.NewCollector()
+		var segs []*tpdu.TPDU
+		for i := range tpdus {
+			m, err := cimd.FromTPDU(&tpdus[i])
+			require.Nil(t, err)
+			b, err := m.MarshalBinary()
+			require.Nil(t, err)
+			rm := cimd.Message{}
+			require.Nil(t, rm.UnmarshalBinary(b))
+			rt, err := rm.TPDU()
+			require.Nil(t, err)
+			segs, err = c.Collect(*rt)
+			require.Nil(t, err)
+		}
+		require.NotNil(t, segs)
+		m, err := sms.Decode(segs)
+		require.Nil(t, err)
+		assert.Equal(t, msg, string(m))
+	}
+}
@@ -144,6 +144,15 @@ func (c *Collector) Collect(pdu tpdu.TPDU) (d []*tpdu.TPDU, err error) {
 	return nil, err
 }
 
+// ConcatKey returns the key used by the Collector to identify the
+// concatenated message that the TPDU is a segment of.
+//
+// Only SMS-SUBMIT and SMS-DELIVER TPDUs are supported.
+func ConcatKey(pdu tpdu.TPDU) (string, error) {
+	segments, _, concatRef, _ := pdu.ConcatInfo()
+	return pduKey(pdu, segments, concatRef)
+}
+
 func pduKey(pdu tpdu.TPDU, segments, concatRef int) (string, error) {
 	st := pdu.SmsType()
 	var key string
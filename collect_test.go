@@ -621,3 +621,51 @@ func TestCollectorPipes(t *testing.T) {
 		assert.Equal(t, p.out, out, p.name)
 	}
 }
+
+func TestConcatKey(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   tpdu.TPDU
+		key  string
+		err  error
+	}{
+		{
+			"deliver",
+			tpdu.TPDU{
+				OA: tpdu.Address{Addr: "1234", TOA: 0x91},
+				UDH: tpdu.UserDataHeader{
+					tpdu.InformationElement{ID: 0, Data: []byte{3, 2, 1}},
+				},
+			},
+			"0:91:1234:3:2",
+			nil,
+		},
+		{
+			"submit",
+			tpdu.TPDU{
+				Direction:  tpdu.MO,
+				FirstOctet: 0x01,
+				DA:         tpdu.Address{Addr: "4321", TOA: 0x81},
+				UDH: tpdu.UserDataHeader{
+					tpdu.InformationElement{ID: 0, Data: []byte{3, 2, 2}},
+				},
+			},
+			"3:81:4321:3:2",
+			nil,
+		},
+		{
+			"status report",
+			tpdu.TPDU{FirstOctet: 0x02},
+			"",
+			tpdu.ErrUnsupportedSmsType(tpdu.SmsStatusReport),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			key, err := sms.ConcatKey(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.key, key)
+		}
+		t.Run(p.name, f)
+	}
+}
@@ -0,0 +1,284 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package diameter
+
+import (
+	"encoding/binary"
+	"net"
+)
+
+// AVPCode identifies an AVP.
+type AVPCode uint32
+
+const (
+	// AVPUserName is the User-Name AVP, which contains the IMSI.
+	AVPUserName AVPCode = 1
+
+	// AVPHostIPAddress is the Host-IP-Address AVP.
+	AVPHostIPAddress AVPCode = 257
+
+	// AVPAuthApplicationID is the Auth-Application-Id AVP.
+	AVPAuthApplicationID AVPCode = 258
+
+	// AVPVendorSpecificApplicationID is the Vendor-Specific-Application-Id
+	// AVP.
+	AVPVendorSpecificApplicationID AVPCode = 260
+
+	// AVPSessionID is the Session-Id AVP.
+	AVPSessionID AVPCode = 263
+
+	// AVPOriginHost is the Origin-Host AVP.
+	AVPOriginHost AVPCode = 264
+
+	// AVPVendorID is the Vendor-Id AVP.
+	AVPVendorID AVPCode = 266
+
+	// AVPResultCode is the Result-Code AVP.
+	AVPResultCode AVPCode = 268
+
+	// AVPProductName is the Product-Name AVP.
+	AVPProductName AVPCode = 269
+
+	// AVPAuthSessionState is the Auth-Session-State AVP.
+	AVPAuthSessionState AVPCode = 277
+
+	// AVPDestinationRealm is the Destination-Realm AVP.
+	AVPDestinationRealm AVPCode = 283
+
+	// AVPDestinationHost is the Destination-Host AVP.
+	AVPDestinationHost AVPCode = 293
+
+	// AVPOriginRealm is the Origin-Realm AVP.
+	AVPOriginRealm AVPCode = 296
+
+	// AVPExperimentalResult is the Experimental-Result AVP.
+	AVPExperimentalResult AVPCode = 297
+
+	// AVPExperimentalResultCode is the Experimental-Result-Code AVP.
+	AVPExperimentalResultCode AVPCode = 298
+
+	// AVPMSISDN is the 3GPP MSISDN AVP.
+	AVPMSISDN AVPCode = 701
+
+	// AVPUserIdentifier is the 3GPP User-Identifier AVP.
+	AVPUserIdentifier AVPCode = 3102
+
+	// AVPSCAddress is the 3GPP SC-Address AVP.
+	AVPSCAddress AVPCode = 3300
+
+	// AVPSMRPUI is the 3GPP SM-RP-UI AVP, which contains the TPDU.
+	AVPSMRPUI AVPCode = 3301
+
+	// AVPTFRFlags is the 3GPP TFR-Flags AVP.
+	AVPTFRFlags AVPCode = 3302
+
+	// AVPSMDeliveryFailureCause is the 3GPP SM-Delivery-Failure-Cause AVP.
+	AVPSMDeliveryFailureCause AVPCode = 3303
+
+	// AVPSMEnumeratedDeliveryFailureCause is the 3GPP
+	// SM-Enumerated-Delivery-Failure-Cause AVP.
+	AVPSMEnumeratedDeliveryFailureCause AVPCode = 3304
+
+	// AVPSMDiagnosticInfo is the 3GPP SM-Diagnostic-Info AVP.
+	AVPSMDiagnosticInfo AVPCode = 3305
+
+	// AVPSMDeliveryTimer is the 3GPP SM-Delivery-Timer AVP.
+	AVPSMDeliveryTimer AVPCode = 3306
+
+	// AVPSMDeliveryOutcome is the 3GPP SM-Delivery-Outcome AVP.
+	AVPSMDeliveryOutcome AVPCode = 3316
+
+	// AVPMMESMDeliveryOutcome is the 3GPP MME-SM-Delivery-Outcome AVP.
+	AVPMMESMDeliveryOutcome AVPCode = 3317
+
+	// AVPMSCSMDeliveryOutcome is the 3GPP MSC-SM-Delivery-Outcome AVP.
+	AVPMSCSMDeliveryOutcome AVPCode = 3318
+
+	// AVPSGSNSMDeliveryOutcome is the 3GPP SGSN-SM-Delivery-Outcome AVP.
+	AVPSGSNSMDeliveryOutcome AVPCode = 3319
+
+	// AVPIPSMGWSMDeliveryOutcome is the 3GPP IP-SM-GW-SM-Delivery-Outcome
+	// AVP.
+	AVPIPSMGWSMDeliveryOutcome AVPCode = 3320
+
+	// AVPSMDeliveryCause is the 3GPP SM-Delivery-Cause AVP.
+	AVPSMDeliveryCause AVPCode = 3321
+
+	// AVPAbsentUserDiagnosticSM is the 3GPP Absent-User-Diagnostic-SM AVP.
+	AVPAbsentUserDiagnosticSM AVPCode = 3322
+
+	// AVPOFRFlags is the 3GPP OFR-Flags AVP.
+	AVPOFRFlags AVPCode = 3328
+)
+
+// VendorID returns the Vendor-Id of the AVP code, for the AVPs defined by
+// this package.
+//
+// The 3GPP AVPs are those with codes from 700, and the remainder are base
+// protocol AVPs.
+func (c AVPCode) VendorID() uint32 {
+	if c >= 700 {
+		return Vendor3GPP
+	}
+	return 0
+}
+
+// AVPFlags are the flags in the AVP header.
+type AVPFlags byte
+
+const (
+	// AVPFlagVendor indicates the AVP header contains a Vendor-Id.
+	//
+	// This flag is derived from the VendorID, so is set when marshalling an
+	// AVP with a non-zero VendorID, and cleared when unmarshalling.
+	AVPFlagVendor AVPFlags = 0x80
+
+	// AVPFlagMandatory indicates the receiver must support the AVP.
+	AVPFlagMandatory AVPFlags = 0x40
+)
+
+// AVP is a Diameter Attribute-Value Pair.
+type AVP struct {
+	Code     AVPCode
+	Flags    AVPFlags
+	VendorID uint32
+	Data     []byte
+}
+
+// AVPs is an ordered set of AVPs, as contained in a message or grouped AVP.
+type AVPs []*AVP
+
+// Find returns the first AVP with the code, or nil if there is none.
+//
+// The VendorID must match that of the code, as returned by VendorID.
+func (aa AVPs) Find(c AVPCode) *AVP {
+	v := c.VendorID()
+	for _, a := range aa {
+		if a.Code == c && a.VendorID == v {
+			return a
+		}
+	}
+	return nil
+}
+
+// NewAVP creates a mandatory AVP, with the VendorID of the code, containing
+// the data.
+func NewAVP(c AVPCode, data []byte) *AVP {
+	return &AVP{Code: c, Flags: AVPFlagMandatory, VendorID: c.VendorID(), Data: data}
+}
+
+// NewUnsigned32 creates an AVP containing an Unsigned32 or Enumerated value.
+func NewUnsigned32(c AVPCode, v uint32) *AVP {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return NewAVP(c, b)
+}
+
+// NewUTF8String creates an AVP containing a UTF8String or DiameterIdentity.
+func NewUTF8String(c AVPCode, s string) *AVP {
+	return NewAVP(c, []byte(s))
+}
+
+// NewAddress creates an AVP containing an IP Address.
+func NewAddress(c AVPCode, ip net.IP) *AVP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return NewAVP(c, append([]byte{0, 1}, ip4...))
+	}
+	return NewAVP(c, append([]byte{0, 2}, ip.To16()...))
+}
+
+// NewGrouped creates a grouped AVP containing the AVPs.
+func NewGrouped(c AVPCode, avps ...*AVP) (*AVP, error) {
+	var b []byte
+	for _, a := range avps {
+		var err error
+		if b, err = a.append(b); err != nil {
+			return nil, err
+		}
+	}
+	return NewAVP(c, b), nil
+}
+
+// Unsigned32 returns the value of an Unsigned32 or Enumerated AVP.
+func (a *AVP) Unsigned32() (uint32, error) {
+	if len(a.Data) != 4 {
+		return 0, ErrInvalidAVPLength(a.Code)
+	}
+	return binary.BigEndian.Uint32(a.Data), nil
+}
+
+// UTF8String returns the value of a UTF8String or DiameterIdentity AVP.
+func (a *AVP) UTF8String() string {
+	return string(a.Data)
+}
+
+// Grouped returns the AVPs contained in a grouped AVP.
+func (a *AVP) Grouped() (AVPs, error) {
+	return unmarshalAVPs(a.Data)
+}
+
+// append appends the binary form of the AVP, including any padding, to b.
+func (a *AVP) append(b []byte) ([]byte, error) {
+	hl := 8
+	flags := a.Flags &^ AVPFlagVendor
+	if a.VendorID != 0 {
+		hl = 12
+		flags |= AVPFlagVendor
+	}
+	l := hl + len(a.Data)
+	if l > 0xffffff {
+		return nil, ErrInvalidAVPLength(a.Code)
+	}
+	h := make([]byte, hl)
+	binary.BigEndian.PutUint32(h, uint32(a.Code))
+	binary.BigEndian.PutUint32(h[4:], uint32(l))
+	h[4] = byte(flags)
+	if a.VendorID != 0 {
+		binary.BigEndian.PutUint32(h[8:], a.VendorID)
+	}
+	b = append(b, h...)
+	b = append(b, a.Data...)
+	for l%4 != 0 {
+		b = append(b, 0)
+		l++
+	}
+	return b, nil
+}
+
+// unmarshalAVPs decodes a sequence of AVPs, such as the body of a message or
+// the data of a grouped AVP.
+func unmarshalAVPs(b []byte) (AVPs, error) {
+	var aa AVPs
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, ErrInvalidLength(len(b))
+		}
+		flags := AVPFlags(b[4])
+		a := AVP{
+			Code:  AVPCode(binary.BigEndian.Uint32(b)),
+			Flags: flags &^ AVPFlagVendor,
+		}
+		l := int(binary.BigEndian.Uint32(b[4:]) & 0xffffff)
+		hl := 8
+		if flags&AVPFlagVendor != 0 {
+			hl = 12
+		}
+		if l < hl || l > len(b) {
+			return nil, ErrInvalidAVPLength(a.Code)
+		}
+		if hl == 12 {
+			a.VendorID = binary.BigEndian.Uint32(b[8:])
+		}
+		a.Data = append([]byte(nil), b[hl:l]...)
+		aa = append(aa, &a)
+		pl := (l + 3) &^ 3
+		if pl > len(b) {
+			// tolerate missing padding on the final AVP
+			pl = len(b)
+		}
+		b = b[pl:]
+	}
+	return aa, nil
+}
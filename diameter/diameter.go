@@ -0,0 +1,221 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package diameter provides encoding and decoding of the Diameter messages
+// used to transfer SMS over the SGd and Gdd interfaces, as defined in 3GPP TS
+// 29.338, and conversions between the SM-RP-UI they carry and TPDUs.
+//
+// The base protocol support is limited to that required by the SGd/Gdd
+// commands, and by the Peer used for testing.
+package diameter
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// CommandCode identifies a Diameter command.
+type CommandCode uint32
+
+const (
+	// CapabilitiesExchange is the Capabilities-Exchange command of RFC 6733.
+	CapabilitiesExchange CommandCode = 257
+
+	// DeviceWatchdog is the Device-Watchdog command of RFC 6733.
+	DeviceWatchdog CommandCode = 280
+
+	// DisconnectPeer is the Disconnect-Peer command of RFC 6733.
+	DisconnectPeer CommandCode = 282
+
+	// MOForwardShortMessage is the MO-Forward-Short-Message command, i.e.
+	// OFR/OFA, of 3GPP TS 29.338 Section 6.3.2.
+	MOForwardShortMessage CommandCode = 8388645
+
+	// MTForwardShortMessage is the MT-Forward-Short-Message command, i.e.
+	// TFR/TFA, of 3GPP TS 29.338 Section 6.3.2.
+	MTForwardShortMessage CommandCode = 8388646
+)
+
+var commandNames = map[CommandCode]string{
+	CapabilitiesExchange:  "Capabilities-Exchange",
+	DeviceWatchdog:        "Device-Watchdog",
+	DisconnectPeer:        "Disconnect-Peer",
+	MOForwardShortMessage: "MO-Forward-Short-Message",
+	MTForwardShortMessage: "MT-Forward-Short-Message",
+}
+
+func (c CommandCode) String() string {
+	if n, ok := commandNames[c]; ok {
+		return n
+	}
+	return "Unknown"
+}
+
+// Flags are the command flags in the message header.
+type Flags byte
+
+const (
+	// FlagRequest indicates the message is a request.
+	FlagRequest Flags = 0x80
+
+	// FlagProxiable indicates the message may be proxied, relayed or
+	// redirected.
+	FlagProxiable Flags = 0x40
+
+	// FlagError indicates the message contains a protocol error.
+	FlagError Flags = 0x20
+
+	// FlagRetransmitted indicates the message is a potential retransmission.
+	FlagRetransmitted Flags = 0x10
+)
+
+const (
+	// Version is the Diameter protocol version.
+	Version = 1
+
+	// HeaderLen is the length of the message header.
+	HeaderLen = 20
+
+	// MaxMessageLen is the maximum length of a message accepted by
+	// ReadMessage.
+	MaxMessageLen = 0x10000
+
+	// AppIDCommon is the Application-Id of the base protocol messages.
+	AppIDCommon = 0
+
+	// AppIDSGd is the Application-Id of the SGd and Gdd interfaces.
+	AppIDSGd = 16777313
+
+	// Vendor3GPP is the Vendor-Id of 3GPP.
+	Vendor3GPP = 10415
+)
+
+// Message is a Diameter message.
+type Message struct {
+	Flags    Flags
+	Command  CommandCode
+	AppID    uint32
+	HopByHop uint32
+	EndToEnd uint32
+	AVPs     AVPs
+}
+
+// IsRequest indicates if the message is a request rather than an answer.
+func (m *Message) IsRequest() bool {
+	return m.Flags&FlagRequest != 0
+}
+
+// MarshalBinary encodes the message into its binary form.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	b := make([]byte, HeaderLen, 256)
+	binary.BigEndian.PutUint32(b[4:], uint32(m.Command))
+	b[4] = byte(m.Flags)
+	binary.BigEndian.PutUint32(b[8:], m.AppID)
+	binary.BigEndian.PutUint32(b[12:], m.HopByHop)
+	binary.BigEndian.PutUint32(b[16:], m.EndToEnd)
+	for _, a := range m.AVPs {
+		var err error
+		if b, err = a.append(b); err != nil {
+			return nil, err
+		}
+	}
+	if len(b) > 0xffffff {
+		return nil, ErrInvalidLength(len(b))
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+	b[0] = Version
+	return b, nil
+}
+
+// UnmarshalBinary decodes the message from its binary form.
+func (m *Message) UnmarshalBinary(b []byte) error {
+	if len(b) < HeaderLen {
+		return ErrInvalidLength(len(b))
+	}
+	if b[0] != Version {
+		return ErrUnsupportedVersion(b[0])
+	}
+	l := int(binary.BigEndian.Uint32(b) & 0xffffff)
+	if l != len(b) {
+		return ErrInvalidLength(l)
+	}
+	avps, err := unmarshalAVPs(b[HeaderLen:])
+	if err != nil {
+		return err
+	}
+	*m = Message{
+		Flags:    Flags(b[4]),
+		Command:  CommandCode(binary.BigEndian.Uint32(b[4:]) & 0xffffff),
+		AppID:    binary.BigEndian.Uint32(b[8:]),
+		HopByHop: binary.BigEndian.Uint32(b[12:]),
+		EndToEnd: binary.BigEndian.Uint32(b[16:]),
+		AVPs:     avps,
+	}
+	return nil
+}
+
+// Answer returns an answer to the request, with the Session-Id, if any,
+// copied from the request.
+//
+// The returned message has the header set appropriately.
+// Any AVPs required by the answer must be added by the caller.
+func (m *Message) Answer() *Message {
+	a := Message{
+		Flags:    m.Flags & FlagProxiable,
+		Command:  m.Command,
+		AppID:    m.AppID,
+		HopByHop: m.HopByHop,
+		EndToEnd: m.EndToEnd,
+	}
+	if s := m.AVPs.Find(AVPSessionID); s != nil {
+		a.AVPs = append(a.AVPs, s)
+	}
+	return &a
+}
+
+// ResultCode returns the Result-Code, or the Experimental-Result-Code if
+// there is no Result-Code, of an answer.
+//
+// Returns zero if the answer has neither.
+func (m *Message) ResultCode() uint32 {
+	return resultCode(m.AVPs)
+}
+
+// ReadMessage reads a single message from the reader.
+//
+// The header is read first to determine the length of the message, and then
+// the remainder of the message.
+func ReadMessage(r io.Reader) (*Message, error) {
+	h := make([]byte, HeaderLen)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	l := int(binary.BigEndian.Uint32(h) & 0xffffff)
+	if l < HeaderLen || l > MaxMessageLen {
+		return nil, ErrInvalidLength(l)
+	}
+	b := make([]byte, l)
+	copy(b, h)
+	if _, err := io.ReadFull(r, b[HeaderLen:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	m := Message{}
+	if err := m.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// WriteMessage marshals the message and writes it to the writer.
+func WriteMessage(w io.Writer, m *Message) error {
+	b, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
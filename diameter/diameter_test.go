@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package diameter_test
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/diameter"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+const (
+	// DWR from host "a" in realm "b".
+	dwr = "0100002C80000118000000000000000100000002" +
+		"0000010840000009" + "61000000" +
+		"0000012840000009" + "62000000"
+
+	// MSISDN AVP containing +6391.
+	msisdnAVP = "000002BDC000000E000028AF" + "36190000"
+)
+
+func TestMessageMarshalBinary(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   diameter.Message
+		out  string
+	}{
+		{
+			"dwr",
+			diameter.Message{
+				Flags:    diameter.FlagRequest,
+				Command:  diameter.DeviceWatchdog,
+				HopByHop: 1,
+				EndToEnd: 2,
+				AVPs: diameter.AVPs{
+					diameter.NewUTF8String(diameter.AVPOriginHost, "a"),
+					diameter.NewUTF8String(diameter.AVPOriginRealm, "b"),
+				},
+			},
+			dwr,
+		},
+		{
+			"vendor",
+			diameter.Message{
+				Command: diameter.MOForwardShortMessage,
+				AppID:   diameter.AppIDSGd,
+				AVPs: diameter.AVPs{
+					diameter.NewAVP(diameter.AVPMSISDN, []byte{0x36, 0x19}),
+				},
+			},
+			"010000240080002501000061" + "0000000000000000" + msisdnAVP,
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.in.MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, fixture.Hex(t, p.out), b)
+
+			m := diameter.Message{}
+			err = m.UnmarshalBinary(b)
+			require.Nil(t, err)
+			assert.Equal(t, p.in, m)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestMessageUnmarshalBinary(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{
+			"empty",
+			"",
+			diameter.ErrInvalidLength(0),
+		},
+		{
+			"version",
+			"0200001400000118000000000000000000000000",
+			diameter.ErrUnsupportedVersion(2),
+		},
+		{
+			"length mismatch",
+			"0100001800000118000000000000000000000000",
+			diameter.ErrInvalidLength(0x18),
+		},
+		{
+			"short avp",
+			"010000180000011800000000000000000000000000000108",
+			diameter.ErrInvalidLength(4),
+		},
+		{
+			"avp overlength",
+			"0100001C00000118000000000000000000000000" + "000001084000000A",
+			diameter.ErrInvalidAVPLength(diameter.AVPOriginHost),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := diameter.Message{}
+			err := m.UnmarshalBinary(fixture.Hex(t, p.in))
+			assert.Equal(t, p.err, err)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestAVPs(t *testing.T) {
+	g, err := diameter.NewGrouped(diameter.AVPUserIdentifier,
+		diameter.NewUTF8String(diameter.AVPUserName, "505011234567890"),
+		diameter.NewAVP(diameter.AVPMSISDN, []byte{0x36, 0x19}))
+	require.Nil(t, err)
+	assert.Equal(t, uint32(diameter.Vendor3GPP), g.VendorID)
+
+	aa, err := g.Grouped()
+	require.Nil(t, err)
+	require.Len(t, aa, 2)
+	assert.Equal(t, "505011234567890", aa.Find(diameter.AVPUserName).UTF8String())
+	assert.Equal(t, []byte{0x36, 0x19}, aa.Find(diameter.AVPMSISDN).Data)
+	assert.Nil(t, aa.Find(diameter.AVPSCAddress))
+
+	v, err := diameter.NewUnsigned32(diameter.AVPResultCode, 2001).Unsigned32()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2001), v)
+	_, err = diameter.NewUTF8String(diameter.AVPResultCode, "a").Unsigned32()
+	assert.Equal(t, diameter.ErrInvalidAVPLength(diameter.AVPResultCode), err)
+
+	a := diameter.NewAddress(diameter.AVPHostIPAddress, net.IPv4(10, 0, 0, 1))
+	assert.Equal(t, []byte{0, 1, 10, 0, 0, 1}, a.Data)
+}
+
+func TestResultCode(t *testing.T) {
+	m := diameter.Message{}
+	assert.Equal(t, uint32(0), m.ResultCode())
+
+	er, err := diameter.NewGrouped(diameter.AVPExperimentalResult,
+		diameter.NewUnsigned32(diameter.AVPVendorID, diameter.Vendor3GPP),
+		diameter.NewUnsigned32(diameter.AVPExperimentalResultCode, diameter.ResultErrorAbsentUser))
+	require.Nil(t, err)
+	m.AVPs = append(m.AVPs, er)
+	assert.Equal(t, uint32(diameter.ResultErrorAbsentUser), m.ResultCode())
+
+	m.AVPs = append(m.AVPs, diameter.NewUnsigned32(diameter.AVPResultCode, diameter.ResultSuccess))
+	assert.Equal(t, uint32(diameter.ResultSuccess), m.ResultCode())
+}
+
+func TestReadMessage(t *testing.T) {
+	b := fixture.Hex(t, dwr)
+	r := bytes.NewReader(append(append([]byte{}, b...), b...))
+	for i := 0; i < 2; i++ {
+		m, err := diameter.ReadMessage(r)
+		require.Nil(t, err)
+		assert.Equal(t, diameter.DeviceWatchdog, m.Command)
+		assert.True(t, m.IsRequest())
+	}
+	_, err := diameter.ReadMessage(r)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = diameter.ReadMessage(bytes.NewReader(b[:30]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = diameter.ReadMessage(bytes.NewReader(fixture.Hex(t, "01FFFFFF00000118000000000000000000000000")))
+	assert.Equal(t, diameter.ErrInvalidLength(0xffffff), err)
+}
+
+func TestWriteMessage(t *testing.T) {
+	var buf bytes.Buffer
+	m := diameter.Message{
+		Flags:    diameter.FlagRequest,
+		Command:  diameter.DeviceWatchdog,
+		HopByHop: 1,
+		EndToEnd: 2,
+		AVPs: diameter.AVPs{
+			diameter.NewUTF8String(diameter.AVPOriginHost, "a"),
+			diameter.NewUTF8String(diameter.AVPOriginRealm, "b"),
+		},
+	}
+	err := diameter.WriteMessage(&buf, &m)
+	require.Nil(t, err)
+	assert.Equal(t, fixture.Hex(t, dwr), buf.Bytes())
+}
+
+func TestAnswer(t *testing.T) {
+	req := diameter.Message{
+		Flags:    diameter.FlagRequest | diameter.FlagProxiable | diameter.FlagRetransmitted,
+		Command:  diameter.MOForwardShortMessage,
+		AppID:    diameter.AppIDSGd,
+		HopByHop: 3,
+		EndToEnd: 4,
+		AVPs: diameter.AVPs{
+			diameter.NewUTF8String(diameter.AVPSessionID, "s1"),
+			diameter.NewUTF8String(diameter.AVPOriginHost, "a"),
+		},
+	}
+	a := req.Answer()
+	assert.Equal(t, &diameter.Message{
+		Flags:    diameter.FlagProxiable,
+		Command:  diameter.MOForwardShortMessage,
+		AppID:    diameter.AppIDSGd,
+		HopByHop: 3,
+		EndToEnd: 4,
+		AVPs: diameter.AVPs{
+			diameter.NewUTF8String(diameter.AVPSessionID, "s1"),
+		},
+	}, a)
+	assert.False(t, a.IsRequest())
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package diameter
+
+import (
+	"fmt"
+)
+
+// ErrInvalidLength indicates the length of a message is invalid.
+type ErrInvalidLength int
+
+func (e ErrInvalidLength) Error() string {
+	return fmt.Sprintf("diameter: invalid length %d", int(e))
+}
+
+// ErrUnsupportedVersion indicates the version of a message is not supported.
+type ErrUnsupportedVersion byte
+
+func (e ErrUnsupportedVersion) Error() string {
+	return fmt.Sprintf("diameter: unsupported version %d", int(e))
+}
+
+// ErrInvalidAVPLength indicates the length of an AVP is invalid, either in
+// its header or for the type of its data.
+type ErrInvalidAVPLength AVPCode
+
+func (e ErrInvalidAVPLength) Error() string {
+	return fmt.Sprintf("diameter: invalid length for AVP %d", int(e))
+}
+
+// ErrMissingAVP indicates a mandatory AVP is absent.
+type ErrMissingAVP AVPCode
+
+func (e ErrMissingAVP) Error() string {
+	return fmt.Sprintf("diameter: missing AVP %d", int(e))
+}
+
+// ErrUnexpectedCommand indicates a message is not the expected command, or
+// is a request rather than an answer or vice versa.
+type ErrUnexpectedCommand CommandCode
+
+func (e ErrUnexpectedCommand) Error() string {
+	return fmt.Sprintf("diameter: unexpected command %s (%d)", CommandCode(e), int(e))
+}
+
+// ErrInvalidNumber indicates a number cannot be encoded as a TBCD string.
+type ErrInvalidNumber string
+
+func (e ErrInvalidNumber) Error() string {
+	return fmt.Sprintf("diameter: invalid number '%s'", string(e))
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package diameter
+
+import (
+	"bufio"
+	"net"
+)
+
+// productName is the Product-Name advertised by the Peer.
+const productName = "warthog618/sms"
+
+// Peer is a minimal Diameter peer, intended for testing, that serves the SGd
+// and Gdd commands over stream connections, such as TCP.
+//
+// The Peer responds to the Capabilities-Exchange, Device-Watchdog and
+// Disconnect-Peer requests, and passes OFRs and TFRs to the corresponding
+// handler.
+// Requests for other commands are rejected with DIAMETER_COMMAND_UNSUPPORTED.
+// Answers received by the Peer are ignored.
+//
+// The zero value is ready for use, and accepts all short messages.
+type Peer struct {
+	// OriginHost is the Origin-Host of the Peer.
+	OriginHost string
+
+	// OriginRealm is the Origin-Realm of the Peer.
+	OriginRealm string
+
+	// OFRHandler, if set, is called for each OFR received.
+	//
+	// The returned answer is sent in reply, with the Origin-Host and
+	// Origin-Realm of the Peer if they are not set.
+	// If the handler is nil, or returns nil, the OFR is answered with
+	// DIAMETER_SUCCESS.
+	OFRHandler func(r *OFR) *OFA
+
+	// TFRHandler, if set, is called for each TFR received.
+	//
+	// The returned answer is handled as for the OFRHandler.
+	TFRHandler func(r *TFR) *TFA
+}
+
+// Serve accepts connections on the listener and serves each with ServeConn.
+//
+// Serve returns when the listener is closed.
+func (p *Peer) Serve(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go p.ServeConn(nc)
+	}
+}
+
+// ServeConn serves Diameter requests on the connection until it is closed,
+// either by the remote peer or by a Disconnect-Peer request.
+func (p *Peer) ServeConn(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	for {
+		m, err := ReadMessage(r)
+		if err != nil {
+			return
+		}
+		if !m.IsRequest() {
+			continue
+		}
+		a := p.handle(m, nc.LocalAddr())
+		if err := WriteMessage(nc, a); err != nil {
+			return
+		}
+		if m.Command == DisconnectPeer {
+			return
+		}
+	}
+}
+
+// handle processes a received request and returns the answer.
+func (p *Peer) handle(m *Message, local net.Addr) *Message {
+	switch m.Command {
+	case CapabilitiesExchange:
+		a := p.answer(m, ResultSuccess)
+		ip := net.IPv4(127, 0, 0, 1)
+		if ta, ok := local.(*net.TCPAddr); ok && ta.IP != nil {
+			ip = ta.IP
+		}
+		// Product-Name must not be flagged mandatory
+		pn := NewUTF8String(AVPProductName, productName)
+		pn.Flags = 0
+		a.AVPs = append(a.AVPs,
+			NewAddress(AVPHostIPAddress, ip),
+			NewUnsigned32(AVPVendorID, 0),
+			pn,
+			vendorSpecificApplicationID())
+		return a
+	case DeviceWatchdog, DisconnectPeer:
+		return p.answer(m, ResultSuccess)
+	case MOForwardShortMessage:
+		r, err := ParseOFR(m)
+		if err != nil {
+			return p.parseError(m, err)
+		}
+		var oa *OFA
+		if p.OFRHandler != nil {
+			oa = p.OFRHandler(r)
+		}
+		if oa == nil {
+			oa = &OFA{ForwardSMAnswer{ResultCode: ResultSuccess}}
+		}
+		return p.forwardSMAnswer(m, &oa.ForwardSMAnswer)
+	case MTForwardShortMessage:
+		r, err := ParseTFR(m)
+		if err != nil {
+			return p.parseError(m, err)
+		}
+		var ta *TFA
+		if p.TFRHandler != nil {
+			ta = p.TFRHandler(r)
+		}
+		if ta == nil {
+			ta = &TFA{ForwardSMAnswer{ResultCode: ResultSuccess}}
+		}
+		return p.forwardSMAnswer(m, &ta.ForwardSMAnswer)
+	default:
+		a := p.answer(m, ResultCommandUnsupported)
+		a.Flags |= FlagError
+		return a
+	}
+}
+
+// answer returns a base protocol answer to the request with the result code.
+func (p *Peer) answer(m *Message, rc uint32) *Message {
+	a := m.Answer()
+	a.AVPs = append(a.AVPs,
+		NewUnsigned32(AVPResultCode, rc),
+		NewUTF8String(AVPOriginHost, p.OriginHost),
+		NewUTF8String(AVPOriginRealm, p.OriginRealm))
+	return a
+}
+
+// forwardSMAnswer returns the encoded OFA or TFA, defaulting the origin to
+// that of the Peer.
+func (p *Peer) forwardSMAnswer(m *Message, fa *ForwardSMAnswer) *Message {
+	if fa.OriginHost == "" {
+		fa.OriginHost = p.OriginHost
+	}
+	if fa.OriginRealm == "" {
+		fa.OriginRealm = p.OriginRealm
+	}
+	a, err := fa.answer(m)
+	if err != nil {
+		return p.answer(m, ResultUnableToComply)
+	}
+	return a
+}
+
+// parseError returns the answer to a request that could not be parsed.
+func (p *Peer) parseError(m *Message, err error) *Message {
+	if _, ok := err.(ErrMissingAVP); ok {
+		return p.answer(m, ResultMissingAVP)
+	}
+	return p.answer(m, ResultInvalidAVPValue)
+}
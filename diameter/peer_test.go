@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package diameter_test
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/diameter"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+// exchange sends the request to the peer and returns the answer.
+func exchange(t *testing.T, nc net.Conn, req *diameter.Message) *diameter.Message {
+	t.Helper()
+	nc.SetDeadline(time.Now().Add(time.Second))
+	err := diameter.WriteMessage(nc, req)
+	require.Nil(t, err)
+	a, err := diameter.ReadMessage(nc)
+	require.Nil(t, err)
+	assert.Equal(t, req.HopByHop, a.HopByHop)
+	assert.Equal(t, req.EndToEnd, a.EndToEnd)
+	return a
+}
+
+// newPeer starts the peer on a TCP listener and returns a connection to it,
+// and a function to close both.
+func newPeer(t *testing.T, p *diameter.Peer) (net.Conn, func()) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	go p.Serve(l)
+	nc, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		l.Close()
+	}
+	require.Nil(t, err)
+	return nc, func() {
+		nc.Close()
+		l.Close()
+	}
+}
+
+func baseRequest(c diameter.CommandCode, hbh uint32) *diameter.Message {
+	return &diameter.Message{
+		Flags:    diameter.FlagRequest,
+		Command:  c,
+		HopByHop: hbh,
+		EndToEnd: hbh,
+		AVPs: diameter.AVPs{
+			diameter.NewUTF8String(diameter.AVPOriginHost, "client.example.com"),
+			diameter.NewUTF8String(diameter.AVPOriginRealm, "example.com"),
+		},
+	}
+}
+
+func TestPeerBase(t *testing.T) {
+	nc, closer := newPeer(t, &diameter.Peer{OriginHost: "peer.example.com", OriginRealm: "example.com"})
+	defer closer()
+
+	a := exchange(t, nc, baseRequest(diameter.CapabilitiesExchange, 1))
+	assert.Equal(t, diameter.CapabilitiesExchange, a.Command)
+	assert.False(t, a.IsRequest())
+	assert.Equal(t, uint32(diameter.ResultSuccess), a.ResultCode())
+	assert.Equal(t, "peer.example.com", a.AVPs.Find(diameter.AVPOriginHost).UTF8String())
+	assert.Equal(t, []byte{0, 1, 127, 0, 0, 1}, a.AVPs.Find(diameter.AVPHostIPAddress).Data)
+	assert.NotNil(t, a.AVPs.Find(diameter.AVPProductName))
+	vsa := a.AVPs.Find(diameter.AVPVendorSpecificApplicationID)
+	require.NotNil(t, vsa)
+	va, err := vsa.Grouped()
+	require.Nil(t, err)
+	appID, err := va.Find(diameter.AVPAuthApplicationID).Unsigned32()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(diameter.AppIDSGd), appID)
+
+	a = exchange(t, nc, baseRequest(diameter.DeviceWatchdog, 2))
+	assert.Equal(t, diameter.DeviceWatchdog, a.Command)
+	assert.Equal(t, uint32(diameter.ResultSuccess), a.ResultCode())
+
+	a = exchange(t, nc, baseRequest(diameter.CommandCode(272), 3))
+	assert.Equal(t, diameter.FlagError, a.Flags)
+	assert.Equal(t, uint32(diameter.ResultCommandUnsupported), a.ResultCode())
+
+	a = exchange(t, nc, baseRequest(diameter.DisconnectPeer, 4))
+	assert.Equal(t, diameter.DisconnectPeer, a.Command)
+	assert.Equal(t, uint32(diameter.ResultSuccess), a.ResultCode())
+	_, err = diameter.ReadMessage(nc)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestPeerOFR(t *testing.T) {
+	var rx *diameter.OFR
+	p := diameter.Peer{
+		OriginHost:  "iwmsc.example.com",
+		OriginRealm: "example.com",
+		OFRHandler: func(r *diameter.OFR) *diameter.OFA {
+			rx = r
+			return &diameter.OFA{diameter.ForwardSMAnswer{
+				ResultCode: diameter.ResultSuccess,
+				UI:         fixture.TPDU(t, tpdu.MT, submitReportTPDU),
+			}}
+		},
+	}
+	nc, closer := newPeer(t, &p)
+	defer closer()
+
+	r := diameter.OFR{
+		SessionID:        "mme.example.com;1;1",
+		OriginHost:       "mme.example.com",
+		OriginRealm:      "example.com",
+		DestinationRealm: "example.com",
+		SCAddress:        "+639170000293",
+		UI:               fixture.TPDU(t, tpdu.MO, submitTPDU),
+	}
+	req, err := r.Message()
+	require.Nil(t, err)
+	req.HopByHop = 7
+	a := exchange(t, nc, req)
+	assert.Equal(t, &r, rx)
+
+	oa, err := diameter.ParseOFA(a)
+	require.Nil(t, err)
+	assert.Equal(t, r.SessionID, oa.SessionID)
+	assert.Equal(t, uint32(diameter.ResultSuccess), oa.ResultCode)
+	assert.Equal(t, "iwmsc.example.com", oa.OriginHost)
+	assert.Equal(t, fixture.TPDU(t, tpdu.MT, submitReportTPDU), oa.UI)
+
+	// missing SM-RP-UI
+	req.AVPs = req.AVPs[:len(req.AVPs)-1]
+	req.HopByHop = 8
+	a = exchange(t, nc, req)
+	assert.Equal(t, uint32(diameter.ResultMissingAVP), a.ResultCode())
+}
+
+func TestPeerTFR(t *testing.T) {
+	// nil handler accepts everything
+	nc, closer := newPeer(t, &diameter.Peer{OriginHost: "mme.example.com", OriginRealm: "example.com"})
+	defer closer()
+
+	r := diameter.TFR{
+		SessionID:        "gmsc.example.com;1;1",
+		OriginHost:       "gmsc.example.com",
+		OriginRealm:      "example.com",
+		DestinationRealm: "example.com",
+		UserName:         "505011234567890",
+		SCAddress:        "+639170000293",
+		UI:               fixture.TPDU(t, tpdu.MT, deliverTPDU),
+	}
+	req, err := r.Message()
+	require.Nil(t, err)
+	a := exchange(t, nc, req)
+	ta, err := diameter.ParseTFA(a)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(diameter.ResultSuccess), ta.ResultCode)
+	assert.Equal(t, "mme.example.com", ta.OriginHost)
+	assert.Nil(t, ta.UI)
+}
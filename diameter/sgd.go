@@ -0,0 +1,687 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package diameter
+
+import (
+	"strings"
+
+	"github.com/warthog618/sms/encoding/semioctet"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Result codes returned in the Result-Code AVP, as defined in RFC 6733
+// Section 7.1.
+const (
+	ResultSuccess            = 2001
+	ResultCommandUnsupported = 3001
+	ResultInvalidAVPValue    = 5004
+	ResultMissingAVP         = 5005
+	ResultUnableToComply     = 5012
+)
+
+// Result codes returned in the Experimental-Result-Code AVP, as defined in
+// 3GPP TS 29.338 Section 6.4.
+const (
+	ResultErrorUserUnknown          = 5001
+	ResultErrorAbsentUser           = 5550
+	ResultErrorUserBusyForMTSMS     = 5551
+	ResultErrorFacilityNotSupported = 5552
+	ResultErrorIllegalUser          = 5553
+	ResultErrorIllegalEquipment     = 5554
+	ResultErrorSMDeliveryFailure    = 5555
+	ResultErrorServiceNotSubscribed = 5556
+	ResultErrorServiceBarred        = 5557
+	ResultErrorMWDListFull          = 5558
+)
+
+// authSessionStateNoState is the NO_STATE_MAINTAINED Auth-Session-State.
+const authSessionStateNoState = 1
+
+// OFR-Flags and TFR-Flags bits.
+const (
+	// OFRFlagS6aS6dIndicator indicates the OFR is sent on the Gdd
+	// interface.
+	OFRFlagS6aS6dIndicator = 0x01
+
+	// tfrFlagMoreMessagesToSend indicates further messages are waiting
+	// for the recipient.
+	tfrFlagMoreMessagesToSend = 0x01
+)
+
+// DeliveryFailureCause is the value of the SM-Enumerated-Delivery-Failure-Cause
+// AVP, as defined in 3GPP TS 29.338 Section 6.3.3.5.
+type DeliveryFailureCause uint32
+
+const (
+	// CauseMemoryCapacityExceeded indicates the recipient has no memory
+	// available.
+	CauseMemoryCapacityExceeded DeliveryFailureCause = iota
+
+	// CauseEquipmentProtocolError indicates a protocol error in the
+	// recipient equipment.
+	CauseEquipmentProtocolError
+
+	// CauseEquipmentNotSMEquipped indicates the recipient equipment does not
+	// support SMS.
+	CauseEquipmentNotSMEquipped
+
+	// CauseUnknownServiceCentre indicates the SC is unknown.
+	CauseUnknownServiceCentre
+
+	// CauseSCCongestion indicates the SC is congested.
+	CauseSCCongestion
+
+	// CauseInvalidSMEAddress indicates the SME address is invalid.
+	CauseInvalidSMEAddress
+
+	// CauseUserNotSCUser indicates the user is not a user of the SC.
+	CauseUserNotSCUser
+)
+
+// SMDeliveryFailureCause is the grouped SM-Delivery-Failure-Cause AVP.
+type SMDeliveryFailureCause struct {
+	Cause DeliveryFailureCause
+
+	// DiagnosticInfo is the optional SM-Diagnostic-Info.
+	DiagnosticInfo []byte
+}
+
+func (c *SMDeliveryFailureCause) avp() (*AVP, error) {
+	aa := []*AVP{NewUnsigned32(AVPSMEnumeratedDeliveryFailureCause, uint32(c.Cause))}
+	if len(c.DiagnosticInfo) > 0 {
+		aa = append(aa, NewAVP(AVPSMDiagnosticInfo, c.DiagnosticInfo))
+	}
+	return NewGrouped(AVPSMDeliveryFailureCause, aa...)
+}
+
+func parseSMDeliveryFailureCause(a *AVP) (*SMDeliveryFailureCause, error) {
+	aa, err := a.Grouped()
+	if err != nil {
+		return nil, err
+	}
+	c, err := requireUnsigned32(aa, AVPSMEnumeratedDeliveryFailureCause)
+	if err != nil {
+		return nil, err
+	}
+	fc := SMDeliveryFailureCause{Cause: DeliveryFailureCause(c)}
+	if d := aa.Find(AVPSMDiagnosticInfo); d != nil {
+		fc.DiagnosticInfo = d.Data
+	}
+	return &fc, nil
+}
+
+// DeliveryCause is the value of the SM-Delivery-Cause AVP, as defined in 3GPP
+// TS 29.338 Section 5.3.3.20.
+type DeliveryCause uint32
+
+const (
+	// DeliveryUEMemoryCapacityExceeded indicates delivery failed as the UE
+	// has no memory available.
+	DeliveryUEMemoryCapacityExceeded DeliveryCause = iota
+
+	// DeliveryAbsentUser indicates delivery failed as the UE is absent.
+	DeliveryAbsentUser
+
+	// DeliverySuccessfulTransfer indicates the message was delivered.
+	DeliverySuccessfulTransfer
+)
+
+// NodeDeliveryOutcome is the outcome of a delivery attempt via a node, i.e.
+// the content of an MME-, MSC-, SGSN- or IP-SM-GW-SM-Delivery-Outcome AVP.
+type NodeDeliveryOutcome struct {
+	Cause DeliveryCause
+
+	// AbsentUserDiagnostic is the Absent-User-Diagnostic-SM, which is only
+	// included if the Cause is DeliveryAbsentUser.
+	AbsentUserDiagnostic uint32
+}
+
+// SMDeliveryOutcome is the grouped SM-Delivery-Outcome AVP, reporting the
+// outcome of delivery attempts via each node.
+type SMDeliveryOutcome struct {
+	MME    *NodeDeliveryOutcome
+	MSC    *NodeDeliveryOutcome
+	SGSN   *NodeDeliveryOutcome
+	IPSMGW *NodeDeliveryOutcome
+}
+
+// nodeOutcomeCodes are the AVP codes of the per-node outcomes, in order.
+var nodeOutcomeCodes = []AVPCode{
+	AVPMMESMDeliveryOutcome,
+	AVPMSCSMDeliveryOutcome,
+	AVPSGSNSMDeliveryOutcome,
+	AVPIPSMGWSMDeliveryOutcome,
+}
+
+func (o *SMDeliveryOutcome) nodes() []**NodeDeliveryOutcome {
+	return []**NodeDeliveryOutcome{&o.MME, &o.MSC, &o.SGSN, &o.IPSMGW}
+}
+
+func (o *SMDeliveryOutcome) avp() (*AVP, error) {
+	var aa []*AVP
+	for i, n := range o.nodes() {
+		if *n == nil {
+			continue
+		}
+		na := []*AVP{NewUnsigned32(AVPSMDeliveryCause, uint32((*n).Cause))}
+		if (*n).Cause == DeliveryAbsentUser {
+			na = append(na, NewUnsigned32(AVPAbsentUserDiagnosticSM, (*n).AbsentUserDiagnostic))
+		}
+		a, err := NewGrouped(nodeOutcomeCodes[i], na...)
+		if err != nil {
+			return nil, err
+		}
+		aa = append(aa, a)
+	}
+	return NewGrouped(AVPSMDeliveryOutcome, aa...)
+}
+
+func parseSMDeliveryOutcome(a *AVP) (*SMDeliveryOutcome, error) {
+	aa, err := a.Grouped()
+	if err != nil {
+		return nil, err
+	}
+	o := SMDeliveryOutcome{}
+	nodes := o.nodes()
+	for i, c := range nodeOutcomeCodes {
+		na := aa.Find(c)
+		if na == nil {
+			continue
+		}
+		ga, err := na.Grouped()
+		if err != nil {
+			return nil, err
+		}
+		dc, err := requireUnsigned32(ga, AVPSMDeliveryCause)
+		if err != nil {
+			return nil, err
+		}
+		n := NodeDeliveryOutcome{Cause: DeliveryCause(dc)}
+		if d := ga.Find(AVPAbsentUserDiagnosticSM); d != nil {
+			if n.AbsentUserDiagnostic, err = d.Unsigned32(); err != nil {
+				return nil, err
+			}
+		}
+		*nodes[i] = &n
+	}
+	return &o, nil
+}
+
+// OFR is the MO-Forward-Short-Message-Request, as defined in 3GPP TS 29.338
+// Section 6.3.2.3, carrying an SMS-SUBMIT or SMS-COMMAND from the MME or
+// SGSN to the SMS-IWMSC.
+type OFR struct {
+	SessionID        string
+	OriginHost       string
+	OriginRealm      string
+	DestinationHost  string
+	DestinationRealm string
+
+	// SCAddress is the E.164 number of the SC.
+	SCAddress string
+
+	// UserName is the optional IMSI of the originator, carried in the
+	// User-Identifier.
+	UserName string
+
+	// MSISDN is the optional MSISDN of the originator, carried in the
+	// User-Identifier.
+	MSISDN string
+
+	// Flags is the OFR-Flags, which is omitted if zero.
+	Flags uint32
+
+	// UI is the TPDU carried in the SM-RP-UI.
+	UI *tpdu.TPDU
+
+	// DeliveryOutcome is the optional SM-Delivery-Outcome.
+	DeliveryOutcome *SMDeliveryOutcome
+}
+
+// Message encodes the request as a Diameter message.
+//
+// The HopByHop and EndToEnd identifiers are left for the sender to assign.
+func (r *OFR) Message() (*Message, error) {
+	m := newRequest(MOForwardShortMessage, r.SessionID, r.OriginHost, r.OriginRealm, r.DestinationHost, r.DestinationRealm)
+	sca, err := marshalNumber(r.SCAddress)
+	if err != nil {
+		return nil, tpdu.EncodeError("SC-Address", err)
+	}
+	m.AVPs = append(m.AVPs, NewAVP(AVPSCAddress, sca))
+	if r.UserName != "" || r.MSISDN != "" {
+		var ua []*AVP
+		if r.UserName != "" {
+			ua = append(ua, NewUTF8String(AVPUserName, r.UserName))
+		}
+		if r.MSISDN != "" {
+			msisdn, err := marshalNumber(r.MSISDN)
+			if err != nil {
+				return nil, tpdu.EncodeError("MSISDN", err)
+			}
+			ua = append(ua, NewAVP(AVPMSISDN, msisdn))
+		}
+		a, err := NewGrouped(AVPUserIdentifier, ua...)
+		if err != nil {
+			return nil, tpdu.EncodeError("User-Identifier", err)
+		}
+		m.AVPs = append(m.AVPs, a)
+	}
+	if r.Flags != 0 {
+		m.AVPs = append(m.AVPs, NewUnsigned32(AVPOFRFlags, r.Flags))
+	}
+	if err = m.appendUI(r.UI, true); err != nil {
+		return nil, err
+	}
+	if r.DeliveryOutcome != nil {
+		a, err := r.DeliveryOutcome.avp()
+		if err != nil {
+			return nil, tpdu.EncodeError("SM-Delivery-Outcome", err)
+		}
+		m.AVPs = append(m.AVPs, a)
+	}
+	return m, nil
+}
+
+// ParseOFR decodes an OFR from a Diameter message.
+func ParseOFR(m *Message) (*OFR, error) {
+	if m.Command != MOForwardShortMessage || !m.IsRequest() {
+		return nil, ErrUnexpectedCommand(m.Command)
+	}
+	r := OFR{}
+	var err error
+	if r.SessionID, r.OriginHost, r.OriginRealm, err = parseCommon(m.AVPs); err != nil {
+		return nil, err
+	}
+	r.DestinationHost, r.DestinationRealm = parseDestination(m.AVPs)
+	if r.SCAddress, err = parseNumber(m.AVPs, AVPSCAddress); err != nil {
+		return nil, err
+	}
+	if ui := m.AVPs.Find(AVPUserIdentifier); ui != nil {
+		ua, err := ui.Grouped()
+		if err != nil {
+			return nil, tpdu.NewDecodeError("User-Identifier", 0, err)
+		}
+		if un := ua.Find(AVPUserName); un != nil {
+			r.UserName = un.UTF8String()
+		}
+		if ua.Find(AVPMSISDN) != nil {
+			if r.MSISDN, err = parseNumber(ua, AVPMSISDN); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if f := m.AVPs.Find(AVPOFRFlags); f != nil {
+		if r.Flags, err = f.Unsigned32(); err != nil {
+			return nil, err
+		}
+	}
+	if r.UI, err = parseUI(m.AVPs, tpdu.MO, true); err != nil {
+		return nil, err
+	}
+	if o := m.AVPs.Find(AVPSMDeliveryOutcome); o != nil {
+		if r.DeliveryOutcome, err = parseSMDeliveryOutcome(o); err != nil {
+			return nil, tpdu.NewDecodeError("SM-Delivery-Outcome", 0, err)
+		}
+	}
+	return &r, nil
+}
+
+// ForwardSMAnswer is the content common to the OFA and TFA.
+type ForwardSMAnswer struct {
+	// SessionID is the Session-Id of the answer.
+	//
+	// This is populated when parsing, and is copied from the request when
+	// encoding.
+	SessionID string
+
+	// ResultCode is the Result-Code, which is omitted if zero.
+	ResultCode uint32
+
+	// ExperimentalResultCode is the 3GPP Experimental-Result-Code, which is
+	// omitted if zero.
+	ExperimentalResultCode uint32
+
+	OriginHost  string
+	OriginRealm string
+
+	// DeliveryFailureCause is the optional SM-Delivery-Failure-Cause.
+	DeliveryFailureCause *SMDeliveryFailureCause
+
+	// UI is the optional SMS-SUBMIT-REPORT or SMS-DELIVER-REPORT carried in
+	// the SM-RP-UI.
+	UI *tpdu.TPDU
+}
+
+// OFA is the MO-Forward-Short-Message-Answer, as defined in 3GPP TS 29.338
+// Section 6.3.2.4.
+type OFA struct {
+	ForwardSMAnswer
+}
+
+// Answer encodes the answer as a Diameter message in reply to the request.
+func (a *OFA) Answer(req *Message) (*Message, error) {
+	return a.ForwardSMAnswer.answer(req)
+}
+
+// ParseOFA decodes an OFA from a Diameter message.
+func ParseOFA(m *Message) (*OFA, error) {
+	if m.Command != MOForwardShortMessage || m.IsRequest() {
+		return nil, ErrUnexpectedCommand(m.Command)
+	}
+	a := OFA{}
+	if err := a.ForwardSMAnswer.parse(m, tpdu.MT); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// TFR is the MT-Forward-Short-Message-Request, as defined in 3GPP TS 29.338
+// Section 6.3.2.5, carrying an SMS-DELIVER or SMS-STATUS-REPORT from the
+// SMS-GMSC to the MME or SGSN.
+type TFR struct {
+	SessionID        string
+	OriginHost       string
+	OriginRealm      string
+	DestinationHost  string
+	DestinationRealm string
+
+	// UserName is the IMSI of the recipient.
+	UserName string
+
+	// SCAddress is the E.164 number of the SC.
+	SCAddress string
+
+	// UI is the TPDU carried in the SM-RP-UI.
+	UI *tpdu.TPDU
+
+	// MoreMessagesToSend is carried in the TFR-Flags.
+	MoreMessagesToSend bool
+
+	// DeliveryTimer is the SM-Delivery-Timer, in seconds, which is omitted
+	// if zero.
+	DeliveryTimer uint32
+}
+
+// Message encodes the request as a Diameter message.
+//
+// The HopByHop and EndToEnd identifiers are left for the sender to assign.
+func (r *TFR) Message() (*Message, error) {
+	m := newRequest(MTForwardShortMessage, r.SessionID, r.OriginHost, r.OriginRealm, r.DestinationHost, r.DestinationRealm)
+	if r.UserName == "" {
+		return nil, tpdu.EncodeError("User-Name", ErrMissingAVP(AVPUserName))
+	}
+	m.AVPs = append(m.AVPs, NewUTF8String(AVPUserName, r.UserName))
+	sca, err := marshalNumber(r.SCAddress)
+	if err != nil {
+		return nil, tpdu.EncodeError("SC-Address", err)
+	}
+	m.AVPs = append(m.AVPs, NewAVP(AVPSCAddress, sca))
+	if err = m.appendUI(r.UI, true); err != nil {
+		return nil, err
+	}
+	if r.MoreMessagesToSend {
+		m.AVPs = append(m.AVPs, NewUnsigned32(AVPTFRFlags, tfrFlagMoreMessagesToSend))
+	}
+	if r.DeliveryTimer != 0 {
+		m.AVPs = append(m.AVPs, NewUnsigned32(AVPSMDeliveryTimer, r.DeliveryTimer))
+	}
+	return m, nil
+}
+
+// ParseTFR decodes a TFR from a Diameter message.
+func ParseTFR(m *Message) (*TFR, error) {
+	if m.Command != MTForwardShortMessage || !m.IsRequest() {
+		return nil, ErrUnexpectedCommand(m.Command)
+	}
+	r := TFR{}
+	var err error
+	if r.SessionID, r.OriginHost, r.OriginRealm, err = parseCommon(m.AVPs); err != nil {
+		return nil, err
+	}
+	r.DestinationHost, r.DestinationRealm = parseDestination(m.AVPs)
+	un := m.AVPs.Find(AVPUserName)
+	if un == nil {
+		return nil, ErrMissingAVP(AVPUserName)
+	}
+	r.UserName = un.UTF8String()
+	if r.SCAddress, err = parseNumber(m.AVPs, AVPSCAddress); err != nil {
+		return nil, err
+	}
+	if r.UI, err = parseUI(m.AVPs, tpdu.MT, true); err != nil {
+		return nil, err
+	}
+	if f := m.AVPs.Find(AVPTFRFlags); f != nil {
+		flags, err := f.Unsigned32()
+		if err != nil {
+			return nil, err
+		}
+		r.MoreMessagesToSend = flags&tfrFlagMoreMessagesToSend != 0
+	}
+	if t := m.AVPs.Find(AVPSMDeliveryTimer); t != nil {
+		if r.DeliveryTimer, err = t.Unsigned32(); err != nil {
+			return nil, err
+		}
+	}
+	return &r, nil
+}
+
+// TFA is the MT-Forward-Short-Message-Answer, as defined in 3GPP TS 29.338
+// Section 6.3.2.6.
+type TFA struct {
+	ForwardSMAnswer
+}
+
+// Answer encodes the answer as a Diameter message in reply to the request.
+func (a *TFA) Answer(req *Message) (*Message, error) {
+	return a.ForwardSMAnswer.answer(req)
+}
+
+// ParseTFA decodes a TFA from a Diameter message.
+func ParseTFA(m *Message) (*TFA, error) {
+	if m.Command != MTForwardShortMessage || m.IsRequest() {
+		return nil, ErrUnexpectedCommand(m.Command)
+	}
+	a := TFA{}
+	if err := a.ForwardSMAnswer.parse(m, tpdu.MO); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (a *ForwardSMAnswer) answer(req *Message) (*Message, error) {
+	m := req.Answer()
+	m.AVPs = append(m.AVPs, vendorSpecificApplicationID())
+	if a.ResultCode != 0 {
+		m.AVPs = append(m.AVPs, NewUnsigned32(AVPResultCode, a.ResultCode))
+	}
+	if a.ExperimentalResultCode != 0 {
+		er, _ := NewGrouped(AVPExperimentalResult,
+			NewUnsigned32(AVPVendorID, Vendor3GPP),
+			NewUnsigned32(AVPExperimentalResultCode, a.ExperimentalResultCode))
+		m.AVPs = append(m.AVPs, er)
+	}
+	m.AVPs = append(m.AVPs,
+		NewUnsigned32(AVPAuthSessionState, authSessionStateNoState),
+		NewUTF8String(AVPOriginHost, a.OriginHost),
+		NewUTF8String(AVPOriginRealm, a.OriginRealm))
+	if a.DeliveryFailureCause != nil {
+		fc, err := a.DeliveryFailureCause.avp()
+		if err != nil {
+			return nil, tpdu.EncodeError("SM-Delivery-Failure-Cause", err)
+		}
+		m.AVPs = append(m.AVPs, fc)
+	}
+	if err := m.appendUI(a.UI, false); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (a *ForwardSMAnswer) parse(m *Message, d tpdu.Direction) error {
+	var err error
+	if a.SessionID, a.OriginHost, a.OriginRealm, err = parseCommon(m.AVPs); err != nil {
+		return err
+	}
+	if rc := m.AVPs.Find(AVPResultCode); rc != nil {
+		if a.ResultCode, err = rc.Unsigned32(); err != nil {
+			return err
+		}
+	}
+	if er := m.AVPs.Find(AVPExperimentalResult); er != nil {
+		ea, err := er.Grouped()
+		if err != nil {
+			return tpdu.NewDecodeError("Experimental-Result", 0, err)
+		}
+		if a.ExperimentalResultCode, err = requireUnsigned32(ea, AVPExperimentalResultCode); err != nil {
+			return err
+		}
+	}
+	if fc := m.AVPs.Find(AVPSMDeliveryFailureCause); fc != nil {
+		if a.DeliveryFailureCause, err = parseSMDeliveryFailureCause(fc); err != nil {
+			return tpdu.NewDecodeError("SM-Delivery-Failure-Cause", 0, err)
+		}
+	}
+	a.UI, err = parseUI(m.AVPs, d, false)
+	return err
+}
+
+// newRequest creates a request with the AVPs common to the SGd requests.
+func newRequest(c CommandCode, sessionID, originHost, originRealm, destHost, destRealm string) *Message {
+	m := Message{
+		Flags:   FlagRequest | FlagProxiable,
+		Command: c,
+		AppID:   AppIDSGd,
+	}
+	m.AVPs = AVPs{
+		NewUTF8String(AVPSessionID, sessionID),
+		vendorSpecificApplicationID(),
+		NewUnsigned32(AVPAuthSessionState, authSessionStateNoState),
+		NewUTF8String(AVPOriginHost, originHost),
+		NewUTF8String(AVPOriginRealm, originRealm),
+	}
+	if destHost != "" {
+		m.AVPs = append(m.AVPs, NewUTF8String(AVPDestinationHost, destHost))
+	}
+	m.AVPs = append(m.AVPs, NewUTF8String(AVPDestinationRealm, destRealm))
+	return &m
+}
+
+// vendorSpecificApplicationID returns the Vendor-Specific-Application-Id
+// identifying the SGd application.
+func vendorSpecificApplicationID() *AVP {
+	a, _ := NewGrouped(AVPVendorSpecificApplicationID,
+		NewUnsigned32(AVPVendorID, Vendor3GPP),
+		NewUnsigned32(AVPAuthApplicationID, AppIDSGd))
+	return a
+}
+
+// appendUI appends the SM-RP-UI containing the TPDU.
+func (m *Message) appendUI(t *tpdu.TPDU, mandatory bool) error {
+	if t == nil {
+		if mandatory {
+			return tpdu.EncodeError("SM-RP-UI", ErrMissingAVP(AVPSMRPUI))
+		}
+		return nil
+	}
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return tpdu.EncodeError("SM-RP-UI", err)
+	}
+	m.AVPs = append(m.AVPs, NewAVP(AVPSMRPUI, b))
+	return nil
+}
+
+// parseUI decodes the TPDU, with the given direction, from the SM-RP-UI.
+func parseUI(aa AVPs, d tpdu.Direction, mandatory bool) (*tpdu.TPDU, error) {
+	a := aa.Find(AVPSMRPUI)
+	if a == nil {
+		if mandatory {
+			return nil, ErrMissingAVP(AVPSMRPUI)
+		}
+		return nil, nil
+	}
+	t := tpdu.TPDU{Direction: d}
+	if err := t.UnmarshalBinary(a.Data); err != nil {
+		return nil, tpdu.NewDecodeError("SM-RP-UI", 0, err)
+	}
+	return &t, nil
+}
+
+// parseCommon decodes the Session-Id, Origin-Host and Origin-Realm.
+func parseCommon(aa AVPs) (sessionID, originHost, originRealm string, err error) {
+	for _, c := range []AVPCode{AVPSessionID, AVPOriginHost, AVPOriginRealm} {
+		if aa.Find(c) == nil {
+			return "", "", "", ErrMissingAVP(c)
+		}
+	}
+	return aa.Find(AVPSessionID).UTF8String(),
+		aa.Find(AVPOriginHost).UTF8String(),
+		aa.Find(AVPOriginRealm).UTF8String(),
+		nil
+}
+
+// parseDestination decodes the optional Destination-Host and
+// Destination-Realm.
+func parseDestination(aa AVPs) (host, realm string) {
+	if a := aa.Find(AVPDestinationHost); a != nil {
+		host = a.UTF8String()
+	}
+	if a := aa.Find(AVPDestinationRealm); a != nil {
+		realm = a.UTF8String()
+	}
+	return
+}
+
+// marshalNumber encodes an E.164 number, which may have a leading '+', as a
+// TBCD string.
+func marshalNumber(n string) ([]byte, error) {
+	n = strings.TrimPrefix(n, "+")
+	if n == "" {
+		return nil, ErrInvalidNumber(n)
+	}
+	return semioctet.Encode([]byte(n))
+}
+
+// parseNumber decodes the E.164 number, in international format, from the
+// TBCD string in the AVP.
+func parseNumber(aa AVPs, c AVPCode) (string, error) {
+	a := aa.Find(c)
+	if a == nil {
+		return "", ErrMissingAVP(c)
+	}
+	if len(a.Data) == 0 {
+		return "", ErrInvalidAVPLength(c)
+	}
+	d, _, err := semioctet.Decode(make([]byte, 2*len(a.Data)), a.Data)
+	if err != nil {
+		return "", err
+	}
+	return "+" + string(d), nil
+}
+
+// requireUnsigned32 returns the value of the mandatory Unsigned32 AVP.
+func requireUnsigned32(aa AVPs, c AVPCode) (uint32, error) {
+	a := aa.Find(c)
+	if a == nil {
+		return 0, ErrMissingAVP(c)
+	}
+	return a.Unsigned32()
+}
+
+// resultCode returns the Result-Code, or failing that the
+// Experimental-Result-Code, in the AVPs.
+func resultCode(aa AVPs) uint32 {
+	if a := aa.Find(AVPResultCode); a != nil {
+		v, _ := a.Unsigned32()
+		return v
+	}
+	if a := aa.Find(AVPExperimentalResult); a != nil {
+		if ea, err := a.Grouped(); err == nil {
+			v, _ := requireUnsigned32(ea, AVPExperimentalResultCode)
+			return v
+		}
+	}
+	return 0
+}
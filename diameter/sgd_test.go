@@ -0,0 +1,345 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package diameter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/diameter"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+const (
+	// SMS-SUBMIT to 6391 containing "hello".
+	submitTPDU = "0142049136190000" + "05E8329BFD06"
+
+	// SMS-DELIVER from 6391 containing "Hahahaha".
+	deliverTPDU = "040491361900005150713220052308C8303A8C0EA3C3"
+
+	// SMS-DELIVER-REPORT with FCS memory capacity exceeded.
+	deliverReportTPDU = "00D300"
+
+	// SMS-SUBMIT-REPORT with FCS SC busy.
+	submitReportTPDU = "01C000" + "51507132200523"
+)
+
+// remarshal passes the message through its binary form, as if it had been
+// sent to a peer.
+func remarshal(t *testing.T, m *diameter.Message) *diameter.Message {
+	t.Helper()
+	b, err := m.MarshalBinary()
+	require.Nil(t, err)
+	r := diameter.Message{}
+	err = r.UnmarshalBinary(b)
+	require.Nil(t, err)
+	return &r
+}
+
+func TestOFR(t *testing.T) {
+	patterns := []struct {
+		name string
+		ofr  func(t *testing.T) *diameter.OFR
+	}{
+		{
+			"minimal",
+			func(t *testing.T) *diameter.OFR {
+				return &diameter.OFR{
+					SessionID:        "mme.example.com;1;1",
+					OriginHost:       "mme.example.com",
+					OriginRealm:      "example.com",
+					DestinationRealm: "example.com",
+					SCAddress:        "+639170000293",
+					UI:               fixture.TPDU(t, tpdu.MO, submitTPDU),
+				}
+			},
+		},
+		{
+			"full",
+			func(t *testing.T) *diameter.OFR {
+				return &diameter.OFR{
+					SessionID:        "mme.example.com;1;2",
+					OriginHost:       "mme.example.com",
+					OriginRealm:      "example.com",
+					DestinationHost:  "iwmsc.example.com",
+					DestinationRealm: "example.com",
+					SCAddress:        "+639170000293",
+					UserName:         "505011234567890",
+					MSISDN:           "+6391",
+					Flags:            diameter.OFRFlagS6aS6dIndicator,
+					UI:               fixture.TPDU(t, tpdu.MO, submitTPDU),
+					DeliveryOutcome: &diameter.SMDeliveryOutcome{
+						MME: &diameter.NodeDeliveryOutcome{
+							Cause:                diameter.DeliveryAbsentUser,
+							AbsentUserDiagnostic: 7,
+						},
+						IPSMGW: &diameter.NodeDeliveryOutcome{
+							Cause: diameter.DeliverySuccessfulTransfer,
+						},
+					},
+				}
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r := p.ofr(t)
+			m, err := r.Message()
+			require.Nil(t, err)
+			assert.True(t, m.IsRequest())
+			assert.Equal(t, diameter.MOForwardShortMessage, m.Command)
+			assert.Equal(t, uint32(diameter.AppIDSGd), m.AppID)
+
+			pr, err := diameter.ParseOFR(remarshal(t, m))
+			require.Nil(t, err)
+			assert.Equal(t, r, pr)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestOFRMessageError(t *testing.T) {
+	r := diameter.OFR{SCAddress: "+6391"}
+	_, err := r.Message()
+	assert.Equal(t, tpdu.EncodeError("SM-RP-UI", diameter.ErrMissingAVP(diameter.AVPSMRPUI)), err)
+
+	r = diameter.OFR{UI: fixture.TPDU(t, tpdu.MO, submitTPDU)}
+	_, err = r.Message()
+	assert.Equal(t, tpdu.EncodeError("SC-Address", diameter.ErrInvalidNumber("")), err)
+}
+
+func TestParseOFRError(t *testing.T) {
+	r := diameter.OFR{
+		SessionID:        "s1",
+		OriginHost:       "a",
+		OriginRealm:      "b",
+		DestinationRealm: "b",
+		SCAddress:        "+639170000293",
+		UI:               fixture.TPDU(t, tpdu.MO, submitTPDU),
+	}
+	m, err := r.Message()
+	require.Nil(t, err)
+
+	patterns := []struct {
+		name string
+		mod  func(m *diameter.Message)
+		err  error
+	}{
+		{
+			"answer",
+			func(m *diameter.Message) {
+				m.Flags &^= diameter.FlagRequest
+			},
+			diameter.ErrUnexpectedCommand(diameter.MOForwardShortMessage),
+		},
+		{
+			"command",
+			func(m *diameter.Message) {
+				m.Command = diameter.MTForwardShortMessage
+			},
+			diameter.ErrUnexpectedCommand(diameter.MTForwardShortMessage),
+		},
+		{
+			"session",
+			func(m *diameter.Message) {
+				m.AVPs = m.AVPs[1:]
+			},
+			diameter.ErrMissingAVP(diameter.AVPSessionID),
+		},
+		{
+			"sc address",
+			func(m *diameter.Message) {
+				m.AVPs.Find(diameter.AVPSCAddress).Code = diameter.AVPSMDeliveryTimer
+			},
+			diameter.ErrMissingAVP(diameter.AVPSCAddress),
+		},
+		{
+			"ui",
+			func(m *diameter.Message) {
+				m.AVPs.Find(diameter.AVPSMRPUI).Code = diameter.AVPSMDeliveryTimer
+			},
+			diameter.ErrMissingAVP(diameter.AVPSMRPUI),
+		},
+		{
+			"flags",
+			func(m *diameter.Message) {
+				m.AVPs = append(m.AVPs, diameter.NewAVP(diameter.AVPOFRFlags, []byte{1}))
+			},
+			diameter.ErrInvalidAVPLength(diameter.AVPOFRFlags),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			pm := remarshal(t, m)
+			p.mod(pm)
+			_, err := diameter.ParseOFR(pm)
+			assert.Equal(t, p.err, err)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestOFA(t *testing.T) {
+	r := diameter.OFR{
+		SessionID:        "mme.example.com;1;1",
+		OriginHost:       "mme.example.com",
+		OriginRealm:      "example.com",
+		DestinationRealm: "example.com",
+		SCAddress:        "+639170000293",
+		UI:               fixture.TPDU(t, tpdu.MO, submitTPDU),
+	}
+	req, err := r.Message()
+	require.Nil(t, err)
+	req.HopByHop = 5
+	req.EndToEnd = 6
+
+	patterns := []struct {
+		name string
+		ofa  func(t *testing.T) *diameter.OFA
+		rc   uint32
+	}{
+		{
+			"success",
+			func(t *testing.T) *diameter.OFA {
+				return &diameter.OFA{diameter.ForwardSMAnswer{
+					ResultCode:  diameter.ResultSuccess,
+					OriginHost:  "iwmsc.example.com",
+					OriginRealm: "example.com",
+				}}
+			},
+			diameter.ResultSuccess,
+		},
+		{
+			"failure",
+			func(t *testing.T) *diameter.OFA {
+				return &diameter.OFA{diameter.ForwardSMAnswer{
+					ExperimentalResultCode: diameter.ResultErrorSMDeliveryFailure,
+					OriginHost:             "iwmsc.example.com",
+					OriginRealm:            "example.com",
+					DeliveryFailureCause: &diameter.SMDeliveryFailureCause{
+						Cause:          diameter.CauseSCCongestion,
+						DiagnosticInfo: []byte{1, 2},
+					},
+					UI: fixture.TPDU(t, tpdu.MT, submitReportTPDU),
+				}}
+			},
+			diameter.ResultErrorSMDeliveryFailure,
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			a := p.ofa(t)
+			m, err := a.Answer(req)
+			require.Nil(t, err)
+			assert.False(t, m.IsRequest())
+			assert.Equal(t, uint32(5), m.HopByHop)
+			assert.Equal(t, uint32(6), m.EndToEnd)
+			assert.Equal(t, p.rc, m.ResultCode())
+
+			pa, err := diameter.ParseOFA(remarshal(t, m))
+			require.Nil(t, err)
+			a.SessionID = r.SessionID
+			assert.Equal(t, a, pa)
+		}
+		t.Run(p.name, f)
+	}
+
+	_, err = diameter.ParseOFA(req)
+	assert.Equal(t, diameter.ErrUnexpectedCommand(diameter.MOForwardShortMessage), err)
+}
+
+func TestTFR(t *testing.T) {
+	patterns := []struct {
+		name string
+		tfr  func(t *testing.T) *diameter.TFR
+	}{
+		{
+			"minimal",
+			func(t *testing.T) *diameter.TFR {
+				return &diameter.TFR{
+					SessionID:        "gmsc.example.com;1;1",
+					OriginHost:       "gmsc.example.com",
+					OriginRealm:      "example.com",
+					DestinationHost:  "mme.example.com",
+					DestinationRealm: "example.com",
+					UserName:         "505011234567890",
+					SCAddress:        "+639170000293",
+					UI:               fixture.TPDU(t, tpdu.MT, deliverTPDU),
+				}
+			},
+		},
+		{
+			"full",
+			func(t *testing.T) *diameter.TFR {
+				return &diameter.TFR{
+					SessionID:          "gmsc.example.com;1;2",
+					OriginHost:         "gmsc.example.com",
+					OriginRealm:        "example.com",
+					DestinationRealm:   "example.com",
+					UserName:           "505011234567890",
+					SCAddress:          "+639170000293",
+					UI:                 fixture.TPDU(t, tpdu.MT, deliverTPDU),
+					MoreMessagesToSend: true,
+					DeliveryTimer:      600,
+				}
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r := p.tfr(t)
+			m, err := r.Message()
+			require.Nil(t, err)
+			assert.True(t, m.IsRequest())
+			assert.Equal(t, diameter.MTForwardShortMessage, m.Command)
+
+			pr, err := diameter.ParseTFR(remarshal(t, m))
+			require.Nil(t, err)
+			assert.Equal(t, r, pr)
+		}
+		t.Run(p.name, f)
+	}
+
+	r := diameter.TFR{SCAddress: "+6391", UI: fixture.TPDU(t, tpdu.MT, deliverTPDU)}
+	_, err := r.Message()
+	assert.Equal(t, tpdu.EncodeError("User-Name", diameter.ErrMissingAVP(diameter.AVPUserName)), err)
+}
+
+func TestTFA(t *testing.T) {
+	r := diameter.TFR{
+		SessionID:        "gmsc.example.com;1;1",
+		OriginHost:       "gmsc.example.com",
+		OriginRealm:      "example.com",
+		DestinationRealm: "example.com",
+		UserName:         "505011234567890",
+		SCAddress:        "+639170000293",
+		UI:               fixture.TPDU(t, tpdu.MT, deliverTPDU),
+	}
+	req, err := r.Message()
+	require.Nil(t, err)
+
+	a := diameter.TFA{diameter.ForwardSMAnswer{
+		ExperimentalResultCode: diameter.ResultErrorSMDeliveryFailure,
+		OriginHost:             "mme.example.com",
+		OriginRealm:            "example.com",
+		DeliveryFailureCause: &diameter.SMDeliveryFailureCause{
+			Cause: diameter.CauseMemoryCapacityExceeded,
+		},
+		UI: fixture.TPDU(t, tpdu.MO, deliverReportTPDU),
+	}}
+	m, err := a.Answer(req)
+	require.Nil(t, err)
+	assert.Equal(t, diameter.MTForwardShortMessage, m.Command)
+
+	pa, err := diameter.ParseTFA(remarshal(t, m))
+	require.Nil(t, err)
+	a.SessionID = r.SessionID
+	assert.Equal(t, &a, pa)
+
+	_, err = diameter.ParseTFA(req)
+	assert.Equal(t, diameter.ErrUnexpectedCommand(diameter.MTForwardShortMessage), err)
+}
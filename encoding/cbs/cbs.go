@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package cbs provides encoding and decoding of Cell Broadcast Service
+// messages, as defined in 3GPP TS 23.041.
+//
+// This includes the GSM and UMTS message formats, the ETWS primary
+// notification, and the classification of public warning messages, i.e.
+// ETWS and CMAS.
+package cbs
+
+import (
+	"encoding/binary"
+
+	"github.com/warthog618/sms/encoding/cbsdcs"
+)
+
+const (
+	// PageSize is the size of a GSM CBS page.
+	PageSize = 88
+
+	// ContentSize is the size of the content of a page.
+	ContentSize = 82
+
+	// MaxPages is the maximum number of pages in a GSM CBS message.
+	MaxPages = 15
+)
+
+// GeographicalScope indicates the area over which the message code is unique,
+// and the display mode.
+type GeographicalScope int
+
+const (
+	// GSCellImmediate indicates a cell wide scope, with the message displayed
+	// immediately.
+	GSCellImmediate GeographicalScope = iota
+
+	// GSPLMN indicates a PLMN wide scope.
+	GSPLMN
+
+	// GSLocationArea indicates a location area, or service area in UMTS, or
+	// tracking area in LTE, wide scope.
+	GSLocationArea
+
+	// GSCell indicates a cell wide scope.
+	GSCell
+)
+
+// SerialNumber identifies a particular message, and its version, from a
+// source and type of message identified by the message identifier.
+//
+// It comprises the geographical scope, the message code and the update
+// number, as defined in 3GPP TS 23.041 Section 9.4.1.2.1.
+type SerialNumber uint16
+
+// NewSerialNumber creates a SerialNumber from its fields.
+//
+// The code is truncated to 10 bits and the update number to 4 bits.
+func NewSerialNumber(gs GeographicalScope, code, update int) SerialNumber {
+	return SerialNumber(int(gs&0x3)<<14 | (code&0x3ff)<<4 | update&0xf)
+}
+
+// GS returns the geographical scope.
+func (s SerialNumber) GS() GeographicalScope {
+	return GeographicalScope(s >> 14)
+}
+
+// MessageCode returns the message code, which distinguishes messages with
+// the same message identifier and geographical scope.
+func (s SerialNumber) MessageCode() int {
+	return int(s>>4) & 0x3ff
+}
+
+// UpdateNumber returns the update number, which distinguishes versions of
+// the same message.
+func (s SerialNumber) UpdateNumber() int {
+	return int(s) & 0xf
+}
+
+// Page is a GSM CBS page, as defined in 3GPP TS 23.041 Section 9.4.1.2.
+type Page struct {
+	Serial SerialNumber
+
+	// MessageID identifies the source and type of the message.
+	MessageID uint16
+
+	DCS cbsdcs.DCS
+
+	// Page is the number of the page, starting at 1.
+	Page int
+
+	// Pages is the total number of pages in the message.
+	Pages int
+
+	// Content is the content of the page, padded to ContentSize.
+	Content []byte
+}
+
+// MarshalBinary encodes the page into its binary form.
+func (p *Page) MarshalBinary() ([]byte, error) {
+	if p.Page < 1 || p.Page > MaxPages || p.Pages < p.Page || p.Pages > MaxPages {
+		return nil, ErrInvalidPageParameter(p.Page<<4 | p.Pages&0xf)
+	}
+	if len(p.Content) > ContentSize {
+		return nil, ErrOverlength(len(p.Content))
+	}
+	b := make([]byte, 6, PageSize)
+	binary.BigEndian.PutUint16(b, uint16(p.Serial))
+	binary.BigEndian.PutUint16(b[2:], p.MessageID)
+	b[4] = byte(p.DCS)
+	b[5] = byte(p.Page<<4 | p.Pages)
+	b = append(b, p.Content...)
+	for len(b) < PageSize {
+		b = append(b, 0)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the page from its binary form.
+//
+// A page parameter of 0 is treated as page 1 of 1.
+func (p *Page) UnmarshalBinary(b []byte) error {
+	if len(b) != PageSize {
+		return ErrInvalidLength(len(b))
+	}
+	pp := int(b[5])
+	page := pp >> 4
+	pages := pp & 0xf
+	if pp == 0 {
+		page = 1
+		pages = 1
+	}
+	if page == 0 || pages < page {
+		return ErrInvalidPageParameter(pp)
+	}
+	p.Serial = SerialNumber(binary.BigEndian.Uint16(b))
+	p.MessageID = binary.BigEndian.Uint16(b[2:])
+	p.DCS = cbsdcs.DCS(b[4])
+	p.Page = page
+	p.Pages = pages
+	p.Content = append([]byte(nil), b[6:]...)
+	return nil
+}
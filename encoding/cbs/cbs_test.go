@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cbs_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/cbs"
+	"github.com/warthog618/sms/encoding/cbsdcs"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+// crPadding is the packed CR padding of a GSM7 page, for 8 CRs starting on
+// an octet boundary.
+const crPadding = "341A8D46A3D168"
+
+const cr = 0x0d
+
+var (
+	// helloPage is "Hello" in a single GSM7 page.
+	helloPage = "523500320F11" + "C8329BFD6E" + strings.Repeat(crPadding, 11)[:152] + "00"
+
+	// hiPage is "Hi" in a single UCS2 page.
+	hiPage = "523500324811" + "00480069" + strings.Repeat("000D", 39)
+)
+
+func TestSerialNumber(t *testing.T) {
+	s := cbs.NewSerialNumber(cbs.GSPLMN, 0x123, 5)
+	assert.Equal(t, cbs.SerialNumber(0x5235), s)
+	assert.Equal(t, cbs.GSPLMN, s.GS())
+	assert.Equal(t, 0x123, s.MessageCode())
+	assert.Equal(t, 5, s.UpdateNumber())
+
+	s = cbs.NewSerialNumber(cbs.GSCell, 0x7ff, 0x1f)
+	assert.Equal(t, cbs.SerialNumber(0xfff), s&0xfff)
+	assert.Equal(t, cbs.GSCell, s.GS())
+	assert.Equal(t, 0x3ff, s.MessageCode())
+	assert.Equal(t, 0xf, s.UpdateNumber())
+}
+
+func TestPageMarshalBinary(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   cbs.Page
+		out  []byte
+		err  error
+	}{
+		{"hello", cbs.Page{Serial: 0x5235, MessageID: 50, DCS: 0x0f, Page: 1, Pages: 1,
+			Content: fixture.Hex(t, helloPage[12:])}, fixture.Hex(t, helloPage), nil},
+		{"short content", cbs.Page{Serial: 0x5235, MessageID: 50, DCS: 0x48, Page: 2, Pages: 3,
+			Content: []byte{1, 2}},
+			append(fixture.Hex(t, "523500324823"+"0102"), make([]byte, 80)...), nil},
+		{"page zero", cbs.Page{Page: 0, Pages: 1}, nil, cbs.ErrInvalidPageParameter(0x01)},
+		{"page beyond pages", cbs.Page{Page: 3, Pages: 2}, nil, cbs.ErrInvalidPageParameter(0x32)},
+		{"overlength", cbs.Page{Page: 1, Pages: 1, Content: make([]byte, 83)}, nil, cbs.ErrOverlength(83)},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.in.MarshalBinary()
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestPageUnmarshalBinary(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   []byte
+		out  cbs.Page
+		err  error
+	}{
+		{"hello", fixture.Hex(t, helloPage), cbs.Page{Serial: 0x5235, MessageID: 50, DCS: 0x0f, Page: 1, Pages: 1,
+			Content: fixture.Hex(t, helloPage[12:])}, nil},
+		{"zero page parameter", fixture.Hex(t, "523500320F00"+helloPage[12:]),
+			cbs.Page{Serial: 0x5235, MessageID: 50, DCS: 0x0f, Page: 1, Pages: 1,
+				Content: fixture.Hex(t, helloPage[12:])}, nil},
+		{"page zero", fixture.Hex(t, "523500320F01"+helloPage[12:]), cbs.Page{}, cbs.ErrInvalidPageParameter(0x01)},
+		{"page beyond pages", fixture.Hex(t, "523500320F21"+helloPage[12:]), cbs.Page{}, cbs.ErrInvalidPageParameter(0x21)},
+		{"short", fixture.Hex(t, helloPage[:170]), cbs.Page{}, cbs.ErrInvalidLength(85)},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			pg := cbs.Page{}
+			err := pg.UnmarshalBinary(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, pg)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	serial := cbs.NewSerialNumber(cbs.GSPLMN, 0x123, 5)
+	pp, err := cbs.Encode(50, serial, "Hello")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(pp))
+	b, err := pp[0].MarshalBinary()
+	require.Nil(t, err)
+	assert.Equal(t, fixture.Hex(t, helloPage), b)
+
+	pp, err = cbs.Encode(50, serial, "Hi", cbsdcs.AsUCS2)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(pp))
+	b, err = pp[0].MarshalBinary()
+	require.Nil(t, err)
+	assert.Equal(t, fixture.Hex(t, hiPage), b)
+
+	_, err = cbs.Encode(50, serial, "Hello", cbsdcs.WithLanguage("xyz"))
+	assert.Equal(t, cbsdcs.ErrInvalidLanguage("xyz"), err)
+	_, err = cbs.Encode(50, serial, "Привет", cbsdcs.WithLanguage("r"))
+	assert.Equal(t, cbsdcs.ErrInvalidLanguage("r"), err)
+	_, err = cbs.Encode(50, serial, strings.Repeat("a", 93*15+1))
+	assert.Equal(t, cbs.ErrOverlength(16), err)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	serial := cbs.NewSerialNumber(cbs.GSCellImmediate, 1, 0)
+	patterns := []struct {
+		name    string
+		text    string
+		options []cbsdcs.EncodeOption
+		pages   int
+		dcs     cbsdcs.DCS
+		lang    string
+	}{
+		{"gsm7", "Hello", nil, 1, cbsdcs.Unspecified, ""},
+		{"gsm7 full page", strings.Repeat("a", 93), nil, 1, cbsdcs.Unspecified, ""},
+		{"gsm7 pages", strings.Repeat("abcdefghij", 20), nil, 3, cbsdcs.Unspecified, ""},
+		{"gsm7 escape", strings.Repeat("a", 92) + "{}", nil, 2, cbsdcs.Unspecified, ""},
+		{"gsm7 language", "Hallo", []cbsdcs.EncodeOption{cbsdcs.WithLanguage("de")}, 1, 0x00, "de"},
+		{"gsm7 language indicated", strings.Repeat("Ciao ", 20),
+			[]cbsdcs.EncodeOption{cbsdcs.WithLanguage("ja")}, 2, cbsdcs.Language7Bit, "ja"},
+		{"ucs2", "Привет", nil, 1, cbsdcs.UCS2, ""},
+		{"ucs2 pages", strings.Repeat("Привет", 10), nil, 2, cbsdcs.UCS2, ""},
+		{"ucs2 language indicated", strings.Repeat("Привет", 10),
+			[]cbsdcs.EncodeOption{cbsdcs.WithLanguage("ru")}, 2, cbsdcs.LanguageUCS2, "ru"},
+		{"ucs2 surrogate", strings.Repeat("a", 40) + "😁", nil, 2, cbsdcs.UCS2, ""},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			pp, err := cbs.Encode(4370, serial, p.text, p.options...)
+			require.Nil(t, err)
+			require.Equal(t, p.pages, len(pp))
+			// round trip via binary, in reverse order
+			var rx []*cbs.Page
+			for i := len(pp) - 1; i >= 0; i-- {
+				assert.Equal(t, i+1, pp[i].Page)
+				b, err := pp[i].MarshalBinary()
+				require.Nil(t, err)
+				require.Equal(t, cbs.PageSize, len(b))
+				pg := cbs.Page{}
+				require.Nil(t, pg.UnmarshalBinary(b))
+				rx = append(rx, &pg)
+			}
+			msg, err := cbs.Decode(rx)
+			require.Nil(t, err)
+			assert.Equal(t, &cbs.Message{
+				Serial:    serial,
+				MessageID: 4370,
+				DCS:       p.dcs,
+				Language:  p.lang,
+				Text:      p.text,
+			}, msg)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	serial := cbs.NewSerialNumber(cbs.GSPLMN, 2, 1)
+	pp, err := cbs.Encode(50, serial, strings.Repeat("abcdefghij", 20))
+	require.Nil(t, err)
+	require.Equal(t, 3, len(pp))
+
+	_, err = cbs.Decode(nil)
+	assert.Equal(t, cbs.ErrIncomplete, err)
+	_, err = cbs.Decode(pp[:2])
+	assert.Equal(t, cbs.ErrIncomplete, err)
+	_, err = cbs.Decode([]*cbs.Page{pp[0], pp[1], pp[1]})
+	assert.Equal(t, cbs.ErrIncomplete, err)
+
+	other := *pp[2]
+	other.Serial = cbs.NewSerialNumber(cbs.GSPLMN, 2, 2)
+	_, err = cbs.Decode([]*cbs.Page{pp[0], pp[1], &other})
+	assert.Equal(t, cbs.ErrMismatch, err)
+
+	udh := *pp[0]
+	udh.DCS = 0x90
+	udh.Pages = 1
+	_, err = cbs.Decode([]*cbs.Page{&udh})
+	assert.Equal(t, cbs.ErrUDH, err)
+
+	reserved := *pp[0]
+	reserved.DCS = 0x80
+	reserved.Pages = 1
+	_, err = cbs.Decode([]*cbs.Page{&reserved})
+	assert.Equal(t, cbsdcs.ErrReserved, err)
+
+	// 8bit data is not stripped of padding
+	data := cbs.Page{DCS: cbsdcs.Data8Bit, Page: 1, Pages: 1, Content: bytes.Repeat([]byte{cr}, 82)}
+	msg, err := cbs.Decode([]*cbs.Page{&data})
+	require.Nil(t, err)
+	assert.Equal(t, strings.Repeat("\r", 82), msg.Text)
+}
+
+func TestCollector(t *testing.T) {
+	serial := cbs.NewSerialNumber(cbs.GSPLMN, 2, 1)
+	pp, err := cbs.Encode(50, serial, strings.Repeat("abcdefghij", 20))
+	require.Nil(t, err)
+	require.Equal(t, 3, len(pp))
+	single, err := cbs.Encode(51, serial, "Hello")
+	require.Nil(t, err)
+
+	c := cbs.NewCollector()
+	assert.Nil(t, c.Collect(pp[2]))
+	assert.Nil(t, c.Collect(pp[0]))
+	// repeated broadcast
+	assert.Nil(t, c.Collect(pp[0]))
+	assert.Equal(t, single, c.Collect(single[0]))
+	assert.Equal(t, 1, c.Pending())
+	// new version of the message is collected separately
+	update := *pp[1]
+	update.Serial = cbs.NewSerialNumber(cbs.GSPLMN, 2, 2)
+	assert.Nil(t, c.Collect(&update))
+	assert.Equal(t, 2, c.Pending())
+	assert.Equal(t, pp, c.Collect(pp[1]))
+	assert.Equal(t, 1, c.Pending())
+
+	// invalid page parameter
+	assert.Nil(t, c.Collect(&cbs.Page{Page: 2, Pages: 1}))
+	assert.Equal(t, 1, c.Pending())
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cbs
+
+import "sync"
+
+// Collector collects the pages of messages until each message is complete.
+//
+// Messages are identified by their message identifier and serial number, so
+// a new version of a message, with a new update number, is collected
+// separately.
+type Collector struct {
+	mu    sync.Mutex
+	pages map[pageKey][]*Page
+}
+
+type pageKey struct {
+	id     uint16
+	serial SerialNumber
+}
+
+// NewCollector creates a Collector.
+func NewCollector() *Collector {
+	return &Collector{pages: make(map[pageKey][]*Page)}
+}
+
+// Collect adds the page to the collection.
+//
+// If the page completes a message then the pages of the message are returned,
+// in order, and removed from the collection. Otherwise nil is returned.
+//
+// Repeated pages, as are common in cell broadcast, replace any previously
+// collected copy.
+func (c *Collector) Collect(p *Page) []*Page {
+	if p.Page < 1 || p.Page > p.Pages {
+		return nil
+	}
+	k := pageKey{p.MessageID, p.Serial}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pp := c.pages[k]
+	if len(pp) != p.Pages {
+		pp = make([]*Page, p.Pages)
+	}
+	pp[p.Page-1] = p
+	for _, x := range pp {
+		if x == nil {
+			c.pages[k] = pp
+			return nil
+		}
+	}
+	delete(c.pages, k)
+	return pp
+}
+
+// Pending returns the number of incomplete messages being collected.
+func (c *Collector) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pages)
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cbs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidLength indicates a page does not have the expected length.
+type ErrInvalidLength int
+
+func (e ErrInvalidLength) Error() string {
+	return fmt.Sprintf("cbs: invalid page length %d", int(e))
+}
+
+// ErrInvalidPageParameter indicates the page parameter is invalid, i.e. the
+// page number is zero or exceeds the number of pages.
+type ErrInvalidPageParameter int
+
+func (e ErrInvalidPageParameter) Error() string {
+	return fmt.Sprintf("cbs: invalid page parameter 0x%02x", int(e))
+}
+
+// ErrOverlength indicates the content does not fit in a page, or the message
+// does not fit in MaxPages pages.
+type ErrOverlength int
+
+func (e ErrOverlength) Error() string {
+	return fmt.Sprintf("cbs: overlength %d", int(e))
+}
+
+// ErrUnsupportedMessageType indicates the message type of a UMTS format
+// message is not a CBS message.
+type ErrUnsupportedMessageType byte
+
+func (e ErrUnsupportedMessageType) Error() string {
+	return fmt.Sprintf("cbs: unsupported message type %d", int(e))
+}
+
+var (
+	// ErrIncomplete indicates the pages provided to Decode do not form a
+	// complete message.
+	ErrIncomplete = errors.New("cbs: incomplete message")
+
+	// ErrMismatch indicates the pages provided to Decode are not from the
+	// same message.
+	ErrMismatch = errors.New("cbs: pages from different messages")
+
+	// ErrUDH indicates the message contains a user data header, which is not
+	// supported.
+	ErrUDH = errors.New("cbs: user data header not supported")
+)
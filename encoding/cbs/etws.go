@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cbs
+
+import (
+	"encoding/binary"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+const (
+	// ETWSPrimarySize is the size of a GSM ETWS primary notification,
+	// including the warning security information.
+	ETWSPrimarySize = 56
+
+	// etwsPrimaryHeaderSize is the size of a GSM ETWS primary notification
+	// without the warning security information.
+	etwsPrimaryHeaderSize = 6
+
+	// SignatureSize is the size of the digital signature in the warning
+	// security information.
+	SignatureSize = 43
+)
+
+// ETWSPrimary is a GSM ETWS primary notification, as defined in 3GPP TS
+// 23.041 Section 9.4.1.3.
+type ETWSPrimary struct {
+	Serial    SerialNumber
+	MessageID uint16
+
+	// WarningType is the warning type value, as defined in 3GPP TS 23.041
+	// Section 9.3.24.
+	WarningType int
+
+	// EmergencyUserAlert indicates the user should be alerted, e.g. with a
+	// sound.
+	EmergencyUserAlert bool
+
+	// Popup indicates the notification should be displayed in a popup.
+	Popup bool
+
+	// Security is the warning security information, or nil if absent.
+	Security *WarningSecurity
+}
+
+// WarningSecurity is the warning security information of an ETWS primary
+// notification, as defined in 3GPP TS 23.041 Section 9.3.25.
+type WarningSecurity struct {
+	Timestamp tpdu.Timestamp
+	Signature [SignatureSize]byte
+}
+
+// Alert returns the Alert indicated by the warning type.
+//
+// The warning type values 0 to 4 correspond to the ETWS message identifiers
+// 4352 to 4356.
+func (e *ETWSPrimary) Alert() Alert {
+	if e.WarningType < 0 || e.WarningType > 4 {
+		return AlertETWSReserved
+	}
+	return AlertEarthquake + Alert(e.WarningType)
+}
+
+// MarshalBinary encodes the primary notification into its binary form.
+func (e *ETWSPrimary) MarshalBinary() ([]byte, error) {
+	b := make([]byte, etwsPrimaryHeaderSize, ETWSPrimarySize)
+	binary.BigEndian.PutUint16(b, uint16(e.Serial))
+	binary.BigEndian.PutUint16(b[2:], e.MessageID)
+	b[4] = byte(e.WarningType&0x7f) << 1
+	if e.EmergencyUserAlert {
+		b[4] |= 0x01
+	}
+	if e.Popup {
+		b[5] = 0x80
+	}
+	if e.Security != nil {
+		ts, err := e.Security.Timestamp.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, ts...)
+		b = append(b, e.Security.Signature[:]...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the primary notification from its binary form.
+//
+// The warning security information is optional.
+func (e *ETWSPrimary) UnmarshalBinary(b []byte) error {
+	if len(b) != etwsPrimaryHeaderSize && len(b) != ETWSPrimarySize {
+		return ErrInvalidLength(len(b))
+	}
+	n := ETWSPrimary{
+		Serial:             SerialNumber(binary.BigEndian.Uint16(b)),
+		MessageID:          binary.BigEndian.Uint16(b[2:]),
+		WarningType:        int(b[4] >> 1),
+		EmergencyUserAlert: b[4]&0x01 != 0,
+		Popup:              b[5]&0x80 != 0,
+	}
+	if len(b) == ETWSPrimarySize {
+		ws := WarningSecurity{}
+		if err := ws.Timestamp.UnmarshalBinary(b[6:13]); err != nil {
+			return err
+		}
+		copy(ws.Signature[:], b[13:])
+		n.Security = &ws
+	}
+	*e = n
+	return nil
+}
@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cbs
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/cbsdcs"
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+const (
+	// cr is the padding following the text in a page.
+	cr = 0x0d
+
+	// esc is the GSM7 escape to the extension table.
+	esc = 0x1b
+
+	// septetsPerPage is the number of GSM7 characters in a page.
+	septetsPerPage = ContentSize * 8 / 7
+)
+
+// Message is a decoded CBS message.
+type Message struct {
+	Serial    SerialNumber
+	MessageID uint16
+	DCS       cbsdcs.DCS
+
+	// Language is the ISO 639 code of the language of the text, if known.
+	Language string
+
+	// Text is the text of the message, with the padding removed.
+	Text string
+}
+
+// Decode decodes the text from the pages of a message.
+//
+// The pages must all be from the same message, and include all the pages of
+// the message, but may be provided in any order.
+func Decode(pages []*Page) (*Message, error) {
+	if len(pages) == 0 {
+		return nil, ErrIncomplete
+	}
+	pp := append([]*Page(nil), pages...)
+	sort.Slice(pp, func(i, j int) bool { return pp[i].Page < pp[j].Page })
+	first := pp[0]
+	if len(pp) != first.Pages {
+		return nil, ErrIncomplete
+	}
+	if first.DCS.HasUDH() {
+		return nil, ErrUDH
+	}
+	msg := Message{Serial: first.Serial, MessageID: first.MessageID, DCS: first.DCS}
+	var text strings.Builder
+	for i, p := range pp {
+		if p.Page != i+1 {
+			return nil, ErrIncomplete
+		}
+		if p.Serial != first.Serial || p.MessageID != first.MessageID ||
+			p.DCS != first.DCS || p.Pages != first.Pages {
+			return nil, ErrMismatch
+		}
+		dcs := p.DCS
+		if i > 0 {
+			// only the first page carries the language indication
+			switch dcs {
+			case cbsdcs.Language7Bit:
+				dcs = cbsdcs.Unspecified
+			case cbsdcs.LanguageUCS2:
+				dcs = cbsdcs.UCS2
+			}
+		}
+		t, lang, err := dcs.Decode(p.Content)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			msg.Language = lang
+		}
+		if alpha, _ := dcs.Alphabet(); alpha != tpdu.Alpha8Bit {
+			t = strings.TrimRight(t, "\r")
+		}
+		text.WriteString(t)
+	}
+	msg.Text = text.String()
+	return &msg, nil
+}
+
+// Encode builds the pages of a message containing the text.
+//
+// The text is encoded using the GSM7 default alphabet, if possible, else
+// UCS2, and split over as many pages as required, each padded with CR.
+func Encode(id uint16, serial SerialNumber, text string, options ...cbsdcs.EncodeOption) ([]*Page, error) {
+	cfg := cbsdcs.NewEncodeConfig(options...)
+	var dcs cbsdcs.DCS
+	var contents [][]byte
+	var err error
+	u, gerr := gsm7.Encode([]byte(text))
+	if cfg.UCS2 || gerr != nil {
+		dcs, contents, err = encodeUCS2(text, cfg.Lang)
+	} else {
+		dcs, contents, err = encode7Bit(u, cfg.Lang)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(contents) > MaxPages {
+		return nil, ErrOverlength(len(contents))
+	}
+	pages := make([]*Page, len(contents))
+	for i, c := range contents {
+		pages[i] = &Page{
+			Serial:    serial,
+			MessageID: id,
+			DCS:       dcs,
+			Page:      i + 1,
+			Pages:     len(contents),
+			Content:   c,
+		}
+	}
+	return pages, nil
+}
+
+// encode7Bit splits the GSM7 septets into pages.
+func encode7Bit(u []byte, lang string) (cbsdcs.DCS, [][]byte, error) {
+	dcs := cbsdcs.Unspecified
+	if lang != "" {
+		var ok bool
+		if dcs, ok = cbsdcs.ForLanguage(lang); !ok {
+			l, err := gsm7.Encode([]byte(lang))
+			if err != nil || len(l) != 2 {
+				return 0, nil, cbsdcs.ErrInvalidLanguage(lang)
+			}
+			dcs = cbsdcs.Language7Bit
+			u = append(append(l, cr), u...)
+		}
+	}
+	var contents [][]byte
+	for {
+		n := len(u)
+		if n > septetsPerPage {
+			n = septetsPerPage
+			// don't split escape sequences
+			if u[n-1] == esc {
+				n--
+			}
+		}
+		page := make([]byte, septetsPerPage)
+		copy(page, u[:n])
+		for i := n; i < len(page); i++ {
+			page[i] = cr
+		}
+		contents = append(contents, gsm7.Pack7Bit(page, 0))
+		u = u[n:]
+		if len(u) == 0 {
+			return dcs, contents, nil
+		}
+	}
+}
+
+// encodeUCS2 splits the text, encoded as UCS2, into pages.
+func encodeUCS2(text, lang string) (cbsdcs.DCS, [][]byte, error) {
+	dcs := cbsdcs.UCS2
+	var prefix []byte
+	if lang != "" {
+		l, err := gsm7.Encode([]byte(lang))
+		if err != nil || len(l) != 2 {
+			return 0, nil, cbsdcs.ErrInvalidLanguage(lang)
+		}
+		dcs = cbsdcs.LanguageUCS2
+		prefix = gsm7.Pack7Bit(l, 0)
+	}
+	u := ucs2.Encode([]rune(text))
+	var contents [][]byte
+	for {
+		n := len(u)
+		if n > ContentSize-len(prefix) {
+			n = ContentSize - len(prefix)
+			// don't split surrogate pairs
+			if u[n-2]&0xfc == 0xd8 {
+				n -= 2
+			}
+		}
+		page := append(prefix, u[:n]...)
+		for len(page) < ContentSize {
+			page = append(page, 0, cr)
+		}
+		contents = append(contents, page)
+		prefix = nil
+		u = u[n:]
+		if len(u) == 0 {
+			return dcs, contents, nil
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cbs
+
+import (
+	"encoding/binary"
+
+	"github.com/warthog618/sms/encoding/cbsdcs"
+)
+
+// UMTSMessageType is the message type of a CBS message in UMTS format.
+const UMTSMessageType = 1
+
+// UMTSMessage is a CBS message in the UMTS format, as defined in 3GPP TS
+// 23.041 Section 9.4.2.2, which carries all the pages of the message.
+//
+// The CB data of the UMTS format is also used for the warning message
+// contents in LTE and 5G, with the identifier, serial number and DCS
+// signalled separately.
+type UMTSMessage struct {
+	MessageID uint16
+	Serial    SerialNumber
+	DCS       cbsdcs.DCS
+
+	// Pages contains the content of each page, up to ContentSize, excluding
+	// any padding beyond the information length.
+	Pages [][]byte
+}
+
+// MarshalBinary encodes the message into its binary form.
+func (m *UMTSMessage) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 6)
+	b[0] = UMTSMessageType
+	binary.BigEndian.PutUint16(b[1:], m.MessageID)
+	binary.BigEndian.PutUint16(b[3:], uint16(m.Serial))
+	b[5] = byte(m.DCS)
+	cbd, err := m.MarshalCBData()
+	if err != nil {
+		return nil, err
+	}
+	return append(b, cbd...), nil
+}
+
+// MarshalCBData encodes the CB data of the message, being the number of pages
+// followed by each page, padded to ContentSize, and its information length.
+func (m *UMTSMessage) MarshalCBData() ([]byte, error) {
+	if len(m.Pages) < 1 || len(m.Pages) > MaxPages {
+		return nil, ErrInvalidPageParameter(len(m.Pages))
+	}
+	b := make([]byte, 1, 1+len(m.Pages)*(ContentSize+1))
+	b[0] = byte(len(m.Pages))
+	for _, p := range m.Pages {
+		if len(p) > ContentSize {
+			return nil, ErrOverlength(len(p))
+		}
+		b = append(b, p...)
+		for i := len(p); i < ContentSize; i++ {
+			b = append(b, 0)
+		}
+		b = append(b, byte(len(p)))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the message from its binary form.
+func (m *UMTSMessage) UnmarshalBinary(b []byte) error {
+	if len(b) < 7 {
+		return ErrInvalidLength(len(b))
+	}
+	if b[0] != UMTSMessageType {
+		return ErrUnsupportedMessageType(b[0])
+	}
+	n := UMTSMessage{
+		MessageID: binary.BigEndian.Uint16(b[1:]),
+		Serial:    SerialNumber(binary.BigEndian.Uint16(b[3:])),
+		DCS:       cbsdcs.DCS(b[5]),
+	}
+	if err := n.UnmarshalCBData(b[6:]); err != nil {
+		return err
+	}
+	*m = n
+	return nil
+}
+
+// UnmarshalCBData decodes the CB data of the message, replacing the Pages.
+func (m *UMTSMessage) UnmarshalCBData(b []byte) error {
+	if len(b) < 1 {
+		return ErrInvalidLength(len(b))
+	}
+	n := int(b[0])
+	if n < 1 || n > MaxPages {
+		return ErrInvalidPageParameter(n)
+	}
+	if len(b) != 1+n*(ContentSize+1) {
+		return ErrInvalidLength(len(b))
+	}
+	pages := make([][]byte, n)
+	for i := range pages {
+		p := b[1+i*(ContentSize+1):]
+		l := int(p[ContentSize])
+		if l > ContentSize {
+			return ErrOverlength(l)
+		}
+		pages[i] = append([]byte(nil), p[:l]...)
+	}
+	m.Pages = pages
+	return nil
+}
+
+// Decode decodes the text of the message.
+func (m *UMTSMessage) Decode() (*Message, error) {
+	pages := make([]*Page, len(m.Pages))
+	for i, c := range m.Pages {
+		pages[i] = &Page{
+			Serial:    m.Serial,
+			MessageID: m.MessageID,
+			DCS:       m.DCS,
+			Page:      i + 1,
+			Pages:     len(m.Pages),
+			Content:   c,
+		}
+	}
+	return Decode(pages)
+}
+
+// NewUMTSMessage creates a UMTS format message from the pages of a GSM
+// format message, such as those returned by Encode.
+func NewUMTSMessage(pages []*Page) (*UMTSMessage, error) {
+	if len(pages) == 0 {
+		return nil, ErrIncomplete
+	}
+	first := pages[0]
+	m := UMTSMessage{MessageID: first.MessageID, Serial: first.Serial, DCS: first.DCS}
+	for i, p := range pages {
+		if p.Page != i+1 || p.Pages != len(pages) {
+			return nil, ErrIncomplete
+		}
+		m.Pages = append(m.Pages, p.Content)
+	}
+	return &m, nil
+}
@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cbs
+
+// Alert is the category of public warning indicated by a message identifier,
+// as defined in 3GPP TS 23.041 Section 9.4.1.2.2.
+type Alert int
+
+const (
+	// AlertNone indicates the message is not a public warning.
+	AlertNone Alert = iota
+
+	// AlertEarthquake is an ETWS earthquake warning.
+	AlertEarthquake
+
+	// AlertTsunami is an ETWS tsunami warning.
+	AlertTsunami
+
+	// AlertEarthquakeTsunami is an ETWS earthquake and tsunami warning.
+	AlertEarthquakeTsunami
+
+	// AlertETWSTest is an ETWS test message.
+	AlertETWSTest
+
+	// AlertETWSOther is an ETWS warning for other emergency types.
+	AlertETWSOther
+
+	// AlertETWSReserved is an ETWS message identifier reserved for future
+	// extension.
+	AlertETWSReserved
+
+	// AlertPresidential is a CMAS Presidential Level Alert.
+	AlertPresidential
+
+	// AlertExtreme is a CMAS Extreme Alert.
+	AlertExtreme
+
+	// AlertSevere is a CMAS Severe Alert.
+	AlertSevere
+
+	// AlertAmber is a CMAS Child Abduction Emergency, or Amber, Alert.
+	AlertAmber
+
+	// AlertMonthlyTest is a CMAS Required Monthly Test.
+	AlertMonthlyTest
+
+	// AlertExercise is a CMAS Exercise.
+	AlertExercise
+
+	// AlertOperator is a CMAS message reserved for operator defined use.
+	AlertOperator
+
+	// AlertPublicSafety is a CMAS Public Safety Alert.
+	AlertPublicSafety
+
+	// AlertStateLocalTest is a CMAS State/Local WEA Test.
+	AlertStateLocalTest
+)
+
+var alertNames = map[Alert]string{
+	AlertNone:              "none",
+	AlertEarthquake:        "earthquake",
+	AlertTsunami:           "tsunami",
+	AlertEarthquakeTsunami: "earthquake and tsunami",
+	AlertETWSTest:          "ETWS test",
+	AlertETWSOther:         "ETWS other",
+	AlertETWSReserved:      "ETWS reserved",
+	AlertPresidential:      "presidential",
+	AlertExtreme:           "extreme",
+	AlertSevere:            "severe",
+	AlertAmber:             "amber",
+	AlertMonthlyTest:       "required monthly test",
+	AlertExercise:          "exercise",
+	AlertOperator:          "operator defined",
+	AlertPublicSafety:      "public safety",
+	AlertStateLocalTest:    "state/local test",
+}
+
+func (a Alert) String() string {
+	if n, ok := alertNames[a]; ok {
+		return n
+	}
+	return "unknown"
+}
+
+// IsETWS indicates the alert is an ETWS warning.
+func (a Alert) IsETWS() bool {
+	return a >= AlertEarthquake && a <= AlertETWSReserved
+}
+
+// IsCMAS indicates the alert is a CMAS, or WEA, warning.
+func (a Alert) IsCMAS() bool {
+	return a >= AlertPresidential && a <= AlertStateLocalTest
+}
+
+// Severity is the severity of a CMAS Extreme or Severe alert.
+//
+// The severity does not always match the Alert, as the Severe Alerts include
+// alerts with a severity of extreme.
+type Severity int
+
+const (
+	// SeverityUnknown indicates the alert does not specify a severity.
+	SeverityUnknown Severity = iota
+
+	// SeverityExtreme indicates an extraordinary threat to life or property.
+	SeverityExtreme
+
+	// SeveritySevere indicates a significant threat to life or property.
+	SeveritySevere
+)
+
+// Urgency is the urgency of a CMAS Extreme or Severe alert.
+type Urgency int
+
+const (
+	// UrgencyUnknown indicates the alert does not specify an urgency.
+	UrgencyUnknown Urgency = iota
+
+	// UrgencyImmediate indicates responsive action should be taken
+	// immediately.
+	UrgencyImmediate
+
+	// UrgencyExpected indicates responsive action should be taken soon,
+	// within the next hour.
+	UrgencyExpected
+)
+
+// Certainty is the certainty of a CMAS Extreme or Severe alert.
+type Certainty int
+
+const (
+	// CertaintyUnknown indicates the alert does not specify a certainty.
+	CertaintyUnknown Certainty = iota
+
+	// CertaintyObserved indicates the event is determined to have occurred
+	// or to be ongoing.
+	CertaintyObserved
+
+	// CertaintyLikely indicates the event is likely, with a probability
+	// greater than 50%.
+	CertaintyLikely
+)
+
+// Warning is the classification of a public warning message identifier.
+type Warning struct {
+	Alert     Alert
+	Severity  Severity
+	Urgency   Urgency
+	Certainty Certainty
+
+	// AdditionalLanguage indicates the identifier is that used to broadcast
+	// a CMAS alert in a language other than the primary language.
+	AdditionalLanguage bool
+}
+
+const (
+	// etwsFirst is the first ETWS message identifier.
+	etwsFirst = 4352
+
+	// cmasFirst is the first CMAS message identifier.
+	cmasFirst = 4370
+
+	// cmasLanguageOffset is the offset from an identifier in the primary
+	// language to the corresponding additional language identifier.
+	cmasLanguageOffset = 13
+)
+
+// cmasWarnings are the CMAS warnings in the primary language, indexed by
+// identifier from cmasFirst.
+var cmasWarnings = []Warning{
+	{Alert: AlertPresidential},
+	{Alert: AlertExtreme, Severity: SeverityExtreme, Urgency: UrgencyImmediate, Certainty: CertaintyObserved},
+	{Alert: AlertExtreme, Severity: SeverityExtreme, Urgency: UrgencyImmediate, Certainty: CertaintyLikely},
+	{Alert: AlertSevere, Severity: SeverityExtreme, Urgency: UrgencyExpected, Certainty: CertaintyObserved},
+	{Alert: AlertSevere, Severity: SeverityExtreme, Urgency: UrgencyExpected, Certainty: CertaintyLikely},
+	{Alert: AlertSevere, Severity: SeveritySevere, Urgency: UrgencyImmediate, Certainty: CertaintyObserved},
+	{Alert: AlertSevere, Severity: SeveritySevere, Urgency: UrgencyImmediate, Certainty: CertaintyLikely},
+	{Alert: AlertSevere, Severity: SeveritySevere, Urgency: UrgencyExpected, Certainty: CertaintyObserved},
+	{Alert: AlertSevere, Severity: SeveritySevere, Urgency: UrgencyExpected, Certainty: CertaintyLikely},
+	{Alert: AlertAmber},
+	{Alert: AlertMonthlyTest},
+	{Alert: AlertExercise},
+	{Alert: AlertOperator},
+}
+
+// Classify returns the public warning classification of the message
+// identifier.
+//
+// The Alert is AlertNone if the identifier is not a public warning.
+func Classify(id uint16) Warning {
+	switch {
+	case id < etwsFirst:
+	case id < etwsFirst+5:
+		return Warning{Alert: AlertEarthquake + Alert(id-etwsFirst)}
+	case id < etwsFirst+8:
+		// reserved for future ETWS extension
+		return Warning{Alert: AlertETWSReserved}
+	case id < cmasFirst:
+	case id < cmasFirst+cmasLanguageOffset:
+		return cmasWarnings[id-cmasFirst]
+	case id < cmasFirst+2*cmasLanguageOffset:
+		w := cmasWarnings[id-cmasFirst-cmasLanguageOffset]
+		w.AdditionalLanguage = true
+		return w
+	case id == 4396, id == 4397:
+		return Warning{Alert: AlertPublicSafety, AdditionalLanguage: id == 4397}
+	case id == 4398, id == 4399:
+		return Warning{Alert: AlertStateLocalTest, AdditionalLanguage: id == 4399}
+	}
+	return Warning{}
+}
@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cbs_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/cbs"
+	"github.com/warthog618/sms/encoding/cbsdcs"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+func TestClassify(t *testing.T) {
+	patterns := []struct {
+		id  uint16
+		out cbs.Warning
+	}{
+		{50, cbs.Warning{}},
+		{4351, cbs.Warning{}},
+		{4352, cbs.Warning{Alert: cbs.AlertEarthquake}},
+		{4353, cbs.Warning{Alert: cbs.AlertTsunami}},
+		{4354, cbs.Warning{Alert: cbs.AlertEarthquakeTsunami}},
+		{4355, cbs.Warning{Alert: cbs.AlertETWSTest}},
+		{4356, cbs.Warning{Alert: cbs.AlertETWSOther}},
+		{4357, cbs.Warning{Alert: cbs.AlertETWSReserved}},
+		{4359, cbs.Warning{Alert: cbs.AlertETWSReserved}},
+		{4360, cbs.Warning{}},
+		{4370, cbs.Warning{Alert: cbs.AlertPresidential}},
+		{4371, cbs.Warning{Alert: cbs.AlertExtreme, Severity: cbs.SeverityExtreme,
+			Urgency: cbs.UrgencyImmediate, Certainty: cbs.CertaintyObserved}},
+		{4372, cbs.Warning{Alert: cbs.AlertExtreme, Severity: cbs.SeverityExtreme,
+			Urgency: cbs.UrgencyImmediate, Certainty: cbs.CertaintyLikely}},
+		{4373, cbs.Warning{Alert: cbs.AlertSevere, Severity: cbs.SeverityExtreme,
+			Urgency: cbs.UrgencyExpected, Certainty: cbs.CertaintyObserved}},
+		{4374, cbs.Warning{Alert: cbs.AlertSevere, Severity: cbs.SeverityExtreme,
+			Urgency: cbs.UrgencyExpected, Certainty: cbs.CertaintyLikely}},
+		{4375, cbs.Warning{Alert: cbs.AlertSevere, Severity: cbs.SeveritySevere,
+			Urgency: cbs.UrgencyImmediate, Certainty: cbs.CertaintyObserved}},
+		{4378, cbs.Warning{Alert: cbs.AlertSevere, Severity: cbs.SeveritySevere,
+			Urgency: cbs.UrgencyExpected, Certainty: cbs.CertaintyLikely}},
+		{4379, cbs.Warning{Alert: cbs.AlertAmber}},
+		{4380, cbs.Warning{Alert: cbs.AlertMonthlyTest}},
+		{4381, cbs.Warning{Alert: cbs.AlertExercise}},
+		{4382, cbs.Warning{Alert: cbs.AlertOperator}},
+		{4383, cbs.Warning{Alert: cbs.AlertPresidential, AdditionalLanguage: true}},
+		{4384, cbs.Warning{Alert: cbs.AlertExtreme, Severity: cbs.SeverityExtreme,
+			Urgency: cbs.UrgencyImmediate, Certainty: cbs.CertaintyObserved, AdditionalLanguage: true}},
+		{4386, cbs.Warning{Alert: cbs.AlertSevere, Severity: cbs.SeverityExtreme,
+			Urgency: cbs.UrgencyExpected, Certainty: cbs.CertaintyObserved, AdditionalLanguage: true}},
+		{4387, cbs.Warning{Alert: cbs.AlertSevere, Severity: cbs.SeverityExtreme,
+			Urgency: cbs.UrgencyExpected, Certainty: cbs.CertaintyLikely, AdditionalLanguage: true}},
+		{4392, cbs.Warning{Alert: cbs.AlertAmber, AdditionalLanguage: true}},
+		{4395, cbs.Warning{Alert: cbs.AlertOperator, AdditionalLanguage: true}},
+		{4396, cbs.Warning{Alert: cbs.AlertPublicSafety}},
+		{4397, cbs.Warning{Alert: cbs.AlertPublicSafety, AdditionalLanguage: true}},
+		{4398, cbs.Warning{Alert: cbs.AlertStateLocalTest}},
+		{4399, cbs.Warning{Alert: cbs.AlertStateLocalTest, AdditionalLanguage: true}},
+		{4400, cbs.Warning{}},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			assert.Equal(t, p.out, cbs.Classify(p.id))
+		}
+		t.Run(p.out.Alert.String(), f)
+	}
+}
+
+func TestAlert(t *testing.T) {
+	assert.True(t, cbs.AlertTsunami.IsETWS())
+	assert.False(t, cbs.AlertTsunami.IsCMAS())
+	assert.True(t, cbs.AlertAmber.IsCMAS())
+	assert.False(t, cbs.AlertAmber.IsETWS())
+	assert.False(t, cbs.AlertNone.IsETWS())
+	assert.False(t, cbs.AlertNone.IsCMAS())
+	assert.Equal(t, "presidential", cbs.AlertPresidential.String())
+	assert.Equal(t, "unknown", cbs.Alert(-1).String())
+}
+
+func TestETWSPrimary(t *testing.T) {
+	sig := [cbs.SignatureSize]byte{}
+	for i := range sig {
+		sig[i] = byte(i)
+	}
+	ts := tpdu.Timestamp{Time: time.Date(2011, time.March, 11, 14, 46, 23, 0, time.FixedZone("SCTS", 9*3600))}
+	patterns := []struct {
+		name string
+		in   string
+		out  cbs.ETWSPrimary
+		err  error
+	}{
+		{"tsunami", "3000" + "1101" + "0380",
+			cbs.ETWSPrimary{Serial: 0x3000, MessageID: 4353, WarningType: 1,
+				EmergencyUserAlert: true, Popup: true}, nil},
+		{"earthquake quiet", "3010" + "1100" + "0000",
+			cbs.ETWSPrimary{Serial: 0x3010, MessageID: 4352}, nil},
+		{"security", "3000" + "1102" + "0580" + "11301141643263" +
+			"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a",
+			cbs.ETWSPrimary{Serial: 0x3000, MessageID: 4354, WarningType: 2,
+				EmergencyUserAlert: true, Popup: true,
+				Security: &cbs.WarningSecurity{Timestamp: ts, Signature: sig}}, nil},
+		{"short", "30001101", cbs.ETWSPrimary{}, cbs.ErrInvalidLength(4)},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b := fixture.Hex(t, p.in)
+			e := cbs.ETWSPrimary{}
+			err := e.UnmarshalBinary(b)
+			assert.Equal(t, p.err, err)
+			if err != nil {
+				return
+			}
+			if p.out.Security != nil {
+				require.NotNil(t, e.Security)
+				assert.True(t, p.out.Security.Timestamp.Equal(e.Security.Timestamp.Time))
+				e.Security.Timestamp = p.out.Security.Timestamp
+			}
+			assert.Equal(t, p.out, e)
+			m, err := e.MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, b, m)
+		}
+		t.Run(p.name, f)
+	}
+
+	// timestamp with invalid BCD
+	e := cbs.ETWSPrimary{}
+	err := e.UnmarshalBinary(fixture.Hex(t, "300011020580"+"1A"+strings.Repeat("00", 49)))
+	assert.NotNil(t, err)
+}
+
+func TestETWSPrimaryAlert(t *testing.T) {
+	patterns := []struct {
+		wt  int
+		out cbs.Alert
+	}{
+		{0, cbs.AlertEarthquake},
+		{1, cbs.AlertTsunami},
+		{2, cbs.AlertEarthquakeTsunami},
+		{3, cbs.AlertETWSTest},
+		{4, cbs.AlertETWSOther},
+		{5, cbs.AlertETWSReserved},
+	}
+	for _, p := range patterns {
+		e := cbs.ETWSPrimary{WarningType: p.wt}
+		assert.Equal(t, p.out, e.Alert())
+	}
+}
+
+func TestUMTSMessage(t *testing.T) {
+	serial := cbs.NewSerialNumber(cbs.GSCellImmediate, 0x100, 0)
+	text := "Presidential Alert: " + strings.Repeat("This is a test of the wireless emergency alert system. ", 3)
+	pages, err := cbs.Encode(4370, serial, text, cbsdcs.WithLanguage("en"))
+	require.Nil(t, err)
+	require.Equal(t, 2, len(pages))
+	m, err := cbs.NewUMTSMessage(pages)
+	require.Nil(t, err)
+
+	b, err := m.MarshalBinary()
+	require.Nil(t, err)
+	require.Equal(t, 6+1+2*(cbs.ContentSize+1), len(b))
+	assert.Equal(t, fixture.Hex(t, "01"+"1112"+"1000"+"01"+"02"), b[:7])
+	assert.Equal(t, byte(cbs.ContentSize), b[7+cbs.ContentSize])
+
+	r := cbs.UMTSMessage{}
+	require.Nil(t, r.UnmarshalBinary(b))
+	assert.Equal(t, *m, r)
+	msg, err := r.Decode()
+	require.Nil(t, err)
+	assert.Equal(t, &cbs.Message{Serial: serial, MessageID: 4370, DCS: 0x01, Language: "en", Text: text}, msg)
+	assert.Equal(t, cbs.AlertPresidential, cbs.Classify(msg.MessageID).Alert)
+}
+
+func TestUMTSMessageCBData(t *testing.T) {
+	// UCS2 page with an information length shorter than the page, as per LTE
+	// warning message contents with the DCS signalled separately.
+	cbd := "01" + "0048006900210021" + strings.Repeat("00", cbs.ContentSize-8) + "08"
+	m := cbs.UMTSMessage{MessageID: 4371, Serial: 0x1230, DCS: cbsdcs.UCS2}
+	require.Nil(t, m.UnmarshalCBData(fixture.Hex(t, cbd)))
+	assert.Equal(t, [][]byte{fixture.Hex(t, "0048006900210021")}, m.Pages)
+	msg, err := m.Decode()
+	require.Nil(t, err)
+	assert.Equal(t, "Hi!!", msg.Text)
+	b, err := m.MarshalCBData()
+	require.Nil(t, err)
+	assert.Equal(t, fixture.Hex(t, cbd), b)
+}
+
+func TestUMTSMessageErrors(t *testing.T) {
+	page := strings.Repeat("00", cbs.ContentSize)
+	patterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{"short", "01111200", cbs.ErrInvalidLength(4)},
+		{"message type", "02111210000f01" + page + "00", cbs.ErrUnsupportedMessageType(2)},
+		{"no pages", "01111210000f00", cbs.ErrInvalidPageParameter(0)},
+		{"too many pages", "01111210000f10", cbs.ErrInvalidPageParameter(16)},
+		{"missing page", "01111210000f02" + page + "00", cbs.ErrInvalidLength(1 + cbs.ContentSize + 1)},
+		{"information length", "01111210000f01" + page + "53", cbs.ErrOverlength(83)},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := cbs.UMTSMessage{}
+			err := m.UnmarshalBinary(fixture.Hex(t, p.in))
+			assert.Equal(t, p.err, err)
+		}
+		t.Run(p.name, f)
+	}
+
+	m := cbs.UMTSMessage{}
+	_, err := m.MarshalBinary()
+	assert.Equal(t, cbs.ErrInvalidPageParameter(0), err)
+	m.Pages = [][]byte{make([]byte, 83)}
+	_, err = m.MarshalBinary()
+	assert.Equal(t, cbs.ErrOverlength(83), err)
+
+	_, err = cbs.NewUMTSMessage(nil)
+	assert.Equal(t, cbs.ErrIncomplete, err)
+	pages, err := cbs.Encode(4370, 0, strings.Repeat("a", 100))
+	require.Nil(t, err)
+	_, err = cbs.NewUMTSMessage(pages[1:])
+	assert.Equal(t, cbs.ErrIncomplete, err)
+}
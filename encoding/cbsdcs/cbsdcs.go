@@ -0,0 +1,294 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package cbsdcs provides the Cell Broadcast Data Coding Scheme, as defined
+// in 3GPP TS 23.038 Section 5, which is used by both CBS messages and USSD
+// strings.
+package cbsdcs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+// DCS is the CBS Data Coding Scheme.
+type DCS byte
+
+const (
+	// Unspecified is the DCS for text in the GSM7 default alphabet in an
+	// unspecified language.
+	Unspecified DCS = 0x0f
+
+	// Language7Bit is the DCS for text in the GSM7 default alphabet preceded
+	// by a language indication.
+	Language7Bit DCS = 0x10
+
+	// LanguageUCS2 is the DCS for text in UCS2 preceded by a language
+	// indication.
+	LanguageUCS2 DCS = 0x11
+
+	// Data8Bit is the DCS for 8 bit data.
+	Data8Bit DCS = 0x44
+
+	// UCS2 is the DCS for text in UCS2 in an unspecified language.
+	UCS2 DCS = 0x48
+)
+
+// languages maps the coding groups 0000 and 0010 to ISO 639 language codes.
+var languages = map[DCS]string{
+	0x00: "de",
+	0x01: "en",
+	0x02: "it",
+	0x03: "fr",
+	0x04: "es",
+	0x05: "nl",
+	0x06: "sv",
+	0x07: "da",
+	0x08: "pt",
+	0x09: "fi",
+	0x0a: "no",
+	0x0b: "el",
+	0x0c: "tr",
+	0x0d: "hu",
+	0x0e: "pl",
+	0x20: "cs",
+	0x21: "he",
+	0x22: "ar",
+	0x23: "ru",
+	0x24: "is",
+}
+
+// ForLanguage returns the DCS that indicates the language, for text in the
+// GSM7 default alphabet.
+//
+// Returns false if the language has no DCS, in which case the language must
+// be indicated in the text using Language7Bit.
+func ForLanguage(lang string) (DCS, bool) {
+	for d, l := range languages {
+		if l == lang {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// Alphabet returns the alphabet used to encode the text.
+func (d DCS) Alphabet() (tpdu.Alphabet, error) {
+	switch {
+	case d&0xc0 == 0x00: // 00xx
+		switch d {
+		case LanguageUCS2:
+			return tpdu.AlphaUCS2, nil
+		case 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b,
+			0x1c, 0x1d, 0x1e, 0x1f:
+			return tpdu.Alpha7Bit, ErrReserved
+		}
+		return tpdu.Alpha7Bit, nil
+	case d&0xc0 == 0x40, d&0xf0 == 0x90: // 01xx and 1001
+		alpha := tpdu.Alphabet((d >> 2) & 0x3)
+		if alpha == tpdu.AlphaReserved {
+			return tpdu.Alpha7Bit, ErrReserved
+		}
+		return alpha, nil
+	case d&0xf0 == 0xf0: // 1111
+		if d&0x04 == 0x04 {
+			return tpdu.Alpha8Bit, nil
+		}
+		return tpdu.Alpha7Bit, nil
+	default: // 1000, 1010..1101 reserved, and 1110 defined by WAP
+		return tpdu.Alpha7Bit, ErrReserved
+	}
+}
+
+// Class returns the message class indicated by the DCS, or MClassUnknown if
+// there is none.
+func (d DCS) Class() tpdu.MessageClass {
+	switch {
+	case d&0xd0 == 0x50, d&0xf0 == 0x90, d&0xf0 == 0xf0: // 01x1, 1001 and 1111
+		return tpdu.MessageClass(d & 0x3)
+	default:
+		return tpdu.MClassUnknown
+	}
+}
+
+// Compressed indicates whether the text is compressed using the algorithm
+// defined in 3GPP TS 23.042.
+func (d DCS) Compressed() bool {
+	return d&0xe0 == 0x60 // 011x
+}
+
+// HasUDH indicates the data is preceded by a User Data Header, which is only
+// applicable to CBS messages.
+func (d DCS) HasUDH() bool {
+	return d&0xf0 == 0x90
+}
+
+// Language returns the ISO 639 code of the language indicated by the DCS, or
+// an empty string if the DCS does not indicate a language.
+//
+// The language of text encoded with Language7Bit or LanguageUCS2 is
+// indicated in the text itself and is returned by Decode.
+func (d DCS) Language() string {
+	return languages[d]
+}
+
+// LanguageIndicated indicates the text is preceded by a language indication.
+func (d DCS) LanguageIndicated() bool {
+	return d == Language7Bit || d == LanguageUCS2
+}
+
+func (d DCS) String() string {
+	str := fmt.Sprintf("0x%02x", int(d))
+	alpha, err := d.Alphabet()
+	if err != nil {
+		return str
+	}
+	switch alpha {
+	case tpdu.Alpha7Bit:
+		str += " 7bit"
+	case tpdu.Alpha8Bit:
+		str += " 8bit"
+	case tpdu.AlphaUCS2:
+		str += " UCS-2"
+	}
+	if l := d.Language(); l != "" {
+		str += " " + l
+	}
+	return str
+}
+
+// Decode decodes the data encoded using the DCS, returning the text and the
+// ISO 639 code of the language, if known.
+//
+// GSM7 data is packed as per 3GPP TS 23.038 Section 6.1.2.3, so any trailing
+// CR filler is removed. Any User Data Header must be removed from the data
+// before decoding.
+//
+// 8 bit data is returned as is.
+func (d DCS) Decode(data []byte) (string, string, error) {
+	if d.Compressed() {
+		return "", "", ErrCompressed
+	}
+	alpha, err := d.Alphabet()
+	if err != nil {
+		return "", "", err
+	}
+	lang := d.Language()
+	switch alpha {
+	case tpdu.Alpha8Bit:
+		return string(data), lang, nil
+	case tpdu.AlphaUCS2:
+		if d == LanguageUCS2 {
+			if len(data) < 2 {
+				return "", "", ErrMissingLanguage
+			}
+			l, err := gsm7.Decode(gsm7.Unpack7Bit(data[:2], 0)[:2])
+			if err != nil {
+				return "", "", err
+			}
+			lang = string(l)
+			data = data[2:]
+		}
+		r, err := ucs2.Decode(data)
+		if err != nil {
+			return "", "", err
+		}
+		return string(r), lang, nil
+	}
+	u := gsm7.Unpack7BitUSSD(data, 0)
+	if d == Language7Bit {
+		if len(u) < 3 || u[2] != '\r' {
+			return "", "", ErrMissingLanguage
+		}
+		l, err := gsm7.Decode(u[:2])
+		if err != nil {
+			return "", "", err
+		}
+		lang = string(l)
+		u = u[3:]
+	}
+	t, err := gsm7.Decode(u)
+	if err != nil {
+		return "", "", err
+	}
+	return string(t), lang, nil
+}
+
+// Encode encodes the text using the GSM7 default alphabet, if possible, else
+// UCS2, returning the DCS and the encoded data.
+//
+// The lang is the ISO 639 code of the language of the text, and is indicated
+// in the DCS or in the data. The lang may be empty if unspecified.
+//
+// GSM7 data is packed as per 3GPP TS 23.038 Section 6.1.2.3.
+func Encode(text, lang string) (DCS, []byte, error) {
+	u, err := gsm7.Encode([]byte(text))
+	if err != nil {
+		return EncodeUCS2(text, lang)
+	}
+	d := Unspecified
+	if lang != "" {
+		var ok bool
+		if d, ok = ForLanguage(lang); !ok {
+			l, err := encodeLanguage(lang)
+			if err != nil {
+				return 0, nil, err
+			}
+			d = Language7Bit
+			u = append(append(l, '\r'), u...)
+		}
+	}
+	return d, gsm7.Pack7BitUSSD(u, 0), nil
+}
+
+// EncodeUCS2 encodes the text using UCS2, returning the DCS and the encoded
+// data.
+//
+// The lang is the ISO 639 code of the language of the text, and is indicated
+// in the data. The lang may be empty if unspecified.
+func EncodeUCS2(text, lang string) (DCS, []byte, error) {
+	data := ucs2.Encode([]rune(text))
+	if lang == "" {
+		return UCS2, data, nil
+	}
+	l, err := encodeLanguage(lang)
+	if err != nil {
+		return 0, nil, err
+	}
+	return LanguageUCS2, append(gsm7.Pack7Bit(l, 0), data...), nil
+}
+
+// encodeLanguage returns the language code as GSM7 septets.
+func encodeLanguage(lang string) ([]byte, error) {
+	l, err := gsm7.Encode([]byte(lang))
+	if err != nil || len(l) != 2 {
+		return nil, ErrInvalidLanguage(lang)
+	}
+	return l, nil
+}
+
+// ErrInvalidLanguage indicates the language is not a two character ISO 639
+// code.
+type ErrInvalidLanguage string
+
+func (e ErrInvalidLanguage) Error() string {
+	return fmt.Sprintf("cbsdcs: invalid language '%s'", string(e))
+}
+
+var (
+	// ErrCompressed indicates the text is compressed, which is not
+	// supported.
+	ErrCompressed = errors.New("cbsdcs: compressed text not supported")
+
+	// ErrMissingLanguage indicates the text does not start with the language
+	// indication required by the DCS.
+	ErrMissingLanguage = errors.New("cbsdcs: missing language indication")
+
+	// ErrReserved indicates the DCS is in a reserved coding group.
+	ErrReserved = errors.New("cbsdcs: reserved coding group")
+)
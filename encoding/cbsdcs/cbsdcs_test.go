@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cbsdcs_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/cbsdcs"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+func TestAlphabet(t *testing.T) {
+	patterns := []struct {
+		in  cbsdcs.DCS
+		out tpdu.Alphabet
+		err error
+	}{
+		{0x00, tpdu.Alpha7Bit, nil},
+		{0x0f, tpdu.Alpha7Bit, nil},
+		{0x10, tpdu.Alpha7Bit, nil},
+		{0x11, tpdu.AlphaUCS2, nil},
+		{0x12, tpdu.Alpha7Bit, cbsdcs.ErrReserved},
+		{0x24, tpdu.Alpha7Bit, nil},
+		{0x3f, tpdu.Alpha7Bit, nil},
+		{0x40, tpdu.Alpha7Bit, nil},
+		{0x44, tpdu.Alpha8Bit, nil},
+		{0x48, tpdu.AlphaUCS2, nil},
+		{0x4c, tpdu.Alpha7Bit, cbsdcs.ErrReserved},
+		{0x80, tpdu.Alpha7Bit, cbsdcs.ErrReserved},
+		{0x95, tpdu.Alpha8Bit, nil},
+		{0x99, tpdu.AlphaUCS2, nil},
+		{0xa0, tpdu.Alpha7Bit, cbsdcs.ErrReserved},
+		{0xe0, tpdu.Alpha7Bit, cbsdcs.ErrReserved},
+		{0xf1, tpdu.Alpha7Bit, nil},
+		{0xf6, tpdu.Alpha8Bit, nil},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			a, err := p.in.Alphabet()
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, a)
+		}
+		t.Run(p.in.String(), f)
+	}
+}
+
+func TestClass(t *testing.T) {
+	patterns := []struct {
+		in  cbsdcs.DCS
+		out tpdu.MessageClass
+	}{
+		{0x0f, tpdu.MClassUnknown},
+		{0x41, tpdu.MClassUnknown},
+		{0x51, tpdu.MClass1},
+		{0x5a, tpdu.MClass2},
+		{0x93, tpdu.MClass3},
+		{0xf0, tpdu.MClass0},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			assert.Equal(t, p.out, p.in.Class())
+		}
+		t.Run(p.in.String(), f)
+	}
+}
+
+func TestFlags(t *testing.T) {
+	assert.True(t, cbsdcs.DCS(0x60).Compressed())
+	assert.True(t, cbsdcs.DCS(0x7f).Compressed())
+	assert.False(t, cbsdcs.DCS(0x48).Compressed())
+	assert.True(t, cbsdcs.DCS(0x90).HasUDH())
+	assert.False(t, cbsdcs.DCS(0x0f).HasUDH())
+	assert.True(t, cbsdcs.Language7Bit.LanguageIndicated())
+	assert.True(t, cbsdcs.LanguageUCS2.LanguageIndicated())
+	assert.False(t, cbsdcs.Unspecified.LanguageIndicated())
+}
+
+func TestLanguage(t *testing.T) {
+	assert.Equal(t, "de", cbsdcs.DCS(0x00).Language())
+	assert.Equal(t, "pl", cbsdcs.DCS(0x0e).Language())
+	assert.Equal(t, "", cbsdcs.DCS(0x0f).Language())
+	assert.Equal(t, "ru", cbsdcs.DCS(0x23).Language())
+	assert.Equal(t, "", cbsdcs.DCS(0x25).Language())
+	assert.Equal(t, "", cbsdcs.DCS(0x48).Language())
+
+	d, ok := cbsdcs.ForLanguage("en")
+	assert.True(t, ok)
+	assert.Equal(t, cbsdcs.DCS(0x01), d)
+	d, ok = cbsdcs.ForLanguage("is")
+	assert.True(t, ok)
+	assert.Equal(t, cbsdcs.DCS(0x24), d)
+	_, ok = cbsdcs.ForLanguage("ja")
+	assert.False(t, ok)
+}
+
+func TestDecode(t *testing.T) {
+	patterns := []struct {
+		name string
+		dcs  cbsdcs.DCS
+		in   string
+		text string
+		lang string
+		err  error
+	}{
+		{"gsm7", 0x0f, "AA180C3602", "*100#", "", nil},
+		{"gsm7 cr filler", 0x0f, "31D98C56B3DD1A", "1234567", "", nil},
+		{"gsm7 language", 0x01, "C8329BFD06", "Hello", "en", nil},
+		{"gsm7 language indicated", 0x10, "EA7063980EBF1B", "Ciao", "ja", nil},
+		{"gsm7 language missing", 0x10, "C3", "", "", cbsdcs.ErrMissingLanguage},
+		{"gsm7 language no cr", 0x10, "EAB0BC0C", "", "", cbsdcs.ErrMissingLanguage},
+		{"8bit", 0x44, "0102", "\x01\x02", "", nil},
+		{"ucs2", 0x48, "041F04400438043204350442", "Привет", "", nil},
+		{"ucs2 language indicated", 0x11, "F23A041F04400438043204350442", "Привет", "ru", nil},
+		{"ucs2 language missing", 0x11, "F2", "", "", cbsdcs.ErrMissingLanguage},
+		{"ucs2 odd", 0x48, "041F04", "", "", ucs2.ErrInvalidLength},
+		{"compressed", 0x60, "00", "", "", cbsdcs.ErrCompressed},
+		{"reserved", 0x80, "00", "", "", cbsdcs.ErrReserved},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			data, err := hex.DecodeString(p.in)
+			require.Nil(t, err)
+			text, lang, err := p.dcs.Decode(data)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.text, text)
+			assert.Equal(t, p.lang, lang)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	patterns := []struct {
+		name string
+		text string
+		lang string
+		dcs  cbsdcs.DCS
+		out  string
+		err  error
+	}{
+		{"gsm7", "*100#", "", 0x0f, "AA180C3602", nil},
+		{"gsm7 cr filler", "1234567", "", 0x0f, "31D98C56B3DD1A", nil},
+		{"gsm7 language", "Hello", "en", 0x01, "C8329BFD06", nil},
+		{"gsm7 language indicated", "Ciao", "ja", 0x10, "EA7063980EBF1B", nil},
+		{"ucs2", "Привет", "", 0x48, "041F04400438043204350442", nil},
+		{"ucs2 language indicated", "Привет", "ru", 0x11, "F23A041F04400438043204350442", nil},
+		{"invalid language", "Ciao", "jpn", 0, "", cbsdcs.ErrInvalidLanguage("jpn")},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			dcs, data, err := cbsdcs.Encode(p.text, p.lang)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.dcs, dcs)
+			assert.Equal(t, p.out, hexString(data))
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestEncodeUCS2(t *testing.T) {
+	dcs, data, err := cbsdcs.EncodeUCS2("Hi", "")
+	assert.Nil(t, err)
+	assert.Equal(t, cbsdcs.UCS2, dcs)
+	assert.Equal(t, []byte{0, 'H', 0, 'i'}, data)
+
+	dcs, data, err = cbsdcs.EncodeUCS2("Hi", "en")
+	assert.Nil(t, err)
+	assert.Equal(t, cbsdcs.LanguageUCS2, dcs)
+	assert.Equal(t, []byte{0x65, 0x37, 0, 'H', 0, 'i'}, data)
+
+	_, _, err = cbsdcs.EncodeUCS2("Hi", "e")
+	assert.Equal(t, cbsdcs.ErrInvalidLanguage("e"), err)
+}
+
+func hexString(b []byte) string {
+	if b == nil {
+		return ""
+	}
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+func TestNewEncodeConfig(t *testing.T) {
+	assert.Equal(t, cbsdcs.EncodeConfig{}, cbsdcs.NewEncodeConfig())
+	assert.Equal(t, cbsdcs.EncodeConfig{Lang: "de", UCS2: true},
+		cbsdcs.NewEncodeConfig(cbsdcs.WithLanguage("fr"), cbsdcs.AsUCS2, cbsdcs.WithLanguage("de")))
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cbsdcs
+
+// EncodeOption alters the encoding of text using a CBS DCS, such as by the
+// Encode functions of the cbs and ussd packages.
+type EncodeOption interface {
+	applyEncodeOption(*EncodeConfig)
+}
+
+// EncodeConfig is the configuration resulting from a set of EncodeOptions.
+type EncodeConfig struct {
+	// Lang is the ISO 639 code of the language of the text, or empty if
+	// unspecified.
+	Lang string
+
+	// UCS2 indicates the text is to be encoded as UCS2, even if it could be
+	// encoded using the GSM7 default alphabet.
+	UCS2 bool
+}
+
+// NewEncodeConfig returns the configuration resulting from applying the
+// options, in order, to the default configuration.
+func NewEncodeConfig(options ...EncodeOption) EncodeConfig {
+	cfg := EncodeConfig{}
+	for _, option := range options {
+		option.applyEncodeOption(&cfg)
+	}
+	return cfg
+}
+
+// LanguageOption specifies the language of the text.
+type LanguageOption string
+
+func (o LanguageOption) applyEncodeOption(c *EncodeConfig) {
+	c.Lang = string(o)
+}
+
+// WithLanguage specifies the ISO 639 code of the language of the text.
+//
+// The language is indicated in the DCS if possible, else in a language
+// indication preceding the text.
+func WithLanguage(lang string) LanguageOption {
+	return LanguageOption(lang)
+}
+
+type ucs2Option struct{}
+
+func (o ucs2Option) applyEncodeOption(c *EncodeConfig) {
+	c.UCS2 = true
+}
+
+// AsUCS2 specifies the text is always encoded as UCS2, even if it could be
+// encoded using the GSM7 default alphabet.
+var AsUCS2 = ucs2Option{}
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cdma
+
+import (
+	"strings"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// NumberType is the NUMBER_TYPE of an address, as defined in C.S0015 Section
+// 3.4.3.3.
+//
+// The interpretation differs for data network addresses, for which only
+// NumberTypeUnknown, NumberTypeIP and NumberTypeEmail are defined.
+type NumberType byte
+
+const (
+	// NumberTypeUnknown indicates the type of the number is unknown.
+	NumberTypeUnknown NumberType = iota
+
+	// NumberTypeInternational indicates the number is international.
+	NumberTypeInternational
+
+	// NumberTypeNational indicates the number is national.
+	NumberTypeNational
+
+	// NumberTypeNetworkSpecific indicates the number is network specific.
+	NumberTypeNetworkSpecific
+
+	// NumberTypeSubscriber indicates the number is a subscriber number.
+	NumberTypeSubscriber
+
+	// NumberTypeAbbreviated indicates the number is abbreviated.
+	NumberTypeAbbreviated NumberType = 6
+)
+
+const (
+	// NumberTypeIP indicates a data network address is an IP address.
+	NumberTypeIP NumberType = 1
+
+	// NumberTypeEmail indicates a data network address is an email address.
+	NumberTypeEmail NumberType = 2
+)
+
+// NumberPlan is the NUMBER_PLAN of an address, as defined in C.S0015 Section
+// 3.4.3.3.
+type NumberPlan byte
+
+const (
+	// NumberPlanUnknown indicates the numbering plan is unknown.
+	NumberPlanUnknown NumberPlan = 0
+
+	// NumberPlanISDN indicates the ISDN/telephony numbering plan (E.164).
+	NumberPlanISDN NumberPlan = 1
+
+	// NumberPlanData indicates the data numbering plan (X.121).
+	NumberPlanData NumberPlan = 3
+
+	// NumberPlanTelex indicates the telex numbering plan (F.69).
+	NumberPlanTelex NumberPlan = 4
+
+	// NumberPlanPrivate indicates a private numbering plan.
+	NumberPlanPrivate NumberPlan = 9
+)
+
+// Address is an originating, destination or callback address.
+type Address struct {
+	// ASCII indicates the DIGIT_MODE is 8 bit ASCII, rather than 4 bit DTMF.
+	ASCII bool
+
+	// DataNetwork indicates the NUMBER_MODE is a data network address,
+	// such as an email address, rather than a telephone number.
+	//
+	// Only applies to ASCII originating and destination addresses.
+	DataNetwork bool
+
+	// Type is the NUMBER_TYPE, which only applies to ASCII addresses.
+	Type NumberType
+
+	// Plan is the NUMBER_PLAN, which only applies to ASCII addresses that
+	// are not data network addresses.
+	Plan NumberPlan
+
+	// Digits are the address characters.
+	//
+	// For DTMF addresses these are restricted to '0'-'9', '*' and '#'.
+	Digits string
+}
+
+// NewAddress creates an address for the telephone number.
+//
+// Numbers prefixed with '+' are encoded as international ASCII addresses.
+// Other numbers are encoded as DTMF, if possible, else as ASCII.
+func NewAddress(number string) Address {
+	if strings.HasPrefix(number, "+") {
+		return Address{
+			ASCII:  true,
+			Type:   NumberTypeInternational,
+			Plan:   NumberPlanISDN,
+			Digits: number[1:],
+		}
+	}
+	for _, r := range number {
+		if _, ok := dtmfCode(r); !ok {
+			return Address{ASCII: true, Plan: NumberPlanISDN, Digits: number}
+		}
+	}
+	return Address{Digits: number}
+}
+
+// Number returns the telephone number of the address.
+//
+// International numbers are prefixed with '+'.
+func (a Address) Number() string {
+	if a.ASCII && !a.DataNetwork && a.Type == NumberTypeInternational {
+		return "+" + a.Digits
+	}
+	return a.Digits
+}
+
+// dtmfDigits maps the 4 bit DTMF codes, from 1, to their characters.
+const dtmfDigits = "1234567890*#"
+
+func dtmfCode(r rune) (uint32, bool) {
+	i := strings.IndexRune(dtmfDigits, r)
+	if i < 0 {
+		return 0, false
+	}
+	return uint32(i + 1), true
+}
+
+// write writes the address fields, from the DIGIT_MODE to the final CHARi.
+//
+// The NUMBER_MODE is only included if numberMode is set, as it is for the
+// originating and destination addresses, but not for callback numbers.
+func (a *Address) write(w *bitWriter, numberMode bool) error {
+	if len(a.Digits) > 0xff {
+		return tpdu.ErrOverlength
+	}
+	w.write(boolBit(a.ASCII), 1)
+	if numberMode {
+		w.write(boolBit(a.DataNetwork && a.ASCII), 1)
+	}
+	if a.ASCII {
+		w.write(uint32(a.Type), 3)
+		if !numberMode || !a.DataNetwork {
+			w.write(uint32(a.Plan), 4)
+		}
+	}
+	w.write(uint32(len(a.Digits)), 8)
+	if a.ASCII {
+		w.writeBytes([]byte(a.Digits))
+		return nil
+	}
+	for _, r := range a.Digits {
+		c, ok := dtmfCode(r)
+		if !ok {
+			return ErrInvalidDigit(r)
+		}
+		w.write(c, 4)
+	}
+	return nil
+}
+
+// readAddress reads the address fields written by write.
+func readAddress(r *bitReader, numberMode bool) (Address, error) {
+	a := Address{}
+	v, err := r.read(1)
+	if err != nil {
+		return a, err
+	}
+	a.ASCII = v != 0
+	if numberMode {
+		if v, err = r.read(1); err != nil {
+			return a, err
+		}
+		a.DataNetwork = v != 0
+	}
+	if a.ASCII {
+		if v, err = r.read(3); err != nil {
+			return a, err
+		}
+		a.Type = NumberType(v)
+		if !a.DataNetwork {
+			if v, err = r.read(4); err != nil {
+				return a, err
+			}
+			a.Plan = NumberPlan(v)
+		}
+	}
+	n, err := r.read(8)
+	if err != nil {
+		return a, err
+	}
+	if a.ASCII {
+		b, err := r.readBytes(int(n))
+		if err != nil {
+			return a, err
+		}
+		a.Digits = string(b)
+		return a, nil
+	}
+	d := make([]byte, n)
+	for i := range d {
+		if v, err = r.read(4); err != nil {
+			return a, err
+		}
+		if v < 1 || int(v) > len(dtmfDigits) {
+			return a, ErrInvalidDTMF(v)
+		}
+		d[i] = dtmfDigits[v-1]
+	}
+	a.Digits = string(d)
+	return a, nil
+}
+
+func boolBit(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
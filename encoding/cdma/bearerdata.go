@@ -0,0 +1,280 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cdma
+
+import (
+	"time"
+
+	"github.com/warthog618/sms/encoding/bcd"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// BearerMessageType is the MESSAGE_TYPE of the message identifier, as
+// defined in C.S0015 Section 4.5.1.
+type BearerMessageType byte
+
+const (
+	// Deliver is a message delivered to the mobile station.
+	Deliver BearerMessageType = iota + 1
+
+	// Submit is a message submitted by the mobile station.
+	Submit
+
+	// Cancellation requests the cancellation of a previously submitted
+	// message.
+	Cancellation
+
+	// DeliveryAck is a delivery acknowledgement from the message center.
+	DeliveryAck
+
+	// UserAck is an acknowledgement from the recipient.
+	UserAck
+
+	// ReadAck indicates the message has been read by the recipient.
+	ReadAck
+)
+
+// Priority is the PRIORITY of the Priority Indicator, as defined in C.S0015
+// Section 4.5.9.
+type Priority byte
+
+const (
+	// PriorityNormal is the default priority.
+	PriorityNormal Priority = iota
+
+	// PriorityInteractive indicates an interactive message.
+	PriorityInteractive
+
+	// PriorityUrgent indicates an urgent message.
+	PriorityUrgent
+
+	// PriorityEmergency indicates an emergency message.
+	PriorityEmergency
+)
+
+// ReplyOption is the Reply Option subparameter, as defined in C.S0015 Section
+// 4.5.11, requesting acknowledgements for a message.
+type ReplyOption struct {
+	// UserAck requests a user acknowledgement.
+	UserAck bool
+
+	// DeliveryAck requests a delivery acknowledgement.
+	DeliveryAck bool
+
+	// ReadAck requests a read acknowledgement.
+	ReadAck bool
+
+	// Report requests a delivery report.
+	Report bool
+}
+
+// Subparameter identifiers, as defined in C.S0015 Section 4.5.
+const (
+	subMessageID      = 0x00
+	subUserData       = 0x01
+	subMCTimeStamp    = 0x03
+	subPriority       = 0x08
+	subReplyOption    = 0x0a
+	subCallbackNumber = 0x0e
+)
+
+// BearerData is the Bearer Data parameter of a transport layer message, as
+// defined in C.S0015 Section 4.5.
+//
+// Subparameters other than those represented here are ignored when
+// decoding.
+type BearerData struct {
+	MessageType BearerMessageType
+
+	// MessageID is the MESSAGE_ID of the message identifier.
+	MessageID uint16
+
+	// UserData is the optional user data.
+	//
+	// The HEADER_IND of the message identifier is set if the user data has
+	// a UDH.
+	UserData *UserData
+
+	// Timestamp is the optional Message Center Time Stamp, which is
+	// omitted if zero.
+	//
+	// The time stamp carries no time zone, so is decoded as UTC.
+	Timestamp time.Time
+
+	// Priority is the Priority Indicator, which is omitted if
+	// PriorityNormal.
+	Priority Priority
+
+	// ReplyOption is the optional Reply Option.
+	ReplyOption *ReplyOption
+
+	// CallbackNumber is the optional Call-Back Number.
+	CallbackNumber *Address
+}
+
+// MarshalBinary encodes the bearer data into its binary form.
+func (bd *BearerData) MarshalBinary() ([]byte, error) {
+	hi := bd.UserData != nil && len(bd.UserData.UDH) > 0
+	w := bitWriter{}
+	w.write(uint32(bd.MessageType), 4)
+	w.write(uint32(bd.MessageID), 16)
+	w.write(boolBit(hi), 1)
+	w.write(0, 3)
+	b := appendParameter(nil, subMessageID, w.bytes())
+	if bd.UserData != nil {
+		ud, err := bd.UserData.marshal()
+		if err != nil {
+			return nil, tpdu.EncodeError("ud", err)
+		}
+		if len(ud) > 0xff {
+			return nil, tpdu.EncodeError("ud", tpdu.ErrOverlength)
+		}
+		b = appendParameter(b, subUserData, ud)
+	}
+	if !bd.Timestamp.IsZero() {
+		ts, err := marshalTimestamp(bd.Timestamp)
+		if err != nil {
+			return nil, tpdu.EncodeError("timestamp", err)
+		}
+		b = appendParameter(b, subMCTimeStamp, ts)
+	}
+	if bd.Priority != PriorityNormal {
+		b = appendParameter(b, subPriority, []byte{byte(bd.Priority&0x03) << 6})
+	}
+	if ro := bd.ReplyOption; ro != nil {
+		w = bitWriter{}
+		for _, f := range []bool{ro.UserAck, ro.DeliveryAck, ro.ReadAck, ro.Report} {
+			w.write(boolBit(f), 1)
+		}
+		w.write(0, 4)
+		b = appendParameter(b, subReplyOption, w.bytes())
+	}
+	if bd.CallbackNumber != nil {
+		w = bitWriter{}
+		if err := bd.CallbackNumber.write(&w, false); err != nil {
+			return nil, tpdu.EncodeError("callback", err)
+		}
+		if len(w.bytes()) > 0xff {
+			return nil, tpdu.EncodeError("callback", tpdu.ErrOverlength)
+		}
+		b = appendParameter(b, subCallbackNumber, w.bytes())
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the bearer data from its binary form.
+func (bd *BearerData) UnmarshalBinary(b []byte) error {
+	n := BearerData{}
+	var hi bool
+	var ud []byte
+	udOff := 0
+	found := false
+	err := parseParameters(b, 0, func(id byte, off int, data []byte) error {
+		switch id {
+		case subMessageID:
+			r := bitReader{b: data}
+			mt, err := r.read(4)
+			if err != nil {
+				return tpdu.NewDecodeError("msgid", off, err)
+			}
+			id, err := r.read(16)
+			if err != nil {
+				return tpdu.NewDecodeError("msgid", off, err)
+			}
+			h, err := r.read(1)
+			if err != nil {
+				return tpdu.NewDecodeError("msgid", off, err)
+			}
+			n.MessageType = BearerMessageType(mt)
+			n.MessageID = uint16(id)
+			hi = h != 0
+			found = true
+		case subUserData:
+			// decoded once the HEADER_IND is known
+			ud = data
+			udOff = off
+		case subMCTimeStamp:
+			ts, err := unmarshalTimestamp(data)
+			if err != nil {
+				return tpdu.NewDecodeError("timestamp", off, err)
+			}
+			n.Timestamp = ts
+		case subPriority:
+			if len(data) < 1 {
+				return tpdu.NewDecodeError("priority", off, tpdu.ErrUnderflow)
+			}
+			n.Priority = Priority(data[0] >> 6)
+		case subReplyOption:
+			if len(data) < 1 {
+				return tpdu.NewDecodeError("replyoption", off, tpdu.ErrUnderflow)
+			}
+			n.ReplyOption = &ReplyOption{
+				UserAck:     data[0]&0x80 != 0,
+				DeliveryAck: data[0]&0x40 != 0,
+				ReadAck:     data[0]&0x20 != 0,
+				Report:      data[0]&0x10 != 0,
+			}
+		case subCallbackNumber:
+			a, err := readAddress(&bitReader{b: data}, false)
+			if err != nil {
+				return tpdu.NewDecodeError("callback", off, err)
+			}
+			n.CallbackNumber = &a
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return tpdu.NewDecodeError("msgid", 0, tpdu.ErrMissing)
+	}
+	if ud != nil {
+		n.UserData = &UserData{}
+		if err = n.UserData.unmarshal(ud, hi); err != nil {
+			return tpdu.NewDecodeError("ud", udOff, err)
+		}
+	}
+	*bd = n
+	return nil
+}
+
+// marshalTimestamp encodes the Message Center Time Stamp, being the year,
+// month, day, hours, minutes and seconds, each as BCD.
+func marshalTimestamp(t time.Time) ([]byte, error) {
+	f := []int{t.Year() % 100, int(t.Month()), t.Day(), t.Hour(), t.Minute(), t.Second()}
+	b := make([]byte, len(f))
+	for i, v := range f {
+		d, err := bcd.Encode(v)
+		if err != nil {
+			return nil, err
+		}
+		// the CDMA BCD has the most significant digit first
+		b[i] = d<<4 | d>>4
+	}
+	return b, nil
+}
+
+// unmarshalTimestamp decodes the Message Center Time Stamp.
+//
+// Years from 96 are in the 20th century, as per C.S0015 Section 4.5.4.
+func unmarshalTimestamp(b []byte) (time.Time, error) {
+	if len(b) < 6 {
+		return time.Time{}, tpdu.ErrUnderflow
+	}
+	var f [6]int
+	for i := range f {
+		v, err := bcd.Decode(b[i]<<4 | b[i]>>4)
+		if err != nil {
+			return time.Time{}, err
+		}
+		f[i] = v
+	}
+	y := f[0] + 2000
+	if f[0] >= 96 {
+		y -= 100
+	}
+	return time.Date(y, time.Month(f[1]), f[2], f[3], f[4], f[5], 0, time.UTC), nil
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cdma
+
+import (
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// bitWriter writes fields of arbitrary bit length, most significant bit
+// first, as used throughout C.S0015.
+type bitWriter struct {
+	b []byte
+	n int // number of bits written
+}
+
+// write writes the least significant bits of v.
+func (w *bitWriter) write(v uint32, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.b = append(w.b, 0)
+		}
+		if v&(1<<uint(i)) != 0 {
+			w.b[w.n/8] |= 0x80 >> uint(w.n%8)
+		}
+		w.n++
+	}
+}
+
+// writeBytes writes each of the bytes as an 8 bit field.
+func (w *bitWriter) writeBytes(b []byte) {
+	for _, v := range b {
+		w.write(uint32(v), 8)
+	}
+}
+
+// bytes returns the fields written, with zero padding to the next octet
+// boundary.
+func (w *bitWriter) bytes() []byte {
+	return w.b
+}
+
+// bitReader reads fields of arbitrary bit length, most significant bit
+// first.
+type bitReader struct {
+	b []byte
+	n int // number of bits read
+}
+
+// read reads a field of the given length.
+func (r *bitReader) read(bits int) (uint32, error) {
+	if r.n+bits > len(r.b)*8 {
+		return 0, tpdu.ErrUnderflow
+	}
+	var v uint32
+	for i := 0; i < bits; i++ {
+		v <<= 1
+		if r.b[r.n/8]&(0x80>>uint(r.n%8)) != 0 {
+			v |= 1
+		}
+		r.n++
+	}
+	return v, nil
+}
+
+// readBytes reads n 8 bit fields.
+func (r *bitReader) readBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	for i := range b {
+		v, err := r.read(8)
+		if err != nil {
+			return nil, err
+		}
+		b[i] = byte(v)
+	}
+	return b, nil
+}
+
+// offset returns the octet containing the next bit to be read, for error
+// reporting.
+func (r *bitReader) offset() int {
+	return r.n / 8
+}
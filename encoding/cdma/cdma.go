@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package cdma provides encoding and decoding of 3GPP2 CDMA SMS messages, as
+// defined in C.S0015 (IS-637), and conversions between those messages and
+// TPDUs.
+//
+// The package covers the transport layer messages and the bearer data
+// subparameters most relevant to bridging with GSM, being the message
+// identifier, user data, message center time stamp, priority, reply option
+// and callback number.
+package cdma
+
+import (
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// MessageType is the SMS_MSG_TYPE of a transport layer message, as defined
+// in C.S0015 Section 3.4.1.
+type MessageType byte
+
+const (
+	// PointToPoint is a message sent to or from a single mobile station.
+	PointToPoint MessageType = iota
+
+	// Broadcast is a message broadcast to mobile stations in an area.
+	Broadcast
+
+	// Acknowledge is a transport layer acknowledgement of a point-to-point
+	// message.
+	Acknowledge
+)
+
+// Teleservice is the Teleservice Identifier, as defined in C.S0015 Section
+// 3.4.3.1.
+type Teleservice uint16
+
+const (
+	// TeleservicePaging is the IS-95 Cellular Paging Teleservice (CPT-95).
+	TeleservicePaging Teleservice = 4097
+
+	// TeleserviceMessaging is the IS-95 Cellular Messaging Teleservice
+	// (CMT-95).
+	TeleserviceMessaging Teleservice = 4098
+
+	// TeleserviceVoiceMail is the Voice Mail Notification (VMN-95).
+	TeleserviceVoiceMail Teleservice = 4099
+
+	// TeleserviceEMS is the Wireless Enhanced Messaging Teleservice (WEMT),
+	// which carries a UDH.
+	TeleserviceEMS Teleservice = 4101
+
+	// TeleserviceCatalog is the Service Category Programming Teleservice
+	// (SCPT).
+	TeleserviceCatalog Teleservice = 4102
+)
+
+// ErrorClass is the ERROR_CLASS of the Cause Codes.
+type ErrorClass byte
+
+const (
+	// NoError indicates the message was accepted.
+	NoError ErrorClass = 0
+
+	// TemporaryError indicates a temporary failure.
+	TemporaryError ErrorClass = 2
+
+	// PermanentError indicates a permanent failure.
+	PermanentError ErrorClass = 3
+)
+
+// CauseCodes is the Cause Codes parameter, as defined in C.S0015 Section
+// 3.4.3.6, which reports the result of a point-to-point message.
+type CauseCodes struct {
+	// ReplySeq is the REPLY_SEQ of the message being acknowledged.
+	ReplySeq byte
+
+	ErrorClass ErrorClass
+
+	// Cause is the CAUSE_CODE, which is only present if the ErrorClass is
+	// not NoError.
+	Cause byte
+}
+
+// Parameter identifiers, as defined in C.S0015 Section 3.4.3.
+const (
+	paramTeleservice     = 0x00
+	paramServiceCategory = 0x01
+	paramOA              = 0x02
+	paramDA              = 0x04
+	paramBearerReply     = 0x06
+	paramCauseCodes      = 0x07
+	paramBearerData      = 0x08
+)
+
+// Message is a transport layer message.
+//
+// The subaddress parameters are ignored when decoding.
+type Message struct {
+	Type MessageType
+
+	// Teleservice is the Teleservice Identifier, which is omitted if zero.
+	Teleservice Teleservice
+
+	// ServiceCategory is the Broadcast Service Category, which only applies
+	// to broadcast messages.
+	ServiceCategory uint16
+
+	// OA is the optional Originating Address.
+	OA *Address
+
+	// DA is the optional Destination Address.
+	DA *Address
+
+	// BearerReply indicates the Bearer Reply Option is present, requesting
+	// a transport layer acknowledgement.
+	BearerReply bool
+
+	// ReplySeq is the REPLY_SEQ of the Bearer Reply Option.
+	ReplySeq byte
+
+	// CauseCodes is the optional Cause Codes.
+	CauseCodes *CauseCodes
+
+	// BearerData is the optional Bearer Data.
+	BearerData *BearerData
+}
+
+// MarshalBinary encodes the message into its binary form.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if m.Type > Acknowledge {
+		return nil, tpdu.EncodeError("type", ErrUnsupportedMessageType(m.Type))
+	}
+	b := []byte{byte(m.Type)}
+	if m.Teleservice != 0 {
+		b = appendParameter(b, paramTeleservice, uint16Bytes(uint16(m.Teleservice)))
+	}
+	if m.Type == Broadcast {
+		b = appendParameter(b, paramServiceCategory, uint16Bytes(m.ServiceCategory))
+	}
+	for _, a := range []struct {
+		id   byte
+		name string
+		addr *Address
+	}{{paramOA, "oa", m.OA}, {paramDA, "da", m.DA}} {
+		if a.addr == nil {
+			continue
+		}
+		w := bitWriter{}
+		if err := a.addr.write(&w, true); err != nil {
+			return nil, tpdu.EncodeError(a.name, err)
+		}
+		if len(w.bytes()) > 0xff {
+			return nil, tpdu.EncodeError(a.name, tpdu.ErrOverlength)
+		}
+		b = appendParameter(b, a.id, w.bytes())
+	}
+	if m.BearerReply {
+		b = appendParameter(b, paramBearerReply, []byte{m.ReplySeq << 2})
+	}
+	if cc := m.CauseCodes; cc != nil {
+		d := []byte{cc.ReplySeq<<2 | byte(cc.ErrorClass&0x03)}
+		if cc.ErrorClass != NoError {
+			d = append(d, cc.Cause)
+		}
+		b = appendParameter(b, paramCauseCodes, d)
+	}
+	if m.BearerData != nil {
+		bd, err := m.BearerData.MarshalBinary()
+		if err != nil {
+			return nil, tpdu.EncodeError("bearerdata", err)
+		}
+		if len(bd) > 0xff {
+			return nil, tpdu.EncodeError("bearerdata", tpdu.ErrOverlength)
+		}
+		b = appendParameter(b, paramBearerData, bd)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the message from its binary form.
+func (m *Message) UnmarshalBinary(b []byte) error {
+	if len(b) < 1 {
+		return tpdu.NewDecodeError("type", 0, tpdu.ErrUnderflow)
+	}
+	n := Message{Type: MessageType(b[0])}
+	if n.Type > Acknowledge {
+		return tpdu.NewDecodeError("type", 0, ErrUnsupportedMessageType(n.Type))
+	}
+	err := parseParameters(b, 1, func(id byte, off int, data []byte) error {
+		switch id {
+		case paramTeleservice:
+			if len(data) < 2 {
+				return tpdu.NewDecodeError("teleservice", off, tpdu.ErrUnderflow)
+			}
+			n.Teleservice = Teleservice(uint16(data[0])<<8 | uint16(data[1]))
+		case paramServiceCategory:
+			if len(data) < 2 {
+				return tpdu.NewDecodeError("category", off, tpdu.ErrUnderflow)
+			}
+			n.ServiceCategory = uint16(data[0])<<8 | uint16(data[1])
+		case paramOA, paramDA:
+			a, err := readAddress(&bitReader{b: data}, true)
+			if id == paramOA {
+				if err != nil {
+					return tpdu.NewDecodeError("oa", off, err)
+				}
+				n.OA = &a
+			} else {
+				if err != nil {
+					return tpdu.NewDecodeError("da", off, err)
+				}
+				n.DA = &a
+			}
+		case paramBearerReply:
+			if len(data) < 1 {
+				return tpdu.NewDecodeError("bearerreply", off, tpdu.ErrUnderflow)
+			}
+			n.BearerReply = true
+			n.ReplySeq = data[0] >> 2
+		case paramCauseCodes:
+			if len(data) < 1 {
+				return tpdu.NewDecodeError("causecodes", off, tpdu.ErrUnderflow)
+			}
+			cc := CauseCodes{ReplySeq: data[0] >> 2, ErrorClass: ErrorClass(data[0] & 0x03)}
+			if cc.ErrorClass != NoError {
+				if len(data) < 2 {
+					return tpdu.NewDecodeError("causecodes", off, tpdu.ErrUnderflow)
+				}
+				cc.Cause = data[1]
+			}
+			n.CauseCodes = &cc
+		case paramBearerData:
+			bd := BearerData{}
+			if err := bd.UnmarshalBinary(data); err != nil {
+				return tpdu.NewDecodeError("bearerdata", off, err)
+			}
+			n.BearerData = &bd
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*m = n
+	return nil
+}
+
+// appendParameter appends a parameter or subparameter, being the identifier,
+// length and data, to b.
+func appendParameter(b []byte, id byte, data []byte) []byte {
+	b = append(b, id, byte(len(data)))
+	return append(b, data...)
+}
+
+// parseParameters calls f with the identifier, offset and data of each
+// parameter or subparameter in b, starting from offset ri.
+func parseParameters(b []byte, ri int, f func(id byte, off int, data []byte) error) error {
+	for ri < len(b) {
+		if ri+2 > len(b) {
+			return tpdu.NewDecodeError("parameter", ri, tpdu.ErrUnderflow)
+		}
+		id := b[ri]
+		l := int(b[ri+1])
+		if ri+2+l > len(b) {
+			return tpdu.NewDecodeError("parameter", ri, tpdu.ErrUnderflow)
+		}
+		if err := f(id, ri, b[ri+2:ri+2+l]); err != nil {
+			return err
+		}
+		ri += 2 + l
+	}
+	return nil
+}
+
+func uint16Bytes(v uint16) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
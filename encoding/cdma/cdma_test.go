@@ -0,0 +1,422 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cdma_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/cdma"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+func addr(number string) *cdma.Address {
+	a := cdma.NewAddress(number)
+	return &a
+}
+
+func gsm7UD(t *testing.T, text string) *cdma.UserData {
+	t.Helper()
+	u, err := cdma.EncodeUserData(text, cdma.EncodingGSM7)
+	require.Nil(t, err)
+	return u
+}
+
+type testPattern struct {
+	name string
+	in   string
+	msg  func(t *testing.T) *cdma.Message
+}
+
+var patterns = []testPattern{
+	{
+		"submit",
+		"00" + "00021002" + "04040118E440" +
+			"080B" + "0003200010" + "010410168D20",
+		func(t *testing.T) *cdma.Message {
+			return &cdma.Message{
+				Teleservice: cdma.TeleserviceMessaging,
+				DA:          addr("6391"),
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Submit,
+					MessageID:   1,
+					UserData:    cdma.NewUserData("hi"),
+				},
+			}
+		},
+	},
+	{
+		"deliver",
+		"00" + "00021002" + "020788821B199C9880" + "060114" +
+			"0821" + "0003112340" + "0107402B474B636378" +
+			"0306200517230250" + "080180" + "0A0180" + "0E03018918",
+		func(t *testing.T) *cdma.Message {
+			return &cdma.Message{
+				Teleservice: cdma.TeleserviceMessaging,
+				OA:          addr("+6391"),
+				BearerReply: true,
+				ReplySeq:    5,
+				BearerData: &cdma.BearerData{
+					MessageType:    cdma.Deliver,
+					MessageID:      0x1234,
+					UserData:       cdma.NewUserData("héllo"),
+					Timestamp:      time.Date(2020, 5, 17, 23, 2, 50, 0, time.UTC),
+					Priority:       cdma.PriorityUrgent,
+					ReplyOption:    &cdma.ReplyOption{UserAck: true},
+					CallbackNumber: addr("123"),
+				},
+			}
+		},
+	},
+	{
+		"udh",
+		"00" + "00021005" + "04040118E440" +
+			"0814" + "0003200028" + "010D486028001818100B465D9B3780",
+		func(t *testing.T) *cdma.Message {
+			ud := gsm7UD(t, "hello")
+			ud.UDH = tpdu.UserDataHeader{{ID: 0, Data: []byte{3, 2, 1}}}
+			return &cdma.Message{
+				Teleservice: cdma.TeleserviceEMS,
+				DA:          addr("6391"),
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Submit,
+					MessageID:   2,
+					UserData:    ud,
+				},
+			}
+		},
+	},
+	{
+		"ack",
+		"02" + "04040118E440" + "07021721",
+		func(t *testing.T) *cdma.Message {
+			return &cdma.Message{
+				Type: cdma.Acknowledge,
+				DA:   addr("6391"),
+				CauseCodes: &cdma.CauseCodes{
+					ReplySeq:   5,
+					ErrorClass: cdma.PermanentError,
+					Cause:      0x21,
+				},
+			}
+		},
+	},
+	{
+		"broadcast",
+		"01" + "00021002" + "01020102" +
+			"080D" + "0003100030" + "0106201270B3AA60",
+		func(t *testing.T) *cdma.Message {
+			return &cdma.Message{
+				Type:            cdma.Broadcast,
+				Teleservice:     cdma.TeleserviceMessaging,
+				ServiceCategory: 0x0102,
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Deliver,
+					MessageID:   3,
+					UserData:    cdma.NewUserData("世界"),
+				},
+			}
+		},
+	},
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.msg(t).MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, fixture.Hex(t, p.in), b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestMarshalBinaryError(t *testing.T) {
+	patterns := []struct {
+		name string
+		msg  cdma.Message
+		err  error
+	}{
+		{
+			"reserved",
+			cdma.Message{Type: 3},
+			tpdu.EncodeError("type", cdma.ErrUnsupportedMessageType(3)),
+		},
+		{
+			"invalid digit",
+			cdma.Message{DA: &cdma.Address{Digits: "12a"}},
+			tpdu.EncodeError("da", cdma.ErrInvalidDigit('a')),
+		},
+		{
+			"unsupported encoding",
+			cdma.Message{BearerData: &cdma.BearerData{
+				MessageType: cdma.Submit,
+				UserData:    &cdma.UserData{Encoding: cdma.EncodingIS91},
+			}},
+			tpdu.EncodeError("bearerdata",
+				tpdu.EncodeError("ud", cdma.ErrUnsupportedEncoding(cdma.EncodingIS91))),
+		},
+		{
+			"odd ucs2",
+			cdma.Message{BearerData: &cdma.BearerData{
+				MessageType: cdma.Submit,
+				UserData:    &cdma.UserData{Encoding: cdma.EncodingUnicode, Data: []byte{0}},
+			}},
+			tpdu.EncodeError("bearerdata", tpdu.EncodeError("ud", tpdu.ErrOddUCS2Length)),
+		},
+		{
+			"overlength ud",
+			cdma.Message{BearerData: &cdma.BearerData{
+				MessageType: cdma.Submit,
+				UserData:    &cdma.UserData{Encoding: cdma.EncodingOctet, Data: make([]byte, 256)},
+			}},
+			tpdu.EncodeError("bearerdata", tpdu.EncodeError("ud", tpdu.ErrOverlength)),
+		},
+		{
+			"invalid callback",
+			cdma.Message{BearerData: &cdma.BearerData{
+				MessageType:    cdma.Submit,
+				CallbackNumber: &cdma.Address{Digits: "+1"},
+			}},
+			tpdu.EncodeError("bearerdata", tpdu.EncodeError("callback", cdma.ErrInvalidDigit('+'))),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.msg.MarshalBinary()
+			assert.Equal(t, p.err, err)
+			assert.Nil(t, b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := cdma.Message{}
+			err := m.UnmarshalBinary(fixture.Hex(t, p.in))
+			require.Nil(t, err)
+			assert.Equal(t, p.msg(t), &m)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUnmarshalBinaryIgnored(t *testing.T) {
+	// subaddress and unknown subparameters
+	m := cdma.Message{}
+	err := m.UnmarshalBinary(fixture.Hex(t, "00"+"050100"+"0808"+"0003200010"+"7F0100"))
+	require.Nil(t, err)
+	assert.Equal(t, cdma.Message{
+		BearerData: &cdma.BearerData{MessageType: cdma.Submit, MessageID: 1},
+	}, m)
+}
+
+func TestUnmarshalBinaryError(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{
+			"empty",
+			"",
+			tpdu.NewDecodeError("type", 0, tpdu.ErrUnderflow),
+		},
+		{
+			"reserved",
+			"03",
+			tpdu.NewDecodeError("type", 0, cdma.ErrUnsupportedMessageType(3)),
+		},
+		{
+			"short parameter",
+			"0000",
+			tpdu.NewDecodeError("parameter", 1, tpdu.ErrUnderflow),
+		},
+		{
+			"overlength parameter",
+			"000002",
+			tpdu.NewDecodeError("parameter", 1, tpdu.ErrUnderflow),
+		},
+		{
+			"short teleservice",
+			"00000110",
+			tpdu.NewDecodeError("teleservice", 1, tpdu.ErrUnderflow),
+		},
+		{
+			"short category",
+			"0100021002010101",
+			tpdu.NewDecodeError("category", 5, tpdu.ErrUnderflow),
+		},
+		{
+			"short address",
+			"00040188",
+			tpdu.NewDecodeError("da", 1, tpdu.ErrUnderflow),
+		},
+		{
+			"invalid dtmf",
+			"0002020040",
+			tpdu.NewDecodeError("oa", 1, cdma.ErrInvalidDTMF(0)),
+		},
+		{
+			"short bearer reply",
+			"000600",
+			tpdu.NewDecodeError("bearerreply", 1, tpdu.ErrUnderflow),
+		},
+		{
+			"short cause codes",
+			"00070103",
+			tpdu.NewDecodeError("causecodes", 1, tpdu.ErrUnderflow),
+		},
+		{
+			"missing msgid",
+			"000800",
+			tpdu.NewDecodeError("bearerdata", 1,
+				tpdu.NewDecodeError("msgid", 0, tpdu.ErrMissing)),
+		},
+		{
+			"short msgid",
+			"0008020000",
+			tpdu.NewDecodeError("bearerdata", 1,
+				tpdu.NewDecodeError("msgid", 0, tpdu.ErrUnderflow)),
+		},
+		{
+			"short timestamp",
+			"0008080003200010030120",
+			tpdu.NewDecodeError("bearerdata", 1,
+				tpdu.NewDecodeError("timestamp", 5, tpdu.ErrUnderflow)),
+		},
+		{
+			"unsupported encoding",
+			"0008090003200010010208FF",
+			tpdu.NewDecodeError("bearerdata", 1,
+				tpdu.NewDecodeError("ud", 5, cdma.ErrUnsupportedEncoding(cdma.EncodingIS91))),
+		},
+		{
+			"short ud",
+			"000809000320001001021028",
+			tpdu.NewDecodeError("bearerdata", 1,
+				tpdu.NewDecodeError("ud", 5, tpdu.ErrUnderflow)),
+		},
+		{
+			"short priority",
+			"00080700032000100800",
+			tpdu.NewDecodeError("bearerdata", 1,
+				tpdu.NewDecodeError("priority", 5, tpdu.ErrUnderflow)),
+		},
+		{
+			"short reply option",
+			"00080700032000100A00",
+			tpdu.NewDecodeError("bearerdata", 1,
+				tpdu.NewDecodeError("replyoption", 5, tpdu.ErrUnderflow)),
+		},
+		{
+			"short callback",
+			"00080800032000100E0180",
+			tpdu.NewDecodeError("bearerdata", 1,
+				tpdu.NewDecodeError("callback", 5, tpdu.ErrUnderflow)),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := cdma.Message{}
+			err := m.UnmarshalBinary(fixture.Hex(t, p.in))
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, cdma.Message{}, m)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	patterns := []struct {
+		name   string
+		number string
+		addr   cdma.Address
+	}{
+		{"dtmf", "*12#0", cdma.Address{Digits: "*12#0"}},
+		{"international", "+6391", cdma.Address{
+			ASCII:  true,
+			Type:   cdma.NumberTypeInternational,
+			Plan:   cdma.NumberPlanISDN,
+			Digits: "6391",
+		}},
+		{"ascii", "12a", cdma.Address{ASCII: true, Plan: cdma.NumberPlanISDN, Digits: "12a"}},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			a := cdma.NewAddress(p.number)
+			assert.Equal(t, p.addr, a)
+			assert.Equal(t, p.number, a.Number())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUserData(t *testing.T) {
+	patterns := []struct {
+		name string
+		text string
+		enc  cdma.Encoding
+		data []byte
+		err  error
+	}{
+		{"ascii", "hi", cdma.EncodingASCII7, []byte("hi"), nil},
+		{"ia5", "hi", cdma.EncodingIA5, []byte("hi"), nil},
+		{"latin", "hé", cdma.EncodingLatin, []byte{'h', 0xe9}, nil},
+		{"unicode", "世", cdma.EncodingUnicode, []byte{0x4e, 0x16}, nil},
+		{"gsm7", "hi@", cdma.EncodingGSM7, []byte{'h', 'i', 0}, nil},
+		{"unencodable ascii", "hé", cdma.EncodingASCII7, nil, cdma.ErrUnencodable('é')},
+		{"unencodable latin", "h世", cdma.EncodingLatin, nil, cdma.ErrUnencodable('世')},
+		{"unsupported", "hi", cdma.EncodingShiftJIS, nil,
+			cdma.ErrUnsupportedEncoding(cdma.EncodingShiftJIS)},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			u, err := cdma.EncodeUserData(p.text, p.enc)
+			assert.Equal(t, p.err, err)
+			if err != nil {
+				assert.Nil(t, u)
+				return
+			}
+			assert.Equal(t, &cdma.UserData{Encoding: p.enc, Data: p.data}, u)
+			text, err := u.Text()
+			assert.Nil(t, err)
+			assert.Equal(t, p.text, text)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestNewUserData(t *testing.T) {
+	assert.Equal(t, cdma.EncodingASCII7, cdma.NewUserData("hi").Encoding)
+	assert.Equal(t, cdma.EncodingLatin, cdma.NewUserData("hé").Encoding)
+	assert.Equal(t, cdma.EncodingUnicode, cdma.NewUserData("h世").Encoding)
+}
+
+func TestUserDataGSMDCS(t *testing.T) {
+	u := cdma.UserData{
+		Encoding:    cdma.EncodingGSMDCS,
+		MessageType: 0x10,
+		Data:        []byte("hi"),
+	}
+	text, err := u.Text()
+	assert.Nil(t, err)
+	assert.Equal(t, "hi", text)
+
+	u.MessageType = 0x08
+	u.Data = []byte{0x4e, 0x16}
+	text, err = u.Text()
+	assert.Nil(t, err)
+	assert.Equal(t, "世", text)
+
+	u.Encoding = cdma.EncodingKorean
+	_, err = u.Text()
+	assert.Equal(t, cdma.ErrUnsupportedEncoding(cdma.EncodingKorean), err)
+}
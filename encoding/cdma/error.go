@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cdma
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedMessageType indicates the transport layer message type is
+// not supported.
+type ErrUnsupportedMessageType MessageType
+
+func (e ErrUnsupportedMessageType) Error() string {
+	return fmt.Sprintf("cdma: unsupported message type %d", int(e))
+}
+
+// ErrUnsupportedBearerMessageType indicates the bearer data message type
+// cannot be converted to a TPDU.
+type ErrUnsupportedBearerMessageType BearerMessageType
+
+func (e ErrUnsupportedBearerMessageType) Error() string {
+	return fmt.Sprintf("cdma: unsupported bearer message type %d", int(e))
+}
+
+// ErrUnsupportedEncoding indicates the user data encoding is not supported.
+type ErrUnsupportedEncoding Encoding
+
+func (e ErrUnsupportedEncoding) Error() string {
+	return fmt.Sprintf("cdma: unsupported encoding %d", int(e))
+}
+
+// ErrInvalidDigit indicates an address character cannot be encoded as DTMF.
+type ErrInvalidDigit rune
+
+func (e ErrInvalidDigit) Error() string {
+	return fmt.Sprintf("cdma: invalid DTMF digit '%c'", rune(e))
+}
+
+// ErrInvalidDTMF indicates a DTMF code does not correspond to a digit.
+type ErrInvalidDTMF byte
+
+func (e ErrInvalidDTMF) Error() string {
+	return fmt.Sprintf("cdma: invalid DTMF code %d", int(e))
+}
+
+// ErrUnencodable indicates a character cannot be encoded in the requested
+// encoding.
+type ErrUnencodable rune
+
+func (e ErrUnencodable) Error() string {
+	return fmt.Sprintf("cdma: unencodable character '%c'", rune(e))
+}
+
+var (
+	// ErrMissingBearerData indicates a message has no bearer data, so cannot
+	// be converted to a TPDU.
+	ErrMissingBearerData = errors.New("cdma: missing bearer data")
+
+	// ErrMissingAddress indicates a message has no originating or
+	// destination address, as required to convert it to a TPDU.
+	ErrMissingAddress = errors.New("cdma: missing address")
+
+	// ErrUnsupportedSmsType indicates the TPDU cannot be converted to a
+	// message.
+	ErrUnsupportedSmsType = errors.New("cdma: unsupported SMS type")
+)
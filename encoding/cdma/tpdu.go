@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cdma
+
+import (
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+// FromTPDU creates the point-to-point message corresponding to the TPDU.
+//
+// SMS-SUBMIT TPDUs are converted to Submit bearer data, and SMS-DELIVER TPDUs
+// to Deliver bearer data.  Other TPDU types are not supported.
+//
+// The id is the MESSAGE_ID assigned to the message, which is independent of
+// the TP-MR.
+//
+// GSM7, 8 bit and UCS2 user data with the basic DCS is converted to the
+// equivalent CDMA encoding.  Other DCS are carried as is using
+// EncodingGSMDCS.  Messages with a UDH use the WEMT teleservice, else the
+// CMT-95 teleservice.
+//
+// A TP-SRR on an SMS-SUBMIT is converted to a DeliveryAck reply option.
+func FromTPDU(t *tpdu.TPDU, id uint16) (*Message, error) {
+	m := Message{Type: PointToPoint, Teleservice: TeleserviceMessaging}
+	bd := BearerData{MessageID: id}
+	switch t.SmsType() {
+	case tpdu.SmsSubmit:
+		bd.MessageType = Submit
+		da := NewAddress(t.DA.Number())
+		m.DA = &da
+		if t.FirstOctet.SRR() {
+			bd.ReplyOption = &ReplyOption{DeliveryAck: true}
+		}
+	case tpdu.SmsDeliver:
+		bd.MessageType = Deliver
+		oa := NewAddress(t.OA.Number())
+		m.OA = &oa
+		if !t.SCTS.IsZero() {
+			bd.Timestamp = t.SCTS.UTC()
+		}
+	default:
+		return nil, ErrUnsupportedSmsType
+	}
+	ud := UserData{UDH: t.UDH}
+	switch t.DCS {
+	case 0x00:
+		ud.Encoding = EncodingGSM7
+	case tpdu.Dcs8BitData:
+		ud.Encoding = EncodingOctet
+	case tpdu.DcsUCS2Data:
+		ud.Encoding = EncodingUnicode
+	default:
+		if _, err := t.Alphabet(); err != nil {
+			return nil, err
+		}
+		ud.Encoding = EncodingGSMDCS
+		ud.MessageType = byte(t.DCS)
+	}
+	if len(t.UD) > 0 {
+		ud.Data = append([]byte(nil), t.UD...)
+	}
+	if len(ud.UDH) > 0 {
+		m.Teleservice = TeleserviceEMS
+	}
+	bd.UserData = &ud
+	m.BearerData = &bd
+	return &m, nil
+}
+
+// TPDU creates the TPDU corresponding to a point-to-point message.
+//
+// This is the reverse of FromTPDU.
+// Submit bearer data is converted to an SMS-SUBMIT, with the TP-MR taken from
+// the lower 8 bits of the MESSAGE_ID, and Deliver bearer data to an
+// SMS-DELIVER.
+// User data with ASCII, IA5 or Latin encoding is converted to GSM7 if
+// possible, else UCS2.
+func (m *Message) TPDU() (*tpdu.TPDU, error) {
+	if m.Type != PointToPoint {
+		return nil, ErrUnsupportedMessageType(m.Type)
+	}
+	bd := m.BearerData
+	if bd == nil {
+		return nil, ErrMissingBearerData
+	}
+	var t *tpdu.TPDU
+	switch bd.MessageType {
+	case Submit:
+		if m.DA == nil {
+			return nil, ErrMissingAddress
+		}
+		t, _ = tpdu.NewSubmit()
+		t.DA = tpduAddress(*m.DA)
+		t.MR = byte(bd.MessageID)
+		if bd.ReplyOption != nil && bd.ReplyOption.DeliveryAck {
+			t.FirstOctet |= tpdu.FoSRR
+		}
+	case Deliver:
+		if m.OA == nil {
+			return nil, ErrMissingAddress
+		}
+		t, _ = tpdu.NewDeliver()
+		t.OA = tpduAddress(*m.OA)
+		t.SCTS = tpdu.Timestamp{Time: bd.Timestamp}
+	default:
+		return nil, ErrUnsupportedBearerMessageType(bd.MessageType)
+	}
+	ud := bd.UserData
+	if ud == nil {
+		return t, nil
+	}
+	var d []byte
+	switch ud.Encoding {
+	case EncodingGSM7:
+		d = ud.Data
+	case EncodingOctet:
+		t.DCS = tpdu.Dcs8BitData
+		d = ud.Data
+	case EncodingUnicode:
+		if len(ud.Data)&0x01 == 0x01 {
+			return nil, tpdu.NewDecodeError("ud", 0, tpdu.ErrOddUCS2Length)
+		}
+		t.DCS = tpdu.DcsUCS2Data
+		d = ud.Data
+	case EncodingASCII7, EncodingIA5, EncodingLatin:
+		t.DCS, d = textToUD(ud.Data)
+	case EncodingGSMDCS:
+		t.DCS = tpdu.DCS(ud.MessageType)
+		d = ud.Data
+	default:
+		return nil, ErrUnsupportedEncoding(ud.Encoding)
+	}
+	if len(ud.UDH) > 0 {
+		t.SetUDH(ud.UDH)
+	}
+	if len(d) > 0 {
+		t.UD = append([]byte(nil), d...)
+	}
+	return t, nil
+}
+
+// tpduAddress returns the TPDU Address corresponding to the CDMA Address.
+func tpduAddress(a Address) tpdu.Address {
+	ta := tpdu.NewAddress()
+	if !a.DataNetwork && a.Type == NumberTypeInternational {
+		ta.SetNumber(a.Digits)
+		return ta
+	}
+	ta.Addr = a.Digits
+	return ta
+}
+
+// textToUD converts ASCII, IA5 or Latin text into GSM7 septets, or UCS2 if
+// that is not possible.
+func textToUD(text []byte) (tpdu.DCS, []byte) {
+	r := make([]rune, len(text))
+	for i, c := range text {
+		// ASCII and IA5 are subsets of Latin1, which maps directly to Unicode.
+		r[i] = rune(c)
+	}
+	ud, err := gsm7.Encode([]byte(string(r)))
+	if err == nil {
+		return 0x00, ud
+	}
+	return tpdu.DcsUCS2Data, ucs2.Encode(r)
+}
@@ -0,0 +1,307 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cdma_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/cdma"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+const (
+	// SMS-SUBMIT to 6391 containing "hello".
+	submitTPDU = "0142049136190000" + "05E8329BFD06"
+
+	// SMS-DELIVER from 6391 containing "Hahahaha".
+	deliverTPDU = "040491361900005150713220052308C8303A8C0EA3C3"
+)
+
+func TestFromTPDU(t *testing.T) {
+	submit, _ := tpdu.NewSubmit(tpdu.WithDA(tpdu.NewAddress(tpdu.FromNumber("6391"))))
+	submit.FirstOctet |= tpdu.FoSRR
+	submit.MR = 0x42
+	submit.UD = []byte("hello")
+	deliver, _ := tpdu.NewDeliver(tpdu.WithOA(tpdu.NewAddress(tpdu.FromNumber("6391"))))
+	deliver.SCTS = tpdu.Timestamp{Time: time.Date(2020, 5, 17, 23, 2, 50, 0, time.UTC)}
+	deliver.DCS = tpdu.DcsUCS2Data
+	deliver.SetUDH(tpdu.UserDataHeader{{ID: 0, Data: []byte{1, 2, 1}}})
+	deliver.UD = []byte{0, 'h'}
+	octet, _ := tpdu.NewSubmit(tpdu.WithDA(tpdu.NewAddress(tpdu.FromNumber("6391"))))
+	octet.DCS = tpdu.Dcs8BitData
+	octet.UD = []byte{1, 2, 3}
+	class, _ := tpdu.NewSubmit(tpdu.WithDA(tpdu.NewAddress(tpdu.FromNumber("6391"))))
+	class.DCS = 0x10
+	class.UD = []byte("hi")
+	patterns := []struct {
+		name string
+		in   *tpdu.TPDU
+		id   uint16
+		out  *cdma.Message
+		err  error
+	}{
+		{
+			"submit",
+			submit,
+			0x42,
+			&cdma.Message{
+				Teleservice: cdma.TeleserviceMessaging,
+				DA:          addr("+6391"),
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Submit,
+					MessageID:   0x42,
+					UserData:    &cdma.UserData{Encoding: cdma.EncodingGSM7, Data: []byte("hello")},
+					ReplyOption: &cdma.ReplyOption{DeliveryAck: true},
+				},
+			},
+			nil,
+		},
+		{
+			"deliver",
+			deliver,
+			0x1234,
+			&cdma.Message{
+				Teleservice: cdma.TeleserviceEMS,
+				OA:          addr("+6391"),
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Deliver,
+					MessageID:   0x1234,
+					UserData: &cdma.UserData{
+						Encoding: cdma.EncodingUnicode,
+						UDH:      tpdu.UserDataHeader{{ID: 0, Data: []byte{1, 2, 1}}},
+						Data:     []byte{0, 'h'},
+					},
+					Timestamp: time.Date(2020, 5, 17, 23, 2, 50, 0, time.UTC),
+				},
+			},
+			nil,
+		},
+		{
+			"octet",
+			octet,
+			0,
+			&cdma.Message{
+				Teleservice: cdma.TeleserviceMessaging,
+				DA:          addr("+6391"),
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Submit,
+					UserData:    &cdma.UserData{Encoding: cdma.EncodingOctet, Data: []byte{1, 2, 3}},
+				},
+			},
+			nil,
+		},
+		{
+			"gsm dcs",
+			class,
+			0,
+			&cdma.Message{
+				Teleservice: cdma.TeleserviceMessaging,
+				DA:          addr("+6391"),
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Submit,
+					UserData: &cdma.UserData{
+						Encoding:    cdma.EncodingGSMDCS,
+						MessageType: 0x10,
+						Data:        []byte("hi"),
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"status report",
+			&tpdu.TPDU{Direction: tpdu.MT, FirstOctet: 0x02},
+			0,
+			nil,
+			cdma.ErrUnsupportedSmsType,
+		},
+		{
+			"bad dcs",
+			&tpdu.TPDU{Direction: tpdu.MT, DCS: 0x80},
+			0,
+			nil,
+			tpdu.ErrInvalid,
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m, err := cdma.FromTPDU(p.in, p.id)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, m)
+			if err != nil {
+				return
+			}
+			rt, err := m.TPDU()
+			require.Nil(t, err)
+			if p.in.SmsType() == tpdu.SmsSubmit {
+				// the TP-MR is the lower octet of the MESSAGE_ID
+				p.in.MR = byte(p.id)
+			}
+			assert.Equal(t, p.in, rt)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestFromTPDUBinary(t *testing.T) {
+	patterns := []struct {
+		name  string
+		in    string
+		dir   tpdu.Direction
+		check func(t *testing.T, m *cdma.Message)
+	}{
+		{
+			"submit",
+			submitTPDU,
+			tpdu.MO,
+			func(t *testing.T, m *cdma.Message) {
+				assert.Equal(t, "+6391", m.DA.Number())
+				text, err := m.BearerData.UserData.Text()
+				assert.Nil(t, err)
+				assert.Equal(t, "hello", text)
+			},
+		},
+		{
+			"deliver",
+			deliverTPDU,
+			tpdu.MT,
+			func(t *testing.T, m *cdma.Message) {
+				assert.Equal(t, "+6391", m.OA.Number())
+				text, err := m.BearerData.UserData.Text()
+				assert.Nil(t, err)
+				assert.Equal(t, "Hahahaha", text)
+				// the time zone is dropped
+				assert.Equal(t, time.UTC, m.BearerData.Timestamp.Location())
+				assert.Equal(t, time.Date(2015, 5, 17, 15, 2, 50, 0, time.UTC),
+					m.BearerData.Timestamp)
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			s := tpdu.TPDU{Direction: p.dir}
+			err := s.UnmarshalBinary(fixture.Hex(t, p.in))
+			require.Nil(t, err)
+			m, err := cdma.FromTPDU(&s, 1)
+			require.Nil(t, err)
+			p.check(t, m)
+			// and over the air
+			b, err := m.MarshalBinary()
+			require.Nil(t, err)
+			rm := cdma.Message{}
+			err = rm.UnmarshalBinary(b)
+			require.Nil(t, err)
+			assert.Equal(t, m, &rm)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestTPDU(t *testing.T) {
+	ascii, _ := tpdu.NewDeliver(tpdu.WithOA(tpdu.NewAddress()))
+	ascii.OA.Addr = "6391"
+	ascii.UD = []byte("hello")
+	latin, _ := tpdu.NewDeliver(tpdu.WithOA(tpdu.NewAddress()))
+	latin.OA.Addr = "6391"
+	latin.DCS = tpdu.DcsUCS2Data
+	latin.UD = []byte{0, 'h', 0, 0xf4}
+	patterns := []struct {
+		name string
+		in   cdma.Message
+		out  *tpdu.TPDU
+		err  error
+	}{
+		{
+			"ascii",
+			cdma.Message{
+				OA: addr("6391"),
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Deliver,
+					UserData:    cdma.NewUserData("hello"),
+				},
+			},
+			ascii,
+			nil,
+		},
+		{
+			"latin",
+			cdma.Message{
+				OA: addr("6391"),
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Deliver,
+					UserData:    cdma.NewUserData("hô"),
+				},
+			},
+			latin,
+			nil,
+		},
+		{
+			"broadcast",
+			cdma.Message{Type: cdma.Broadcast},
+			nil,
+			cdma.ErrUnsupportedMessageType(cdma.Broadcast),
+		},
+		{
+			"missing bearer data",
+			cdma.Message{DA: addr("6391")},
+			nil,
+			cdma.ErrMissingBearerData,
+		},
+		{
+			"missing da",
+			cdma.Message{BearerData: &cdma.BearerData{MessageType: cdma.Submit}},
+			nil,
+			cdma.ErrMissingAddress,
+		},
+		{
+			"missing oa",
+			cdma.Message{BearerData: &cdma.BearerData{MessageType: cdma.Deliver}},
+			nil,
+			cdma.ErrMissingAddress,
+		},
+		{
+			"delivery ack",
+			cdma.Message{BearerData: &cdma.BearerData{MessageType: cdma.DeliveryAck}},
+			nil,
+			cdma.ErrUnsupportedBearerMessageType(cdma.DeliveryAck),
+		},
+		{
+			"odd ucs2",
+			cdma.Message{
+				DA: addr("6391"),
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Submit,
+					UserData:    &cdma.UserData{Encoding: cdma.EncodingUnicode, Data: []byte{0}},
+				},
+			},
+			nil,
+			tpdu.NewDecodeError("ud", 0, tpdu.ErrOddUCS2Length),
+		},
+		{
+			"unsupported encoding",
+			cdma.Message{
+				DA: addr("6391"),
+				BearerData: &cdma.BearerData{
+					MessageType: cdma.Submit,
+					UserData:    &cdma.UserData{Encoding: cdma.EncodingKorean},
+				},
+			},
+			nil,
+			cdma.ErrUnsupportedEncoding(cdma.EncodingKorean),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			s, err := p.in.TPDU()
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, s)
+		}
+		t.Run(p.name, f)
+	}
+}
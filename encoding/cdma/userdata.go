@@ -0,0 +1,296 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cdma
+
+import (
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+// Encoding is the MSG_ENCODING of the user data, as defined in C.R1001
+// Table 9.1-1.
+type Encoding byte
+
+const (
+	// EncodingOctet indicates the user data is unspecified octets.
+	EncodingOctet Encoding = iota
+
+	// EncodingIS91 indicates the user data is an IS-91 extended protocol
+	// message.
+	EncodingIS91
+
+	// EncodingASCII7 indicates the user data is 7 bit ASCII.
+	EncodingASCII7
+
+	// EncodingIA5 indicates the user data is 7 bit IA5.
+	EncodingIA5
+
+	// EncodingUnicode indicates the user data is 16 bit UCS-2.
+	EncodingUnicode
+
+	// EncodingShiftJIS indicates the user data is Shift-JIS octets.
+	EncodingShiftJIS
+
+	// EncodingKorean indicates the user data is KS X 1001 octets.
+	EncodingKorean
+
+	// EncodingLatinHebrew indicates the user data is ISO-8859-8.
+	EncodingLatinHebrew
+
+	// EncodingLatin indicates the user data is ISO-8859-1.
+	EncodingLatin
+
+	// EncodingGSM7 indicates the user data is GSM 7 bit default alphabet
+	// septets.
+	EncodingGSM7
+
+	// EncodingGSMDCS indicates the user data is encoded as per the GSM DCS
+	// carried in the MESSAGE_TYPE.
+	EncodingGSMDCS
+)
+
+// UserData is the User Data subparameter of the bearer data, as defined in
+// C.S0015 Section 4.5.2.
+type UserData struct {
+	Encoding Encoding
+
+	// MessageType is the MESSAGE_TYPE, which only applies to the
+	// EncodingGSMDCS, for which it contains the GSM DCS.
+	MessageType byte
+
+	// UDH is the optional user data header, which is indicated by the
+	// HEADER_IND of the message identifier.
+	UDH tpdu.UserDataHeader
+
+	// Data contains the characters of the message, excluding the UDH.
+	//
+	// For 7 bit encodings each character is stored in the lower 7 bits of a
+	// byte. For EncodingUnicode each character is a big endian pair of
+	// bytes. For the remaining encodings each character is a byte.
+	Data []byte
+}
+
+// NewUserData creates the user data for the text using the most compact of
+// EncodingASCII7, EncodingLatin and EncodingUnicode that can encode the text.
+func NewUserData(text string) *UserData {
+	e := EncodingASCII7
+	for _, r := range text {
+		if r > 0xff {
+			e = EncodingUnicode
+			break
+		}
+		if r > 0x7f {
+			e = EncodingLatin
+		}
+	}
+	u, _ := EncodeUserData(text, e)
+	return u
+}
+
+// EncodeUserData creates the user data for the text using the encoding.
+//
+// Only EncodingASCII7, EncodingIA5, EncodingLatin, EncodingUnicode and
+// EncodingGSM7 are supported.
+func EncodeUserData(text string, e Encoding) (*UserData, error) {
+	u := UserData{Encoding: e}
+	switch e {
+	case EncodingASCII7, EncodingIA5, EncodingLatin:
+		max := rune(0x7f)
+		if e == EncodingLatin {
+			max = 0xff
+		}
+		for _, r := range text {
+			if r > max {
+				return nil, ErrUnencodable(r)
+			}
+			u.Data = append(u.Data, byte(r))
+		}
+	case EncodingUnicode:
+		u.Data = ucs2.Encode([]rune(text))
+	case EncodingGSM7:
+		d, err := gsm7.Encode([]byte(text))
+		if err != nil {
+			return nil, err
+		}
+		u.Data = d
+	default:
+		return nil, ErrUnsupportedEncoding(e)
+	}
+	return &u, nil
+}
+
+// Text returns the user data decoded to UTF-8.
+//
+// The UDH is ignored, other than when determining the GSM character set for
+// EncodingGSMDCS.
+func (u *UserData) Text() (string, error) {
+	switch u.Encoding {
+	case EncodingASCII7, EncodingIA5, EncodingLatin:
+		r := make([]rune, len(u.Data))
+		for i, c := range u.Data {
+			r[i] = rune(c)
+		}
+		return string(r), nil
+	case EncodingUnicode:
+		r, err := ucs2.Decode(u.Data)
+		if err != nil {
+			return "", err
+		}
+		return string(r), nil
+	case EncodingGSM7:
+		d, err := gsm7.Decode(u.Data)
+		if err != nil {
+			return "", err
+		}
+		return string(d), nil
+	case EncodingGSMDCS:
+		alpha, err := tpdu.DCS(u.MessageType).Alphabet()
+		if err != nil {
+			return "", err
+		}
+		d, err := tpdu.DecodeUserData(u.Data, u.UDH, alpha)
+		if err != nil {
+			return "", err
+		}
+		return string(d), nil
+	default:
+		return "", ErrUnsupportedEncoding(u.Encoding)
+	}
+}
+
+// bits returns the size of the characters, in bits.
+func (u *UserData) bits() (int, error) {
+	switch u.Encoding {
+	case EncodingIS91:
+		return 0, ErrUnsupportedEncoding(u.Encoding)
+	case EncodingASCII7, EncodingIA5, EncodingGSM7:
+		return 7, nil
+	case EncodingUnicode:
+		return 16, nil
+	case EncodingGSMDCS:
+		alpha, err := tpdu.DCS(u.MessageType).Alphabet()
+		if err != nil {
+			return 0, err
+		}
+		switch alpha {
+		case tpdu.Alpha7Bit:
+			return 7, nil
+		case tpdu.AlphaUCS2:
+			return 16, nil
+		}
+	}
+	return 8, nil
+}
+
+// headerFields returns the number of fields occupied by a UDH of length l,
+// including the UDHL, for characters of the given size.
+func headerFields(l, bits int) int {
+	return (l*8 + bits - 1) / bits
+}
+
+// marshal encodes the subparameter data.
+func (u *UserData) marshal() ([]byte, error) {
+	bits, err := u.bits()
+	if err != nil {
+		return nil, err
+	}
+	if bits == 16 && len(u.Data)%2 != 0 {
+		return nil, tpdu.ErrOddUCS2Length
+	}
+	udh, err := u.UDH.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	hf := headerFields(len(udh), bits)
+	nf := hf + len(u.Data)
+	if bits == 16 {
+		nf = hf + len(u.Data)/2
+	}
+	if nf > 0xff {
+		return nil, tpdu.ErrOverlength
+	}
+	w := bitWriter{}
+	w.write(uint32(u.Encoding), 5)
+	if u.Encoding == EncodingGSMDCS {
+		w.write(uint32(u.MessageType), 8)
+	}
+	w.write(uint32(nf), 8)
+	w.writeBytes(udh)
+	w.write(0, hf*bits-len(udh)*8)
+	if bits == 7 {
+		for _, c := range u.Data {
+			w.write(uint32(c), 7)
+		}
+	} else {
+		w.writeBytes(u.Data)
+	}
+	return w.bytes(), nil
+}
+
+// unmarshal decodes the subparameter data, with hasUDH indicating the
+// HEADER_IND of the message identifier.
+func (u *UserData) unmarshal(b []byte, hasUDH bool) error {
+	r := bitReader{b: b}
+	v, err := r.read(5)
+	if err != nil {
+		return err
+	}
+	n := UserData{Encoding: Encoding(v)}
+	if n.Encoding == EncodingGSMDCS {
+		if v, err = r.read(8); err != nil {
+			return err
+		}
+		n.MessageType = byte(v)
+	}
+	bits, err := n.bits()
+	if err != nil {
+		return err
+	}
+	nf, err := r.read(8)
+	if err != nil {
+		return err
+	}
+	fields := int(nf)
+	if hasUDH {
+		udhl, err := r.read(8)
+		if err != nil {
+			return err
+		}
+		ub, err := r.readBytes(int(udhl))
+		if err != nil {
+			return err
+		}
+		udh := append([]byte{byte(udhl)}, ub...)
+		if _, err = n.UDH.UnmarshalBinary(udh); err != nil {
+			return err
+		}
+		hf := headerFields(len(udh), bits)
+		if hf > fields {
+			return tpdu.ErrUnderflow
+		}
+		if _, err = r.read(hf*bits - len(udh)*8); err != nil {
+			return err
+		}
+		fields -= hf
+	}
+	switch {
+	case fields == 0:
+	case bits == 7:
+		n.Data = make([]byte, fields)
+		for i := range n.Data {
+			if v, err = r.read(7); err != nil {
+				return err
+			}
+			n.Data[i] = byte(v)
+		}
+	default:
+		if n.Data, err = r.readBytes(fields * bits / 8); err != nil {
+			return err
+		}
+	}
+	*u = n
+	return nil
+}
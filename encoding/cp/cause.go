@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cp
+
+import "strconv"
+
+// Cause is the CP-Cause value of a CP-ERROR, as defined in 3GPP TS 24.011
+// Section 8.1.4.2.
+type Cause byte
+
+const (
+	// CauseNetworkFailure indicates the message could not be delivered
+	// because of a failure in the network.
+	CauseNetworkFailure Cause = 17
+
+	// CauseCongestion indicates the message could not be accepted because
+	// of congestion.
+	CauseCongestion Cause = 22
+
+	// CauseInvalidTI indicates the message has a transaction identifier
+	// that is not currently in use.
+	CauseInvalidTI Cause = 81
+
+	// CauseSemanticallyIncorrect indicates the message is semantically
+	// incorrect.
+	CauseSemanticallyIncorrect Cause = 95
+
+	// CauseInvalidMandatoryInformation indicates the message is missing
+	// mandatory information, or it is invalid.
+	CauseInvalidMandatoryInformation Cause = 96
+
+	// CauseMessageTypeNonExistent indicates the message type is not defined
+	// or not implemented.
+	CauseMessageTypeNonExistent Cause = 97
+
+	// CauseMessageNotCompatible indicates the message is not compatible with
+	// the protocol state.
+	CauseMessageNotCompatible Cause = 98
+
+	// CauseIENonExistent indicates the message contains an information
+	// element that is not defined or not implemented.
+	CauseIENonExistent Cause = 99
+
+	// CauseProtocolError indicates an unspecified protocol error.
+	CauseProtocolError Cause = 111
+)
+
+var causeNames = map[Cause]string{
+	CauseNetworkFailure:              "network failure",
+	CauseCongestion:                  "congestion",
+	CauseInvalidTI:                   "invalid transaction identifier value",
+	CauseSemanticallyIncorrect:       "semantically incorrect message",
+	CauseInvalidMandatoryInformation: "invalid mandatory information",
+	CauseMessageTypeNonExistent:      "message type non-existent or not implemented",
+	CauseMessageNotCompatible:        "message not compatible with the short message protocol state",
+	CauseIENonExistent:               "information element non-existent or not implemented",
+	CauseProtocolError:               "protocol error, unspecified",
+}
+
+func (c Cause) String() string {
+	if n, ok := causeNames[c]; ok {
+		return n
+	}
+	return strconv.Itoa(int(c))
+}
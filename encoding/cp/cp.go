@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package cp provides encoding and decoding of the Connection Protocol
+// messages that carry RP messages between the MS and the network, as defined
+// in 3GPP TS 24.011 Section 7.2.
+package cp
+
+import (
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// MessageType is the CP message type, as defined in 3GPP TS 24.011 Section
+// 8.1.3.
+type MessageType byte
+
+const (
+	// Data is a CP-DATA, carrying an RP message.
+	Data MessageType = 0x01
+
+	// Ack is a CP-ACK, acknowledging a CP-DATA.
+	Ack MessageType = 0x04
+
+	// Error is a CP-ERROR, indicating an error at the CP layer.
+	Error MessageType = 0x10
+)
+
+var typeNames = map[MessageType]string{
+	Data:  "CP-DATA",
+	Ack:   "CP-ACK",
+	Error: "CP-ERROR",
+}
+
+func (t MessageType) String() string {
+	if n, ok := typeNames[t]; ok {
+		return n
+	}
+	return "CP-Reserved"
+}
+
+// ProtocolDiscriminator is the protocol discriminator for SMS messages, as
+// defined in 3GPP TS 24.007 Section 11.2.3.1.1.
+const ProtocolDiscriminator = 0x09
+
+// maxUDLen is the maximum length of the CP-User-Data.
+const maxUDLen = 248
+
+// Message is a CP message.
+//
+// The fields populated depend on the message Type.
+type Message struct {
+	// TI is the transaction identifier value, in the range 0-6.
+	TI byte
+
+	// TIFlag is set in messages sent by the side that did not originate
+	// the transaction.
+	TIFlag bool
+
+	Type MessageType
+
+	// Cause is the CP-Cause of a CP-ERROR.
+	Cause Cause
+
+	// UD is the RP message carried in the CP-User-Data of a CP-DATA.
+	UD *rp.Message
+}
+
+// MarshalBinary encodes the message into its binary form.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if m.TI > 6 {
+		return nil, tpdu.EncodeError("ti", ErrInvalidTI(m.TI))
+	}
+	b := []byte{m.TI<<4 | ProtocolDiscriminator, byte(m.Type)}
+	if m.TIFlag {
+		b[0] |= 0x80
+	}
+	switch m.Type {
+	case Data:
+		if m.UD == nil {
+			return nil, tpdu.EncodeError("ud", ErrMissingUserData)
+		}
+		ud, err := m.UD.MarshalBinary()
+		if err != nil {
+			return nil, tpdu.EncodeError("ud", err)
+		}
+		if len(ud) > maxUDLen {
+			return nil, tpdu.EncodeError("ud", tpdu.ErrOverlength)
+		}
+		b = append(b, byte(len(ud)))
+		b = append(b, ud...)
+	case Ack:
+	case Error:
+		b = append(b, byte(m.Cause))
+	default:
+		return nil, tpdu.EncodeError("type", ErrUnsupportedMessageType(m.Type))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the message from its binary form.
+func (m *Message) UnmarshalBinary(b []byte) error {
+	if len(b) < 2 {
+		return tpdu.NewDecodeError("type", len(b), tpdu.ErrUnderflow)
+	}
+	if b[0]&0x0f != ProtocolDiscriminator {
+		return tpdu.NewDecodeError("pd", 0, ErrInvalidProtocolDiscriminator(b[0]&0x0f))
+	}
+	n := Message{
+		TI:     (b[0] >> 4) & 0x07,
+		TIFlag: b[0]&0x80 != 0,
+		Type:   MessageType(b[1]),
+	}
+	if n.TI == 7 {
+		return tpdu.NewDecodeError("ti", 0, ErrInvalidTI(n.TI))
+	}
+	ri := 2
+	switch n.Type {
+	case Data:
+		if ri >= len(b) {
+			return tpdu.NewDecodeError("ud", ri, tpdu.ErrUnderflow)
+		}
+		l := int(b[ri])
+		ri++
+		if len(b) < ri+l {
+			return tpdu.NewDecodeError("ud", ri, tpdu.ErrUnderflow)
+		}
+		var ud rp.Message
+		if err := ud.UnmarshalBinary(b[ri : ri+l]); err != nil {
+			return tpdu.NewDecodeError("ud", ri, err)
+		}
+		n.UD = &ud
+		ri += l
+	case Ack:
+	case Error:
+		if ri >= len(b) {
+			return tpdu.NewDecodeError("cause", ri, tpdu.ErrUnderflow)
+		}
+		n.Cause = Cause(b[ri])
+		ri++
+	default:
+		return tpdu.NewDecodeError("type", 1, ErrUnsupportedMessageType(n.Type))
+	}
+	if ri != len(b) {
+		return tpdu.NewDecodeError("ud", ri, tpdu.ErrOverlength)
+	}
+	*m = n
+	return nil
+}
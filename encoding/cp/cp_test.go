@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cp_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/cp"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+const (
+	// SMS-SUBMIT to 6391 containing "hello".
+	submitTPDU = "0142049136190000" + "05E8329BFD06"
+
+	// RP-DATA (MS->N) carrying the SMS-SUBMIT.
+	rpData = "002A000791361907002039" + "0E" + submitTPDU
+)
+
+var smsc = pdumode.SMSCAddress{
+	Address: tpdu.Address{Addr: "639170000293", TOA: 0x91},
+}
+
+func rpDataMsg(t *testing.T) *rp.Message {
+	t.Helper()
+	s := tpdu.TPDU{Direction: tpdu.MO}
+	err := s.UnmarshalBinary(fixture.Hex(t, submitTPDU))
+	require.Nil(t, err)
+	return &rp.Message{Type: rp.DataMSToN, MR: 0x2a, DA: smsc, UD: &s}
+}
+
+type testPattern struct {
+	name string
+	in   string
+	msg  func(t *testing.T) *cp.Message
+}
+
+var patterns = []testPattern{
+	{
+		"data",
+		"09011A" + rpData,
+		func(t *testing.T) *cp.Message {
+			return &cp.Message{Type: cp.Data, UD: rpDataMsg(t)}
+		},
+	},
+	{
+		"ack",
+		"A904",
+		func(t *testing.T) *cp.Message {
+			return &cp.Message{TI: 2, TIFlag: true, Type: cp.Ack}
+		},
+	},
+	{
+		"error",
+		"691011",
+		func(t *testing.T) *cp.Message {
+			return &cp.Message{TI: 6, Type: cp.Error, Cause: cp.CauseNetworkFailure}
+		},
+	},
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.msg(t).MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, fixture.Hex(t, p.in), b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestMarshalBinaryError(t *testing.T) {
+	patterns := []struct {
+		name string
+		msg  cp.Message
+		err  error
+	}{
+		{
+			"invalid ti",
+			cp.Message{TI: 7, Type: cp.Ack},
+			tpdu.EncodeError("ti", cp.ErrInvalidTI(7)),
+		},
+		{
+			"reserved",
+			cp.Message{Type: 2},
+			tpdu.EncodeError("type", cp.ErrUnsupportedMessageType(2)),
+		},
+		{
+			"missing ud",
+			cp.Message{Type: cp.Data},
+			tpdu.EncodeError("ud", cp.ErrMissingUserData),
+		},
+		{
+			"bad ud",
+			cp.Message{Type: cp.Data, UD: &rp.Message{Type: 7}},
+			tpdu.EncodeError("ud", tpdu.EncodeError("type", rp.ErrUnsupportedMessageType(7))),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.msg.MarshalBinary()
+			assert.Equal(t, p.err, err)
+			assert.Nil(t, b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := cp.Message{}
+			err := m.UnmarshalBinary(fixture.Hex(t, p.in))
+			require.Nil(t, err)
+			assert.Equal(t, p.msg(t), &m)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUnmarshalBinaryError(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{
+			"empty",
+			"",
+			tpdu.NewDecodeError("type", 0, tpdu.ErrUnderflow),
+		},
+		{
+			"invalid pd",
+			"0304",
+			tpdu.NewDecodeError("pd", 0, cp.ErrInvalidProtocolDiscriminator(3)),
+		},
+		{
+			"invalid ti",
+			"7904",
+			tpdu.NewDecodeError("ti", 0, cp.ErrInvalidTI(7)),
+		},
+		{
+			"reserved",
+			"0902",
+			tpdu.NewDecodeError("type", 1, cp.ErrUnsupportedMessageType(2)),
+		},
+		{
+			"missing ud",
+			"0901",
+			tpdu.NewDecodeError("ud", 2, tpdu.ErrUnderflow),
+		},
+		{
+			"short ud",
+			"09010402",
+			tpdu.NewDecodeError("ud", 3, tpdu.ErrUnderflow),
+		},
+		{
+			"bad ud",
+			"0901020700",
+			tpdu.NewDecodeError("ud", 3,
+				tpdu.NewDecodeError("type", 0, rp.ErrUnsupportedMessageType(7))),
+		},
+		{
+			"missing cause",
+			"0910",
+			tpdu.NewDecodeError("cause", 2, tpdu.ErrUnderflow),
+		},
+		{
+			"overlength",
+			"090400",
+			tpdu.NewDecodeError("ud", 2, tpdu.ErrOverlength),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := cp.Message{}
+			err := m.UnmarshalBinary(fixture.Hex(t, p.in))
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, cp.Message{}, m)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestCause(t *testing.T) {
+	assert.Equal(t, "congestion", cp.CauseCongestion.String())
+	assert.Equal(t, "3", cp.Cause(3).String())
+}
+
+func TestMessageTypeString(t *testing.T) {
+	assert.Equal(t, "CP-DATA", cp.Data.String())
+	assert.Equal(t, "CP-ACK", cp.Ack.String())
+	assert.Equal(t, "CP-ERROR", cp.Error.String())
+	assert.Equal(t, "CP-Reserved", cp.MessageType(2).String())
+}
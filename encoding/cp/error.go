@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package cp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidProtocolDiscriminator indicates the message does not have the
+// SMS protocol discriminator.
+type ErrInvalidProtocolDiscriminator byte
+
+func (e ErrInvalidProtocolDiscriminator) Error() string {
+	return fmt.Sprintf("cp: invalid protocol discriminator %d", int(e))
+}
+
+// ErrInvalidTI indicates the transaction identifier value is out of range.
+type ErrInvalidTI byte
+
+func (e ErrInvalidTI) Error() string {
+	return fmt.Sprintf("cp: invalid transaction identifier %d", int(e))
+}
+
+// ErrUnsupportedMessageType indicates the message type is not a CP message
+// type.
+type ErrUnsupportedMessageType MessageType
+
+func (e ErrUnsupportedMessageType) Error() string {
+	return fmt.Sprintf("cp: unsupported message type 0x%02x", int(e))
+}
+
+// ErrMissingUserData indicates a CP-DATA has no CP-User-Data.
+var ErrMissingUserData = errors.New("cp: missing user data")
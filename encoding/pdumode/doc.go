@@ -4,4 +4,7 @@
 
 // Package pdumode provides functions to encode and decode PDU mode frames
 // exchanged with a GSM modem in PDU mode.
+//
+// It also provides parsers for the 3GPP TS 27.005 response lines that carry
+// those PDUs, such as +CMGL, +CMGR, +CMT and +CDS.
 package pdumode
@@ -0,0 +1,29 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package pdumode
+
+import "fmt"
+
+// ErrInvalidHeader indicates a response line could not be parsed.
+type ErrInvalidHeader string
+
+func (e ErrInvalidHeader) Error() string {
+	return fmt.Sprintf("pdumode: invalid header: %q", string(e))
+}
+
+// ErrLengthMismatch indicates the <length> in a response header does not
+// match the length of the TPDU that follows it.
+type ErrLengthMismatch struct {
+	// Header is the length indicated in the header.
+	Header int
+
+	// TPDU is the length of the TPDU.
+	TPDU int
+}
+
+func (e ErrLengthMismatch) Error() string {
+	return fmt.Sprintf("pdumode: header length %d does not match TPDU length %d",
+		e.Header, e.TPDU)
+}
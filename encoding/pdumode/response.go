@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package pdumode
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Stat is the status of a message in storage, as per 3GPP TS 27.005 Section
+// 3.1.
+type Stat int
+
+const (
+	// StatRecUnread indicates a received message that has not been read.
+	StatRecUnread Stat = iota
+
+	// StatRecRead indicates a received message that has been read.
+	StatRecRead
+
+	// StatStoUnsent indicates a stored message that has not been sent.
+	StatStoUnsent
+
+	// StatStoSent indicates a stored message that has been sent.
+	StatStoSent
+
+	// StatAll selects all messages when listing.
+	StatAll
+)
+
+var statNames = map[Stat]string{
+	StatRecUnread: "REC UNREAD",
+	StatRecRead:   "REC READ",
+	StatStoUnsent: "STO UNSENT",
+	StatStoSent:   "STO SENT",
+	StatAll:       "ALL",
+}
+
+// String returns the text mode form of the Stat.
+func (s Stat) String() string {
+	if n, ok := statNames[s]; ok {
+		return n
+	}
+	return strconv.Itoa(int(s))
+}
+
+// CMGL is an entry in the response to AT+CMGL.
+//
+// +CMGL: <index>,<stat>,[<alpha>],<length><CR><LF><pdu>
+type CMGL struct {
+	Index  int
+	Stat   Stat
+	Alpha  string
+	Length int
+	PDU    *PDU
+}
+
+// CMGR is the response to AT+CMGR.
+//
+// +CMGR: <stat>,[<alpha>],<length><CR><LF><pdu>
+type CMGR struct {
+	Stat   Stat
+	Alpha  string
+	Length int
+	PDU    *PDU
+}
+
+// CMT is the unsolicited result indicating a received SMS-DELIVER.
+//
+// +CMT: [<alpha>],<length><CR><LF><pdu>
+type CMT struct {
+	Alpha  string
+	Length int
+	PDU    *PDU
+}
+
+// CDS is the unsolicited result indicating a received SMS-STATUS-REPORT.
+//
+// +CDS: <length><CR><LF><pdu>
+type CDS struct {
+	Length int
+	PDU    *PDU
+}
+
+// CMGS is the response to AT+CMGS.
+//
+// +CMGS: <mr>[,<ackpdu>]
+type CMGS struct {
+	// MR is the TP-MR assigned to the submitted TPDU.
+	MR int
+
+	// AckPDU is the SMS-SUBMIT-REPORT TPDU returned by the network, if
+	// any.
+	AckPDU []byte
+}
+
+// ParseCMGL parses an entry in the response to AT+CMGL, consisting of the
+// header line and the following PDU line.
+func ParseCMGL(header, pdu string) (*CMGL, error) {
+	f, err := HeaderFields(header, "+CMGL:", 3)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := strconv.Atoi(f[0])
+	if err != nil || idx < 0 {
+		return nil, ErrInvalidHeader(header)
+	}
+	stat, err := parseStat(f[1], header)
+	if err != nil {
+		return nil, err
+	}
+	r := CMGL{Index: idx, Stat: stat}
+	r.Alpha, r.Length, r.PDU, err = parseBody(f[2:], header, pdu)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ParseCMGR parses the response to AT+CMGR, consisting of the header line
+// and the following PDU line.
+func ParseCMGR(header, pdu string) (*CMGR, error) {
+	f, err := HeaderFields(header, "+CMGR:", 2)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := parseStat(f[0], header)
+	if err != nil {
+		return nil, err
+	}
+	r := CMGR{Stat: stat}
+	r.Alpha, r.Length, r.PDU, err = parseBody(f[1:], header, pdu)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ParseCMT parses the +CMT unsolicited result, consisting of the header line
+// and the following PDU line.
+func ParseCMT(header, pdu string) (*CMT, error) {
+	f, err := HeaderFields(header, "+CMT:", 1)
+	if err != nil {
+		return nil, err
+	}
+	r := CMT{}
+	r.Alpha, r.Length, r.PDU, err = parseBody(f, header, pdu)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ParseCDS parses the +CDS unsolicited result, consisting of the header line
+// and the following PDU line.
+func ParseCDS(header, pdu string) (*CDS, error) {
+	f, err := HeaderFields(header, "+CDS:", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(f) != 1 {
+		return nil, ErrInvalidHeader(header)
+	}
+	r := CDS{}
+	_, r.Length, r.PDU, err = parseBody(f, header, pdu)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ParseCMGS parses the response to AT+CMGS.
+func ParseCMGS(line string) (*CMGS, error) {
+	f, err := HeaderFields(line, "+CMGS:", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(f) > 2 {
+		return nil, ErrInvalidHeader(line)
+	}
+	mr, err := strconv.Atoi(f[0])
+	if err != nil || mr < 0 || mr > 255 {
+		return nil, ErrInvalidHeader(line)
+	}
+	r := CMGS{MR: mr}
+	if len(f) == 2 && len(f[1]) > 0 {
+		if r.AckPDU, err = hex.DecodeString(f[1]); err != nil {
+			return nil, ErrInvalidHeader(line)
+		}
+	}
+	return &r, nil
+}
+
+// CMGSCommand returns the AT+CMGS command used to send the PDU.
+//
+// The <length> is the length of the TPDU, excluding the SMSC address.
+func CMGSCommand(p *PDU) string {
+	return fmt.Sprintf("AT+CMGS=%d", len(p.TPDU))
+}
+
+// headerFields strips the prefix from the header and splits the remainder
+// into its comma separated fields, removing any quotes.
+//
+// Returns an error if the header does not have the prefix or has fewer than
+// min fields.
+func HeaderFields(header, prefix string, min int) ([]string, error) {
+	if !strings.HasPrefix(header, prefix) {
+		return nil, ErrInvalidHeader(header)
+	}
+	s := strings.TrimSpace(header[len(prefix):])
+	var f []string
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			f = append(f, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	f = append(f, strings.TrimSpace(b.String()))
+	if quoted || len(f) < min {
+		return nil, ErrInvalidHeader(header)
+	}
+	return f, nil
+}
+
+// parseStat parses the <stat> field of a header.
+func parseStat(s, header string) (Stat, error) {
+	stat, err := strconv.Atoi(s)
+	if err != nil || stat < int(StatRecUnread) || stat > int(StatStoSent) {
+		return 0, ErrInvalidHeader(header)
+	}
+	return Stat(stat), nil
+}
+
+// parseBody parses the optional [<alpha>] and the <length> fields of a
+// header, and the PDU that follows it.
+//
+// The length is checked against the length of the TPDU.
+func parseBody(f []string, header, pdu string) (string, int, *PDU, error) {
+	var alpha string
+	switch len(f) {
+	case 1:
+	case 2:
+		alpha = f[0]
+	default:
+		return "", 0, nil, ErrInvalidHeader(header)
+	}
+	l, err := strconv.Atoi(f[len(f)-1])
+	if err != nil || l < 0 {
+		return "", 0, nil, ErrInvalidHeader(header)
+	}
+	p, err := UnmarshalHexString(strings.TrimSpace(pdu))
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if len(p.TPDU) != l {
+		return "", 0, nil, ErrLengthMismatch{Header: l, TPDU: len(p.TPDU)}
+	}
+	return alpha, l, p, nil
+}
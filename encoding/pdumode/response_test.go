@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package pdumode_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+const (
+	// SMS-DELIVER with SMSC address
+	deliverPDU = "07911614786007F0040B911624214365F700000290901071142302C834"
+
+	// SMS-STATUS-REPORT without SMSC address
+	statusReportPDU = "0006" + "2A" + "04A12143" + "02909010711423" + "02909010711523" + "00"
+)
+
+func mustUnmarshalHex(t *testing.T, s string) *pdumode.PDU {
+	t.Helper()
+	p, err := pdumode.UnmarshalHexString(s)
+	require.Nil(t, err)
+	return p
+}
+
+func TestParseCMGL(t *testing.T) {
+	p := mustUnmarshalHex(t, deliverPDU)
+	patterns := []struct {
+		name   string
+		header string
+		pdu    string
+		out    *pdumode.CMGL
+		err    error
+	}{
+		{"empty alpha", "+CMGL: 3,1,,21", deliverPDU,
+			&pdumode.CMGL{Index: 3, Stat: pdumode.StatRecRead, Length: 21, PDU: p}, nil},
+		{"alpha", "+CMGL: 3,0,\"Bob, Jr\",21", deliverPDU,
+			&pdumode.CMGL{Index: 3, Stat: pdumode.StatRecUnread, Alpha: "Bob, Jr", Length: 21, PDU: p}, nil},
+		{"no alpha", "+CMGL: 0,3,21", deliverPDU,
+			&pdumode.CMGL{Index: 0, Stat: pdumode.StatStoSent, Length: 21, PDU: p}, nil},
+		{"prefix", "+CMGR: 3,1,,21", deliverPDU, nil, pdumode.ErrInvalidHeader("+CMGR: 3,1,,21")},
+		{"short", "+CMGL: 3,1", deliverPDU, nil, pdumode.ErrInvalidHeader("+CMGL: 3,1")},
+		{"long", "+CMGL: 3,1,,,21", deliverPDU, nil, pdumode.ErrInvalidHeader("+CMGL: 3,1,,,21")},
+		{"index", "+CMGL: x,1,,21", deliverPDU, nil, pdumode.ErrInvalidHeader("+CMGL: x,1,,21")},
+		{"stat", "+CMGL: 3,4,,21", deliverPDU, nil, pdumode.ErrInvalidHeader("+CMGL: 3,4,,21")},
+		{"length", "+CMGL: 3,1,,x", deliverPDU, nil, pdumode.ErrInvalidHeader("+CMGL: 3,1,,x")},
+		{"quote", "+CMGL: 3,1,\",21", deliverPDU, nil, pdumode.ErrInvalidHeader("+CMGL: 3,1,\",21")},
+		{"mismatch", "+CMGL: 3,1,,22", deliverPDU, nil, pdumode.ErrLengthMismatch{Header: 22, TPDU: 21}},
+		{"pdu", "+CMGL: 3,1,,21", "0x", nil, hex.InvalidByteError('x')},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := pdumode.ParseCMGL(p.header, p.pdu)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, r)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestParseCMGR(t *testing.T) {
+	p := mustUnmarshalHex(t, deliverPDU)
+	patterns := []struct {
+		name   string
+		header string
+		out    *pdumode.CMGR
+		err    error
+	}{
+		{"empty alpha", "+CMGR: 0,,21", &pdumode.CMGR{Stat: pdumode.StatRecUnread, Length: 21, PDU: p}, nil},
+		{"alpha", "+CMGR: 2,\"Alice\",21", &pdumode.CMGR{Stat: pdumode.StatStoUnsent, Alpha: "Alice", Length: 21, PDU: p}, nil},
+		{"no alpha", "+CMGR: 1,21", &pdumode.CMGR{Stat: pdumode.StatRecRead, Length: 21, PDU: p}, nil},
+		{"short", "+CMGR: 21", nil, pdumode.ErrInvalidHeader("+CMGR: 21")},
+		{"stat", "+CMGR: -1,,21", nil, pdumode.ErrInvalidHeader("+CMGR: -1,,21")},
+		{"mismatch", "+CMGR: 0,,20", nil, pdumode.ErrLengthMismatch{Header: 20, TPDU: 21}},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := pdumode.ParseCMGR(p.header, deliverPDU)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, r)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestParseCMT(t *testing.T) {
+	p := mustUnmarshalHex(t, deliverPDU)
+	patterns := []struct {
+		name   string
+		header string
+		out    *pdumode.CMT
+		err    error
+	}{
+		{"empty alpha", "+CMT: ,21", &pdumode.CMT{Length: 21, PDU: p}, nil},
+		{"alpha", "+CMT: \"Alice\",21", &pdumode.CMT{Alpha: "Alice", Length: 21, PDU: p}, nil},
+		{"no alpha", "+CMT: 21", &pdumode.CMT{Length: 21, PDU: p}, nil},
+		{"prefix", "+CMTI: \"SM\",21", nil, pdumode.ErrInvalidHeader("+CMTI: \"SM\",21")},
+		{"long", "+CMT: ,,21", nil, pdumode.ErrInvalidHeader("+CMT: ,,21")},
+		{"mismatch", "+CMT: ,7", nil, pdumode.ErrLengthMismatch{Header: 7, TPDU: 21}},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := pdumode.ParseCMT(p.header, deliverPDU)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, r)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestParseCDS(t *testing.T) {
+	p := mustUnmarshalHex(t, statusReportPDU)
+	patterns := []struct {
+		name   string
+		header string
+		out    *pdumode.CDS
+		err    error
+	}{
+		{"ok", "+CDS: 21", &pdumode.CDS{Length: 21, PDU: p}, nil},
+		{"alpha", "+CDS: ,21", nil, pdumode.ErrInvalidHeader("+CDS: ,21")},
+		{"length", "+CDS: ", nil, pdumode.ErrInvalidHeader("+CDS: ")},
+		{"mismatch", "+CDS: 22", nil, pdumode.ErrLengthMismatch{Header: 22, TPDU: 21}},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := pdumode.ParseCDS(p.header, statusReportPDU)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, r)
+		}
+		t.Run(p.name, f)
+	}
+
+	// the PDU decodes as a status report
+	r, err := pdumode.ParseCDS("+CDS: 21", statusReportPDU)
+	require.Nil(t, err)
+	sr := tpdu.TPDU{}
+	err = sr.UnmarshalBinary(r.PDU.TPDU)
+	require.Nil(t, err)
+	assert.Equal(t, tpdu.SmsStatusReport, sr.SmsType())
+	assert.Equal(t, byte(42), sr.MR)
+}
+
+func TestParseCMGS(t *testing.T) {
+	patterns := []struct {
+		name string
+		line string
+		out  *pdumode.CMGS
+		err  error
+	}{
+		{"mr", "+CMGS: 42", &pdumode.CMGS{MR: 42}, nil},
+		{"ackpdu", "+CMGS: 0,\"0100\"", &pdumode.CMGS{MR: 0, AckPDU: []byte{1, 0}}, nil},
+		{"empty ackpdu", "+CMGS: 255,", &pdumode.CMGS{MR: 255}, nil},
+		{"prefix", "+CMGW: 42", nil, pdumode.ErrInvalidHeader("+CMGW: 42")},
+		{"range", "+CMGS: 256", nil, pdumode.ErrInvalidHeader("+CMGS: 256")},
+		{"mr digits", "+CMGS: x", nil, pdumode.ErrInvalidHeader("+CMGS: x")},
+		{"ackpdu hex", "+CMGS: 1,\"xx\"", nil, pdumode.ErrInvalidHeader("+CMGS: 1,\"xx\"")},
+		{"long", "+CMGS: 1,00,00", nil, pdumode.ErrInvalidHeader("+CMGS: 1,00,00")},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := pdumode.ParseCMGS(p.line)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, r)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestCMGSCommand(t *testing.T) {
+	p := mustUnmarshalHex(t, deliverPDU)
+	assert.Equal(t, "AT+CMGS=21", pdumode.CMGSCommand(p))
+	p = mustUnmarshalHex(t, "00"+deliverPDU[16:])
+	assert.Equal(t, "AT+CMGS=21", pdumode.CMGSCommand(p))
+}
+
+func TestHeaderFields(t *testing.T) {
+	patterns := []struct {
+		name   string
+		header string
+		min    int
+		out    []string
+		err    error
+	}{
+		{"plain", "+CMT: 1,23", 2, []string{"1", "23"}, nil},
+		{"quoted", "+CMT: \"a,b\", 23", 2, []string{"a,b", "23"}, nil},
+		{"empty", "+CMT: ,23", 2, []string{"", "23"}, nil},
+		{"prefix", "+CDS: 1,23", 2, nil, pdumode.ErrInvalidHeader("+CDS: 1,23")},
+		{"unterminated", "+CMT: \"a,23", 1, nil, pdumode.ErrInvalidHeader("+CMT: \"a,23")},
+		{"short", "+CMT: 23", 2, nil, pdumode.ErrInvalidHeader("+CMT: 23")},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			ff, err := pdumode.HeaderFields(p.header, "+CMT:", p.min)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, ff)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestStat(t *testing.T) {
+	assert.Equal(t, "REC UNREAD", pdumode.StatRecUnread.String())
+	assert.Equal(t, "STO SENT", pdumode.StatStoSent.String())
+	assert.Equal(t, "ALL", pdumode.StatAll.String())
+	assert.Equal(t, "7", pdumode.Stat(7).String())
+}
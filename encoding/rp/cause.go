@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package rp
+
+import "strconv"
+
+// Cause is the RP-Cause value of an RP-ERROR, as defined in 3GPP TS 24.011
+// Section 8.2.5.4.
+type Cause byte
+
+const (
+	// CauseUnassignedNumber indicates the destination requested by the MS
+	// cannot be reached because the number is not allocated.
+	CauseUnassignedNumber Cause = 1
+
+	// CauseOperatorDeterminedBarring indicates the MS has tried to send a
+	// message when the operator has barred it.
+	CauseOperatorDeterminedBarring Cause = 8
+
+	// CauseCallBarred indicates the outgoing call barred service applies to
+	// the short message service for the MS.
+	CauseCallBarred Cause = 10
+
+	// CauseShortMessageTransferRejected indicates the equipment sending this
+	// cause does not wish to accept the message, although it could.
+	CauseShortMessageTransferRejected Cause = 21
+
+	// CauseMemoryCapacityExceeded indicates the MS has no memory available
+	// to store the message.
+	CauseMemoryCapacityExceeded Cause = 22
+
+	// CauseDestinationOutOfOrder indicates the destination cannot be
+	// reached because its interface is not functioning correctly.
+	CauseDestinationOutOfOrder Cause = 27
+
+	// CauseUnidentifiedSubscriber indicates the subscriber is not registered
+	// in the PLMN.
+	CauseUnidentifiedSubscriber Cause = 28
+
+	// CauseFacilityRejected indicates the facility requested by the MS is
+	// not supported by the PLMN.
+	CauseFacilityRejected Cause = 29
+
+	// CauseUnknownSubscriber indicates the subscriber is not registered in
+	// the HLR.
+	CauseUnknownSubscriber Cause = 30
+
+	// CauseNetworkOutOfOrder indicates the network is not functioning
+	// correctly, and is not expected to recover soon.
+	CauseNetworkOutOfOrder Cause = 38
+
+	// CauseTemporaryFailure indicates the network is not functioning
+	// correctly, but is expected to recover soon.
+	CauseTemporaryFailure Cause = 41
+
+	// CauseCongestion indicates the network is congested.
+	CauseCongestion Cause = 42
+
+	// CauseResourcesUnavailable indicates a resource is unavailable.
+	CauseResourcesUnavailable Cause = 47
+
+	// CauseFacilityNotSubscribed indicates the MS is not subscribed to the
+	// facility.
+	CauseFacilityNotSubscribed Cause = 50
+
+	// CauseFacilityNotImplemented indicates the network does not implement
+	// the facility.
+	CauseFacilityNotImplemented Cause = 69
+
+	// CauseInvalidReference indicates the MR of the message is not in use on
+	// the interface.
+	CauseInvalidReference Cause = 81
+
+	// CauseSemanticallyIncorrect indicates the message is semantically
+	// incorrect.
+	CauseSemanticallyIncorrect Cause = 95
+
+	// CauseInvalidMandatoryInformation indicates the message is missing
+	// mandatory information, or it is invalid.
+	CauseInvalidMandatoryInformation Cause = 96
+
+	// CauseMessageTypeNonExistent indicates the message type is not defined
+	// or not implemented.
+	CauseMessageTypeNonExistent Cause = 97
+
+	// CauseMessageNotCompatible indicates the message is not compatible with
+	// the protocol state.
+	CauseMessageNotCompatible Cause = 98
+
+	// CauseIENonExistent indicates the message contains an information
+	// element that is not defined or not implemented.
+	CauseIENonExistent Cause = 99
+
+	// CauseProtocolError indicates an unspecified protocol error.
+	CauseProtocolError Cause = 111
+
+	// CauseInterworking indicates an unspecified interworking error.
+	CauseInterworking Cause = 127
+)
+
+var causeNames = map[Cause]string{
+	CauseUnassignedNumber:             "unassigned (unallocated) number",
+	CauseOperatorDeterminedBarring:    "operator determined barring",
+	CauseCallBarred:                   "call barred",
+	CauseShortMessageTransferRejected: "short message transfer rejected",
+	CauseMemoryCapacityExceeded:       "memory capacity exceeded",
+	CauseDestinationOutOfOrder:        "destination out of order",
+	CauseUnidentifiedSubscriber:       "unidentified subscriber",
+	CauseFacilityRejected:             "facility rejected",
+	CauseUnknownSubscriber:            "unknown subscriber",
+	CauseNetworkOutOfOrder:            "network out of order",
+	CauseTemporaryFailure:             "temporary failure",
+	CauseCongestion:                   "congestion",
+	CauseResourcesUnavailable:         "resources unavailable, unspecified",
+	CauseFacilityNotSubscribed:        "requested facility not subscribed",
+	CauseFacilityNotImplemented:       "requested facility not implemented",
+	CauseInvalidReference:             "invalid short message transfer reference value",
+	CauseSemanticallyIncorrect:        "semantically incorrect message",
+	CauseInvalidMandatoryInformation:  "invalid mandatory information",
+	CauseMessageTypeNonExistent:       "message type non-existent or not implemented",
+	CauseMessageNotCompatible:         "message not compatible with short message protocol state",
+	CauseIENonExistent:                "information element non-existent or not implemented",
+	CauseProtocolError:                "protocol error, unspecified",
+	CauseInterworking:                 "interworking, unspecified",
+}
+
+func (c Cause) String() string {
+	if n, ok := causeNames[c]; ok {
+		return n
+	}
+	return strconv.Itoa(int(c))
+}
+
+// Temporary indicates the cause is a temporary condition, so the transfer
+// may be retried later.
+func (c Cause) Temporary() bool {
+	switch c {
+	case CauseMemoryCapacityExceeded, CauseDestinationOutOfOrder,
+		CauseNetworkOutOfOrder, CauseTemporaryFailure, CauseCongestion,
+		CauseResourcesUnavailable:
+		return true
+	}
+	return false
+}
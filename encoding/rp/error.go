@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package rp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidIEI indicates an optional information element has an unexpected
+// IEI.
+type ErrInvalidIEI byte
+
+func (e ErrInvalidIEI) Error() string {
+	return fmt.Sprintf("rp: invalid IEI 0x%02x", int(e))
+}
+
+// ErrUnsupportedMessageType indicates the message type is reserved.
+type ErrUnsupportedMessageType MessageType
+
+func (e ErrUnsupportedMessageType) Error() string {
+	return fmt.Sprintf("rp: unsupported message type %d", int(e))
+}
+
+var (
+	// ErrInvalidCause indicates the RP-Cause has an invalid length.
+	ErrInvalidCause = errors.New("rp: invalid cause")
+
+	// ErrMissingUserData indicates an RP-DATA has no RP-User-Data.
+	ErrMissingUserData = errors.New("rp: missing user data")
+)
@@ -0,0 +1,254 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package rp provides encoding and decoding of the Relay Protocol messages
+// that carry TPDUs between the MS and the network, as defined in 3GPP TS
+// 24.011 Section 7.3.
+package rp
+
+import (
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// MessageType is the RP Message Type Indicator, as defined in 3GPP TS 24.011
+// Section 8.2.2.
+type MessageType byte
+
+const (
+	// DataMSToN is an RP-DATA sent from the MS to the network, carrying an
+	// SMS-SUBMIT or SMS-COMMAND.
+	DataMSToN MessageType = iota
+
+	// DataNToMS is an RP-DATA sent from the network to the MS, carrying an
+	// SMS-DELIVER or SMS-STATUS-REPORT.
+	DataNToMS
+
+	// AckMSToN is an RP-ACK sent from the MS to the network, optionally
+	// carrying an SMS-DELIVER-REPORT.
+	AckMSToN
+
+	// AckNToMS is an RP-ACK sent from the network to the MS, optionally
+	// carrying an SMS-SUBMIT-REPORT.
+	AckNToMS
+
+	// ErrorMSToN is an RP-ERROR sent from the MS to the network, optionally
+	// carrying an SMS-DELIVER-REPORT.
+	ErrorMSToN
+
+	// ErrorNToMS is an RP-ERROR sent from the network to the MS, optionally
+	// carrying an SMS-SUBMIT-REPORT.
+	ErrorNToMS
+
+	// SMMA is an RP-SMMA sent from the MS to the network, indicating the MS
+	// has memory available to receive messages.
+	SMMA
+)
+
+var typeNames = map[MessageType]string{
+	DataMSToN:  "RP-DATA (MS->N)",
+	DataNToMS:  "RP-DATA (N->MS)",
+	AckMSToN:   "RP-ACK (MS->N)",
+	AckNToMS:   "RP-ACK (N->MS)",
+	ErrorMSToN: "RP-ERROR (MS->N)",
+	ErrorNToMS: "RP-ERROR (N->MS)",
+	SMMA:       "RP-SMMA (MS->N)",
+}
+
+func (t MessageType) String() string {
+	if n, ok := typeNames[t]; ok {
+		return n
+	}
+	return "RP-Reserved"
+}
+
+// Direction returns the direction, relative to the MS, of the TPDU carried
+// in the message.
+//
+// Messages from the MS to the network carry MO TPDUs, while those from the
+// network to the MS carry MT TPDUs.
+func (t MessageType) Direction() tpdu.Direction {
+	if t&0x01 == 0 {
+		return tpdu.MO
+	}
+	return tpdu.MT
+}
+
+// uiIEI is the IEI of the optional RP-User-Data in RP-ACK and RP-ERROR.
+const uiIEI = 0x41
+
+// Message is an RP message.
+//
+// The fields populated depend on the message Type.
+type Message struct {
+	Type MessageType
+
+	// MR is the RP-Message Reference.
+	MR byte
+
+	// OA is the RP-Originator Address, which is the SC address in an
+	// RP-DATA (N->MS), and empty otherwise.
+	OA pdumode.SMSCAddress
+
+	// DA is the RP-Destination Address, which is the SC address in an
+	// RP-DATA (MS->N), and empty otherwise.
+	DA pdumode.SMSCAddress
+
+	// Cause is the RP-Cause of an RP-ERROR.
+	Cause Cause
+
+	// Diagnostic is the optional diagnostic field of the RP-Cause.
+	Diagnostic []byte
+
+	// UD is the TPDU carried in the RP-User-Data.
+	//
+	// It is mandatory for RP-DATA, optional for RP-ACK and RP-ERROR, and
+	// absent from RP-SMMA.
+	UD *tpdu.TPDU
+}
+
+// MarshalBinary encodes the message into its binary form.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if m.Type > SMMA {
+		return nil, tpdu.EncodeError("type", ErrUnsupportedMessageType(m.Type))
+	}
+	b := []byte{byte(m.Type), m.MR}
+	switch m.Type {
+	case DataMSToN, DataNToMS:
+		oa, err := m.OA.MarshalBinary()
+		if err != nil {
+			return nil, tpdu.EncodeError("oa", err)
+		}
+		da, err := m.DA.MarshalBinary()
+		if err != nil {
+			return nil, tpdu.EncodeError("da", err)
+		}
+		b = append(b, oa...)
+		b = append(b, da...)
+		if m.UD == nil {
+			return nil, tpdu.EncodeError("ud", ErrMissingUserData)
+		}
+		ud, err := m.marshalUD()
+		if err != nil {
+			return nil, err
+		}
+		return append(b, ud...), nil
+	case ErrorMSToN, ErrorNToMS:
+		if len(m.Diagnostic) > 1 {
+			return nil, tpdu.EncodeError("cause", ErrInvalidCause)
+		}
+		b = append(b, byte(1+len(m.Diagnostic)), byte(m.Cause)&0x7f)
+		b = append(b, m.Diagnostic...)
+		fallthrough
+	case AckMSToN, AckNToMS:
+		if m.UD != nil {
+			ud, err := m.marshalUD()
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, uiIEI)
+			b = append(b, ud...)
+		}
+	}
+	return b, nil
+}
+
+// marshalUD encodes the RP-User-Data, being the length and the TPDU.
+func (m *Message) marshalUD() ([]byte, error) {
+	t, err := m.UD.MarshalBinary()
+	if err != nil {
+		return nil, tpdu.EncodeError("ud", err)
+	}
+	if len(t) > 255 {
+		return nil, tpdu.EncodeError("ud", tpdu.ErrOverlength)
+	}
+	return append([]byte{byte(len(t))}, t...), nil
+}
+
+// UnmarshalBinary decodes the message from its binary form.
+//
+// The direction of the TPDU carried in the RP-User-Data is determined from
+// the message type.
+func (m *Message) UnmarshalBinary(b []byte) error {
+	if len(b) < 2 {
+		return tpdu.NewDecodeError("mr", len(b), tpdu.ErrUnderflow)
+	}
+	n := Message{Type: MessageType(b[0] & 0x07), MR: b[1]}
+	ri := 2
+	switch n.Type {
+	case DataMSToN, DataNToMS:
+		l, err := n.OA.UnmarshalBinary(b[ri:])
+		if err != nil {
+			return tpdu.NewDecodeError("oa", ri, err)
+		}
+		ri += l
+		l, err = n.DA.UnmarshalBinary(b[ri:])
+		if err != nil {
+			return tpdu.NewDecodeError("da", ri, err)
+		}
+		ri += l
+		if ri >= len(b) {
+			return tpdu.NewDecodeError("ud", ri, tpdu.ErrUnderflow)
+		}
+		l, err = n.unmarshalUD(b[ri:])
+		if err != nil {
+			return tpdu.NewDecodeError("ud", ri, err)
+		}
+		ri += l
+	case ErrorMSToN, ErrorNToMS:
+		if ri >= len(b) {
+			return tpdu.NewDecodeError("cause", ri, tpdu.ErrUnderflow)
+		}
+		l := int(b[ri])
+		if l < 1 || l > 2 {
+			return tpdu.NewDecodeError("cause", ri, ErrInvalidCause)
+		}
+		if len(b) < ri+1+l {
+			return tpdu.NewDecodeError("cause", ri, tpdu.ErrUnderflow)
+		}
+		n.Cause = Cause(b[ri+1] & 0x7f)
+		if l == 2 {
+			n.Diagnostic = []byte{b[ri+2]}
+		}
+		ri += 1 + l
+		fallthrough
+	case AckMSToN, AckNToMS:
+		if ri < len(b) {
+			if b[ri] != uiIEI {
+				return tpdu.NewDecodeError("ud", ri, ErrInvalidIEI(b[ri]))
+			}
+			ri++
+			l, err := n.unmarshalUD(b[ri:])
+			if err != nil {
+				return tpdu.NewDecodeError("ud", ri, err)
+			}
+			ri += l
+		}
+	case SMMA:
+	default:
+		return tpdu.NewDecodeError("type", 0, ErrUnsupportedMessageType(n.Type))
+	}
+	if ri != len(b) {
+		return tpdu.NewDecodeError("ud", ri, tpdu.ErrOverlength)
+	}
+	*m = n
+	return nil
+}
+
+// unmarshalUD decodes the RP-User-Data, being the length and the TPDU.
+func (m *Message) unmarshalUD(b []byte) (int, error) {
+	if len(b) < 1 {
+		return 0, tpdu.ErrUnderflow
+	}
+	l := int(b[0])
+	if len(b) < 1+l {
+		return 0, tpdu.ErrUnderflow
+	}
+	t := tpdu.TPDU{Direction: m.Type.Direction()}
+	if err := t.UnmarshalBinary(b[1 : 1+l]); err != nil {
+		return 0, err
+	}
+	m.UD = &t
+	return 1 + l, nil
+}
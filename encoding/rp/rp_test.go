@@ -0,0 +1,268 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package rp_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+const (
+	// SMS-SUBMIT to 6391 containing "hello".
+	submitTPDU = "0142049136190000" + "05E8329BFD06"
+
+	// SMS-DELIVER from 6391 containing "Hahahaha".
+	deliverTPDU = "040491361900005150713220052308C8303A8C0EA3C3"
+
+	// SMS-DELIVER-REPORT with FCS memory capacity exceeded.
+	deliverReportTPDU = "00D300"
+)
+
+var smsc = pdumode.SMSCAddress{
+	Address: tpdu.Address{Addr: "639170000293", TOA: 0x91},
+}
+
+type testPattern struct {
+	name string
+	in   string
+	msg  func(t *testing.T) *rp.Message
+}
+
+var patterns = []testPattern{
+	{
+		"data ms to n",
+		"002A000791361907002039" + "0E" + submitTPDU,
+		func(t *testing.T) *rp.Message {
+			return &rp.Message{
+				Type: rp.DataMSToN,
+				MR:   0x2a,
+				DA:   smsc,
+				UD:   fixture.TPDU(t, tpdu.MO, submitTPDU),
+			}
+		},
+	},
+	{
+		"data n to ms",
+		"012A079136190700203900" + "16" + deliverTPDU,
+		func(t *testing.T) *rp.Message {
+			return &rp.Message{
+				Type: rp.DataNToMS,
+				MR:   0x2a,
+				OA:   smsc,
+				UD:   fixture.TPDU(t, tpdu.MT, deliverTPDU),
+			}
+		},
+	},
+	{
+		"ack ms to n",
+		"022A",
+		func(t *testing.T) *rp.Message {
+			return &rp.Message{Type: rp.AckMSToN, MR: 0x2a}
+		},
+	},
+	{
+		"ack n to ms",
+		"032A",
+		func(t *testing.T) *rp.Message {
+			return &rp.Message{Type: rp.AckNToMS, MR: 0x2a}
+		},
+	},
+	{
+		"error ms to n",
+		"042A021600" + "4103" + deliverReportTPDU,
+		func(t *testing.T) *rp.Message {
+			return &rp.Message{
+				Type:       rp.ErrorMSToN,
+				MR:         0x2a,
+				Cause:      rp.CauseMemoryCapacityExceeded,
+				Diagnostic: []byte{0},
+				UD:         fixture.TPDU(t, tpdu.MO, deliverReportTPDU),
+			}
+		},
+	},
+	{
+		"error n to ms",
+		"052A012A",
+		func(t *testing.T) *rp.Message {
+			return &rp.Message{
+				Type:  rp.ErrorNToMS,
+				MR:    0x2a,
+				Cause: rp.CauseCongestion,
+			}
+		},
+	},
+	{
+		"smma",
+		"062A",
+		func(t *testing.T) *rp.Message {
+			return &rp.Message{Type: rp.SMMA, MR: 0x2a}
+		},
+	},
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.msg(t).MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, fixture.Hex(t, p.in), b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestMarshalBinaryError(t *testing.T) {
+	patterns := []struct {
+		name string
+		msg  rp.Message
+		err  error
+	}{
+		{
+			"reserved",
+			rp.Message{Type: 7},
+			tpdu.EncodeError("type", rp.ErrUnsupportedMessageType(7)),
+		},
+		{
+			"missing ud",
+			rp.Message{Type: rp.DataMSToN, DA: smsc},
+			tpdu.EncodeError("ud", rp.ErrMissingUserData),
+		},
+		{
+			"long diagnostic",
+			rp.Message{Type: rp.ErrorNToMS, Diagnostic: []byte{1, 2}},
+			tpdu.EncodeError("cause", rp.ErrInvalidCause),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.msg.MarshalBinary()
+			assert.Equal(t, p.err, err)
+			assert.Nil(t, b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := rp.Message{}
+			err := m.UnmarshalBinary(fixture.Hex(t, p.in))
+			require.Nil(t, err)
+			assert.Equal(t, p.msg(t), &m)
+			if m.UD != nil {
+				assert.Equal(t, m.Type.Direction(), m.UD.Direction)
+			}
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUnmarshalBinaryError(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{
+			"empty",
+			"",
+			tpdu.NewDecodeError("mr", 0, tpdu.ErrUnderflow),
+		},
+		{
+			"reserved",
+			"072A",
+			tpdu.NewDecodeError("type", 0, rp.ErrUnsupportedMessageType(7)),
+		},
+		{
+			"missing oa",
+			"002A",
+			tpdu.NewDecodeError("oa.length", 2, tpdu.ErrUnderflow),
+		},
+		{
+			"short da",
+			"002A000791",
+			tpdu.NewDecodeError("da.addr", 5, tpdu.ErrUnderflow),
+		},
+		{
+			"missing ud",
+			"002A0000",
+			tpdu.NewDecodeError("ud", 4, tpdu.ErrUnderflow),
+		},
+		{
+			"short ud",
+			"002A00000F0142",
+			tpdu.NewDecodeError("ud", 4, tpdu.ErrUnderflow),
+		},
+		{
+			"missing cause",
+			"052A",
+			tpdu.NewDecodeError("cause", 2, tpdu.ErrUnderflow),
+		},
+		{
+			"short cause",
+			"052A02",
+			tpdu.NewDecodeError("cause", 2, tpdu.ErrUnderflow),
+		},
+		{
+			"invalid cause",
+			"052A00",
+			tpdu.NewDecodeError("cause", 2, rp.ErrInvalidCause),
+		},
+		{
+			"invalid iei",
+			"032A420100",
+			tpdu.NewDecodeError("ud", 2, rp.ErrInvalidIEI(0x42)),
+		},
+		{
+			"overlength",
+			"062A00",
+			tpdu.NewDecodeError("ud", 2, tpdu.ErrOverlength),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := rp.Message{}
+			err := m.UnmarshalBinary(fixture.Hex(t, p.in))
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, rp.Message{}, m)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestDirection(t *testing.T) {
+	patterns := []struct {
+		mt  rp.MessageType
+		dir tpdu.Direction
+	}{
+		{rp.DataMSToN, tpdu.MO},
+		{rp.DataNToMS, tpdu.MT},
+		{rp.AckMSToN, tpdu.MO},
+		{rp.AckNToMS, tpdu.MT},
+		{rp.ErrorMSToN, tpdu.MO},
+		{rp.ErrorNToMS, tpdu.MT},
+		{rp.SMMA, tpdu.MO},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			assert.Equal(t, p.dir, p.mt.Direction())
+		}
+		t.Run(p.mt.String(), f)
+	}
+}
+
+func TestCause(t *testing.T) {
+	assert.Equal(t, "memory capacity exceeded", rp.CauseMemoryCapacityExceeded.String())
+	assert.Equal(t, "3", rp.Cause(3).String())
+	assert.True(t, rp.CauseCongestion.Temporary())
+	assert.False(t, rp.CauseUnknownSubscriber.Temporary())
+}
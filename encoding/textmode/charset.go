@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package textmode
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+// Charset identifies the TE character set, as selected by AT+CSCS.
+type Charset string
+
+const (
+	// GSM is the GSM 7 bit default alphabet, with one septet per character.
+	GSM Charset = "GSM"
+
+	// IRA is the International Reference Alphabet, i.e. ASCII.
+	IRA Charset = "IRA"
+
+	// HEX represents each GSM 7 bit septet as two hexadecimal digits.
+	HEX Charset = "HEX"
+
+	// UCS2 represents each 16-bit UCS2 character as four hexadecimal digits.
+	UCS2 Charset = "UCS2"
+)
+
+// CSCSCommand returns the AT+CSCS command that selects the character set.
+func (cs Charset) CSCSCommand() string {
+	return fmt.Sprintf("AT+CSCS=\"%s\"", string(cs))
+}
+
+// EncodeString converts a UTF-8 string into the character set.
+func EncodeString(s string, cs Charset) (string, error) {
+	switch cs {
+	case GSM, HEX:
+		u, err := gsm7.Encode([]byte(s))
+		if err != nil {
+			return "", err
+		}
+		if cs == HEX {
+			return strings.ToUpper(hex.EncodeToString(u)), nil
+		}
+		return string(u), nil
+	case IRA:
+		for _, r := range s {
+			if r >= utf8.RuneSelf {
+				return "", ErrUnencodable(r)
+			}
+		}
+		return s, nil
+	case UCS2:
+		return strings.ToUpper(hex.EncodeToString(ucs2.Encode([]rune(s)))), nil
+	}
+	return "", ErrUnsupportedCharset(cs)
+}
+
+// DecodeString converts a string in the character set into UTF-8.
+func DecodeString(s string, cs Charset) (string, error) {
+	switch cs {
+	case GSM, HEX:
+		u := []byte(s)
+		if cs == HEX {
+			var err error
+			if u, err = hex.DecodeString(s); err != nil {
+				return "", err
+			}
+		}
+		d, err := gsm7.Decode(u)
+		if err != nil {
+			return "", err
+		}
+		return string(d), nil
+	case IRA:
+		for _, r := range s {
+			if r >= utf8.RuneSelf {
+				return "", ErrUnencodable(r)
+			}
+		}
+		return s, nil
+	case UCS2:
+		u, err := hex.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		r, err := ucs2.Decode(u)
+		if err != nil {
+			return "", err
+		}
+		return string(r), nil
+	}
+	return "", ErrUnsupportedCharset(cs)
+}
+
+// EncodeText converts the user data of a TPDU into the text mode <data>.
+//
+// User data in the 7 bit alphabet, being unpacked septets, is converted into
+// the character set, while 8 bit and UCS2 user data are always represented in
+// hex.
+func EncodeText(ud tpdu.UserData, alpha tpdu.Alphabet, cs Charset) (string, error) {
+	if alpha != tpdu.Alpha7Bit {
+		return strings.ToUpper(hex.EncodeToString(ud)), nil
+	}
+	switch cs {
+	case GSM:
+		return string(ud), nil
+	case HEX:
+		return strings.ToUpper(hex.EncodeToString(ud)), nil
+	}
+	d, err := gsm7.Decode(ud)
+	if err != nil {
+		return "", err
+	}
+	return EncodeString(string(d), cs)
+}
+
+// DecodeText converts the text mode <data> into TPDU user data.
+//
+// This is the inverse of EncodeText.
+func DecodeText(s string, alpha tpdu.Alphabet, cs Charset) (tpdu.UserData, error) {
+	var ud []byte
+	var err error
+	switch {
+	case alpha != tpdu.Alpha7Bit, cs == HEX:
+		ud, err = hex.DecodeString(s)
+	case cs == GSM:
+		ud = []byte(s)
+	default:
+		var d string
+		if d, err = DecodeString(s, cs); err == nil {
+			ud, err = gsm7.Encode([]byte(d))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tpdu.UserData(ud), nil
+}
@@ -0,0 +1,15 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package textmode provides functions to translate between TPDUs and the
+// parameters and response lines exchanged with a GSM modem in text mode
+// (AT+CMGF=1), as per 3GPP TS 27.005 Section 3.
+//
+// Outgoing TPDUs are split into the AT+CSMP parameters and the destination and
+// text of the AT+CMGS command, while incoming +CMT and +CMGR responses are
+// converted back into TPDUs.
+//
+// Strings are converted to and from the TE character set selected by AT+CSCS,
+// which may be GSM, IRA, HEX or UCS2.
+package textmode
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package textmode
+
+import "fmt"
+
+// ErrInvalidHeader indicates a response line could not be parsed.
+type ErrInvalidHeader string
+
+func (e ErrInvalidHeader) Error() string {
+	return fmt.Sprintf("textmode: invalid header: %q", string(e))
+}
+
+// ErrInvalidTime indicates a time string is not in the
+// "yy/MM/dd,hh:mm:ss±zz" format.
+type ErrInvalidTime string
+
+func (e ErrInvalidTime) Error() string {
+	return fmt.Sprintf("textmode: invalid time: %q", string(e))
+}
+
+// ErrLengthMismatch indicates the <length> in a response header does not
+// match the length of the data that follows it.
+type ErrLengthMismatch struct {
+	// Header is the length indicated in the header.
+	Header int
+
+	// Data is the length of the data, in characters.
+	Data int
+}
+
+func (e ErrLengthMismatch) Error() string {
+	return fmt.Sprintf("textmode: header length %d does not match data length %d",
+		e.Header, e.Data)
+}
+
+// ErrUnencodable indicates a character cannot be represented in the selected
+// character set.
+type ErrUnencodable rune
+
+func (e ErrUnencodable) Error() string {
+	return fmt.Sprintf("textmode: unencodable character: %q", rune(e))
+}
+
+// ErrUnsupportedCharset indicates the TE character set is not supported.
+type ErrUnsupportedCharset string
+
+func (e ErrUnsupportedCharset) Error() string {
+	return fmt.Sprintf("textmode: unsupported character set: %q", string(e))
+}
@@ -0,0 +1,230 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package textmode
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// CMT is the unsolicited result indicating a received SMS-DELIVER in text
+// mode.
+//
+// +CMT: <oa>,[<alpha>],<scts>[,<tooa>,<fo>,<pid>,<dcs>,<sca>,<tosca>,<length>]<CR><LF><data>
+type CMT struct {
+	Alpha string
+
+	// SCA is the address of the SMSC, if provided in the header.
+	SCA pdumode.SMSCAddress
+
+	// Length is the length of the data, if provided in the header.
+	Length int
+
+	TPDU *tpdu.TPDU
+}
+
+// CMGR is the response to AT+CMGR in text mode for a received message.
+//
+// +CMGR: <stat>,<oa>,[<alpha>],<scts>[,<tooa>,<fo>,<pid>,<dcs>,<sca>,<tosca>,<length>]<CR><LF><data>
+type CMGR struct {
+	Stat pdumode.Stat
+
+	Alpha string
+
+	// SCA is the address of the SMSC, if provided in the header.
+	SCA pdumode.SMSCAddress
+
+	// Length is the length of the data, if provided in the header.
+	Length int
+
+	TPDU *tpdu.TPDU
+}
+
+// deliver contains the fields common to the +CMT and +CMGR responses.
+type deliver struct {
+	alpha  string
+	sca    pdumode.SMSCAddress
+	length int
+	tpdu   *tpdu.TPDU
+}
+
+// ParseCMT parses the +CMT unsolicited result, consisting of the header line
+// and the following data line, into an SMS-DELIVER TPDU.
+//
+// The cs is the TE character set used to encode the strings in the header and
+// the data.
+func ParseCMT(header, data string, cs Charset) (*CMT, error) {
+	f, err := headerFields(header, "+CMT:", 3)
+	if err != nil {
+		return nil, err
+	}
+	d, err := parseDeliver(f, header, data, cs)
+	if err != nil {
+		return nil, err
+	}
+	return &CMT{Alpha: d.alpha, SCA: d.sca, Length: d.length, TPDU: d.tpdu}, nil
+}
+
+// ParseCMGR parses the response to AT+CMGR, consisting of the header line and
+// the following data line, into an SMS-DELIVER TPDU.
+//
+// Only received messages, i.e. those with a REC READ or REC UNREAD stat, are
+// supported.
+//
+// The cs is the TE character set used to encode the strings in the header and
+// the data.
+func ParseCMGR(header, data string, cs Charset) (*CMGR, error) {
+	f, err := headerFields(header, "+CMGR:", 4)
+	if err != nil {
+		return nil, err
+	}
+	var stat pdumode.Stat
+	switch f[0] {
+	case pdumode.StatRecUnread.String():
+		stat = pdumode.StatRecUnread
+	case pdumode.StatRecRead.String():
+		stat = pdumode.StatRecRead
+	default:
+		return nil, ErrInvalidHeader(header)
+	}
+	d, err := parseDeliver(f[1:], header, data, cs)
+	if err != nil {
+		return nil, err
+	}
+	return &CMGR{Stat: stat, Alpha: d.alpha, SCA: d.sca, Length: d.length, TPDU: d.tpdu}, nil
+}
+
+// parseDeliver parses the fields of a received message header, from the <oa>
+// onwards, and the data that follows it.
+//
+// If the header only contains the <oa>, <alpha> and <scts> then the TPDU
+// parameters are assumed to be the defaults, i.e. the 7 bit alphabet.
+func parseDeliver(f []string, header, data string, cs Charset) (*deliver, error) {
+	if len(f) != 3 && len(f) != 10 {
+		return nil, ErrInvalidHeader(header)
+	}
+	d := deliver{length: -1}
+	oa, err := DecodeString(f[0], cs)
+	if err != nil {
+		return nil, ErrInvalidHeader(header)
+	}
+	if d.alpha, err = DecodeString(f[1], cs); err != nil {
+		return nil, ErrInvalidHeader(header)
+	}
+	scts, err := ParseTime(f[2])
+	if err != nil {
+		return nil, ErrInvalidHeader(header)
+	}
+	t := &tpdu.TPDU{
+		Direction: tpdu.MT,
+		OA:        tpdu.NewAddress(tpdu.FromNumber(oa)),
+		SCTS:      tpdu.Timestamp{Time: scts},
+	}
+	if !strings.HasPrefix(oa, "+") {
+		t.OA.SetTypeOfNumber(tpdu.TonUnknown)
+	}
+	if len(f) == 10 {
+		var o [6]byte // tooa, fo, pid, dcs, sca (unused), tosca
+		for i, s := range f[3:9] {
+			if i == 4 {
+				continue
+			}
+			v, err := strconv.ParseUint(s, 10, 8)
+			if err != nil {
+				return nil, ErrInvalidHeader(header)
+			}
+			o[i] = byte(v)
+		}
+		t.OA.TOA = o[0]
+		if t.OA.TypeOfNumber() == tpdu.TonAlphanumeric {
+			t.OA.Addr = oa
+		}
+		t.FirstOctet = tpdu.FirstOctet(o[1])
+		t.PID = o[2]
+		t.DCS = tpdu.DCS(o[3])
+		sca, err := DecodeString(f[7], cs)
+		if err != nil {
+			return nil, ErrInvalidHeader(header)
+		}
+		if len(sca) > 0 {
+			d.sca.TOA = o[5]
+			d.sca.Addr = strings.TrimPrefix(sca, "+")
+		}
+		l, err := strconv.Atoi(f[9])
+		if err != nil || l < 0 {
+			return nil, ErrInvalidHeader(header)
+		}
+		d.length = l
+		if t.SmsType() != tpdu.SmsDeliver {
+			return nil, ErrInvalidHeader(header)
+		}
+	}
+	alpha, err := t.Alphabet()
+	if err != nil {
+		return nil, err
+	}
+	var n int
+	if t.UDHI() {
+		t, n, err = decodeUserData(t, data)
+	} else {
+		t.UD, err = DecodeText(data, alpha, cs)
+		n = len(t.UD)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.length < 0 {
+		d.length = 0
+	} else if d.length != n {
+		return nil, ErrLengthMismatch{Header: d.length, Data: n}
+	}
+	d.tpdu = t
+	return &d, nil
+}
+
+// decodeUserData decodes the hex user data, including the UDH, into the TPDU.
+//
+// This is performed by marshalling the TPDU without user data, appending
+// the user data to that, then unmarshalling the result.
+//
+// Returns the updated TPDU and the number of octets of user data.
+//
+// As the number of septets cannot be determined from the packed user data, a
+// 7 bit message that ends on an octet boundary may gain a trailing '@'.
+func decodeUserData(t *tpdu.TPDU, data string) (*tpdu.TPDU, int, error) {
+	ud, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return nil, 0, err
+	}
+	udl := len(ud)
+	if alpha, _ := t.Alphabet(); alpha == tpdu.Alpha7Bit {
+		udl = udl * 8 / 7
+	}
+	b[len(b)-1] = byte(udl)
+	b = append(b, ud...)
+	r := tpdu.TPDU{Direction: tpdu.MT}
+	if err = r.UnmarshalBinary(b); err != nil {
+		return nil, 0, err
+	}
+	return &r, len(ud), nil
+}
+
+// headerFields strips the prefix from the header and splits the remainder
+// into its comma separated fields, as per pdumode.HeaderFields.
+func headerFields(header, prefix string, min int) ([]string, error) {
+	f, err := pdumode.HeaderFields(header, prefix, min)
+	if err != nil {
+		return nil, ErrInvalidHeader(header)
+	}
+	return f, nil
+}
@@ -0,0 +1,120 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package textmode
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Submit contains the text mode parameters required to send an SMS-SUBMIT.
+//
+// The FO, VP, PID and DCS are set using AT+CSMP, then the DA, TODA and Text
+// are sent using AT+CMGS.
+type Submit struct {
+	// FO is the first octet of the TPDU.
+	FO byte
+
+	// VP is the validity period, formatted for AT+CSMP.
+	//
+	// It is empty if no VP is present, an integer in relative format, a
+	// quoted time in absolute format, and quoted hex in enhanced format.
+	VP string
+
+	// PID is the TP-PID.
+	PID byte
+
+	// DCS is the TP-DCS.
+	DCS byte
+
+	// DA is the destination address, in the TE character set.
+	DA string
+
+	// TODA is the type of the destination address.
+	TODA byte
+
+	// Text is the message body, as per EncodeText.
+	//
+	// If the TPDU has a UDH then the Text is the hex of the complete user
+	// data, including the UDH.
+	Text string
+}
+
+// NewSubmit converts an SMS-SUBMIT TPDU into the equivalent text mode
+// parameters.
+//
+// The TP-MR is not carried as it is assigned by the modem.
+func NewSubmit(t *tpdu.TPDU, cs Charset) (*Submit, error) {
+	if st := t.SmsType(); st != tpdu.SmsSubmit {
+		return nil, tpdu.ErrUnsupportedSmsType(st)
+	}
+	da, err := EncodeString(t.DA.Number(), cs)
+	if err != nil {
+		return nil, err
+	}
+	s := Submit{
+		FO:   byte(t.FirstOctet),
+		PID:  t.PID,
+		DCS:  byte(t.DCS),
+		DA:   da,
+		TODA: t.DA.TOA,
+	}
+	var vp []byte
+	if t.VP.Format != tpdu.VpfNotPresent {
+		vp, err = t.VP.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		switch t.VP.Format {
+		case tpdu.VpfRelative:
+			s.VP = strconv.Itoa(int(vp[0]))
+		case tpdu.VpfAbsolute:
+			s.VP = "\"" + FormatTime(t.VP.Time.Time) + "\""
+		case tpdu.VpfEnhanced:
+			s.VP = "\"" + strings.ToUpper(hex.EncodeToString(vp)) + "\""
+		}
+	}
+	if !t.UDHI() {
+		alpha, err := t.Alphabet()
+		if err != nil {
+			return nil, err
+		}
+		if s.Text, err = EncodeText(t.UD, alpha, cs); err != nil {
+			return nil, err
+		}
+		return &s, nil
+	}
+	// the user data, including the UDH, is the tail of the binary TPDU,
+	// following the UDL.
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	dab, err := t.DA.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	udl := 4 + len(dab) + len(vp) // fo, mr, da, pid, dcs, vp
+	s.Text = strings.ToUpper(hex.EncodeToString(b[udl+1:]))
+	return &s, nil
+}
+
+// CSMPCommand returns the AT+CSMP command that sets the parameters of the
+// SMS-SUBMIT.
+func (s *Submit) CSMPCommand() string {
+	return fmt.Sprintf("AT+CSMP=%d,%s,%d,%d", s.FO, s.VP, s.PID, s.DCS)
+}
+
+// CMGSCommand returns the AT+CMGS command that sends the SMS-SUBMIT.
+//
+// The command is followed by the Text, terminated by Ctrl-Z, once the modem
+// prompts for it.
+func (s *Submit) CMGSCommand() string {
+	return fmt.Sprintf("AT+CMGS=\"%s\",%d", s.DA, s.TODA)
+}
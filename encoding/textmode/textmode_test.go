@@ -0,0 +1,284 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package textmode_test
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/textmode"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+const (
+	// SMS-DELIVER with SMSC address, as used in the pdumode tests
+	deliverPDU = "07911614786007F0040B911624214365F700000290901071142302C834"
+
+	// the same SMS-DELIVER in text mode
+	deliverHeader = "+CMT: \"+61421234567\",,\"20/09/09,01:17:41+32\",145,4,0,0,\"+61418706700\",145,2"
+)
+
+func mustDecodePDU(t *testing.T, s string) (*pdumode.PDU, *tpdu.TPDU) {
+	t.Helper()
+	p, err := pdumode.UnmarshalHexString(s)
+	require.Nil(t, err)
+	d := tpdu.TPDU{Direction: tpdu.MT}
+	err = d.UnmarshalBinary(p.TPDU)
+	require.Nil(t, err)
+	return p, &d
+}
+
+func TestCharset(t *testing.T) {
+	patterns := []struct {
+		name string
+		cs   textmode.Charset
+		in   string
+		out  string
+		err  error
+	}{
+		{"gsm", textmode.GSM, "a@€", "a\x00\x1b\x65", nil},
+		{"ira", textmode.IRA, "+61", "+61", nil},
+		{"ira unencodable", textmode.IRA, "é", "", textmode.ErrUnencodable('é')},
+		{"hex", textmode.HEX, "a@", "6100", nil},
+		{"ucs2", textmode.UCS2, "+6€", "002B003620AC", nil},
+		{"unsupported", textmode.Charset("8859-1"), "a", "", textmode.ErrUnsupportedCharset("8859-1")},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			out, err := textmode.EncodeString(p.in, p.cs)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, out)
+			if err != nil {
+				return
+			}
+			in, err := textmode.DecodeString(out, p.cs)
+			assert.Nil(t, err)
+			assert.Equal(t, p.in, in)
+		}
+		t.Run(p.name, f)
+	}
+	_, err := textmode.DecodeString("0x", textmode.HEX)
+	assert.Equal(t, hex.InvalidByteError('x'), err)
+	_, err = textmode.DecodeString("D800", textmode.UCS2)
+	assert.Equal(t, ucs2.ErrDanglingSurrogate([]byte{0xd8, 0x00}), err)
+	_, err = textmode.DecodeString("é", textmode.IRA)
+	assert.Equal(t, textmode.ErrUnencodable('é'), err)
+	assert.Equal(t, "AT+CSCS=\"UCS2\"", textmode.UCS2.CSCSCommand())
+}
+
+func TestText(t *testing.T) {
+	hi, err := gsm7.Encode([]byte("hi€"))
+	require.Nil(t, err)
+	patterns := []struct {
+		name  string
+		alpha tpdu.Alphabet
+		cs    textmode.Charset
+		ud    tpdu.UserData
+		out   string
+	}{
+		{"7bit gsm", tpdu.Alpha7Bit, textmode.GSM, hi, "hi\x1b\x65"},
+		{"7bit hex", tpdu.Alpha7Bit, textmode.HEX, hi, "68691B65"},
+		{"7bit ira", tpdu.Alpha7Bit, textmode.IRA, tpdu.UserData("hi"), "hi"},
+		{"7bit ucs2", tpdu.Alpha7Bit, textmode.UCS2, hi, "0068006920AC"},
+		{"8bit", tpdu.Alpha8Bit, textmode.GSM, tpdu.UserData{1, 0xfe}, "01FE"},
+		{"ucs2", tpdu.AlphaUCS2, textmode.IRA, tpdu.UserData{0x20, 0xac}, "20AC"},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			out, err := textmode.EncodeText(p.ud, p.alpha, p.cs)
+			assert.Nil(t, err)
+			assert.Equal(t, p.out, out)
+			ud, err := textmode.DecodeText(out, p.alpha, p.cs)
+			assert.Nil(t, err)
+			assert.Equal(t, p.ud, ud)
+		}
+		t.Run(p.name, f)
+	}
+	_, err = textmode.EncodeText(hi, tpdu.Alpha7Bit, textmode.IRA)
+	assert.Equal(t, textmode.ErrUnencodable('€'), err)
+	_, err = textmode.DecodeText("hé", tpdu.Alpha7Bit, textmode.IRA)
+	assert.Equal(t, textmode.ErrUnencodable('é'), err)
+}
+
+func TestTime(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		out  time.Time
+		err  error
+	}{
+		{"east", "20/09/09,01:17:41+32", time.Date(2020, 9, 9, 1, 17, 41, 0, time.FixedZone("SCTS", 8*3600)), nil},
+		{"west", "99/12/31,23:59:59-14", time.Date(1999, 12, 31, 23, 59, 59, 0, time.FixedZone("SCTS", -14*900)), nil},
+		{"utc", "21/01/02,03:04:05+00", time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), nil},
+		{"no zone", "21/01/02,03:04:05", time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), nil},
+		{"short", "21/01/02,03:04", time.Time{}, textmode.ErrInvalidTime("21/01/02,03:04")},
+		{"separator", "21/01/02 03:04:05+00", time.Time{}, textmode.ErrInvalidTime("21/01/02 03:04:05+00")},
+		{"digits", "21/0x/02,03:04:05+00", time.Time{}, textmode.ErrInvalidTime("21/0x/02,03:04:05+00")},
+		{"zone sign", "21/01/02,03:04:05 00", time.Time{}, textmode.ErrInvalidTime("21/01/02,03:04:05 00")},
+		{"range", "21/02/30,03:04:05+00", time.Time{}, textmode.ErrInvalidTime("21/02/30,03:04:05+00")},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			out, err := textmode.ParseTime(p.in)
+			assert.Equal(t, p.err, err)
+			assert.True(t, p.out.Equal(out))
+			if err == nil && len(p.in) == 20 {
+				assert.Equal(t, p.in, textmode.FormatTime(out))
+			}
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestNewSubmit(t *testing.T) {
+	s, err := tpdu.NewSubmit()
+	require.Nil(t, err)
+	s.DA = tpdu.NewAddress(tpdu.FromNumber("+61412345678"))
+	s.UD = tpdu.UserData("hello")
+	vp := tpdu.ValidityPeriod{}
+	vp.SetRelative(24 * time.Hour)
+	s.SetVP(vp)
+
+	r, err := textmode.NewSubmit(s, textmode.IRA)
+	require.Nil(t, err)
+	assert.Equal(t, &textmode.Submit{FO: 0x11, VP: "167", DA: "+61412345678", TODA: 0x91, Text: "hello"}, r)
+	assert.Equal(t, "AT+CSMP=17,167,0,0", r.CSMPCommand())
+	assert.Equal(t, "AT+CMGS=\"+61412345678\",145", r.CMGSCommand())
+
+	// absolute vp and ucs2
+	vp.SetAbsolute(tpdu.Timestamp{Time: time.Date(2020, 9, 9, 1, 17, 41, 0, time.FixedZone("", -3600))})
+	s.SetVP(vp)
+	s.SetDCS(byte(tpdu.Dcs8BitData&^0x04 | 0x08))
+	s.UD = ucs2.Encode([]rune("€"))
+	r, err = textmode.NewSubmit(s, textmode.UCS2)
+	require.Nil(t, err)
+	assert.Equal(t, "AT+CSMP=25,\"20/09/09,01:17:41-04\",0,8", r.CSMPCommand())
+	assert.Equal(t, "AT+CMGS=\"002B00360031003400310032003300340035003600370038\",145", r.CMGSCommand())
+	assert.Equal(t, "20AC", r.Text)
+
+	// enhanced vp
+	vp.SetEnhanced(time.Minute, byte(tpdu.EvpfRelativeSeconds))
+	s.SetVP(vp)
+	r, err = textmode.NewSubmit(s, textmode.IRA)
+	require.Nil(t, err)
+	assert.Equal(t, "\"023C0000000000\"", r.VP)
+
+	// no vp
+	s.SetVP(tpdu.ValidityPeriod{})
+	r, err = textmode.NewSubmit(s, textmode.IRA)
+	require.Nil(t, err)
+	assert.Equal(t, "AT+CSMP=1,,0,8", r.CSMPCommand())
+
+	// unencodable da
+	_, err = textmode.NewSubmit(s, textmode.Charset("8859-1"))
+	assert.Equal(t, textmode.ErrUnsupportedCharset("8859-1"), err)
+
+	// not a submit
+	d, err := tpdu.NewDeliver()
+	require.Nil(t, err)
+	_, err = textmode.NewSubmit(d, textmode.IRA)
+	assert.Equal(t, tpdu.ErrUnsupportedSmsType(tpdu.SmsDeliver), err)
+}
+
+func TestUDH(t *testing.T) {
+	s, err := tpdu.NewSubmit()
+	require.Nil(t, err)
+	s.DA = tpdu.NewAddress(tpdu.FromNumber("+61412345678"))
+	udh := tpdu.UserDataHeader{tpdu.InformationElement{ID: 0, Data: []byte{1, 2, 1}}}
+	s.SetUDH(udh)
+	s.UD = tpdu.UserData("hi")
+	r, err := textmode.NewSubmit(s, textmode.GSM)
+	require.Nil(t, err)
+	assert.Equal(t, "050003010201D069", r.Text)
+
+	// the same user data in a received message
+	header := "+CMT: \"+61421234567\",,\"20/09/09,01:17:41+32\",145,68,0,0,\"\",145,8"
+	c, err := textmode.ParseCMT(header, r.Text, textmode.GSM)
+	require.Nil(t, err)
+	assert.True(t, c.TPDU.UDHI())
+	assert.Equal(t, udh, c.TPDU.UDH)
+	assert.Equal(t, s.UD, c.TPDU.UD)
+	assert.Equal(t, 8, c.Length)
+	assert.Equal(t, pdumode.SMSCAddress{}, c.SCA)
+
+	_, err = textmode.ParseCMT(header, "0x", textmode.GSM)
+	assert.Equal(t, hex.InvalidByteError('x'), err)
+}
+
+func TestParseCMT(t *testing.T) {
+	p, d := mustDecodePDU(t, deliverPDU)
+	short := *d
+	short.FirstOctet = 0
+	alpha := *d
+	alpha.OA = tpdu.Address{TOA: 0xd0, Addr: "Alice"}
+	patterns := []struct {
+		name   string
+		header string
+		data   string
+		cs     textmode.Charset
+		out    *textmode.CMT
+		err    error
+	}{
+		{"full", deliverHeader, "Hi", textmode.GSM,
+			&textmode.CMT{SCA: p.SMSC, Length: 2, TPDU: d}, nil},
+		{"short", "+CMT: \"+61421234567\",\"Bob\",\"20/09/09,01:17:41+32\"", "Hi", textmode.IRA,
+			&textmode.CMT{Alpha: "Bob", TPDU: &short}, nil},
+		{"ucs2", "+CMT: \"002B00360031003400320031003200330034003500360037\",\"0042\",\"20/09/09,01:17:41+32\"",
+			"00480069", textmode.UCS2,
+			&textmode.CMT{Alpha: "B", TPDU: &short}, nil},
+		{"alphanumeric", "+CMT: \"Alice\",,\"20/09/09,01:17:41+32\",208,4,0,0,\"+61418706700\",145,2", "Hi", textmode.IRA,
+			&textmode.CMT{SCA: p.SMSC, Length: 2, TPDU: &alpha}, nil},
+		{"prefix", "+CMGR: ,,", "hi", textmode.IRA, nil, textmode.ErrInvalidHeader("+CMGR: ,,")},
+		{"fields", "+CMT: \"+61421234567\",,\"20/09/09,01:17:41+32\",145", "hi", textmode.IRA,
+			nil, textmode.ErrInvalidHeader("+CMT: \"+61421234567\",,\"20/09/09,01:17:41+32\",145")},
+		{"scts", "+CMT: \"+61421234567\",,\"20/09/09\"", "hi", textmode.IRA,
+			nil, textmode.ErrInvalidHeader("+CMT: \"+61421234567\",,\"20/09/09\"")},
+		{"oa", "+CMT: \"+6x\",,\"20/09/09,01:17:41+32\"", "hi", textmode.HEX,
+			nil, textmode.ErrInvalidHeader("+CMT: \"+6x\",,\"20/09/09,01:17:41+32\"")},
+		{"tooa", "+CMT: \"+61421234567\",,\"20/09/09,01:17:41+32\",256,4,0,0,,,2", "hi", textmode.IRA,
+			nil, textmode.ErrInvalidHeader("+CMT: \"+61421234567\",,\"20/09/09,01:17:41+32\",256,4,0,0,,,2")},
+		{"fo", "+CMT: \"+61421234567\",,\"20/09/09,01:17:41+32\",145,1,0,0,,0,2", "hi", textmode.IRA,
+			nil, textmode.ErrInvalidHeader("+CMT: \"+61421234567\",,\"20/09/09,01:17:41+32\",145,1,0,0,,0,2")},
+		{"length", "+CMT: \"+61421234567\",,\"20/09/09,01:17:41+32\",145,4,0,0,,0,x", "hi", textmode.IRA,
+			nil, textmode.ErrInvalidHeader("+CMT: \"+61421234567\",,\"20/09/09,01:17:41+32\",145,4,0,0,,0,x")},
+		{"mismatch", deliverHeader, "hi!", textmode.GSM, nil, textmode.ErrLengthMismatch{Header: 2, Data: 3}},
+		{"data", deliverHeader, "hé", textmode.IRA, nil, textmode.ErrUnencodable('é')},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := textmode.ParseCMT(p.header, p.data, p.cs)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, r)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestParseCMGR(t *testing.T) {
+	p, d := mustDecodePDU(t, deliverPDU)
+	header := "+CMGR: \"REC READ\"," + deliverHeader[len("+CMT: "):]
+	r, err := textmode.ParseCMGR(header, "Hi", textmode.GSM)
+	require.Nil(t, err)
+	assert.Equal(t, &textmode.CMGR{Stat: pdumode.StatRecRead, SCA: p.SMSC, Length: 2, TPDU: d}, r)
+
+	header = "+CMGR: \"REC UNREAD\",\"+61421234567\",,\"20/09/09,01:17:41+32\""
+	r, err = textmode.ParseCMGR(header, "Hi", textmode.GSM)
+	require.Nil(t, err)
+	assert.Equal(t, pdumode.StatRecUnread, r.Stat)
+	assert.Equal(t, d.UD, r.TPDU.UD)
+
+	header = "+CMGR: \"STO UNSENT\",\"+61421234567\",,\"20/09/09,01:17:41+32\""
+	_, err = textmode.ParseCMGR(header, "hi", textmode.GSM)
+	assert.Equal(t, textmode.ErrInvalidHeader(header), err)
+
+	_, err = textmode.ParseCMGR("+CMGR: \"REC READ\"", "hi", textmode.GSM)
+	assert.Equal(t, textmode.ErrInvalidHeader("+CMGR: \"REC READ\""), err)
+}
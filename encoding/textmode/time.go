@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package textmode
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// FormatTime formats a time in the "yy/MM/dd,hh:mm:ss±zz" format used for the
+// <scts> and absolute <vp> fields.
+//
+// The zone is expressed in quarters of an hour.
+func FormatTime(t time.Time) string {
+	_, tz := t.Zone()
+	sign := '+'
+	if tz < 0 {
+		sign = '-'
+		tz = -tz
+	}
+	return fmt.Sprintf("%02d/%02d/%02d,%02d:%02d:%02d%c%02d",
+		t.Year()%100, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(),
+		sign, tz/(15*60))
+}
+
+// ParseTime parses a time in the "yy/MM/dd,hh:mm:ss±zz" format used for the
+// <scts> and absolute <vp> fields.
+//
+// The zone is optional, and if absent the time is assumed to be UTC.
+// As with the binary SCTS, years before 70 are assumed to be in the 21st
+// century.
+func ParseTime(s string) (time.Time, error) {
+	if len(s) != 17 && len(s) != 20 {
+		return time.Time{}, ErrInvalidTime(s)
+	}
+	var f [6]int
+	for i := range f {
+		o := i * 3
+		if i < 5 && s[o+2] != "//,::"[i] {
+			return time.Time{}, ErrInvalidTime(s)
+		}
+		v, err := strconv.ParseUint(s[o:o+2], 10, 8)
+		if err != nil {
+			return time.Time{}, ErrInvalidTime(s)
+		}
+		f[i] = int(v)
+	}
+	loc := time.UTC
+	if len(s) == 20 {
+		tz, err := strconv.ParseInt(s[17:], 10, 8)
+		if err != nil || (s[17] != '+' && s[17] != '-') {
+			return time.Time{}, ErrInvalidTime(s)
+		}
+		if tz != 0 {
+			loc = time.FixedZone("SCTS", int(tz)*15*60)
+		}
+	}
+	year := f[0] + 1900
+	if f[0] < 70 {
+		year += 100
+	}
+	t := time.Date(year, time.Month(f[1]), f[2], f[3], f[4], f[5], 0, loc)
+	if t.Month() != time.Month(f[1]) || t.Day() != f[2] || t.Hour() != f[3] ||
+		t.Minute() != f[4] || t.Second() != f[5] {
+		return time.Time{}, ErrInvalidTime(s)
+	}
+	return t, nil
+}
@@ -56,3 +56,53 @@ func (o UDHOption) ApplyTPDUOption(t *TPDU) error {
 func WithUDH(udh UserDataHeader) UDHOption {
 	return UDHOption{udh}
 }
+
+// FCSOption specifies the FCS for the TPDU.
+type FCSOption struct {
+	fcs byte
+}
+
+// ApplyTPDUOption applies the FCS to the TPDU.
+func (o FCSOption) ApplyTPDUOption(t *TPDU) error {
+	t.FCS = o.fcs
+	return nil
+}
+
+// WithFCS creates a FCSOption to apply to a TPDU.
+func WithFCS(fcs byte) FCSOption {
+	return FCSOption{fcs}
+}
+
+// PIDOption specifies the PID for the TPDU.
+type PIDOption struct {
+	pid byte
+}
+
+// ApplyTPDUOption applies the PID to the TPDU, and sets the corresponding bit
+// of the PI.
+func (o PIDOption) ApplyTPDUOption(t *TPDU) error {
+	t.SetPID(o.pid)
+	return nil
+}
+
+// WithPID creates a PIDOption to apply to a TPDU.
+func WithPID(pid byte) PIDOption {
+	return PIDOption{pid}
+}
+
+// UDOption specifies the UD for the TPDU.
+type UDOption struct {
+	ud UserData
+}
+
+// ApplyTPDUOption applies the UD to the TPDU, and sets the corresponding bit
+// of the PI.
+func (o UDOption) ApplyTPDUOption(t *TPDU) error {
+	t.SetUD(o.ud)
+	return nil
+}
+
+// WithUD creates a UDOption to apply to a TPDU.
+func WithUD(ud UserData) UDOption {
+	return UDOption{ud}
+}
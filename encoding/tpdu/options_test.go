@@ -47,3 +47,24 @@ func TestWithDirection(t *testing.T) {
 	require.Nil(t, err)
 	assert.Equal(t, tpdu.MO, s.Direction)
 }
+
+func TestWithFCS(t *testing.T) {
+	s, err := tpdu.New(tpdu.WithFCS(0xd3))
+	require.Nil(t, err)
+	assert.Equal(t, byte(0xd3), s.FCS)
+	assert.Equal(t, tpdu.PI(0), s.PI)
+}
+
+func TestWithPID(t *testing.T) {
+	s, err := tpdu.New(tpdu.WithPID(0x41))
+	require.Nil(t, err)
+	assert.Equal(t, byte(0x41), s.PID)
+	assert.Equal(t, tpdu.PI(tpdu.PiPID), s.PI)
+}
+
+func TestWithUD(t *testing.T) {
+	s, err := tpdu.New(tpdu.WithUD(tpdu.UserData("report")))
+	require.Nil(t, err)
+	assert.Equal(t, tpdu.UserData("report"), s.UD)
+	assert.Equal(t, tpdu.PI(tpdu.PiUDL), s.PI)
+}
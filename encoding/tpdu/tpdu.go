@@ -130,6 +130,20 @@ func NewDeliver(options ...Option) (*TPDU, error) {
 	return New(options...)
 }
 
+// NewDeliverReport creates a new TPDU of type SmsDeliverReport.
+//
+// The report is carried in an RP-ACK if the FCS is zero, and in an RP-ERROR
+// otherwise, e.g.
+//
+//	NewDeliverReport(WithFCS(0xd3)) // memory capacity exceeded
+//
+// The optional PID, DCS and UD are set with the WithPID, DCS and WithUD
+// options, which also set the corresponding bits of the PI.
+func NewDeliverReport(options ...Option) (*TPDU, error) {
+	options = append([]Option{SmsDeliverReport}, options...)
+	return New(options...)
+}
+
 // NewSubmit creates a new TPDU of type SmsSubmit.
 func NewSubmit(options ...Option) (*TPDU, error) {
 	options = append([]Option{SmsSubmit}, options...)
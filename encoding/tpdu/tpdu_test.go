@@ -59,6 +59,27 @@ func TestNewDeliver(t *testing.T) {
 	assert.Nil(t, s)
 }
 
+func TestNewDeliverReport(t *testing.T) {
+	s, err := tpdu.NewDeliverReport()
+	require.Nil(t, err)
+	assert.Equal(t, tpdu.SmsDeliverReport, s.SmsType())
+	b, err := s.MarshalBinary()
+	require.Nil(t, err)
+	assert.Equal(t, []byte{0x00, 0x00}, b)
+
+	s, err = tpdu.NewDeliverReport(tpdu.WithFCS(0xd3), tpdu.WithPID(0x41),
+		tpdu.DCS(0x04), tpdu.WithUD([]byte{1, 2}))
+	require.Nil(t, err)
+	b, err = s.MarshalBinary()
+	require.Nil(t, err)
+	assert.Equal(t, []byte{0x00, 0xd3, 0x07, 0x41, 0x04, 0x02, 0x01, 0x02}, b)
+
+	inerr := errors.New("failed TPDU option")
+	s, err = tpdu.NewDeliverReport(BadOption{inerr})
+	assert.Equal(t, inerr, err)
+	assert.Nil(t, s)
+}
+
 func TestNewSubmit(t *testing.T) {
 	s, err := tpdu.NewSubmit()
 	require.Nil(t, err)
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ussd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/cbsdcs"
+)
+
+// Status is the <m> field of the +CUSD result, indicating the state of the
+// USSD session.
+type Status int
+
+const (
+	// StatusDone indicates no further action is required.
+	StatusDone Status = iota
+
+	// StatusActionRequired indicates further action is required, i.e. the
+	// network expects a reply.
+	StatusActionRequired
+
+	// StatusTerminated indicates the session was terminated by the network.
+	StatusTerminated
+
+	// StatusOtherClient indicates another local client has responded.
+	StatusOtherClient
+
+	// StatusNotSupported indicates the operation is not supported.
+	StatusNotSupported
+
+	// StatusTimeout indicates the network timed out.
+	StatusTimeout
+)
+
+var statusNames = map[Status]string{
+	StatusDone:           "done",
+	StatusActionRequired: "action required",
+	StatusTerminated:     "terminated",
+	StatusOtherClient:    "other client responded",
+	StatusNotSupported:   "not supported",
+	StatusTimeout:        "timeout",
+}
+
+func (s Status) String() string {
+	if n, ok := statusNames[s]; ok {
+		return n
+	}
+	return strconv.Itoa(int(s))
+}
+
+// CUSD is the +CUSD result, returning a USSD response from the network.
+//
+// +CUSD: <m>[,<str>,<dcs>]
+type CUSD struct {
+	Status Status
+
+	// String is the USSD string, or nil if the result does not contain one.
+	String *String
+}
+
+// CancelCommand is the AT command that cancels the current USSD session.
+const CancelCommand = "AT+CUSD=2"
+
+// CUSDCommand returns the AT+CUSD command that sends the USSD string, with
+// the string in hex form.
+func CUSDCommand(s *String) string {
+	return fmt.Sprintf("AT+CUSD=1,\"%s\",%d", s.MarshalHexString(), int(s.DCS))
+}
+
+// ParseCUSD parses the +CUSD result, with the string in hex form.
+//
+// If the <dcs> is absent then the string is assumed to be GSM7 in an
+// unspecified language.
+func ParseCUSD(line string) (*CUSD, error) {
+	if !strings.HasPrefix(line, "+CUSD:") {
+		return nil, ErrInvalidResult(line)
+	}
+	f := strings.Split(strings.TrimSpace(line[len("+CUSD:"):]), ",")
+	if len(f) > 3 {
+		return nil, ErrInvalidResult(line)
+	}
+	m, err := strconv.Atoi(strings.TrimSpace(f[0]))
+	if err != nil || m < 0 {
+		return nil, ErrInvalidResult(line)
+	}
+	r := CUSD{Status: Status(m)}
+	if len(f) == 1 {
+		return &r, nil
+	}
+	str := strings.TrimSpace(f[1])
+	if len(str) < 2 || str[0] != '"' || str[len(str)-1] != '"' {
+		return nil, ErrInvalidResult(line)
+	}
+	dcs := cbsdcs.Unspecified
+	if len(f) == 3 {
+		d, err := strconv.Atoi(strings.TrimSpace(f[2]))
+		if err != nil || d < 0 || d > 255 {
+			return nil, ErrInvalidResult(line)
+		}
+		dcs = cbsdcs.DCS(d)
+	}
+	r.String = &String{}
+	if err := r.String.UnmarshalHexString(dcs, str[1:len(str)-1]); err != nil {
+		return nil, ErrInvalidResult(line)
+	}
+	return &r, nil
+}
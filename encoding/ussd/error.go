@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ussd
+
+import "fmt"
+
+// ErrInvalidResult indicates a +CUSD result could not be parsed.
+type ErrInvalidResult string
+
+func (e ErrInvalidResult) Error() string {
+	return fmt.Sprintf("ussd: invalid result '%s'", string(e))
+}
+
+// ErrOverlength indicates the encoded string is longer than MaxOctets.
+type ErrOverlength int
+
+func (e ErrOverlength) Error() string {
+	return fmt.Sprintf("ussd: encoded length %d exceeds %d", int(e), MaxOctets)
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package ussd provides encoding and decoding of USSD strings, as defined in
+// 3GPP TS 23.038, and the AT+CUSD command and result, as defined in 3GPP TS
+// 27.007 Section 7.15.
+package ussd
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/cbsdcs"
+)
+
+const (
+	// MaxOctets is the maximum length of an encoded USSD string.
+	MaxOctets = 160
+
+	// MaxSeptets is the maximum number of GSM7 characters in a USSD string.
+	MaxSeptets = MaxOctets * 8 / 7
+)
+
+// String is an encoded USSD string.
+type String struct {
+	// DCS is the data coding scheme, as defined in 3GPP TS 23.038 Section 5.
+	DCS cbsdcs.DCS
+
+	// Data is the encoded string, with GSM7 text packed as per 3GPP TS 23.038
+	// Section 6.1.2.3.
+	Data []byte
+}
+
+// Message is a decoded USSD string.
+type Message struct {
+	// Text is the text of the string.
+	Text string
+
+	// Language is the ISO 639 code of the language of the text, if known.
+	Language string
+}
+
+// Encode encodes the text as a USSD string.
+//
+// The text is encoded using the GSM7 default alphabet if possible, else UCS2.
+//
+// Returns an error if the encoded text is longer than MaxOctets.
+func Encode(text string, options ...cbsdcs.EncodeOption) (*String, error) {
+	cfg := cbsdcs.NewEncodeConfig(options...)
+	var s String
+	var err error
+	if cfg.UCS2 {
+		s.DCS, s.Data, err = cbsdcs.EncodeUCS2(text, cfg.Lang)
+	} else {
+		s.DCS, s.Data, err = cbsdcs.Encode(text, cfg.Lang)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Data) > MaxOctets {
+		return nil, ErrOverlength(len(s.Data))
+	}
+	return &s, nil
+}
+
+// Decode decodes the USSD string.
+func Decode(s *String) (*Message, error) {
+	text, lang, err := s.DCS.Decode(s.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Text: text, Language: lang}, nil
+}
+
+// MarshalHexString returns the data of the string in the hex form used by
+// AT+CUSD.
+func (s *String) MarshalHexString() string {
+	return strings.ToUpper(hex.EncodeToString(s.Data))
+}
+
+// UnmarshalHexString decodes the string from the hex form used by AT+CUSD.
+func (s *String) UnmarshalHexString(dcs cbsdcs.DCS, h string) error {
+	data, err := hex.DecodeString(h)
+	if err != nil {
+		return err
+	}
+	s.DCS = dcs
+	s.Data = data
+	return nil
+}
@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ussd_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/cbsdcs"
+	"github.com/warthog618/sms/encoding/ussd"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+func TestEncode(t *testing.T) {
+	patterns := []struct {
+		name    string
+		text    string
+		options []cbsdcs.EncodeOption
+		out     *ussd.String
+		err     error
+	}{
+		{"gsm7", "*100#", nil,
+			&ussd.String{DCS: 0x0f, Data: fixture.Hex(t, "AA180C3602")}, nil},
+		{"ucs2", "Привет", nil,
+			&ussd.String{DCS: 0x48, Data: fixture.Hex(t, "041F04400438043204350442")}, nil},
+		{"as ucs2", "Hi", []cbsdcs.EncodeOption{cbsdcs.AsUCS2},
+			&ussd.String{DCS: 0x48, Data: fixture.Hex(t, "00480069")}, nil},
+		{"language", "Hallo", []cbsdcs.EncodeOption{cbsdcs.WithLanguage("de")},
+			&ussd.String{DCS: 0x00, Data: fixture.Hex(t, "C8309BFD06")}, nil},
+		{"language indicated", "Ciao", []cbsdcs.EncodeOption{cbsdcs.WithLanguage("ja")},
+			&ussd.String{DCS: 0x10, Data: fixture.Hex(t, "EA7063980EBF1B")}, nil},
+		{"ucs2 language indicated", "Привет", []cbsdcs.EncodeOption{cbsdcs.WithLanguage("ru")},
+			&ussd.String{DCS: 0x11, Data: fixture.Hex(t, "F23A041F04400438043204350442")}, nil},
+		{"invalid language", "Ciao", []cbsdcs.EncodeOption{cbsdcs.WithLanguage("jpn")},
+			nil, cbsdcs.ErrInvalidLanguage("jpn")},
+		{"max septets", strings.Repeat("a", ussd.MaxSeptets), nil,
+			&ussd.String{DCS: 0x0f, Data: fixture.Hex(t, strings.Repeat("E170381C0E87C3", 23)[:318]+"03")}, nil},
+		{"overlength septets", strings.Repeat("a", ussd.MaxSeptets+1), nil,
+			nil, ussd.ErrOverlength(161)},
+		{"overlength ucs2", strings.Repeat("я", 81), nil,
+			nil, ussd.ErrOverlength(162)},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			s, err := ussd.Encode(p.text, p.options...)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, s)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   *ussd.String
+		out  *ussd.Message
+		err  error
+	}{
+		{"gsm7", &ussd.String{DCS: 0x0f, Data: fixture.Hex(t, "AA180C3602")},
+			&ussd.Message{Text: "*100#"}, nil},
+		{"cr filler", &ussd.String{DCS: 0x0f, Data: fixture.Hex(t, "31D98C56B3DD1A")},
+			&ussd.Message{Text: "1234567"}, nil},
+		{"language", &ussd.String{DCS: 0x01, Data: fixture.Hex(t, "C8329BFD06")},
+			&ussd.Message{Text: "Hello", Language: "en"}, nil},
+		{"language indicated", &ussd.String{DCS: 0x10, Data: fixture.Hex(t, "EA7063980EBF1B")},
+			&ussd.Message{Text: "Ciao", Language: "ja"}, nil},
+		{"ucs2", &ussd.String{DCS: 0x48, Data: fixture.Hex(t, "041F04400438043204350442")},
+			&ussd.Message{Text: "Привет"}, nil},
+		{"reserved", &ussd.String{DCS: 0xa0, Data: fixture.Hex(t, "00")},
+			nil, cbsdcs.ErrReserved},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m, err := ussd.Decode(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, m)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestHexString(t *testing.T) {
+	s := ussd.String{DCS: 0x0f, Data: []byte{0xaa, 0x18, 0x0c, 0x36, 0x02}}
+	assert.Equal(t, "AA180C3602", s.MarshalHexString())
+
+	var r ussd.String
+	err := r.UnmarshalHexString(0x0f, "aa180C3602")
+	assert.Nil(t, err)
+	assert.Equal(t, s, r)
+
+	err = r.UnmarshalHexString(0x0f, "zz")
+	assert.Equal(t, hex.InvalidByteError('z'), err)
+}
+
+func TestCUSDCommand(t *testing.T) {
+	s, err := ussd.Encode("*100#")
+	require.Nil(t, err)
+	assert.Equal(t, "AT+CUSD=1,\"AA180C3602\",15", ussd.CUSDCommand(s))
+	s, err = ussd.Encode("Hi", cbsdcs.AsUCS2)
+	require.Nil(t, err)
+	assert.Equal(t, "AT+CUSD=1,\"00480069\",72", ussd.CUSDCommand(s))
+	assert.Equal(t, "AT+CUSD=2", ussd.CancelCommand)
+}
+
+func TestParseCUSD(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		out  *ussd.CUSD
+		err  error
+	}{
+		{"status", "+CUSD: 2", &ussd.CUSD{Status: ussd.StatusTerminated}, nil},
+		{"string", "+CUSD: 1,\"AA180C3602\",15", &ussd.CUSD{
+			Status: ussd.StatusActionRequired,
+			String: &ussd.String{DCS: 0x0f, Data: fixture.Hex(t, "AA180C3602")}}, nil},
+		{"ucs2", "+CUSD: 0,\"00480069\",72", &ussd.CUSD{
+			Status: ussd.StatusDone,
+			String: &ussd.String{DCS: 0x48, Data: fixture.Hex(t, "00480069")}}, nil},
+		{"no dcs", "+CUSD: 0,\"AA180C3602\"", &ussd.CUSD{
+			Status: ussd.StatusDone,
+			String: &ussd.String{DCS: 0x0f, Data: fixture.Hex(t, "AA180C3602")}}, nil},
+		{"empty string", "+CUSD: 0,\"\",15", &ussd.CUSD{
+			Status: ussd.StatusDone,
+			String: &ussd.String{DCS: 0x0f, Data: []byte{}}}, nil},
+		{"prefix", "+CUSS: 0", nil, ussd.ErrInvalidResult("+CUSS: 0")},
+		{"status digits", "+CUSD: x", nil, ussd.ErrInvalidResult("+CUSD: x")},
+		{"unquoted", "+CUSD: 0,AA,15", nil, ussd.ErrInvalidResult("+CUSD: 0,AA,15")},
+		{"dcs", "+CUSD: 0,\"AA\",256", nil, ussd.ErrInvalidResult("+CUSD: 0,\"AA\",256")},
+		{"hex", "+CUSD: 0,\"AX\",15", nil, ussd.ErrInvalidResult("+CUSD: 0,\"AX\",15")},
+		{"long", "+CUSD: 0,\"AA\",15,1", nil, ussd.ErrInvalidResult("+CUSD: 0,\"AA\",15,1")},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := ussd.ParseCUSD(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, r)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	assert.Equal(t, "action required", ussd.StatusActionRequired.String())
+	assert.Equal(t, "timeout", ussd.StatusTimeout.String())
+	assert.Equal(t, "6", ussd.Status(6).String())
+}
@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package gsmmap
+
+import (
+	"github.com/warthog618/sms/encoding/semioctet"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// maxAddressLen is the maximum length of an AddressString, including the
+// nature of address and numbering plan octet.
+const maxAddressLen = 20
+
+// marshalAddress encodes an AddressString or ISDN-AddressString, as defined
+// in 3GPP TS 29.002 Section 17.7.8.
+//
+// The first octet, containing the nature of address and numbering plan, has
+// the same layout as the TOA of a TPDU address, so the TOA is used directly.
+func marshalAddress(a tpdu.Address) ([]byte, error) {
+	d, err := semioctet.Encode([]byte(a.Addr))
+	if err != nil {
+		return nil, err
+	}
+	if len(d)+1 > maxAddressLen {
+		return nil, tpdu.ErrOverlength
+	}
+	return append([]byte{a.TOA | 0x80}, d...), nil
+}
+
+// unmarshalAddress decodes an AddressString or ISDN-AddressString.
+func unmarshalAddress(b []byte) (tpdu.Address, error) {
+	if len(b) < 1 {
+		return tpdu.Address{}, tpdu.ErrUnderflow
+	}
+	if len(b) > maxAddressLen {
+		return tpdu.Address{}, tpdu.ErrOverlength
+	}
+	d, _, err := semioctet.Decode(make([]byte, 2*(len(b)-1)), b[1:])
+	if err != nil {
+		return tpdu.Address{}, err
+	}
+	return tpdu.Address{Addr: string(d), TOA: b[0]}, nil
+}
+
+// marshalIMSI encodes an IMSI as a TBCD-STRING, as defined in 3GPP TS 29.002
+// Section 17.7.8.
+func marshalIMSI(imsi string) ([]byte, error) {
+	if len(imsi) < 5 || len(imsi) > 15 {
+		return nil, ErrInvalidIMSI(imsi)
+	}
+	for _, r := range imsi {
+		if r < '0' || r > '9' {
+			return nil, ErrInvalidIMSI(imsi)
+		}
+	}
+	return semioctet.Encode([]byte(imsi))
+}
+
+// unmarshalIMSI decodes an IMSI from a TBCD-STRING.
+func unmarshalIMSI(b []byte) (string, error) {
+	if len(b) < 3 {
+		return "", tpdu.ErrUnderflow
+	}
+	if len(b) > 8 {
+		return "", tpdu.ErrOverlength
+	}
+	d, _, err := semioctet.Decode(make([]byte, 2*len(b)), b)
+	if err != nil {
+		return "", err
+	}
+	return string(d), nil
+}
+
+// AddressType identifies the form of an SM-RP-DA or SM-RP-OA.
+type AddressType int
+
+const (
+	// AddrNone indicates the address is absent, i.e. noSM-RP-DA or
+	// noSM-RP-OA.
+	AddrNone AddressType = iota
+
+	// AddrIMSI indicates the address is an IMSI.
+	//
+	// This is only valid for the SM-RP-DA.
+	AddrIMSI
+
+	// AddrLMSI indicates the address is an LMSI.
+	//
+	// This is only valid for the SM-RP-DA.
+	AddrLMSI
+
+	// AddrMSISDN indicates the address is an MSISDN.
+	//
+	// This is only valid for the SM-RP-OA.
+	AddrMSISDN
+
+	// AddrServiceCentre indicates the address is the service centre
+	// address.
+	AddrServiceCentre
+)
+
+// Context specific tags of the SM-RP-DA and SM-RP-OA alternatives.
+var addressTags = map[AddressType]byte{
+	AddrIMSI:          0,
+	AddrLMSI:          1,
+	AddrMSISDN:        2,
+	AddrServiceCentre: 4,
+	AddrNone:          5,
+}
+
+// RPAddress is an SM-RP-DA or SM-RP-OA, as defined in 3GPP TS 29.002
+// Section 7.6.8.
+type RPAddress struct {
+	Type AddressType
+
+	// IMSI is the IMSI, when the Type is AddrIMSI.
+	IMSI string
+
+	// LMSI is the LMSI, when the Type is AddrLMSI.
+	LMSI []byte
+
+	// Address is the MSISDN or service centre address, when the Type is
+	// AddrMSISDN or AddrServiceCentre.
+	Address tpdu.Address
+}
+
+// NewIMSIAddress creates an RPAddress containing an IMSI.
+func NewIMSIAddress(imsi string) RPAddress {
+	return RPAddress{Type: AddrIMSI, IMSI: imsi}
+}
+
+// NewMSISDNAddress creates an RPAddress containing an MSISDN.
+func NewMSISDNAddress(number string) RPAddress {
+	return RPAddress{Type: AddrMSISDN, Address: tpdu.NewAddress(tpdu.FromNumber(number))}
+}
+
+// NewServiceCentreAddress creates an RPAddress containing a service centre
+// address.
+func NewServiceCentreAddress(number string) RPAddress {
+	return RPAddress{Type: AddrServiceCentre, Address: tpdu.NewAddress(tpdu.FromNumber(number))}
+}
+
+// appendDA appends the address, as an SM-RP-DA, to b.
+func (a RPAddress) appendDA(b []byte) ([]byte, error) {
+	switch a.Type {
+	case AddrNone, AddrIMSI, AddrLMSI, AddrServiceCentre:
+		return a.append(b)
+	}
+	return nil, ErrInvalidAddressType(a.Type)
+}
+
+// appendOA appends the address, as an SM-RP-OA, to b.
+func (a RPAddress) appendOA(b []byte) ([]byte, error) {
+	switch a.Type {
+	case AddrNone, AddrMSISDN, AddrServiceCentre:
+		return a.append(b)
+	}
+	return nil, ErrInvalidAddressType(a.Type)
+}
+
+func (a RPAddress) append(b []byte) ([]byte, error) {
+	var c []byte
+	var err error
+	switch a.Type {
+	case AddrIMSI:
+		c, err = marshalIMSI(a.IMSI)
+	case AddrLMSI:
+		if len(a.LMSI) != 4 {
+			return nil, ErrInvalidLMSI
+		}
+		c = a.LMSI
+	case AddrMSISDN, AddrServiceCentre:
+		c, err = marshalAddress(a.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return appendTLV(b, ctx(addressTags[a.Type]), c), nil
+}
+
+// unmarshalDA decodes an SM-RP-DA from the element.
+func unmarshalDA(e element) (RPAddress, error) {
+	switch e.id {
+	case ctx(0):
+		imsi, err := unmarshalIMSI(e.content)
+		return RPAddress{Type: AddrIMSI, IMSI: imsi}, err
+	case ctx(1):
+		if len(e.content) != 4 {
+			return RPAddress{}, ErrInvalidLMSI
+		}
+		return RPAddress{Type: AddrLMSI, LMSI: append([]byte(nil), e.content...)}, nil
+	case ctx(4):
+		a, err := unmarshalAddress(e.content)
+		return RPAddress{Type: AddrServiceCentre, Address: a}, err
+	case ctx(5):
+		return RPAddress{Type: AddrNone}, nil
+	}
+	return RPAddress{}, ErrUnexpectedTag(e.id)
+}
+
+// unmarshalOA decodes an SM-RP-OA from the element.
+func unmarshalOA(e element) (RPAddress, error) {
+	switch e.id {
+	case ctx(2):
+		a, err := unmarshalAddress(e.content)
+		return RPAddress{Type: AddrMSISDN, Address: a}, err
+	case ctx(4):
+		a, err := unmarshalAddress(e.content)
+		return RPAddress{Type: AddrServiceCentre, Address: a}, err
+	case ctx(5):
+		return RPAddress{Type: AddrNone}, nil
+	}
+	return RPAddress{}, ErrUnexpectedTag(e.id)
+}
@@ -0,0 +1,177 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package gsmmap
+
+import (
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Identifier octets of the BER elements used by the MAP arguments.
+const (
+	idBoolean     = 0x01
+	idOctetString = 0x04
+	idNull        = 0x05
+	idSequence    = 0x30
+
+	classContext = 0x80
+	constructed  = 0x20
+)
+
+// ctx returns the identifier octet of a primitive context specific tag.
+func ctx(n byte) byte {
+	return classContext | n
+}
+
+// ctxc returns the identifier octet of a constructed context specific tag.
+func ctxc(n byte) byte {
+	return classContext | constructed | n
+}
+
+// element is a decoded BER TLV.
+type element struct {
+	// id is the first identifier octet, containing the class, the
+	// constructed flag and, for low tag numbers, the tag number.
+	//
+	// Elements with high tag numbers are only skipped, so the tag number
+	// that follows is discarded.
+	id byte
+
+	// off is the offset of the content from the start of the enclosing
+	// buffer.
+	off int
+
+	content []byte
+}
+
+// appendTLV appends an element, with a definite length, to b.
+func appendTLV(b []byte, id byte, content []byte) []byte {
+	b = append(b, id)
+	l := len(content)
+	switch {
+	case l < 0x80:
+		b = append(b, byte(l))
+	case l < 0x100:
+		b = append(b, 0x81, byte(l))
+	case l < 0x10000:
+		b = append(b, 0x82, byte(l>>8), byte(l))
+	default:
+		b = append(b, 0x83, byte(l>>16), byte(l>>8), byte(l))
+	}
+	return append(b, content...)
+}
+
+// parseElements decodes the sequence of elements that forms the content of a
+// constructed element.
+func parseElements(b []byte) ([]element, error) {
+	var ee []element
+	ri := 0
+	for ri < len(b) {
+		e, n, err := parseElement(b[ri:])
+		if err != nil {
+			return nil, tpdu.NewDecodeError("element", ri, err)
+		}
+		e.off += ri
+		ee = append(ee, e)
+		ri += n
+	}
+	return ee, nil
+}
+
+// parseElement decodes the element at the start of b, returning the element
+// and the number of bytes it occupies.
+//
+// Both definite and indefinite lengths are supported, though the latter only
+// for constructed elements, as per X.690 Section 8.1.3.2.
+func parseElement(b []byte) (element, int, error) {
+	if len(b) < 2 {
+		return element{}, 0, tpdu.ErrUnderflow
+	}
+	e := element{id: b[0]}
+	ri := 1
+	if e.id&0x1f == 0x1f {
+		// high tag number form - skip the subsequent identifier octets
+		for {
+			if ri >= len(b) {
+				return element{}, 0, tpdu.ErrUnderflow
+			}
+			c := b[ri]
+			ri++
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		if ri >= len(b) {
+			return element{}, 0, tpdu.ErrUnderflow
+		}
+	}
+	lo := b[ri]
+	ri++
+	if lo == 0x80 {
+		if e.id&constructed == 0 {
+			return element{}, 0, ErrInvalidLength
+		}
+		// indefinite length - contents run to the end-of-contents octets
+		start := ri
+		for {
+			if len(b) < ri+2 {
+				return element{}, 0, tpdu.ErrUnderflow
+			}
+			if b[ri] == 0 && b[ri+1] == 0 {
+				break
+			}
+			_, n, err := parseElement(b[ri:])
+			if err != nil {
+				return element{}, 0, err
+			}
+			ri += n
+		}
+		e.off = start
+		e.content = b[start:ri]
+		return e, ri + 2, nil
+	}
+	l := int(lo)
+	if lo&0x80 != 0 {
+		nl := int(lo & 0x7f)
+		if nl > 3 {
+			return element{}, 0, ErrInvalidLength
+		}
+		if len(b) < ri+nl {
+			return element{}, 0, tpdu.ErrUnderflow
+		}
+		l = 0
+		for i := 0; i < nl; i++ {
+			l = l<<8 | int(b[ri+i])
+		}
+		ri += nl
+	}
+	if len(b) < ri+l {
+		return element{}, 0, tpdu.ErrUnderflow
+	}
+	e.off = ri
+	e.content = b[ri : ri+l]
+	return e, ri + l, nil
+}
+
+// parseSequence decodes b as a single SEQUENCE, returning its elements.
+func parseSequence(b []byte) ([]element, error) {
+	e, n, err := parseElement(b)
+	if err != nil {
+		return nil, tpdu.NewDecodeError("sequence", 0, err)
+	}
+	if e.id != idSequence {
+		return nil, tpdu.NewDecodeError("sequence", 0, ErrUnexpectedTag(e.id))
+	}
+	if n != len(b) {
+		return nil, tpdu.NewDecodeError("sequence", n, tpdu.ErrOverlength)
+	}
+	ee, err := parseElements(e.content)
+	if err != nil {
+		return nil, tpdu.NewDecodeError("sequence", e.off, err)
+	}
+	for i := range ee {
+		ee[i].off += e.off
+	}
+	return ee, nil
+}
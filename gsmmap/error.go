@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package gsmmap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnexpectedTag indicates an element has a tag not permitted in its
+// position.
+type ErrUnexpectedTag byte
+
+func (e ErrUnexpectedTag) Error() string {
+	return fmt.Sprintf("gsmmap: unexpected tag 0x%02x", int(e))
+}
+
+// ErrMissingElement indicates a mandatory element is absent.
+type ErrMissingElement string
+
+func (e ErrMissingElement) Error() string {
+	return fmt.Sprintf("gsmmap: missing %s", string(e))
+}
+
+// ErrInvalidAddressType indicates the type of an RPAddress is not permitted
+// in its position.
+type ErrInvalidAddressType AddressType
+
+func (e ErrInvalidAddressType) Error() string {
+	return fmt.Sprintf("gsmmap: invalid address type %d", int(e))
+}
+
+// ErrInvalidIMSI indicates an IMSI is not 5 to 15 decimal digits.
+type ErrInvalidIMSI string
+
+func (e ErrInvalidIMSI) Error() string {
+	return fmt.Sprintf("gsmmap: invalid IMSI '%s'", string(e))
+}
+
+var (
+	// ErrInvalidLength indicates the length octets of an element are not
+	// supported.
+	ErrInvalidLength = errors.New("gsmmap: invalid length")
+
+	// ErrInvalidLMSI indicates an LMSI is not 4 octets.
+	ErrInvalidLMSI = errors.New("gsmmap: invalid LMSI")
+)
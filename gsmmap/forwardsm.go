@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package gsmmap provides BER encoding and decoding of the MAP operation
+// arguments and results used to transfer short messages, as defined in 3GPP
+// TS 29.002.
+//
+// The package covers mo-forwardSM, mt-forwardSM and sendRoutingInfoForSM,
+// with the TPDU carried in the sm-RP-UI marshalled to and from a tpdu.TPDU.
+// Extension containers and unknown extensions are skipped when decoding, and
+// never encoded.
+package gsmmap
+
+import (
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// maxSignalInfoLen is the maximum length of a SignalInfo.
+const maxSignalInfoLen = 200
+
+// MOForwardSMArg is the argument of the mo-forwardSM operation, as defined in
+// 3GPP TS 29.002 Section 7.6.8, carrying an SMS-SUBMIT or SMS-COMMAND from
+// the MSC to the SMS-IWMSC.
+type MOForwardSMArg struct {
+	// DA is the SM-RP-DA, which is the service centre address.
+	DA RPAddress
+
+	// OA is the SM-RP-OA, which is the MSISDN of the originator.
+	OA RPAddress
+
+	// UI is the TPDU carried in the SM-RP-UI.
+	UI *tpdu.TPDU
+
+	// IMSI is the optional IMSI of the originator.
+	IMSI string
+}
+
+// MarshalBinary encodes the argument into its BER form.
+func (a *MOForwardSMArg) MarshalBinary() ([]byte, error) {
+	b, err := marshalForwardSM(a.DA, a.OA, a.UI)
+	if err != nil {
+		return nil, err
+	}
+	if a.IMSI != "" {
+		imsi, err := marshalIMSI(a.IMSI)
+		if err != nil {
+			return nil, tpdu.EncodeError("imsi", err)
+		}
+		b = appendTLV(b, idOctetString, imsi)
+	}
+	return appendTLV(nil, idSequence, b), nil
+}
+
+// UnmarshalBinary decodes the argument from its BER form.
+func (a *MOForwardSMArg) UnmarshalBinary(b []byte) error {
+	ee, err := parseSequence(b)
+	if err != nil {
+		return err
+	}
+	n := MOForwardSMArg{}
+	n.DA, n.OA, n.UI, ee, err = unmarshalForwardSM(ee, tpdu.MO)
+	if err != nil {
+		return err
+	}
+	for _, e := range ee {
+		if e.id == idOctetString {
+			n.IMSI, err = unmarshalIMSI(e.content)
+			if err != nil {
+				return tpdu.NewDecodeError("imsi", e.off, err)
+			}
+		}
+	}
+	*a = n
+	return nil
+}
+
+// MTForwardSMArg is the argument of the mt-forwardSM operation, as defined in
+// 3GPP TS 29.002 Section 7.6.8, carrying an SMS-DELIVER or
+// SMS-STATUS-REPORT from the SMS-GMSC to the MSC, SGSN or MME.
+type MTForwardSMArg struct {
+	// DA is the SM-RP-DA, which is the IMSI or LMSI of the recipient.
+	DA RPAddress
+
+	// OA is the SM-RP-OA, which is the service centre address.
+	OA RPAddress
+
+	// UI is the TPDU carried in the SM-RP-UI.
+	UI *tpdu.TPDU
+
+	// MoreMessagesToSend indicates further messages are waiting for the
+	// recipient.
+	MoreMessagesToSend bool
+}
+
+// MarshalBinary encodes the argument into its BER form.
+func (a *MTForwardSMArg) MarshalBinary() ([]byte, error) {
+	b, err := marshalForwardSM(a.DA, a.OA, a.UI)
+	if err != nil {
+		return nil, err
+	}
+	if a.MoreMessagesToSend {
+		b = appendTLV(b, idNull, nil)
+	}
+	return appendTLV(nil, idSequence, b), nil
+}
+
+// UnmarshalBinary decodes the argument from its BER form.
+func (a *MTForwardSMArg) UnmarshalBinary(b []byte) error {
+	ee, err := parseSequence(b)
+	if err != nil {
+		return err
+	}
+	n := MTForwardSMArg{}
+	n.DA, n.OA, n.UI, ee, err = unmarshalForwardSM(ee, tpdu.MT)
+	if err != nil {
+		return err
+	}
+	for _, e := range ee {
+		if e.id == idNull {
+			n.MoreMessagesToSend = true
+		}
+	}
+	*a = n
+	return nil
+}
+
+// MOForwardSMRes is the result of the mo-forwardSM operation, optionally
+// carrying an SMS-SUBMIT-REPORT.
+type MOForwardSMRes struct {
+	// UI is the optional TPDU carried in the SM-RP-UI.
+	UI *tpdu.TPDU
+}
+
+// MarshalBinary encodes the result into its BER form.
+func (r *MOForwardSMRes) MarshalBinary() ([]byte, error) {
+	return marshalForwardSMRes(r.UI)
+}
+
+// UnmarshalBinary decodes the result from its BER form.
+//
+// An empty b is treated as a result with no parameters.
+func (r *MOForwardSMRes) UnmarshalBinary(b []byte) error {
+	ui, err := unmarshalForwardSMRes(b, tpdu.MT)
+	if err != nil {
+		return err
+	}
+	r.UI = ui
+	return nil
+}
+
+// MTForwardSMRes is the result of the mt-forwardSM operation, optionally
+// carrying an SMS-DELIVER-REPORT.
+type MTForwardSMRes struct {
+	// UI is the optional TPDU carried in the SM-RP-UI.
+	UI *tpdu.TPDU
+}
+
+// MarshalBinary encodes the result into its BER form.
+func (r *MTForwardSMRes) MarshalBinary() ([]byte, error) {
+	return marshalForwardSMRes(r.UI)
+}
+
+// UnmarshalBinary decodes the result from its BER form.
+//
+// An empty b is treated as a result with no parameters.
+func (r *MTForwardSMRes) UnmarshalBinary(b []byte) error {
+	ui, err := unmarshalForwardSMRes(b, tpdu.MO)
+	if err != nil {
+		return err
+	}
+	r.UI = ui
+	return nil
+}
+
+// marshalForwardSM encodes the mandatory elements common to the forwardSM
+// arguments, returning the content of the SEQUENCE.
+func marshalForwardSM(da, oa RPAddress, ui *tpdu.TPDU) ([]byte, error) {
+	b, err := da.appendDA(nil)
+	if err != nil {
+		return nil, tpdu.EncodeError("sm-RP-DA", err)
+	}
+	b, err = oa.appendOA(b)
+	if err != nil {
+		return nil, tpdu.EncodeError("sm-RP-OA", err)
+	}
+	if ui == nil {
+		return nil, tpdu.EncodeError("sm-RP-UI", ErrMissingElement("sm-RP-UI"))
+	}
+	si, err := marshalSignalInfo(ui)
+	if err != nil {
+		return nil, tpdu.EncodeError("sm-RP-UI", err)
+	}
+	return appendTLV(b, idOctetString, si), nil
+}
+
+// unmarshalForwardSM decodes the mandatory elements common to the forwardSM
+// arguments, returning them and the remaining elements.
+func unmarshalForwardSM(ee []element, d tpdu.Direction) (da, oa RPAddress, ui *tpdu.TPDU, rest []element, err error) {
+	if len(ee) < 3 {
+		return da, oa, nil, nil, tpdu.NewDecodeError("sm-RP-UI", 0, ErrMissingElement("sm-RP-UI"))
+	}
+	if da, err = unmarshalDA(ee[0]); err != nil {
+		return da, oa, nil, nil, tpdu.NewDecodeError("sm-RP-DA", ee[0].off, err)
+	}
+	if oa, err = unmarshalOA(ee[1]); err != nil {
+		return da, oa, nil, nil, tpdu.NewDecodeError("sm-RP-OA", ee[1].off, err)
+	}
+	if ee[2].id != idOctetString {
+		return da, oa, nil, nil, tpdu.NewDecodeError("sm-RP-UI", ee[2].off, ErrUnexpectedTag(ee[2].id))
+	}
+	if ui, err = unmarshalSignalInfo(ee[2].content, d); err != nil {
+		return da, oa, nil, nil, tpdu.NewDecodeError("sm-RP-UI", ee[2].off, err)
+	}
+	return da, oa, ui, ee[3:], nil
+}
+
+func marshalForwardSMRes(ui *tpdu.TPDU) ([]byte, error) {
+	var b []byte
+	if ui != nil {
+		si, err := marshalSignalInfo(ui)
+		if err != nil {
+			return nil, tpdu.EncodeError("sm-RP-UI", err)
+		}
+		b = appendTLV(b, idOctetString, si)
+	}
+	return appendTLV(nil, idSequence, b), nil
+}
+
+func unmarshalForwardSMRes(b []byte, d tpdu.Direction) (*tpdu.TPDU, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	ee, err := parseSequence(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range ee {
+		if e.id == idOctetString {
+			ui, err := unmarshalSignalInfo(e.content, d)
+			if err != nil {
+				return nil, tpdu.NewDecodeError("sm-RP-UI", e.off, err)
+			}
+			return ui, nil
+		}
+	}
+	return nil, nil
+}
+
+// marshalSignalInfo encodes the TPDU as the content of a SignalInfo.
+func marshalSignalInfo(t *tpdu.TPDU) ([]byte, error) {
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxSignalInfoLen {
+		return nil, tpdu.ErrOverlength
+	}
+	return b, nil
+}
+
+// unmarshalSignalInfo decodes a TPDU, with the given direction, from the
+// content of a SignalInfo.
+func unmarshalSignalInfo(b []byte, d tpdu.Direction) (*tpdu.TPDU, error) {
+	if len(b) > maxSignalInfoLen {
+		return nil, tpdu.ErrOverlength
+	}
+	t := tpdu.TPDU{Direction: d}
+	if err := t.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
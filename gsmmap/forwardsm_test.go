@@ -0,0 +1,321 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package gsmmap_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/gsmmap"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+const (
+	// SMS-SUBMIT to 6391 containing "hello".
+	submitTPDU = "0142049136190000" + "05E8329BFD06"
+
+	// SMS-DELIVER from 6391 containing "Hahahaha".
+	deliverTPDU = "040491361900005150713220052308C8303A8C0EA3C3"
+
+	// SMS-DELIVER-REPORT with FCS memory capacity exceeded.
+	deliverReportTPDU = "00D300"
+
+	// SMS-SUBMIT-REPORT with FCS SC busy.
+	submitReportTPDU = "01C000" + "51507132200523"
+
+	imsi    = "505011234567890"
+	imsiBER = "05051132547698F0"
+	scBER   = "91361907002039"
+)
+
+var sca = tpdu.Address{Addr: "639170000293", TOA: 0x91}
+
+func TestMOForwardSMArg(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		arg  func(t *testing.T) *gsmmap.MOForwardSMArg
+	}{
+		{
+			"minimal",
+			"301E" + "8407" + scBER + "8203913619" + "040E" + submitTPDU,
+			func(t *testing.T) *gsmmap.MOForwardSMArg {
+				return &gsmmap.MOForwardSMArg{
+					DA: gsmmap.RPAddress{Type: gsmmap.AddrServiceCentre, Address: sca},
+					OA: gsmmap.NewMSISDNAddress("+6391"),
+					UI: fixture.TPDU(t, tpdu.MO, submitTPDU),
+				}
+			},
+		},
+		{
+			"imsi",
+			"3028" + "8407" + scBER + "8203913619" + "040E" + submitTPDU + "0408" + imsiBER,
+			func(t *testing.T) *gsmmap.MOForwardSMArg {
+				return &gsmmap.MOForwardSMArg{
+					DA:   gsmmap.NewServiceCentreAddress("+639170000293"),
+					OA:   gsmmap.NewMSISDNAddress("+6391"),
+					UI:   fixture.TPDU(t, tpdu.MO, submitTPDU),
+					IMSI: imsi,
+				}
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.arg(t).MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, fixture.Hex(t, p.in), b)
+
+			a := gsmmap.MOForwardSMArg{}
+			err = a.UnmarshalBinary(b)
+			require.Nil(t, err)
+			assert.Equal(t, p.arg(t), &a)
+			assert.Equal(t, tpdu.MO, a.UI.Direction)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestMTForwardSMArg(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		arg  func(t *testing.T) *gsmmap.MTForwardSMArg
+	}{
+		{
+			"imsi",
+			"302B" + "8008" + imsiBER + "8407" + scBER + "0416" + deliverTPDU,
+			func(t *testing.T) *gsmmap.MTForwardSMArg {
+				return &gsmmap.MTForwardSMArg{
+					DA: gsmmap.NewIMSIAddress(imsi),
+					OA: gsmmap.NewServiceCentreAddress("+639170000293"),
+					UI: fixture.TPDU(t, tpdu.MT, deliverTPDU),
+				}
+			},
+		},
+		{
+			"lmsi with more messages",
+			"3029" + "810401020304" + "8407" + scBER + "0416" + deliverTPDU + "0500",
+			func(t *testing.T) *gsmmap.MTForwardSMArg {
+				return &gsmmap.MTForwardSMArg{
+					DA:                 gsmmap.RPAddress{Type: gsmmap.AddrLMSI, LMSI: []byte{1, 2, 3, 4}},
+					OA:                 gsmmap.NewServiceCentreAddress("+639170000293"),
+					UI:                 fixture.TPDU(t, tpdu.MT, deliverTPDU),
+					MoreMessagesToSend: true,
+				}
+			},
+		},
+		{
+			"no addresses",
+			"301C" + "8500" + "8500" + "0416" + deliverTPDU,
+			func(t *testing.T) *gsmmap.MTForwardSMArg {
+				return &gsmmap.MTForwardSMArg{
+					UI: fixture.TPDU(t, tpdu.MT, deliverTPDU),
+				}
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.arg(t).MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, fixture.Hex(t, p.in), b)
+
+			a := gsmmap.MTForwardSMArg{}
+			err = a.UnmarshalBinary(b)
+			require.Nil(t, err)
+			assert.Equal(t, p.arg(t), &a)
+			assert.Equal(t, tpdu.MT, a.UI.Direction)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestMTForwardSMArgExtensions(t *testing.T) {
+	// indefinite length, extension container, and post-extension elements
+	in := "3080" + "8008" + imsiBER + "8407" + scBER + "0416" + deliverTPDU +
+		"0500" + "3003A00100" + "020100" + "9F3201FF" + "0000"
+	a := gsmmap.MTForwardSMArg{}
+	err := a.UnmarshalBinary(fixture.Hex(t, in))
+	require.Nil(t, err)
+	expected := gsmmap.MTForwardSMArg{
+		DA:                 gsmmap.NewIMSIAddress(imsi),
+		OA:                 gsmmap.NewServiceCentreAddress("+639170000293"),
+		UI:                 fixture.TPDU(t, tpdu.MT, deliverTPDU),
+		MoreMessagesToSend: true,
+	}
+	assert.Equal(t, expected, a)
+}
+
+func TestForwardSMArgMarshalError(t *testing.T) {
+	ui := &tpdu.TPDU{}
+	patterns := []struct {
+		name string
+		arg  gsmmap.MTForwardSMArg
+		err  error
+	}{
+		{
+			"oa as da",
+			gsmmap.MTForwardSMArg{DA: gsmmap.NewMSISDNAddress("1234"), UI: ui},
+			tpdu.EncodeError("sm-RP-DA", gsmmap.ErrInvalidAddressType(gsmmap.AddrMSISDN)),
+		},
+		{
+			"da as oa",
+			gsmmap.MTForwardSMArg{OA: gsmmap.NewIMSIAddress(imsi), UI: ui},
+			tpdu.EncodeError("sm-RP-OA", gsmmap.ErrInvalidAddressType(gsmmap.AddrIMSI)),
+		},
+		{
+			"invalid imsi",
+			gsmmap.MTForwardSMArg{DA: gsmmap.NewIMSIAddress("1234"), UI: ui},
+			tpdu.EncodeError("sm-RP-DA", gsmmap.ErrInvalidIMSI("1234")),
+		},
+		{
+			"invalid lmsi",
+			gsmmap.MTForwardSMArg{DA: gsmmap.RPAddress{Type: gsmmap.AddrLMSI}, UI: ui},
+			tpdu.EncodeError("sm-RP-DA", gsmmap.ErrInvalidLMSI),
+		},
+		{
+			"long address",
+			gsmmap.MTForwardSMArg{OA: gsmmap.NewServiceCentreAddress("1234567890123456789012345678901234567890"), UI: ui},
+			tpdu.EncodeError("sm-RP-OA", tpdu.ErrOverlength),
+		},
+		{
+			"missing ui",
+			gsmmap.MTForwardSMArg{},
+			tpdu.EncodeError("sm-RP-UI", gsmmap.ErrMissingElement("sm-RP-UI")),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.arg.MarshalBinary()
+			assert.Equal(t, p.err, err)
+			assert.Nil(t, b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestForwardSMArgUnmarshalError(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{
+			"empty",
+			"",
+			tpdu.NewDecodeError("sequence", 0, tpdu.ErrUnderflow),
+		},
+		{
+			"not sequence",
+			"3100",
+			tpdu.NewDecodeError("sequence", 0, gsmmap.ErrUnexpectedTag(0x31)),
+		},
+		{
+			"trailing",
+			"300000",
+			tpdu.NewDecodeError("sequence", 2, tpdu.ErrOverlength),
+		},
+		{
+			"short",
+			"3004850085",
+			tpdu.NewDecodeError("sequence", 0, tpdu.ErrUnderflow),
+		},
+		{
+			"short element",
+			"30048500850A",
+			tpdu.NewDecodeError("sequence.element", 4, tpdu.ErrUnderflow),
+		},
+		{
+			"long length",
+			"30088500858400000001",
+			tpdu.NewDecodeError("sequence.element", 4, gsmmap.ErrInvalidLength),
+		},
+		{
+			"indefinite primitive",
+			"3080858000000000",
+			tpdu.NewDecodeError("sequence", 0, gsmmap.ErrInvalidLength),
+		},
+		{
+			"missing ui",
+			"300485008500",
+			tpdu.NewDecodeError("sm-RP-UI", 0, gsmmap.ErrMissingElement("sm-RP-UI")),
+		},
+		{
+			"invalid da",
+			"3006820085000400",
+			tpdu.NewDecodeError("sm-RP-DA", 4, gsmmap.ErrUnexpectedTag(0x82)),
+		},
+		{
+			"invalid oa",
+			"3006850080000400",
+			tpdu.NewDecodeError("sm-RP-OA", 6, gsmmap.ErrUnexpectedTag(0x80)),
+		},
+		{
+			"short imsi",
+			"30088002050585000400",
+			tpdu.NewDecodeError("sm-RP-DA", 4, tpdu.ErrUnderflow),
+		},
+		{
+			"ui tag",
+			"3006850085008000",
+			tpdu.NewDecodeError("sm-RP-UI", 8, gsmmap.ErrUnexpectedTag(0x80)),
+		},
+		{
+			"bad ui",
+			"3006850085000400",
+			tpdu.NewDecodeError("sm-RP-UI.tpdu.firstOctet", 8, tpdu.ErrUnderflow),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			a := gsmmap.MTForwardSMArg{}
+			err := a.UnmarshalBinary(fixture.Hex(t, p.in))
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, gsmmap.MTForwardSMArg{}, a)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestMOForwardSMRes(t *testing.T) {
+	r := gsmmap.MOForwardSMRes{UI: fixture.TPDU(t, tpdu.MT, submitReportTPDU)}
+	b, err := r.MarshalBinary()
+	require.Nil(t, err)
+	assert.Equal(t, fixture.Hex(t, "300C"+"040A"+submitReportTPDU), b)
+
+	d := gsmmap.MOForwardSMRes{}
+	err = d.UnmarshalBinary(b)
+	require.Nil(t, err)
+	assert.Equal(t, r, d)
+	assert.Equal(t, tpdu.MT, d.UI.Direction)
+
+	err = d.UnmarshalBinary(nil)
+	require.Nil(t, err)
+	assert.Nil(t, d.UI)
+}
+
+func TestMTForwardSMRes(t *testing.T) {
+	r := gsmmap.MTForwardSMRes{}
+	b, err := r.MarshalBinary()
+	require.Nil(t, err)
+	assert.Equal(t, []byte{0x30, 0x00}, b)
+
+	r.UI = fixture.TPDU(t, tpdu.MO, deliverReportTPDU)
+	b, err = r.MarshalBinary()
+	require.Nil(t, err)
+	assert.Equal(t, fixture.Hex(t, "3005"+"0403"+deliverReportTPDU), b)
+
+	d := gsmmap.MTForwardSMRes{}
+	err = d.UnmarshalBinary(b)
+	require.Nil(t, err)
+	assert.Equal(t, r, d)
+	assert.Equal(t, tpdu.MO, d.UI.Direction)
+
+	err = d.UnmarshalBinary(fixture.Hex(t, "30020400"))
+	assert.Equal(t, tpdu.NewDecodeError("sm-RP-UI.tpdu.firstOctet", 4, tpdu.ErrUnderflow), err)
+}
@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package gsmmap
+
+import (
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// RoutingInfoForSMArg is the argument of the sendRoutingInfoForSM operation,
+// as defined in 3GPP TS 29.002 Section 7.6.8, requesting the routing
+// information for an MT short message from the HLR.
+type RoutingInfoForSMArg struct {
+	// MSISDN is the MSISDN of the recipient.
+	MSISDN tpdu.Address
+
+	// Priority is the SM-RP-PRI, indicating delivery should be attempted
+	// even if the service centre address is already in the message waiting
+	// data.
+	Priority bool
+
+	// SCAddress is the address of the service centre.
+	SCAddress tpdu.Address
+
+	// GPRSSupport indicates the SMS-GMSC supports delivery via the SGSN.
+	GPRSSupport bool
+}
+
+// MarshalBinary encodes the argument into its BER form.
+func (a *RoutingInfoForSMArg) MarshalBinary() ([]byte, error) {
+	msisdn, err := marshalAddress(a.MSISDN)
+	if err != nil {
+		return nil, tpdu.EncodeError("msisdn", err)
+	}
+	sca, err := marshalAddress(a.SCAddress)
+	if err != nil {
+		return nil, tpdu.EncodeError("serviceCentreAddress", err)
+	}
+	b := appendTLV(nil, ctx(0), msisdn)
+	b = appendTLV(b, ctx(1), marshalBoolean(a.Priority))
+	b = appendTLV(b, ctx(2), sca)
+	if a.GPRSSupport {
+		b = appendTLV(b, ctx(7), nil)
+	}
+	return appendTLV(nil, idSequence, b), nil
+}
+
+// UnmarshalBinary decodes the argument from its BER form.
+func (a *RoutingInfoForSMArg) UnmarshalBinary(b []byte) error {
+	ee, err := parseSequence(b)
+	if err != nil {
+		return err
+	}
+	n := RoutingInfoForSMArg{}
+	var found byte
+	for _, e := range ee {
+		switch e.id {
+		case ctx(0):
+			if n.MSISDN, err = unmarshalAddress(e.content); err != nil {
+				return tpdu.NewDecodeError("msisdn", e.off, err)
+			}
+		case ctx(1):
+			if n.Priority, err = unmarshalBoolean(e.content); err != nil {
+				return tpdu.NewDecodeError("sm-RP-PRI", e.off, err)
+			}
+		case ctx(2):
+			if n.SCAddress, err = unmarshalAddress(e.content); err != nil {
+				return tpdu.NewDecodeError("serviceCentreAddress", e.off, err)
+			}
+		case ctx(7):
+			n.GPRSSupport = true
+		default:
+			continue
+		}
+		found |= 1 << (e.id & 0x1f)
+	}
+	for _, m := range []struct {
+		tag  byte
+		name string
+	}{{0, "msisdn"}, {1, "sm-RP-PRI"}, {2, "serviceCentreAddress"}} {
+		if found&(1<<m.tag) == 0 {
+			return tpdu.NewDecodeError(m.name, 0, ErrMissingElement(m.name))
+		}
+	}
+	*a = n
+	return nil
+}
+
+// AdditionalNumber is the number of an additional node that can deliver an
+// MT short message to the recipient.
+type AdditionalNumber struct {
+	// SGSN indicates the number is an SGSN number rather than an MSC
+	// number.
+	SGSN bool
+
+	Number tpdu.Address
+}
+
+// RoutingInfoForSMRes is the result of the sendRoutingInfoForSM operation, as
+// defined in 3GPP TS 29.002 Section 7.6.8.
+type RoutingInfoForSMRes struct {
+	// IMSI is the IMSI of the recipient.
+	IMSI string
+
+	// NetworkNodeNumber is the number of the MSC, SGSN or MME serving the
+	// recipient.
+	NetworkNodeNumber tpdu.Address
+
+	// LMSI is the optional LMSI of the recipient.
+	LMSI []byte
+
+	// GPRSNodeIndicator indicates the NetworkNodeNumber is an SGSN number.
+	GPRSNodeIndicator bool
+
+	// AdditionalNumber is the optional number of a second serving node.
+	AdditionalNumber *AdditionalNumber
+}
+
+// MarshalBinary encodes the result into its BER form.
+func (r *RoutingInfoForSMRes) MarshalBinary() ([]byte, error) {
+	imsi, err := marshalIMSI(r.IMSI)
+	if err != nil {
+		return nil, tpdu.EncodeError("imsi", err)
+	}
+	nn, err := marshalAddress(r.NetworkNodeNumber)
+	if err != nil {
+		return nil, tpdu.EncodeError("locationInfoWithLMSI.networkNode-Number", err)
+	}
+	li := appendTLV(nil, ctx(1), nn)
+	if r.LMSI != nil {
+		if len(r.LMSI) != 4 {
+			return nil, tpdu.EncodeError("locationInfoWithLMSI.lmsi", ErrInvalidLMSI)
+		}
+		li = appendTLV(li, idOctetString, r.LMSI)
+	}
+	if r.GPRSNodeIndicator {
+		li = appendTLV(li, ctx(5), nil)
+	}
+	if r.AdditionalNumber != nil {
+		an, err := marshalAddress(r.AdditionalNumber.Number)
+		if err != nil {
+			return nil, tpdu.EncodeError("locationInfoWithLMSI.additional-Number", err)
+		}
+		var tag byte
+		if r.AdditionalNumber.SGSN {
+			tag = 1
+		}
+		li = appendTLV(li, ctxc(6), appendTLV(nil, ctx(tag), an))
+	}
+	b := appendTLV(nil, idOctetString, imsi)
+	b = appendTLV(b, ctxc(0), li)
+	return appendTLV(nil, idSequence, b), nil
+}
+
+// UnmarshalBinary decodes the result from its BER form.
+func (r *RoutingInfoForSMRes) UnmarshalBinary(b []byte) error {
+	ee, err := parseSequence(b)
+	if err != nil {
+		return err
+	}
+	n := RoutingInfoForSMRes{}
+	if len(ee) < 1 || ee[0].id != idOctetString {
+		return tpdu.NewDecodeError("imsi", 0, ErrMissingElement("imsi"))
+	}
+	if n.IMSI, err = unmarshalIMSI(ee[0].content); err != nil {
+		return tpdu.NewDecodeError("imsi", ee[0].off, err)
+	}
+	if len(ee) < 2 || ee[1].id != ctxc(0) {
+		return tpdu.NewDecodeError("locationInfoWithLMSI", 0, ErrMissingElement("locationInfoWithLMSI"))
+	}
+	if err = n.unmarshalLocationInfo(ee[1].content); err != nil {
+		return tpdu.NewDecodeError("locationInfoWithLMSI", ee[1].off, err)
+	}
+	*r = n
+	return nil
+}
+
+// unmarshalLocationInfo decodes the content of the LocationInfoWithLMSI.
+func (r *RoutingInfoForSMRes) unmarshalLocationInfo(b []byte) error {
+	ee, err := parseElements(b)
+	if err != nil {
+		return err
+	}
+	if len(ee) < 1 || ee[0].id != ctx(1) {
+		return ErrMissingElement("networkNode-Number")
+	}
+	if r.NetworkNodeNumber, err = unmarshalAddress(ee[0].content); err != nil {
+		return tpdu.NewDecodeError("networkNode-Number", ee[0].off, err)
+	}
+	for _, e := range ee[1:] {
+		switch e.id {
+		case idOctetString:
+			if len(e.content) != 4 {
+				return tpdu.NewDecodeError("lmsi", e.off, ErrInvalidLMSI)
+			}
+			r.LMSI = append([]byte(nil), e.content...)
+		case ctx(5):
+			r.GPRSNodeIndicator = true
+		case ctxc(6):
+			an, err := unmarshalAdditionalNumber(e.content)
+			if err != nil {
+				return tpdu.NewDecodeError("additional-Number", e.off, err)
+			}
+			r.AdditionalNumber = an
+		}
+	}
+	return nil
+}
+
+// unmarshalAdditionalNumber decodes the content of an Additional-Number.
+func unmarshalAdditionalNumber(b []byte) (*AdditionalNumber, error) {
+	e, n, err := parseElement(b)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(b) {
+		return nil, tpdu.ErrOverlength
+	}
+	an := AdditionalNumber{}
+	switch e.id {
+	case ctx(0):
+	case ctx(1):
+		an.SGSN = true
+	default:
+		return nil, ErrUnexpectedTag(e.id)
+	}
+	if an.Number, err = unmarshalAddress(e.content); err != nil {
+		return nil, tpdu.NewDecodeError("number", e.off, err)
+	}
+	return &an, nil
+}
+
+func marshalBoolean(v bool) []byte {
+	if v {
+		return []byte{0xff}
+	}
+	return []byte{0x00}
+}
+
+func unmarshalBoolean(b []byte) (bool, error) {
+	if len(b) < 1 {
+		return false, tpdu.ErrUnderflow
+	}
+	if len(b) > 1 {
+		return false, tpdu.ErrOverlength
+	}
+	return b[0] != 0, nil
+}
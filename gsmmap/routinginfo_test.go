@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package gsmmap_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/gsmmap"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+func TestRoutingInfoForSMArg(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		arg  gsmmap.RoutingInfoForSMArg
+	}{
+		{
+			"minimal",
+			"3011" + "8003913619" + "810100" + "8207" + scBER,
+			gsmmap.RoutingInfoForSMArg{
+				MSISDN:    tpdu.Address{Addr: "6391", TOA: 0x91},
+				SCAddress: sca,
+			},
+		},
+		{
+			"full",
+			"3013" + "8003913619" + "8101FF" + "8207" + scBER + "8700",
+			gsmmap.RoutingInfoForSMArg{
+				MSISDN:      tpdu.Address{Addr: "6391", TOA: 0x91},
+				Priority:    true,
+				SCAddress:   sca,
+				GPRSSupport: true,
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.arg.MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, fixture.Hex(t, p.in), b)
+
+			a := gsmmap.RoutingInfoForSMArg{}
+			err = a.UnmarshalBinary(b)
+			require.Nil(t, err)
+			assert.Equal(t, p.arg, a)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestRoutingInfoForSMArgUnmarshalError(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{
+			"missing msisdn",
+			"300C" + "810100" + "8207" + scBER,
+			tpdu.NewDecodeError("msisdn", 0, gsmmap.ErrMissingElement("msisdn")),
+		},
+		{
+			"missing sca",
+			"3008" + "8003913619" + "810100",
+			tpdu.NewDecodeError("serviceCentreAddress", 0, gsmmap.ErrMissingElement("serviceCentreAddress")),
+		},
+		{
+			"bad priority",
+			"3002" + "8100",
+			tpdu.NewDecodeError("sm-RP-PRI", 4, tpdu.ErrUnderflow),
+		},
+		{
+			"empty msisdn",
+			"3002" + "8000",
+			tpdu.NewDecodeError("msisdn", 4, tpdu.ErrUnderflow),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			a := gsmmap.RoutingInfoForSMArg{}
+			err := a.UnmarshalBinary(fixture.Hex(t, p.in))
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, gsmmap.RoutingInfoForSMArg{}, a)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestRoutingInfoForSMRes(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		res  gsmmap.RoutingInfoForSMRes
+	}{
+		{
+			"minimal",
+			"3010" + "0408" + imsiBER + "A004" + "8102" + "9116",
+			gsmmap.RoutingInfoForSMRes{
+				IMSI:              imsi,
+				NetworkNodeNumber: tpdu.Address{Addr: "61", TOA: 0x91},
+			},
+		},
+		{
+			"full",
+			"3021" + "0408" + imsiBER + "A015" + "8104911614F2" + "040401020304" + "8500" + "A605" + "8103911614",
+			gsmmap.RoutingInfoForSMRes{
+				IMSI:              imsi,
+				NetworkNodeNumber: tpdu.Address{Addr: "61412", TOA: 0x91},
+				LMSI:              []byte{1, 2, 3, 4},
+				GPRSNodeIndicator: true,
+				AdditionalNumber: &gsmmap.AdditionalNumber{
+					SGSN:   true,
+					Number: tpdu.Address{Addr: "6141", TOA: 0x91},
+				},
+			},
+		},
+		{
+			"msc",
+			"3016" + "0408" + imsiBER + "A00A" + "8102" + "9116" + "A604" + "80029116",
+			gsmmap.RoutingInfoForSMRes{
+				IMSI:              imsi,
+				NetworkNodeNumber: tpdu.Address{Addr: "61", TOA: 0x91},
+				AdditionalNumber: &gsmmap.AdditionalNumber{
+					Number: tpdu.Address{Addr: "61", TOA: 0x91},
+				},
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.res.MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, fixture.Hex(t, p.in), b)
+
+			r := gsmmap.RoutingInfoForSMRes{}
+			err = r.UnmarshalBinary(b)
+			require.Nil(t, err)
+			assert.Equal(t, p.res, r)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestRoutingInfoForSMResError(t *testing.T) {
+	r := gsmmap.RoutingInfoForSMRes{IMSI: imsi, LMSI: []byte{1}}
+	_, err := r.MarshalBinary()
+	assert.Equal(t, tpdu.EncodeError("locationInfoWithLMSI.lmsi", gsmmap.ErrInvalidLMSI), err)
+
+	patterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{
+			"missing imsi",
+			"3000",
+			tpdu.NewDecodeError("imsi", 0, gsmmap.ErrMissingElement("imsi")),
+		},
+		{
+			"missing location",
+			"300A" + "0408" + imsiBER,
+			tpdu.NewDecodeError("locationInfoWithLMSI", 0, gsmmap.ErrMissingElement("locationInfoWithLMSI")),
+		},
+		{
+			"missing node number",
+			"300E" + "0408" + imsiBER + "A002" + "8500",
+			tpdu.NewDecodeError("locationInfoWithLMSI", 14, gsmmap.ErrMissingElement("networkNode-Number")),
+		},
+		{
+			"bad additional number",
+			"3014" + "0408" + imsiBER + "A008" + "8102" + "9116" + "A602" + "8200",
+			tpdu.NewDecodeError("locationInfoWithLMSI.additional-Number", 20, gsmmap.ErrUnexpectedTag(0x82)),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r := gsmmap.RoutingInfoForSMRes{}
+			err := r.UnmarshalBinary(fixture.Hex(t, p.in))
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, gsmmap.RoutingInfoForSMRes{}, r)
+		}
+		t.Run(p.name, f)
+	}
+}
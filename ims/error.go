@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ims
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/warthog618/sms/encoding/rp"
+)
+
+// ErrInvalidStartLine indicates the start line of a message is neither a
+// valid Request-Line nor a valid Status-Line.
+type ErrInvalidStartLine string
+
+func (e ErrInvalidStartLine) Error() string {
+	return fmt.Sprintf("ims: invalid start line: '%s'", string(e))
+}
+
+// ErrInvalidHeader indicates a header line is not a valid header field.
+type ErrInvalidHeader string
+
+func (e ErrInvalidHeader) Error() string {
+	return fmt.Sprintf("ims: invalid header: '%s'", string(e))
+}
+
+// ErrInvalidContentLength indicates the Content-Length is not a valid length,
+// exceeds the available data, or exceeds MaxMessageSize.
+type ErrInvalidContentLength string
+
+func (e ErrInvalidContentLength) Error() string {
+	return fmt.Sprintf("ims: invalid content length: '%s'", string(e))
+}
+
+// ErrInvalidStatusCode indicates the status code of a response is out of
+// range.
+type ErrInvalidStatusCode int
+
+func (e ErrInvalidStatusCode) Error() string {
+	return fmt.Sprintf("ims: invalid status code: %d", int(e))
+}
+
+// ErrUnsupportedContentType indicates the body of a MESSAGE is not an RP
+// message.
+type ErrUnsupportedContentType string
+
+func (e ErrUnsupportedContentType) Error() string {
+	return fmt.Sprintf("ims: unsupported content type: '%s'", string(e))
+}
+
+// ErrUnexpectedRPType indicates the RP message is not of the type expected.
+type ErrUnexpectedRPType rp.MessageType
+
+func (e ErrUnexpectedRPType) Error() string {
+	return fmt.Sprintf("ims: unexpected RP message type: %s", rp.MessageType(e))
+}
+
+var (
+	// ErrMalformed indicates a message is truncated before the end of its
+	// header.
+	ErrMalformed = errors.New("ims: malformed message")
+
+	// ErrMissingRequestURI indicates a request has no Request-URI.
+	ErrMissingRequestURI = errors.New("ims: missing Request-URI")
+
+	// ErrNotMessage indicates a SIP message is not a MESSAGE request.
+	ErrNotMessage = errors.New("ims: not a MESSAGE request")
+)
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ims
+
+import (
+	"bufio"
+	"net"
+
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Handler is called with the originator URI and the TPDU of each RP-DATA
+// received by a Gateway.
+//
+// It returns the TPDU to include in the RP-User-Data of the reply, which may
+// be nil, and the RP-Cause. A zero cause results in an RP-ACK, else an
+// RP-ERROR with that cause.
+type Handler func(from string, t *tpdu.TPDU) (*tpdu.TPDU, rp.Cause)
+
+// Gateway is a minimal IP-SM-GW, intended for testing, that accepts SMS
+// carried in SIP MESSAGE requests over UDP or TCP.
+//
+// Each RP-DATA or RP-SMMA is accepted with a 202 response, followed by a
+// MESSAGE carrying the RP-ACK or RP-ERROR, which is returned over the same
+// connection, or to the same UDP address, as the request.
+// Each RP-ACK or RP-ERROR is accepted with a 200 response.
+//
+// The zero value is ready for use, and acknowledges all messages.
+type Gateway struct {
+	// Handler, if set, is called for each RP-DATA received.
+	Handler Handler
+
+	// ReportHandler, if set, is called with the originator URI and the
+	// RP-ACK or RP-ERROR for each report received.
+	ReportHandler func(from string, r *rp.Message)
+}
+
+// Serve accepts TCP connections on the listener and serves each with
+// ServeConn.
+//
+// Serve returns when the listener is closed.
+func (g *Gateway) Serve(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go g.ServeConn(nc)
+	}
+}
+
+// ServeConn serves SIP requests on the TCP connection until it is closed.
+func (g *Gateway) ServeConn(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	local := nc.LocalAddr().String()
+	for {
+		m, err := ReadMessage(r)
+		if err != nil {
+			return
+		}
+		for _, o := range g.handle(m, "TCP", local) {
+			if err := WriteMessage(nc, o); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ServePacket serves SIP requests received on the UDP connection until it is
+// closed.
+func (g *Gateway) ServePacket(pc net.PacketConn) error {
+	b := make([]byte, MaxMessageSize)
+	local := pc.LocalAddr().String()
+	for {
+		n, addr, err := pc.ReadFrom(b)
+		if err != nil {
+			return err
+		}
+		m := Message{}
+		if err := m.UnmarshalBinary(b[:n]); err != nil {
+			continue
+		}
+		for _, o := range g.handle(&m, "UDP", local) {
+			ob, err := o.MarshalBinary()
+			if err != nil {
+				continue
+			}
+			pc.WriteTo(ob, addr)
+		}
+	}
+}
+
+// handle processes a received message and returns the messages to be sent
+// in reply.
+func (g *Gateway) handle(m *Message, transport, local string) []*Message {
+	if !m.IsRequest() {
+		// responses to reports need no further action
+		return nil
+	}
+	if m.Method != MethodMessage {
+		resp := m.Response(405, "Method Not Allowed")
+		resp.Header.Add("Allow", MethodMessage)
+		return []*Message{resp}
+	}
+	r, err := m.RP()
+	if err != nil {
+		if _, ok := err.(ErrUnsupportedContentType); ok {
+			resp := m.Response(415, "Unsupported Media Type")
+			resp.Header.Add("Accept", ContentType)
+			return []*Message{resp}
+		}
+		return []*Message{m.Response(400, "Bad Request")}
+	}
+	from := addrSpec(m.Header.Get("From"))
+	switch r.Type {
+	case rp.DataMSToN, rp.DataNToMS:
+		var ud *tpdu.TPDU
+		var cause rp.Cause
+		if g.Handler != nil {
+			ud, cause = g.Handler(from, r.UD)
+		}
+		var rpt *Message
+		if cause == 0 {
+			rpt, err = NewAck(m, ud)
+		} else {
+			rpt, err = NewError(m, cause, ud)
+		}
+		if err != nil {
+			return []*Message{m.Response(500, "Server Internal Error")}
+		}
+		return []*Message{m.Response(202, "Accepted"), withVia(rpt, transport, local)}
+	case rp.SMMA:
+		rpt, _ := NewAck(m, nil)
+		return []*Message{m.Response(202, "Accepted"), withVia(rpt, transport, local)}
+	default:
+		if g.ReportHandler != nil {
+			g.ReportHandler(from, r)
+		}
+		return []*Message{m.Response(200, "OK")}
+	}
+}
+
+// withVia adds the Via for a request sent by the Gateway.
+func withVia(m *Message, transport, local string) *Message {
+	v := HeaderField{
+		Name:  "Via",
+		Value: "SIP/2.0/" + transport + " " + local + ";branch=z9hG4bK" + newToken(),
+	}
+	m.Header = append(Header{v}, m.Header...)
+	return m
+}
@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ims_test
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/ims"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+func handler(t *testing.T, got chan<- *tpdu.TPDU) ims.Handler {
+	return func(from string, p *tpdu.TPDU) (*tpdu.TPDU, rp.Cause) {
+		assert.Equal(t, ue, from)
+		got <- p
+		if p.DA.Addr == "6391" {
+			return nil, 0
+		}
+		return nil, rp.CauseUnassignedNumber
+	}
+}
+
+func TestGatewayUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer pc.Close()
+	got := make(chan *tpdu.TPDU, 1)
+	g := ims.Gateway{Handler: handler(t, got)}
+	go g.ServePacket(pc)
+
+	c, err := net.Dial("udp", pc.LocalAddr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(time.Second))
+
+	s := fixture.TPDU(t, tpdu.MO, submitTPDU)
+	req, err := ims.NewSubmit(ue, smsc, 0x2a, s)
+	require.Nil(t, err)
+	req.Header = append(ims.Header{{"Via", "SIP/2.0/UDP " + c.LocalAddr().String()}}, req.Header...)
+	b, err := req.MarshalBinary()
+	require.Nil(t, err)
+	_, err = c.Write(b)
+	require.Nil(t, err)
+
+	resp := readPacket(t, c)
+	assert.Equal(t, 202, resp.StatusCode)
+	assert.Equal(t, req.Header.Get("Call-ID"), resp.Header.Get("Call-ID"))
+	assert.Equal(t, s, <-got)
+
+	rpt := readPacket(t, c)
+	assert.Equal(t, "MESSAGE", rpt.Method)
+	assert.Equal(t, req.Header.Get("Call-ID"), rpt.Header.Get("In-Reply-To"))
+	assert.Regexp(t, "^SIP/2.0/UDP "+pc.LocalAddr().String()+";branch=z9hG4bK", rpt.Header.Get("Via"))
+	r, err := rpt.RP()
+	require.Nil(t, err)
+	assert.Equal(t, &rp.Message{Type: rp.AckNToMS, MR: 0x2a}, r)
+
+	// not a MESSAGE
+	_, err = c.Write([]byte("OPTIONS sip:gw SIP/2.0\r\nCall-ID: 1\r\n\r\n"))
+	require.Nil(t, err)
+	resp = readPacket(t, c)
+	assert.Equal(t, 405, resp.StatusCode)
+	assert.Equal(t, "MESSAGE", resp.Header.Get("Allow"))
+}
+
+func readPacket(t *testing.T, c net.Conn) *ims.Message {
+	t.Helper()
+	b := make([]byte, 2048)
+	n, err := c.Read(b)
+	require.Nil(t, err)
+	m := ims.Message{}
+	err = m.UnmarshalBinary(b[:n])
+	require.Nil(t, err)
+	return &m
+}
+
+func TestGatewayTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	got := make(chan *tpdu.TPDU, 1)
+	reports := make(chan *rp.Message, 1)
+	g := ims.Gateway{
+		Handler: handler(t, got),
+		ReportHandler: func(from string, r *rp.Message) {
+			reports <- r
+		},
+	}
+	go g.Serve(l)
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	require.Nil(t, err)
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(time.Second))
+	br := bufio.NewReader(c)
+
+	// rejected submission
+	s := fixture.TPDU(t, tpdu.MO, submitTPDU)
+	s.DA.Addr = "1234"
+	req, err := ims.NewSubmit(ue, smsc, 0x2b, s)
+	require.Nil(t, err)
+	err = ims.WriteMessage(c, req)
+	require.Nil(t, err)
+	resp, err := ims.ReadMessage(br)
+	require.Nil(t, err)
+	assert.Equal(t, 202, resp.StatusCode)
+	assert.Equal(t, s, <-got)
+	rpt, err := ims.ReadMessage(br)
+	require.Nil(t, err)
+	assert.Regexp(t, "^SIP/2.0/TCP ", rpt.Header.Get("Via"))
+	r, err := rpt.RP()
+	require.Nil(t, err)
+	assert.Equal(t, &rp.Message{Type: rp.ErrorNToMS, MR: 0x2b, Cause: rp.CauseUnassignedNumber}, r)
+	err = ims.WriteMessage(c, rpt.Response(200, "OK"))
+	require.Nil(t, err)
+
+	// report for an MT message
+	d, err := ims.NewDeliver(ue, smsc, 0x12, fixture.TPDU(t, tpdu.MT, deliverTPDU))
+	require.Nil(t, err)
+	ack, err := ims.NewAck(d, nil)
+	require.Nil(t, err)
+	err = ims.WriteMessage(c, ack)
+	require.Nil(t, err)
+	resp, err = ims.ReadMessage(br)
+	require.Nil(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, &rp.Message{Type: rp.AckMSToN, MR: 0x12}, <-reports)
+
+	// smma
+	smma := *req
+	smma.Body = []byte{0x06, 0x2c}
+	err = ims.WriteMessage(c, &smma)
+	require.Nil(t, err)
+	resp, err = ims.ReadMessage(br)
+	require.Nil(t, err)
+	assert.Equal(t, 202, resp.StatusCode)
+	rpt, err = ims.ReadMessage(br)
+	require.Nil(t, err)
+	r, err = rpt.RP()
+	require.Nil(t, err)
+	assert.Equal(t, &rp.Message{Type: rp.AckNToMS, MR: 0x2c}, r)
+
+	// wrong content
+	bad := *req
+	bad.Header = ims.Header{{"Content-Type", "text/plain"}}
+	err = ims.WriteMessage(c, &bad)
+	require.Nil(t, err)
+	resp, err = ims.ReadMessage(br)
+	require.Nil(t, err)
+	assert.Equal(t, 415, resp.StatusCode)
+
+	// malformed body
+	bad = *req
+	bad.Body = []byte{0x00}
+	err = ims.WriteMessage(c, &bad)
+	require.Nil(t, err)
+	resp, err = ims.ReadMessage(br)
+	require.Nil(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+}
@@ -0,0 +1,353 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ims
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// sipVersion is the SIP version of requests and responses.
+const sipVersion = "SIP/2.0"
+
+// MaxMessageSize is the largest SIP message accepted, being the largest that
+// fits in a UDP datagram.
+//
+// The body of a message read from a stream is limited to this size.
+const MaxMessageSize = 65535
+
+// HeaderField is a single SIP header field.
+type HeaderField struct {
+	Name  string
+	Value string
+}
+
+// Header is the ordered set of header fields of a SIP message.
+//
+// Names are stored in their canonical long form, so compact forms such as
+// "i" for Call-ID are expanded when decoded.
+type Header []HeaderField
+
+// compactNames maps the compact header names of RFC 3261 Section 7.3.3 to
+// their long forms.
+var compactNames = map[string]string{
+	"c": "Content-Type",
+	"f": "From",
+	"i": "Call-ID",
+	"l": "Content-Length",
+	"t": "To",
+	"v": "Via",
+}
+
+// irregularNames maps header names that do not follow the MIME
+// capitalisation to their canonical forms.
+var irregularNames = map[string]string{
+	"call-id": "Call-ID",
+	"cseq":    "CSeq",
+}
+
+// canonicalName returns the canonical form of a header name.
+func canonicalName(n string) string {
+	ln := strings.ToLower(n)
+	if l, ok := compactNames[ln]; ok {
+		return l
+	}
+	if l, ok := irregularNames[ln]; ok {
+		return l
+	}
+	return textproto.CanonicalMIMEHeaderKey(n)
+}
+
+// Get returns the value of the first field with the name, or an empty string
+// if there is no such field.
+func (h Header) Get(name string) string {
+	name = canonicalName(name)
+	for _, f := range h {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// Values returns the values of all fields with the name, in order.
+func (h Header) Values(name string) []string {
+	name = canonicalName(name)
+	var vv []string
+	for _, f := range h {
+		if f.Name == name {
+			vv = append(vv, f.Value)
+		}
+	}
+	return vv
+}
+
+// Add appends a field to the header.
+func (h *Header) Add(name, value string) {
+	*h = append(*h, HeaderField{canonicalName(name), value})
+}
+
+// Set replaces the value of the first field with the name, and removes any
+// others, or appends the field if there is none.
+func (h *Header) Set(name, value string) {
+	name = canonicalName(name)
+	found := false
+	n := (*h)[:0]
+	for _, f := range *h {
+		if f.Name == name {
+			if found {
+				continue
+			}
+			found = true
+			f.Value = value
+		}
+		n = append(n, f)
+	}
+	if !found {
+		n = append(n, HeaderField{name, value})
+	}
+	*h = n
+}
+
+// Del removes all fields with the name.
+func (h *Header) Del(name string) {
+	name = canonicalName(name)
+	n := (*h)[:0]
+	for _, f := range *h {
+		if f.Name != name {
+			n = append(n, f)
+		}
+	}
+	*h = n
+}
+
+// Message is a SIP request or response.
+//
+// Only the features required to carry SMS are supported - there is no
+// support for dialogs, authentication or multipart bodies.
+type Message struct {
+	// Method is the method of a request, and empty for a response.
+	Method string
+
+	// RequestURI is the Request-URI of a request.
+	RequestURI string
+
+	// StatusCode is the status code of a response.
+	StatusCode int
+
+	// Reason is the reason phrase of a response.
+	Reason string
+
+	Header Header
+
+	Body []byte
+}
+
+// IsRequest indicates if the message is a request rather than a response.
+func (m *Message) IsRequest() bool {
+	return m.Method != ""
+}
+
+// MarshalBinary encodes the message into its binary form.
+//
+// The Content-Length is set to match the body.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	var b bytes.Buffer
+	if m.IsRequest() {
+		if m.RequestURI == "" {
+			return nil, ErrMissingRequestURI
+		}
+		b.WriteString(m.Method + " " + m.RequestURI + " " + sipVersion + "\r\n")
+	} else {
+		if m.StatusCode < 100 || m.StatusCode > 699 {
+			return nil, ErrInvalidStatusCode(m.StatusCode)
+		}
+		b.WriteString(sipVersion + " " + strconv.Itoa(m.StatusCode) + " " + m.Reason + "\r\n")
+	}
+	for _, f := range m.Header {
+		if f.Name == "Content-Length" {
+			continue
+		}
+		b.WriteString(f.Name + ": " + f.Value + "\r\n")
+	}
+	b.WriteString("Content-Length: " + strconv.Itoa(len(m.Body)) + "\r\n\r\n")
+	b.Write(m.Body)
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary decodes the message from its binary form, such as a UDP
+// datagram.
+//
+// If there is no Content-Length then the body extends to the end of the
+// data.
+func (m *Message) UnmarshalBinary(b []byte) error {
+	r := bufio.NewReader(bytes.NewReader(b))
+	n, err := readHeader(r)
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrMalformed
+		}
+		return err
+	}
+	rest, _ := ioutil.ReadAll(r)
+	if cl := n.Header.Get("Content-Length"); cl != "" {
+		l, err := strconv.Atoi(strings.TrimSpace(cl))
+		if err != nil || l < 0 || l > len(rest) {
+			return ErrInvalidContentLength(cl)
+		}
+		rest = rest[:l]
+	}
+	if len(rest) > 0 {
+		n.Body = rest
+	}
+	*m = *n
+	return nil
+}
+
+// ReadMessage reads a SIP message from a stream, such as a TCP connection.
+//
+// The length of the body is determined by the Content-Length, which is
+// assumed to be zero if absent, and must not exceed MaxMessageSize.
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	m, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	cl := m.Header.Get("Content-Length")
+	if cl == "" {
+		return m, nil
+	}
+	l, err := strconv.Atoi(strings.TrimSpace(cl))
+	if err != nil || l < 0 || l > MaxMessageSize {
+		return nil, ErrInvalidContentLength(cl)
+	}
+	if l > 0 {
+		m.Body = make([]byte, l)
+		if _, err := io.ReadFull(r, m.Body); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// WriteMessage writes a SIP message to a stream.
+func WriteMessage(w io.Writer, m *Message) error {
+	b, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// readHeader reads the start line and header fields of a message.
+func readHeader(r *bufio.Reader) (*Message, error) {
+	tr := textproto.NewReader(r)
+	var line string
+	var err error
+	// RFC 3261 Section 7.5 - ignore CRLFs preceding the start line.
+	for line == "" {
+		if line, err = tr.ReadLine(); err != nil {
+			return nil, err
+		}
+	}
+	m := Message{}
+	if err = m.parseStartLine(line); err != nil {
+		return nil, err
+	}
+	for {
+		line, err = tr.ReadContinuedLine()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if line == "" {
+			break
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 1 {
+			return nil, ErrInvalidHeader(line)
+		}
+		m.Header.Add(strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]))
+	}
+	return &m, nil
+}
+
+func (m *Message) parseStartLine(line string) error {
+	ff := strings.SplitN(line, " ", 3)
+	if len(ff) != 3 {
+		return ErrInvalidStartLine(line)
+	}
+	if ff[0] == sipVersion {
+		c, err := strconv.Atoi(ff[1])
+		if err != nil || c < 100 || c > 699 {
+			return ErrInvalidStartLine(line)
+		}
+		m.StatusCode = c
+		m.Reason = ff[2]
+		return nil
+	}
+	if ff[2] != sipVersion || ff[0] == "" || ff[1] == "" {
+		return ErrInvalidStartLine(line)
+	}
+	m.Method = ff[0]
+	m.RequestURI = ff[1]
+	return nil
+}
+
+// Response creates a response to the request.
+//
+// The Via, From, To, Call-ID and CSeq are copied from the request, with a
+// tag added to the To if it has none.
+func (m *Message) Response(code int, reason string) *Message {
+	r := Message{StatusCode: code, Reason: reason}
+	for _, f := range m.Header {
+		switch f.Name {
+		case "Via", "From", "Call-ID", "CSeq":
+			r.Header = append(r.Header, f)
+		case "To":
+			if !strings.Contains(f.Value, ";tag=") {
+				f.Value += ";tag=" + newToken()
+			}
+			r.Header = append(r.Header, f)
+		}
+	}
+	return &r
+}
+
+// addrSpec returns the URI from a From or To header value, stripping any
+// display name and header parameters.
+func addrSpec(v string) string {
+	if i := strings.IndexByte(v, '<'); i >= 0 {
+		v = v[i+1:]
+		if j := strings.IndexByte(v, '>'); j >= 0 {
+			return v[:j]
+		}
+		return v
+	}
+	if i := strings.IndexByte(v, ';'); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v)
+}
+
+// newToken returns a random token suitable for a tag, branch or Call-ID.
+func newToken() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ims_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/ims"
+)
+
+const request = "MESSAGE tel:+639170000293 SIP/2.0\r\n" +
+	"Via: SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK1234\r\n" +
+	"From: <sip:+6391@ims.example.com>;tag=abcd\r\n" +
+	"To: <tel:+639170000293>\r\n" +
+	"Call-ID: 1234@10.0.0.1\r\n" +
+	"CSeq: 1 MESSAGE\r\n" +
+	"Content-Type: application/vnd.3gpp.sms\r\n" +
+	"Content-Length: 3\r\n" +
+	"\r\n" +
+	"\x06\x2a\x00"
+
+func requestMsg() *ims.Message {
+	return &ims.Message{
+		Method:     "MESSAGE",
+		RequestURI: "tel:+639170000293",
+		Header: ims.Header{
+			{"Via", "SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK1234"},
+			{"From", "<sip:+6391@ims.example.com>;tag=abcd"},
+			{"To", "<tel:+639170000293>"},
+			{"Call-ID", "1234@10.0.0.1"},
+			{"CSeq", "1 MESSAGE"},
+			{"Content-Type", "application/vnd.3gpp.sms"},
+			{"Content-Length", "3"},
+		},
+		Body: []byte{0x06, 0x2a, 0x00},
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	b, err := requestMsg().MarshalBinary()
+	require.Nil(t, err)
+	assert.Equal(t, request, string(b))
+
+	r := ims.Message{StatusCode: 202, Reason: "Accepted"}
+	b, err = r.MarshalBinary()
+	require.Nil(t, err)
+	assert.Equal(t, "SIP/2.0 202 Accepted\r\nContent-Length: 0\r\n\r\n", string(b))
+}
+
+func TestMarshalBinaryError(t *testing.T) {
+	m := ims.Message{Method: "MESSAGE"}
+	_, err := m.MarshalBinary()
+	assert.Equal(t, ims.ErrMissingRequestURI, err)
+
+	m = ims.Message{StatusCode: 99}
+	_, err = m.MarshalBinary()
+	assert.Equal(t, ims.ErrInvalidStatusCode(99), err)
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	m := ims.Message{}
+	err := m.UnmarshalBinary([]byte(request))
+	require.Nil(t, err)
+	assert.Equal(t, requestMsg(), &m)
+
+	// compact form, no Content-Length, and a folded header
+	in := "\r\nSIP/2.0 200 OK\r\ni: 1234\r\nv: SIP/2.0/UDP\r\n  10.0.0.1\r\nl: 0\r\n\r\n"
+	m = ims.Message{}
+	err = m.UnmarshalBinary([]byte(in))
+	require.Nil(t, err)
+	assert.Equal(t, 200, m.StatusCode)
+	assert.Equal(t, "OK", m.Reason)
+	assert.Equal(t, "1234", m.Header.Get("call-id"))
+	assert.Equal(t, "SIP/2.0/UDP 10.0.0.1", m.Header.Get("Via"))
+	assert.Nil(t, m.Body)
+
+	m = ims.Message{}
+	err = m.UnmarshalBinary([]byte("MESSAGE sip:a SIP/2.0\r\nCSEQ: 2 MESSAGE\r\n\r\nhello"))
+	require.Nil(t, err)
+	assert.Equal(t, "2 MESSAGE", m.Header.Get("CSeq"))
+	assert.Equal(t, []byte("hello"), m.Body)
+}
+
+func TestUnmarshalBinaryError(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{"empty", "", ims.ErrMalformed},
+		{"truncated", "MESSAGE sip:a SIP/2.0\r\nTo: x\r\n", ims.ErrMalformed},
+		{"start line", "MESSAGE sip:a\r\n\r\n", ims.ErrInvalidStartLine("MESSAGE sip:a")},
+		{"version", "MESSAGE sip:a SIP/3.0\r\n\r\n", ims.ErrInvalidStartLine("MESSAGE sip:a SIP/3.0")},
+		{"status", "SIP/2.0 2000 OK\r\n\r\n", ims.ErrInvalidStartLine("SIP/2.0 2000 OK")},
+		{"header", "SIP/2.0 200 OK\r\nbogus\r\n\r\n", ims.ErrInvalidHeader("bogus")},
+		{"content length", "SIP/2.0 200 OK\r\nl: 4\r\n\r\nabc", ims.ErrInvalidContentLength("4")},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			m := ims.Message{}
+			err := m.UnmarshalBinary([]byte(p.in))
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, ims.Message{}, m)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	r := bufio.NewReader(bytes.NewBufferString(request + request[:len(request)-2]))
+	m, err := ims.ReadMessage(r)
+	require.Nil(t, err)
+	assert.Equal(t, requestMsg(), m)
+	m, err = ims.ReadMessage(r)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Nil(t, m)
+
+	patterns := []struct {
+		name string
+		cl   string
+	}{
+		{"negative", "-1"},
+		{"invalid", "x"},
+		{"oversize", "65536"},
+		{"huge", "9999999999"},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r := bufio.NewReader(bytes.NewBufferString(
+				"MESSAGE sip:a SIP/2.0\r\nContent-Length: " + p.cl + "\r\n\r\nhello"))
+			m, err := ims.ReadMessage(r)
+			assert.Equal(t, ims.ErrInvalidContentLength(p.cl), err)
+			assert.Nil(t, m)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestWriteMessage(t *testing.T) {
+	var b bytes.Buffer
+	err := ims.WriteMessage(&b, requestMsg())
+	require.Nil(t, err)
+	assert.Equal(t, request, b.String())
+}
+
+func TestHeader(t *testing.T) {
+	h := ims.Header{}
+	h.Add("v", "one")
+	h.Add("To", "x")
+	h.Add("Via", "two")
+	assert.Equal(t, []string{"one", "two"}, h.Values("VIA"))
+	h.Set("via", "three")
+	assert.Equal(t, ims.Header{{"Via", "three"}, {"To", "x"}}, h)
+	h.Set("Call-Id", "id")
+	assert.Equal(t, "id", h.Get("i"))
+	h.Del("t")
+	assert.Equal(t, ims.Header{{"Via", "three"}, {"Call-ID", "id"}}, h)
+	assert.Equal(t, "", h.Get("To"))
+}
+
+func TestResponse(t *testing.T) {
+	r := requestMsg().Response(202, "Accepted")
+	assert.Equal(t, 202, r.StatusCode)
+	assert.Equal(t, "Accepted", r.Reason)
+	assert.False(t, r.IsRequest())
+	assert.Equal(t, "SIP/2.0/UDP 10.0.0.1:5060;branch=z9hG4bK1234", r.Header.Get("Via"))
+	assert.Equal(t, "<sip:+6391@ims.example.com>;tag=abcd", r.Header.Get("From"))
+	assert.Regexp(t, "^<tel:\\+639170000293>;tag=[0-9a-f]+$", r.Header.Get("To"))
+	assert.Equal(t, "1234@10.0.0.1", r.Header.Get("Call-ID"))
+	assert.Equal(t, "1 MESSAGE", r.Header.Get("CSeq"))
+	assert.Equal(t, "", r.Header.Get("Content-Type"))
+}
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package ims provides the encapsulation of SMS in SIP MESSAGE requests, as
+// used for SMS over IMS and defined in 3GPP TS 24.341.
+//
+// The body of each MESSAGE is an RP message, of type application/vnd.3gpp.sms,
+// with the RP-DATA carrying the TPDU, and the RP-ACK or RP-ERROR returned in
+// a separate MESSAGE that references the original with an In-Reply-To.
+package ims
+
+import (
+	"mime"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+const (
+	// ContentType is the MIME type of MESSAGE bodies carrying RP messages.
+	ContentType = "application/vnd.3gpp.sms"
+
+	// MethodMessage is the SIP MESSAGE method.
+	MethodMessage = "MESSAGE"
+
+	// featureTag is the media feature tag indicating support for SMS over
+	// IP, as defined in 3GPP TS 24.341 Section 5.3.2.4.
+	featureTag = "+g.3gpp.smsip"
+)
+
+// TelURI returns the tel URI for an address.
+//
+// International numbers are prefixed with '+'.
+func TelURI(a tpdu.Address) string {
+	return "tel:" + a.Number()
+}
+
+// Number returns the telephone number from a tel URI, or from a SIP URI
+// with a telephone number user part.
+//
+// Returns an empty string if the URI contains no number.
+func Number(uri string) string {
+	uri = addrSpec(uri)
+	var n string
+	switch {
+	case strings.HasPrefix(uri, "tel:"):
+		n = uri[4:]
+	case strings.HasPrefix(uri, "sip:"), strings.HasPrefix(uri, "sips:"):
+		n = uri[strings.IndexByte(uri, ':')+1:]
+		i := strings.IndexByte(n, '@')
+		if i < 0 {
+			return ""
+		}
+		n = n[:i]
+	default:
+		return ""
+	}
+	if i := strings.IndexByte(n, ';'); i >= 0 {
+		n = n[:i]
+	}
+	for i, r := range n {
+		if (r < '0' || r > '9') && (r != '+' || i != 0) {
+			return ""
+		}
+	}
+	return n
+}
+
+// NewRequest creates a MESSAGE request, addressed to the Request-URI from the
+// originator URI, carrying the RP message.
+//
+// Messages from the network to the MS are marked with the SMS over IP
+// feature tag, and as not to be forked, so they are only routed to UEs
+// supporting SMS over IP.
+//
+// The Via is left for the transport to add.
+func NewRequest(requestURI, from string, r *rp.Message) (*Message, error) {
+	body, err := r.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	m := Message{
+		Method:     MethodMessage,
+		RequestURI: requestURI,
+		Body:       body,
+	}
+	m.Header.Add("Max-Forwards", "70")
+	m.Header.Add("From", "<"+from+">;tag="+newToken())
+	m.Header.Add("To", "<"+requestURI+">")
+	m.Header.Add("Call-ID", newToken())
+	m.Header.Add("CSeq", "1 "+MethodMessage)
+	if r.Type.Direction() == tpdu.MT {
+		m.Header.Add("Accept-Contact", "*;"+featureTag)
+		m.Header.Add("Request-Disposition", "no-fork")
+	}
+	m.Header.Add("Content-Type", ContentType)
+	return &m, nil
+}
+
+// NewSubmit creates a MESSAGE request from the UE, carrying an RP-DATA
+// containing the SMS-SUBMIT, addressed to the SC.
+//
+// The Request-URI is the tel URI of the SC. Where the SC has a PSI,
+// NewRequest should be used instead.
+func NewSubmit(from string, sc pdumode.SMSCAddress, mr byte, t *tpdu.TPDU) (*Message, error) {
+	r := rp.Message{Type: rp.DataMSToN, MR: mr, DA: sc, UD: t}
+	return NewRequest(TelURI(sc.Address), from, &r)
+}
+
+// NewDeliver creates a MESSAGE request to the UE, carrying an RP-DATA
+// containing the SMS-DELIVER or SMS-STATUS-REPORT, originating from the SC.
+func NewDeliver(to string, sc pdumode.SMSCAddress, mr byte, t *tpdu.TPDU) (*Message, error) {
+	r := rp.Message{Type: rp.DataNToMS, MR: mr, OA: sc, UD: t}
+	return NewRequest(to, TelURI(sc.Address), &r)
+}
+
+// RP decodes the RP message carried in a MESSAGE request.
+func (m *Message) RP() (*rp.Message, error) {
+	if m.Method != MethodMessage {
+		return nil, ErrNotMessage
+	}
+	ct := m.Header.Get("Content-Type")
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil || mt != ContentType {
+		return nil, ErrUnsupportedContentType(ct)
+	}
+	var r rp.Message
+	if err := r.UnmarshalBinary(m.Body); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// TPDU decodes the TPDU carried in the RP-User-Data of a MESSAGE request.
+//
+// Returns nil, and no error, if the RP message carries no RP-User-Data, as
+// may be the case for RP-ACK and RP-ERROR.
+func (m *Message) TPDU() (*tpdu.TPDU, error) {
+	r, err := m.RP()
+	if err != nil {
+		return nil, err
+	}
+	return r.UD, nil
+}
+
+// NewAck creates the MESSAGE request carrying the RP-ACK in reply to the
+// RP-DATA or RP-SMMA carried in the request.
+//
+// The ud, if not nil, is the SMS-SUBMIT-REPORT or SMS-DELIVER-REPORT to
+// include in the RP-User-Data.
+func NewAck(req *Message, ud *tpdu.TPDU) (*Message, error) {
+	return newReport(req, rp.AckMSToN, 0, ud)
+}
+
+// NewError creates the MESSAGE request carrying the RP-ERROR, with the cause,
+// in reply to the RP-DATA or RP-SMMA carried in the request.
+//
+// The ud, if not nil, is the SMS-SUBMIT-REPORT or SMS-DELIVER-REPORT to
+// include in the RP-User-Data.
+func NewError(req *Message, cause rp.Cause, ud *tpdu.TPDU) (*Message, error) {
+	return newReport(req, rp.ErrorMSToN, cause, ud)
+}
+
+// newReport creates the MESSAGE request carrying the RP-ACK or RP-ERROR in
+// reply to the request.
+//
+// The mt is the MS to N form of the reply type, which is adjusted to suit
+// the direction of the request.
+func newReport(req *Message, mt rp.MessageType, cause rp.Cause, ud *tpdu.TPDU) (*Message, error) {
+	r, err := req.RP()
+	if err != nil {
+		return nil, err
+	}
+	switch r.Type {
+	case rp.DataMSToN, rp.SMMA:
+		mt++
+	case rp.DataNToMS:
+	default:
+		return nil, ErrUnexpectedRPType(r.Type)
+	}
+	rpt := rp.Message{Type: mt, MR: r.MR, Cause: cause, UD: ud}
+	body, err := rpt.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	to := addrSpec(req.Header.Get("From"))
+	m := Message{
+		Method:     MethodMessage,
+		RequestURI: to,
+		Body:       body,
+	}
+	m.Header.Add("Max-Forwards", "70")
+	m.Header.Add("From", "<"+addrSpec(req.Header.Get("To"))+">;tag="+newToken())
+	m.Header.Add("To", "<"+to+">")
+	m.Header.Add("Call-ID", newToken())
+	m.Header.Add("CSeq", "1 "+MethodMessage)
+	m.Header.Add("In-Reply-To", req.Header.Get("Call-ID"))
+	m.Header.Add("Content-Type", ContentType)
+	return &m, nil
+}
@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ims_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/ims"
+	"github.com/warthog618/sms/internal/fixture"
+)
+
+const (
+	// SMS-SUBMIT to 6391 containing "hello".
+	submitTPDU = "0142049136190000" + "05E8329BFD06"
+
+	// SMS-DELIVER from 6391 containing "Hahahaha".
+	deliverTPDU = "040491361900005150713220052308C8303A8C0EA3C3"
+
+	ue = "sip:+61412345678@ims.example.com"
+)
+
+var smsc = pdumode.SMSCAddress{
+	Address: tpdu.Address{Addr: "639170000293", TOA: 0x91},
+}
+
+func TestNewSubmit(t *testing.T) {
+	s := fixture.TPDU(t, tpdu.MO, submitTPDU)
+	m, err := ims.NewSubmit(ue, smsc, 0x2a, s)
+	require.Nil(t, err)
+	assert.Equal(t, "MESSAGE", m.Method)
+	assert.Equal(t, "tel:+639170000293", m.RequestURI)
+	assert.Equal(t, "<tel:+639170000293>", m.Header.Get("To"))
+	assert.Regexp(t, "^<"+regexp.QuoteMeta(ue)+">;tag=[0-9a-f]+$", m.Header.Get("From"))
+	assert.NotEqual(t, "", m.Header.Get("Call-ID"))
+	assert.Equal(t, "1 MESSAGE", m.Header.Get("CSeq"))
+	assert.Equal(t, ims.ContentType, m.Header.Get("Content-Type"))
+	assert.Equal(t, "", m.Header.Get("Accept-Contact"))
+
+	r, err := m.RP()
+	require.Nil(t, err)
+	assert.Equal(t, &rp.Message{Type: rp.DataMSToN, MR: 0x2a, DA: smsc, UD: s}, r)
+	p, err := m.TPDU()
+	require.Nil(t, err)
+	assert.Equal(t, s, p)
+	assert.Equal(t, tpdu.MO, p.Direction)
+}
+
+func TestNewDeliver(t *testing.T) {
+	d := fixture.TPDU(t, tpdu.MT, deliverTPDU)
+	m, err := ims.NewDeliver(ue, smsc, 0x12, d)
+	require.Nil(t, err)
+	assert.Equal(t, ue, m.RequestURI)
+	assert.Regexp(t, "^<tel:\\+639170000293>;tag=", m.Header.Get("From"))
+	assert.Equal(t, "*;+g.3gpp.smsip", m.Header.Get("Accept-Contact"))
+	assert.Equal(t, "no-fork", m.Header.Get("Request-Disposition"))
+
+	// round trip via the wire
+	b, err := m.MarshalBinary()
+	require.Nil(t, err)
+	n := ims.Message{}
+	err = n.UnmarshalBinary(b)
+	require.Nil(t, err)
+	p, err := n.TPDU()
+	require.Nil(t, err)
+	assert.Equal(t, d, p)
+}
+
+func TestRPError(t *testing.T) {
+	m := ims.Message{Method: "INVITE"}
+	_, err := m.RP()
+	assert.Equal(t, ims.ErrNotMessage, err)
+
+	m = ims.Message{Method: "MESSAGE", Header: ims.Header{{"Content-Type", "text/plain"}}}
+	_, err = m.TPDU()
+	assert.Equal(t, ims.ErrUnsupportedContentType("text/plain"), err)
+
+	m = ims.Message{
+		Method: "MESSAGE",
+		Header: ims.Header{{"Content-Type", "Application/Vnd.3gpp.SMS; x=1"}},
+		Body:   []byte{0x07, 0x2a},
+	}
+	_, err = m.RP()
+	assert.Equal(t, tpdu.NewDecodeError("type", 0, rp.ErrUnsupportedMessageType(7)), err)
+}
+
+func TestNewAck(t *testing.T) {
+	req, err := ims.NewSubmit(ue, smsc, 0x2a, fixture.TPDU(t, tpdu.MO, submitTPDU))
+	require.Nil(t, err)
+	m, err := ims.NewAck(req, nil)
+	require.Nil(t, err)
+	assert.Equal(t, ue, m.RequestURI)
+	assert.Equal(t, "<"+ue+">", m.Header.Get("To"))
+	assert.Regexp(t, "^<tel:\\+639170000293>;tag=", m.Header.Get("From"))
+	assert.Equal(t, req.Header.Get("Call-ID"), m.Header.Get("In-Reply-To"))
+	assert.NotEqual(t, req.Header.Get("Call-ID"), m.Header.Get("Call-ID"))
+	assert.Equal(t, []byte{0x03, 0x2a}, m.Body)
+}
+
+func TestNewError(t *testing.T) {
+	req, err := ims.NewDeliver(ue, smsc, 0x12, fixture.TPDU(t, tpdu.MT, deliverTPDU))
+	require.Nil(t, err)
+	report := fixture.TPDU(t, tpdu.MO, "00D300")
+	m, err := ims.NewError(req, rp.CauseMemoryCapacityExceeded, report)
+	require.Nil(t, err)
+	assert.Equal(t, "tel:+639170000293", m.RequestURI)
+	r, err := m.RP()
+	require.Nil(t, err)
+	expected := &rp.Message{
+		Type:  rp.ErrorMSToN,
+		MR:    0x12,
+		Cause: rp.CauseMemoryCapacityExceeded,
+		UD:    report,
+	}
+	assert.Equal(t, expected, r)
+
+	// not in reply to an RP-DATA
+	_, err = ims.NewError(m, rp.CauseCongestion, nil)
+	assert.Equal(t, ims.ErrUnexpectedRPType(rp.ErrorMSToN), err)
+}
+
+func TestNumber(t *testing.T) {
+	patterns := []struct {
+		uri string
+		n   string
+	}{
+		{"tel:+639170000293", "+639170000293"},
+		{"<tel:1234;phone-context=example.com>;tag=x", "1234"},
+		{"sip:+6391@ims.example.com;user=phone", "+6391"},
+		{"\"Bob\" <sips:6391@ims.example.com>", "6391"},
+		{"sip:bob@ims.example.com", ""},
+		{"sip:ims.example.com", ""},
+		{"mailto:bob@example.com", ""},
+		{"tel:12+34", ""},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			assert.Equal(t, p.n, ims.Number(p.uri))
+		}
+		t.Run(p.uri, f)
+	}
+	assert.Equal(t, "tel:+639170000293", ims.TelURI(smsc.Address))
+}
@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package fixture provides helpers for building test fixtures from the hex
+// encodings found in specifications and traces.
+package fixture
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Hex returns the bytes encoded in the hex string, failing the test if the
+// string is not valid hex.
+func Hex(t testing.TB, h string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(h)
+	require.Nil(t, err)
+	return b
+}
+
+// TPDU returns the TPDU, with the given direction, encoded in the hex string,
+// failing the test if the TPDU cannot be decoded.
+func TPDU(t testing.TB, d tpdu.Direction, h string) *tpdu.TPDU {
+	t.Helper()
+	pdu := tpdu.TPDU{Direction: d}
+	err := pdu.UnmarshalBinary(Hex(t, h))
+	require.Nil(t, err)
+	return &pdu
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem
+
+import "errors"
+
+// ErrorClass classifies errors by whether, and when, a failed request may be
+// repeated.
+//
+// CMSError and CMEError match their class using errors.Is, e.g.
+//
+//	if errors.Is(err, modem.ClassTemporary) {
+//	    // try again later
+//	}
+type ErrorClass int
+
+const (
+	// ClassPermanent indicates the request will fail again if repeated.
+	ClassPermanent ErrorClass = iota
+
+	// ClassTemporary indicates the cause of the failure may clear over time,
+	// so the request may succeed if repeated after a delay.
+	ClassTemporary
+
+	// ClassRetryable indicates a transient failure, so the request may be
+	// repeated immediately.
+	ClassRetryable
+)
+
+var classNames = map[ErrorClass]string{
+	ClassPermanent: "permanent",
+	ClassTemporary: "temporary",
+	ClassRetryable: "retryable",
+}
+
+func (c ErrorClass) Error() string {
+	return "modem: " + c.String() + " error"
+}
+
+func (c ErrorClass) String() string {
+	if n, ok := classNames[c]; ok {
+		return n
+	}
+	return "unknown"
+}
+
+// ClassOf returns the class of an error returned by the Modem.
+//
+// ErrTimeout is retryable, and errors without a class are permanent.
+func ClassOf(err error) ErrorClass {
+	var cms CMSError
+	if errors.As(err, &cms) {
+		return cms.Class()
+	}
+	var cme CMEError
+	if errors.As(err, &cme) {
+		return cme.Class()
+	}
+	if errors.Is(err, ErrTimeout) {
+		return ClassRetryable
+	}
+	return ClassPermanent
+}
+
+// codeInfo describes a +CMS or +CME ERROR code.
+type codeInfo struct {
+	desc  string
+	class ErrorClass
+}
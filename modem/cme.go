@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem
+
+import "fmt"
+
+// CMEError is the code returned by the modem in a +CME ERROR final result, as
+// per 3GPP TS 27.007 Section 9.2.
+type CMEError int
+
+// +CME ERROR codes, as per 3GPP TS 27.007 Section 9.2.
+//
+// The general errors are from Section 9.2.1, and the GPRS and EPS related
+// errors, codes 103 to 181, from Section 9.2.2.
+const (
+	// General errors
+
+	CMEPhoneFailure                    CMEError = 0
+	CMENoConnection                    CMEError = 1
+	CMELinkReserved                    CMEError = 2
+	CMEOperationNotAllowed             CMEError = 3
+	CMEOperationNotSupported           CMEError = 4
+	CMEPHSIMPINRequired                CMEError = 5
+	CMEPHFSIMPINRequired               CMEError = 6
+	CMEPHFSIMPUKRequired               CMEError = 7
+	CMESIMNotInserted                  CMEError = 10
+	CMESIMPINRequired                  CMEError = 11
+	CMESIMPUKRequired                  CMEError = 12
+	CMESIMFailure                      CMEError = 13
+	CMESIMBusy                         CMEError = 14
+	CMESIMWrong                        CMEError = 15
+	CMEIncorrectPassword               CMEError = 16
+	CMESIMPIN2Required                 CMEError = 17
+	CMESIMPUK2Required                 CMEError = 18
+	CMEMemoryFull                      CMEError = 20
+	CMEInvalidIndex                    CMEError = 21
+	CMENotFound                        CMEError = 22
+	CMEMemoryFailure                   CMEError = 23
+	CMETextTooLong                     CMEError = 24
+	CMEInvalidTextCharacters           CMEError = 25
+	CMEDialStringTooLong               CMEError = 26
+	CMEInvalidDialStringCharacters     CMEError = 27
+	CMENoNetworkService                CMEError = 30
+	CMENetworkTimeout                  CMEError = 31
+	CMEEmergencyCallsOnly              CMEError = 32
+	CMENetworkPINRequired              CMEError = 40
+	CMENetworkPUKRequired              CMEError = 41
+	CMENetworkSubsetPINRequired        CMEError = 42
+	CMENetworkSubsetPUKRequired        CMEError = 43
+	CMEServiceProviderPINRequired      CMEError = 44
+	CMEServiceProviderPUKRequired      CMEError = 45
+	CMECorporatePINRequired            CMEError = 46
+	CMECorporatePUKRequired            CMEError = 47
+	CMEHiddenKeyRequired               CMEError = 48
+	CMEEAPMethodNotSupported           CMEError = 49
+	CMEIncorrectParameters             CMEError = 50
+	CMECommandDisabled                 CMEError = 51
+	CMECommandAborted                  CMEError = 52
+	CMENotAttachedRestricted           CMEError = 53
+	CMEModemNotAllowedEmergencyOnly    CMEError = 54
+	CMEOperationNotAllowedRestricted   CMEError = 55
+	CMEFixedDialNumberOnly             CMEError = 56
+	CMETemporarilyOutOfService         CMEError = 57
+	CMELanguageNotSupported            CMEError = 58
+	CMEUnexpectedDataValue             CMEError = 59
+	CMESystemFailure                   CMEError = 60
+	CMEDataMissing                     CMEError = 61
+	CMECallBarred                      CMEError = 62
+	CMEMessageWaitingIndicationFailure CMEError = 63
+	CMEUnknown                         CMEError = 100
+
+	// GPRS and EPS related errors
+
+	// failure to attach
+
+	CMEIllegalMS                    CMEError = 103
+	CMEIllegalME                    CMEError = 106
+	CMEGPRSNotAllowed               CMEError = 107
+	CMEGPRSAndNonGPRSNotAllowed     CMEError = 108
+	CMEPLMNNotAllowed               CMEError = 111
+	CMELocationAreaNotAllowed       CMEError = 112
+	CMERoamingNotAllowed            CMEError = 113
+	CMEGPRSNotAllowedInPLMN         CMEError = 114
+	CMENoSuitableCells              CMEError = 115
+	CMECongestion                   CMEError = 122
+	CMENotAuthorizedForCSG          CMEError = 125
+	CMESemanticallyIncorrectMessage CMEError = 172
+	CMEMandatoryIEError             CMEError = 173
+	CMEIENotImplemented             CMEError = 174
+	CMEConditionalIEError           CMEError = 175
+	CMEProtocolError                CMEError = 176
+
+	// failure to activate a context
+
+	CMEInsufficientResources        CMEError = 126
+	CMEMissingOrUnknownAPN          CMEError = 127
+	CMEUnknownPDPAddressOrType      CMEError = 128
+	CMEUserAuthenticationFailed     CMEError = 129
+	CMEActivationRejectedByGateway  CMEError = 130
+	CMEActivationRejected           CMEError = 131
+	CMEServiceOptionNotSupported    CMEError = 132
+	CMEServiceOptionNotSubscribed   CMEError = 133
+	CMEServiceOptionOutOfOrder      CMEError = 134
+	CMEFeatureNotSupported          CMEError = 140
+	CMETFTSemanticError             CMEError = 141
+	CMETFTSyntacticalError          CMEError = 142
+	CMEUnknownPDPContext            CMEError = 143
+	CMEPacketFilterSemanticError    CMEError = 144
+	CMEPacketFilterSyntacticalError CMEError = 145
+	CMEPDPContextWithoutTFT         CMEError = 146
+	CMEOperatorDeterminedBarring    CMEError = 177
+	CMEMaxPDPContextsReached        CMEError = 178
+	CMEAPNNotSupportedInRAT         CMEError = 179
+	CMEBearerControlModeViolation   CMEError = 180
+	CMEUnsupportedQCI               CMEError = 181
+
+	// failure to disconnect a PDN
+
+	CMELastPDNDisconnectionNotAllowed CMEError = 171
+
+	// other GPRS errors
+
+	CMEUnspecifiedGPRSError           CMEError = 148
+	CMEPDPAuthenticationFailure       CMEError = 149
+	CMEInvalidMobileClass             CMEError = 150
+	CMEVBSVGCSNotSupported            CMEError = 151
+	CMENoServiceSubscription          CMEError = 152
+	CMENoGroupIDSubscription          CMEError = 153
+	CMEGroupIDNotActivated            CMEError = 154
+	CMENoMatchingNotification         CMEError = 155
+	CMEVBSVGCSCallPresent             CMEError = 156
+	CMEVBSVGCSCongestion              CMEError = 157
+	CMENetworkFailure                 CMEError = 158
+	CMEUplinkBusy                     CMEError = 159
+	CMENoSIMFileAccessRights          CMEError = 160
+	CMENoPrioritySubscription         CMEError = 161
+	CMEOperationNotApplicable         CMEError = 162
+	CMEGroupIDPrefixesNotSupported    CMEError = 163
+	CMEGroupIDPrefixesNotUsableForVBS CMEError = 164
+	CMEGroupIDPrefixInvalid           CMEError = 165
+)
+
+var cmeCodes = map[CMEError]codeInfo{
+	CMEPhoneFailure:                    {"phone failure", ClassTemporary},
+	CMENoConnection:                    {"no connection to phone", ClassTemporary},
+	CMELinkReserved:                    {"phone-adaptor link reserved", ClassRetryable},
+	CMEOperationNotAllowed:             {"operation not allowed", ClassPermanent},
+	CMEOperationNotSupported:           {"operation not supported", ClassPermanent},
+	CMEPHSIMPINRequired:                {"PH-SIM PIN required", ClassPermanent},
+	CMEPHFSIMPINRequired:               {"PH-FSIM PIN required", ClassPermanent},
+	CMEPHFSIMPUKRequired:               {"PH-FSIM PUK required", ClassPermanent},
+	CMESIMNotInserted:                  {"SIM not inserted", ClassPermanent},
+	CMESIMPINRequired:                  {"SIM PIN required", ClassPermanent},
+	CMESIMPUKRequired:                  {"SIM PUK required", ClassPermanent},
+	CMESIMFailure:                      {"SIM failure", ClassPermanent},
+	CMESIMBusy:                         {"SIM busy", ClassRetryable},
+	CMESIMWrong:                        {"SIM wrong", ClassPermanent},
+	CMEIncorrectPassword:               {"incorrect password", ClassPermanent},
+	CMESIMPIN2Required:                 {"SIM PIN2 required", ClassPermanent},
+	CMESIMPUK2Required:                 {"SIM PUK2 required", ClassPermanent},
+	CMEMemoryFull:                      {"memory full", ClassTemporary},
+	CMEInvalidIndex:                    {"invalid index", ClassPermanent},
+	CMENotFound:                        {"not found", ClassPermanent},
+	CMEMemoryFailure:                   {"memory failure", ClassTemporary},
+	CMETextTooLong:                     {"text string too long", ClassPermanent},
+	CMEInvalidTextCharacters:           {"invalid characters in text string", ClassPermanent},
+	CMEDialStringTooLong:               {"dial string too long", ClassPermanent},
+	CMEInvalidDialStringCharacters:     {"invalid characters in dial string", ClassPermanent},
+	CMENoNetworkService:                {"no network service", ClassTemporary},
+	CMENetworkTimeout:                  {"network timeout", ClassRetryable},
+	CMEEmergencyCallsOnly:              {"network not allowed - emergency calls only", ClassTemporary},
+	CMENetworkPINRequired:              {"network personalization PIN required", ClassPermanent},
+	CMENetworkPUKRequired:              {"network personalization PUK required", ClassPermanent},
+	CMENetworkSubsetPINRequired:        {"network subset personalization PIN required", ClassPermanent},
+	CMENetworkSubsetPUKRequired:        {"network subset personalization PUK required", ClassPermanent},
+	CMEServiceProviderPINRequired:      {"service provider personalization PIN required", ClassPermanent},
+	CMEServiceProviderPUKRequired:      {"service provider personalization PUK required", ClassPermanent},
+	CMECorporatePINRequired:            {"corporate personalization PIN required", ClassPermanent},
+	CMECorporatePUKRequired:            {"corporate personalization PUK required", ClassPermanent},
+	CMEHiddenKeyRequired:               {"hidden key required", ClassPermanent},
+	CMEEAPMethodNotSupported:           {"EAP method not supported", ClassPermanent},
+	CMEIncorrectParameters:             {"incorrect parameters", ClassPermanent},
+	CMECommandDisabled:                 {"command implemented but currently disabled", ClassPermanent},
+	CMECommandAborted:                  {"command aborted by user", ClassRetryable},
+	CMENotAttachedRestricted:           {"not attached to network due to MT functionality restrictions", ClassTemporary},
+	CMEModemNotAllowedEmergencyOnly:    {"modem not allowed - MT restricted to emergency calls only", ClassTemporary},
+	CMEOperationNotAllowedRestricted:   {"operation not allowed because of MT functionality restrictions", ClassTemporary},
+	CMEFixedDialNumberOnly:             {"fixed dial number only allowed", ClassPermanent},
+	CMETemporarilyOutOfService:         {"temporarily out of service due to other MT usage", ClassTemporary},
+	CMELanguageNotSupported:            {"language/alphabet not supported", ClassPermanent},
+	CMEUnexpectedDataValue:             {"unexpected data value", ClassPermanent},
+	CMESystemFailure:                   {"system failure", ClassTemporary},
+	CMEDataMissing:                     {"data missing", ClassPermanent},
+	CMECallBarred:                      {"call barred", ClassPermanent},
+	CMEMessageWaitingIndicationFailure: {"message waiting indication subscription failure", ClassPermanent},
+	CMEUnknown:                         {"unknown", ClassTemporary},
+
+	CMEIllegalMS:                    {"illegal MS", ClassPermanent},
+	CMEIllegalME:                    {"illegal ME", ClassPermanent},
+	CMEGPRSNotAllowed:               {"GPRS services not allowed", ClassPermanent},
+	CMEGPRSAndNonGPRSNotAllowed:     {"GPRS services and non-GPRS services not allowed", ClassPermanent},
+	CMEPLMNNotAllowed:               {"PLMN not allowed", ClassPermanent},
+	CMELocationAreaNotAllowed:       {"location area not allowed", ClassPermanent},
+	CMERoamingNotAllowed:            {"roaming not allowed in this location area", ClassPermanent},
+	CMEGPRSNotAllowedInPLMN:         {"GPRS services not allowed in this PLMN", ClassPermanent},
+	CMENoSuitableCells:              {"no suitable cells in location area", ClassTemporary},
+	CMECongestion:                   {"congestion", ClassTemporary},
+	CMENotAuthorizedForCSG:          {"not authorized for this CSG", ClassPermanent},
+	CMESemanticallyIncorrectMessage: {"semantically incorrect message", ClassPermanent},
+	CMEMandatoryIEError:             {"mandatory information element error", ClassPermanent},
+	CMEIENotImplemented:             {"information element non-existent or not implemented", ClassPermanent},
+	CMEConditionalIEError:           {"conditional IE error", ClassPermanent},
+	CMEProtocolError:                {"protocol error, unspecified", ClassPermanent},
+
+	CMEInsufficientResources:        {"insufficient resources", ClassTemporary},
+	CMEMissingOrUnknownAPN:          {"missing or unknown APN", ClassPermanent},
+	CMEUnknownPDPAddressOrType:      {"unknown PDP address or PDP type", ClassPermanent},
+	CMEUserAuthenticationFailed:     {"user authentication failed", ClassPermanent},
+	CMEActivationRejectedByGateway:  {"activation rejected by GGSN, Serving GW or PDN GW", ClassPermanent},
+	CMEActivationRejected:           {"activation rejected, unspecified", ClassTemporary},
+	CMEServiceOptionNotSupported:    {"service option not supported", ClassPermanent},
+	CMEServiceOptionNotSubscribed:   {"requested service option not subscribed", ClassPermanent},
+	CMEServiceOptionOutOfOrder:      {"service option temporarily out of order", ClassTemporary},
+	CMEFeatureNotSupported:          {"feature not supported", ClassPermanent},
+	CMETFTSemanticError:             {"semantic error in the TFT operation", ClassPermanent},
+	CMETFTSyntacticalError:          {"syntactical error in the TFT operation", ClassPermanent},
+	CMEUnknownPDPContext:            {"unknown PDP context", ClassPermanent},
+	CMEPacketFilterSemanticError:    {"semantic errors in packet filter(s)", ClassPermanent},
+	CMEPacketFilterSyntacticalError: {"syntactical errors in packet filter(s)", ClassPermanent},
+	CMEPDPContextWithoutTFT:         {"PDP context without TFT already activated", ClassPermanent},
+	CMEOperatorDeterminedBarring:    {"operator determined barring", ClassPermanent},
+	CMEMaxPDPContextsReached:        {"maximum number of PDP contexts reached", ClassTemporary},
+	CMEAPNNotSupportedInRAT:         {"requested APN not supported in current RAT and PLMN combination", ClassPermanent},
+	CMEBearerControlModeViolation:   {"request rejected, Bearer Control Mode violation", ClassPermanent},
+	CMEUnsupportedQCI:               {"unsupported QCI value", ClassPermanent},
+
+	CMELastPDNDisconnectionNotAllowed: {"last PDN disconnection not allowed", ClassPermanent},
+
+	CMEUnspecifiedGPRSError:           {"unspecified GPRS error", ClassTemporary},
+	CMEPDPAuthenticationFailure:       {"PDP authentication failure", ClassPermanent},
+	CMEInvalidMobileClass:             {"invalid mobile class", ClassPermanent},
+	CMEVBSVGCSNotSupported:            {"VBS/VGCS not supported by the network", ClassPermanent},
+	CMENoServiceSubscription:          {"no service subscription on SIM", ClassPermanent},
+	CMENoGroupIDSubscription:          {"no subscription for group ID", ClassPermanent},
+	CMEGroupIDNotActivated:            {"group ID not activated on SIM", ClassPermanent},
+	CMENoMatchingNotification:         {"no matching notification", ClassPermanent},
+	CMEVBSVGCSCallPresent:             {"VBS/VGCS call already present", ClassTemporary},
+	CMEVBSVGCSCongestion:              {"congestion", ClassTemporary},
+	CMENetworkFailure:                 {"network failure", ClassTemporary},
+	CMEUplinkBusy:                     {"uplink busy", ClassRetryable},
+	CMENoSIMFileAccessRights:          {"no access rights for SIM file", ClassPermanent},
+	CMENoPrioritySubscription:         {"no subscription for priority", ClassPermanent},
+	CMEOperationNotApplicable:         {"operation not applicable or not possible", ClassPermanent},
+	CMEGroupIDPrefixesNotSupported:    {"group ID prefixes not supported", ClassPermanent},
+	CMEGroupIDPrefixesNotUsableForVBS: {"group ID prefixes not usable for VBS", ClassPermanent},
+	CMEGroupIDPrefixInvalid:           {"group ID prefix value invalid", ClassPermanent},
+}
+
+func (e CMEError) Error() string {
+	if i, ok := cmeCodes[e]; ok {
+		return fmt.Sprintf("modem: +CME ERROR: %d (%s)", int(e), i.desc)
+	}
+	return fmt.Sprintf("modem: +CME ERROR: %d", int(e))
+}
+
+// Class returns the class of the error.
+//
+// Unlisted codes are permanent.
+func (e CMEError) Class() ErrorClass {
+	if i, ok := cmeCodes[e]; ok {
+		return i.class
+	}
+	return ClassPermanent
+}
+
+// Is returns true if the target is the ErrorClass of the error.
+func (e CMEError) Is(target error) bool {
+	c, ok := target.(ErrorClass)
+	return ok && c == e.Class()
+}
@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem
+
+import "fmt"
+
+// CMSError is the code returned by the modem in a +CMS ERROR final result, as
+// per 3GPP TS 27.005 Section 3.2.5.
+//
+// Codes 0-127 are RP causes, as per 3GPP TS 24.011 Annex E-2, and codes
+// 128-255 are TP-FCS values, as per 3GPP TS 23.040 Section 9.2.3.22, so a
+// TP-FCS returned in an SMS-SUBMIT-REPORT or SMS-DELIVER-REPORT may be
+// converted using CMSError(t.FCS).
+type CMSError int
+
+// +CMS ERROR codes, as per 3GPP TS 27.005 Section 3.2.5.
+const (
+	// RP causes
+
+	CMSUnassignedNumber                 CMSError = 1
+	CMSOperatorDeterminedBarring        CMSError = 8
+	CMSCallBarred                       CMSError = 10
+	CMSTransferRejected                 CMSError = 21
+	CMSMemoryCapacityExceeded           CMSError = 22
+	CMSDestinationOutOfService          CMSError = 27
+	CMSUnidentifiedSubscriber           CMSError = 28
+	CMSFacilityRejected                 CMSError = 29
+	CMSUnknownSubscriber                CMSError = 30
+	CMSNetworkOutOfOrder                CMSError = 38
+	CMSTemporaryFailure                 CMSError = 41
+	CMSCongestion                       CMSError = 42
+	CMSResourcesUnavailable             CMSError = 47
+	CMSFacilityNotSubscribed            CMSError = 50
+	CMSFacilityNotImplemented           CMSError = 69
+	CMSInvalidTransferReference         CMSError = 81
+	CMSSemanticallyIncorrectMessage     CMSError = 95
+	CMSInvalidMandatoryInformation      CMSError = 96
+	CMSMessageTypeNotImplemented        CMSError = 97
+	CMSMessageNotCompatible             CMSError = 98
+	CMSInformationElementNotImplemented CMSError = 99
+	CMSProtocolError                    CMSError = 111
+	CMSInterworking                     CMSError = 127
+
+	// TP-FCS values
+
+	CMSTelematicInterworkingNotSupported CMSError = 0x80
+	CMSType0NotSupported                 CMSError = 0x81
+	CMSCannotReplace                     CMSError = 0x82
+	CMSPIDError                          CMSError = 0x8f
+	CMSDCSNotSupported                   CMSError = 0x90
+	CMSMessageClassNotSupported          CMSError = 0x91
+	CMSDCSError                          CMSError = 0x9f
+	CMSCommandCannotBeActioned           CMSError = 0xa0
+	CMSCommandUnsupported                CMSError = 0xa1
+	CMSCommandError                      CMSError = 0xaf
+	CMSTPDUNotSupported                  CMSError = 0xb0
+	CMSSCBusy                            CMSError = 0xc0
+	CMSNoSCSubscription                  CMSError = 0xc1
+	CMSSCSystemFailure                   CMSError = 0xc2
+	CMSInvalidSMEAddress                 CMSError = 0xc3
+	CMSDestinationSMEBarred              CMSError = 0xc4
+	CMSDuplicateRejected                 CMSError = 0xc5
+	CMSVPFNotSupported                   CMSError = 0xc6
+	CMSVPNotSupported                    CMSError = 0xc7
+	CMSSIMStorageFull                    CMSError = 0xd0
+	CMSNoSIMStorage                      CMSError = 0xd1
+	CMSErrorInMS                         CMSError = 0xd2
+	CMSMemoryCapacityExceededMS          CMSError = 0xd3
+	CMSSATBusy                           CMSError = 0xd4
+	CMSSIMDataDownloadError              CMSError = 0xd5
+	CMSUnspecifiedFCS                    CMSError = 0xff
+
+	// ME and network errors
+
+	CMSMEFailure             CMSError = 300
+	CMSServiceReserved       CMSError = 301
+	CMSOperationNotAllowed   CMSError = 302
+	CMSOperationNotSupported CMSError = 303
+	CMSInvalidPDUParameter   CMSError = 304
+	CMSInvalidTextParameter  CMSError = 305
+	CMSSIMNotInserted        CMSError = 310
+	CMSSIMPINRequired        CMSError = 311
+	CMSPHSIMPINRequired      CMSError = 312
+	CMSSIMFailure            CMSError = 313
+	CMSSIMBusy               CMSError = 314
+	CMSSIMWrong              CMSError = 315
+	CMSSIMPUKRequired        CMSError = 316
+	CMSSIMPIN2Required       CMSError = 317
+	CMSSIMPUK2Required       CMSError = 318
+	CMSMemoryFailure         CMSError = 320
+	CMSInvalidMemoryIndex    CMSError = 321
+	CMSMemoryFull            CMSError = 322
+	CMSSMSCAddressUnknown    CMSError = 330
+	CMSNoNetworkService      CMSError = 331
+	CMSNetworkTimeout        CMSError = 332
+	CMSNoCNMAExpected        CMSError = 340
+	CMSUnknown               CMSError = 500
+)
+
+var cmsCodes = map[CMSError]codeInfo{
+	CMSUnassignedNumber:                 {"unassigned number", ClassPermanent},
+	CMSOperatorDeterminedBarring:        {"operator determined barring", ClassPermanent},
+	CMSCallBarred:                       {"call barred", ClassPermanent},
+	CMSTransferRejected:                 {"short message transfer rejected", ClassPermanent},
+	CMSMemoryCapacityExceeded:           {"memory capacity exceeded", ClassTemporary},
+	CMSDestinationOutOfService:          {"destination out of service", ClassTemporary},
+	CMSUnidentifiedSubscriber:           {"unidentified subscriber", ClassPermanent},
+	CMSFacilityRejected:                 {"facility rejected", ClassPermanent},
+	CMSUnknownSubscriber:                {"unknown subscriber", ClassPermanent},
+	CMSNetworkOutOfOrder:                {"network out of order", ClassTemporary},
+	CMSTemporaryFailure:                 {"temporary failure", ClassTemporary},
+	CMSCongestion:                       {"congestion", ClassTemporary},
+	CMSResourcesUnavailable:             {"resources unavailable", ClassTemporary},
+	CMSFacilityNotSubscribed:            {"requested facility not subscribed", ClassPermanent},
+	CMSFacilityNotImplemented:           {"requested facility not implemented", ClassPermanent},
+	CMSInvalidTransferReference:         {"invalid short message transfer reference value", ClassPermanent},
+	CMSSemanticallyIncorrectMessage:     {"semantically incorrect message", ClassPermanent},
+	CMSInvalidMandatoryInformation:      {"invalid mandatory information", ClassPermanent},
+	CMSMessageTypeNotImplemented:        {"message type non-existent or not implemented", ClassPermanent},
+	CMSMessageNotCompatible:             {"message not compatible with short message protocol state", ClassPermanent},
+	CMSInformationElementNotImplemented: {"information element non-existent or not implemented", ClassPermanent},
+	CMSProtocolError:                    {"protocol error", ClassPermanent},
+	CMSInterworking:                     {"interworking", ClassPermanent},
+
+	CMSTelematicInterworkingNotSupported: {"telematic interworking not supported", ClassPermanent},
+	CMSType0NotSupported:                 {"short message type 0 not supported", ClassPermanent},
+	CMSCannotReplace:                     {"cannot replace short message", ClassPermanent},
+	CMSPIDError:                          {"unspecified TP-PID error", ClassPermanent},
+	CMSDCSNotSupported:                   {"data coding scheme not supported", ClassPermanent},
+	CMSMessageClassNotSupported:          {"message class not supported", ClassPermanent},
+	CMSDCSError:                          {"unspecified TP-DCS error", ClassPermanent},
+	CMSCommandCannotBeActioned:           {"command cannot be actioned", ClassPermanent},
+	CMSCommandUnsupported:                {"command unsupported", ClassPermanent},
+	CMSCommandError:                      {"unspecified TP-Command error", ClassPermanent},
+	CMSTPDUNotSupported:                  {"TPDU not supported", ClassPermanent},
+	CMSSCBusy:                            {"SC busy", ClassTemporary},
+	CMSNoSCSubscription:                  {"no SC subscription", ClassPermanent},
+	CMSSCSystemFailure:                   {"SC system failure", ClassTemporary},
+	CMSInvalidSMEAddress:                 {"invalid SME address", ClassPermanent},
+	CMSDestinationSMEBarred:              {"destination SME barred", ClassPermanent},
+	CMSDuplicateRejected:                 {"SM rejected - duplicate SM", ClassPermanent},
+	CMSVPFNotSupported:                   {"TP-VPF not supported", ClassPermanent},
+	CMSVPNotSupported:                    {"TP-VP not supported", ClassPermanent},
+	CMSSIMStorageFull:                    {"SIM SMS storage full", ClassTemporary},
+	CMSNoSIMStorage:                      {"no SMS storage capability in SIM", ClassPermanent},
+	CMSErrorInMS:                         {"error in MS", ClassPermanent},
+	CMSMemoryCapacityExceededMS:          {"memory capacity exceeded", ClassTemporary},
+	CMSSATBusy:                           {"SIM application toolkit busy", ClassRetryable},
+	CMSSIMDataDownloadError:              {"SIM data download error", ClassPermanent},
+	CMSUnspecifiedFCS:                    {"unspecified error cause", ClassPermanent},
+
+	CMSMEFailure:             {"ME failure", ClassTemporary},
+	CMSServiceReserved:       {"SMS service of ME reserved", ClassTemporary},
+	CMSOperationNotAllowed:   {"operation not allowed", ClassPermanent},
+	CMSOperationNotSupported: {"operation not supported", ClassPermanent},
+	CMSInvalidPDUParameter:   {"invalid PDU mode parameter", ClassPermanent},
+	CMSInvalidTextParameter:  {"invalid text mode parameter", ClassPermanent},
+	CMSSIMNotInserted:        {"SIM not inserted", ClassPermanent},
+	CMSSIMPINRequired:        {"SIM PIN required", ClassPermanent},
+	CMSPHSIMPINRequired:      {"PH-SIM PIN required", ClassPermanent},
+	CMSSIMFailure:            {"SIM failure", ClassPermanent},
+	CMSSIMBusy:               {"SIM busy", ClassRetryable},
+	CMSSIMWrong:              {"SIM wrong", ClassPermanent},
+	CMSSIMPUKRequired:        {"SIM PUK required", ClassPermanent},
+	CMSSIMPIN2Required:       {"SIM PIN2 required", ClassPermanent},
+	CMSSIMPUK2Required:       {"SIM PUK2 required", ClassPermanent},
+	CMSMemoryFailure:         {"memory failure", ClassTemporary},
+	CMSInvalidMemoryIndex:    {"invalid memory index", ClassPermanent},
+	CMSMemoryFull:            {"memory full", ClassTemporary},
+	CMSSMSCAddressUnknown:    {"SMSC address unknown", ClassPermanent},
+	CMSNoNetworkService:      {"no network service", ClassTemporary},
+	CMSNetworkTimeout:        {"network timeout", ClassRetryable},
+	CMSNoCNMAExpected:        {"no +CNMA acknowledgement expected", ClassPermanent},
+	CMSUnknown:               {"unknown error", ClassTemporary},
+}
+
+func (e CMSError) Error() string {
+	if i, ok := cmsCodes[e]; ok {
+		return fmt.Sprintf("modem: +CMS ERROR: %d (%s)", int(e), i.desc)
+	}
+	return fmt.Sprintf("modem: +CMS ERROR: %d", int(e))
+}
+
+// Class returns the class of the error.
+//
+// Unlisted RP causes are temporary, as per 3GPP TS 24.011 Table 8.4,
+// while other unlisted codes are permanent.
+func (e CMSError) Class() ErrorClass {
+	if i, ok := cmsCodes[e]; ok {
+		return i.class
+	}
+	if _, ok := e.RPCause(); ok {
+		return ClassTemporary
+	}
+	return ClassPermanent
+}
+
+// FCS returns the TP-FCS corresponding to the error, if any.
+func (e CMSError) FCS() (byte, bool) {
+	if e >= 128 && e <= 255 {
+		return byte(e), true
+	}
+	return 0, false
+}
+
+// Is returns true if the target is the ErrorClass of the error.
+func (e CMSError) Is(target error) bool {
+	c, ok := target.(ErrorClass)
+	return ok && c == e.Class()
+}
+
+// RPCause returns the RP cause corresponding to the error, if any.
+func (e CMSError) RPCause() (byte, bool) {
+	if e >= 0 && e <= 127 {
+		return byte(e), true
+	}
+	return 0, false
+}
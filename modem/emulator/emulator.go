@@ -0,0 +1,333 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package emulator provides a GSM modem emulator for testing.
+//
+// The Emulator implements the SMS subset of the 3GPP TS 27.005 AT command
+// set in PDU mode, including message storage, and may be driven over any
+// io.ReadWriter, such as an io.Pipe or a pty.
+//
+// PDUs submitted to the Emulator are validated using the pdumode and tpdu
+// packages, and are rejected with +CMS ERROR: 304 if malformed, so errors in
+// the PDUs generated by the code under test fail loudly.
+package emulator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Message status values, as used by AT+CMGL, AT+CMGR and AT+CMGW.
+const (
+	statRecUnread = 0
+	statRecRead   = 1
+	statStoUnsent = 2
+	statStoSent   = 3
+	statAll       = 4
+)
+
+// +CMS ERROR codes generated by the Emulator, as defined in 3GPP TS 27.005
+// Section 3.2.5.
+const (
+	// CMSOperationNotAllowed indicates the command is not allowed in the
+	// current state, e.g. a PDU mode command in text mode.
+	CMSOperationNotAllowed = 302
+
+	// CMSOperationNotSupported indicates the parameters of the command are
+	// not supported.
+	CMSOperationNotSupported = 303
+
+	// CMSInvalidPDUParameter indicates the PDU provided to the command is
+	// malformed.
+	CMSInvalidPDUParameter = 304
+
+	// CMSInvalidMemoryIndex indicates there is no message at the index.
+	CMSInvalidMemoryIndex = 321
+
+	// CMSMemoryFull indicates the message storage is full.
+	CMSMemoryFull = 322
+
+	// CMSSMSCAddressUnknown indicates there is no SMSC address in the PDU
+	// and none has been set with AT+CSCA.
+	CMSSMSCAddressUnknown = 330
+
+	// CMSNoCNMAExpected indicates an AT+CNMA was received when no
+	// acknowledgement was expected.
+	CMSNoCNMAExpected = 340
+)
+
+// Ack is an acknowledgement of a received message provided with AT+CNMA.
+type Ack struct {
+	// N is the type of acknowledgement, 0 or 1 for an RP-ACK, 2 for an
+	// RP-ERROR.
+	N int
+
+	// TPDU is the SMS-DELIVER-REPORT provided with the acknowledgement, if
+	// any.
+	TPDU *tpdu.TPDU
+}
+
+// Emulator is an emulated GSM modem.
+//
+// The state of the modem, including the message storage, persists across
+// connections served by the Emulator.
+type Emulator struct {
+	sh func(*tpdu.TPDU)
+
+	mu        sync.Mutex // covers the fields below
+	conn      *conn
+	echo      bool
+	cmee      int
+	cmgf      int
+	csms      int
+	sca       pdumode.SMSCAddress
+	cnmi      [5]int
+	mems      map[string]*storage
+	memNames  []string
+	mem       [3]*storage
+	mr        byte
+	ackWait   bool
+	failures  map[string][]int
+	submitted []*tpdu.TPDU
+	acks      []Ack
+}
+
+// storage is a message storage area, such as the SIM.
+type storage struct {
+	name     string
+	capacity int
+	msgs     map[int]*stored
+}
+
+// stored is a message held in storage.
+type stored struct {
+	stat int
+
+	// pdu is the binary PDU, including the SMSC address.
+	pdu []byte
+}
+
+// Option alters the behaviour of an Emulator.
+type Option func(*Emulator)
+
+// WithStorage adds a message storage area to the Emulator.
+//
+// The first storage added is initially selected for all operations.
+// The default is a "SM" storage with a capacity of 10, and a "ME" storage
+// with a capacity of 20.
+func WithStorage(name string, capacity int) Option {
+	return func(e *Emulator) {
+		e.addStorage(name, capacity)
+	}
+}
+
+// WithSMSC sets the SMSC address initially reported by AT+CSCA.
+func WithSMSC(number string) Option {
+	return func(e *Emulator) {
+		e.sca.Address = tpdu.NewAddress(tpdu.FromNumber(number))
+	}
+}
+
+// WithSubmitHandler sets a handler that is passed the TPDUs successfully
+// submitted with AT+CMGS.
+//
+// The handler is called from the goroutine serving the connection, after the
+// TP-MR has been assigned, and before the +CMGS response is returned.
+func WithSubmitHandler(h func(*tpdu.TPDU)) Option {
+	return func(e *Emulator) {
+		e.sh = h
+	}
+}
+
+// New creates an Emulator.
+func New(options ...Option) *Emulator {
+	e := Emulator{
+		sh:       func(*tpdu.TPDU) {},
+		echo:     true,
+		mems:     make(map[string]*storage),
+		failures: make(map[string][]int),
+	}
+	for _, option := range options {
+		option(&e)
+	}
+	if len(e.mems) == 0 {
+		e.addStorage("SM", 10)
+		e.addStorage("ME", 20)
+	}
+	return &e
+}
+
+func (e *Emulator) addStorage(name string, capacity int) {
+	s := &storage{name: name, capacity: capacity, msgs: make(map[int]*stored)}
+	if _, ok := e.mems[name]; !ok {
+		e.memNames = append(e.memNames, name)
+	}
+	e.mems[name] = s
+	if e.mem[0] == nil {
+		e.mem = [3]*storage{s, s, s}
+	}
+}
+
+// Fail causes the next instance of the command to fail with the +CMS ERROR
+// code.
+//
+// The command is identified by its name, e.g. "+CMGS".  Commands that accept
+// a PDU fail after the PDU has been provided, as they would if rejected by
+// the network.
+//
+// Failures are queued, so calling Fail repeatedly fails successive instances
+// of the command.
+func (e *Emulator) Fail(cmd string, code int) {
+	cmd = strings.ToUpper(cmd)
+	e.mu.Lock()
+	e.failures[cmd] = append(e.failures[cmd], code)
+	e.mu.Unlock()
+}
+
+// Submitted returns the TPDUs submitted with AT+CMGS.
+func (e *Emulator) Submitted() []*tpdu.TPDU {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*tpdu.TPDU(nil), e.submitted...)
+}
+
+// Acks returns the acknowledgements provided with AT+CNMA.
+func (e *Emulator) Acks() []Ack {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Ack(nil), e.acks...)
+}
+
+// Inject passes a TPDU to the Emulator as if it had been received from the
+// network.
+//
+// The TPDU must be an SMS-DELIVER or SMS-STATUS-REPORT, and is sent with the
+// SMSC address set by AT+CSCA.
+func (e *Emulator) Inject(t *tpdu.TPDU) error {
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	p := pdumode.PDU{SMSC: e.sca, TPDU: b}
+	e.mu.Unlock()
+	return e.InjectPDU(&p)
+}
+
+// InjectPDU passes a PDU to the Emulator as if it had been received from the
+// network.
+//
+// The PDU is routed as per the AT+CNMI settings.  SMS-DELIVERs are either
+// stored in the receive storage, and indicated with +CMTI if enabled, or
+// passed to the connection as +CMT.  SMS-STATUS-REPORTs are either
+// discarded, passed to the connection as +CDS, or stored and indicated with
+// +CDSI.
+//
+// Returns ErrMemoryFull if the message must be stored and the storage is
+// full.
+func (e *Emulator) InjectPDU(p *pdumode.PDU) error {
+	t := tpdu.TPDU{Direction: tpdu.MT}
+	if err := t.UnmarshalBinary(p.TPDU); err != nil {
+		return err
+	}
+	b, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch st := t.SmsType(); st {
+	case tpdu.SmsDeliver:
+		switch e.cnmi[1] {
+		case 2:
+			if e.conn != nil {
+				e.urc(fmt.Sprintf("+CMT: ,%d\r\n%X", len(p.TPDU), b))
+				return nil
+			}
+		case 1, 3:
+			idx, err := e.store(e.mem[2], statRecUnread, b)
+			if err != nil {
+				return err
+			}
+			e.indicate(fmt.Sprintf("+CMTI: %q,%d", e.mem[2].name, idx))
+			return nil
+		}
+		_, err = e.store(e.mem[2], statRecUnread, b)
+		return err
+	case tpdu.SmsStatusReport:
+		switch e.cnmi[3] {
+		case 1:
+			if e.conn != nil {
+				e.urc(fmt.Sprintf("+CDS: %d\r\n%X", len(p.TPDU), b))
+			}
+		case 2:
+			idx, err := e.store(e.mem[2], statRecUnread, b)
+			if err != nil {
+				return err
+			}
+			e.indicate(fmt.Sprintf("+CDSI: %q,%d", e.mem[2].name, idx))
+		}
+		return nil
+	default:
+		return tpdu.ErrUnsupportedSmsType(st)
+	}
+}
+
+// InjectURC sends the unsolicited result to the connection as is, e.g. to
+// test the handling of malformed results.
+//
+// As for a +CMT, the result must be acknowledged with AT+CNMA if AT+CSMS=1.
+func (e *Emulator) InjectURC(urc string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.urc(urc)
+}
+
+// urc sends an unsolicited result that must be acknowledged with AT+CNMA if
+// AT+CSMS=1.
+func (e *Emulator) urc(s string) {
+	if e.csms == 1 {
+		e.ackWait = true
+	}
+	e.indicate(s)
+}
+
+// indicate sends an unsolicited result to the connection, if any.
+func (e *Emulator) indicate(s string) {
+	if e.conn != nil {
+		e.conn.write("\r\n" + s + "\r\n")
+	}
+}
+
+// store adds the PDU to the storage, returning its index.
+func (e *Emulator) store(s *storage, stat int, pdu []byte) (int, error) {
+	if len(s.msgs) >= s.capacity {
+		return 0, ErrMemoryFull
+	}
+	idx := 1
+	for ; s.msgs[idx] != nil; idx++ {
+	}
+	s.msgs[idx] = &stored{stat: stat, pdu: pdu}
+	return idx, nil
+}
+
+// indexes returns the indexes of the messages in the storage, in order.
+func (s *storage) indexes() []int {
+	idxs := make([]int, 0, len(s.msgs))
+	for idx := range s.msgs {
+		idxs = append(idxs, idx)
+	}
+	sort.Ints(idxs)
+	return idxs
+}
+
+// ErrMemoryFull indicates a received message could not be stored as the
+// storage is full.
+var ErrMemoryFull = errors.New("emulator: memory full")
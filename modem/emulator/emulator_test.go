@@ -0,0 +1,638 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package emulator_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/modem"
+	"github.com/warthog618/sms/modem/emulator"
+)
+
+// pipes is the TE side of a connection to the Emulator.
+type pipes struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (p pipes) Close() error {
+	p.PipeReader.Close()
+	return p.PipeWriter.Close()
+}
+
+// serve connects the Emulator to a pair of pipes, and returns the TE side.
+func serve(e *emulator.Emulator) pipes {
+	tr, ew := io.Pipe()
+	er, tw := io.Pipe()
+	go func() {
+		e.Serve(pipes{er, ew})
+		ew.Close()
+	}()
+	return pipes{tr, tw}
+}
+
+// client issues raw commands to the Emulator.
+type client struct {
+	p pipes
+	r *bufio.Reader
+}
+
+func newClient(e *emulator.Emulator) *client {
+	p := serve(e)
+	c := client{p: p, r: bufio.NewReader(p)}
+	return &c
+}
+
+// cmd sends the command and returns the response, up to and including the
+// final result.
+func (c *client) cmd(t *testing.T, cmd string) string {
+	t.Helper()
+	_, err := io.WriteString(c.p, cmd+"\r")
+	require.Nil(t, err)
+	return c.read(t)
+}
+
+// data sends the PDU following a prompt and returns the response.
+func (c *client) data(t *testing.T, pdu string) string {
+	t.Helper()
+	_, err := io.WriteString(c.p, pdu+"\x1a")
+	require.Nil(t, err)
+	return c.read(t)
+}
+
+func (c *client) read(t *testing.T) string {
+	t.Helper()
+	var b strings.Builder
+	for {
+		l, err := c.r.ReadString('\n')
+		require.Nil(t, err)
+		b.WriteString(l)
+		switch l = strings.TrimSpace(l); {
+		case l == "OK", l == "ERROR", strings.HasPrefix(l, "+CMS ERROR:"):
+			return b.String()
+		}
+	}
+}
+
+// readPrompt is used in place of read when the prompt is expected, as
+// ReadString would block waiting for the LF that never comes.
+func (c *client) readPrompt(t *testing.T) string {
+	t.Helper()
+	p := make([]byte, 4)
+	_, err := io.ReadFull(c.r, p)
+	require.Nil(t, err)
+	return string(p)
+}
+
+func (c *client) close() {
+	c.p.Close()
+}
+
+func TestCommands(t *testing.T) {
+	e := emulator.New()
+	c := newClient(e)
+	defer c.close()
+	assert.Equal(t, "ATE0\r\r\nOK\r\n", c.cmd(t, "ATE0"))
+	patterns := []struct {
+		cmd  string
+		resp string
+	}{
+		{"AT", "\r\nOK\r\n"},
+		{"at+cmgf?", "\r\n+CMGF: 0\r\n\r\nOK\r\n"},
+		{"AT+CMGF=?", "\r\n+CMGF: (0,1)\r\n\r\nOK\r\n"},
+		{"AT+CMGF=2", "\r\nERROR\r\n"},
+		{"AT+CMEE?", "\r\n+CMEE: 0\r\n\r\nOK\r\n"},
+		{"AT+CNMI=4", "\r\nERROR\r\n"},
+		{"AT+CMEE=1", "\r\nOK\r\n"},
+		{"AT+CNMI=4", "\r\n+CMS ERROR: 303\r\n"},
+		{"AT+CNMI=2,1,0,1,0", "\r\nOK\r\n"},
+		{"AT+CNMI?", "\r\n+CNMI: 2,1,0,1,0\r\n\r\nOK\r\n"},
+		{"AT+CNMI=1,2", "\r\nOK\r\n"},
+		{"AT+CNMI?", "\r\n+CNMI: 1,2,0,1,0\r\n\r\nOK\r\n"},
+		{"AT+CNMI=?", "\r\n+CNMI: (0-3),(0-3),(0-3),(0-2),(0,1)\r\n\r\nOK\r\n"},
+		{"AT+CSCA?", "\r\n+CSCA: \"\",0\r\n\r\nOK\r\n"},
+		{"AT+CSCA=\"+61412345678\"", "\r\nOK\r\n"},
+		{"AT+CSCA?", "\r\n+CSCA: \"+61412345678\",145\r\n\r\nOK\r\n"},
+		{"AT+CSCA=\"0412345678\",129", "\r\nOK\r\n"},
+		{"AT+CSCA?", "\r\n+CSCA: \"0412345678\",129\r\n\r\nOK\r\n"},
+		{"AT+CPMS=?", "\r\n+CPMS: (\"SM\",\"ME\"),(\"SM\",\"ME\"),(\"SM\",\"ME\")\r\n\r\nOK\r\n"},
+		{"AT+CPMS?", "\r\n+CPMS: \"SM\",0,10,\"SM\",0,10,\"SM\",0,10\r\n\r\nOK\r\n"},
+		{"AT+CPMS=\"ME\"", "\r\n+CPMS: 0,20,0,10,0,10\r\n\r\nOK\r\n"},
+		{"AT+CPMS=\"SM\",\"ME\",\"ME\"", "\r\n+CPMS: 0,10,0,20,0,20\r\n\r\nOK\r\n"},
+		{"AT+CPMS=\"XX\"", "\r\n+CMS ERROR: 303\r\n"},
+		{"AT+CSMS?", "\r\n+CSMS: 0,1,1,1\r\n\r\nOK\r\n"},
+		{"AT+CSMS=1", "\r\n+CSMS: 1,1,1\r\n\r\nOK\r\n"},
+		{"AT+CNMA", "\r\n+CMS ERROR: 340\r\n"},
+		{"AT+CMGR=1", "\r\n+CMS ERROR: 321\r\n"},
+		{"AT+CMGD=1", "\r\n+CMS ERROR: 321\r\n"},
+		{"AT+CMGL", "\r\nOK\r\n"},
+		{"AT+CMGS=0", "\r\n+CMS ERROR: 304\r\n"},
+		{"ATX", "\r\nERROR\r\n"},
+		{"AT+FOO", "\r\nERROR\r\n"},
+		{"AT+CMGF=1", "\r\nOK\r\n"},
+		{"AT+CMGL=4", "\r\n+CMS ERROR: 302\r\n"},
+		{"AT+CMGS=12", "\r\n+CMS ERROR: 302\r\n"},
+		{"ATZ", "\r\nOK\r\n"},
+		{"AT+CMGF?", "AT+CMGF?\r\r\n+CMGF: 0\r\n\r\nOK\r\n"},
+	}
+	for _, p := range patterns {
+		assert.Equal(t, p.resp, c.cmd(t, p.cmd), p.cmd)
+	}
+}
+
+func TestFail(t *testing.T) {
+	e := emulator.New()
+	c := newClient(e)
+	defer c.close()
+	c.cmd(t, "ATE0")
+	c.cmd(t, "AT+CMEE=1")
+	e.Fail("+cmgf", 500)
+	e.Fail("+CMGF", 302)
+	assert.Equal(t, "\r\n+CMS ERROR: 500\r\n", c.cmd(t, "AT+CMGF=0"))
+	assert.Equal(t, "\r\n+CMS ERROR: 302\r\n", c.cmd(t, "AT+CMGF?"))
+	assert.Equal(t, "\r\nOK\r\n", c.cmd(t, "AT+CMGF=0"))
+}
+
+// submitPDU returns a PDU containing an SMS-SUBMIT and the length of the
+// TPDU.
+func submitPDU(t *testing.T, sca string, msg string) (string, int) {
+	t.Helper()
+	tpdus, err := sms.Encode([]byte(msg), sms.To("+61421234567"))
+	require.Nil(t, err)
+	b, err := tpdus[0].MarshalBinary()
+	require.Nil(t, err)
+	return sca + strings.ToUpper(hex.EncodeToString(b)), len(b)
+}
+
+// deliverPDU returns a PDU containing an SMS-DELIVER and the length of the
+// TPDU.
+func deliverPDU(t *testing.T, msg string) (string, int) {
+	t.Helper()
+	tpdus, err := sms.Encode([]byte(msg), sms.AsDeliver, sms.From("+61421234567"))
+	require.Nil(t, err)
+	b, err := tpdus[0].MarshalBinary()
+	require.Nil(t, err)
+	return "00" + strings.ToUpper(hex.EncodeToString(b)), len(b)
+}
+
+func TestCMGS(t *testing.T) {
+	var handled []*tpdu.TPDU
+	e := emulator.New(
+		emulator.WithSMSC("+61412345678"),
+		emulator.WithSubmitHandler(func(t *tpdu.TPDU) { handled = append(handled, t) }))
+	c := newClient(e)
+	defer c.close()
+	c.cmd(t, "ATE0")
+	c.cmd(t, "AT+CMEE=1")
+
+	pdu, l := submitPDU(t, "00", "hello")
+	dpdu, dl := deliverPDU(t, "hello")
+	patterns := []struct {
+		name string
+		l    int
+		pdu  string
+		resp string
+	}{
+		{"ok", l, pdu, "\r\n+CMGS: 1\r\n\r\nOK\r\n"},
+		{"with sca", l, "07911614325476F8" + pdu[2:], "\r\n+CMGS: 2\r\n\r\nOK\r\n"},
+		{"length", l + 1, pdu, "\r\n+CMS ERROR: 304\r\n"},
+		{"hex", l, pdu[:4] + "XX" + pdu[6:], "\r\n+CMS ERROR: 304\r\n"},
+		{"truncated", l - 1, pdu[:len(pdu)-2], "\r\n+CMS ERROR: 304\r\n"},
+		{"sca", l, "09" + pdu[2:], "\r\n+CMS ERROR: 304\r\n"},
+		{"deliver", dl, dpdu, "\r\n+CMS ERROR: 304\r\n"},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			assert.Equal(t, "\r\n> ", c.readPromptAfter(t, fmt.Sprintf("AT+CMGS=%d", p.l)))
+			assert.Equal(t, p.resp, c.data(t, p.pdu))
+		}
+		t.Run(p.name, f)
+	}
+	sub := e.Submitted()
+	require.Equal(t, 2, len(sub))
+	assert.Equal(t, sub, handled)
+	assert.Equal(t, byte(1), sub[0].MR)
+	assert.Equal(t, byte(2), sub[1].MR)
+	assert.Equal(t, tpdu.SmsSubmit, sub[0].SmsType())
+	assert.Equal(t, "61421234567", sub[0].DA.Addr)
+	assert.Equal(t, tpdu.UserData("hello"), sub[0].UD)
+
+	// abort
+	assert.Equal(t, "\r\n> ", c.readPromptAfter(t, fmt.Sprintf("AT+CMGS=%d", l)))
+	_, err := io.WriteString(c.p, pdu+"\x1b")
+	require.Nil(t, err)
+	assert.Equal(t, "\r\nOK\r\n", c.cmd(t, "AT"))
+	assert.Equal(t, 2, len(e.Submitted()))
+
+	// injected failure follows the PDU
+	e.Fail("+CMGS", 500)
+	assert.Equal(t, "\r\n> ", c.readPromptAfter(t, fmt.Sprintf("AT+CMGS=%d", l)))
+	assert.Equal(t, "\r\n+CMS ERROR: 500\r\n", c.data(t, pdu))
+	assert.Equal(t, 2, len(e.Submitted()))
+
+	// no SMSC address
+	e = emulator.New()
+	c = newClient(e)
+	defer c.close()
+	c.cmd(t, "ATE0")
+	c.cmd(t, "AT+CMEE=1")
+	assert.Equal(t, "\r\n> ", c.readPromptAfter(t, fmt.Sprintf("AT+CMGS=%d", l)))
+	assert.Equal(t, "\r\n+CMS ERROR: 330\r\n", c.data(t, pdu))
+}
+
+// readPromptAfter sends the command and returns the data prompt.
+func (c *client) readPromptAfter(t *testing.T, cmd string) string {
+	t.Helper()
+	_, err := io.WriteString(c.p, cmd+"\r")
+	require.Nil(t, err)
+	return c.readPrompt(t)
+}
+
+func TestStorage(t *testing.T) {
+	e := emulator.New(emulator.WithStorage("SM", 3))
+	c := newClient(e)
+	defer c.close()
+	c.cmd(t, "ATE0")
+	c.cmd(t, "AT+CMEE=1")
+
+	spdu, sl := submitPDU(t, "00", "stored")
+	dpdu, dl := deliverPDU(t, "received")
+	c.readPromptAfter(t, fmt.Sprintf("AT+CMGW=%d", sl))
+	assert.Equal(t, "\r\n+CMGW: 1\r\n\r\nOK\r\n", c.data(t, spdu))
+	c.readPromptAfter(t, fmt.Sprintf("AT+CMGW=%d,1", dl))
+	assert.Equal(t, "\r\n+CMGW: 2\r\n\r\nOK\r\n", c.data(t, dpdu))
+
+	// direction must match stat
+	c.readPromptAfter(t, fmt.Sprintf("AT+CMGW=%d,3", dl))
+	assert.Equal(t, "\r\n+CMS ERROR: 304\r\n", c.data(t, dpdu))
+	c.readPromptAfter(t, fmt.Sprintf("AT+CMGW=%d,0", sl))
+	assert.Equal(t, "\r\n+CMS ERROR: 304\r\n", c.data(t, spdu))
+
+	// received message
+	tpdus, err := sms.Encode([]byte("unread"), sms.AsDeliver, sms.From("+61421234567"))
+	require.Nil(t, err)
+	require.Nil(t, e.Inject(&tpdus[0]))
+	updu := fmt.Sprintf("%X", mustMarshal(t, &tpdus[0]))
+	ul := len(updu) / 2
+
+	assert.Equal(t,
+		"\r\n+CMGL: 3,0,,"+itoa(ul)+"\r\n00"+updu+"\r\n\r\nOK\r\n",
+		c.cmd(t, "AT+CMGL=0"))
+	assert.Equal(t, "\r\nOK\r\n", c.cmd(t, "AT+CMGL"))
+	assert.Equal(t,
+		"\r\n+CMGL: 1,2,,"+itoa(sl)+"\r\n"+spdu+
+			"\r\n+CMGL: 2,1,,"+itoa(dl)+"\r\n"+dpdu+
+			"\r\n+CMGL: 3,1,,"+itoa(ul)+"\r\n00"+updu+
+			"\r\n\r\nOK\r\n",
+		c.cmd(t, "AT+CMGL=4"))
+	assert.Equal(t, "\r\n+CMGR: 2,,"+itoa(sl)+"\r\n"+spdu+"\r\n\r\nOK\r\n", c.cmd(t, "AT+CMGR=1"))
+	assert.Equal(t, "\r\n+CPMS: \"SM\",3,3,\"SM\",3,3,\"SM\",3,3\r\n\r\nOK\r\n", c.cmd(t, "AT+CPMS?"))
+
+	// full
+	c.readPromptAfter(t, fmt.Sprintf("AT+CMGW=%d", sl))
+	assert.Equal(t, "\r\n+CMS ERROR: 322\r\n", c.data(t, spdu))
+	assert.Equal(t, emulator.ErrMemoryFull, e.Inject(&tpdus[0]))
+
+	// delete
+	assert.Equal(t, "\r\n+CMGD: (1,2,3),(0-4)\r\n\r\nOK\r\n", c.cmd(t, "AT+CMGD=?"))
+	assert.Equal(t, "\r\nOK\r\n", c.cmd(t, "AT+CMGD=2"))
+	assert.Equal(t, "\r\n+CMS ERROR: 321\r\n", c.cmd(t, "AT+CMGD=2"))
+	assert.Equal(t, "\r\nOK\r\n", c.cmd(t, "AT+CMGD=0,1"))
+	assert.Equal(t, "\r\n+CMGD: (1),(0-4)\r\n\r\nOK\r\n", c.cmd(t, "AT+CMGD=?"))
+	assert.Equal(t, "\r\nOK\r\n", c.cmd(t, "AT+CMGD=0,2"))
+	assert.Equal(t, "\r\n+CMGD: (1),(0-4)\r\n\r\nOK\r\n", c.cmd(t, "AT+CMGD=?"))
+	assert.Equal(t, "\r\nOK\r\n", c.cmd(t, "AT+CMGD=0,3"))
+	assert.Equal(t, "\r\n+CMGD: (),(0-4)\r\n\r\nOK\r\n", c.cmd(t, "AT+CMGD=?"))
+}
+
+func TestInject(t *testing.T) {
+	e := emulator.New(emulator.WithSMSC("+61412345678"))
+	c := newClient(e)
+	defer c.close()
+	c.cmd(t, "ATE0")
+	c.cmd(t, "AT+CMEE=1")
+	tpdus, err := sms.Encode([]byte("hello"), sms.AsDeliver, sms.From("+61421234567"))
+	require.Nil(t, err)
+	d := &tpdus[0]
+	dpdu := "07911614325476F8" + fmt.Sprintf("%X", mustMarshal(t, d))
+	dl := itoa(len(mustMarshal(t, d)))
+
+	sr, err := tpdu.New(tpdu.SmsStatusReport)
+	require.Nil(t, err)
+	sr.MR = 42
+	sr.RA = tpdu.NewAddress(tpdu.FromNumber("+61421234567"))
+	srpdu := "07911614325476F8" + fmt.Sprintf("%X", mustMarshal(t, sr))
+	srl := itoa(len(mustMarshal(t, sr)))
+
+	// routed directly
+	c.cmd(t, "AT+CNMI=2,2,0,1,0")
+	require.Nil(t, e.Inject(d))
+	assert.Equal(t, "\r\n+CMT: ,"+dl+"\r\n", c.readLine(t))
+	assert.Equal(t, dpdu+"\r\n", c.readLine(t))
+	require.Nil(t, e.Inject(sr))
+	assert.Equal(t, "\r\n+CDS: "+srl+"\r\n", c.readLine(t))
+	assert.Equal(t, srpdu+"\r\n", c.readLine(t))
+
+	// stored and indicated
+	c.cmd(t, "AT+CNMI=2,1,0,2,0")
+	require.Nil(t, e.Inject(d))
+	assert.Equal(t, "\r\n+CMTI: \"SM\",1\r\n", c.readLine(t))
+	require.Nil(t, e.Inject(sr))
+	assert.Equal(t, "\r\n+CDSI: \"SM\",2\r\n", c.readLine(t))
+	assert.Equal(t, "\r\n+CMGR: 0,,"+dl+"\r\n"+dpdu+"\r\n\r\nOK\r\n", c.cmd(t, "AT+CMGR=1"))
+	assert.Equal(t, "\r\n+CMGR: 0,,"+srl+"\r\n"+srpdu+"\r\n\r\nOK\r\n", c.cmd(t, "AT+CMGR=2"))
+
+	// stored silently
+	c.cmd(t, "AT+CNMI=2,0,0,0,0")
+	require.Nil(t, e.Inject(d))
+	require.Nil(t, e.Inject(sr))
+	assert.Equal(t, "\r\n+CMGR: 0,,"+dl+"\r\n"+dpdu+"\r\n\r\nOK\r\n", c.cmd(t, "AT+CMGR=3"))
+	assert.Equal(t, "\r\n+CMS ERROR: 321\r\n", c.cmd(t, "AT+CMGR=4"))
+
+	// only MT TPDUs may be injected
+	s, err := tpdu.New(tpdu.SmsSubmit)
+	require.Nil(t, err)
+	err = e.InjectPDU(&pdumode.PDU{TPDU: mustMarshal(t, s)})
+	assert.NotNil(t, err)
+}
+
+func TestCNMA(t *testing.T) {
+	e := emulator.New(emulator.WithSMSC("+61412345678"))
+	c := newClient(e)
+	defer c.close()
+	c.cmd(t, "ATE0")
+	c.cmd(t, "AT+CMEE=1")
+	c.cmd(t, "AT+CSMS=1")
+	c.cmd(t, "AT+CNMI=2,2,0,1,0")
+	tpdus, err := sms.Encode([]byte("hello"), sms.AsDeliver, sms.From("+61421234567"))
+	require.Nil(t, err)
+
+	require.Nil(t, e.Inject(&tpdus[0]))
+	c.readLine(t)
+	c.readLine(t)
+	assert.Equal(t, "\r\nOK\r\n", c.cmd(t, "AT+CNMA"))
+	assert.Equal(t, "\r\n+CMS ERROR: 340\r\n", c.cmd(t, "AT+CNMA"))
+
+	require.Nil(t, e.Inject(&tpdus[0]))
+	c.readLine(t)
+	c.readLine(t)
+	// SMS-SUBMIT is not a DELIVER-REPORT
+	spdu, sl := submitPDU(t, "", "hello")
+	c.readPromptAfter(t, fmt.Sprintf("AT+CNMA=2,%d", sl))
+	assert.Equal(t, "\r\n+CMS ERROR: 304\r\n", c.data(t, spdu))
+	c.readPromptAfter(t, "AT+CNMA=2,3")
+	assert.Equal(t, "\r\nOK\r\n", c.data(t, "00D300"))
+
+	// RP-ACK with a report
+	require.Nil(t, e.Inject(&tpdus[0]))
+	c.readLine(t)
+	c.readLine(t)
+	c.readPromptAfter(t, "AT+CNMA=1,2")
+	assert.Equal(t, "\r\nOK\r\n", c.data(t, "0000"))
+
+	acks := e.Acks()
+	require.Equal(t, 3, len(acks))
+	assert.Equal(t, 0, acks[0].N)
+	assert.Nil(t, acks[0].TPDU)
+	assert.Equal(t, 2, acks[1].N)
+	require.NotNil(t, acks[1].TPDU)
+	assert.Equal(t, tpdu.SmsDeliverReport, acks[1].TPDU.SmsType())
+	assert.Equal(t, byte(0xd3), acks[1].TPDU.FCS)
+	assert.Equal(t, 1, acks[2].N)
+	require.NotNil(t, acks[2].TPDU)
+	assert.Equal(t, tpdu.SmsDeliverReport, acks[2].TPDU.SmsType())
+	assert.Equal(t, byte(0), acks[2].TPDU.FCS)
+}
+
+func TestModem(t *testing.T) {
+	e := emulator.New(emulator.WithSMSC("+61412345678"))
+	testModem(t, e, serve(e))
+}
+
+func testModem(t *testing.T, e *emulator.Emulator, rw io.ReadWriter) {
+	ch := make(chan *tpdu.TPDU, 4)
+	m := modem.New(rw, modem.WithHandler(func(t *tpdu.TPDU) { ch <- t }))
+	defer m.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Nil(t, m.Init(ctx))
+
+	msg := "this is a very long message that does not fit in a single SMS message, at least it will if I keep adding more to it as 160 characters is more than you might think"
+	tpdus, err := sms.Encode([]byte(msg), sms.To("+61421234567"))
+	require.Nil(t, err)
+	mrs, err := m.Send(ctx, tpdus...)
+	require.Nil(t, err)
+	assert.Equal(t, []int{1, 2}, mrs)
+	sub := e.Submitted()
+	require.Equal(t, 2, len(sub))
+	dmsg, err := sms.Decode(sub)
+	require.Nil(t, err)
+	assert.Equal(t, msg, string(dmsg))
+
+	e.Fail("+CMGS", 500)
+	mrs, err = m.Send(ctx, tpdus...)
+	assert.Equal(t, modem.CMSError(500), err)
+	assert.Equal(t, []int{}, mrs)
+
+	// +CMT
+	tpdus, err = sms.Encode([]byte("hello"), sms.AsDeliver, sms.From("+61421234567"))
+	require.Nil(t, err)
+	require.Nil(t, e.Inject(&tpdus[0]))
+	r := receive(t, ch)
+	assert.Equal(t, tpdu.UserData("hello"), r.UD)
+
+	// +CMTI
+	_, err = m.Command(ctx, "+CNMI=2,1,0,1,0")
+	require.Nil(t, err)
+	require.Nil(t, e.Inject(&tpdus[0]))
+	r = receive(t, ch)
+	assert.Equal(t, tpdu.UserData("hello"), r.UD)
+	assert.Equal(t, "61421234567", r.OA.Addr)
+
+	// storage
+	tpdus, err = sms.Encode([]byte(msg), sms.To("+61421234567"))
+	require.Nil(t, err)
+	for i := range tpdus {
+		_, err = m.Write(ctx, &tpdus[i], pdumode.StatStoUnsent)
+		require.Nil(t, err)
+	}
+	msgs, err := m.List(ctx, pdumode.StatStoUnsent)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(msgs))
+	dmsg, err = sms.Decode(msgs[0].TPDUs)
+	require.Nil(t, err)
+	assert.Equal(t, msg, string(dmsg))
+	require.Nil(t, m.Delete(ctx, 0, modem.DeleteReadSentUnsent))
+	ss, err := m.Storage(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, modem.Storage{Name: "SM", Used: 0, Total: 10}, ss[0])
+}
+
+func TestPTY(t *testing.T) {
+	master, slave, err := emulator.OpenPTY()
+	if err != nil {
+		t.Skip("pty unavailable:", err)
+	}
+	defer master.Close()
+	e := emulator.New(emulator.WithSMSC("+61412345678"))
+	done := make(chan error)
+	go func() {
+		done <- e.Serve(master)
+	}()
+	testModem(t, e, slave)
+	select {
+	case err = <-done:
+		// Linux returns EIO once the slave is closed
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Serve didn't return")
+	}
+}
+
+func TestModemAck(t *testing.T) {
+	e := emulator.New()
+	rw := serve(e)
+	defer rw.Close()
+	ch := make(chan *tpdu.TPDU, 1)
+	report := func(t *tpdu.TPDU) *tpdu.TPDU {
+		r, _ := tpdu.NewDeliverReport(tpdu.WithFCS(0xd3))
+		return r
+	}
+	m := modem.New(rw,
+		modem.WithHandler(func(t *tpdu.TPDU) { ch <- t }),
+		modem.WithAck(report))
+	defer m.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Nil(t, m.Init(ctx))
+
+	tpdus, err := sms.Encode([]byte("hello"), sms.AsDeliver, sms.From("+61421234567"))
+	require.Nil(t, err)
+	require.Nil(t, e.Inject(&tpdus[0]))
+	r := receive(t, ch)
+	assert.Equal(t, tpdu.UserData("hello"), r.UD)
+	var acks []emulator.Ack
+	for i := 0; i < 100 && len(acks) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		acks = e.Acks()
+	}
+	require.Equal(t, 1, len(acks))
+	assert.Equal(t, 2, acks[0].N)
+	require.NotNil(t, acks[0].TPDU)
+	assert.Equal(t, byte(0xd3), acks[0].TPDU.FCS)
+}
+
+func TestModemAckMalformed(t *testing.T) {
+	e := emulator.New()
+	rw := serve(e)
+	defer rw.Close()
+	ch := make(chan *tpdu.TPDU, 1)
+	m := modem.New(rw,
+		modem.WithHandler(func(t *tpdu.TPDU) { ch <- t }),
+		modem.WithAck(nil))
+	defer m.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Nil(t, m.Init(ctx))
+
+	// length does not match the PDU
+	e.InjectURC("+CMT: ,99\r\n00040B911624211365F700000250104154650005E8329BFD06")
+	acks := waitAcks(t, e, 1)
+	assert.Equal(t, 2, acks[0].N)
+	require.NotNil(t, acks[0].TPDU)
+	assert.Equal(t, byte(0xff), acks[0].TPDU.FCS)
+	assert.Equal(t, 0, len(ch))
+}
+
+func TestModemAckFail(t *testing.T) {
+	e := emulator.New()
+	rw := serve(e)
+	defer rw.Close()
+	ch := make(chan *tpdu.TPDU, 1)
+	errs := make(chan error, 1)
+	m := modem.New(rw,
+		modem.WithHandler(func(t *tpdu.TPDU) { ch <- t }),
+		modem.WithErrorHandler(func(err error) { errs <- err }),
+		modem.WithAck(nil))
+	defer m.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Nil(t, m.Init(ctx))
+
+	e.Fail("+CNMA", 500)
+	tpdus, err := sms.Encode([]byte("hello"), sms.AsDeliver, sms.From("+61421234567"))
+	require.Nil(t, err)
+	require.Nil(t, e.Inject(&tpdus[0]))
+	r := receive(t, ch)
+	assert.Equal(t, tpdu.UserData("hello"), r.UD)
+	select {
+	case err = <-errs:
+		assert.Equal(t, modem.CMSError(500), err)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for error")
+	}
+	assert.Equal(t, 0, len(e.Acks()))
+}
+
+// waitAcks waits for the Emulator to receive n acknowledgements.
+func waitAcks(t *testing.T, e *emulator.Emulator, n int) []emulator.Ack {
+	t.Helper()
+	var acks []emulator.Ack
+	for i := 0; i < 100 && len(acks) < n; i++ {
+		time.Sleep(10 * time.Millisecond)
+		acks = e.Acks()
+	}
+	require.Equal(t, n, len(acks))
+	return acks
+}
+
+func receive(t *testing.T, ch <-chan *tpdu.TPDU) *tpdu.TPDU {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for TPDU")
+	}
+	return nil
+}
+
+// readLine reads an unsolicited result line, including any leading blank
+// line.
+func (c *client) readLine(t *testing.T) string {
+	t.Helper()
+	l, err := c.r.ReadString('\n')
+	require.Nil(t, err)
+	if l == "\r\n" {
+		var l2 string
+		l2, err = c.r.ReadString('\n')
+		require.Nil(t, err)
+		l += l2
+	}
+	return l
+}
+
+func mustMarshal(t *testing.T, p *tpdu.TPDU) []byte {
+	t.Helper()
+	b, err := p.MarshalBinary()
+	require.Nil(t, err)
+	return b
+}
+
+func itoa(i int) string {
+	return fmt.Sprintf("%d", i)
+}
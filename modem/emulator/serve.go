@@ -0,0 +1,813 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package emulator
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Serve emulates the modem on the ReadWriter until reading from it fails.
+//
+// Unsolicited results, such as +CMT, are sent to the ReadWriter most recently
+// passed to Serve.
+//
+// Returns nil if the ReadWriter reaches EOF, else the error that terminated
+// the Serve.
+func (e *Emulator) Serve(rw io.ReadWriter) error {
+	c := conn{w: rw}
+	c.cond = sync.NewCond(&c.mu)
+	go c.send()
+	defer c.close()
+	e.mu.Lock()
+	e.conn = &c
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		if e.conn == &c {
+			e.conn = nil
+		}
+		e.mu.Unlock()
+	}()
+	r := bufio.NewReader(rw)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		e.mu.Lock()
+		echo := e.echo
+		e.mu.Unlock()
+		if echo {
+			c.write(line + "\r")
+		}
+		if len(line) < 2 || !strings.EqualFold(line[:2], "AT") {
+			continue
+		}
+		info, err := e.execute(&c, r, line[2:])
+		if err == errAborted {
+			continue
+		}
+		c.write(e.response(info, err))
+	}
+}
+
+// request is a parsed extended command.
+type request struct {
+	name string
+	op   int
+	args []string
+}
+
+// Forms of extended command.
+const (
+	opAction = iota
+	opRead
+	opTest
+	opSet
+)
+
+type handler func(e *Emulator, c *conn, r *bufio.Reader, req request) ([]string, error)
+
+// handlers maps extended command names to their handlers.
+var handlers = map[string]handler{
+	"+CMEE": (*Emulator).cmdCMEE,
+	"+CMGD": (*Emulator).cmdCMGD,
+	"+CMGF": (*Emulator).cmdCMGF,
+	"+CMGL": (*Emulator).cmdCMGL,
+	"+CMGR": (*Emulator).cmdCMGR,
+	"+CMGS": (*Emulator).cmdCMGS,
+	"+CMGW": (*Emulator).cmdCMGW,
+	"+CNMA": (*Emulator).cmdCNMA,
+	"+CNMI": (*Emulator).cmdCNMI,
+	"+CPMS": (*Emulator).cmdCPMS,
+	"+CSCA": (*Emulator).cmdCSCA,
+	"+CSMS": (*Emulator).cmdCSMS,
+}
+
+// dataCommands are the commands that prompt for a PDU, and so check for
+// injected failures after the PDU is provided.
+var dataCommands = map[string]bool{
+	"+CMGS": true,
+	"+CMGW": true,
+	"+CNMA": true,
+}
+
+// execute performs the command, returning the information text and final
+// result.
+func (e *Emulator) execute(c *conn, r *bufio.Reader, cmd string) ([]string, error) {
+	if len(cmd) == 0 {
+		return nil, nil
+	}
+	if cmd[0] != '+' {
+		return e.basic(cmd)
+	}
+	req := parseRequest(cmd)
+	h, ok := handlers[req.name]
+	if !ok {
+		return nil, errError
+	}
+	if !dataCommands[req.name] && req.op != opTest {
+		e.mu.Lock()
+		err := e.failure(req.name)
+		e.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h(e, c, r, req)
+}
+
+// basic performs the basic commands supported by the Emulator.
+func (e *Emulator) basic(cmd string) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch strings.ToUpper(cmd) {
+	case "E", "E0":
+		e.echo = false
+	case "E1":
+		e.echo = true
+	case "Z":
+		e.echo = true
+		e.cmee = 0
+		e.cmgf = 0
+		e.csms = 0
+		e.cnmi = [5]int{}
+		e.ackWait = false
+	default:
+		return nil, errError
+	}
+	return nil, nil
+}
+
+// failure returns the next injected failure for the command, if any.
+func (e *Emulator) failure(name string) error {
+	ff := e.failures[name]
+	if len(ff) == 0 {
+		return nil
+	}
+	e.failures[name] = ff[1:]
+	return cmsError(ff[0])
+}
+
+// response formats the information text and final result returned to the
+// TE.
+func (e *Emulator) response(info []string, err error) string {
+	var b strings.Builder
+	if len(info) > 0 {
+		b.WriteString("\r\n")
+		b.WriteString(strings.Join(info, "\r\n"))
+		b.WriteString("\r\n")
+	}
+	switch v := err.(type) {
+	case nil:
+		b.WriteString("\r\nOK\r\n")
+	case cmsError:
+		e.mu.Lock()
+		cmee := e.cmee
+		e.mu.Unlock()
+		if cmee == 0 {
+			b.WriteString("\r\nERROR\r\n")
+		} else {
+			fmt.Fprintf(&b, "\r\n+CMS ERROR: %d\r\n", int(v))
+		}
+	default:
+		b.WriteString("\r\nERROR\r\n")
+	}
+	return b.String()
+}
+
+func (e *Emulator) cmdCMEE(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch req.op {
+	case opRead:
+		return []string{fmt.Sprintf("+CMEE: %d", e.cmee)}, nil
+	case opTest:
+		return []string{"+CMEE: (0-2)"}, nil
+	case opSet:
+		n, err := intArg(req.args, 0, 0, 0, 2)
+		if err != nil {
+			return nil, err
+		}
+		e.cmee = n
+		return nil, nil
+	}
+	return nil, errError
+}
+
+func (e *Emulator) cmdCMGF(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch req.op {
+	case opRead:
+		return []string{fmt.Sprintf("+CMGF: %d", e.cmgf)}, nil
+	case opTest:
+		return []string{"+CMGF: (0,1)"}, nil
+	case opSet:
+		n, err := intArg(req.args, 0, 0, 0, 1)
+		if err != nil {
+			return nil, err
+		}
+		e.cmgf = n
+		return nil, nil
+	}
+	return nil, errError
+}
+
+func (e *Emulator) cmdCSMS(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch req.op {
+	case opRead:
+		return []string{fmt.Sprintf("+CSMS: %d,1,1,1", e.csms)}, nil
+	case opTest:
+		return []string{"+CSMS: (0,1)"}, nil
+	case opSet:
+		n, err := intArg(req.args, 0, -1, 0, 1)
+		if err != nil {
+			return nil, cmsError(CMSOperationNotSupported)
+		}
+		e.csms = n
+		return []string{"+CSMS: 1,1,1"}, nil
+	}
+	return nil, errError
+}
+
+func (e *Emulator) cmdCSCA(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch req.op {
+	case opRead:
+		return []string{fmt.Sprintf("+CSCA: %q,%d", e.sca.Number(), e.sca.TOA)}, nil
+	case opTest:
+		return nil, nil
+	case opSet:
+		if len(req.args) < 1 || len(req.args) > 2 {
+			return nil, errError
+		}
+		number := req.args[0]
+		toa := 129
+		if strings.HasPrefix(number, "+") {
+			toa = 145
+		}
+		toa, err := intArg(req.args, 1, toa, 128, 255)
+		if err != nil {
+			return nil, err
+		}
+		e.sca.TOA = byte(toa)
+		e.sca.Addr = strings.TrimPrefix(number, "+")
+		return nil, nil
+	}
+	return nil, errError
+}
+
+func (e *Emulator) cmdCNMI(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	max := [5]int{3, 3, 3, 2, 1}
+	switch req.op {
+	case opRead:
+		n := e.cnmi
+		return []string{fmt.Sprintf("+CNMI: %d,%d,%d,%d,%d", n[0], n[1], n[2], n[3], n[4])}, nil
+	case opTest:
+		return []string{"+CNMI: (0-3),(0-3),(0-3),(0-2),(0,1)"}, nil
+	case opSet:
+		if len(req.args) > len(max) {
+			return nil, errError
+		}
+		n := e.cnmi
+		for i := range req.args {
+			v, err := intArg(req.args, i, n[i], 0, max[i])
+			if err != nil {
+				return nil, cmsError(CMSOperationNotSupported)
+			}
+			n[i] = v
+		}
+		e.cnmi = n
+		return nil, nil
+	}
+	return nil, errError
+}
+
+func (e *Emulator) cmdCPMS(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch req.op {
+	case opRead:
+		var f []string
+		for _, s := range e.mem {
+			f = append(f, fmt.Sprintf("%q,%d,%d", s.name, len(s.msgs), s.capacity))
+		}
+		return []string{"+CPMS: " + strings.Join(f, ",")}, nil
+	case opTest:
+		names := make([]string, len(e.memNames))
+		for i, n := range e.memNames {
+			names[i] = strconv.Quote(n)
+		}
+		l := "(" + strings.Join(names, ",") + ")"
+		return []string{"+CPMS: " + l + "," + l + "," + l}, nil
+	case opSet:
+		if len(req.args) < 1 || len(req.args) > 3 {
+			return nil, errError
+		}
+		mem := e.mem
+		for i, n := range req.args {
+			s, ok := e.mems[n]
+			if !ok {
+				return nil, cmsError(CMSOperationNotSupported)
+			}
+			mem[i] = s
+		}
+		e.mem = mem
+		var f []string
+		for _, s := range e.mem {
+			f = append(f, fmt.Sprintf("%d,%d", len(s.msgs), s.capacity))
+		}
+		return []string{"+CPMS: " + strings.Join(f, ",")}, nil
+	}
+	return nil, errError
+}
+
+func (e *Emulator) cmdCMGS(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	l, err := e.dataRequest(req, 1)
+	if l == 0 {
+		return nil, err
+	}
+	pdu, err := readData(c, r)
+	if err != nil {
+		return nil, err
+	}
+	p, t, err := decodePDU(pdu, l, true, tpdu.MO)
+	if err != nil {
+		return nil, err
+	}
+	if st := t.SmsType(); st != tpdu.SmsSubmit && st != tpdu.SmsCommand {
+		return nil, cmsError(CMSInvalidPDUParameter)
+	}
+	e.mu.Lock()
+	if p.SMSC.Addr == "" && e.sca.Addr == "" {
+		e.mu.Unlock()
+		return nil, cmsError(CMSSMSCAddressUnknown)
+	}
+	if err = e.failure(req.name); err != nil {
+		e.mu.Unlock()
+		return nil, err
+	}
+	e.mr++
+	t.MR = e.mr
+	e.submitted = append(e.submitted, t)
+	e.mu.Unlock()
+	e.sh(t)
+	return []string{fmt.Sprintf("+CMGS: %d", t.MR)}, nil
+}
+
+func (e *Emulator) cmdCMGW(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	l, err := e.dataRequest(req, 2)
+	if l == 0 {
+		return nil, err
+	}
+	stat, err := intArg(req.args, 1, statStoUnsent, statRecUnread, statStoSent)
+	if err != nil {
+		return nil, err
+	}
+	pdu, err := readData(c, r)
+	if err != nil {
+		return nil, err
+	}
+	dir := tpdu.MO
+	if stat < statStoUnsent {
+		dir = tpdu.MT
+	}
+	p, t, err := decodePDU(pdu, l, true, dir)
+	if err != nil {
+		return nil, err
+	}
+	st := t.SmsType()
+	if dir == tpdu.MO && st != tpdu.SmsSubmit && st != tpdu.SmsCommand ||
+		dir == tpdu.MT && st != tpdu.SmsDeliver && st != tpdu.SmsStatusReport {
+		return nil, cmsError(CMSInvalidPDUParameter)
+	}
+	b, _ := p.MarshalBinary()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err = e.failure(req.name); err != nil {
+		return nil, err
+	}
+	idx, err := e.store(e.mem[1], stat, b)
+	if err != nil {
+		return nil, cmsError(CMSMemoryFull)
+	}
+	return []string{fmt.Sprintf("+CMGW: %d", idx)}, nil
+}
+
+func (e *Emulator) cmdCMGL(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cmgf != 0 {
+		return nil, cmsError(CMSOperationNotAllowed)
+	}
+	switch req.op {
+	case opTest:
+		return []string{"+CMGL: (0-4)"}, nil
+	case opAction, opSet:
+		stat, err := intArg(req.args, 0, statRecUnread, statRecUnread, statAll)
+		if err != nil {
+			return nil, cmsError(CMSOperationNotSupported)
+		}
+		var info []string
+		s := e.mem[0]
+		for _, idx := range s.indexes() {
+			m := s.msgs[idx]
+			if stat != statAll && m.stat != stat {
+				continue
+			}
+			info = append(info,
+				fmt.Sprintf("+CMGL: %d,%d,,%d", idx, m.stat, tpduLen(m.pdu)),
+				fmt.Sprintf("%X", m.pdu))
+			if m.stat == statRecUnread {
+				m.stat = statRecRead
+			}
+		}
+		return info, nil
+	}
+	return nil, errError
+}
+
+func (e *Emulator) cmdCMGR(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cmgf != 0 {
+		return nil, cmsError(CMSOperationNotAllowed)
+	}
+	switch req.op {
+	case opTest:
+		return nil, nil
+	case opSet:
+		idx, err := intArg(req.args, 0, -1, 0, 0xffff)
+		if err != nil {
+			return nil, err
+		}
+		m := e.mem[0].msgs[idx]
+		if m == nil {
+			return nil, cmsError(CMSInvalidMemoryIndex)
+		}
+		info := []string{
+			fmt.Sprintf("+CMGR: %d,,%d", m.stat, tpduLen(m.pdu)),
+			fmt.Sprintf("%X", m.pdu),
+		}
+		if m.stat == statRecUnread {
+			m.stat = statRecRead
+		}
+		return info, nil
+	}
+	return nil, errError
+}
+
+func (e *Emulator) cmdCMGD(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.mem[0]
+	switch req.op {
+	case opTest:
+		idxs := s.indexes()
+		f := make([]string, len(idxs))
+		for i, idx := range idxs {
+			f[i] = strconv.Itoa(idx)
+		}
+		return []string{"+CMGD: (" + strings.Join(f, ",") + "),(0-4)"}, nil
+	case opSet:
+		idx, err := intArg(req.args, 0, -1, 0, 0xffff)
+		if err != nil {
+			return nil, err
+		}
+		flag, err := intArg(req.args, 1, 0, 0, 4)
+		if err != nil {
+			return nil, cmsError(CMSOperationNotSupported)
+		}
+		if flag == 0 {
+			if s.msgs[idx] == nil {
+				return nil, cmsError(CMSInvalidMemoryIndex)
+			}
+			delete(s.msgs, idx)
+			return nil, nil
+		}
+		// the stats deleted by each delflag, with 4 deleting all
+		stats := [][]int{
+			1: {statRecRead},
+			2: {statRecRead, statStoSent},
+			3: {statRecRead, statStoSent, statStoUnsent},
+			4: {statRecUnread, statRecRead, statStoUnsent, statStoSent},
+		}[flag]
+		for idx, m := range s.msgs {
+			for _, stat := range stats {
+				if m.stat == stat {
+					delete(s.msgs, idx)
+				}
+			}
+		}
+		return nil, nil
+	}
+	return nil, errError
+}
+
+func (e *Emulator) cmdCNMA(c *conn, r *bufio.Reader, req request) ([]string, error) {
+	if req.op == opTest {
+		return []string{"+CNMA: (0-2)"}, nil
+	}
+	if req.op != opAction && req.op != opSet {
+		return nil, errError
+	}
+	e.mu.Lock()
+	wait := e.csms == 1 && e.ackWait
+	e.mu.Unlock()
+	if !wait {
+		return nil, cmsError(CMSNoCNMAExpected)
+	}
+	n, err := intArg(req.args, 0, 0, 0, 2)
+	if err != nil {
+		return nil, err
+	}
+	l, err := intArg(req.args, 1, 0, 0, 255)
+	if err != nil {
+		return nil, err
+	}
+	ack := Ack{N: n}
+	if n != 0 && l != 0 {
+		pdu, err := readData(c, r)
+		if err != nil {
+			return nil, err
+		}
+		if n == 1 && len(pdu) >= 2 {
+			// the report in an RP-ACK has no TP-FCS, but the decoder
+			// expects one, so insert a zero FCS.
+			pdu = pdu[:2] + "00" + pdu[2:]
+			l++
+		}
+		_, t, err := decodePDU(pdu, l, false, tpdu.MO)
+		if err != nil {
+			return nil, err
+		}
+		if t.SmsType() != tpdu.SmsDeliverReport {
+			return nil, cmsError(CMSInvalidPDUParameter)
+		}
+		ack.TPDU = t
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err = e.failure(req.name); err != nil {
+		return nil, err
+	}
+	e.ackWait = false
+	e.acks = append(e.acks, ack)
+	return nil, nil
+}
+
+// dataRequest checks the request for a command that prompts for a PDU and
+// returns the length of the TPDU to be provided.
+//
+// A zero length indicates the command has completed, with the returned
+// error as the final result.
+func (e *Emulator) dataRequest(req request, maxArgs int) (int, error) {
+	if req.op == opTest {
+		return 0, nil
+	}
+	if req.op != opSet || len(req.args) > maxArgs {
+		return 0, errError
+	}
+	e.mu.Lock()
+	cmgf := e.cmgf
+	e.mu.Unlock()
+	if cmgf != 0 {
+		return 0, cmsError(CMSOperationNotAllowed)
+	}
+	l, err := intArg(req.args, 0, -1, 1, 255)
+	if err != nil {
+		return 0, cmsError(CMSInvalidPDUParameter)
+	}
+	return l, nil
+}
+
+// decodePDU validates the hex PDU provided with a command, which must contain
+// a TPDU of length l, and is preceded by an SMSC address if sca is set.
+//
+// Returns the decoded PDU and TPDU, or +CMS ERROR: 304 if the PDU is
+// malformed.
+func decodePDU(s string, l int, sca bool, dir tpdu.Direction) (*pdumode.PDU, *tpdu.TPDU, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, nil, cmsError(CMSInvalidPDUParameter)
+	}
+	p := &pdumode.PDU{TPDU: b}
+	if sca {
+		if p, err = pdumode.UnmarshalBinary(b); err != nil {
+			return nil, nil, cmsError(CMSInvalidPDUParameter)
+		}
+	}
+	if len(p.TPDU) != l {
+		return nil, nil, cmsError(CMSInvalidPDUParameter)
+	}
+	t := tpdu.TPDU{Direction: dir}
+	if err = t.UnmarshalBinary(p.TPDU); err != nil {
+		return nil, nil, cmsError(CMSInvalidPDUParameter)
+	}
+	return p, &t, nil
+}
+
+// tpduLen returns the length of the TPDU within the binary PDU.
+func tpduLen(pdu []byte) int {
+	p, err := pdumode.UnmarshalBinary(pdu)
+	if err != nil {
+		return 0
+	}
+	return len(p.TPDU)
+}
+
+// parseRequest parses an extended command into its name, form and
+// arguments.
+func parseRequest(cmd string) request {
+	req := request{}
+	i := strings.IndexAny(cmd, "=?")
+	if i < 0 {
+		req.name = strings.ToUpper(cmd)
+		req.op = opAction
+		return req
+	}
+	req.name = strings.ToUpper(cmd[:i])
+	switch rest := cmd[i:]; {
+	case rest == "?":
+		req.op = opRead
+	case rest == "=?":
+		req.op = opTest
+	default:
+		req.op = opSet
+		req.args = parseArgs(rest[1:])
+	}
+	return req
+}
+
+// parseArgs splits the arguments of a command, removing any quotes.
+func parseArgs(s string) []string {
+	var args []string
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			args = append(args, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return append(args, strings.TrimSpace(b.String()))
+}
+
+// intArg returns the integer argument at index i, or def if the argument is
+// absent or empty.
+//
+// Returns errError if the argument is not an integer in the range
+// [min,max], or is absent and def is negative.
+func intArg(args []string, i, def, min, max int) (int, error) {
+	if i >= len(args) || args[i] == "" {
+		if def < 0 {
+			return 0, errError
+		}
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[i])
+	if err != nil || n < min || n > max {
+		return 0, errError
+	}
+	return n, nil
+}
+
+// readLine reads a command line from the TE.
+//
+// Lines are terminated by CR, and any LF is ignored.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '\r':
+			return strings.TrimSpace(string(line)), nil
+		case '\n':
+		default:
+			line = append(line, b)
+		}
+	}
+}
+
+// readData prompts for, and reads, the PDU following a command.
+//
+// The PDU is terminated by Ctrl-Z, or aborted by ESC, in which case
+// errAborted is returned.
+func readData(c *conn, r *bufio.Reader) (string, error) {
+	c.write("\r\n> ")
+	var data []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", errAborted
+		}
+		switch b {
+		case 0x1a:
+			return strings.TrimSpace(string(data)), nil
+		case 0x1b:
+			return "", errAborted
+		case '\r', '\n':
+		default:
+			data = append(data, b)
+		}
+	}
+}
+
+// conn is a connection served by the Emulator.
+type conn struct {
+	w io.Writer
+
+	// output is queued and written by a separate goroutine so neither the
+	// reader nor injection block on a write.
+	mu     sync.Mutex // covers the fields below
+	cond   *sync.Cond
+	out    []string
+	closed bool
+}
+
+// write queues the output to be sent to the TE.
+func (c *conn) write(s string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.out = append(c.out, s)
+	c.cond.Signal()
+}
+
+// send writes queued output to the TE until the conn is closed.
+func (c *conn) send() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		for len(c.out) == 0 && !c.closed {
+			c.cond.Wait()
+		}
+		if len(c.out) == 0 {
+			return
+		}
+		s := c.out[0]
+		c.out = c.out[1:]
+		c.mu.Unlock()
+		_, err := io.WriteString(c.w, s)
+		c.mu.Lock()
+		if err != nil {
+			c.closed = true
+			c.out = nil
+		}
+	}
+}
+
+// close closes the conn once any queued output has been sent.
+func (c *conn) close() {
+	c.mu.Lock()
+	c.closed = true
+	c.cond.Signal()
+	c.mu.Unlock()
+}
+
+// cmsError is a +CMS ERROR final result.
+type cmsError int
+
+func (e cmsError) Error() string {
+	return fmt.Sprintf("+CMS ERROR: %d", int(e))
+}
+
+var (
+	// errError is an ERROR final result.
+	errError = errors.New("ERROR")
+
+	// errAborted indicates the command was aborted and no final result is
+	// returned.
+	errAborted = errors.New("aborted")
+)
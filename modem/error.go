@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidResponse indicates the modem returned a response that could not
+// be parsed.
+type ErrInvalidResponse string
+
+func (e ErrInvalidResponse) Error() string {
+	return fmt.Sprintf("modem: invalid response: %q", string(e))
+}
+
+var (
+	// ErrClosed indicates the Modem has been closed, or the underlying
+	// connection has failed.
+	ErrClosed = errors.New("modem: closed")
+
+	// ErrError indicates the modem returned an ERROR final result, or a
+	// +CMS/+CME ERROR that could not be parsed.
+	ErrError = errors.New("modem: ERROR")
+
+	// ErrTimeout indicates the modem did not respond to a command in time.
+	ErrTimeout = errors.New("modem: response timeout")
+
+	// ErrUSSDBusy indicates another USSD session is active on the Modem.
+	ErrUSSDBusy = errors.New("modem: USSD session in progress")
+
+	// ErrUSSDInactive indicates a reply was made to a USSD session that is
+	// not active.
+	ErrUSSDInactive = errors.New("modem: USSD session not active")
+)
@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/warthog618/sms/modem"
+)
+
+func TestCMSError(t *testing.T) {
+	patterns := []struct {
+		name  string
+		err   modem.CMSError
+		msg   string
+		class modem.ErrorClass
+		fcs   int
+		rp    int
+	}{
+		{"rp", modem.CMSUnassignedNumber, "modem: +CMS ERROR: 1 (unassigned number)", modem.ClassPermanent, -1, 1},
+		{"rp temporary", modem.CMSCongestion, "modem: +CMS ERROR: 42 (congestion)", modem.ClassTemporary, -1, 42},
+		{"rp unlisted", modem.CMSError(60), "modem: +CMS ERROR: 60", modem.ClassTemporary, -1, 60},
+		{"fcs", modem.CMSDCSNotSupported, "modem: +CMS ERROR: 144 (data coding scheme not supported)", modem.ClassPermanent, 0x90, -1},
+		{"fcs retryable", modem.CMSSATBusy, "modem: +CMS ERROR: 212 (SIM application toolkit busy)", modem.ClassRetryable, 0xd4, -1},
+		{"fcs unlisted", modem.CMSError(0xe0), "modem: +CMS ERROR: 224", modem.ClassPermanent, 0xe0, -1},
+		{"me", modem.CMSInvalidPDUParameter, "modem: +CMS ERROR: 304 (invalid PDU mode parameter)", modem.ClassPermanent, -1, -1},
+		{"me retryable", modem.CMSNetworkTimeout, "modem: +CMS ERROR: 332 (network timeout)", modem.ClassRetryable, -1, -1},
+		{"unknown", modem.CMSUnknown, "modem: +CMS ERROR: 500 (unknown error)", modem.ClassTemporary, -1, -1},
+		{"manufacturer", modem.CMSError(512), "modem: +CMS ERROR: 512", modem.ClassPermanent, -1, -1},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			assert.Equal(t, p.msg, p.err.Error())
+			assert.Equal(t, p.class, p.err.Class())
+			fcs, ok := p.err.FCS()
+			assert.Equal(t, p.fcs >= 0, ok)
+			if ok {
+				assert.Equal(t, byte(p.fcs), fcs)
+			}
+			rp, ok := p.err.RPCause()
+			assert.Equal(t, p.rp >= 0, ok)
+			if ok {
+				assert.Equal(t, byte(p.rp), rp)
+			}
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestCMEError(t *testing.T) {
+	patterns := []struct {
+		name  string
+		err   modem.CMEError
+		msg   string
+		class modem.ErrorClass
+	}{
+		{"permanent", modem.CMESIMPINRequired, "modem: +CME ERROR: 11 (SIM PIN required)", modem.ClassPermanent},
+		{"temporary", modem.CMENoNetworkService, "modem: +CME ERROR: 30 (no network service)", modem.ClassTemporary},
+		{"retryable", modem.CMESIMBusy, "modem: +CME ERROR: 14 (SIM busy)", modem.ClassRetryable},
+		{"gprs", modem.CMEIllegalMS, "modem: +CME ERROR: 103 (illegal MS)", modem.ClassPermanent},
+		{"congestion", modem.CMECongestion, "modem: +CME ERROR: 122 (congestion)", modem.ClassTemporary},
+		{"tft", modem.CMETFTSemanticError, "modem: +CME ERROR: 141 (semantic error in the TFT operation)", modem.ClassPermanent},
+		{"vgcs", modem.CMEUplinkBusy, "modem: +CME ERROR: 159 (uplink busy)", modem.ClassRetryable},
+		{"eps", modem.CMEUnsupportedQCI, "modem: +CME ERROR: 181 (unsupported QCI value)", modem.ClassPermanent},
+		{"unlisted", modem.CMEError(999), "modem: +CME ERROR: 999", modem.ClassPermanent},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			assert.Equal(t, p.msg, p.err.Error())
+			assert.Equal(t, p.class, p.err.Class())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	err := fmt.Errorf("send: %w", modem.CMSSCBusy)
+	assert.True(t, errors.Is(err, modem.CMSSCBusy))
+	assert.True(t, errors.Is(err, modem.ClassTemporary))
+	assert.False(t, errors.Is(err, modem.ClassPermanent))
+	assert.False(t, errors.Is(err, modem.CMSSCSystemFailure))
+	var cms modem.CMSError
+	assert.True(t, errors.As(err, &cms))
+	assert.Equal(t, modem.CMSSCBusy, cms)
+
+	err = fmt.Errorf("init: %w", modem.CMESIMBusy)
+	assert.True(t, errors.Is(err, modem.ClassRetryable))
+	assert.False(t, errors.Is(err, modem.CMSSIMBusy))
+
+	assert.Equal(t, modem.ClassTemporary, modem.ClassOf(modem.CMSError(41)))
+	assert.Equal(t, modem.ClassRetryable, modem.ClassOf(err))
+	assert.Equal(t, modem.ClassRetryable, modem.ClassOf(modem.ErrTimeout))
+	assert.Equal(t, modem.ClassPermanent, modem.ClassOf(modem.ErrClosed))
+	assert.Equal(t, modem.ClassPermanent, modem.ClassOf(nil))
+
+	assert.Equal(t, "modem: temporary error", modem.ClassTemporary.Error())
+	assert.Equal(t, "unknown", modem.ErrorClass(7).String())
+}
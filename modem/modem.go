@@ -0,0 +1,533 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package modem provides a driver for GSM modems that send and receive SMS
+// using the 3GPP TS 27.005 AT command set in PDU mode.
+//
+// The Modem drives an io.ReadWriter, typically a serial port, issuing
+// commands and collecting their responses, while passing unsolicited result
+// codes for received messages to a handler.
+//
+// Messages held in the modem storage may also be listed, read, written and
+// deleted, and interactive USSD sessions may be conducted with the network.
+package modem
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+const (
+	// ctrlZ terminates the PDU following an AT+CMGS prompt.
+	ctrlZ = "\x1a"
+
+	// esc aborts the PDU following an AT+CMGS prompt.
+	esc = "\x1b"
+)
+
+// Modem is a driver for a GSM modem.
+//
+// A Modem is safe for concurrent use, though commands are issued to the modem
+// one at a time.
+type Modem struct {
+	rw         io.ReadWriter
+	timeout    time.Duration
+	cnmi       string
+	handler    func(*tpdu.TPDU)
+	errHandler func(error)
+	ack        bool
+	report     func(*tpdu.TPDU) *tpdu.TPDU
+
+	// cmdMu serialises commands
+	cmdMu sync.Mutex
+
+	// done is closed when the Modem is closed or the connection fails.
+	done chan struct{}
+
+	mu     sync.Mutex // covers the fields below
+	cmd    *command
+	events []func()
+	err    error
+	ussd   *USSDSession
+
+	// pending is signalled when events are queued.
+	pending chan struct{}
+}
+
+// command is a command in progress.
+type command struct {
+	// info contains the information text returned by the modem.
+	info []string
+
+	// prompt is signalled when the modem prompts for data.
+	prompt chan struct{}
+
+	// result receives the final result.
+	result chan error
+}
+
+// New creates a Modem that drives the modem connected to the ReadWriter.
+//
+// The Modem immediately starts reading from the ReadWriter, but does not
+// configure the modem until Init is called.
+func New(rw io.ReadWriter, options ...Option) *Modem {
+	m := Modem{
+		rw:         rw,
+		timeout:    10 * time.Second,
+		cnmi:       "2,2,0,1,0",
+		handler:    func(*tpdu.TPDU) {},
+		errHandler: func(error) {},
+		done:       make(chan struct{}),
+		pending:    make(chan struct{}, 1),
+	}
+	for _, option := range options {
+		option(&m)
+	}
+	go m.read()
+	go m.dispatch()
+	return &m
+}
+
+// Close stops the Modem.
+//
+// If the ReadWriter is an io.Closer then it is closed.
+// Any command in progress is aborted with ErrClosed.
+func (m *Modem) Close() error {
+	m.close(ErrClosed)
+	if c, ok := m.rw.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Init configures the modem for PDU mode and for the delivery of received
+// messages to the Modem.
+func (m *Modem) Init(ctx context.Context) error {
+	cmds := []string{
+		"E0",      // disable echo
+		"+CMEE=1", // numeric error codes
+		"+CMGF=0", // PDU mode
+	}
+	if m.ack {
+		cmds = append(cmds, "+CSMS=1") // phase 2+
+	}
+	cmds = append(cmds, "+CNMI="+m.cnmi)
+	for _, cmd := range cmds {
+		if _, err := m.Command(ctx, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command issues the command to the modem and returns the information text
+// of the response.
+//
+// The command is provided without the "AT" prefix, e.g. "+CMGF=0".
+//
+// If the modem returns an error final result then the corresponding error is
+// returned, i.e. ErrError, CMSError or CMEError.
+func (m *Modem) Command(ctx context.Context, cmd string) ([]string, error) {
+	return m.command(ctx, cmd, nil)
+}
+
+// SMSCommand issues a command that prompts for data, such as AT+CMGS, and
+// provides the data, typically a PDU, when prompted.
+//
+// Returns the information text of the response.
+func (m *Modem) SMSCommand(ctx context.Context, cmd, data string) ([]string, error) {
+	return m.command(ctx, cmd, &data)
+}
+
+// Ack acknowledges a received message using AT+CNMA.
+//
+// The report is the SMS-DELIVER-REPORT to acknowledge the message with, as
+// created by tpdu.NewDeliverReport. If the report is nil then the message is
+// acknowledged without a report.
+//
+// This is only required if the phase 2+ message service is selected and
+// WithAck is not used.
+func (m *Modem) Ack(ctx context.Context, report *tpdu.TPDU) error {
+	if report == nil {
+		_, err := m.Command(ctx, "+CNMA")
+		return err
+	}
+	cmd, pdu, err := CNMACommand(report)
+	if err != nil {
+		return err
+	}
+	_, err = m.SMSCommand(ctx, strings.TrimPrefix(cmd, "AT"), pdu)
+	return err
+}
+
+// CNMACommand returns the AT+CNMA command, and the PDU that follows it, that
+// acknowledges a received message with the SMS-DELIVER-REPORT.
+//
+// A report with a zero FCS is carried in an RP-ACK, with <n> 1, while others
+// are carried in an RP-ERROR, with <n> 2.
+func CNMACommand(report *tpdu.TPDU) (cmd string, pdu string, err error) {
+	if st := report.SmsType(); st != tpdu.SmsDeliverReport {
+		return "", "", tpdu.ErrUnsupportedSmsType(st)
+	}
+	b, err := report.MarshalBinary()
+	if err != nil {
+		return "", "", err
+	}
+	n := 1
+	if report.FCS != 0 {
+		n = 2
+	}
+	cmd = fmt.Sprintf("AT+CNMA=%d,%d", n, len(b))
+	return cmd, strings.ToUpper(hex.EncodeToString(b)), nil
+}
+
+// Send sends the TPDUs using AT+CMGS.
+//
+// The TPDUs are typically the output of an sms.Encoder, and so may be the
+// segments of a concatenated message. The TPDUs are sent using the SMSC
+// address configured in the modem.
+//
+// Returns the TP-MR assigned by the modem to each TPDU sent. In the case of
+// error the TP-MRs of the TPDUs successfully sent before the error are
+// returned.
+func (m *Modem) Send(ctx context.Context, pdus ...tpdu.TPDU) ([]int, error) {
+	mrs := make([]int, 0, len(pdus))
+	for i := range pdus {
+		b, err := pdus[i].MarshalBinary()
+		if err != nil {
+			return mrs, err
+		}
+		p := pdumode.PDU{TPDU: b}
+		s, err := p.MarshalHexString()
+		if err != nil {
+			return mrs, err
+		}
+		cmd := strings.TrimPrefix(pdumode.CMGSCommand(&p), "AT")
+		info, err := m.SMSCommand(ctx, cmd, strings.ToUpper(s))
+		if err != nil {
+			return mrs, err
+		}
+		mr, err := parseCMGS(info)
+		if err != nil {
+			return mrs, err
+		}
+		mrs = append(mrs, mr)
+	}
+	return mrs, nil
+}
+
+func (m *Modem) command(ctx context.Context, cmd string, data *string) ([]string, error) {
+	m.cmdMu.Lock()
+	defer m.cmdMu.Unlock()
+	c := &command{
+		prompt: make(chan struct{}, 1),
+		result: make(chan error, 1),
+	}
+	m.mu.Lock()
+	if m.err != nil {
+		m.mu.Unlock()
+		return nil, m.err
+	}
+	m.cmd = c
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		if m.cmd == c {
+			m.cmd = nil
+		}
+		m.mu.Unlock()
+	}()
+	if _, err := io.WriteString(m.rw, "AT"+cmd+"\r"); err != nil {
+		return nil, err
+	}
+	t := time.NewTimer(m.timeout)
+	defer t.Stop()
+	if data != nil {
+		select {
+		case <-c.prompt:
+		case err := <-c.result:
+			if err == nil {
+				err = ErrInvalidResponse("OK")
+			}
+			return c.info, err
+		case <-m.done:
+			return nil, m.error()
+		case <-ctx.Done():
+			io.WriteString(m.rw, esc)
+			return nil, ctx.Err()
+		case <-t.C:
+			io.WriteString(m.rw, esc)
+			return nil, ErrTimeout
+		}
+		if _, err := io.WriteString(m.rw, *data+ctrlZ); err != nil {
+			return nil, err
+		}
+	}
+	select {
+	case err := <-c.result:
+		return c.info, err
+	case <-m.done:
+		return nil, m.error()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.C:
+		return nil, ErrTimeout
+	}
+}
+
+func (m *Modem) close(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return
+	}
+	m.err = err
+	close(m.done)
+}
+
+func (m *Modem) error() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// read reads lines from the modem until the connection fails.
+func (m *Modem) read() {
+	r := bufio.NewReader(m.rw)
+	var line []byte
+	// hdr is the header of an unsolicited result awaiting its PDU line.
+	var hdr string
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			m.close(ErrClosed)
+			return
+		}
+		if b != '\n' {
+			line = append(line, b)
+			// the prompt for data is not terminated
+			if string(line) == "> " {
+				m.prompt()
+				line = line[:0]
+			}
+			continue
+		}
+		l := strings.TrimSpace(string(line))
+		line = line[:0]
+		if len(l) == 0 {
+			continue
+		}
+		if hdr != "" {
+			h := hdr
+			hdr = ""
+			m.event(func() { m.deliver(h, l) })
+			continue
+		}
+		switch {
+		case strings.HasPrefix(l, "+CMT:"), strings.HasPrefix(l, "+CDS:"):
+			hdr = l
+		case strings.HasPrefix(l, "+CMTI:"), strings.HasPrefix(l, "+CDSI:"):
+			m.event(func() { m.readIndicated(l) })
+		case strings.HasPrefix(l, "+CUSD:"):
+			m.cusd(l)
+		default:
+			m.respond(l)
+		}
+	}
+}
+
+// prompt signals the command in progress that the modem is ready for data.
+func (m *Modem) prompt() {
+	m.mu.Lock()
+	c := m.cmd
+	m.mu.Unlock()
+	if c == nil {
+		return
+	}
+	select {
+	case c.prompt <- struct{}{}:
+	default:
+	}
+}
+
+// respond adds a line of the response to the command in progress.
+func (m *Modem) respond(l string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := m.cmd
+	if c == nil {
+		return
+	}
+	var err error
+	switch {
+	case l == "OK":
+	case l == "ERROR":
+		err = ErrError
+	case strings.HasPrefix(l, "+CMS ERROR:"):
+		err = ErrError
+		if n, perr := strconv.Atoi(strings.TrimSpace(l[11:])); perr == nil {
+			err = CMSError(n)
+		}
+	case strings.HasPrefix(l, "+CME ERROR:"):
+		err = ErrError
+		if n, perr := strconv.Atoi(strings.TrimSpace(l[11:])); perr == nil {
+			err = CMEError(n)
+		}
+	case strings.HasPrefix(l, "AT"):
+		// echo of the command
+		return
+	default:
+		c.info = append(c.info, l)
+		return
+	}
+	m.cmd = nil
+	c.result <- err
+}
+
+// deliver passes a TPDU received in a +CMT or +CDS to the handler, and
+// acknowledges it if WithAck is set.
+//
+// TPDUs that cannot be parsed or decoded are not passed to the handler, and
+// are rejected with an unspecified TP-FCS.
+func (m *Modem) deliver(hdr, pdu string) {
+	var p *pdumode.PDU
+	var err error
+	if strings.HasPrefix(hdr, "+CMT:") {
+		var r *pdumode.CMT
+		if r, err = pdumode.ParseCMT(hdr, pdu); err == nil {
+			p = r.PDU
+		}
+	} else {
+		var r *pdumode.CDS
+		if r, err = pdumode.ParseCDS(hdr, pdu); err == nil {
+			p = r.PDU
+		}
+	}
+	var t *tpdu.TPDU
+	if err == nil {
+		t, err = decodeTPDU(p)
+	}
+	if err == nil {
+		m.handler(t)
+	}
+	if !m.ack {
+		return
+	}
+	var report *tpdu.TPDU
+	switch {
+	case err != nil:
+		report, _ = tpdu.NewDeliverReport(tpdu.WithFCS(0xff))
+	case m.report != nil && t.SmsType() == tpdu.SmsDeliver:
+		report = m.report(t)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	if err = m.Ack(ctx, report); err != nil {
+		m.errHandler(err)
+	}
+}
+
+// readIndicated reads the message indicated by a +CMTI or +CDSI and passes it
+// to the handler.
+//
+// The message is read from the current read storage, as selected by
+// AT+CPMS.
+func (m *Modem) readIndicated(l string) {
+	fields := strings.Split(l[strings.Index(l, ":")+1:], ",")
+	if len(fields) != 2 {
+		return
+	}
+	idx, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	info, err := m.Command(ctx, fmt.Sprintf("+CMGR=%d", idx))
+	if err != nil {
+		return
+	}
+	for i := 0; i < len(info)-1; i++ {
+		if strings.HasPrefix(info[i], "+CMGR:") {
+			r, err := pdumode.ParseCMGR(info[i], info[i+1])
+			if err != nil {
+				return
+			}
+			if t, err := decodeTPDU(r.PDU); err == nil {
+				m.handler(t)
+			}
+			return
+		}
+	}
+}
+
+// event queues a handler call for dispatch.
+//
+// The queue is unbounded so the reader never blocks on a handler that is
+// waiting for the modem to respond.
+func (m *Modem) event(f func()) {
+	m.mu.Lock()
+	m.events = append(m.events, f)
+	m.mu.Unlock()
+	select {
+	case m.pending <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch calls the handlers, in order, from a goroutine separate from the
+// reader so handlers may issue commands to the Modem.
+func (m *Modem) dispatch() {
+	for {
+		select {
+		case <-m.pending:
+		case <-m.done:
+			return
+		}
+		for {
+			m.mu.Lock()
+			if len(m.events) == 0 {
+				m.mu.Unlock()
+				break
+			}
+			f := m.events[0]
+			m.events = m.events[1:]
+			m.mu.Unlock()
+			f()
+		}
+	}
+}
+
+// decodeTPDU decodes the TPDU received from the modem.
+func decodeTPDU(p *pdumode.PDU) (*tpdu.TPDU, error) {
+	t := tpdu.TPDU{Direction: tpdu.MT}
+	if err := t.UnmarshalBinary(p.TPDU); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// parseCMGS extracts the TP-MR from the +CMGS response.
+func parseCMGS(info []string) (int, error) {
+	for _, l := range info {
+		if strings.HasPrefix(l, "+CMGS:") {
+			r, err := pdumode.ParseCMGS(l)
+			if err != nil {
+				return 0, err
+			}
+			return r.MR, nil
+		}
+	}
+	return 0, ErrInvalidResponse(strings.Join(info, "\n"))
+}
@@ -0,0 +1,507 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem_test
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/modem"
+)
+
+// step is a step in the script followed by the fakeModem.
+type step struct {
+	// rx is the command expected from the Modem, including the terminating
+	// CR or Ctrl-Z.
+	rx string
+
+	// tx is the response returned to the Modem.
+	tx string
+}
+
+// fakeModem plays a script of commands and responses over io.Pipes.
+type fakeModem struct {
+	// modem side
+	mr *io.PipeReader
+	mw *io.PipeWriter
+
+	// fake side
+	fr *io.PipeReader
+	fw *io.PipeWriter
+
+	done chan struct{}
+
+	mu sync.Mutex
+	rx []string
+}
+
+// pipes is the ReadWriter provided to the Modem.
+type pipes struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (p pipes) Close() error {
+	p.PipeReader.Close()
+	return p.PipeWriter.Close()
+}
+
+func newFakeModem(script []step) *fakeModem {
+	f := fakeModem{done: make(chan struct{})}
+	f.mr, f.fw = io.Pipe()
+	f.fr, f.mw = io.Pipe()
+	go f.run(script)
+	return &f
+}
+
+func (f *fakeModem) rw() io.ReadWriter {
+	return pipes{f.mr, f.mw}
+}
+
+func (f *fakeModem) run(script []step) {
+	defer close(f.done)
+	for _, s := range script {
+		cmd, err := f.readCmd()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.rx = append(f.rx, cmd)
+		f.mu.Unlock()
+		if cmd != s.rx {
+			f.write("\r\nERROR\r\n")
+			continue
+		}
+		f.write(s.tx)
+	}
+	// drain anything after the end of the script
+	for {
+		cmd, err := f.readCmd()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.rx = append(f.rx, cmd)
+		f.mu.Unlock()
+	}
+}
+
+func (f *fakeModem) readCmd() (string, error) {
+	var cmd []byte
+	b := make([]byte, 1)
+	for {
+		if _, err := f.fr.Read(b); err != nil {
+			return "", err
+		}
+		cmd = append(cmd, b[0])
+		if b[0] == '\r' || b[0] == 0x1a || b[0] == 0x1b {
+			return string(cmd), nil
+		}
+	}
+}
+
+// write injects a response, or an unsolicited result, into the Modem.
+func (f *fakeModem) write(s string) {
+	io.WriteString(f.fw, s)
+}
+
+// received returns the commands received from the Modem.
+func (f *fakeModem) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.rx...)
+}
+
+func (f *fakeModem) close() {
+	f.fw.Close()
+	f.fr.Close()
+}
+
+func testContext() (context.Context, func()) {
+	return context.WithTimeout(context.Background(), time.Second)
+}
+
+func TestInit(t *testing.T) {
+	script := []step{
+		{"ATE0\r", "ATE0\r\r\nOK\r\n"},
+		{"AT+CMEE=1\r", "\r\nOK\r\n"},
+		{"AT+CMGF=0\r", "\r\nOK\r\n"},
+		{"AT+CNMI=1,1,0,2,0\r", "\r\nOK\r\n"},
+	}
+	f := newFakeModem(script)
+	defer f.close()
+	m := modem.New(f.rw(), modem.WithCNMI("1,1,0,2,0"))
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+	err := m.Init(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"ATE0\r", "AT+CMEE=1\r", "AT+CMGF=0\r", "AT+CNMI=1,1,0,2,0\r"}, f.received())
+
+	// failure
+	f = newFakeModem([]step{
+		{"ATE0\r", "\r\nOK\r\n"},
+		{"AT+CMEE=1\r", "\r\nOK\r\n"},
+		{"AT+CMGF=0\r", "\r\n+CME ERROR: 4\r\n"},
+	})
+	defer f.close()
+	m = modem.New(f.rw())
+	defer m.Close()
+	err = m.Init(ctx)
+	assert.Equal(t, modem.CMEError(4), err)
+	assert.Equal(t, 3, len(f.received()))
+}
+
+func TestCommand(t *testing.T) {
+	patterns := []struct {
+		name string
+		tx   string
+		info []string
+		err  error
+	}{
+		{"ok", "\r\nOK\r\n", nil, nil},
+		{"info", "\r\n+CSCA: \"+61412345678\",145\r\n\r\nOK\r\n", []string{"+CSCA: \"+61412345678\",145"}, nil},
+		{"echo", "AT+CSCA?\r\r\nOK\r\n", nil, nil},
+		{"error", "\r\nERROR\r\n", nil, modem.ErrError},
+		{"cms error", "\r\n+CMS ERROR: 304\r\n", nil, modem.CMSError(304)},
+		{"cme error", "\r\n+CME ERROR: 10\r\n", nil, modem.CMEError(10)},
+		{"verbose error", "\r\n+CME ERROR: SIM not inserted\r\n", nil, modem.ErrError},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			fm := newFakeModem([]step{{"AT+CSCA?\r", p.tx}})
+			defer fm.close()
+			m := modem.New(fm.rw())
+			defer m.Close()
+			ctx, cancel := testContext()
+			defer cancel()
+			info, err := m.Command(ctx, "+CSCA?")
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.info, info)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	f := newFakeModem([]step{{"AT\r", ""}})
+	defer f.close()
+	m := modem.New(f.rw(), modem.WithTimeout(10*time.Millisecond))
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+	_, err := m.Command(ctx, "")
+	assert.Equal(t, modem.ErrTimeout, err)
+
+	// late response is ignored
+	f.write("\r\nOK\r\n")
+	_, err = m.Command(ctx, "")
+	assert.Equal(t, modem.ErrTimeout, err)
+}
+
+func TestClose(t *testing.T) {
+	f := newFakeModem(nil)
+	m := modem.New(f.rw())
+	f.close()
+	ctx, cancel := testContext()
+	defer cancel()
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		t.Fatal("fake modem didn't exit")
+	}
+	// allow the reader to notice the closed pipe
+	var err error
+	for i := 0; i < 100; i++ {
+		if _, err = m.Command(ctx, ""); err == modem.ErrClosed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, modem.ErrClosed, err)
+
+	f = newFakeModem(nil)
+	defer f.close()
+	m = modem.New(f.rw())
+	assert.Nil(t, m.Close())
+	_, err = m.Command(ctx, "")
+	assert.Equal(t, modem.ErrClosed, err)
+}
+
+func TestSend(t *testing.T) {
+	msg := "this is a very long message that does not fit in a single SMS message, at least it will if I keep adding more to it as 160 characters is more than you might think"
+	e := sms.NewEncoder(sms.AsSubmit, sms.To("1234"))
+	tpdus, err := e.Encode([]byte(msg))
+	require.Nil(t, err)
+	require.Equal(t, 2, len(tpdus))
+	var script []step
+	for i, p := range tpdus {
+		b, err := p.MarshalBinary()
+		require.Nil(t, err)
+		script = append(script,
+			step{"AT+CMGS=" + strconv.Itoa(len(b)) + "\r", "\r\n> "},
+			step{"00" + strings.ToUpper(hex.EncodeToString(b)) + "\x1a",
+				"\r\n+CMGS: " + strconv.Itoa(42+i) + "\r\n\r\nOK\r\n"})
+	}
+	f := newFakeModem(script)
+	defer f.close()
+	m := modem.New(f.rw())
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+	mrs, err := m.Send(ctx, tpdus...)
+	require.Nil(t, err)
+	assert.Equal(t, []int{42, 43}, mrs)
+	assert.Equal(t, 4, len(f.received()))
+}
+
+func TestSendFail(t *testing.T) {
+	e := sms.NewEncoder(sms.AsSubmit, sms.To("1234"))
+	tpdus, err := e.Encode([]byte("hello"))
+	require.Nil(t, err)
+	b, err := tpdus[0].MarshalBinary()
+	require.Nil(t, err)
+	cmgs := "AT+CMGS=" + strconv.Itoa(len(b)) + "\r"
+	pdu := "00" + strings.ToUpper(hex.EncodeToString(b)) + "\x1a"
+	patterns := []struct {
+		name   string
+		script []step
+		err    error
+	}{
+		{"rejected", []step{{cmgs, "\r\n+CMS ERROR: 304\r\n"}}, modem.CMSError(304)},
+		{"failed", []step{{cmgs, "\r\n> "}, {pdu, "\r\n+CMS ERROR: 500\r\n"}}, modem.CMSError(500)},
+		{"no prompt", []step{{cmgs, "\r\nOK\r\n"}}, modem.ErrInvalidResponse("OK")},
+		{"no mr", []step{{cmgs, "\r\n> "}, {pdu, "\r\nOK\r\n"}}, modem.ErrInvalidResponse("")},
+		{"bad mr", []step{{cmgs, "\r\n> "}, {pdu, "\r\n+CMGS: 256\r\n\r\nOK\r\n"}}, pdumode.ErrInvalidHeader("+CMGS: 256")},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			fm := newFakeModem(p.script)
+			defer fm.close()
+			m := modem.New(fm.rw())
+			defer m.Close()
+			ctx, cancel := testContext()
+			defer cancel()
+			mrs, err := m.Send(ctx, tpdus...)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, []int{}, mrs)
+		}
+		t.Run(p.name, f)
+	}
+
+	// ack pdu following the mr
+	fm := newFakeModem([]step{{cmgs, "\r\n> "}, {pdu, "\r\n+CMGS: 7,\"0100\"\r\n\r\nOK\r\n"}})
+	defer fm.close()
+	m := modem.New(fm.rw())
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+	mrs, err := m.Send(ctx, tpdus...)
+	require.Nil(t, err)
+	assert.Equal(t, []int{7}, mrs)
+
+	// timeout waiting for prompt aborts with escape
+	fm = newFakeModem([]step{{cmgs, ""}})
+	defer fm.close()
+	m = modem.New(fm.rw(), modem.WithTimeout(10*time.Millisecond))
+	defer m.Close()
+	_, err = m.Send(ctx, tpdus...)
+	assert.Equal(t, modem.ErrTimeout, err)
+	for i := 0; i < 100 && len(fm.received()) < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, []string{cmgs, "\x1b"}, fm.received())
+}
+
+const (
+	// SMS-DELIVER from +61421234567, "Hi", with SMSC address
+	deliverPDU = "07911614786007F0040B911624214365F700000290901071142302C834"
+
+	// SMS-STATUS-REPORT, MR 42, from 1234, delivered
+	statusReportPDU = "0006" + "2A" + "04A12143" + "02909010711423" + "02909010711523" + "00"
+)
+
+func TestReceive(t *testing.T) {
+	f := newFakeModem([]step{
+		{"AT+CMGR=3\r", "\r\n+CMGR: 0,,21\r\n" + deliverPDU + "\r\n\r\nOK\r\n"},
+	})
+	defer f.close()
+	ch := make(chan *tpdu.TPDU, 3)
+	m := modem.New(f.rw(), modem.WithHandler(func(t *tpdu.TPDU) { ch <- t }))
+	defer m.Close()
+	f.write("\r\n+CMT: ,21\r\n" + deliverPDU + "\r\n")
+	f.write("\r\n+CDS: 21\r\n" + statusReportPDU + "\r\n")
+	f.write("\r\n+CMTI: \"SM\",3\r\n")
+
+	expected := []struct {
+		st   tpdu.SmsType
+		addr string
+	}{
+		{tpdu.SmsDeliver, "61421234567"},
+		{tpdu.SmsStatusReport, "1234"},
+		{tpdu.SmsDeliver, "61421234567"},
+	}
+	for _, x := range expected {
+		select {
+		case r := <-ch:
+			assert.Equal(t, x.st, r.SmsType())
+			if x.st == tpdu.SmsDeliver {
+				assert.Equal(t, x.addr, r.OA.Addr)
+				assert.Equal(t, tpdu.UserData("Hi"), r.UD)
+			} else {
+				assert.Equal(t, x.addr, r.RA.Addr)
+				assert.Equal(t, byte(42), r.MR)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for TPDU")
+		}
+	}
+	assert.Equal(t, []string{"AT+CMGR=3\r"}, f.received())
+}
+
+func TestReceiveDuringCommand(t *testing.T) {
+	f := newFakeModem([]step{
+		{"AT+CSQ\r", "\r\n+CMT: ,21\r\n" + deliverPDU + "\r\n\r\n+CSQ: 20,99\r\n\r\nOK\r\n"},
+	})
+	defer f.close()
+	ch := make(chan *tpdu.TPDU, 1)
+	m := modem.New(f.rw(), modem.WithHandler(func(t *tpdu.TPDU) { ch <- t }))
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+	info, err := m.Command(ctx, "+CSQ")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"+CSQ: 20,99"}, info)
+	select {
+	case r := <-ch:
+		assert.Equal(t, "61421234567", r.OA.Addr)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for TPDU")
+	}
+}
+
+func TestCNMACommand(t *testing.T) {
+	patterns := []struct {
+		name   string
+		report func() (*tpdu.TPDU, error)
+		cmd    string
+		pdu    string
+		err    error
+	}{
+		{"rp-ack", func() (*tpdu.TPDU, error) { return tpdu.NewDeliverReport() },
+			"AT+CNMA=1,2", "0000", nil},
+		{"rp-error", func() (*tpdu.TPDU, error) { return tpdu.NewDeliverReport(tpdu.WithFCS(0xd3)) },
+			"AT+CNMA=2,3", "00D300", nil},
+		{"ud", func() (*tpdu.TPDU, error) {
+			return tpdu.NewDeliverReport(tpdu.WithFCS(0xd3), tpdu.DCS(0x04), tpdu.WithUD([]byte{0xab}))
+		}, "AT+CNMA=2,6", "00D3060401AB", nil},
+		{"deliver", func() (*tpdu.TPDU, error) { return tpdu.NewDeliver() },
+			"", "", tpdu.ErrUnsupportedSmsType(tpdu.SmsDeliver)},
+		{"bad dcs", func() (*tpdu.TPDU, error) {
+			return tpdu.NewDeliverReport(tpdu.DCS(0x80), tpdu.WithUD([]byte{0xab}))
+		}, "", "", tpdu.EncodeError("SmsDeliverReport.ud.alphabet", tpdu.ErrInvalid)},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := p.report()
+			require.Nil(t, err)
+			cmd, pdu, err := modem.CNMACommand(r)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.cmd, cmd)
+			assert.Equal(t, p.pdu, pdu)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestAck(t *testing.T) {
+	f := newFakeModem([]step{
+		{"AT+CNMA\r", "\r\nOK\r\n"},
+		{"AT+CNMA=2,3\r", "\r\n> "},
+		{"00D300\x1a", "\r\nOK\r\n"},
+		{"AT+CNMA\r", "\r\n+CMS ERROR: 340\r\n"},
+	})
+	defer f.close()
+	m := modem.New(f.rw())
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	assert.Nil(t, m.Ack(ctx, nil))
+	r, err := tpdu.NewDeliverReport(tpdu.WithFCS(0xd3))
+	require.Nil(t, err)
+	assert.Nil(t, m.Ack(ctx, r))
+	assert.Equal(t, modem.CMSNoCNMAExpected, m.Ack(ctx, nil))
+	d, err := tpdu.NewDeliver()
+	require.Nil(t, err)
+	assert.Equal(t, tpdu.ErrUnsupportedSmsType(tpdu.SmsDeliver), m.Ack(ctx, d))
+}
+
+func TestReceiveAck(t *testing.T) {
+	script := []step{
+		{"AT+CNMA=2,3\r", "\r\n> "},
+		{"00D300\x1a", "\r\nOK\r\n"},
+		{"AT+CNMA\r", "\r\nOK\r\n"},
+		{"AT+CNMA=2,3\r", "\r\n> "},
+		{"00FF00\x1a", "\r\nOK\r\n"},
+	}
+	f := newFakeModem(script)
+	defer f.close()
+	ch := make(chan *tpdu.TPDU, 3)
+	report := func(t *tpdu.TPDU) *tpdu.TPDU {
+		r, _ := tpdu.NewDeliverReport(tpdu.WithFCS(0xd3))
+		return r
+	}
+	m := modem.New(f.rw(),
+		modem.WithHandler(func(t *tpdu.TPDU) { ch <- t }),
+		modem.WithAck(report))
+	defer m.Close()
+	f.write("\r\n+CMT: ,21\r\n" + deliverPDU + "\r\n")
+	f.write("\r\n+CDS: 21\r\n" + statusReportPDU + "\r\n")
+	f.write("\r\n+CMT: ,3\r\n00040000\r\n") // undecodable
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for TPDU")
+		}
+	}
+	for i := 0; i < 100 && len(f.received()) < len(script); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	rx := make([]string, len(script))
+	for i, s := range script {
+		rx[i] = s.rx
+	}
+	assert.Equal(t, rx, f.received())
+	assert.Equal(t, 0, len(ch))
+
+	// Init selects phase 2+
+	f = newFakeModem([]step{
+		{"ATE0\r", "\r\nOK\r\n"},
+		{"AT+CMEE=1\r", "\r\nOK\r\n"},
+		{"AT+CMGF=0\r", "\r\nOK\r\n"},
+		{"AT+CSMS=1\r", "\r\n+CSMS: 1,1,1\r\n\r\nOK\r\n"},
+		{"AT+CNMI=2,2,0,1,0\r", "\r\nOK\r\n"},
+	})
+	defer f.close()
+	m = modem.New(f.rw(), modem.WithAck(nil))
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+	require.Nil(t, m.Init(ctx))
+	assert.Equal(t, 5, len(f.received()))
+}
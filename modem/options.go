@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem
+
+import (
+	"time"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Option alters the behaviour of a Modem.
+type Option func(*Modem)
+
+// WithTimeout sets the time the Modem waits for the response to a command.
+//
+// The default is 10 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(m *Modem) {
+		m.timeout = d
+	}
+}
+
+// WithCNMI sets the parameters of the AT+CNMI command issued by Init.
+//
+// The default is "2,2,0,1,0", which routes SMS-DELIVERs directly to the
+// Modem as +CMT, and SMS-STATUS-REPORTs as +CDS.
+func WithCNMI(params string) Option {
+	return func(m *Modem) {
+		m.cnmi = params
+	}
+}
+
+// WithHandler sets the handler for TPDUs received from the modem.
+//
+// The handler is passed each SMS-DELIVER and SMS-STATUS-REPORT as it is
+// received, so the segments of a concatenated message are passed
+// individually. They may be reassembled using an sms.Collector.
+//
+// The handler is called from a goroutine separate from the one reading the
+// modem, so it may issue commands to the Modem.
+func WithHandler(h func(*tpdu.TPDU)) Option {
+	return func(m *Modem) {
+		m.handler = h
+	}
+}
+
+// WithErrorHandler sets the handler for errors encountered while handling
+// received messages, such as the failure of the AT+CNMA that acknowledges a
+// message.
+//
+// The handler is called from the same goroutine as the handler set by
+// WithHandler.
+func WithErrorHandler(h func(error)) Option {
+	return func(m *Modem) {
+		m.errHandler = h
+	}
+}
+
+// WithAck enables the phase 2+ acknowledgement of received messages.
+//
+// Init selects the phase 2+ message service with AT+CSMS=1, and each message
+// received as a +CMT or +CDS is then acknowledged with AT+CNMA once the
+// handler returns.
+//
+// The report function is called with each SMS-DELIVER and returns the
+// SMS-DELIVER-REPORT to acknowledge it with, as created by
+// tpdu.NewDeliverReport, or nil to acknowledge it without a report.
+// A report with a non-zero FCS rejects the message.
+//
+// If the report function is nil then messages are acknowledged without a
+// report. SMS-STATUS-REPORTs are always acknowledged without a report, while
+// messages that cannot be parsed or decoded are rejected with an unspecified
+// TP-FCS.
+//
+// Failures to acknowledge a message are passed to the handler set by
+// WithErrorHandler.
+func WithAck(report func(*tpdu.TPDU) *tpdu.TPDU) Option {
+	return func(m *Modem) {
+		m.ack = true
+		m.report = report
+	}
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package pool
+
+import "time"
+
+// Option alters the behaviour of a Pool.
+type Option func(*Pool)
+
+// WithPolicy sets the policy used to select the modem that sends a message.
+//
+// The default is RoundRobin.
+func WithPolicy(policy Policy) Option {
+	return func(p *Pool) {
+		p.policy = policy
+	}
+}
+
+// WithMaxErrors sets the number of consecutive send errors after which a
+// modem is taken out of rotation.
+//
+// Only timeouts and ME +CMS ERRORs, from 300, are counted, other than those
+// indicating invalid parameters. +CMS ERRORs returned by the network, such as
+// an RP cause or TP-FCS, are failures of the message, not of the modem, so
+// are not counted. A modem that is closed is taken out of rotation
+// immediately.
+//
+// The default is 3.
+func WithMaxErrors(n int) Option {
+	return func(p *Pool) {
+		p.maxErrors = n
+	}
+}
+
+// WithRecovery sets the period after which a modem taken out of rotation is
+// returned to it.
+//
+// The default is zero, which leaves the modem out of rotation until it is
+// restored using Restore.
+func WithRecovery(d time.Duration) Option {
+	return func(p *Pool) {
+		p.recovery = d
+	}
+}
+
+// MemberOption alters the behaviour of a modem in a Pool.
+type MemberOption func(*member)
+
+// WithQuota sets the number of TPDUs the modem may send, e.g. the limit of
+// the plan of its SIM.
+//
+// Once the quota is reached the modem is no longer selected, until the quota
+// is reset using ResetQuotas.
+//
+// The default is zero, which is unlimited.
+func WithQuota(n int) MemberOption {
+	return func(m *member) {
+		m.quota = n
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package pool
+
+// Policy determines the modem selected to send a message.
+type Policy int
+
+const (
+	// RoundRobin selects each modem in turn.
+	RoundRobin Policy = iota
+
+	// LeastLoaded selects the modem with the fewest TPDUs in flight, with
+	// ties broken by the fewest TPDUs sent.
+	LeastLoaded
+
+	// Quota selects the modem with the most quota remaining, treating
+	// modems without a quota as having unlimited quota.
+	Quota
+)
+
+// selectMember selects a member from the candidates, which are provided in
+// round robin order.
+func (p Policy) selectMember(cands []*member) *member {
+	sel := cands[0]
+	switch p {
+	case LeastLoaded:
+		for _, mb := range cands[1:] {
+			if mb.inflight < sel.inflight ||
+				(mb.inflight == sel.inflight && mb.sent < sel.sent) {
+				sel = mb
+			}
+		}
+	case Quota:
+		for _, mb := range cands[1:] {
+			if remaining(mb) > remaining(sel) {
+				sel = mb
+			}
+		}
+	}
+	return sel
+}
+
+// remaining returns the quota remaining for the member, which is the maximum
+// int if unlimited.
+func remaining(mb *member) int {
+	if mb.quota == 0 {
+		return int(^uint(0) >> 1)
+	}
+	return mb.quota - mb.sent - mb.inflight
+}
@@ -0,0 +1,286 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package pool provides a pool of modems that shares outbound messages
+// between them.
+//
+// Each message is sent by a single modem, selected by the pool policy, so the
+// segments of a concatenated message are kept together. Modems that
+// repeatedly fail are taken out of rotation.
+package pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/modem"
+)
+
+// Pool shares outbound messages between a set of modems.
+//
+// A Pool is safe for concurrent use.
+type Pool struct {
+	policy    Policy
+	maxErrors int
+	recovery  time.Duration
+
+	mu      sync.Mutex // covers the fields below
+	members []*member
+	next    int // the next member for round robin
+}
+
+// member is a modem in the pool.
+type member struct {
+	id    string
+	m     *modem.Modem
+	quota int
+
+	// mr generates the TP-MR for TPDUs sent by this member.
+	mr *sms.Counter
+
+	// the fields below are covered by the Pool mu
+	inflight int
+	sent     int
+	errors   int
+	downAt   time.Time
+	down     bool
+}
+
+// Stats describes the state of a modem in the pool.
+type Stats struct {
+	// ID identifies the modem.
+	ID string
+
+	// Sent is the number of TPDUs sent by the modem, and counts against the
+	// quota.
+	Sent int
+
+	// InFlight is the number of TPDUs being sent by the modem.
+	InFlight int
+
+	// Errors is the number of consecutive send errors returned by the
+	// modem.
+	Errors int
+
+	// Quota is the number of TPDUs the modem may send, or zero if unlimited.
+	Quota int
+
+	// MR is the last TP-MR assigned to a TPDU sent by the modem.
+	MR int
+
+	// Healthy indicates the modem is in rotation.
+	Healthy bool
+}
+
+// New creates a Pool.
+//
+// Modems are added to the Pool using Add.
+func New(options ...Option) *Pool {
+	p := Pool{
+		policy:    RoundRobin,
+		maxErrors: 3,
+	}
+	for _, option := range options {
+		option(&p)
+	}
+	return &p
+}
+
+// Add adds a modem to the pool.
+//
+// The id identifies the modem, typically by its SIM, in Stats and errors.
+func (p *Pool) Add(id string, m *modem.Modem, options ...MemberOption) {
+	mb := member{id: id, m: m, mr: &sms.Counter{}}
+	for _, option := range options {
+		option(&mb)
+	}
+	p.mu.Lock()
+	p.members = append(p.members, &mb)
+	p.mu.Unlock()
+}
+
+// Restore returns a modem taken out of rotation to the pool.
+func (p *Pool) Restore(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, mb := range p.members {
+		if mb.id == id {
+			mb.down = false
+			mb.errors = 0
+		}
+	}
+}
+
+// ResetQuotas resets the count of TPDUs sent by each modem, e.g. at the start
+// of a new billing period.
+func (p *Pool) ResetQuotas() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, mb := range p.members {
+		mb.sent = 0
+	}
+}
+
+// Send sends the TPDUs of a message using a modem selected from the pool.
+//
+// The TPDUs are typically the output of an sms.Encoder, and are all sent by
+// the same modem, with the TP-MR of each assigned by the counter of the
+// modem.
+//
+// If the selected modem fails before sending any of the TPDUs then the send
+// is retried on another modem, unless the error is specific to the message.
+//
+// Returns the id of the modem that sent the TPDUs, and the TP-MRs returned by
+// that modem, as per modem.Modem.Send.
+func (p *Pool) Send(ctx context.Context, pdus ...tpdu.TPDU) (string, []int, error) {
+	tried := map[*member]bool{}
+	var lastErr error
+	for {
+		mb := p.acquire(len(pdus), tried)
+		if mb == nil {
+			if lastErr != nil {
+				return "", nil, lastErr
+			}
+			return "", nil, ErrNoModem
+		}
+		tried[mb] = true
+		tt := make([]tpdu.TPDU, len(pdus))
+		for i := range pdus {
+			tt[i] = pdus[i]
+			tt[i].MR = byte(mb.mr.Count())
+		}
+		mrs, err := mb.m.Send(ctx, tt...)
+		p.release(mb, len(pdus), len(mrs), err)
+		if err == nil || len(mrs) != 0 || !failover(err) {
+			return mb.id, mrs, err
+		}
+		lastErr = err
+	}
+}
+
+// Stats returns the state of the modems in the pool, in the order they were
+// added.
+func (p *Pool) Stats() []Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ss := make([]Stats, len(p.members))
+	for i, mb := range p.members {
+		ss[i] = Stats{
+			ID:       mb.id,
+			Sent:     mb.sent,
+			InFlight: mb.inflight,
+			Errors:   mb.errors,
+			Quota:    mb.quota,
+			MR:       mb.mr.Read() % 256,
+			Healthy:  p.healthy(mb, time.Now()),
+		}
+	}
+	return ss
+}
+
+// acquire selects a member to send n TPDUs, excluding those already tried.
+func (p *Pool) acquire(n int, tried map[*member]bool) *member {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var cands []*member
+	// candidates in round robin order, starting from next
+	for i := range p.members {
+		idx := (p.next + i) % len(p.members)
+		mb := p.members[idx]
+		if tried[mb] || !p.healthy(mb, now) {
+			continue
+		}
+		if mb.quota != 0 && mb.sent+mb.inflight+n > mb.quota {
+			continue
+		}
+		cands = append(cands, mb)
+	}
+	if len(cands) == 0 {
+		return nil
+	}
+	mb := p.policy.selectMember(cands)
+	for i, m := range p.members {
+		if m == mb {
+			p.next = i + 1
+		}
+	}
+	mb.inflight += n
+	return mb
+}
+
+// release updates the state of a member after a send.
+func (p *Pool) release(mb *member, n, sent int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	mb.inflight -= n
+	mb.sent += sent
+	switch {
+	case err == nil, messageError(err):
+		// the modem reached the network
+		mb.errors = 0
+	case errors.Is(err, modem.ErrClosed):
+		mb.errors++
+		mb.down = true
+		mb.downAt = time.Now()
+	case failover(err):
+		mb.errors++
+		if mb.errors >= p.maxErrors {
+			mb.down = true
+			mb.downAt = time.Now()
+		}
+	}
+}
+
+// healthy returns true if the member is in rotation, returning it to
+// rotation if the recovery period has expired.
+func (p *Pool) healthy(mb *member, now time.Time) bool {
+	if mb.down && p.recovery != 0 && now.Sub(mb.downAt) >= p.recovery {
+		mb.down = false
+		mb.errors = 0
+	}
+	return !mb.down
+}
+
+// failover returns true if a send that failed with the error may be retried
+// on another modem.
+//
+// Only failures of the modem itself, being closure, timeouts and ME +CMS
+// ERRORs other than invalid parameters, are retried.
+func failover(err error) bool {
+	if errors.Is(err, modem.ErrClosed) || errors.Is(err, modem.ErrTimeout) {
+		return true
+	}
+	var cms modem.CMSError
+	if errors.As(err, &cms) {
+		switch cms {
+		case modem.CMSInvalidPDUParameter, modem.CMSInvalidTextParameter:
+			return false
+		}
+		return cms >= modem.CMSMEFailure
+	}
+	return false
+}
+
+// messageError returns true if the error is an RP cause or TP-FCS returned by
+// the network, so is a failure of the message rather than of the modem.
+func messageError(err error) bool {
+	var cms modem.CMSError
+	if !errors.As(err, &cms) {
+		return false
+	}
+	if _, ok := cms.RPCause(); ok {
+		return true
+	}
+	_, ok := cms.FCS()
+	return ok
+}
+
+// ErrNoModem indicates there is no modem available in the pool to send the
+// message.
+var ErrNoModem = errors.New("pool: no modem available")
@@ -0,0 +1,286 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package pool_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/modem"
+	"github.com/warthog618/sms/modem/emulator"
+	"github.com/warthog618/sms/modem/pool"
+)
+
+// pipes is the TE side of a connection to an Emulator.
+type pipes struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+// newModem creates a Modem connected to an Emulator.
+func newModem(t *testing.T) (*modem.Modem, *emulator.Emulator) {
+	t.Helper()
+	e := emulator.New(emulator.WithSMSC("+61412345678"))
+	tr, ew := io.Pipe()
+	er, tw := io.Pipe()
+	go func() {
+		e.Serve(pipes{er, ew})
+		ew.Close()
+	}()
+	m := modem.New(pipes{tr, tw})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Nil(t, m.Init(ctx))
+	return m, e
+}
+
+// newPool creates a Pool of n modems, with ids "a", "b"...
+func newPool(t *testing.T, n int, options ...pool.Option) (*pool.Pool, []*modem.Modem, []*emulator.Emulator) {
+	t.Helper()
+	p := pool.New(options...)
+	var mm []*modem.Modem
+	var ee []*emulator.Emulator
+	for i := 0; i < n; i++ {
+		m, e := newModem(t)
+		p.Add(string(rune('a'+i)), m)
+		mm = append(mm, m)
+		ee = append(ee, e)
+	}
+	return p, mm, ee
+}
+
+func closeAll(mm []*modem.Modem) {
+	for _, m := range mm {
+		m.Close()
+	}
+}
+
+func encode(t *testing.T, msg string) []tpdu.TPDU {
+	t.Helper()
+	tpdus, err := sms.Encode([]byte(msg), sms.To("+61421234567"))
+	require.Nil(t, err)
+	return tpdus
+}
+
+const longMsg = "this is a very long message that does not fit in a single SMS message, at least it will if I keep adding more to it as 160 characters is more than you might think"
+
+func send(t *testing.T, p *pool.Pool, tpdus []tpdu.TPDU) (string, error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	id, mrs, err := p.Send(ctx, tpdus...)
+	if err == nil {
+		assert.Equal(t, len(tpdus), len(mrs))
+	}
+	return id, err
+}
+
+func TestRoundRobin(t *testing.T) {
+	p, mm, ee := newPool(t, 3)
+	defer closeAll(mm)
+	short := encode(t, "hello")
+	for _, x := range []string{"a", "b", "c", "a"} {
+		id, err := send(t, p, short)
+		require.Nil(t, err)
+		assert.Equal(t, x, id)
+	}
+	assert.Equal(t, 2, len(ee[0].Submitted()))
+	assert.Equal(t, 1, len(ee[1].Submitted()))
+	assert.Equal(t, 1, len(ee[2].Submitted()))
+
+	// all segments on the one modem
+	long := encode(t, longMsg)
+	require.Equal(t, 2, len(long))
+	id, err := send(t, p, long)
+	require.Nil(t, err)
+	assert.Equal(t, "b", id)
+	sub := ee[1].Submitted()
+	require.Equal(t, 3, len(sub))
+	msg, err := sms.Decode(sub[1:])
+	require.Nil(t, err)
+	assert.Equal(t, longMsg, string(msg))
+
+	// MR counted per modem
+	stats := p.Stats()
+	require.Equal(t, 3, len(stats))
+	assert.Equal(t, pool.Stats{ID: "a", Sent: 2, MR: 2, Healthy: true}, stats[0])
+	assert.Equal(t, pool.Stats{ID: "b", Sent: 3, MR: 3, Healthy: true}, stats[1])
+	assert.Equal(t, pool.Stats{ID: "c", Sent: 1, MR: 1, Healthy: true}, stats[2])
+}
+
+func TestLeastLoaded(t *testing.T) {
+	p, mm, _ := newPool(t, 3, pool.WithPolicy(pool.LeastLoaded))
+	defer closeAll(mm)
+	short := encode(t, "hello")
+	long := encode(t, longMsg)
+	patterns := []struct {
+		tpdus []tpdu.TPDU
+		id    string
+	}{
+		{long, "a"},
+		{short, "b"},
+		{short, "c"},
+		{short, "b"},
+		{short, "c"},
+		{short, "a"},
+	}
+	for _, x := range patterns {
+		id, err := send(t, p, x.tpdus)
+		require.Nil(t, err)
+		assert.Equal(t, x.id, id)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	p := pool.New(pool.WithPolicy(pool.Quota))
+	ma, ea := newModem(t)
+	defer ma.Close()
+	mb, eb := newModem(t)
+	defer mb.Close()
+	p.Add("a", ma, pool.WithQuota(2))
+	p.Add("b", mb, pool.WithQuota(3))
+	short := encode(t, "hello")
+	long := encode(t, longMsg)
+	patterns := []struct {
+		tpdus []tpdu.TPDU
+		id    string
+		err   error
+	}{
+		{short, "b", nil},
+		{long, "a", nil},
+		{long, "b", nil},
+		{short, "", pool.ErrNoModem},
+	}
+	for _, x := range patterns {
+		id, err := send(t, p, x.tpdus)
+		assert.Equal(t, x.err, err)
+		assert.Equal(t, x.id, id)
+	}
+	assert.Equal(t, 2, len(ea.Submitted()))
+	assert.Equal(t, 3, len(eb.Submitted()))
+
+	p.ResetQuotas()
+	id, err := send(t, p, short)
+	assert.Nil(t, err)
+	assert.Equal(t, "b", id)
+}
+
+func TestFailover(t *testing.T) {
+	p, mm, ee := newPool(t, 2, pool.WithMaxErrors(2))
+	defer closeAll(mm)
+	short := encode(t, "hello")
+	ee[0].Fail("+CMGS", 500)
+	ee[0].Fail("+CMGS", 500)
+
+	id, err := send(t, p, short)
+	require.Nil(t, err)
+	assert.Equal(t, "b", id)
+	assert.Equal(t, 1, p.Stats()[0].Errors)
+	assert.True(t, p.Stats()[0].Healthy)
+
+	id, err = send(t, p, short)
+	require.Nil(t, err)
+	assert.Equal(t, "b", id)
+	assert.Equal(t, 2, p.Stats()[0].Errors)
+	assert.False(t, p.Stats()[0].Healthy)
+
+	// out of rotation
+	id, err = send(t, p, short)
+	require.Nil(t, err)
+	assert.Equal(t, "b", id)
+	assert.Equal(t, 0, len(ee[0].Submitted()))
+
+	p.Restore("a")
+	assert.True(t, p.Stats()[0].Healthy)
+	id, err = send(t, p, short)
+	require.Nil(t, err)
+	assert.Equal(t, "a", id)
+	assert.Equal(t, 0, p.Stats()[0].Errors)
+
+	// errors specific to the message are not retried
+	ee[1].Fail("+CMGS", 304)
+	id, err = send(t, p, short)
+	assert.Equal(t, modem.CMSError(304), err)
+	assert.Equal(t, "b", id)
+
+	// no modem left to retry
+	ee[0].Fail("+CMGS", 500)
+	ee[1].Fail("+CMGS", 500)
+	id, err = send(t, p, short)
+	assert.Equal(t, modem.CMSError(500), err)
+	assert.Equal(t, "", id)
+
+	// closed modems leave rotation immediately
+	mm[0].Close()
+	id, err = send(t, p, short)
+	require.Nil(t, err)
+	assert.Equal(t, "b", id)
+	assert.False(t, p.Stats()[0].Healthy)
+}
+
+func TestMessageError(t *testing.T) {
+	p, mm, ee := newPool(t, 1, pool.WithMaxErrors(2))
+	defer closeAll(mm)
+	short := encode(t, "hello")
+	// the network rejecting the destination is not a failure of the modem
+	for i := 0; i < 3; i++ {
+		ee[0].Fail("+CMGS", int(modem.CMSUnassignedNumber))
+	}
+	for i := 0; i < 3; i++ {
+		id, err := send(t, p, short)
+		assert.Equal(t, modem.CMSUnassignedNumber, err)
+		assert.Equal(t, "a", id)
+		assert.Equal(t, 0, p.Stats()[0].Errors)
+		assert.True(t, p.Stats()[0].Healthy)
+	}
+
+	// while a response from the network shows the modem is working
+	ee[0].Fail("+CMGS", 500)
+	id, err := send(t, p, short)
+	assert.Equal(t, modem.CMSError(500), err)
+	assert.Equal(t, "", id)
+	assert.Equal(t, 1, p.Stats()[0].Errors)
+	ee[0].Fail("+CMGS", int(modem.CMSCallBarred))
+	id, err = send(t, p, short)
+	assert.Equal(t, modem.CMSCallBarred, err)
+	assert.Equal(t, "a", id)
+	assert.Equal(t, 0, p.Stats()[0].Errors)
+
+	id, err = send(t, p, short)
+	require.Nil(t, err)
+	assert.Equal(t, "a", id)
+	assert.True(t, p.Stats()[0].Healthy)
+}
+
+func TestRecovery(t *testing.T) {
+	p, mm, ee := newPool(t, 2, pool.WithMaxErrors(1), pool.WithRecovery(10*time.Millisecond))
+	defer closeAll(mm)
+	short := encode(t, "hello")
+	ee[0].Fail("+CMGS", 500)
+	id, err := send(t, p, short)
+	require.Nil(t, err)
+	assert.Equal(t, "b", id)
+	assert.False(t, p.Stats()[0].Healthy)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, p.Stats()[0].Healthy)
+	id, err = send(t, p, short)
+	require.Nil(t, err)
+	assert.Equal(t, "a", id)
+}
+
+func TestNoModem(t *testing.T) {
+	p := pool.New()
+	id, mrs, err := p.Send(context.Background(), encode(t, "hello")...)
+	assert.Equal(t, pool.ErrNoModem, err)
+	assert.Equal(t, "", id)
+	assert.Nil(t, mrs)
+}
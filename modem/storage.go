@@ -0,0 +1,318 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Message is a message held in the modem storage.
+//
+// A concatenated message is held as a set of TPDUs, each in its own storage
+// location, so a Message contains the TPDUs and their indices, in segment
+// order.
+type Message struct {
+	// Stat is the status of the first TPDU of the message.
+	Stat pdumode.Stat
+
+	// Indices are the locations of the TPDUs in the storage.
+	Indices []int
+
+	// TPDUs are the segments of the message.
+	//
+	// If the storage does not contain all the segments of a concatenated
+	// message then only the segments available are provided.
+	TPDUs []*tpdu.TPDU
+}
+
+// Storage describes a message storage area, as reported by AT+CPMS.
+type Storage struct {
+	// Name is the name of the storage, e.g. "SM" or "ME".
+	Name string
+
+	// Used is the number of messages held in the storage.
+	Used int
+
+	// Total is the number of messages the storage can hold.
+	Total int
+}
+
+// DeleteFlag selects the messages deleted by Delete, as per the <delflag> of
+// AT+CMGD.
+type DeleteFlag int
+
+const (
+	// DeleteIndex deletes the message at the index.
+	DeleteIndex DeleteFlag = iota
+
+	// DeleteRead deletes all read messages.
+	DeleteRead
+
+	// DeleteReadSent deletes all read and sent messages.
+	DeleteReadSent
+
+	// DeleteReadSentUnsent deletes all read, sent and unsent messages.
+	DeleteReadSentUnsent
+
+	// DeleteAll deletes all messages.
+	DeleteAll
+)
+
+// List returns the messages with the stat from the current read storage, using
+// AT+CMGL.
+//
+// The segments of concatenated messages are grouped into a single Message.
+// TPDUs that cannot be decoded are ignored.
+//
+// Note that listing received unread messages marks them as read.
+func (m *Modem) List(ctx context.Context, stat pdumode.Stat) ([]Message, error) {
+	info, err := m.Command(ctx, fmt.Sprintf("+CMGL=%d", stat))
+	if err != nil {
+		return nil, err
+	}
+	c := sms.NewCollector()
+	defer c.Close()
+	var msgs []Message
+	// the indices of the segments of incomplete messages, in collection order
+	groups := map[string][]int{}
+	var keys []string
+	segs := map[int]*tpdu.TPDU{}
+	stats := map[int]pdumode.Stat{}
+	for i := 0; i < len(info)-1; i++ {
+		if !strings.HasPrefix(info[i], "+CMGL:") {
+			continue
+		}
+		r, err := pdumode.ParseCMGL(info[i], info[i+1])
+		i++
+		if err != nil {
+			continue
+		}
+		t, err := decodeStored(r.Stat, r.PDU)
+		if err != nil {
+			continue
+		}
+		segs[r.Index] = t
+		stats[r.Index] = r.Stat
+		segments, _, _, ok := t.ConcatInfo()
+		k, err := sms.ConcatKey(*t)
+		if !ok || segments < 2 || err != nil {
+			msgs = append(msgs, Message{Stat: r.Stat, Indices: []int{r.Index}, TPDUs: []*tpdu.TPDU{t}})
+			continue
+		}
+		d, err := c.Collect(*t)
+		if err != nil {
+			// inconsistent or duplicate segment, so return it alone
+			msgs = append(msgs, Message{Stat: r.Stat, Indices: []int{r.Index}, TPDUs: []*tpdu.TPDU{t}})
+			continue
+		}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], r.Index)
+		if d == nil {
+			continue
+		}
+		msgs = append(msgs, message(groups[k], segs, stats))
+		delete(groups, k)
+	}
+	for _, k := range keys {
+		if idxs, ok := groups[k]; ok {
+			msgs = append(msgs, message(idxs, segs, stats))
+			delete(groups, k)
+		}
+	}
+	return msgs, nil
+}
+
+// Read returns the message at the index in the current read storage, using
+// AT+CMGR.
+//
+// Note that reading a received unread message marks it as read.
+func (m *Modem) Read(ctx context.Context, index int) (*Message, error) {
+	info, err := m.Command(ctx, fmt.Sprintf("+CMGR=%d", index))
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(info)-1; i++ {
+		if strings.HasPrefix(info[i], "+CMGR:") {
+			r, err := pdumode.ParseCMGR(info[i], info[i+1])
+			if err != nil {
+				return nil, err
+			}
+			t, err := decodeStored(r.Stat, r.PDU)
+			if err != nil {
+				return nil, err
+			}
+			return &Message{Stat: r.Stat, Indices: []int{index}, TPDUs: []*tpdu.TPDU{t}}, nil
+		}
+	}
+	return nil, ErrInvalidResponse(strings.Join(info, "\n"))
+}
+
+// Write writes the TPDU to the current write storage, using AT+CMGW, and
+// returns the index it is stored at.
+//
+// The stat should be one of the STO values for an SMS-SUBMIT, or one of the
+// REC values for an SMS-DELIVER.
+func (m *Modem) Write(ctx context.Context, t *tpdu.TPDU, stat pdumode.Stat) (int, error) {
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	p := pdumode.PDU{TPDU: b}
+	s, err := p.MarshalHexString()
+	if err != nil {
+		return 0, err
+	}
+	cmd := fmt.Sprintf("+CMGW=%d,%d", len(b), stat)
+	info, err := m.SMSCommand(ctx, cmd, strings.ToUpper(s))
+	if err != nil {
+		return 0, err
+	}
+	for _, l := range info {
+		if strings.HasPrefix(l, "+CMGW:") {
+			idx, err := strconv.Atoi(strings.TrimSpace(l[6:]))
+			if err != nil {
+				break
+			}
+			return idx, nil
+		}
+	}
+	return 0, ErrInvalidResponse(strings.Join(info, "\n"))
+}
+
+// Delete deletes messages from the current read storage, using AT+CMGD.
+//
+// The index is ignored by the modem unless the flag is DeleteIndex.
+func (m *Modem) Delete(ctx context.Context, index int, flag DeleteFlag) error {
+	cmd := fmt.Sprintf("+CMGD=%d", index)
+	if flag != DeleteIndex {
+		cmd += fmt.Sprintf(",%d", flag)
+	}
+	_, err := m.Command(ctx, cmd)
+	return err
+}
+
+// Storage returns the storage areas currently used for reading and deleting,
+// writing and sending, and receiving messages, using AT+CPMS.
+func (m *Modem) Storage(ctx context.Context) ([]Storage, error) {
+	info, err := m.Command(ctx, "+CPMS?")
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range info {
+		if !strings.HasPrefix(l, "+CPMS:") {
+			continue
+		}
+		f := strings.Split(l[6:], ",")
+		if len(f)%3 != 0 {
+			break
+		}
+		var ss []Storage
+		for i := 0; i < len(f); i += 3 {
+			s, err := parseStorage(f[i+1 : i+3])
+			if err != nil {
+				return nil, ErrInvalidResponse(l)
+			}
+			s.Name = strings.Trim(strings.TrimSpace(f[i]), "\"")
+			ss = append(ss, s)
+		}
+		return ss, nil
+	}
+	return nil, ErrInvalidResponse(strings.Join(info, "\n"))
+}
+
+// SelectStorage selects the storage areas used for reading and deleting,
+// writing and sending, and receiving messages, using AT+CPMS.
+//
+// One to three names may be provided, with the areas not named remaining
+// unchanged.
+//
+// Returns the usage of the selected storage areas, with the names of those not
+// named left empty.
+func (m *Modem) SelectStorage(ctx context.Context, names ...string) ([]Storage, error) {
+	q := make([]string, len(names))
+	for i, n := range names {
+		q[i] = strconv.Quote(n)
+	}
+	l := "+CPMS=" + strings.Join(q, ",")
+	info, err := m.Command(ctx, l)
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range info {
+		if !strings.HasPrefix(l, "+CPMS:") {
+			continue
+		}
+		f := strings.Split(l[6:], ",")
+		if len(f)%2 != 0 || len(f) < 2*len(names) {
+			break
+		}
+		var ss []Storage
+		for i := 0; i < len(f); i += 2 {
+			s, err := parseStorage(f[i : i+2])
+			if err != nil {
+				return nil, ErrInvalidResponse(l)
+			}
+			if i/2 < len(names) {
+				s.Name = names[i/2]
+			}
+			ss = append(ss, s)
+		}
+		return ss, nil
+	}
+	return nil, ErrInvalidResponse(strings.Join(info, "\n"))
+}
+
+// decodeStored decodes a TPDU read from storage.
+//
+// The direction of the TPDU is determined by the stat, with received messages
+// being MT and stored messages being MO.
+func decodeStored(stat pdumode.Stat, p *pdumode.PDU) (*tpdu.TPDU, error) {
+	t := tpdu.TPDU{Direction: tpdu.MT}
+	if stat == pdumode.StatStoUnsent || stat == pdumode.StatStoSent {
+		t.Direction = tpdu.MO
+	}
+	if err := t.UnmarshalBinary(p.TPDU); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// message builds a Message from the segments at the indices, ordering them
+// by sequence number.
+func message(idxs []int, segs map[int]*tpdu.TPDU, stats map[int]pdumode.Stat) Message {
+	sort.Slice(idxs, func(i, j int) bool {
+		_, si, _, _ := segs[idxs[i]].ConcatInfo()
+		_, sj, _, _ := segs[idxs[j]].ConcatInfo()
+		return si < sj
+	})
+	msg := Message{Stat: stats[idxs[0]], Indices: idxs}
+	for _, idx := range idxs {
+		msg.TPDUs = append(msg.TPDUs, segs[idx])
+	}
+	return msg
+}
+
+// parseStorage parses the <used>,<total> fields of a +CPMS response.
+func parseStorage(f []string) (Storage, error) {
+	used, err := strconv.Atoi(strings.TrimSpace(f[0]))
+	if err != nil {
+		return Storage{}, err
+	}
+	total, err := strconv.Atoi(strings.TrimSpace(f[1]))
+	if err != nil {
+		return Storage{}, err
+	}
+	return Storage{Used: used, Total: total}, nil
+}
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/modem"
+)
+
+// storedPDU returns the TPDU in PDU mode hex, without an SMSC address, and
+// the TPDU length.
+func storedPDU(t *testing.T, p *tpdu.TPDU) (string, int) {
+	t.Helper()
+	b, err := p.MarshalBinary()
+	require.Nil(t, err)
+	return "00" + strings.ToUpper(hex.EncodeToString(b)), len(b)
+}
+
+func TestList(t *testing.T) {
+	long := strings.Repeat("long message ", 20)
+	concat, err := sms.Encode([]byte(long), sms.AsDeliver, sms.From("+61421234567"))
+	require.Nil(t, err)
+	require.Equal(t, 2, len(concat))
+	orphan, err := sms.Encode([]byte(long+long), sms.AsDeliver, sms.From("+61421234567"))
+	require.Nil(t, err)
+	single, err := sms.Encode([]byte("hello"), sms.AsDeliver, sms.From("+61421234567"))
+	require.Nil(t, err)
+
+	var rsp strings.Builder
+	entry := func(idx int, stat pdumode.Stat, p *tpdu.TPDU) {
+		s, l := storedPDU(t, p)
+		fmt.Fprintf(&rsp, "\r\n+CMGL: %d,%d,,%d\r\n%s", idx, stat, l, s)
+	}
+	entry(1, pdumode.StatRecRead, &single[0])
+	entry(3, pdumode.StatRecRead, &concat[1])
+	rsp.WriteString("\r\n+CMGL: 4,1,,3\r\n00FFFFFF") // undecodable
+	entry(5, pdumode.StatRecUnread, &concat[0])
+	entry(7, pdumode.StatRecRead, &orphan[0])
+	rsp.WriteString("\r\n\r\nOK\r\n")
+
+	f := newFakeModem([]step{{"AT+CMGL=4\r", rsp.String()}})
+	defer f.close()
+	m := modem.New(f.rw())
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+	msgs, err := m.List(ctx, pdumode.StatAll)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(msgs))
+
+	assert.Equal(t, pdumode.StatRecRead, msgs[0].Stat)
+	assert.Equal(t, []int{1}, msgs[0].Indices)
+	require.Equal(t, 1, len(msgs[0].TPDUs))
+	assert.Equal(t, tpdu.UserData("hello"), msgs[0].TPDUs[0].UD)
+
+	assert.Equal(t, pdumode.StatRecUnread, msgs[1].Stat)
+	assert.Equal(t, []int{5, 3}, msgs[1].Indices)
+	d, err := sms.Decode(msgs[1].TPDUs)
+	require.Nil(t, err)
+	assert.Equal(t, long, string(d))
+
+	assert.Equal(t, []int{7}, msgs[2].Indices)
+	assert.False(t, sms.IsCompleteMessage(msgs[2].TPDUs))
+
+	// error
+	f = newFakeModem([]step{{"AT+CMGL=0\r", "\r\n+CMS ERROR: 321\r\n"}})
+	defer f.close()
+	m = modem.New(f.rw())
+	defer m.Close()
+	msgs, err = m.List(ctx, pdumode.StatRecUnread)
+	assert.Equal(t, modem.CMSInvalidMemoryIndex, err)
+	assert.Nil(t, msgs)
+}
+
+func TestRead(t *testing.T) {
+	submit, err := sms.Encode([]byte("hello"), sms.To("+61421234567"))
+	require.Nil(t, err)
+	s, l := storedPDU(t, &submit[0])
+	script := []step{
+		{"AT+CMGR=2\r", fmt.Sprintf("\r\n+CMGR: 2,,%d\r\n%s\r\n\r\nOK\r\n", l, s)},
+		{"AT+CMGR=3\r", "\r\n+CMS ERROR: 321\r\n"},
+		{"AT+CMGR=4\r", "\r\nOK\r\n"},
+		{"AT+CMGR=5\r", fmt.Sprintf("\r\n+CMGR: 2,,%d\r\n%s\r\n\r\nOK\r\n", l+1, s)},
+	}
+	f := newFakeModem(script)
+	defer f.close()
+	m := modem.New(f.rw())
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	msg, err := m.Read(ctx, 2)
+	require.Nil(t, err)
+	assert.Equal(t, pdumode.StatStoUnsent, msg.Stat)
+	assert.Equal(t, []int{2}, msg.Indices)
+	require.Equal(t, 1, len(msg.TPDUs))
+	assert.Equal(t, tpdu.SmsSubmit, msg.TPDUs[0].SmsType())
+	assert.Equal(t, tpdu.MO, msg.TPDUs[0].Direction)
+	assert.Equal(t, "61421234567", msg.TPDUs[0].DA.Addr)
+
+	_, err = m.Read(ctx, 3)
+	assert.Equal(t, modem.CMSInvalidMemoryIndex, err)
+	_, err = m.Read(ctx, 4)
+	assert.Equal(t, modem.ErrInvalidResponse(""), err)
+	_, err = m.Read(ctx, 5)
+	assert.Equal(t, pdumode.ErrLengthMismatch{Header: l + 1, TPDU: l}, err)
+}
+
+func TestWrite(t *testing.T) {
+	submit, err := sms.Encode([]byte("hello"), sms.To("+61421234567"))
+	require.Nil(t, err)
+	s, l := storedPDU(t, &submit[0])
+	cmgw := fmt.Sprintf("AT+CMGW=%d,2\r", l)
+	script := []step{
+		{cmgw, "\r\n> "},
+		{s + "\x1a", "\r\n+CMGW: 4\r\n\r\nOK\r\n"},
+		{cmgw, "\r\n+CMS ERROR: 322\r\n"},
+		{cmgw, "\r\n> "},
+		{s + "\x1a", "\r\nOK\r\n"},
+	}
+	f := newFakeModem(script)
+	defer f.close()
+	m := modem.New(f.rw())
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	idx, err := m.Write(ctx, &submit[0], pdumode.StatStoUnsent)
+	require.Nil(t, err)
+	assert.Equal(t, 4, idx)
+	_, err = m.Write(ctx, &submit[0], pdumode.StatStoUnsent)
+	assert.Equal(t, modem.CMSMemoryFull, err)
+	_, err = m.Write(ctx, &submit[0], pdumode.StatStoUnsent)
+	assert.Equal(t, modem.ErrInvalidResponse(""), err)
+}
+
+func TestDelete(t *testing.T) {
+	script := []step{
+		{"AT+CMGD=4\r", "\r\nOK\r\n"},
+		{"AT+CMGD=0,4\r", "\r\nOK\r\n"},
+		{"AT+CMGD=9\r", "\r\n+CMS ERROR: 321\r\n"},
+	}
+	f := newFakeModem(script)
+	defer f.close()
+	m := modem.New(f.rw())
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	assert.Nil(t, m.Delete(ctx, 4, modem.DeleteIndex))
+	assert.Nil(t, m.Delete(ctx, 0, modem.DeleteAll))
+	assert.Equal(t, modem.CMSInvalidMemoryIndex, m.Delete(ctx, 9, modem.DeleteIndex))
+}
+
+func TestStorage(t *testing.T) {
+	script := []step{
+		{"AT+CPMS?\r", "\r\n+CPMS: \"SM\",3,10,\"ME\",0,20,\"SM\",3,10\r\n\r\nOK\r\n"},
+		{"AT+CPMS?\r", "\r\n+CPMS: \"SM\",3\r\n\r\nOK\r\n"},
+		{"AT+CPMS=\"ME\"\r", "\r\n+CPMS: 0,20,0,20,3,10\r\n\r\nOK\r\n"},
+		{"AT+CPMS=\"ME\",\"SM\"\r", "\r\n+CPMS: 0,20,x,10,3,10\r\n\r\nOK\r\n"},
+		{"AT+CPMS=\"XX\"\r", "\r\n+CMS ERROR: 303\r\n"},
+	}
+	f := newFakeModem(script)
+	defer f.close()
+	m := modem.New(f.rw())
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	ss, err := m.Storage(ctx)
+	require.Nil(t, err)
+	assert.Equal(t, []modem.Storage{{"SM", 3, 10}, {"ME", 0, 20}, {"SM", 3, 10}}, ss)
+	_, err = m.Storage(ctx)
+	assert.Equal(t, modem.ErrInvalidResponse("+CPMS: \"SM\",3"), err)
+
+	ss, err = m.SelectStorage(ctx, "ME")
+	require.Nil(t, err)
+	assert.Equal(t, []modem.Storage{{"ME", 0, 20}, {"", 0, 20}, {"", 3, 10}}, ss)
+	_, err = m.SelectStorage(ctx, "ME", "SM")
+	assert.Equal(t, modem.ErrInvalidResponse("+CPMS: 0,20,x,10,3,10"), err)
+	_, err = m.SelectStorage(ctx, "XX")
+	assert.Equal(t, modem.CMSOperationNotSupported, err)
+}
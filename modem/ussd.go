@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem
+
+import (
+	"context"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/ussd"
+)
+
+// USSDSession is an interactive USSD session, such as a balance query or a
+// menu provided by the network operator.
+//
+// The network supports only one session at a time, so only one session may
+// be active on a Modem at a time.
+type USSDSession struct {
+	m *Modem
+
+	// results receives the +CUSD results while the session is active.
+	results chan *ussd.CUSD
+}
+
+// USSDResponse is a response from the network in a USSD session.
+type USSDResponse struct {
+	// Status indicates the state of the session.
+	//
+	// The session remains active only while the Status is
+	// ussd.StatusActionRequired.
+	Status ussd.Status
+
+	// Text is the decoded text of the response, if any.
+	Text string
+
+	// Language is the ISO 639 code of the language of the text, if known.
+	Language string
+}
+
+// NewUSSDSession creates a USSD session on the Modem.
+//
+// The session is not active until Start is called.
+func (m *Modem) NewUSSDSession() *USSDSession {
+	return &USSDSession{m: m, results: make(chan *ussd.CUSD, 1)}
+}
+
+// Start starts the session by sending the USSD code, e.g. "*100#", and
+// returns the first response from the network.
+//
+// The code, and replies, are sent using AT+CUSD with the string packed and
+// in hex form, as per ussd.CUSDCommand.
+//
+// If the context expires while waiting for the response then the session
+// remains active and should be cancelled using Cancel.
+func (s *USSDSession) Start(ctx context.Context, code string) (*USSDResponse, error) {
+	s.m.mu.Lock()
+	if s.m.ussd != nil && s.m.ussd != s {
+		s.m.mu.Unlock()
+		return nil, ErrUSSDBusy
+	}
+	s.m.ussd = s
+	s.m.mu.Unlock()
+	return s.send(ctx, code)
+}
+
+// Reply sends the text in reply to a response with status
+// ussd.StatusActionRequired, such as the selection from a menu, and returns
+// the next response from the network.
+func (s *USSDSession) Reply(ctx context.Context, text string) (*USSDResponse, error) {
+	if !s.Active() {
+		return nil, ErrUSSDInactive
+	}
+	return s.send(ctx, text)
+}
+
+// Cancel aborts the session using AT+CUSD=2.
+//
+// Cancelling a session that is not active has no effect.
+func (s *USSDSession) Cancel(ctx context.Context) error {
+	if !s.Active() {
+		return nil
+	}
+	s.end()
+	_, err := s.m.Command(ctx, strings.TrimPrefix(ussd.CancelCommand, "AT"))
+	return err
+}
+
+// Active indicates the session is awaiting a response, or a reply.
+func (s *USSDSession) Active() bool {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	return s.m.ussd == s
+}
+
+// send sends the text and waits for the response.
+func (s *USSDSession) send(ctx context.Context, text string) (*USSDResponse, error) {
+	str, err := ussd.Encode(text)
+	if err != nil {
+		return nil, err
+	}
+	// discard any stale result
+	select {
+	case <-s.results:
+	default:
+	}
+	cmd := strings.TrimPrefix(ussd.CUSDCommand(str), "AT")
+	if _, err = s.m.Command(ctx, cmd); err != nil {
+		s.end()
+		return nil, err
+	}
+	var r *ussd.CUSD
+	select {
+	case r = <-s.results:
+	case <-s.m.done:
+		s.end()
+		return nil, s.m.error()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if r.Status != ussd.StatusActionRequired {
+		s.end()
+	}
+	rsp := USSDResponse{Status: r.Status}
+	if r.String != nil {
+		msg, err := ussd.Decode(r.String)
+		if err != nil {
+			return &rsp, err
+		}
+		rsp.Text = msg.Text
+		rsp.Language = msg.Language
+	}
+	return &rsp, nil
+}
+
+// end deactivates the session.
+func (s *USSDSession) end() {
+	s.m.mu.Lock()
+	if s.m.ussd == s {
+		s.m.ussd = nil
+	}
+	s.m.mu.Unlock()
+}
+
+// cusd passes a +CUSD result to the active USSD session.
+//
+// Results that cannot be parsed, or that arrive when no session is active,
+// are discarded.
+func (m *Modem) cusd(l string) {
+	r, err := ussd.ParseCUSD(l)
+	if err != nil {
+		return
+	}
+	m.mu.Lock()
+	s := m.ussd
+	m.mu.Unlock()
+	if s == nil {
+		return
+	}
+	select {
+	case s.results <- r:
+	default:
+	}
+}
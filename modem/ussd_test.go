@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package modem_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/ussd"
+	"github.com/warthog618/sms/modem"
+)
+
+// cusd returns the +CUSD result containing the text.
+func cusd(t *testing.T, status ussd.Status, text string) string {
+	t.Helper()
+	s, err := ussd.Encode(text)
+	require.Nil(t, err)
+	return fmt.Sprintf("\r\n+CUSD: %d,\"%s\",%d\r\n", int(status), s.MarshalHexString(), int(s.DCS))
+}
+
+func TestUSSDSession(t *testing.T) {
+	menu := "1. Balance\n2. Data"
+	f := newFakeModem([]step{
+		{"AT+CUSD=1,\"AA180C3602\",15\r", "\r\nOK\r\n" + cusd(t, ussd.StatusActionRequired, menu)},
+		{"AT+CUSD=1,\"31\",15\r", "\r\nOK\r\n" + cusd(t, ussd.StatusDone, "Balance $5.00")},
+	})
+	defer f.close()
+	m := modem.New(f.rw())
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	s := m.NewUSSDSession()
+	assert.False(t, s.Active())
+	r, err := s.Start(ctx, "*100#")
+	require.Nil(t, err)
+	assert.Equal(t, &modem.USSDResponse{Status: ussd.StatusActionRequired, Text: menu}, r)
+	assert.True(t, s.Active())
+
+	r, err = s.Reply(ctx, "1")
+	require.Nil(t, err)
+	assert.Equal(t, &modem.USSDResponse{Status: ussd.StatusDone, Text: "Balance $5.00"}, r)
+	assert.False(t, s.Active())
+
+	r, err = s.Reply(ctx, "1")
+	assert.Equal(t, modem.ErrUSSDInactive, err)
+	assert.Nil(t, r)
+}
+
+func TestUSSDStatus(t *testing.T) {
+	patterns := []struct {
+		name string
+		tx   string
+		out  *modem.USSDResponse
+	}{
+		{"terminated", "\r\nOK\r\n\r\n+CUSD: 2\r\n",
+			&modem.USSDResponse{Status: ussd.StatusTerminated}},
+		{"other client", "\r\nOK\r\n\r\n+CUSD: 3\r\n",
+			&modem.USSDResponse{Status: ussd.StatusOtherClient}},
+		{"not supported", "\r\nOK\r\n\r\n+CUSD: 4\r\n",
+			&modem.USSDResponse{Status: ussd.StatusNotSupported}},
+		{"timeout", "\r\nOK\r\n\r\n+CUSD: 5\r\n",
+			&modem.USSDResponse{Status: ussd.StatusTimeout}},
+		{"ucs2", "\r\nOK\r\n\r\n+CUSD: 2,\"00480069\",72\r\n",
+			&modem.USSDResponse{Status: ussd.StatusTerminated, Text: "Hi"}},
+		{"language", "\r\nOK\r\n\r\n+CUSD: 0,\"C8329BFD06\",1\r\n",
+			&modem.USSDResponse{Status: ussd.StatusDone, Text: "Hello", Language: "en"}},
+		{"before ok", "\r\n+CUSD: 4\r\n\r\nOK\r\n",
+			&modem.USSDResponse{Status: ussd.StatusNotSupported}},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			f := newFakeModem([]step{{"AT+CUSD=1,\"AA180C3602\",15\r", p.tx}})
+			defer f.close()
+			m := modem.New(f.rw())
+			defer m.Close()
+			ctx, cancel := testContext()
+			defer cancel()
+			s := m.NewUSSDSession()
+			r, err := s.Start(ctx, "*100#")
+			require.Nil(t, err)
+			assert.Equal(t, p.out, r)
+			assert.False(t, s.Active())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUSSDCancel(t *testing.T) {
+	f := newFakeModem([]step{
+		{"AT+CUSD=1,\"AA180C3602\",15\r", "\r\nOK\r\n" + cusd(t, ussd.StatusActionRequired, "1. Balance")},
+		{"AT+CUSD=2\r", "\r\nOK\r\n"},
+	})
+	defer f.close()
+	m := modem.New(f.rw())
+	defer m.Close()
+	ctx, cancel := testContext()
+	defer cancel()
+
+	s := m.NewUSSDSession()
+	_, err := s.Start(ctx, "*100#")
+	require.Nil(t, err)
+	assert.True(t, s.Active())
+
+	// only one session at a time
+	s2 := m.NewUSSDSession()
+	r, err := s2.Start(ctx, "*101#")
+	assert.Equal(t, modem.ErrUSSDBusy, err)
+	assert.Nil(t, r)
+
+	assert.Nil(t, s.Cancel(ctx))
+	assert.False(t, s.Active())
+
+	// no effect when inactive
+	assert.Nil(t, s.Cancel(ctx))
+	assert.Equal(t, []string{"AT+CUSD=1,\"AA180C3602\",15\r", "AT+CUSD=2\r"}, f.received())
+}
+
+func TestUSSDError(t *testing.T) {
+	f := newFakeModem([]step{
+		{"AT+CUSD=1,\"AA180C3602\",15\r", "\r\n+CME ERROR: 3\r\n"},
+		{"AT+CUSD=1,\"AA180C3602\",15\r", "\r\nOK\r\n"},
+	})
+	defer f.close()
+	m := modem.New(f.rw())
+	defer m.Close()
+
+	s := m.NewUSSDSession()
+	ctx, cancel := testContext()
+	defer cancel()
+	r, err := s.Start(ctx, "*100#")
+	assert.Equal(t, modem.CMEError(3), err)
+	assert.Nil(t, r)
+	assert.False(t, s.Active())
+
+	// no response from the network
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r, err = s.Start(ctx, "*100#")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Nil(t, r)
+	assert.True(t, s.Active())
+}
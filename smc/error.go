@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/warthog618/sms/encoding/cp"
+)
+
+// CauseError indicates a transaction was aborted with a CP-ERROR.
+type CauseError struct {
+	Cause cp.Cause
+
+	// Remote is set if the CP-ERROR was received from the peer, and clear
+	// if it was sent to the peer.
+	Remote bool
+}
+
+func (e CauseError) Error() string {
+	if e.Remote {
+		return fmt.Sprintf("smc: received CP-ERROR: %s", e.Cause)
+	}
+	return fmt.Sprintf("smc: sent CP-ERROR: %s", e.Cause)
+}
+
+// ErrInvalidState indicates the operation is not permitted in the current
+// state.
+type ErrInvalidState State
+
+func (e ErrInvalidState) Error() string {
+	return fmt.Sprintf("smc: invalid in state %s", State(e))
+}
+
+// ErrTimeout indicates TC1* expired after the final retransmission of a
+// CP-DATA.
+var ErrTimeout = errors.New("smc: timeout")
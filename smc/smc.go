@@ -0,0 +1,389 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package smc provides the Short Message Control entity, which implements
+// the SM-CP procedures of 3GPP TS 24.011 Section 5.
+//
+// An Entity handles a single CP transaction, being the transfer of an
+// RP-DATA in one direction and the RP-ACK or RP-ERROR in reply, each carried
+// in a CP-DATA and acknowledged with a CP-ACK. The MM connection is provided
+// by the caller as a function that sends the encoded CP messages.
+package smc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms/encoding/cp"
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Side identifies which end of the connection an Entity is on.
+type Side int
+
+const (
+	// MS is the mobile station side.
+	MS Side = iota
+
+	// Network is the network side.
+	Network
+)
+
+// State is the state of an Entity, as defined in 3GPP TS 24.011 Section 5.2.
+//
+// The MM connection pending states are not modelled, as the MM connection is
+// assumed to be available.
+type State int
+
+const (
+	// Idle indicates no transaction is in progress.
+	Idle State = iota
+
+	// WaitForCPAck indicates a CP-DATA has been sent and the CP-ACK is
+	// awaited.
+	WaitForCPAck
+
+	// MMConnectionEstablished indicates the CP-DATA exchange in one
+	// direction has completed and the reply is awaited.
+	MMConnectionEstablished
+)
+
+var stateNames = map[State]string{
+	Idle:                    "Idle",
+	WaitForCPAck:            "Wait for CP-ACK",
+	MMConnectionEstablished: "MM connection established",
+}
+
+func (s State) String() string {
+	if n, ok := stateNames[s]; ok {
+		return n
+	}
+	return "Unknown"
+}
+
+// Timer is a running timer, as returned by a TimerFunc.
+type Timer interface {
+	// Stop prevents the timer from firing.
+	Stop() bool
+}
+
+// TimerFunc starts a timer that calls f after the duration d.
+type TimerFunc func(d time.Duration, f func()) Timer
+
+// Entity is an SMC entity handling a single CP transaction.
+type Entity struct {
+	side    Side
+	ti      byte
+	send    func([]byte) error
+	tc1     time.Duration
+	maxRetx int
+	timer   TimerFunc
+	release func(error)
+
+	mu sync.Mutex // covers the fields below
+	// originator is set if this side sent the initial CP-DATA.
+	originator bool
+	state      State
+	// pending is the most recently sent CP-DATA, retained for retransmission.
+	pending []byte
+	retx    int
+	tc1t    Timer
+	// gen identifies the current run of TC1*, so stale expiries are ignored.
+	gen int
+}
+
+// Option alters the behaviour of an Entity.
+type Option func(*Entity)
+
+// WithTC1 sets the duration of the TC1* retransmission timer.
+//
+// The default is 20 seconds.
+func WithTC1(d time.Duration) Option {
+	return func(e *Entity) {
+		e.tc1 = d
+	}
+}
+
+// WithMaxRetransmissions sets the number of times a CP-DATA is retransmitted
+// on TC1* expiry before the transaction is aborted.
+//
+// The default is 2.
+func WithMaxRetransmissions(n int) Option {
+	return func(e *Entity) {
+		e.maxRetx = n
+	}
+}
+
+// WithTimer sets the function used to start TC1*.
+//
+// This is intended for driving timer expiry deterministically in tests.
+// The default uses time.AfterFunc.
+func WithTimer(t TimerFunc) Option {
+	return func(e *Entity) {
+		e.timer = t
+	}
+}
+
+// WithReleaseHandler sets a handler called when the transaction completes
+// and the entity returns to Idle.
+//
+// The error is nil for a successful transaction, ErrTimeout if TC1* expired
+// on the final retransmission, or a CauseError if a CP-ERROR was sent or
+// received.
+func WithReleaseHandler(h func(error)) Option {
+	return func(e *Entity) {
+		e.release = h
+	}
+}
+
+// New creates an Entity on the given side for the transaction identifier ti.
+//
+// The send function is called to transmit encoded CP messages over the MM
+// connection. It is called with internal locks held, so must not call back
+// into the Entity.
+func New(side Side, ti byte, send func([]byte) error, options ...Option) *Entity {
+	e := Entity{
+		side:    side,
+		ti:      ti,
+		send:    send,
+		tc1:     20 * time.Second,
+		maxRetx: 2,
+		timer: func(d time.Duration, f func()) Timer {
+			return time.AfterFunc(d, f)
+		},
+	}
+	for _, option := range options {
+		option(&e)
+	}
+	return &e
+}
+
+// State returns the current state of the entity.
+func (e *Entity) State() State {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.state
+}
+
+// MO indicates the current or most recent transaction is mobile originated,
+// i.e. it was originated by the MS side.
+func (e *Entity) MO() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.originator == (e.side == MS)
+}
+
+// Send sends an RP message in a CP-DATA and starts TC1*.
+//
+// When Idle this originates a transaction, and the message should be an
+// RP-DATA or RP-SMMA. Once an RP-DATA has been received this sends the
+// reply, which should be an RP-ACK or RP-ERROR.
+func (e *Entity) Send(m *rp.Message) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	originator := e.originator
+	switch e.state {
+	case Idle:
+		originator = true
+	case MMConnectionEstablished:
+		if e.originator {
+			return ErrInvalidState(e.state)
+		}
+	default:
+		return ErrInvalidState(e.state)
+	}
+	b, err := e.marshal(&cp.Message{Type: cp.Data, UD: m}, originator)
+	if err != nil {
+		return err
+	}
+	if err = e.send(b); err != nil {
+		return err
+	}
+	e.originator = originator
+	e.pending = b
+	e.retx = 0
+	e.state = WaitForCPAck
+	e.startTC1()
+	return nil
+}
+
+// Receive handles an encoded CP message received from the peer.
+//
+// If the message is a CP-DATA then it is acknowledged with a CP-ACK, and
+// the RP message it carries is returned for the SM-RL.
+//
+// If the message is a CP-ERROR then the transaction is released, and a
+// CauseError is returned.
+//
+// If the message is invalid, or is not expected in the current state, then
+// a CP-ERROR is sent to the peer, the transaction is released, and a
+// CauseError is returned.
+func (e *Entity) Receive(b []byte) (*rp.Message, error) {
+	e.mu.Lock()
+	m, rel, err := e.receive(b)
+	e.mu.Unlock()
+	rel()
+	return m, err
+}
+
+// Abort sends a CP-ERROR with the given cause and releases the transaction.
+func (e *Entity) Abort(c cp.Cause) error {
+	e.mu.Lock()
+	if e.state == Idle {
+		e.mu.Unlock()
+		return ErrInvalidState(Idle)
+	}
+	err := e.sendError(c)
+	rel := e.releaseLocked(CauseError{Cause: c})
+	e.mu.Unlock()
+	rel()
+	return err
+}
+
+func (e *Entity) receive(b []byte) (*rp.Message, func(), error) {
+	var m cp.Message
+	if err := m.UnmarshalBinary(b); err != nil {
+		return e.reject(decodeCause(err))
+	}
+	if m.TI != e.ti {
+		// not this transaction, so reply without disturbing it.
+		e.send(mustMarshal(&cp.Message{TI: m.TI, TIFlag: !m.TIFlag, Type: cp.Error, Cause: cp.CauseInvalidTI}))
+		return nil, func() {}, CauseError{Cause: cp.CauseInvalidTI}
+	}
+	// messages from the originator have the TI flag clear.
+	if m.TIFlag != (e.originator && e.state != Idle) {
+		return e.reject(cp.CauseInvalidTI)
+	}
+	switch m.Type {
+	case cp.Data:
+		switch e.state {
+		case Idle:
+			e.originator = false
+		case WaitForCPAck:
+			if !e.originator {
+				return e.reject(cp.CauseMessageNotCompatible)
+			}
+			// the CP-DATA is an implicit CP-ACK of the RP-DATA.
+			e.stopTC1()
+		case MMConnectionEstablished:
+			if !e.originator {
+				return e.reject(cp.CauseMessageNotCompatible)
+			}
+		}
+		b, _ := e.marshal(&cp.Message{Type: cp.Ack}, e.originator)
+		if err := e.send(b); err != nil {
+			return nil, e.releaseLocked(err), err
+		}
+		if e.originator {
+			// the reply has been received, so the transaction is complete.
+			return m.UD, e.releaseLocked(nil), nil
+		}
+		e.state = MMConnectionEstablished
+		return m.UD, func() {}, nil
+	case cp.Ack:
+		if e.state != WaitForCPAck {
+			return e.reject(cp.CauseMessageNotCompatible)
+		}
+		e.stopTC1()
+		if e.originator {
+			e.state = MMConnectionEstablished
+			return nil, func() {}, nil
+		}
+		return nil, e.releaseLocked(nil), nil
+	default: // cp.Error
+		err := CauseError{Cause: m.Cause, Remote: true}
+		if e.state == Idle {
+			return nil, func() {}, err
+		}
+		return nil, e.releaseLocked(err), err
+	}
+}
+
+// reject sends a CP-ERROR with the given cause and releases the transaction.
+func (e *Entity) reject(c cp.Cause) (*rp.Message, func(), error) {
+	err := CauseError{Cause: c}
+	e.sendError(c)
+	if e.state == Idle {
+		return nil, func() {}, err
+	}
+	return nil, e.releaseLocked(err), err
+}
+
+func (e *Entity) sendError(c cp.Cause) error {
+	b, _ := e.marshal(&cp.Message{Type: cp.Error, Cause: c}, e.originator)
+	return e.send(b)
+}
+
+func (e *Entity) marshal(m *cp.Message, originator bool) ([]byte, error) {
+	m.TI = e.ti
+	m.TIFlag = !originator
+	return m.MarshalBinary()
+}
+
+// startTC1 starts a new run of TC1*.
+func (e *Entity) startTC1() {
+	e.gen++
+	gen := e.gen
+	e.tc1t = e.timer(e.tc1, func() { e.expireTC1(gen) })
+}
+
+func (e *Entity) stopTC1() {
+	e.gen++
+	if e.tc1t != nil {
+		e.tc1t.Stop()
+		e.tc1t = nil
+	}
+}
+
+// expireTC1 handles the expiry of TC1*, retransmitting the CP-DATA or
+// releasing the transaction if the retransmissions are exhausted.
+func (e *Entity) expireTC1(gen int) {
+	e.mu.Lock()
+	if gen != e.gen || e.state != WaitForCPAck {
+		e.mu.Unlock()
+		return
+	}
+	rel := func() {}
+	if e.retx >= e.maxRetx {
+		rel = e.releaseLocked(ErrTimeout)
+	} else if err := e.send(e.pending); err != nil {
+		rel = e.releaseLocked(err)
+	} else {
+		e.retx++
+		e.startTC1()
+	}
+	e.mu.Unlock()
+	rel()
+}
+
+// releaseLocked returns the entity to Idle and returns a function that
+// reports the release to the handler, to be called once the lock is
+// released.
+func (e *Entity) releaseLocked(err error) func() {
+	e.stopTC1()
+	e.state = Idle
+	e.pending = nil
+	h := e.release
+	if h == nil {
+		return func() {}
+	}
+	return func() { h(err) }
+}
+
+// decodeCause returns the CP-Cause reported to the peer for a message that
+// failed to decode.
+func decodeCause(err error) cp.Cause {
+	if de, ok := err.(tpdu.DecodeError); ok {
+		if _, ok := de.Err.(cp.ErrUnsupportedMessageType); ok {
+			return cp.CauseMessageTypeNonExistent
+		}
+	}
+	return cp.CauseInvalidMandatoryInformation
+}
+
+func mustMarshal(m *cp.Message) []byte {
+	b, _ := m.MarshalBinary()
+	return b
+}
@@ -0,0 +1,330 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smc_test
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/cp"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smc"
+)
+
+// timers is a manually fired source of timers.
+type timers struct {
+	running []*timer
+}
+
+type timer struct {
+	d       time.Duration
+	f       func()
+	stopped bool
+}
+
+func (t *timer) Stop() bool {
+	s := t.stopped
+	t.stopped = true
+	return !s
+}
+
+func (ts *timers) AfterFunc(d time.Duration, f func()) smc.Timer {
+	t := &timer{d: d, f: f}
+	ts.running = append(ts.running, t)
+	return t
+}
+
+// Fire expires the most recently started timer, even if stopped.
+func (ts *timers) Fire() {
+	t := ts.running[len(ts.running)-1]
+	t.f()
+}
+
+// link records the CP messages sent by an entity.
+type link struct {
+	sent [][]byte
+}
+
+func (l *link) Send(b []byte) error {
+	l.sent = append(l.sent, b)
+	return nil
+}
+
+// Last returns the most recently sent message.
+func (l *link) Last() []byte {
+	if len(l.sent) == 0 {
+		return nil
+	}
+	return l.sent[len(l.sent)-1]
+}
+
+type releases struct {
+	errs []error
+}
+
+func (r *releases) Handle(err error) {
+	r.errs = append(r.errs, err)
+}
+
+var smsc = pdumode.SMSCAddress{
+	Address: tpdu.Address{Addr: "639170000293", TOA: 0x91},
+}
+
+func rpData(t *testing.T) *rp.Message {
+	t.Helper()
+	b, _ := hex.DecodeString("0142049136190000" + "05E8329BFD06")
+	s := tpdu.TPDU{Direction: tpdu.MO}
+	err := s.UnmarshalBinary(b)
+	require.Nil(t, err)
+	return &rp.Message{Type: rp.DataMSToN, MR: 0x2a, DA: smsc, UD: &s}
+}
+
+func decode(t *testing.T, b []byte) *cp.Message {
+	t.Helper()
+	var m cp.Message
+	err := m.UnmarshalBinary(b)
+	require.Nil(t, err)
+	return &m
+}
+
+func TestMO(t *testing.T) {
+	var msl, nl link
+	var msr, nr releases
+	ms := smc.New(smc.MS, 3, msl.Send, smc.WithReleaseHandler(msr.Handle))
+	n := smc.New(smc.Network, 3, nl.Send, smc.WithReleaseHandler(nr.Handle))
+
+	// RP-DATA MS->N
+	req := rpData(t)
+	err := ms.Send(req)
+	require.Nil(t, err)
+	assert.Equal(t, smc.WaitForCPAck, ms.State())
+	assert.True(t, ms.MO())
+	assert.Equal(t, &cp.Message{TI: 3, Type: cp.Data, UD: req}, decode(t, msl.Last()))
+
+	m, err := n.Receive(msl.Last())
+	require.Nil(t, err)
+	assert.Equal(t, req, m)
+	assert.Equal(t, smc.MMConnectionEstablished, n.State())
+	assert.True(t, n.MO())
+	assert.Equal(t, &cp.Message{TI: 3, TIFlag: true, Type: cp.Ack}, decode(t, nl.Last()))
+
+	m, err = ms.Receive(nl.Last())
+	require.Nil(t, err)
+	assert.Nil(t, m)
+	assert.Equal(t, smc.MMConnectionEstablished, ms.State())
+
+	// RP-ACK N->MS
+	ack := &rp.Message{Type: rp.AckNToMS, MR: 0x2a}
+	err = n.Send(ack)
+	require.Nil(t, err)
+	assert.Equal(t, smc.WaitForCPAck, n.State())
+
+	m, err = ms.Receive(nl.Last())
+	require.Nil(t, err)
+	assert.Equal(t, ack, m)
+	assert.Equal(t, smc.Idle, ms.State())
+	assert.Equal(t, []error{nil}, msr.errs)
+	assert.Equal(t, &cp.Message{TI: 3, Type: cp.Ack}, decode(t, msl.Last()))
+
+	m, err = n.Receive(msl.Last())
+	require.Nil(t, err)
+	assert.Nil(t, m)
+	assert.Equal(t, smc.Idle, n.State())
+	assert.Equal(t, []error{nil}, nr.errs)
+}
+
+func TestMT(t *testing.T) {
+	var msl, nl link
+	ms := smc.New(smc.MS, 0, msl.Send)
+	n := smc.New(smc.Network, 0, nl.Send)
+
+	err := n.Send(&rp.Message{Type: rp.DataNToMS, OA: smsc, UD: &tpdu.TPDU{}})
+	require.Nil(t, err)
+	assert.False(t, n.MO())
+	_, err = ms.Receive(nl.Last())
+	require.Nil(t, err)
+	assert.False(t, ms.MO())
+	_, err = n.Receive(msl.Last())
+	require.Nil(t, err)
+
+	// a second reply is not permitted by the originator
+	err = n.Send(&rp.Message{Type: rp.AckNToMS})
+	assert.Equal(t, smc.ErrInvalidState(smc.MMConnectionEstablished), err)
+}
+
+func TestImplicitAck(t *testing.T) {
+	var msl, nl link
+	var ts timers
+	ms := smc.New(smc.MS, 1, msl.Send, smc.WithTimer(ts.AfterFunc))
+	n := smc.New(smc.Network, 1, nl.Send)
+
+	err := ms.Send(rpData(t))
+	require.Nil(t, err)
+	_, err = n.Receive(msl.Last())
+	require.Nil(t, err)
+	// CP-ACK lost, so the CP-DATA serves as the ack.
+	ack := &rp.Message{Type: rp.AckNToMS, MR: 0x2a}
+	err = n.Send(ack)
+	require.Nil(t, err)
+	m, err := ms.Receive(nl.Last())
+	require.Nil(t, err)
+	assert.Equal(t, ack, m)
+	assert.Equal(t, smc.Idle, ms.State())
+	assert.True(t, ts.running[0].stopped)
+}
+
+func TestRetransmission(t *testing.T) {
+	var l link
+	var ts timers
+	var r releases
+	e := smc.New(smc.MS, 0, l.Send,
+		smc.WithTimer(ts.AfterFunc),
+		smc.WithTC1(5*time.Second),
+		smc.WithMaxRetransmissions(2),
+		smc.WithReleaseHandler(r.Handle))
+
+	err := e.Send(rpData(t))
+	require.Nil(t, err)
+	require.Equal(t, 1, len(ts.running))
+	assert.Equal(t, 5*time.Second, ts.running[0].d)
+
+	ts.Fire()
+	assert.Equal(t, 2, len(l.sent))
+	assert.Equal(t, l.sent[0], l.sent[1])
+	ts.Fire()
+	assert.Equal(t, 3, len(l.sent))
+	assert.Equal(t, smc.WaitForCPAck, e.State())
+	assert.Nil(t, r.errs)
+
+	ts.Fire()
+	assert.Equal(t, 3, len(l.sent))
+	assert.Equal(t, smc.Idle, e.State())
+	assert.Equal(t, []error{smc.ErrTimeout}, r.errs)
+}
+
+func TestStaleTimer(t *testing.T) {
+	var l link
+	var ts timers
+	e := smc.New(smc.MS, 0, l.Send, smc.WithTimer(ts.AfterFunc))
+
+	err := e.Send(rpData(t))
+	require.Nil(t, err)
+	_, err = e.Receive([]byte{0x89, 0x04})
+	require.Nil(t, err)
+	assert.True(t, ts.running[0].stopped)
+
+	// expiry racing with the CP-ACK is ignored
+	ts.Fire()
+	assert.Equal(t, 1, len(l.sent))
+	assert.Equal(t, smc.MMConnectionEstablished, e.State())
+}
+
+func TestReceiveError(t *testing.T) {
+	var l link
+	var r releases
+	e := smc.New(smc.MS, 0, l.Send, smc.WithReleaseHandler(r.Handle))
+
+	err := e.Send(rpData(t))
+	require.Nil(t, err)
+	m, err := e.Receive([]byte{0x89, 0x10, 0x16})
+	expected := smc.CauseError{Cause: cp.CauseCongestion, Remote: true}
+	assert.Equal(t, expected, err)
+	assert.Nil(t, m)
+	assert.Equal(t, smc.Idle, e.State())
+	assert.Equal(t, []error{expected}, r.errs)
+	assert.Equal(t, 1, len(l.sent))
+}
+
+func TestReject(t *testing.T) {
+	patterns := []struct {
+		name  string
+		send  bool
+		in    []byte
+		err   error
+		reply []byte
+	}{
+		{
+			"ack when idle",
+			false,
+			[]byte{0x09, 0x04},
+			smc.CauseError{Cause: cp.CauseMessageNotCompatible},
+			[]byte{0x89, 0x10, 0x62},
+		},
+		{
+			"data from originator",
+			true,
+			[]byte{0x09, 0x01, 0x02, 0x03, 0x2a},
+			smc.CauseError{Cause: cp.CauseInvalidTI},
+			[]byte{0x09, 0x10, 0x51},
+		},
+		{
+			"other ti",
+			true,
+			[]byte{0xa9, 0x04},
+			smc.CauseError{Cause: cp.CauseInvalidTI},
+			[]byte{0x29, 0x10, 0x51},
+		},
+		{
+			"unknown type",
+			true,
+			[]byte{0x89, 0x02},
+			smc.CauseError{Cause: cp.CauseMessageTypeNonExistent},
+			[]byte{0x09, 0x10, 0x61},
+		},
+		{
+			"malformed",
+			true,
+			[]byte{0x89, 0x01},
+			smc.CauseError{Cause: cp.CauseInvalidMandatoryInformation},
+			[]byte{0x09, 0x10, 0x60},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			var l link
+			e := smc.New(smc.MS, 0, l.Send)
+			if p.send {
+				err := e.Send(rpData(t))
+				require.Nil(t, err)
+			}
+			m, err := e.Receive(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Nil(t, m)
+			assert.Equal(t, p.reply, l.Last())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestAbort(t *testing.T) {
+	var l link
+	var r releases
+	e := smc.New(smc.Network, 4, l.Send, smc.WithReleaseHandler(r.Handle))
+
+	err := e.Abort(cp.CauseNetworkFailure)
+	assert.Equal(t, smc.ErrInvalidState(smc.Idle), err)
+
+	_, err = e.Receive([]byte{0x49, 0x01, 0x02, 0x06, 0x2a})
+	require.Nil(t, err)
+	err = e.Abort(cp.CauseNetworkFailure)
+	require.Nil(t, err)
+	assert.Equal(t, []byte{0xc9, 0x10, 0x11}, l.Last())
+	assert.Equal(t, smc.Idle, e.State())
+	assert.Equal(t, []error{smc.CauseError{Cause: cp.CauseNetworkFailure}}, r.errs)
+}
+
+func TestSendInvalidState(t *testing.T) {
+	var l link
+	e := smc.New(smc.MS, 0, l.Send)
+	err := e.Send(rpData(t))
+	require.Nil(t, err)
+	err = e.Send(rpData(t))
+	assert.Equal(t, smc.ErrInvalidState(smc.WaitForCPAck), err)
+}
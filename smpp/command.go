@@ -0,0 +1,307 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp
+
+import "fmt"
+
+// CommandID identifies the type of SMPP PDU, as defined in SMPP v3.4 Section
+// 5.1.2.1.
+type CommandID uint32
+
+const (
+	// GenericNack is the generic_nack command.
+	GenericNack CommandID = 0x80000000
+
+	// BindReceiver is the bind_receiver command.
+	BindReceiver CommandID = 0x00000001
+
+	// BindReceiverResp is the bind_receiver_resp command.
+	BindReceiverResp CommandID = 0x80000001
+
+	// BindTransmitter is the bind_transmitter command.
+	BindTransmitter CommandID = 0x00000002
+
+	// BindTransmitterResp is the bind_transmitter_resp command.
+	BindTransmitterResp CommandID = 0x80000002
+
+	// QuerySM is the query_sm command.
+	QuerySM CommandID = 0x00000003
+
+	// QuerySMResp is the query_sm_resp command.
+	QuerySMResp CommandID = 0x80000003
+
+	// SubmitSM is the submit_sm command.
+	SubmitSM CommandID = 0x00000004
+
+	// SubmitSMResp is the submit_sm_resp command.
+	SubmitSMResp CommandID = 0x80000004
+
+	// DeliverSM is the deliver_sm command.
+	DeliverSM CommandID = 0x00000005
+
+	// DeliverSMResp is the deliver_sm_resp command.
+	DeliverSMResp CommandID = 0x80000005
+
+	// Unbind is the unbind command.
+	Unbind CommandID = 0x00000006
+
+	// UnbindResp is the unbind_resp command.
+	UnbindResp CommandID = 0x80000006
+
+	// ReplaceSM is the replace_sm command.
+	ReplaceSM CommandID = 0x00000007
+
+	// ReplaceSMResp is the replace_sm_resp command.
+	ReplaceSMResp CommandID = 0x80000007
+
+	// CancelSM is the cancel_sm command.
+	CancelSM CommandID = 0x00000008
+
+	// CancelSMResp is the cancel_sm_resp command.
+	CancelSMResp CommandID = 0x80000008
+
+	// BindTransceiver is the bind_transceiver command.
+	BindTransceiver CommandID = 0x00000009
+
+	// BindTransceiverResp is the bind_transceiver_resp command.
+	BindTransceiverResp CommandID = 0x80000009
+
+	// Outbind is the outbind command.
+	Outbind CommandID = 0x0000000b
+
+	// EnquireLink is the enquire_link command.
+	EnquireLink CommandID = 0x00000015
+
+	// EnquireLinkResp is the enquire_link_resp command.
+	EnquireLinkResp CommandID = 0x80000015
+
+	// SubmitMulti is the submit_multi command.
+	SubmitMulti CommandID = 0x00000021
+
+	// SubmitMultiResp is the submit_multi_resp command.
+	SubmitMultiResp CommandID = 0x80000021
+
+	// AlertNotification is the alert_notification command.
+	AlertNotification CommandID = 0x00000102
+
+	// DataSM is the data_sm command.
+	DataSM CommandID = 0x00000103
+
+	// DataSMResp is the data_sm_resp command.
+	DataSMResp CommandID = 0x80000103
+)
+
+const respMask CommandID = 0x80000000
+
+// IsResponse returns true if the command is a response.
+func (c CommandID) IsResponse() bool {
+	return c&respMask != 0
+}
+
+// Response returns the CommandID of the response corresponding to the
+// request.
+//
+// Commands that have no response, i.e. outbind and alert_notification, and
+// commands that are already responses, return GenericNack.
+func (c CommandID) Response() CommandID {
+	switch c {
+	case Outbind, AlertNotification:
+		return GenericNack
+	}
+	if c.IsResponse() {
+		return GenericNack
+	}
+	return c | respMask
+}
+
+var commandNames = map[CommandID]string{
+	GenericNack:         "generic_nack",
+	BindReceiver:        "bind_receiver",
+	BindReceiverResp:    "bind_receiver_resp",
+	BindTransmitter:     "bind_transmitter",
+	BindTransmitterResp: "bind_transmitter_resp",
+	QuerySM:             "query_sm",
+	QuerySMResp:         "query_sm_resp",
+	SubmitSM:            "submit_sm",
+	SubmitSMResp:        "submit_sm_resp",
+	DeliverSM:           "deliver_sm",
+	DeliverSMResp:       "deliver_sm_resp",
+	Unbind:              "unbind",
+	UnbindResp:          "unbind_resp",
+	ReplaceSM:           "replace_sm",
+	ReplaceSMResp:       "replace_sm_resp",
+	CancelSM:            "cancel_sm",
+	CancelSMResp:        "cancel_sm_resp",
+	BindTransceiver:     "bind_transceiver",
+	BindTransceiverResp: "bind_transceiver_resp",
+	Outbind:             "outbind",
+	EnquireLink:         "enquire_link",
+	EnquireLinkResp:     "enquire_link_resp",
+	SubmitMulti:         "submit_multi",
+	SubmitMultiResp:     "submit_multi_resp",
+	AlertNotification:   "alert_notification",
+	DataSM:              "data_sm",
+	DataSMResp:          "data_sm_resp",
+}
+
+func (c CommandID) String() string {
+	if n, ok := commandNames[c]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%08x", uint32(c))
+}
+
+// ESMClass represents the esm_class field, as defined in SMPP v3.4 Section
+// 5.2.12.
+type ESMClass byte
+
+// Mode returns the messaging mode bits of the esm_class.
+func (e ESMClass) Mode() ESMClass {
+	return e & EsmModeMask
+}
+
+// MessageType returns the message type bits of the esm_class.
+func (e ESMClass) MessageType() ESMClass {
+	return e & EsmTypeMask
+}
+
+// RP returns true if the reply path bit is set.
+func (e ESMClass) RP() bool {
+	return e&EsmReplyPath != 0
+}
+
+// UDHI returns true if the UDHI bit is set.
+func (e ESMClass) UDHI() bool {
+	return e&EsmUDHI != 0
+}
+
+const (
+	// esm_class bit fields
+
+	// EsmModeMask masks the messaging mode bits.
+	EsmModeMask ESMClass = 0x03
+
+	// EsmModeDefault selects the default SMSC mode.
+	EsmModeDefault ESMClass = 0x00
+
+	// EsmModeDatagram selects datagram mode.
+	EsmModeDatagram ESMClass = 0x01
+
+	// EsmModeForward selects forward (transaction) mode.
+	EsmModeForward ESMClass = 0x02
+
+	// EsmModeStoreAndForward selects store and forward mode.
+	EsmModeStoreAndForward ESMClass = 0x03
+
+	// EsmTypeMask masks the message type bits.
+	EsmTypeMask ESMClass = 0x3c
+
+	// EsmTypeDefault indicates a default message type, i.e. a normal
+	// message.
+	EsmTypeDefault ESMClass = 0x00
+
+	// EsmTypeDeliveryReceipt indicates the short message contains an SMSC
+	// delivery receipt.
+	EsmTypeDeliveryReceipt ESMClass = 0x04
+
+	// EsmTypeDeliveryAck indicates the short message contains an SME
+	// delivery acknowledgement.
+	EsmTypeDeliveryAck ESMClass = 0x08
+
+	// EsmTypeManualAck indicates the short message contains an SME manual
+	// or user acknowledgement.
+	EsmTypeManualAck ESMClass = 0x10
+
+	// EsmTypeConversationAbort indicates a conversation abort.
+	EsmTypeConversationAbort ESMClass = 0x18
+
+	// EsmTypeIntermediateNotification indicates the short message contains
+	// an intermediate delivery notification.
+	EsmTypeIntermediateNotification ESMClass = 0x20
+
+	// EsmUDHI indicates the short message begins with a User Data Header.
+	EsmUDHI ESMClass = 0x40
+
+	// EsmReplyPath indicates a reply path.
+	EsmReplyPath ESMClass = 0x80
+)
+
+const (
+	// registered_delivery bit fields
+
+	// RegDeliveryReceiptMask masks the SMSC delivery receipt bits.
+	RegDeliveryReceiptMask = 0x03
+
+	// RegDeliveryReceipt requests an SMSC delivery receipt on both success
+	// and failure.
+	RegDeliveryReceipt = 0x01
+
+	// RegDeliveryFailure requests an SMSC delivery receipt on failure only.
+	RegDeliveryFailure = 0x02
+
+	// RegDeliveryIntermediate requests intermediate notifications.
+	RegDeliveryIntermediate = 0x10
+)
+
+// MessageState represents the message_state field, as defined in SMPP v3.4
+// Section 5.2.28.
+type MessageState byte
+
+const (
+	// StateUnset indicates no message state is provided.
+	StateUnset MessageState = iota
+
+	// StateEnroute indicates the message is in enroute state.
+	StateEnroute
+
+	// StateDelivered indicates the message has been delivered.
+	StateDelivered
+
+	// StateExpired indicates the validity period has expired.
+	StateExpired
+
+	// StateDeleted indicates the message has been deleted.
+	StateDeleted
+
+	// StateUndeliverable indicates the message is undeliverable.
+	StateUndeliverable
+
+	// StateAccepted indicates the message is in accepted state, i.e. has
+	// been manually read on behalf of the subscriber by customer service.
+	StateAccepted
+
+	// StateUnknown indicates the message is in an invalid state.
+	StateUnknown
+
+	// StateRejected indicates the message is in a rejected state.
+	StateRejected
+)
+
+func (s MessageState) String() string {
+	switch s {
+	case StateEnroute:
+		return "ENROUTE"
+	case StateDelivered:
+		return "DELIVERED"
+	case StateExpired:
+		return "EXPIRED"
+	case StateDeleted:
+		return "DELETED"
+	case StateUndeliverable:
+		return "UNDELIVERABLE"
+	case StateAccepted:
+		return "ACCEPTED"
+	case StateUnknown:
+		return "UNKNOWN"
+	case StateRejected:
+		return "REJECTED"
+	default:
+		return fmt.Sprintf("0x%02x", byte(s))
+	}
+}
+
+// InterfaceVersion is the SMPP interface version implemented by this
+// package, for use in the bind interface_version field.
+const InterfaceVersion = 0x34
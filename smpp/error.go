@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedCommand indicates the command_id of the PDU is not supported.
+type ErrUnsupportedCommand CommandID
+
+func (e ErrUnsupportedCommand) Error() string {
+	return fmt.Sprintf("smpp: unsupported command: %s", CommandID(e))
+}
+
+// ErrInvalidLength indicates the command_length of a PDU is invalid.
+type ErrInvalidLength uint32
+
+func (e ErrInvalidLength) Error() string {
+	return fmt.Sprintf("smpp: invalid command length: %d", uint32(e))
+}
+
+// ErrUnsupportedDataCoding indicates the data_coding cannot be converted to
+// a TPDU DCS.
+type ErrUnsupportedDataCoding byte
+
+func (e ErrUnsupportedDataCoding) Error() string {
+	return fmt.Sprintf("smpp: unsupported data coding: 0x%02x", byte(e))
+}
+
+// ErrInvalidTime indicates a time string is not in the SMPP time format.
+type ErrInvalidTime string
+
+func (e ErrInvalidTime) Error() string {
+	return fmt.Sprintf("smpp: invalid time: '%s'", string(e))
+}
+
+var (
+	// ErrUnsupportedSmsType indicates the TPDU cannot be converted to or from
+	// an SMPP PDU.
+	ErrUnsupportedSmsType = errors.New("smpp: unsupported SMS type")
+)
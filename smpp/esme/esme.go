@@ -0,0 +1,584 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package esme provides an SMPP v3.4 External Short Message Entity client.
+//
+// The Client binds to an SMSC as a transmitter, receiver or transceiver,
+// keeps the link alive with enquire_link, and reconnects with backoff if the
+// link fails.
+package esme
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smpp"
+)
+
+// BindType identifies the type of bind performed by the Client.
+type BindType int
+
+const (
+	// Transceiver binds as both transmitter and receiver.
+	Transceiver BindType = iota
+
+	// Transmitter binds as a transmitter only.
+	Transmitter
+
+	// Receiver binds as a receiver only.
+	Receiver
+)
+
+func (b BindType) command() smpp.CommandID {
+	switch b {
+	case Transmitter:
+		return smpp.BindTransmitter
+	case Receiver:
+		return smpp.BindReceiver
+	default:
+		return smpp.BindTransceiver
+	}
+}
+
+// Dialer establishes the connection to the SMSC.
+//
+// The Client calls the Dialer each time it needs to (re)connect.
+type Dialer func(ctx context.Context) (net.Conn, error)
+
+// TCPDialer returns a Dialer that connects to the SMSC at the TCP address.
+func TCPDialer(addr string) Dialer {
+	return func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", addr)
+	}
+}
+
+// Client is an SMPP ESME client.
+//
+// A Client is safe for concurrent use.
+type Client struct {
+	dial          Dialer
+	bindType      BindType
+	systemID      string
+	password      string
+	systemType    string
+	addressRange  smpp.Address
+	window        int
+	enquirePeriod time.Duration
+	respTimeout   time.Duration
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	collector     *sms.Collector
+	ownCollector  bool
+	dh            func([]*tpdu.TPDU)
+	ph            func(*smpp.PDU)
+
+	// sequence number of the last request
+	seq uint32
+
+	// slots limits the number of outstanding requests
+	slots chan struct{}
+
+	// events queues calls to the handlers
+	events chan func()
+
+	ctx     context.Context
+	cancel  func()
+	stopped chan struct{}
+
+	mu sync.Mutex // covers the fields below
+	s  *session
+	// bound is closed when a session is bound, and is replaced when the
+	// session is lost.
+	bound  chan struct{}
+	closed bool
+}
+
+// New creates a Client that connects to an SMSC using the Dialer.
+//
+// The Client immediately starts connecting and binding to the SMSC, and
+// continues to do so until closed.
+func New(dial Dialer, options ...Option) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := Client{
+		dial:          dial,
+		window:        10,
+		enquirePeriod: 30 * time.Second,
+		respTimeout:   10 * time.Second,
+		minBackoff:    time.Second,
+		maxBackoff:    time.Minute,
+		events:        make(chan func(), 16),
+		ctx:           ctx,
+		cancel:        cancel,
+		stopped:       make(chan struct{}),
+		bound:         make(chan struct{}),
+		dh:            func([]*tpdu.TPDU) {},
+		ph:            func(*smpp.PDU) {},
+	}
+	for _, option := range options {
+		option(&c)
+	}
+	if c.window < 1 {
+		c.window = 1
+	}
+	c.slots = make(chan struct{}, c.window)
+	if c.collector == nil {
+		c.collector = sms.NewCollector()
+		c.ownCollector = true
+	}
+	go c.dispatch()
+	go c.run()
+	return &c
+}
+
+// Close unbinds from the SMSC and closes the connection.
+//
+// Any outstanding requests are aborted with ErrClosed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	s := c.s
+	c.mu.Unlock()
+	c.cancel()
+	if s != nil {
+		p := smpp.PDU{CommandID: smpp.Unbind, Sequence: c.nextSequence()}
+		ctx, cancel := context.WithTimeout(context.Background(), c.respTimeout)
+		c.request(ctx, s, &p)
+		cancel()
+		s.close(ErrClosed)
+	}
+	<-c.stopped
+	if c.ownCollector {
+		c.collector.Close()
+	}
+	return nil
+}
+
+// WaitBound blocks until the Client is bound to the SMSC, or the context is
+// done.
+func (c *Client) WaitBound(ctx context.Context) error {
+	_, err := c.session(ctx)
+	return err
+}
+
+// Send sends a request PDU to the SMSC and returns the response.
+//
+// The Sequence of the PDU is assigned by the Client.
+//
+// If the Client is not bound then Send blocks until it is, or the context is
+// done. If the window of outstanding requests is full then Send blocks until
+// a slot becomes available.
+//
+// If the response has a non-zero command_status then both the response and
+// the corresponding smpp.Status error are returned.
+func (c *Client) Send(ctx context.Context, p *smpp.PDU) (*smpp.PDU, error) {
+	s, err := c.session(ctx)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case c.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrClosed
+	}
+	defer func() { <-c.slots }()
+	p.Sequence = c.nextSequence()
+	r, err := c.request(ctx, s, p)
+	if err != nil && c.ctx.Err() != nil {
+		return nil, ErrClosed
+	}
+	return r, err
+}
+
+// Submit sends the TPDUs to the SMSC as a sequence of submit_sm.
+//
+// The TPDUs are typically the output of an sms.Encoder, and so may be the
+// segments of a concatenated message.
+//
+// Returns the message_id assigned by the SMSC for each TPDU submitted. In the
+// case of error the message_ids of the TPDUs successfully submitted before
+// the error are returned.
+func (c *Client) Submit(ctx context.Context, pdus ...tpdu.TPDU) ([]string, error) {
+	ids := make([]string, 0, len(pdus))
+	for i := range pdus {
+		p, err := smpp.FromTPDU(&pdus[i])
+		if err != nil {
+			return ids, err
+		}
+		r, err := c.Send(ctx, p)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, r.MessageID)
+	}
+	return ids, nil
+}
+
+func (c *Client) nextSequence() uint32 {
+	for {
+		// sequence numbers are restricted to 0x00000001-0x7fffffff
+		seq := atomic.AddUint32(&c.seq, 1) & 0x7fffffff
+		if seq != 0 {
+			return seq
+		}
+	}
+}
+
+// session returns the bound session, waiting for one if necessary.
+func (c *Client) session(ctx context.Context) (*session, error) {
+	for {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return nil, ErrClosed
+		}
+		s := c.s
+		bound := c.bound
+		c.mu.Unlock()
+		if s != nil {
+			return s, nil
+		}
+		select {
+		case <-bound:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.ctx.Done():
+			return nil, ErrClosed
+		}
+	}
+}
+
+// request sends the request on the session and waits for the response.
+func (c *Client) request(ctx context.Context, s *session, p *smpp.PDU) (*smpp.PDU, error) {
+	ch := make(chan *smpp.PDU, 1)
+	if err := s.register(p.Sequence, ch); err != nil {
+		return nil, err
+	}
+	defer s.unregister(p.Sequence)
+	if err := s.write(p); err != nil {
+		return nil, err
+	}
+	t := time.NewTimer(c.respTimeout)
+	defer t.Stop()
+	select {
+	case r := <-ch:
+		if r.Status != smpp.StatusOK {
+			return r, r.Status
+		}
+		return r, nil
+	case <-s.done:
+		return nil, s.error()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.C:
+		return nil, ErrTimeout
+	}
+}
+
+// run connects to the SMSC and maintains the session until the Client is
+// closed.
+func (c *Client) run() {
+	defer close(c.stopped)
+	backoff := c.minBackoff
+	for {
+		if s, err := c.connect(); err == nil {
+			backoff = c.minBackoff
+			c.serve(s)
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-c.ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+		backoff *= 2
+		if backoff > c.maxBackoff {
+			backoff = c.maxBackoff
+		}
+	}
+}
+
+// connect dials and binds to the SMSC.
+func (c *Client) connect() (*session, error) {
+	nc, err := c.dial(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := newSession(nc)
+	go c.read(s)
+	p := smpp.PDU{
+		CommandID:        c.bindType.command(),
+		Sequence:         c.nextSequence(),
+		SystemID:         c.systemID,
+		Password:         c.password,
+		SystemType:       c.systemType,
+		InterfaceVersion: smpp.InterfaceVersion,
+		AddressRange:     c.addressRange,
+	}
+	if _, err = c.request(c.ctx, s, &p); err != nil {
+		s.close(err)
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		s.close(ErrClosed)
+		return nil, ErrClosed
+	}
+	c.s = s
+	close(c.bound)
+	return s, nil
+}
+
+// serve keeps the session alive until it fails or the Client is closed.
+func (c *Client) serve(s *session) {
+	var tc <-chan time.Time
+	if c.enquirePeriod > 0 {
+		t := time.NewTicker(c.enquirePeriod)
+		defer t.Stop()
+		tc = t.C
+	}
+loop:
+	for {
+		select {
+		case <-s.done:
+			break loop
+		case <-c.ctx.Done():
+			// Close takes care of the unbind
+			return
+		case <-tc:
+			p := smpp.PDU{CommandID: smpp.EnquireLink, Sequence: c.nextSequence()}
+			if _, err := c.request(c.ctx, s, &p); err != nil {
+				s.close(err)
+			}
+		}
+	}
+	c.mu.Lock()
+	if c.s == s {
+		c.s = nil
+		c.bound = make(chan struct{})
+	}
+	c.mu.Unlock()
+}
+
+// read receives PDUs from the SMSC until the session fails.
+func (c *Client) read(s *session) {
+	for {
+		b, err := readFrame(s.nc)
+		if err != nil {
+			s.close(err)
+			return
+		}
+		p := smpp.PDU{}
+		if err = p.UnmarshalBinary(b); err != nil {
+			if de, ok := err.(tpdu.DecodeError); ok && de.Field == "command_id" {
+				s.nack(&p, smpp.StatusInvCmdID)
+			} else if !p.CommandID.IsResponse() {
+				s.write(p.Response(smpp.StatusInvMsgLen))
+			}
+			continue
+		}
+		if p.CommandID.IsResponse() {
+			s.respond(&p)
+			continue
+		}
+		c.handle(s, &p)
+	}
+}
+
+// handle processes a request from the SMSC.
+func (c *Client) handle(s *session, p *smpp.PDU) {
+	switch p.CommandID {
+	case smpp.EnquireLink:
+		s.write(p.Response(smpp.StatusOK))
+	case smpp.Unbind:
+		s.write(p.Response(smpp.StatusOK))
+		s.close(ErrUnbound)
+	case smpp.DeliverSM:
+		s.write(p.Response(c.deliver(p)))
+	case smpp.AlertNotification:
+		c.event(func() { c.ph(p) })
+	default:
+		s.nack(p, smpp.StatusInvCmdID)
+	}
+}
+
+// deliver passes a deliver_sm to the appropriate handler and returns the
+// status for the response.
+//
+// Short messages are reassembled before being passed to the deliver handler.
+// Anything else, such as delivery receipts, are passed to the PDU handler.
+func (c *Client) deliver(p *smpp.PDU) smpp.Status {
+	if p.ESMClass.MessageType() != smpp.EsmTypeDefault {
+		c.event(func() { c.ph(p) })
+		return smpp.StatusOK
+	}
+	t, err := p.TPDU()
+	if err != nil {
+		return smpp.StatusRxPAppn
+	}
+	segs, err := c.collector.Collect(*t)
+	if err != nil {
+		return smpp.StatusRxPAppn
+	}
+	if segs != nil {
+		c.event(func() { c.dh(segs) })
+	}
+	return smpp.StatusOK
+}
+
+// event queues a handler call for dispatch.
+func (c *Client) event(f func()) {
+	select {
+	case c.events <- f:
+	case <-c.ctx.Done():
+	}
+}
+
+// dispatch calls the handlers, in order, from a goroutine separate from the
+// reader so handlers may make requests of the Client.
+func (c *Client) dispatch() {
+	for {
+		select {
+		case f := <-c.events:
+			f()
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// readFrame reads the raw binary form of a single PDU from the reader.
+func readFrame(r io.Reader) ([]byte, error) {
+	h := make([]byte, smpp.HeaderLen)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, err
+	}
+	l := binary.BigEndian.Uint32(h)
+	if l < smpp.HeaderLen || l > smpp.MaxPDULen {
+		return nil, smpp.ErrInvalidLength(l)
+	}
+	b := make([]byte, l)
+	copy(b, h)
+	if _, err := io.ReadFull(r, b[smpp.HeaderLen:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// session is a single connection to the SMSC.
+type session struct {
+	nc   net.Conn
+	done chan struct{}
+
+	wmu sync.Mutex // serialises writes
+
+	mu      sync.Mutex // covers pending and err
+	pending map[uint32]chan *smpp.PDU
+	err     error
+}
+
+func newSession(nc net.Conn) *session {
+	return &session{
+		nc:      nc,
+		done:    make(chan struct{}),
+		pending: make(map[uint32]chan *smpp.PDU),
+	}
+}
+
+func (s *session) close(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	s.err = err
+	close(s.done)
+	s.nc.Close()
+}
+
+func (s *session) error() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *session) register(seq uint32, ch chan *smpp.PDU) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.pending[seq] = ch
+	return nil
+}
+
+func (s *session) unregister(seq uint32) {
+	s.mu.Lock()
+	delete(s.pending, seq)
+	s.mu.Unlock()
+}
+
+// respond passes a response to the matching outstanding request.
+//
+// Responses that match no outstanding request are dropped.
+func (s *session) respond(p *smpp.PDU) {
+	s.mu.Lock()
+	ch := s.pending[p.Sequence]
+	s.mu.Unlock()
+	if ch != nil {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// nack rejects the request with a generic_nack.
+func (s *session) nack(p *smpp.PDU, status smpp.Status) {
+	s.write(&smpp.PDU{
+		CommandID: smpp.GenericNack,
+		Status:    status,
+		Sequence:  p.Sequence,
+	})
+}
+
+func (s *session) write(p *smpp.PDU) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	err := smpp.WritePDU(s.nc, p)
+	if err != nil {
+		s.close(err)
+	}
+	return err
+}
+
+var (
+	// ErrClosed indicates the Client has been closed.
+	ErrClosed = errors.New("esme: closed")
+
+	// ErrTimeout indicates the SMSC did not respond to a request in time.
+	ErrTimeout = errors.New("esme: response timeout")
+
+	// ErrUnbound indicates the SMSC unbound the session.
+	ErrUnbound = errors.New("esme: unbound by SMSC")
+)
@@ -0,0 +1,468 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package esme_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smpp"
+	"github.com/warthog618/sms/smpp/esme"
+)
+
+// fakeSMSC is a minimal SMSC that serves ESME connections over net.Pipe.
+type fakeSMSC struct {
+	// handler returns the response to a request, or nil for no response.
+	handler func(p *smpp.PDU) *smpp.PDU
+
+	mu       sync.Mutex
+	conns    []*fakeConn
+	requests []*smpp.PDU
+	dials    int
+	msgID    int
+}
+
+type fakeConn struct {
+	mu sync.Mutex
+	nc net.Conn
+}
+
+func (fc *fakeConn) write(p *smpp.PDU) error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return smpp.WritePDU(fc.nc, p)
+}
+
+func newFakeSMSC() *fakeSMSC {
+	f := fakeSMSC{}
+	f.handler = f.defaultHandler
+	return &f
+}
+
+func (f *fakeSMSC) defaultHandler(p *smpp.PDU) *smpp.PDU {
+	r := p.Response(smpp.StatusOK)
+	switch p.CommandID {
+	case smpp.BindTransceiver, smpp.BindTransmitter, smpp.BindReceiver:
+		r.SystemID = "fake"
+	case smpp.SubmitSM:
+		f.mu.Lock()
+		f.msgID++
+		r.MessageID = fmt.Sprintf("%d", f.msgID)
+		f.mu.Unlock()
+	}
+	return r
+}
+
+func (f *fakeSMSC) dial(ctx context.Context) (net.Conn, error) {
+	esmeSide, smscSide := net.Pipe()
+	fc := &fakeConn{nc: smscSide}
+	f.mu.Lock()
+	f.dials++
+	f.conns = append(f.conns, fc)
+	f.mu.Unlock()
+	go f.serve(fc)
+	return esmeSide, nil
+}
+
+func (f *fakeSMSC) serve(fc *fakeConn) {
+	for {
+		p, err := smpp.ReadPDU(fc.nc)
+		if err != nil {
+			fc.nc.Close()
+			return
+		}
+		f.mu.Lock()
+		f.requests = append(f.requests, p)
+		h := f.handler
+		f.mu.Unlock()
+		if p.CommandID.IsResponse() {
+			continue
+		}
+		if r := h(p); r != nil {
+			fc.write(r)
+		}
+	}
+}
+
+func (f *fakeSMSC) setHandler(h func(p *smpp.PDU) *smpp.PDU) {
+	f.mu.Lock()
+	f.handler = h
+	f.mu.Unlock()
+}
+
+func (f *fakeSMSC) conn() *fakeConn {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conns[len(f.conns)-1]
+}
+
+func (f *fakeSMSC) dialCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.dials
+}
+
+// received returns the requests received with the command id.
+func (f *fakeSMSC) received(cmd smpp.CommandID) []*smpp.PDU {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var pp []*smpp.PDU
+	for _, p := range f.requests {
+		if p.CommandID == cmd {
+			pp = append(pp, p)
+		}
+	}
+	return pp
+}
+
+func waitBound(t *testing.T, c *esme.Client) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.Nil(t, c.WaitBound(ctx))
+}
+
+func TestBind(t *testing.T) {
+	patterns := []struct {
+		name string
+		bt   esme.BindType
+		cmd  smpp.CommandID
+	}{
+		{"transceiver", esme.Transceiver, smpp.BindTransceiver},
+		{"transmitter", esme.Transmitter, smpp.BindTransmitter},
+		{"receiver", esme.Receiver, smpp.BindReceiver},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			s := newFakeSMSC()
+			c := esme.New(s.dial,
+				esme.WithBindType(p.bt),
+				esme.WithCredentials("id", "pw"),
+				esme.WithSystemType("st"))
+			defer c.Close()
+			waitBound(t, c)
+			binds := s.received(p.cmd)
+			require.Equal(t, 1, len(binds))
+			assert.Equal(t, "id", binds[0].SystemID)
+			assert.Equal(t, "pw", binds[0].Password)
+			assert.Equal(t, "st", binds[0].SystemType)
+			assert.Equal(t, byte(smpp.InterfaceVersion), binds[0].InterfaceVersion)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestBindFail(t *testing.T) {
+	s := newFakeSMSC()
+	s.setHandler(func(p *smpp.PDU) *smpp.PDU {
+		return p.Response(smpp.StatusInvPaswd)
+	})
+	c := esme.New(s.dial, esme.WithBackoff(time.Millisecond, 2*time.Millisecond))
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.WaitBound(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, s.dialCount() > 1)
+}
+
+func TestSubmit(t *testing.T) {
+	s := newFakeSMSC()
+	c := esme.New(s.dial)
+	defer c.Close()
+	msg := "this is a very long message that does not fit in a single SMS message, at least it will if I keep adding more to it as 160 characters is more than you might think"
+	e := sms.NewEncoder(sms.AsSubmit, sms.To("1234"))
+	tpdus, err := e.Encode([]byte(msg))
+	require.Nil(t, err)
+	require.Equal(t, 2, len(tpdus))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ids, err := c.Submit(ctx, tpdus...)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids)
+
+	submits := s.received(smpp.SubmitSM)
+	require.Equal(t, 2, len(submits))
+	segs := make([]*tpdu.TPDU, len(submits))
+	for i, p := range submits {
+		assert.Equal(t, "1234", p.Dest.Addr)
+		segs[i], err = p.TPDU()
+		require.Nil(t, err)
+	}
+	m, err := sms.Decode(segs)
+	require.Nil(t, err)
+	assert.Equal(t, msg, string(m))
+}
+
+func TestSubmitError(t *testing.T) {
+	s := newFakeSMSC()
+	c := esme.New(s.dial)
+	defer c.Close()
+	waitBound(t, c)
+	s.setHandler(func(p *smpp.PDU) *smpp.PDU {
+		return p.Response(smpp.StatusThrottled)
+	})
+	tp, _ := tpdu.NewSubmit()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ids, err := c.Submit(ctx, *tp)
+	assert.Equal(t, smpp.StatusThrottled, err)
+	assert.Equal(t, []string{}, ids)
+
+	ids, err = c.Submit(ctx, tpdu.TPDU{FirstOctet: 0x02})
+	assert.Equal(t, smpp.ErrUnsupportedSmsType, err)
+	assert.Equal(t, []string{}, ids)
+}
+
+func TestSubmitTimeout(t *testing.T) {
+	s := newFakeSMSC()
+	c := esme.New(s.dial, esme.WithResponseTimeout(10*time.Millisecond))
+	defer c.Close()
+	waitBound(t, c)
+	s.setHandler(func(p *smpp.PDU) *smpp.PDU {
+		return nil
+	})
+	tp, _ := tpdu.NewSubmit()
+	_, err := c.Submit(context.Background(), *tp)
+	assert.Equal(t, esme.ErrTimeout, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = c.Send(ctx, &smpp.PDU{CommandID: smpp.EnquireLink})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestWindow(t *testing.T) {
+	s := newFakeSMSC()
+	c := esme.New(s.dial, esme.WithWindow(2))
+	defer c.Close()
+	waitBound(t, c)
+
+	var mu sync.Mutex
+	held := []*smpp.PDU{}
+	s.setHandler(func(p *smpp.PDU) *smpp.PDU {
+		mu.Lock()
+		held = append(held, p)
+		mu.Unlock()
+		return nil
+	})
+	var wg sync.WaitGroup
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Send(ctx, &smpp.PDU{CommandID: smpp.QuerySM})
+			assert.Nil(t, err)
+		}()
+	}
+	released := 0
+	for released < 5 {
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		pending := held
+		held = nil
+		mu.Unlock()
+		assert.True(t, len(pending) <= 2, "window exceeded: %d", len(pending))
+		for _, p := range pending {
+			s.conn().write(p.Response(smpp.StatusOK))
+			released++
+		}
+	}
+	wg.Wait()
+	s.setHandler(s.defaultHandler)
+}
+
+func TestEnquireLink(t *testing.T) {
+	s := newFakeSMSC()
+	c := esme.New(s.dial, esme.WithEnquireLink(5*time.Millisecond))
+	defer c.Close()
+	waitBound(t, c)
+	time.Sleep(30 * time.Millisecond)
+	assert.True(t, len(s.received(smpp.EnquireLink)) > 1)
+	assert.Equal(t, 1, s.dialCount())
+}
+
+func TestEnquireLinkFail(t *testing.T) {
+	s := newFakeSMSC()
+	c := esme.New(s.dial,
+		esme.WithEnquireLink(5*time.Millisecond),
+		esme.WithResponseTimeout(5*time.Millisecond),
+		esme.WithBackoff(time.Millisecond, time.Millisecond))
+	defer c.Close()
+	waitBound(t, c)
+	s.setHandler(func(p *smpp.PDU) *smpp.PDU {
+		if p.CommandID == smpp.EnquireLink {
+			return nil
+		}
+		return p.Response(smpp.StatusOK)
+	})
+	time.Sleep(50 * time.Millisecond)
+	assert.True(t, s.dialCount() > 1)
+}
+
+func TestReconnect(t *testing.T) {
+	s := newFakeSMSC()
+	c := esme.New(s.dial, esme.WithBackoff(time.Millisecond, time.Millisecond))
+	defer c.Close()
+	waitBound(t, c)
+	s.conn().nc.Close()
+	tp, _ := tpdu.NewSubmit()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var err error
+	for ctx.Err() == nil {
+		_, err = c.Submit(ctx, *tp)
+		if err == nil {
+			break
+		}
+	}
+	assert.Nil(t, err)
+	assert.Equal(t, 2, s.dialCount())
+}
+
+func TestUnbindBySMSC(t *testing.T) {
+	s := newFakeSMSC()
+	c := esme.New(s.dial, esme.WithBackoff(time.Millisecond, time.Millisecond))
+	defer c.Close()
+	waitBound(t, c)
+	fc := s.conn()
+	fc.write(&smpp.PDU{CommandID: smpp.Unbind, Sequence: 42})
+	time.Sleep(20 * time.Millisecond)
+	resps := s.received(smpp.UnbindResp)
+	require.Equal(t, 1, len(resps))
+	assert.Equal(t, uint32(42), resps[0].Sequence)
+	waitBound(t, c)
+	assert.Equal(t, 2, s.dialCount())
+}
+
+func TestRequestsFromSMSC(t *testing.T) {
+	s := newFakeSMSC()
+	c := esme.New(s.dial)
+	defer c.Close()
+	waitBound(t, c)
+	fc := s.conn()
+	fc.write(&smpp.PDU{CommandID: smpp.EnquireLink, Sequence: 1})
+	fc.write(&smpp.PDU{CommandID: smpp.QuerySM, Sequence: 2})
+	time.Sleep(20 * time.Millisecond)
+	resps := s.received(smpp.EnquireLinkResp)
+	require.Equal(t, 1, len(resps))
+	assert.Equal(t, uint32(1), resps[0].Sequence)
+	nacks := s.received(smpp.GenericNack)
+	require.Equal(t, 1, len(nacks))
+	assert.Equal(t, uint32(2), nacks[0].Sequence)
+	assert.Equal(t, smpp.StatusInvCmdID, nacks[0].Status)
+}
+
+func TestDeliver(t *testing.T) {
+	s := newFakeSMSC()
+	msgs := make(chan []*tpdu.TPDU, 1)
+	pdus := make(chan *smpp.PDU, 1)
+	c := esme.New(s.dial,
+		esme.WithDeliverHandler(func(segs []*tpdu.TPDU) { msgs <- segs }),
+		esme.WithPDUHandler(func(p *smpp.PDU) { pdus <- p }))
+	defer c.Close()
+	waitBound(t, c)
+
+	msg := "this is a very long message that does not fit in a single SMS message, at least it will if I keep adding more to it as 160 characters is more than you might think"
+	tpdus, err := sms.Encode([]byte(msg), sms.AsDeliver, sms.From("1234"))
+	require.Nil(t, err)
+	require.Equal(t, 2, len(tpdus))
+	fc := s.conn()
+	for i := range tpdus {
+		p, err := smpp.FromTPDU(&tpdus[i])
+		require.Nil(t, err)
+		p.Sequence = uint32(100 + i)
+		fc.write(p)
+	}
+	select {
+	case segs := <-msgs:
+		m, err := sms.Decode(segs)
+		require.Nil(t, err)
+		assert.Equal(t, msg, string(m))
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+	// the response is written after the message is passed to the handler
+	time.Sleep(10 * time.Millisecond)
+	resps := s.received(smpp.DeliverSMResp)
+	require.Equal(t, 2, len(resps))
+	for i, r := range resps {
+		assert.Equal(t, uint32(100+i), r.Sequence)
+		assert.Equal(t, smpp.StatusOK, r.Status)
+	}
+
+	// delivery receipt
+	dr := smpp.PDU{
+		CommandID:    smpp.DeliverSM,
+		Sequence:     200,
+		ESMClass:     smpp.EsmTypeDeliveryReceipt,
+		ShortMessage: []byte("id:1 stat:DELIVRD"),
+	}
+	fc.write(&dr)
+	select {
+	case p := <-pdus:
+		assert.Equal(t, smpp.EsmTypeDeliveryReceipt, p.ESMClass)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for receipt")
+	}
+
+	// undecodable
+	fc.write(&smpp.PDU{
+		CommandID:    smpp.DeliverSM,
+		Sequence:     201,
+		ESMClass:     smpp.EsmUDHI,
+		ShortMessage: []byte{5, 0},
+	})
+	time.Sleep(20 * time.Millisecond)
+	resps = s.received(smpp.DeliverSMResp)
+	require.Equal(t, 4, len(resps))
+	assert.Equal(t, uint32(201), resps[3].Sequence)
+	assert.Equal(t, smpp.StatusRxPAppn, resps[3].Status)
+}
+
+func TestClose(t *testing.T) {
+	s := newFakeSMSC()
+	c := esme.New(s.dial)
+	waitBound(t, c)
+	err := c.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(s.received(smpp.Unbind)))
+	err = c.Close()
+	assert.Nil(t, err)
+
+	tp, _ := tpdu.NewSubmit()
+	_, err = c.Submit(context.Background(), *tp)
+	assert.Equal(t, esme.ErrClosed, err)
+	err = c.WaitBound(context.Background())
+	assert.Equal(t, esme.ErrClosed, err)
+}
+
+func TestCloseUnbound(t *testing.T) {
+	c := esme.New(func(ctx context.Context) (net.Conn, error) {
+		return nil, fmt.Errorf("no route")
+	})
+	done := make(chan error)
+	go func() {
+		done <- c.WaitBound(context.Background())
+	}()
+	time.Sleep(5 * time.Millisecond)
+	c.Close()
+	select {
+	case err := <-done:
+		assert.Equal(t, esme.ErrClosed, err)
+	case <-time.After(time.Second):
+		t.Fatal("WaitBound not aborted")
+	}
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package esme
+
+import (
+	"time"
+
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smpp"
+)
+
+// Option alters the behaviour of a Client.
+type Option func(*Client)
+
+// WithBindType sets the type of bind performed by the Client.
+//
+// The default is Transceiver.
+func WithBindType(b BindType) Option {
+	return func(c *Client) {
+		c.bindType = b
+	}
+}
+
+// WithCredentials sets the system_id and password used to bind to the SMSC.
+func WithCredentials(systemID, password string) Option {
+	return func(c *Client) {
+		c.systemID = systemID
+		c.password = password
+	}
+}
+
+// WithSystemType sets the system_type used to bind to the SMSC.
+func WithSystemType(systemType string) Option {
+	return func(c *Client) {
+		c.systemType = systemType
+	}
+}
+
+// WithAddressRange sets the address_range used to bind to the SMSC.
+func WithAddressRange(a smpp.Address) Option {
+	return func(c *Client) {
+		c.addressRange = a
+	}
+}
+
+// WithWindow sets the maximum number of requests that may be outstanding at
+// any time.
+//
+// The default is 10.
+func WithWindow(n int) Option {
+	return func(c *Client) {
+		c.window = n
+	}
+}
+
+// WithEnquireLink sets the period between enquire_link requests.
+//
+// A failed enquire_link causes the Client to drop the connection and
+// reconnect.
+// A zero period disables the enquire_link.
+// The default is 30 seconds.
+func WithEnquireLink(period time.Duration) Option {
+	return func(c *Client) {
+		c.enquirePeriod = period
+	}
+}
+
+// WithResponseTimeout sets the time the Client waits for the response to a
+// request.
+//
+// The default is 10 seconds.
+func WithResponseTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.respTimeout = d
+	}
+}
+
+// WithBackoff sets the bounds of the delay between reconnection attempts.
+//
+// The delay starts at min and doubles with each failed attempt up to max.
+// The default is 1 second to 1 minute.
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff = min
+		c.maxBackoff = max
+	}
+}
+
+// WithCollector sets the Collector used to reassemble concatenated messages
+// received in deliver_sm.
+//
+// This allows the Collector to be configured, e.g. with a reassembly
+// timeout. The Collector is not closed when the Client is closed.
+func WithCollector(col *sms.Collector) Option {
+	return func(c *Client) {
+		c.collector = col
+	}
+}
+
+// WithDeliverHandler sets the handler for messages received from the SMSC.
+//
+// The handler is passed the complete set of TPDUs making up each message.
+func WithDeliverHandler(h func([]*tpdu.TPDU)) Option {
+	return func(c *Client) {
+		c.dh = h
+	}
+}
+
+// WithPDUHandler sets the handler for requests from the SMSC other than short
+// messages, such as deliver_sm containing delivery receipts, and
+// alert_notification.
+func WithPDUHandler(h func(*smpp.PDU)) Option {
+	return func(c *Client) {
+		c.ph = h
+	}
+}
@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// DeliveryReceipt represents the SMSC delivery receipt carried in the
+// short_message of a deliver_sm, as described in SMPP v3.4 Appendix B.
+//
+// The receipt has the form:
+//
+//	id:IIIIIIIIII sub:SSS dlvrd:DDD submit date:YYMMDDhhmm done date:YYMMDDhhmm stat:DDDDDDD err:EEE text:...
+type DeliveryReceipt struct {
+	// ID is the message_id assigned to the message by the SMSC.
+	ID string
+
+	// Submitted is the number of short messages originally submitted.
+	Submitted int
+
+	// Delivered is the number of short messages delivered.
+	Delivered int
+
+	// SubmitDate is the time the message was submitted.
+	SubmitDate time.Time
+
+	// DoneDate is the time the message reached its final state.
+	DoneDate time.Time
+
+	// State is the final state of the message.
+	State MessageState
+
+	// Err is the network specific error code.
+	Err int
+
+	// Text is the start of the text of the original message.
+	Text string
+}
+
+// receiptDateFormat is the format of the receipt submit and done dates.
+//
+// Dates may also be provided with seconds, which are accepted when parsing.
+const receiptDateFormat = "0601021504"
+
+var receiptStats = map[MessageState]string{
+	StateEnroute:       "ENROUTE",
+	StateDelivered:     "DELIVRD",
+	StateExpired:       "EXPIRED",
+	StateDeleted:       "DELETED",
+	StateUndeliverable: "UNDELIV",
+	StateAccepted:      "ACCEPTD",
+	StateUnknown:       "UNKNOWN",
+	StateRejected:      "REJECTD",
+}
+
+// String returns the receipt in the text form carried in the short_message.
+func (r DeliveryReceipt) String() string {
+	stat, ok := receiptStats[r.State]
+	if !ok {
+		stat = receiptStats[StateUnknown]
+	}
+	return fmt.Sprintf("id:%s sub:%03d dlvrd:%03d submit date:%s done date:%s stat:%s err:%03d text:%s",
+		r.ID, r.Submitted, r.Delivered,
+		formatReceiptDate(r.SubmitDate), formatReceiptDate(r.DoneDate),
+		stat, r.Err, r.Text)
+}
+
+// ParseDeliveryReceipt parses the text form of a delivery receipt.
+//
+// Field names are case insensitive and fields other than id and stat are
+// optional. The text field, if present, must be last and extends to the end
+// of the receipt.  Unrecognised fields are ignored.
+//
+// Dates are taken to be in UTC, as the receipt does not specify a timezone.
+func ParseDeliveryReceipt(s string) (*DeliveryReceipt, error) {
+	r := DeliveryReceipt{}
+	var hasID, hasStat bool
+	ls := strings.ToLower(s)
+	i := 0
+	for i < len(s) {
+		if s[i] == ' ' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(ls[i:], "text:") {
+			r.Text = s[i+5:]
+			break
+		}
+		var field, value string
+		var vi int
+		for _, f := range receiptFields {
+			if strings.HasPrefix(ls[i:], f+":") {
+				field = f
+				break
+			}
+		}
+		if field == "" {
+			// skip the unrecognised token
+			end := strings.IndexByte(s[i:], ' ')
+			if end < 0 {
+				break
+			}
+			i += end
+			continue
+		}
+		vi = i + len(field) + 1
+		end := strings.IndexByte(s[vi:], ' ')
+		if end < 0 {
+			end = len(s) - vi
+		}
+		value = s[vi : vi+end]
+		i = vi + end
+		var err error
+		switch field {
+		case "id":
+			r.ID = value
+			hasID = true
+		case "sub":
+			r.Submitted, err = strconv.Atoi(value)
+		case "dlvrd":
+			r.Delivered, err = strconv.Atoi(value)
+		case "submit date":
+			r.SubmitDate, err = parseReceiptDate(value)
+		case "done date":
+			r.DoneDate, err = parseReceiptDate(value)
+		case "stat":
+			r.State, err = parseReceiptStat(value)
+			hasStat = true
+		case "err":
+			r.Err, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return nil, tpdu.NewDecodeError(field, vi, tpdu.ErrInvalid)
+		}
+	}
+	if !hasID {
+		return nil, tpdu.NewDecodeError("id", 0, tpdu.ErrMissing)
+	}
+	if !hasStat {
+		return nil, tpdu.NewDecodeError("stat", 0, tpdu.ErrMissing)
+	}
+	return &r, nil
+}
+
+// receiptFields are the recognised receipt field names, other than text.
+var receiptFields = []string{"id", "sub", "dlvrd", "submit date", "done date", "stat", "err"}
+
+func formatReceiptDate(t time.Time) string {
+	if t.IsZero() {
+		return "0000000000"
+	}
+	return t.Format(receiptDateFormat)
+}
+
+func parseReceiptDate(s string) (time.Time, error) {
+	switch len(s) {
+	case 10:
+		if s == "0000000000" {
+			return time.Time{}, nil
+		}
+		return time.ParseInLocation(receiptDateFormat, s, time.UTC)
+	case 12:
+		return time.ParseInLocation(receiptDateFormat+"05", s, time.UTC)
+	default:
+		return time.Time{}, ErrInvalidTime(s)
+	}
+}
+
+func parseReceiptStat(s string) (MessageState, error) {
+	s = strings.ToUpper(s)
+	for state, stat := range receiptStats {
+		if s == stat || s == state.String() {
+			return state, nil
+		}
+	}
+	return StateUnset, tpdu.ErrInvalid
+}
+
+// StatusReport returns the SMS-STATUS-REPORT equivalent to the receipt.
+//
+// The recipient address, the destination of the original message, is not
+// contained in the receipt so must be provided.
+//
+// The TP-ST is derived from the State, or from Err if that is a TP-ST
+// consistent with the State.
+func (r *DeliveryReceipt) StatusReport(ra tpdu.Address) *tpdu.TPDU {
+	t, _ := tpdu.New(tpdu.SmsStatusReport)
+	t.RA = ra
+	t.SCTS = tpdu.Timestamp{Time: r.SubmitDate}
+	t.DT = tpdu.Timestamp{Time: r.DoneDate}
+	st := stFromState(r.State)
+	if r.Err > 0 && r.Err <= 0xff && stateFromST(byte(r.Err)) == r.State {
+		st = byte(r.Err)
+	}
+	t.ST = st
+	return t
+}
+
+// DeliveryReceiptFromStatusReport returns the receipt equivalent to the
+// SMS-STATUS-REPORT.
+//
+// The message_id assigned to the original message is not contained in the
+// status report so must be provided.
+//
+// The TP-ST is returned in Err.
+func DeliveryReceiptFromStatusReport(t *tpdu.TPDU, id string) (*DeliveryReceipt, error) {
+	if st := t.SmsType(); st != tpdu.SmsStatusReport {
+		return nil, ErrUnsupportedSmsType
+	}
+	r := DeliveryReceipt{
+		ID:         id,
+		Submitted:  1,
+		SubmitDate: t.SCTS.Time,
+		DoneDate:   t.DT.Time,
+		State:      stateFromST(t.ST),
+		Err:        int(t.ST),
+	}
+	if r.State == StateDelivered {
+		r.Delivered = 1
+	}
+	return &r, nil
+}
+
+// stateFromST returns the MessageState corresponding to a TP-ST, as defined
+// in 3GPP TS 23.040 Section 9.2.3.15.
+func stateFromST(st byte) MessageState {
+	switch {
+	case st == 0x01:
+		// forwarded to the SME, unable to confirm delivery
+		return StateAccepted
+	case st < 0x20:
+		// transaction completed
+		return StateDelivered
+	case st < 0x40:
+		// temporary error, SC still trying
+		return StateEnroute
+	case st == 0x46:
+		return StateExpired
+	case st == 0x47, st == 0x48:
+		return StateDeleted
+	case st < 0x80:
+		// permanent error, or temporary error with SC no longer trying
+		return StateUndeliverable
+	default:
+		return StateUnknown
+	}
+}
+
+// stFromState returns the TP-ST best corresponding to a MessageState.
+func stFromState(s MessageState) byte {
+	switch s {
+	case StateDelivered:
+		return 0x00 // received by the SME
+	case StateAccepted:
+		return 0x01 // forwarded to the SME, unable to confirm delivery
+	case StateEnroute:
+		return 0x20 // congestion
+	case StateExpired:
+		return 0x46 // validity period expired
+	case StateDeleted:
+		return 0x48 // deleted by SC administration
+	case StateRejected:
+		return 0x42 // connection rejected by SME
+	default:
+		return 0x40 // remote procedure error
+	}
+}
@@ -0,0 +1,261 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smpp"
+)
+
+func TestParseDeliveryReceipt(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		out  *smpp.DeliveryReceipt
+		err  error
+	}{
+		{
+			"full",
+			"id:1234567890 sub:001 dlvrd:001 submit date:2004121030 done date:2004121031 stat:DELIVRD err:000 text:hello world",
+			&smpp.DeliveryReceipt{
+				ID:         "1234567890",
+				Submitted:  1,
+				Delivered:  1,
+				SubmitDate: time.Date(2020, time.April, 12, 10, 30, 0, 0, time.UTC),
+				DoneDate:   time.Date(2020, time.April, 12, 10, 31, 0, 0, time.UTC),
+				State:      smpp.StateDelivered,
+				Text:       "hello world",
+			},
+			nil,
+		},
+		{
+			"seconds",
+			"id:a1 submit date:200412103015 done date:200412103115 stat:UNDELIV err:011",
+			&smpp.DeliveryReceipt{
+				ID:         "a1",
+				SubmitDate: time.Date(2020, time.April, 12, 10, 30, 15, 0, time.UTC),
+				DoneDate:   time.Date(2020, time.April, 12, 10, 31, 15, 0, time.UTC),
+				State:      smpp.StateUndeliverable,
+				Err:        11,
+			},
+			nil,
+		},
+		{
+			"case and unknown fields",
+			"ID:42 Sub:1 foo:bar stat:expired Text:",
+			&smpp.DeliveryReceipt{
+				ID:        "42",
+				Submitted: 1,
+				State:     smpp.StateExpired,
+			},
+			nil,
+		},
+		{
+			"long state name",
+			"id:42 stat:DELIVERED",
+			&smpp.DeliveryReceipt{ID: "42", State: smpp.StateDelivered},
+			nil,
+		},
+		{"missing id", "stat:DELIVRD", nil, tpdu.NewDecodeError("id", 0, tpdu.ErrMissing)},
+		{"missing stat", "id:42", nil, tpdu.NewDecodeError("stat", 0, tpdu.ErrMissing)},
+		{"bad stat", "id:42 stat:DONE", nil, tpdu.NewDecodeError("stat", 11, tpdu.ErrInvalid)},
+		{"bad sub", "id:42 sub:x stat:DELIVRD", nil, tpdu.NewDecodeError("sub", 10, tpdu.ErrInvalid)},
+		{"bad date", "id:42 submit date:20041210 stat:DELIVRD", nil, tpdu.NewDecodeError("submit date", 18, tpdu.ErrInvalid)},
+		{"bad err", "id:42 stat:DELIVRD err:abc", nil, tpdu.NewDecodeError("err", 23, tpdu.ErrInvalid)},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := smpp.ParseDeliveryReceipt(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, r)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestDeliveryReceiptString(t *testing.T) {
+	r := smpp.DeliveryReceipt{
+		ID:         "1234567890",
+		Submitted:  1,
+		Delivered:  1,
+		SubmitDate: time.Date(2020, time.April, 12, 10, 30, 0, 0, time.UTC),
+		DoneDate:   time.Date(2020, time.April, 12, 10, 31, 0, 0, time.UTC),
+		State:      smpp.StateDelivered,
+		Text:       "hello",
+	}
+	s := r.String()
+	assert.Equal(t, "id:1234567890 sub:001 dlvrd:001 submit date:2004121030 done date:2004121031 stat:DELIVRD err:000 text:hello", s)
+	pr, err := smpp.ParseDeliveryReceipt(s)
+	require.Nil(t, err)
+	assert.Equal(t, &r, pr)
+
+	r = smpp.DeliveryReceipt{ID: "1", State: smpp.StateUnset}
+	assert.Equal(t, "id:1 sub:000 dlvrd:000 submit date:0000000000 done date:0000000000 stat:UNKNOWN err:000 text:", r.String())
+}
+
+func TestStatusReportConversion(t *testing.T) {
+	loc := time.FixedZone("SCTS", 8*3600)
+	ra := tpdu.NewAddress(tpdu.FromNumber("1234"))
+	patterns := []struct {
+		name  string
+		st    byte
+		state smpp.MessageState
+	}{
+		{"delivered", 0x00, smpp.StateDelivered},
+		{"forwarded", 0x01, smpp.StateAccepted},
+		{"replaced", 0x02, smpp.StateDelivered},
+		{"congestion", 0x21, smpp.StateEnroute},
+		{"expired", 0x46, smpp.StateExpired},
+		{"sme error", 0x65, smpp.StateUndeliverable},
+		{"deleted", 0x47, smpp.StateDeleted},
+		{"undeliverable", 0x41, smpp.StateUndeliverable},
+		{"reserved", 0x80, smpp.StateUnknown},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			sr, _ := tpdu.New(tpdu.SmsStatusReport)
+			sr.RA = ra
+			sr.SCTS = tpdu.Timestamp{Time: time.Date(2020, time.April, 12, 10, 30, 0, 0, loc)}
+			sr.DT = tpdu.Timestamp{Time: time.Date(2020, time.April, 12, 10, 31, 0, 0, loc)}
+			sr.ST = p.st
+			r, err := smpp.DeliveryReceiptFromStatusReport(sr, "42")
+			require.Nil(t, err)
+			assert.Equal(t, "42", r.ID)
+			assert.Equal(t, p.state, r.State)
+			assert.Equal(t, int(p.st), r.Err)
+			assert.Equal(t, "2004121030", r.SubmitDate.Format("0601021504"))
+
+			rsr := r.StatusReport(ra)
+			assert.Equal(t, p.st, rsr.ST)
+			assert.Equal(t, ra, rsr.RA)
+			assert.True(t, sr.SCTS.Equal(rsr.SCTS.Time))
+			assert.True(t, sr.DT.Equal(rsr.DT.Time))
+		}
+		t.Run(p.name, f)
+	}
+
+	_, err := smpp.DeliveryReceiptFromStatusReport(&tpdu.TPDU{}, "42")
+	assert.Equal(t, smpp.ErrUnsupportedSmsType, err)
+
+	// Err inconsistent with state is ignored
+	r := smpp.DeliveryReceipt{ID: "1", State: smpp.StateExpired, Err: 5}
+	assert.Equal(t, byte(0x46), r.StatusReport(ra).ST)
+
+	// accepted survives the round trip
+	r = smpp.DeliveryReceipt{ID: "1", State: smpp.StateAccepted}
+	rr, err := smpp.DeliveryReceiptFromStatusReport(r.StatusReport(ra), "1")
+	require.Nil(t, err)
+	assert.Equal(t, smpp.StateAccepted, rr.State)
+	assert.Equal(t, 0, rr.Delivered)
+}
+
+func TestFromStatusReport(t *testing.T) {
+	sr, _ := tpdu.New(tpdu.SmsStatusReport)
+	sr.RA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+	sr.MR = 7
+	sr.SCTS = tpdu.Timestamp{Time: time.Date(2020, time.April, 12, 10, 30, 0, 0, time.UTC)}
+	sr.DT = tpdu.Timestamp{Time: time.Date(2020, time.April, 12, 10, 31, 0, 0, time.UTC)}
+	sr.ST = 0x46
+	p, err := smpp.FromStatusReport(sr, "42")
+	require.Nil(t, err)
+	assert.Equal(t, smpp.DeliverSM, p.CommandID)
+	assert.Equal(t, smpp.EsmTypeDeliveryReceipt, p.ESMClass)
+	assert.Equal(t, smpp.Address{TON: 1, NPI: 1, Addr: "1234"}, p.Source)
+	assert.Equal(t, "id:42 sub:001 dlvrd:000 submit date:2004121030 done date:2004121031 stat:EXPIRED err:070 text:", string(p.ShortMessage))
+	assert.Equal(t, smpp.TLVs{
+		smpp.NewTLVString(smpp.TagReceiptedMessageID, "42"),
+		smpp.NewTLV8(smpp.TagMessageState, byte(smpp.StateExpired)),
+		smpp.NewTLV16(smpp.TagUserMessageReference, 7),
+	}, p.TLVs)
+
+	b, err := p.MarshalBinary()
+	require.Nil(t, err)
+	rp := smpp.PDU{}
+	require.Nil(t, rp.UnmarshalBinary(b))
+	rsr, err := rp.TPDU()
+	require.Nil(t, err)
+	assert.Equal(t, sr, rsr)
+
+	_, err = smpp.FromStatusReport(&tpdu.TPDU{}, "42")
+	assert.Equal(t, smpp.ErrUnsupportedSmsType, err)
+}
+
+func TestPDUDeliveryReceipt(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   smpp.PDU
+		out  *smpp.DeliveryReceipt
+		err  error
+	}{
+		{
+			"text",
+			smpp.PDU{ShortMessage: []byte("id:1 stat:DELIVRD")},
+			&smpp.DeliveryReceipt{ID: "1", State: smpp.StateDelivered},
+			nil,
+		},
+		{
+			"tlvs",
+			smpp.PDU{TLVs: smpp.TLVs{
+				smpp.NewTLVString(smpp.TagReceiptedMessageID, "2"),
+				smpp.NewTLV8(smpp.TagMessageState, byte(smpp.StateRejected)),
+			}},
+			&smpp.DeliveryReceipt{ID: "2", State: smpp.StateRejected},
+			nil,
+		},
+		{
+			"tlvs override text",
+			smpp.PDU{
+				ShortMessage: []byte("id:1 sub:001 stat:DELIVRD"),
+				TLVs: smpp.TLVs{
+					smpp.NewTLVString(smpp.TagReceiptedMessageID, "2"),
+					smpp.NewTLV8(smpp.TagMessageState, byte(smpp.StateRejected)),
+				}},
+			&smpp.DeliveryReceipt{ID: "2", Submitted: 1, State: smpp.StateRejected},
+			nil,
+		},
+		{
+			"bad text with tlvs",
+			smpp.PDU{
+				ShortMessage: []byte("garbage"),
+				TLVs: smpp.TLVs{
+					smpp.NewTLVString(smpp.TagReceiptedMessageID, "2"),
+					smpp.NewTLV8(smpp.TagMessageState, byte(smpp.StateRejected)),
+				}},
+			&smpp.DeliveryReceipt{ID: "2", State: smpp.StateRejected},
+			nil,
+		},
+		{
+			"bad text",
+			smpp.PDU{ShortMessage: []byte("garbage")},
+			nil,
+			tpdu.NewDecodeError("short_message", 0, tpdu.NewDecodeError("id", 0, tpdu.ErrMissing)),
+		},
+		{
+			"missing id",
+			smpp.PDU{TLVs: smpp.TLVs{smpp.NewTLV8(smpp.TagMessageState, 2)}},
+			nil,
+			tpdu.NewDecodeError("receipted_message_id", 0, tpdu.ErrMissing),
+		},
+		{
+			"missing state",
+			smpp.PDU{TLVs: smpp.TLVs{smpp.NewTLVString(smpp.TagReceiptedMessageID, "2")}},
+			nil,
+			tpdu.NewDecodeError("message_state", 0, tpdu.ErrMissing),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			r, err := p.in.DeliveryReceipt()
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, r)
+		}
+		t.Run(p.name, f)
+	}
+}
@@ -0,0 +1,647 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package smpp provides the SMPP v3.4 PDU type and conversions to and from its
+// binary form, and to and from SMS TPDUs.
+package smpp
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// PDU represents all SMPP PDUs.
+//
+// As with the TPDU, the one type covers all commands, and only the fields
+// relevant to the CommandID are marshalled.
+type PDU struct {
+	// CommandID identifies the type of the PDU.
+	CommandID CommandID
+
+	// Status contains the command_status field.
+	//
+	// Only relevant to responses, and should be StatusOK for requests.
+	Status Status
+
+	// Sequence contains the sequence_number field used to correlate requests
+	// and responses.
+	Sequence uint32
+
+	// SystemID contains the system_id field.
+	//
+	// Only applies to the bind commands and their responses, and outbind.
+	SystemID string
+
+	// Password contains the password field.
+	//
+	// Only applies to the bind commands and outbind.
+	Password string
+
+	// SystemType contains the system_type field.
+	//
+	// Only applies to the bind commands.
+	SystemType string
+
+	// InterfaceVersion contains the interface_version field.
+	//
+	// Only applies to the bind commands.
+	InterfaceVersion byte
+
+	// AddressRange contains the addr_ton, addr_npi and address_range fields.
+	//
+	// Only applies to the bind commands.
+	AddressRange Address
+
+	// ServiceType contains the service_type field.
+	//
+	// Only applies to submit_sm, submit_multi, deliver_sm, data_sm and
+	// cancel_sm.
+	ServiceType string
+
+	// Source contains the source_addr_ton, source_addr_npi and source_addr
+	// fields.
+	Source Address
+
+	// Dest contains the dest_addr_ton, dest_addr_npi and destination_addr
+	// fields.
+	//
+	// Only applies to submit_sm, deliver_sm, data_sm and cancel_sm.
+	Dest Address
+
+	// Dests contains the dest_address list.
+	//
+	// Only applies to submit_multi.
+	Dests []DestAddress
+
+	// ESME contains the esme_addr_ton, esme_addr_npi and esme_addr fields.
+	//
+	// Only applies to alert_notification.
+	ESME Address
+
+	// ESMClass contains the esm_class field.
+	ESMClass ESMClass
+
+	// ProtocolID contains the protocol_id field.
+	ProtocolID byte
+
+	// PriorityFlag contains the priority_flag field.
+	PriorityFlag byte
+
+	// ScheduleDeliveryTime contains the schedule_delivery_time field, in
+	// SMPP time format.
+	ScheduleDeliveryTime string
+
+	// ValidityPeriod contains the validity_period field, in SMPP time format.
+	ValidityPeriod string
+
+	// RegisteredDelivery contains the registered_delivery field.
+	RegisteredDelivery byte
+
+	// ReplaceIfPresent contains the replace_if_present_flag field.
+	ReplaceIfPresent byte
+
+	// DataCoding contains the data_coding field.
+	DataCoding byte
+
+	// SMDefaultMsgID contains the sm_default_msg_id field.
+	SMDefaultMsgID byte
+
+	// ShortMessage contains the short_message field.
+	//
+	// The sm_length is determined from the length of the ShortMessage.
+	ShortMessage []byte
+
+	// MessageID contains the message_id field.
+	MessageID string
+
+	// FinalDate contains the final_date field, in SMPP time format.
+	//
+	// Only applies to query_sm_resp.
+	FinalDate string
+
+	// MessageState contains the message_state field.
+	//
+	// Only applies to query_sm_resp.
+	MessageState MessageState
+
+	// ErrorCode contains the error_code field.
+	//
+	// Only applies to query_sm_resp.
+	ErrorCode byte
+
+	// Unsuccess contains the unsuccess_sme list.
+	//
+	// Only applies to submit_multi_resp.
+	Unsuccess []UnsuccessSME
+
+	// TLVs contains the optional parameters that follow the mandatory
+	// parameters.
+	TLVs TLVs
+}
+
+// Address represents an SMPP address, being a TON, NPI and address string.
+type Address struct {
+	TON  byte
+	NPI  byte
+	Addr string
+}
+
+// DestAddress is an entry in the submit_multi dest_address list.
+//
+// If DLName is empty then the entry is an SME address, else it is the name
+// of a distribution list.
+type DestAddress struct {
+	Address
+	DLName string
+}
+
+// UnsuccessSME is an entry in the submit_multi_resp unsuccess_sme list.
+type UnsuccessSME struct {
+	Address
+	Status Status
+}
+
+// HeaderLen is the length of the PDU header common to all PDUs.
+const HeaderLen = 16
+
+// MaxPDULen is the largest PDU that will be read by ReadPDU.
+//
+// This is not a protocol limit, but guards against reading garbage.
+const MaxPDULen = 64 * 1024
+
+// MarshalBinary marshals the PDU into the corresponding byte array, including
+// the header.
+func (p *PDU) MarshalBinary() ([]byte, error) {
+	e := encoder{b: make([]byte, HeaderLen, 64)}
+	switch p.CommandID {
+	case GenericNack, Unbind, UnbindResp, EnquireLink, EnquireLinkResp,
+		CancelSMResp, ReplaceSMResp:
+		// header only
+	case BindReceiver, BindTransmitter, BindTransceiver:
+		p.marshalBind(&e)
+	case BindReceiverResp, BindTransmitterResp, BindTransceiverResp:
+		e.cstring("system_id", p.SystemID, 16)
+	case Outbind:
+		e.cstring("system_id", p.SystemID, 16)
+		e.cstring("password", p.Password, 9)
+	case SubmitSM, DeliverSM:
+		p.marshalSM(&e)
+	case SubmitSMResp, DeliverSMResp, DataSMResp:
+		e.cstring("message_id", p.MessageID, 65)
+	case DataSM:
+		e.cstring("service_type", p.ServiceType, 6)
+		e.address("source", p.Source, 65)
+		e.address("dest", p.Dest, 65)
+		e.byte(byte(p.ESMClass), p.RegisteredDelivery, p.DataCoding)
+	case QuerySM:
+		e.cstring("message_id", p.MessageID, 65)
+		e.address("source", p.Source, 21)
+	case QuerySMResp:
+		e.cstring("message_id", p.MessageID, 65)
+		e.cstring("final_date", p.FinalDate, 17)
+		e.byte(byte(p.MessageState), p.ErrorCode)
+	case CancelSM:
+		e.cstring("service_type", p.ServiceType, 6)
+		e.cstring("message_id", p.MessageID, 65)
+		e.address("source", p.Source, 21)
+		e.address("dest", p.Dest, 21)
+	case ReplaceSM:
+		e.cstring("message_id", p.MessageID, 65)
+		e.address("source", p.Source, 21)
+		e.cstring("schedule_delivery_time", p.ScheduleDeliveryTime, 17)
+		e.cstring("validity_period", p.ValidityPeriod, 17)
+		e.byte(p.RegisteredDelivery, p.SMDefaultMsgID)
+		e.shortMessage(p.ShortMessage)
+	case SubmitMulti:
+		p.marshalSubmitMulti(&e)
+	case SubmitMultiResp:
+		e.cstring("message_id", p.MessageID, 65)
+		if len(p.Unsuccess) > 255 {
+			return nil, tpdu.EncodeError("unsuccess_sme", tpdu.ErrOverlength)
+		}
+		e.byte(byte(len(p.Unsuccess)))
+		for _, u := range p.Unsuccess {
+			e.address("unsuccess_sme", u.Address, 21)
+			e.uint32(uint32(u.Status))
+		}
+	case AlertNotification:
+		e.address("source", p.Source, 65)
+		e.address("esme", p.ESME, 65)
+	default:
+		return nil, ErrUnsupportedCommand(p.CommandID)
+	}
+	if e.err != nil {
+		return nil, tpdu.EncodeError(p.CommandID.String(), e.err)
+	}
+	tlvs, err := p.TLVs.MarshalBinary()
+	if err != nil {
+		return nil, tpdu.EncodeError(p.CommandID.String(), err)
+	}
+	b := append(e.b, tlvs...)
+	binary.BigEndian.PutUint32(b[0:], uint32(len(b)))
+	binary.BigEndian.PutUint32(b[4:], uint32(p.CommandID))
+	binary.BigEndian.PutUint32(b[8:], uint32(p.Status))
+	binary.BigEndian.PutUint32(b[12:], p.Sequence)
+	return b, nil
+}
+
+func (p *PDU) marshalBind(e *encoder) {
+	e.cstring("system_id", p.SystemID, 16)
+	e.cstring("password", p.Password, 9)
+	e.cstring("system_type", p.SystemType, 13)
+	e.byte(p.InterfaceVersion)
+	e.address("address_range", p.AddressRange, 41)
+}
+
+func (p *PDU) marshalSM(e *encoder) {
+	e.cstring("service_type", p.ServiceType, 6)
+	e.address("source", p.Source, 21)
+	e.address("dest", p.Dest, 21)
+	p.marshalSMTail(e)
+}
+
+func (p *PDU) marshalSMTail(e *encoder) {
+	e.byte(byte(p.ESMClass), p.ProtocolID, p.PriorityFlag)
+	e.cstring("schedule_delivery_time", p.ScheduleDeliveryTime, 17)
+	e.cstring("validity_period", p.ValidityPeriod, 17)
+	e.byte(p.RegisteredDelivery, p.ReplaceIfPresent, p.DataCoding, p.SMDefaultMsgID)
+	e.shortMessage(p.ShortMessage)
+}
+
+func (p *PDU) marshalSubmitMulti(e *encoder) {
+	e.cstring("service_type", p.ServiceType, 6)
+	e.address("source", p.Source, 21)
+	if len(p.Dests) > 254 {
+		e.fail("dest_address", tpdu.ErrOverlength)
+		return
+	}
+	e.byte(byte(len(p.Dests)))
+	for _, d := range p.Dests {
+		if d.DLName != "" {
+			e.byte(DestFlagDLName)
+			e.cstring("dl_name", d.DLName, 21)
+			continue
+		}
+		e.byte(DestFlagSMEAddress)
+		e.address("dest_address", d.Address, 21)
+	}
+	p.marshalSMTail(e)
+}
+
+// UnmarshalBinary unmarshals a PDU from the corresponding byte array.
+//
+// The src must contain the complete PDU, including the header.
+//
+// In the case of error the PDU will be partially unmarshalled, up to the point
+// that the decoding error was detected.
+func (p *PDU) UnmarshalBinary(src []byte) error {
+	if len(src) < HeaderLen {
+		return tpdu.NewDecodeError("header", 0, tpdu.ErrUnderflow)
+	}
+	l := binary.BigEndian.Uint32(src)
+	if l < HeaderLen {
+		return tpdu.NewDecodeError("command_length", 0, ErrInvalidLength(l))
+	}
+	if uint32(len(src)) < l {
+		return tpdu.NewDecodeError("command_length", 0, tpdu.ErrUnderflow)
+	}
+	if uint32(len(src)) > l {
+		return tpdu.NewDecodeError("command_length", 0, tpdu.ErrOverlength)
+	}
+	p.CommandID = CommandID(binary.BigEndian.Uint32(src[4:]))
+	p.Status = Status(binary.BigEndian.Uint32(src[8:]))
+	p.Sequence = binary.BigEndian.Uint32(src[12:])
+	d := decoder{src: src, ri: HeaderLen}
+	switch p.CommandID {
+	case GenericNack, Unbind, UnbindResp, EnquireLink, EnquireLinkResp,
+		CancelSMResp, ReplaceSMResp:
+		// header only
+	case BindReceiver, BindTransmitter, BindTransceiver:
+		p.SystemID = d.cstring("system_id", 16)
+		p.Password = d.cstring("password", 9)
+		p.SystemType = d.cstring("system_type", 13)
+		p.InterfaceVersion = d.byte("interface_version")
+		p.AddressRange = d.address("address_range", 41)
+	case BindReceiverResp, BindTransmitterResp, BindTransceiverResp:
+		// body may be omitted if the bind failed
+		if d.more() {
+			p.SystemID = d.cstring("system_id", 16)
+		}
+	case Outbind:
+		p.SystemID = d.cstring("system_id", 16)
+		p.Password = d.cstring("password", 9)
+	case SubmitSM, DeliverSM:
+		p.ServiceType = d.cstring("service_type", 6)
+		p.Source = d.address("source", 21)
+		p.Dest = d.address("dest", 21)
+		p.unmarshalSMTail(&d)
+	case SubmitSMResp, DeliverSMResp, DataSMResp:
+		if d.more() {
+			p.MessageID = d.cstring("message_id", 65)
+		}
+	case DataSM:
+		p.ServiceType = d.cstring("service_type", 6)
+		p.Source = d.address("source", 65)
+		p.Dest = d.address("dest", 65)
+		p.ESMClass = ESMClass(d.byte("esm_class"))
+		p.RegisteredDelivery = d.byte("registered_delivery")
+		p.DataCoding = d.byte("data_coding")
+	case QuerySM:
+		p.MessageID = d.cstring("message_id", 65)
+		p.Source = d.address("source", 21)
+	case QuerySMResp:
+		p.MessageID = d.cstring("message_id", 65)
+		p.FinalDate = d.cstring("final_date", 17)
+		p.MessageState = MessageState(d.byte("message_state"))
+		p.ErrorCode = d.byte("error_code")
+	case CancelSM:
+		p.ServiceType = d.cstring("service_type", 6)
+		p.MessageID = d.cstring("message_id", 65)
+		p.Source = d.address("source", 21)
+		p.Dest = d.address("dest", 21)
+	case ReplaceSM:
+		p.MessageID = d.cstring("message_id", 65)
+		p.Source = d.address("source", 21)
+		p.ScheduleDeliveryTime = d.cstring("schedule_delivery_time", 17)
+		p.ValidityPeriod = d.cstring("validity_period", 17)
+		p.RegisteredDelivery = d.byte("registered_delivery")
+		p.SMDefaultMsgID = d.byte("sm_default_msg_id")
+		p.ShortMessage = d.shortMessage()
+	case SubmitMulti:
+		p.unmarshalSubmitMulti(&d)
+	case SubmitMultiResp:
+		if !d.more() {
+			break
+		}
+		p.MessageID = d.cstring("message_id", 65)
+		n := int(d.byte("no_unsuccess"))
+		p.Unsuccess = nil
+		for i := 0; i < n && d.err == nil; i++ {
+			u := UnsuccessSME{}
+			u.Address = d.address("unsuccess_sme", 21)
+			u.Status = Status(d.uint32("error_status_code"))
+			p.Unsuccess = append(p.Unsuccess, u)
+		}
+	case AlertNotification:
+		p.Source = d.address("source", 65)
+		p.ESME = d.address("esme", 65)
+	default:
+		return tpdu.NewDecodeError("command_id", 4, ErrUnsupportedCommand(p.CommandID))
+	}
+	if d.err != nil {
+		return tpdu.NewDecodeError(p.CommandID.String(), 0, d.err)
+	}
+	p.TLVs = nil
+	if d.more() {
+		var tlvs TLVs
+		err := tlvs.UnmarshalBinary(src[d.ri:])
+		if err != nil {
+			return tpdu.NewDecodeError(p.CommandID.String(), d.ri, err)
+		}
+		p.TLVs = tlvs
+	}
+	return nil
+}
+
+func (p *PDU) unmarshalSMTail(d *decoder) {
+	p.ESMClass = ESMClass(d.byte("esm_class"))
+	p.ProtocolID = d.byte("protocol_id")
+	p.PriorityFlag = d.byte("priority_flag")
+	p.ScheduleDeliveryTime = d.cstring("schedule_delivery_time", 17)
+	p.ValidityPeriod = d.cstring("validity_period", 17)
+	p.RegisteredDelivery = d.byte("registered_delivery")
+	p.ReplaceIfPresent = d.byte("replace_if_present_flag")
+	p.DataCoding = d.byte("data_coding")
+	p.SMDefaultMsgID = d.byte("sm_default_msg_id")
+	p.ShortMessage = d.shortMessage()
+}
+
+func (p *PDU) unmarshalSubmitMulti(d *decoder) {
+	p.ServiceType = d.cstring("service_type", 6)
+	p.Source = d.address("source", 21)
+	n := int(d.byte("number_of_dests"))
+	p.Dests = nil
+	for i := 0; i < n && d.err == nil; i++ {
+		switch d.byte("dest_flag") {
+		case DestFlagSMEAddress:
+			a := d.address("dest_address", 21)
+			p.Dests = append(p.Dests, DestAddress{Address: a})
+		case DestFlagDLName:
+			dl := d.cstring("dl_name", 21)
+			p.Dests = append(p.Dests, DestAddress{DLName: dl})
+		default:
+			d.fail("dest_flag", tpdu.ErrInvalid)
+		}
+	}
+	p.unmarshalSMTail(d)
+}
+
+// Response returns a response PDU for the request PDU, with the status
+// provided.
+//
+// The returned PDU has the CommandID and Sequence set appropriately.
+// Any other fields required by the response must be filled in by the caller.
+func (p *PDU) Response(s Status) *PDU {
+	r := PDU{
+		CommandID: p.CommandID.Response(),
+		Status:    s,
+		Sequence:  p.Sequence,
+	}
+	return &r
+}
+
+// ReadPDU reads a single PDU from the reader.
+//
+// The header is read first to determine the length of the PDU, and then the
+// remainder of the PDU.
+func ReadPDU(r io.Reader) (*PDU, error) {
+	h := make([]byte, HeaderLen)
+	_, err := io.ReadFull(r, h)
+	if err != nil {
+		return nil, err
+	}
+	l := binary.BigEndian.Uint32(h)
+	if l < HeaderLen || l > MaxPDULen {
+		return nil, ErrInvalidLength(l)
+	}
+	b := make([]byte, l)
+	copy(b, h)
+	_, err = io.ReadFull(r, b[HeaderLen:])
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	p := PDU{}
+	err = p.UnmarshalBinary(b)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// WritePDU marshals the PDU and writes it to the writer.
+func WritePDU(w io.Writer, p *PDU) error {
+	b, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+const (
+	// DestFlagSMEAddress indicates a submit_multi dest_address is an SME
+	// address.
+	DestFlagSMEAddress = 1
+
+	// DestFlagDLName indicates a submit_multi dest_address is a distribution
+	// list name.
+	DestFlagDLName = 2
+)
+
+// encoder accumulates the binary form of PDU fields.
+//
+// The first error encountered is recorded and subsequent operations are
+// ignored.
+type encoder struct {
+	b   []byte
+	err error
+}
+
+func (e *encoder) fail(f string, err error) {
+	if e.err == nil {
+		e.err = tpdu.EncodeError(f, err)
+	}
+}
+
+func (e *encoder) byte(b ...byte) {
+	e.b = append(e.b, b...)
+}
+
+func (e *encoder) uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	e.b = append(e.b, b[:]...)
+}
+
+// cstring encodes a C-Octet String, with max being the maximum length
+// including the terminating NULL.
+func (e *encoder) cstring(f string, s string, max int) {
+	if len(s) >= max {
+		e.fail(f, tpdu.ErrOverlength)
+		return
+	}
+	e.b = append(e.b, s...)
+	e.b = append(e.b, 0)
+}
+
+func (e *encoder) address(f string, a Address, max int) {
+	e.b = append(e.b, a.TON, a.NPI)
+	e.cstring(f, a.Addr, max)
+}
+
+func (e *encoder) shortMessage(sm []byte) {
+	if len(sm) > 254 {
+		e.fail("short_message", tpdu.ErrOverlength)
+		return
+	}
+	e.b = append(e.b, byte(len(sm)))
+	e.b = append(e.b, sm...)
+}
+
+// decoder extracts PDU fields from binary.
+//
+// The first error encountered is recorded and subsequent operations return
+// zero values.
+type decoder struct {
+	src []byte
+	ri  int
+	err error
+}
+
+func (d *decoder) fail(f string, err error) {
+	if d.err == nil {
+		d.err = tpdu.NewDecodeError(f, d.ri, err)
+	}
+}
+
+func (d *decoder) more() bool {
+	return d.err == nil && d.ri < len(d.src)
+}
+
+func (d *decoder) byte(f string) byte {
+	if d.err != nil {
+		return 0
+	}
+	if d.ri >= len(d.src) {
+		d.fail(f, tpdu.ErrUnderflow)
+		return 0
+	}
+	b := d.src[d.ri]
+	d.ri++
+	return b
+}
+
+func (d *decoder) uint32(f string) uint32 {
+	if d.err != nil {
+		return 0
+	}
+	if d.ri+4 > len(d.src) {
+		d.fail(f, tpdu.ErrUnderflow)
+		return 0
+	}
+	v := binary.BigEndian.Uint32(d.src[d.ri:])
+	d.ri += 4
+	return v
+}
+
+// cstring decodes a C-Octet String, with max being the maximum length
+// including the terminating NULL.
+func (d *decoder) cstring(f string, max int) string {
+	if d.err != nil {
+		return ""
+	}
+	for i := d.ri; i < len(d.src); i++ {
+		if d.src[i] == 0 {
+			if i-d.ri >= max {
+				d.fail(f, tpdu.ErrOverlength)
+				return ""
+			}
+			s := string(d.src[d.ri:i])
+			d.ri = i + 1
+			return s
+		}
+	}
+	d.fail(f, tpdu.ErrUnderflow)
+	return ""
+}
+
+func (d *decoder) address(f string, max int) Address {
+	a := Address{}
+	a.TON = d.byte(f + ".ton")
+	a.NPI = d.byte(f + ".npi")
+	a.Addr = d.cstring(f+".addr", max)
+	return a
+}
+
+func (d *decoder) shortMessage() []byte {
+	l := int(d.byte("sm_length"))
+	if d.err != nil {
+		return nil
+	}
+	if d.ri+l > len(d.src) {
+		d.fail("short_message", tpdu.ErrUnderflow)
+		return nil
+	}
+	if l == 0 {
+		return nil
+	}
+	sm := append([]byte(nil), d.src[d.ri:d.ri+l]...)
+	d.ri += l
+	return sm
+}
@@ -0,0 +1,391 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smpp"
+)
+
+type pduPattern struct {
+	name string
+	in   smpp.PDU
+	out  string
+}
+
+var pduPatterns = []pduPattern{
+	{
+		"enquire_link",
+		smpp.PDU{CommandID: smpp.EnquireLink, Sequence: 7},
+		"00000010" + "00000015" + "00000000" + "00000007",
+	},
+	{
+		"generic_nack",
+		smpp.PDU{CommandID: smpp.GenericNack, Status: smpp.StatusInvCmdID, Sequence: 1},
+		"00000010" + "80000000" + "00000003" + "00000001",
+	},
+	{
+		"bind_transceiver",
+		smpp.PDU{
+			CommandID:        smpp.BindTransceiver,
+			Sequence:         1,
+			SystemID:         "sys",
+			Password:         "pw",
+			SystemType:       "",
+			InterfaceVersion: smpp.InterfaceVersion,
+			AddressRange:     smpp.Address{TON: 1, NPI: 1},
+		},
+		"0000001c" + "00000009" + "00000000" + "00000001" +
+			"73797300" + "707700" + "00" + "34" + "0101" + "00",
+	},
+	{
+		"bind_transceiver_resp",
+		smpp.PDU{
+			CommandID: smpp.BindTransceiverResp,
+			Sequence:  1,
+			SystemID:  "smsc",
+			TLVs:      smpp.TLVs{smpp.NewTLV8(smpp.TagSCInterfaceVersion, 0x34)},
+		},
+		"0000001a" + "80000009" + "00000000" + "00000001" +
+			"736d736300" + "0210000134",
+	},
+	{
+		"outbind",
+		smpp.PDU{CommandID: smpp.Outbind, Sequence: 3, SystemID: "a", Password: "b"},
+		"00000014" + "0000000b" + "00000000" + "00000003" + "6100" + "6200",
+	},
+	{
+		"submit_sm",
+		smpp.PDU{
+			CommandID:          smpp.SubmitSM,
+			Sequence:           2,
+			Source:             smpp.Address{TON: 5, NPI: 0, Addr: "me"},
+			Dest:               smpp.Address{TON: 1, NPI: 1, Addr: "1234"},
+			ESMClass:           smpp.EsmUDHI,
+			ValidityPeriod:     "000001000000000R",
+			RegisteredDelivery: smpp.RegDeliveryReceipt,
+			DataCoding:         smpp.DataCodingUCS2,
+			ShortMessage:       []byte{0x05, 0x00, 0x03, 0x01, 0x02, 0x01, 0x00, 0x41},
+		},
+		"0000003f" + "00000004" + "00000000" + "00000002" +
+			"00" + "05006d6500" + "01013132333400" + "40" + "00" + "00" + "00" +
+			"30303030303130303030303030303052" + "00" +
+			"01" + "00" + "08" + "00" + "08" + "0500030102010041",
+	},
+	{
+		"submit_sm_resp",
+		smpp.PDU{CommandID: smpp.SubmitSMResp, Sequence: 2, MessageID: "abc"},
+		"00000014" + "80000004" + "00000000" + "00000002" + "61626300",
+	},
+	{
+		"deliver_sm",
+		smpp.PDU{
+			CommandID:    smpp.DeliverSM,
+			Sequence:     9,
+			Source:       smpp.Address{TON: 1, NPI: 1, Addr: "1234"},
+			Dest:         smpp.Address{TON: 0, NPI: 0, Addr: ""},
+			ShortMessage: []byte("hi"),
+			TLVs:         smpp.TLVs{smpp.NewTLVString(smpp.TagReceiptedMessageID, "x")},
+		},
+		"0000002d" + "00000005" + "00000000" + "00000009" +
+			"00" + "01013132333400" + "000000" + "00" + "00" + "00" + "00" + "00" +
+			"00" + "00" + "00" + "00" + "02" + "6869" + "001e00027800",
+	},
+	{
+		"deliver_sm_resp",
+		smpp.PDU{CommandID: smpp.DeliverSMResp, Sequence: 9},
+		"00000011" + "80000005" + "00000000" + "00000009" + "00",
+	},
+	{
+		"data_sm",
+		smpp.PDU{
+			CommandID:  smpp.DataSM,
+			Sequence:   4,
+			Dest:       smpp.Address{TON: 1, NPI: 1, Addr: "1"},
+			DataCoding: smpp.DataCoding8Bit,
+			TLVs:       smpp.TLVs{{smpp.TagMessagePayload, []byte{0xaa}}},
+		},
+		"00000020" + "00000103" + "00000000" + "00000004" +
+			"00" + "000000" + "01013100" + "00" + "00" + "04" + "04240001aa",
+	},
+	{
+		"query_sm",
+		smpp.PDU{
+			CommandID: smpp.QuerySM,
+			Sequence:  5,
+			MessageID: "id",
+			Source:    smpp.Address{TON: 1, NPI: 1, Addr: "1"},
+		},
+		"00000017" + "00000003" + "00000000" + "00000005" + "696400" + "01013100",
+	},
+	{
+		"query_sm_resp",
+		smpp.PDU{
+			CommandID:    smpp.QuerySMResp,
+			Sequence:     5,
+			MessageID:    "id",
+			MessageState: smpp.StateDelivered,
+			ErrorCode:    3,
+		},
+		"00000016" + "80000003" + "00000000" + "00000005" + "696400" + "00" + "02" + "03",
+	},
+	{
+		"cancel_sm",
+		smpp.PDU{
+			CommandID: smpp.CancelSM,
+			Sequence:  6,
+			MessageID: "id",
+			Source:    smpp.Address{TON: 1, NPI: 1, Addr: "1"},
+			Dest:      smpp.Address{TON: 1, NPI: 1, Addr: "2"},
+		},
+		"0000001c" + "00000008" + "00000000" + "00000006" +
+			"00" + "696400" + "01013100" + "01013200",
+	},
+	{
+		"replace_sm",
+		smpp.PDU{
+			CommandID:    smpp.ReplaceSM,
+			Sequence:     6,
+			MessageID:    "id",
+			Source:       smpp.Address{TON: 1, NPI: 1, Addr: "1"},
+			ShortMessage: []byte("x"),
+		},
+		"0000001d" + "00000007" + "00000000" + "00000006" +
+			"696400" + "01013100" + "00" + "00" + "00" + "00" + "01" + "78",
+	},
+	{
+		"submit_multi",
+		smpp.PDU{
+			CommandID: smpp.SubmitMulti,
+			Sequence:  8,
+			Dests: []smpp.DestAddress{
+				{Address: smpp.Address{TON: 1, NPI: 1, Addr: "1"}},
+				{DLName: "dl"},
+			},
+			ShortMessage: []byte("x"),
+		},
+		"00000029" + "00000021" + "00000000" + "00000008" +
+			"00" + "000000" + "02" + "01" + "01013100" + "02" + "646c00" +
+			"000000" + "00" + "00" + "00000000" + "01" + "78",
+	},
+	{
+		"submit_multi_resp",
+		smpp.PDU{
+			CommandID: smpp.SubmitMultiResp,
+			Sequence:  8,
+			MessageID: "m",
+			Unsuccess: []smpp.UnsuccessSME{
+				{Address: smpp.Address{TON: 1, NPI: 1, Addr: "1"}, Status: smpp.StatusInvDstAdr},
+			},
+		},
+		"0000001b" + "80000021" + "00000000" + "00000008" +
+			"6d00" + "01" + "01013100" + "0000000b",
+	},
+	{
+		"alert_notification",
+		smpp.PDU{
+			CommandID: smpp.AlertNotification,
+			Sequence:  10,
+			Source:    smpp.Address{TON: 1, NPI: 1, Addr: "1"},
+			ESME:      smpp.Address{TON: 1, NPI: 1, Addr: "2"},
+			TLVs:      smpp.TLVs{smpp.NewTLV8(smpp.TagMSAvailabilityStatus, 0)},
+		},
+		"0000001d" + "00000102" + "00000000" + "0000000a" +
+			"01013100" + "01013200" + "0422000100",
+	},
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, p := range pduPatterns {
+		f := func(t *testing.T) {
+			b, err := p.in.MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, p.out, hex.EncodeToString(b))
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	for _, p := range pduPatterns {
+		f := func(t *testing.T) {
+			b, err := hex.DecodeString(p.out)
+			require.Nil(t, err)
+			pdu := smpp.PDU{}
+			err = pdu.UnmarshalBinary(b)
+			require.Nil(t, err)
+			assert.Equal(t, p.in, pdu)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestMarshalBinaryError(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   smpp.PDU
+		err  error
+	}{
+		{
+			"unsupported",
+			smpp.PDU{CommandID: 0x42},
+			smpp.ErrUnsupportedCommand(0x42),
+		},
+		{
+			"long system_id",
+			smpp.PDU{CommandID: smpp.BindTransmitter, SystemID: "0123456789abcdef"},
+			tpdu.EncodeError("bind_transmitter", tpdu.EncodeError("system_id", tpdu.ErrOverlength)),
+		},
+		{
+			"long short_message",
+			smpp.PDU{CommandID: smpp.SubmitSM, ShortMessage: make([]byte, 255)},
+			tpdu.EncodeError("submit_sm", tpdu.EncodeError("short_message", tpdu.ErrOverlength)),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.in.MarshalBinary()
+			assert.Equal(t, p.err, err)
+			assert.Nil(t, b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUnmarshalBinaryError(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{
+			"empty",
+			"",
+			tpdu.NewDecodeError("header", 0, tpdu.ErrUnderflow),
+		},
+		{
+			"short length",
+			"0000000f" + "00000015" + "00000000" + "00000007",
+			tpdu.NewDecodeError("command_length", 0, smpp.ErrInvalidLength(15)),
+		},
+		{
+			"underflow",
+			"00000011" + "00000015" + "00000000" + "00000007",
+			tpdu.NewDecodeError("command_length", 0, tpdu.ErrUnderflow),
+		},
+		{
+			"overlength",
+			"00000010" + "00000015" + "00000000" + "00000007" + "00",
+			tpdu.NewDecodeError("command_length", 0, tpdu.ErrOverlength),
+		},
+		{
+			"unsupported",
+			"00000010" + "00000042" + "00000000" + "00000007",
+			tpdu.NewDecodeError("command_id", 4, smpp.ErrUnsupportedCommand(0x42)),
+		},
+		{
+			"unterminated",
+			"00000013" + "0000000b" + "00000000" + "00000007" + "616263",
+			tpdu.NewDecodeError("outbind", 0,
+				tpdu.NewDecodeError("system_id", 16, tpdu.ErrUnderflow)),
+		},
+		{
+			"short_message underflow",
+			"0000001d" + "00000007" + "00000000" + "00000006" +
+				"696400" + "01013100" + "00" + "00" + "00" + "00" + "02" + "78",
+			tpdu.NewDecodeError("replace_sm", 0,
+				tpdu.NewDecodeError("short_message", 28, tpdu.ErrUnderflow)),
+		},
+		{
+			"tlv underflow",
+			"00000013" + "80000015" + "00000000" + "00000007" + "042400",
+			tpdu.NewDecodeError("enquire_link_resp", 16,
+				tpdu.NewDecodeError("tlv", 0, tpdu.ErrUnderflow)),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := hex.DecodeString(p.in)
+			require.Nil(t, err)
+			pdu := smpp.PDU{}
+			err = pdu.UnmarshalBinary(b)
+			assert.Equal(t, p.err, err)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	req := smpp.PDU{CommandID: smpp.SubmitSM, Sequence: 42}
+	r := req.Response(smpp.StatusThrottled)
+	assert.Equal(t, smpp.SubmitSMResp, r.CommandID)
+	assert.Equal(t, smpp.StatusThrottled, r.Status)
+	assert.Equal(t, uint32(42), r.Sequence)
+
+	req = smpp.PDU{CommandID: smpp.Outbind, Sequence: 43}
+	r = req.Response(smpp.StatusOK)
+	assert.Equal(t, smpp.GenericNack, r.CommandID)
+}
+
+func TestReadWritePDU(t *testing.T) {
+	var buf bytes.Buffer
+	for _, p := range pduPatterns {
+		err := smpp.WritePDU(&buf, &p.in)
+		require.Nil(t, err)
+	}
+	for _, p := range pduPatterns {
+		pdu, err := smpp.ReadPDU(&buf)
+		require.Nil(t, err)
+		assert.Equal(t, p.in, *pdu)
+	}
+	pdu, err := smpp.ReadPDU(&buf)
+	assert.Equal(t, io.EOF, err)
+	assert.Nil(t, pdu)
+
+	b, _ := hex.DecodeString("00000011" + "00000015" + "00000000" + "00000007")
+	pdu, err = smpp.ReadPDU(bytes.NewReader(b))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Nil(t, pdu)
+
+	b, _ = hex.DecodeString("00000008" + "00000015" + "00000000" + "00000007")
+	pdu, err = smpp.ReadPDU(bytes.NewReader(b))
+	assert.Equal(t, smpp.ErrInvalidLength(8), err)
+	assert.Nil(t, pdu)
+
+	err = smpp.WritePDU(&buf, &smpp.PDU{CommandID: 0x42})
+	assert.Equal(t, smpp.ErrUnsupportedCommand(0x42), err)
+}
+
+func TestCommandIDString(t *testing.T) {
+	assert.Equal(t, "submit_sm", smpp.SubmitSM.String())
+	assert.Equal(t, "generic_nack", smpp.GenericNack.String())
+	assert.Equal(t, "0x00000042", smpp.CommandID(0x42).String())
+	assert.True(t, smpp.SubmitSMResp.IsResponse())
+	assert.False(t, smpp.SubmitSM.IsResponse())
+}
+
+func TestStatus(t *testing.T) {
+	assert.Equal(t, "ESME_RTHROTTLED", smpp.StatusThrottled.String())
+	assert.Equal(t, "0x00000400", smpp.Status(0x400).String())
+	assert.Equal(t, "smpp: command status ESME_RSYSERR", smpp.StatusSysErr.Error())
+}
+
+func TestESMClass(t *testing.T) {
+	e := smpp.EsmUDHI | smpp.EsmReplyPath | smpp.EsmTypeDeliveryReceipt | smpp.EsmModeDatagram
+	assert.True(t, e.UDHI())
+	assert.True(t, e.RP())
+	assert.Equal(t, smpp.EsmTypeDeliveryReceipt, e.MessageType())
+	assert.Equal(t, smpp.EsmModeDatagram, e.Mode())
+	e = 0
+	assert.False(t, e.UDHI())
+	assert.False(t, e.RP())
+}
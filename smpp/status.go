@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp
+
+import "fmt"
+
+// Status represents the command_status field, as defined in SMPP v3.4
+// Section 5.1.3.
+//
+// A non-zero Status is also an error, so a failed response can be returned
+// as is.
+type Status uint32
+
+const (
+	// StatusOK indicates no error (ESME_ROK).
+	StatusOK Status = 0x00000000
+
+	// StatusInvMsgLen indicates the message length is invalid
+	// (ESME_RINVMSGLEN).
+	StatusInvMsgLen Status = 0x00000001
+
+	// StatusInvCmdLen indicates the command length is invalid
+	// (ESME_RINVCMDLEN).
+	StatusInvCmdLen Status = 0x00000002
+
+	// StatusInvCmdID indicates an invalid command ID (ESME_RINVCMDID).
+	StatusInvCmdID Status = 0x00000003
+
+	// StatusInvBnd indicates the command is incorrect for the bind status
+	// (ESME_RINVBNDSTS).
+	StatusInvBnd Status = 0x00000004
+
+	// StatusAlyBnd indicates the ESME is already bound (ESME_RALYBND).
+	StatusAlyBnd Status = 0x00000005
+
+	// StatusInvPrtFlg indicates an invalid priority flag (ESME_RINVPRTFLG).
+	StatusInvPrtFlg Status = 0x00000006
+
+	// StatusInvRegDlvFlg indicates an invalid registered delivery flag
+	// (ESME_RINVREGDLVFLG).
+	StatusInvRegDlvFlg Status = 0x00000007
+
+	// StatusSysErr indicates a system error (ESME_RSYSERR).
+	StatusSysErr Status = 0x00000008
+
+	// StatusInvSrcAdr indicates an invalid source address
+	// (ESME_RINVSRCADR).
+	StatusInvSrcAdr Status = 0x0000000a
+
+	// StatusInvDstAdr indicates an invalid destination address
+	// (ESME_RINVDSTADR).
+	StatusInvDstAdr Status = 0x0000000b
+
+	// StatusInvMsgID indicates an invalid message ID (ESME_RINVMSGID).
+	StatusInvMsgID Status = 0x0000000c
+
+	// StatusBindFail indicates the bind failed (ESME_RBINDFAIL).
+	StatusBindFail Status = 0x0000000d
+
+	// StatusInvPaswd indicates an invalid password (ESME_RINVPASWD).
+	StatusInvPaswd Status = 0x0000000e
+
+	// StatusInvSysID indicates an invalid system ID (ESME_RINVSYSID).
+	StatusInvSysID Status = 0x0000000f
+
+	// StatusCancelFail indicates cancel_sm failed (ESME_RCANCELFAIL).
+	StatusCancelFail Status = 0x00000011
+
+	// StatusReplaceFail indicates replace_sm failed (ESME_RREPLACEFAIL).
+	StatusReplaceFail Status = 0x00000013
+
+	// StatusMsgQFul indicates the message queue is full (ESME_RMSGQFUL).
+	StatusMsgQFul Status = 0x00000014
+
+	// StatusInvSerTyp indicates an invalid service type (ESME_RINVSERTYP).
+	StatusInvSerTyp Status = 0x00000015
+
+	// StatusInvNumDests indicates an invalid number of destinations
+	// (ESME_RINVNUMDESTS).
+	StatusInvNumDests Status = 0x00000033
+
+	// StatusInvDLName indicates an invalid distribution list name
+	// (ESME_RINVDLNAME).
+	StatusInvDLName Status = 0x00000034
+
+	// StatusInvDestFlag indicates an invalid destination flag
+	// (ESME_RINVDESTFLAG).
+	StatusInvDestFlag Status = 0x00000040
+
+	// StatusInvSubRep indicates an invalid submit with replace request
+	// (ESME_RINVSUBREP).
+	StatusInvSubRep Status = 0x00000042
+
+	// StatusInvEsmClass indicates an invalid esm_class (ESME_RINVESMCLASS).
+	StatusInvEsmClass Status = 0x00000043
+
+	// StatusCntSubDL indicates a submit to a distribution list is not
+	// possible (ESME_RCNTSUBDL).
+	StatusCntSubDL Status = 0x00000044
+
+	// StatusSubmitFail indicates submit_sm or submit_multi failed
+	// (ESME_RSUBMITFAIL).
+	StatusSubmitFail Status = 0x00000045
+
+	// StatusInvSrcTON indicates an invalid source address TON
+	// (ESME_RINVSRCTON).
+	StatusInvSrcTON Status = 0x00000048
+
+	// StatusInvSrcNPI indicates an invalid source address NPI
+	// (ESME_RINVSRCNPI).
+	StatusInvSrcNPI Status = 0x00000049
+
+	// StatusInvDstTON indicates an invalid destination address TON
+	// (ESME_RINVDSTTON).
+	StatusInvDstTON Status = 0x00000050
+
+	// StatusInvDstNPI indicates an invalid destination address NPI
+	// (ESME_RINVDSTNPI).
+	StatusInvDstNPI Status = 0x00000051
+
+	// StatusInvSysTyp indicates an invalid system_type (ESME_RINVSYSTYP).
+	StatusInvSysTyp Status = 0x00000053
+
+	// StatusInvRepFlag indicates an invalid replace_if_present flag
+	// (ESME_RINVREPFLAG).
+	StatusInvRepFlag Status = 0x00000054
+
+	// StatusInvNumMsgs indicates an invalid number of messages
+	// (ESME_RINVNUMMSGS).
+	StatusInvNumMsgs Status = 0x00000055
+
+	// StatusThrottled indicates the ESME has exceeded the allowed message
+	// limits (ESME_RTHROTTLED).
+	StatusThrottled Status = 0x00000058
+
+	// StatusInvSched indicates an invalid scheduled delivery time
+	// (ESME_RINVSCHED).
+	StatusInvSched Status = 0x00000061
+
+	// StatusInvExpiry indicates an invalid validity period
+	// (ESME_RINVEXPIRY).
+	StatusInvExpiry Status = 0x00000062
+
+	// StatusInvDftMsgID indicates the predefined message is invalid or not
+	// found (ESME_RINVDFTMSGID).
+	StatusInvDftMsgID Status = 0x00000063
+
+	// StatusRxTAppn indicates an ESME receiver temporary application error
+	// (ESME_RX_T_APPN).
+	StatusRxTAppn Status = 0x00000064
+
+	// StatusRxPAppn indicates an ESME receiver permanent application error
+	// (ESME_RX_P_APPN).
+	StatusRxPAppn Status = 0x00000065
+
+	// StatusRxRAppn indicates an ESME receiver reject message error
+	// (ESME_RX_R_APPN).
+	StatusRxRAppn Status = 0x00000066
+
+	// StatusQueryFail indicates query_sm failed (ESME_RQUERYFAIL).
+	StatusQueryFail Status = 0x00000067
+
+	// StatusInvOptParStream indicates an error in the optional part of the
+	// PDU body (ESME_RINVOPTPARSTREAM).
+	StatusInvOptParStream Status = 0x000000c0
+
+	// StatusOptParNotAllwd indicates an optional parameter is not allowed
+	// (ESME_ROPTPARNOTALLWD).
+	StatusOptParNotAllwd Status = 0x000000c1
+
+	// StatusInvParLen indicates an invalid parameter length
+	// (ESME_RINVPARLEN).
+	StatusInvParLen Status = 0x000000c2
+
+	// StatusMissingOptParam indicates an expected optional parameter is
+	// missing (ESME_RMISSINGOPTPARAM).
+	StatusMissingOptParam Status = 0x000000c3
+
+	// StatusInvOptParamVal indicates an invalid optional parameter value
+	// (ESME_RINVOPTPARAMVAL).
+	StatusInvOptParamVal Status = 0x000000c4
+
+	// StatusDeliveryFailure indicates delivery failure, used for data_sm_resp
+	// (ESME_RDELIVERYFAILURE).
+	StatusDeliveryFailure Status = 0x000000fe
+
+	// StatusUnknownErr indicates an unknown error (ESME_RUNKNOWNERR).
+	StatusUnknownErr Status = 0x000000ff
+)
+
+var statusNames = map[Status]string{
+	StatusOK:              "ESME_ROK",
+	StatusInvMsgLen:       "ESME_RINVMSGLEN",
+	StatusInvCmdLen:       "ESME_RINVCMDLEN",
+	StatusInvCmdID:        "ESME_RINVCMDID",
+	StatusInvBnd:          "ESME_RINVBNDSTS",
+	StatusAlyBnd:          "ESME_RALYBND",
+	StatusInvPrtFlg:       "ESME_RINVPRTFLG",
+	StatusInvRegDlvFlg:    "ESME_RINVREGDLVFLG",
+	StatusSysErr:          "ESME_RSYSERR",
+	StatusInvSrcAdr:       "ESME_RINVSRCADR",
+	StatusInvDstAdr:       "ESME_RINVDSTADR",
+	StatusInvMsgID:        "ESME_RINVMSGID",
+	StatusBindFail:        "ESME_RBINDFAIL",
+	StatusInvPaswd:        "ESME_RINVPASWD",
+	StatusInvSysID:        "ESME_RINVSYSID",
+	StatusCancelFail:      "ESME_RCANCELFAIL",
+	StatusReplaceFail:     "ESME_RREPLACEFAIL",
+	StatusMsgQFul:         "ESME_RMSGQFUL",
+	StatusInvSerTyp:       "ESME_RINVSERTYP",
+	StatusInvNumDests:     "ESME_RINVNUMDESTS",
+	StatusInvDLName:       "ESME_RINVDLNAME",
+	StatusInvDestFlag:     "ESME_RINVDESTFLAG",
+	StatusInvSubRep:       "ESME_RINVSUBREP",
+	StatusInvEsmClass:     "ESME_RINVESMCLASS",
+	StatusCntSubDL:        "ESME_RCNTSUBDL",
+	StatusSubmitFail:      "ESME_RSUBMITFAIL",
+	StatusInvSrcTON:       "ESME_RINVSRCTON",
+	StatusInvSrcNPI:       "ESME_RINVSRCNPI",
+	StatusInvDstTON:       "ESME_RINVDSTTON",
+	StatusInvDstNPI:       "ESME_RINVDSTNPI",
+	StatusInvSysTyp:       "ESME_RINVSYSTYP",
+	StatusInvRepFlag:      "ESME_RINVREPFLAG",
+	StatusInvNumMsgs:      "ESME_RINVNUMMSGS",
+	StatusThrottled:       "ESME_RTHROTTLED",
+	StatusInvSched:        "ESME_RINVSCHED",
+	StatusInvExpiry:       "ESME_RINVEXPIRY",
+	StatusInvDftMsgID:     "ESME_RINVDFTMSGID",
+	StatusRxTAppn:         "ESME_RX_T_APPN",
+	StatusRxPAppn:         "ESME_RX_P_APPN",
+	StatusRxRAppn:         "ESME_RX_R_APPN",
+	StatusQueryFail:       "ESME_RQUERYFAIL",
+	StatusInvOptParStream: "ESME_RINVOPTPARSTREAM",
+	StatusOptParNotAllwd:  "ESME_ROPTPARNOTALLWD",
+	StatusInvParLen:       "ESME_RINVPARLEN",
+	StatusMissingOptParam: "ESME_RMISSINGOPTPARAM",
+	StatusInvOptParamVal:  "ESME_RINVOPTPARAMVAL",
+	StatusDeliveryFailure: "ESME_RDELIVERYFAILURE",
+	StatusUnknownErr:      "ESME_RUNKNOWNERR",
+}
+
+func (s Status) String() string {
+	if n, ok := statusNames[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%08x", uint32(s))
+}
+
+func (s Status) Error() string {
+	return fmt.Sprintf("smpp: command status %s", s.String())
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Time represents a time field in SMPP time format, as defined in SMPP v3.4
+// Section 7.1.1.
+//
+// The time may be either absolute, "YYMMDDhhmmsstnnp", or relative,
+// "YYMMDDhhmmss000R".
+type Time struct {
+	// Time is the absolute time.
+	//
+	// Only applies if Relative is false.
+	Time time.Time
+
+	// Duration is the relative time.
+	//
+	// Only applies if Relative is true.
+	Duration time.Duration
+
+	// Relative indicates the time is relative rather than absolute.
+	Relative bool
+}
+
+// IsZero returns true if the time is unset, and so will be encoded as an
+// empty string.
+func (t Time) IsZero() bool {
+	if t.Relative {
+		return t.Duration == 0
+	}
+	return t.Time.IsZero()
+}
+
+// String returns the time in SMPP time format.
+//
+// A zero time returns the empty string.
+func (t Time) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Relative {
+		return formatRelative(t.Duration)
+	}
+	return formatAbsolute(t.Time)
+}
+
+// ParseTime parses a time in SMPP time format.
+//
+// An empty string returns a zero Time.
+func ParseTime(s string) (Time, error) {
+	if len(s) == 0 {
+		return Time{}, nil
+	}
+	if len(s) != 16 {
+		return Time{}, ErrInvalidTime(s)
+	}
+	f := make([]int, 8)
+	for i := range f[:6] {
+		v, err := strconv.Atoi(s[i*2 : i*2+2])
+		if err != nil || v < 0 {
+			return Time{}, ErrInvalidTime(s)
+		}
+		f[i] = v
+	}
+	tenths, err := strconv.Atoi(s[12:13])
+	if err != nil || tenths < 0 {
+		return Time{}, ErrInvalidTime(s)
+	}
+	nn, err := strconv.Atoi(s[13:15])
+	if err != nil || nn < 0 || nn > 48 {
+		return Time{}, ErrInvalidTime(s)
+	}
+	switch s[15] {
+	case 'R':
+		d := time.Duration(f[0])*365*24*time.Hour +
+			time.Duration(f[1])*30*24*time.Hour +
+			time.Duration(f[2])*24*time.Hour +
+			time.Duration(f[3])*time.Hour +
+			time.Duration(f[4])*time.Minute +
+			time.Duration(f[5])*time.Second
+		return Time{Duration: d, Relative: true}, nil
+	case '+', '-':
+		offset := nn * 15 * 60
+		if s[15] == '-' {
+			offset = -offset
+		}
+		loc := time.UTC
+		if offset != 0 {
+			loc = time.FixedZone("SMPP", offset)
+		}
+		if f[1] < 1 || f[1] > 12 || f[2] < 1 || f[2] > 31 ||
+			f[3] > 23 || f[4] > 59 || f[5] > 59 {
+			return Time{}, ErrInvalidTime(s)
+		}
+		t := time.Date(2000+f[0], time.Month(f[1]), f[2], f[3], f[4], f[5],
+			tenths*int(time.Second/10), loc)
+		return Time{Time: t}, nil
+	}
+	return Time{}, ErrInvalidTime(s)
+}
+
+func formatAbsolute(t time.Time) string {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("%02d%02d%02d%02d%02d%02d%d%02d%c",
+		t.Year()%100, int(t.Month()), t.Day(),
+		t.Hour(), t.Minute(), t.Second(),
+		t.Nanosecond()/int(time.Second/10),
+		offset/(15*60), sign)
+}
+
+func formatRelative(d time.Duration) string {
+	day := 24 * time.Hour
+	years := d / (365 * day)
+	d -= years * 365 * day
+	months := d / (30 * day)
+	d -= months * 30 * day
+	days := d / day
+	d -= days * day
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	mins := d / time.Minute
+	d -= mins * time.Minute
+	secs := d / time.Second
+	if years > 99 {
+		years = 99
+	}
+	return fmt.Sprintf("%02d%02d%02d%02d%02d%02d000R",
+		years, months, days, hours, mins, secs)
+}
+
+// FormatValidityPeriod converts a TPDU validity period into the
+// corresponding SMPP time string.
+//
+// Relative and enhanced formats are converted to relative times, and the
+// absolute format to an absolute time.
+// A VP that is not present returns an empty string.
+func FormatValidityPeriod(vp tpdu.ValidityPeriod) (string, error) {
+	switch vp.Format {
+	case tpdu.VpfNotPresent:
+		return "", nil
+	case tpdu.VpfAbsolute:
+		return Time{Time: vp.Time.Time}.String(), nil
+	case tpdu.VpfRelative, tpdu.VpfEnhanced:
+		return Time{Duration: vp.Duration, Relative: true}.String(), nil
+	}
+	return "", tpdu.EncodeError("vpf", tpdu.ErrInvalid)
+}
+
+// ParseValidityPeriod converts an SMPP time string into the corresponding
+// TPDU validity period.
+//
+// An empty string returns a VP that is not present.
+func ParseValidityPeriod(s string) (tpdu.ValidityPeriod, error) {
+	vp := tpdu.ValidityPeriod{}
+	t, err := ParseTime(s)
+	if err != nil {
+		return vp, err
+	}
+	if t.IsZero() {
+		return vp, nil
+	}
+	if t.Relative {
+		vp.SetRelative(t.Duration)
+	} else {
+		vp.SetAbsolute(tpdu.Timestamp{Time: t.Time})
+	}
+	return vp, nil
+}
@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smpp"
+)
+
+func TestParseTime(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   string
+		out  smpp.Time
+		err  error
+	}{
+		{"empty", "", smpp.Time{}, nil},
+		{
+			"absolute utc",
+			"200412103015200+",
+			smpp.Time{Time: time.Date(2020, time.April, 12, 10, 30, 15, 200000000, time.UTC)},
+			nil,
+		},
+		{
+			"absolute east",
+			"200412103015032+",
+			smpp.Time{Time: time.Date(2020, time.April, 12, 10, 30, 15, 0, time.FixedZone("SMPP", 8*3600))},
+			nil,
+		},
+		{
+			"absolute west",
+			"200412103015020-",
+			smpp.Time{Time: time.Date(2020, time.April, 12, 10, 30, 15, 0, time.FixedZone("SMPP", -5*3600))},
+			nil,
+		},
+		{
+			"relative",
+			"000102030405000R",
+			smpp.Time{
+				Duration: 30*24*time.Hour + 2*24*time.Hour + 3*time.Hour + 4*time.Minute + 5*time.Second,
+				Relative: true,
+			},
+			nil,
+		},
+		{"short", "20041210301520+", smpp.Time{}, smpp.ErrInvalidTime("20041210301520+")},
+		{"bad digit", "2004121030152x0+", smpp.Time{}, smpp.ErrInvalidTime("2004121030152x0+")},
+		{"bad indicator", "200412103015200x", smpp.Time{}, smpp.ErrInvalidTime("200412103015200x")},
+		{"bad month", "201312103015200+", smpp.Time{}, smpp.ErrInvalidTime("201312103015200+")},
+		{"bad offset", "200412103015249+", smpp.Time{}, smpp.ErrInvalidTime("200412103015249+")},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			st, err := smpp.ParseTime(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, st)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestTimeString(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   smpp.Time
+		out  string
+	}{
+		{"zero", smpp.Time{}, ""},
+		{"zero relative", smpp.Time{Relative: true}, ""},
+		{
+			"absolute",
+			smpp.Time{Time: time.Date(2020, time.April, 12, 10, 30, 15, 200000000, time.UTC)},
+			"200412103015200+",
+		},
+		{
+			"absolute west",
+			smpp.Time{Time: time.Date(2020, time.April, 12, 10, 30, 15, 0, time.FixedZone("x", -5*3600))},
+			"200412103015020-",
+		},
+		{
+			"relative",
+			smpp.Time{Duration: 400*24*time.Hour + 90*time.Minute, Relative: true},
+			"010105013000000R",
+		},
+		{
+			"relative overflow",
+			smpp.Time{Duration: 200 * 365 * 24 * time.Hour, Relative: true},
+			"990000000000000R",
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			assert.Equal(t, p.out, p.in.String())
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestValidityPeriod(t *testing.T) {
+	abs := tpdu.ValidityPeriod{}
+	abs.SetAbsolute(tpdu.Timestamp{Time: time.Date(2020, time.April, 12, 10, 30, 15, 0, time.FixedZone("SMPP", 8*3600))})
+	rel := tpdu.ValidityPeriod{}
+	rel.SetRelative(2 * time.Hour)
+	enh := tpdu.ValidityPeriod{}
+	enh.SetEnhanced(2*time.Hour, 0x03)
+	patterns := []struct {
+		name string
+		in   tpdu.ValidityPeriod
+		s    string
+		out  tpdu.ValidityPeriod
+	}{
+		{"not present", tpdu.ValidityPeriod{}, "", tpdu.ValidityPeriod{}},
+		{"absolute", abs, "200412103015032+", abs},
+		{"relative", rel, "000000020000000R", rel},
+		{"enhanced", enh, "000000020000000R", rel},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			s, err := smpp.FormatValidityPeriod(p.in)
+			require.Nil(t, err)
+			assert.Equal(t, p.s, s)
+			vp, err := smpp.ParseValidityPeriod(s)
+			require.Nil(t, err)
+			assert.Equal(t, p.out, vp)
+		}
+		t.Run(p.name, f)
+	}
+	_, err := smpp.FormatValidityPeriod(tpdu.ValidityPeriod{Format: 7})
+	assert.Equal(t, tpdu.EncodeError("vpf", tpdu.ErrInvalid), err)
+	_, err = smpp.ParseValidityPeriod("banana")
+	assert.Equal(t, smpp.ErrInvalidTime("banana"), err)
+}
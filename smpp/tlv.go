@@ -0,0 +1,279 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp
+
+import (
+	"encoding/binary"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Tag identifies an optional parameter, as defined in SMPP v3.4 Section 5.3.2.
+type Tag uint16
+
+const (
+	// TagDestAddrSubunit is the dest_addr_subunit tag.
+	TagDestAddrSubunit Tag = 0x0005
+
+	// TagDestNetworkType is the dest_network_type tag.
+	TagDestNetworkType Tag = 0x0006
+
+	// TagDestBearerType is the dest_bearer_type tag.
+	TagDestBearerType Tag = 0x0007
+
+	// TagDestTelematicsID is the dest_telematics_id tag.
+	TagDestTelematicsID Tag = 0x0008
+
+	// TagSourceAddrSubunit is the source_addr_subunit tag.
+	TagSourceAddrSubunit Tag = 0x000d
+
+	// TagSourceNetworkType is the source_network_type tag.
+	TagSourceNetworkType Tag = 0x000e
+
+	// TagSourceBearerType is the source_bearer_type tag.
+	TagSourceBearerType Tag = 0x000f
+
+	// TagSourceTelematicsID is the source_telematics_id tag.
+	TagSourceTelematicsID Tag = 0x0010
+
+	// TagQOSTimeToLive is the qos_time_to_live tag.
+	TagQOSTimeToLive Tag = 0x0017
+
+	// TagPayloadType is the payload_type tag.
+	TagPayloadType Tag = 0x0019
+
+	// TagAdditionalStatusInfoText is the additional_status_info_text tag.
+	TagAdditionalStatusInfoText Tag = 0x001d
+
+	// TagReceiptedMessageID is the receipted_message_id tag.
+	TagReceiptedMessageID Tag = 0x001e
+
+	// TagMSMsgWaitFacilities is the ms_msg_wait_facilities tag.
+	TagMSMsgWaitFacilities Tag = 0x0030
+
+	// TagPrivacyIndicator is the privacy_indicator tag.
+	TagPrivacyIndicator Tag = 0x0201
+
+	// TagSourceSubaddress is the source_subaddress tag.
+	TagSourceSubaddress Tag = 0x0202
+
+	// TagDestSubaddress is the dest_subaddress tag.
+	TagDestSubaddress Tag = 0x0203
+
+	// TagUserMessageReference is the user_message_reference tag.
+	TagUserMessageReference Tag = 0x0204
+
+	// TagUserResponseCode is the user_response_code tag.
+	TagUserResponseCode Tag = 0x0205
+
+	// TagSourcePort is the source_port tag.
+	TagSourcePort Tag = 0x020a
+
+	// TagDestinationPort is the destination_port tag.
+	TagDestinationPort Tag = 0x020b
+
+	// TagSarMsgRefNum is the sar_msg_ref_num tag.
+	TagSarMsgRefNum Tag = 0x020c
+
+	// TagLanguageIndicator is the language_indicator tag.
+	TagLanguageIndicator Tag = 0x020d
+
+	// TagSarTotalSegments is the sar_total_segments tag.
+	TagSarTotalSegments Tag = 0x020e
+
+	// TagSarSegmentSeqnum is the sar_segment_seqnum tag.
+	TagSarSegmentSeqnum Tag = 0x020f
+
+	// TagSCInterfaceVersion is the sc_interface_version tag.
+	TagSCInterfaceVersion Tag = 0x0210
+
+	// TagCallbackNumPresInd is the callback_num_pres_ind tag.
+	TagCallbackNumPresInd Tag = 0x0302
+
+	// TagCallbackNumAtag is the callback_num_atag tag.
+	TagCallbackNumAtag Tag = 0x0303
+
+	// TagNumberOfMessages is the number_of_messages tag.
+	TagNumberOfMessages Tag = 0x0304
+
+	// TagCallbackNum is the callback_num tag.
+	TagCallbackNum Tag = 0x0381
+
+	// TagDpfResult is the dpf_result tag.
+	TagDpfResult Tag = 0x0420
+
+	// TagSetDpf is the set_dpf tag.
+	TagSetDpf Tag = 0x0421
+
+	// TagMSAvailabilityStatus is the ms_availability_status tag.
+	TagMSAvailabilityStatus Tag = 0x0422
+
+	// TagNetworkErrorCode is the network_error_code tag.
+	TagNetworkErrorCode Tag = 0x0423
+
+	// TagMessagePayload is the message_payload tag.
+	TagMessagePayload Tag = 0x0424
+
+	// TagDeliveryFailureReason is the delivery_failure_reason tag.
+	TagDeliveryFailureReason Tag = 0x0425
+
+	// TagMoreMessagesToSend is the more_messages_to_send tag.
+	TagMoreMessagesToSend Tag = 0x0426
+
+	// TagMessageState is the message_state tag.
+	TagMessageState Tag = 0x0427
+
+	// TagUSSDServiceOp is the ussd_service_op tag.
+	TagUSSDServiceOp Tag = 0x0501
+
+	// TagDisplayTime is the display_time tag.
+	TagDisplayTime Tag = 0x1201
+
+	// TagSMSSignal is the sms_signal tag.
+	TagSMSSignal Tag = 0x1203
+
+	// TagMSValidity is the ms_validity tag.
+	TagMSValidity Tag = 0x1204
+
+	// TagAlertOnMessageDelivery is the alert_on_message_delivery tag.
+	TagAlertOnMessageDelivery Tag = 0x130c
+
+	// TagITSReplyType is the its_reply_type tag.
+	TagITSReplyType Tag = 0x1380
+
+	// TagITSSessionInfo is the its_session_info tag.
+	TagITSSessionInfo Tag = 0x1383
+)
+
+// TLV represents an optional parameter.
+type TLV struct {
+	Tag   Tag
+	Value []byte
+}
+
+// TLVs is the set of optional parameters contained in a PDU.
+type TLVs []TLV
+
+// NewTLV8 creates a TLV containing a single octet integer value.
+func NewTLV8(t Tag, v byte) TLV {
+	return TLV{t, []byte{v}}
+}
+
+// NewTLV16 creates a TLV containing a two octet integer value.
+func NewTLV16(t Tag, v uint16) TLV {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return TLV{t, b}
+}
+
+// NewTLVString creates a TLV containing a C-Octet String value.
+func NewTLVString(t Tag, s string) TLV {
+	b := make([]byte, 0, len(s)+1)
+	b = append(b, s...)
+	b = append(b, 0)
+	return TLV{t, b}
+}
+
+// Uint returns the value of the TLV interpreted as a big endian integer.
+//
+// The value must be 1, 2 or 4 octets long.
+func (t TLV) Uint() (uint32, bool) {
+	switch len(t.Value) {
+	case 1:
+		return uint32(t.Value[0]), true
+	case 2:
+		return uint32(binary.BigEndian.Uint16(t.Value)), true
+	case 4:
+		return binary.BigEndian.Uint32(t.Value), true
+	}
+	return 0, false
+}
+
+// CString returns the value of the TLV interpreted as a C-Octet String.
+//
+// A missing NULL terminator is tolerated.
+func (t TLV) CString() string {
+	v := t.Value
+	for i, c := range v {
+		if c == 0 {
+			return string(v[:i])
+		}
+	}
+	return string(v)
+}
+
+// Get returns the last instance of the TLV with the given tag.
+//
+// If no such TLV is found then the function returns false.
+func (tlvs TLVs) Get(t Tag) (TLV, bool) {
+	for i := len(tlvs) - 1; i >= 0; i-- {
+		if tlvs[i].Tag == t {
+			return tlvs[i], true
+		}
+	}
+	return TLV{}, false
+}
+
+// Set replaces any existing TLVs with the tag of the provided TLV with the
+// provided TLV.
+func (tlvs *TLVs) Set(tlv TLV) {
+	tlvs.Del(tlv.Tag)
+	*tlvs = append(*tlvs, tlv)
+}
+
+// Del removes all TLVs with the given tag.
+func (tlvs *TLVs) Del(t Tag) {
+	var n TLVs
+	for _, tlv := range *tlvs {
+		if tlv.Tag != t {
+			n = append(n, tlv)
+		}
+	}
+	*tlvs = n
+}
+
+// MarshalBinary marshals the TLVs into binary.
+func (tlvs TLVs) MarshalBinary() ([]byte, error) {
+	if len(tlvs) == 0 {
+		return nil, nil
+	}
+	l := 0
+	for _, tlv := range tlvs {
+		if len(tlv.Value) > 0xffff {
+			return nil, tpdu.EncodeError("tlv", tpdu.ErrOverlength)
+		}
+		l += 4 + len(tlv.Value)
+	}
+	b := make([]byte, 0, l)
+	for _, tlv := range tlvs {
+		b = append(b, byte(tlv.Tag>>8), byte(tlv.Tag))
+		b = append(b, byte(len(tlv.Value)>>8), byte(len(tlv.Value)))
+		b = append(b, tlv.Value...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary unmarshals the TLVs from binary.
+//
+// The src is expected to contain only TLVs.
+func (tlvs *TLVs) UnmarshalBinary(src []byte) error {
+	var n TLVs
+	ri := 0
+	for ri < len(src) {
+		if len(src) < ri+4 {
+			return tpdu.NewDecodeError("tlv", ri, tpdu.ErrUnderflow)
+		}
+		t := Tag(binary.BigEndian.Uint16(src[ri:]))
+		l := int(binary.BigEndian.Uint16(src[ri+2:]))
+		if len(src) < ri+4+l {
+			return tpdu.NewDecodeError("tlv", ri, tpdu.ErrUnderflow)
+		}
+		v := append([]byte(nil), src[ri+4:ri+4+l]...)
+		n = append(n, TLV{t, v})
+		ri += 4 + l
+	}
+	*tlvs = n
+	return nil
+}
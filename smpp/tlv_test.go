@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smpp"
+)
+
+func TestTLVsMarshalBinary(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   smpp.TLVs
+		out  []byte
+		err  error
+	}{
+		{"nil", nil, nil, nil},
+		{
+			"one",
+			smpp.TLVs{smpp.NewTLV16(smpp.TagSarMsgRefNum, 0x1234)},
+			[]byte{0x02, 0x0c, 0x00, 0x02, 0x12, 0x34},
+			nil,
+		},
+		{
+			"two",
+			smpp.TLVs{
+				smpp.NewTLV8(smpp.TagSarTotalSegments, 3),
+				smpp.NewTLVString(smpp.TagReceiptedMessageID, "ab"),
+			},
+			[]byte{0x02, 0x0e, 0x00, 0x01, 0x03, 0x00, 0x1e, 0x00, 0x03, 'a', 'b', 0},
+			nil,
+		},
+		{
+			"empty value",
+			smpp.TLVs{{smpp.TagAlertOnMessageDelivery, nil}},
+			[]byte{0x13, 0x0c, 0x00, 0x00},
+			nil,
+		},
+		{
+			"overlength",
+			smpp.TLVs{{smpp.TagMessagePayload, make([]byte, 0x10000)}},
+			nil,
+			tpdu.EncodeError("tlv", tpdu.ErrOverlength),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			b, err := p.in.MarshalBinary()
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestTLVsUnmarshalBinary(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   []byte
+		out  smpp.TLVs
+		err  error
+	}{
+		{"nil", nil, nil, nil},
+		{
+			"two",
+			[]byte{0x02, 0x0e, 0x00, 0x01, 0x03, 0x00, 0x1e, 0x00, 0x03, 'a', 'b', 0},
+			smpp.TLVs{
+				smpp.NewTLV8(smpp.TagSarTotalSegments, 3),
+				smpp.NewTLVString(smpp.TagReceiptedMessageID, "ab"),
+			},
+			nil,
+		},
+		{
+			"short header",
+			[]byte{0x02, 0x0e, 0x00},
+			nil,
+			tpdu.NewDecodeError("tlv", 0, tpdu.ErrUnderflow),
+		},
+		{
+			"short value",
+			[]byte{0x02, 0x0e, 0x00, 0x01, 0x03, 0x00, 0x1e, 0x00, 0x03, 'a'},
+			nil,
+			tpdu.NewDecodeError("tlv", 5, tpdu.ErrUnderflow),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			var tlvs smpp.TLVs
+			err := tlvs.UnmarshalBinary(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, tlvs)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestTLVsGetSetDel(t *testing.T) {
+	var tlvs smpp.TLVs
+	_, ok := tlvs.Get(smpp.TagSourcePort)
+	assert.False(t, ok)
+
+	tlvs.Set(smpp.NewTLV16(smpp.TagSourcePort, 1))
+	tlvs.Set(smpp.NewTLV16(smpp.TagDestinationPort, 2))
+	tlvs.Set(smpp.NewTLV16(smpp.TagSourcePort, 3))
+	assert.Equal(t, 2, len(tlvs))
+	tlv, ok := tlvs.Get(smpp.TagSourcePort)
+	assert.True(t, ok)
+	v, ok := tlv.Uint()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(3), v)
+
+	tlvs.Del(smpp.TagSourcePort)
+	_, ok = tlvs.Get(smpp.TagSourcePort)
+	assert.False(t, ok)
+	assert.Equal(t, 1, len(tlvs))
+}
+
+func TestTLVValues(t *testing.T) {
+	v, ok := smpp.NewTLV8(smpp.TagMessageState, 2).Uint()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(2), v)
+
+	v, ok = smpp.TLV{smpp.TagQOSTimeToLive, []byte{1, 2, 3, 4}}.Uint()
+	assert.True(t, ok)
+	assert.Equal(t, uint32(0x01020304), v)
+
+	_, ok = smpp.TLV{smpp.TagQOSTimeToLive, []byte{1, 2, 3}}.Uint()
+	assert.False(t, ok)
+
+	assert.Equal(t, "abc", smpp.NewTLVString(smpp.TagReceiptedMessageID, "abc").CString())
+	assert.Equal(t, "abc", smpp.TLV{smpp.TagReceiptedMessageID, []byte("abc")}.CString())
+}
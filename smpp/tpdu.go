@@ -0,0 +1,336 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp
+
+import (
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+// SMPP data_coding values, as defined in SMPP v3.4 Section 5.2.19.
+const (
+	// DataCodingDefault indicates the SMSC default alphabet, taken to be the
+	// GSM7 default alphabet.
+	DataCodingDefault = 0x00
+
+	// DataCodingIA5 indicates IA5 (CCITT T.50)/ASCII.
+	DataCodingIA5 = 0x01
+
+	// DataCodingBinary indicates 8bit binary (octet unspecified).
+	DataCodingBinary = 0x02
+
+	// DataCodingLatin1 indicates Latin 1 (ISO-8859-1).
+	DataCodingLatin1 = 0x03
+
+	// DataCoding8Bit indicates 8bit binary (octet unspecified).
+	DataCoding8Bit = 0x04
+
+	// DataCodingUCS2 indicates UCS2 (ISO/IEC-10646).
+	DataCodingUCS2 = 0x08
+)
+
+// DataCodingFromDCS returns the SMPP data_coding corresponding to the TPDU
+// DCS.
+//
+// The GSM message waiting and message class coding groups (0xc0-0xff) are
+// shared by both and are passed through unaltered.
+// The general data coding groups are mapped to the equivalent alphabet. The
+// message class, if any, is preserved for the 7bit and 8bit alphabets, but
+// is lost for UCS2 as SMPP has no way to encode that combination.
+func DataCodingFromDCS(dcs tpdu.DCS) (byte, error) {
+	if dcs >= 0xc0 {
+		return byte(dcs), nil
+	}
+	if dcs&0x80 != 0 || dcs.Compressed() {
+		return 0, ErrUnsupportedDataCoding(dcs)
+	}
+	alpha, _ := dcs.Alphabet()
+	if alpha == tpdu.AlphaUCS2 {
+		return DataCodingUCS2, nil
+	}
+	if dcs&0x10 != 0 {
+		// preserve message class
+		return 0xf0 | byte(alpha)<<2 | byte(dcs&0x03), nil
+	}
+	if alpha == tpdu.Alpha8Bit {
+		return DataCoding8Bit, nil
+	}
+	return DataCodingDefault, nil
+}
+
+// DCSFromDataCoding returns the TPDU DCS corresponding to the SMPP
+// data_coding.
+//
+// The IA5 and Latin1 data_codings return a 7bit DCS, but the corresponding
+// short message is text and must be converted to GSM7 or UCS2. This is
+// performed by PDU.TPDU.
+func DCSFromDataCoding(dc byte) (tpdu.DCS, error) {
+	switch dc {
+	case DataCodingDefault, DataCodingIA5, DataCodingLatin1:
+		return 0x00, nil
+	case DataCodingBinary, DataCoding8Bit:
+		return tpdu.Dcs8BitData, nil
+	case DataCodingUCS2:
+		return tpdu.DcsUCS2Data, nil
+	}
+	if dc >= 0xc0 {
+		return tpdu.DCS(dc), nil
+	}
+	return 0, ErrUnsupportedDataCoding(dc)
+}
+
+// AddressFromTPDU returns the SMPP Address corresponding to the TPDU Address.
+func AddressFromTPDU(a tpdu.Address) Address {
+	return Address{
+		TON:  byte(a.TypeOfNumber()),
+		NPI:  byte(a.NumberingPlan()),
+		Addr: a.Addr,
+	}
+}
+
+// TPDUAddress returns the TPDU Address corresponding to the SMPP Address.
+//
+// The SMPP TON and NPI share the TS 23.040 values, so are copied as is.
+// Any '+' prefix on an international number is dropped.
+func (a Address) TPDUAddress() tpdu.Address {
+	ta := tpdu.NewAddress()
+	ta.SetTypeOfNumber(tpdu.TypeOfNumber(a.TON))
+	ta.SetNumberingPlan(tpdu.NumberingPlan(a.NPI))
+	addr := a.Addr
+	if len(addr) > 0 && addr[0] == '+' {
+		addr = addr[1:]
+	}
+	ta.Addr = addr
+	return ta
+}
+
+// FromTPDU creates the SMPP PDU corresponding to the TPDU.
+//
+// SMS-SUBMIT TPDUs are converted to submit_sm, and SMS-DELIVER TPDUs to
+// deliver_sm.  Other TPDU types are not supported.
+//
+// The short message contains the UDH, if any, followed by the UD, and the
+// esm_class UDHI bit indicates the presence of the UDH.  GSM7 UD is not
+// packed, but provided as septets, one per octet, as per the SMSC default
+// alphabet.  If the short message is too long for the short_message field it
+// is placed in a message_payload TLV instead.
+//
+// The TP-MR of an SMS-SUBMIT is carried in a user_message_reference TLV.
+//
+// The Sequence is left zeroed and must be set by the caller.
+func FromTPDU(t *tpdu.TPDU) (*PDU, error) {
+	p := PDU{}
+	switch t.SmsType() {
+	case tpdu.SmsSubmit:
+		p.CommandID = SubmitSM
+		p.Dest = AddressFromTPDU(t.DA)
+		vp, err := FormatValidityPeriod(t.VP)
+		if err != nil {
+			return nil, err
+		}
+		p.ValidityPeriod = vp
+		if t.FirstOctet.SRR() {
+			p.RegisteredDelivery = RegDeliveryReceipt
+		}
+		if t.MR != 0 {
+			p.TLVs.Set(NewTLV16(TagUserMessageReference, uint16(t.MR)))
+		}
+	case tpdu.SmsDeliver:
+		p.CommandID = DeliverSM
+		p.Source = AddressFromTPDU(t.OA)
+	default:
+		return nil, ErrUnsupportedSmsType
+	}
+	dc, err := DataCodingFromDCS(t.DCS)
+	if err != nil {
+		return nil, err
+	}
+	p.DataCoding = dc
+	p.ProtocolID = t.PID
+	if t.FirstOctet.RP() {
+		p.ESMClass |= EsmReplyPath
+	}
+	sm := []byte(t.UD)
+	if len(t.UDH) > 0 {
+		p.ESMClass |= EsmUDHI
+		udh, err := t.UDH.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		sm = append(udh, t.UD...)
+	}
+	if len(sm) > 254 {
+		p.TLVs.Set(TLV{TagMessagePayload, sm})
+	} else if len(sm) > 0 {
+		p.ShortMessage = sm
+	}
+	return &p, nil
+}
+
+// TPDU creates the TPDU corresponding to a submit_sm or deliver_sm PDU.
+//
+// This is the reverse of FromTPDU and FromStatusReport.
+// A deliver_sm containing a delivery receipt is converted to the equivalent
+// SMS-STATUS-REPORT.
+// The short message may be provided in either the short_message field or a
+// message_payload TLV.
+// Short messages with IA5 or Latin1 data_coding are converted to GSM7 if
+// possible, else UCS2.
+func (p *PDU) TPDU() (*tpdu.TPDU, error) {
+	var t *tpdu.TPDU
+	switch p.CommandID {
+	case SubmitSM:
+		t, _ = tpdu.NewSubmit()
+		t.DA = p.Dest.TPDUAddress()
+		vp, err := ParseValidityPeriod(p.ValidityPeriod)
+		if err != nil {
+			return nil, tpdu.NewDecodeError("validity_period", 0, err)
+		}
+		t.SetVP(vp)
+		if p.RegisteredDelivery&RegDeliveryReceiptMask != 0 {
+			t.FirstOctet |= tpdu.FoSRR
+		}
+		if tlv, ok := p.TLVs.Get(TagUserMessageReference); ok {
+			mr, _ := tlv.Uint()
+			t.MR = byte(mr)
+		}
+	case DeliverSM:
+		if p.ESMClass.MessageType() == EsmTypeDeliveryReceipt {
+			return p.statusReport()
+		}
+		t, _ = tpdu.NewDeliver()
+		t.OA = p.Source.TPDUAddress()
+	default:
+		return nil, ErrUnsupportedCommand(p.CommandID)
+	}
+	dcs, err := DCSFromDataCoding(p.DataCoding)
+	if err != nil {
+		return nil, err
+	}
+	t.DCS = dcs
+	t.PID = p.ProtocolID
+	if p.ESMClass.RP() {
+		t.FirstOctet |= tpdu.FoRP
+	}
+	sm := p.ShortMessage
+	if len(sm) == 0 {
+		if tlv, ok := p.TLVs.Get(TagMessagePayload); ok {
+			sm = tlv.Value
+		}
+	}
+	if p.ESMClass.UDHI() {
+		var udh tpdu.UserDataHeader
+		n, err := udh.UnmarshalBinary(sm)
+		if err != nil {
+			return nil, tpdu.NewDecodeError("short_message", 0, err)
+		}
+		t.SetUDH(udh)
+		sm = sm[n:]
+	}
+	switch p.DataCoding {
+	case DataCodingIA5, DataCodingLatin1:
+		t.DCS, sm = textToUD(sm)
+	case DataCodingUCS2:
+		if len(sm)&0x01 == 0x01 {
+			return nil, tpdu.NewDecodeError("short_message", 0, tpdu.ErrOddUCS2Length)
+		}
+	}
+	if len(sm) > 0 {
+		t.UD = append([]byte(nil), sm...)
+	}
+	return t, nil
+}
+
+// textToUD converts IA5 or Latin1 text into GSM7 septets, or UCS2 if that is
+// not possible.
+func textToUD(sm []byte) (tpdu.DCS, []byte) {
+	r := make([]rune, len(sm))
+	for i, c := range sm {
+		// IA5 is a subset of Latin1, which maps directly to Unicode.
+		r[i] = rune(c)
+	}
+	ud, err := gsm7.Encode([]byte(string(r)))
+	if err == nil {
+		return 0x00, ud
+	}
+	return tpdu.DcsUCS2Data, ucs2.Encode(r)
+}
+
+// FromStatusReport creates the deliver_sm delivery receipt corresponding to
+// the SMS-STATUS-REPORT.
+//
+// The id is the message_id assigned to the message being reported on, which
+// is not contained in the status report.
+//
+// The receipt is provided both in the short_message text and in the
+// receipted_message_id and message_state TLVs. The TP-MR is carried in a
+// user_message_reference TLV.
+func FromStatusReport(t *tpdu.TPDU, id string) (*PDU, error) {
+	r, err := DeliveryReceiptFromStatusReport(t, id)
+	if err != nil {
+		return nil, err
+	}
+	p := PDU{
+		CommandID:    DeliverSM,
+		Source:       AddressFromTPDU(t.RA),
+		ESMClass:     EsmTypeDeliveryReceipt,
+		ShortMessage: []byte(r.String()),
+	}
+	p.TLVs.Set(NewTLVString(TagReceiptedMessageID, id))
+	p.TLVs.Set(NewTLV8(TagMessageState, byte(r.State)))
+	if t.MR != 0 {
+		p.TLVs.Set(NewTLV16(TagUserMessageReference, uint16(t.MR)))
+	}
+	return &p, nil
+}
+
+// DeliveryReceipt returns the delivery receipt contained in a deliver_sm.
+//
+// The receipt is parsed from the short_message text, if present, with the
+// receipted_message_id and message_state TLVs, if present, taking
+// precedence over the corresponding fields in the text.
+func (p *PDU) DeliveryReceipt() (*DeliveryReceipt, error) {
+	idTLV, hasID := p.TLVs.Get(TagReceiptedMessageID)
+	stateTLV, hasState := p.TLVs.Get(TagMessageState)
+	r := &DeliveryReceipt{}
+	if len(p.ShortMessage) > 0 {
+		pr, err := ParseDeliveryReceipt(string(p.ShortMessage))
+		if err != nil {
+			if !hasID || !hasState {
+				return nil, tpdu.NewDecodeError("short_message", 0, err)
+			}
+		} else {
+			r = pr
+		}
+	} else if !hasID {
+		return nil, tpdu.NewDecodeError("receipted_message_id", 0, tpdu.ErrMissing)
+	} else if !hasState {
+		return nil, tpdu.NewDecodeError("message_state", 0, tpdu.ErrMissing)
+	}
+	if hasID {
+		r.ID = idTLV.CString()
+	}
+	if hasState {
+		state, _ := stateTLV.Uint()
+		r.State = MessageState(state)
+	}
+	return r, nil
+}
+
+// statusReport creates the SMS-STATUS-REPORT corresponding to a deliver_sm
+// containing a delivery receipt.
+func (p *PDU) statusReport() (*tpdu.TPDU, error) {
+	r, err := p.DeliveryReceipt()
+	if err != nil {
+		return nil, err
+	}
+	t := r.StatusReport(p.Source.TPDUAddress())
+	if tlv, ok := p.TLVs.Get(TagUserMessageReference); ok {
+		mr, _ := tlv.Uint()
+		t.MR = byte(mr)
+	}
+	return t, nil
+}
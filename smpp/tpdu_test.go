@@ -0,0 +1,268 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smpp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smpp"
+)
+
+func TestDataCodingFromDCS(t *testing.T) {
+	patterns := []struct {
+		in  tpdu.DCS
+		out byte
+		err error
+	}{
+		{0x00, smpp.DataCodingDefault, nil},
+		{0x04, smpp.DataCoding8Bit, nil},
+		{0x08, smpp.DataCodingUCS2, nil},
+		{0x11, 0xf1, nil},
+		{0x16, 0xf6, nil},
+		{0x18, smpp.DataCodingUCS2, nil},
+		{0xc8, 0xc8, nil},
+		{0xf5, 0xf5, nil},
+		{0x20, 0, smpp.ErrUnsupportedDataCoding(0x20)},
+		{0x80, 0, smpp.ErrUnsupportedDataCoding(0x80)},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			dc, err := smpp.DataCodingFromDCS(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, dc)
+		}
+		t.Run(p.in.String(), f)
+	}
+}
+
+func TestDCSFromDataCoding(t *testing.T) {
+	patterns := []struct {
+		in  byte
+		out tpdu.DCS
+		err error
+	}{
+		{smpp.DataCodingDefault, 0x00, nil},
+		{smpp.DataCodingIA5, 0x00, nil},
+		{smpp.DataCodingBinary, 0x04, nil},
+		{smpp.DataCodingLatin1, 0x00, nil},
+		{smpp.DataCoding8Bit, 0x04, nil},
+		{smpp.DataCodingUCS2, 0x08, nil},
+		{0xf1, 0xf1, nil},
+		{0x05, 0, smpp.ErrUnsupportedDataCoding(0x05)},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			dcs, err := smpp.DCSFromDataCoding(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, dcs)
+		}
+		t.Run(tpdu.DCS(p.in).String(), f)
+	}
+}
+
+func TestAddress(t *testing.T) {
+	ta := tpdu.NewAddress(tpdu.FromNumber("+61409865629"))
+	a := smpp.AddressFromTPDU(ta)
+	assert.Equal(t, smpp.Address{TON: 1, NPI: 1, Addr: "61409865629"}, a)
+	assert.Equal(t, ta, a.TPDUAddress())
+
+	a = smpp.Address{TON: 1, NPI: 1, Addr: "+61409865629"}
+	assert.Equal(t, ta, a.TPDUAddress())
+
+	a = smpp.Address{TON: 5, NPI: 0, Addr: "Alpha"}
+	ta = a.TPDUAddress()
+	assert.Equal(t, byte(0xd0), ta.TOA)
+	assert.Equal(t, "Alpha", ta.Addr)
+}
+
+func TestFromTPDU(t *testing.T) {
+	submit, _ := tpdu.NewSubmit(tpdu.WithDA(tpdu.NewAddress(tpdu.FromNumber("1234"))))
+	submit.FirstOctet |= tpdu.FoSRR | tpdu.FoRP
+	submit.SetVP(tpdu.ValidityPeriod{Format: tpdu.VpfRelative, Duration: time.Hour})
+	submit.PID = 0x41
+	submit.MR = 0x42
+	submit.UD = []byte("hello")
+	deliver, _ := tpdu.NewDeliver(tpdu.WithOA(tpdu.NewAddress(tpdu.FromNumber("4321"))))
+	deliver.DCS = tpdu.DcsUCS2Data
+	deliver.SetUDH(tpdu.UserDataHeader{{ID: 0, Data: []byte{1, 2, 1}}})
+	deliver.UD = []byte{0, 'h'}
+	long, _ := tpdu.NewSubmit()
+	long.DCS = tpdu.Dcs8BitData
+	long.UD = make([]byte, 255)
+	patterns := []struct {
+		name string
+		in   *tpdu.TPDU
+		out  *smpp.PDU
+		err  error
+	}{
+		{
+			"submit",
+			submit,
+			&smpp.PDU{
+				CommandID:          smpp.SubmitSM,
+				Dest:               smpp.Address{TON: 1, NPI: 1, Addr: "1234"},
+				ESMClass:           smpp.EsmReplyPath,
+				ProtocolID:         0x41,
+				ValidityPeriod:     "000000010000000R",
+				RegisteredDelivery: smpp.RegDeliveryReceipt,
+				ShortMessage:       []byte("hello"),
+				TLVs:               smpp.TLVs{smpp.NewTLV16(smpp.TagUserMessageReference, 0x42)},
+			},
+			nil,
+		},
+		{
+			"deliver",
+			deliver,
+			&smpp.PDU{
+				CommandID:    smpp.DeliverSM,
+				Source:       smpp.Address{TON: 1, NPI: 1, Addr: "4321"},
+				ESMClass:     smpp.EsmUDHI,
+				DataCoding:   smpp.DataCodingUCS2,
+				ShortMessage: []byte{5, 0, 3, 1, 2, 1, 0, 'h'},
+			},
+			nil,
+		},
+		{
+			"payload",
+			long,
+			&smpp.PDU{
+				CommandID:  smpp.SubmitSM,
+				Dest:       smpp.Address{TON: 0, NPI: 0},
+				DataCoding: smpp.DataCoding8Bit,
+				TLVs:       smpp.TLVs{{smpp.TagMessagePayload, make([]byte, 255)}},
+			},
+			nil,
+		},
+		{
+			"status report",
+			&tpdu.TPDU{Direction: tpdu.MT, FirstOctet: 0x02},
+			nil,
+			smpp.ErrUnsupportedSmsType,
+		},
+		{
+			"bad dcs",
+			&tpdu.TPDU{Direction: tpdu.MT, DCS: 0x80},
+			nil,
+			smpp.ErrUnsupportedDataCoding(0x80),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			pdu, err := smpp.FromTPDU(p.in)
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, pdu)
+			if err != nil {
+				return
+			}
+			rt, err := pdu.TPDU()
+			require.Nil(t, err)
+			assert.Equal(t, p.in, rt)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestTPDU(t *testing.T) {
+	ia5, _ := tpdu.NewDeliver(tpdu.WithOA(tpdu.NewAddress(tpdu.FromNumber("4321"))))
+	ia5.UD = []byte("hello")
+	latin1, _ := tpdu.NewDeliver(tpdu.WithOA(tpdu.NewAddress(tpdu.FromNumber("4321"))))
+	latin1.DCS = tpdu.DcsUCS2Data
+	latin1.UD = []byte{0, 'h', 0, 0xfe}
+	patterns := []struct {
+		name string
+		in   smpp.PDU
+		out  *tpdu.TPDU
+		err  error
+	}{
+		{
+			"ia5",
+			smpp.PDU{
+				CommandID:    smpp.DeliverSM,
+				Source:       smpp.Address{TON: 1, NPI: 1, Addr: "4321"},
+				DataCoding:   smpp.DataCodingIA5,
+				ShortMessage: []byte("hello"),
+			},
+			ia5,
+			nil,
+		},
+		{
+			"latin1",
+			smpp.PDU{
+				CommandID:    smpp.DeliverSM,
+				Source:       smpp.Address{TON: 1, NPI: 1, Addr: "4321"},
+				DataCoding:   smpp.DataCodingLatin1,
+				ShortMessage: []byte{'h', 0xfe},
+			},
+			latin1,
+			nil,
+		},
+		{
+			"unsupported command",
+			smpp.PDU{CommandID: smpp.DataSM},
+			nil,
+			smpp.ErrUnsupportedCommand(smpp.DataSM),
+		},
+		{
+			"bad vp",
+			smpp.PDU{CommandID: smpp.SubmitSM, ValidityPeriod: "x"},
+			nil,
+			tpdu.NewDecodeError("validity_period", 0, smpp.ErrInvalidTime("x")),
+		},
+		{
+			"bad data coding",
+			smpp.PDU{CommandID: smpp.SubmitSM, DataCoding: 0x0e},
+			nil,
+			smpp.ErrUnsupportedDataCoding(0x0e),
+		},
+		{
+			"bad udh",
+			smpp.PDU{CommandID: smpp.SubmitSM, ESMClass: smpp.EsmUDHI, ShortMessage: []byte{5, 0}},
+			nil,
+			tpdu.NewDecodeError("short_message", 0, tpdu.NewDecodeError("ie", 1, tpdu.ErrUnderflow)),
+		},
+		{
+			"odd ucs2",
+			smpp.PDU{CommandID: smpp.SubmitSM, DataCoding: smpp.DataCodingUCS2, ShortMessage: []byte{0}},
+			nil,
+			tpdu.NewDecodeError("short_message", 0, tpdu.ErrOddUCS2Length),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			pdu, err := p.in.TPDU()
+			assert.Equal(t, p.err, err)
+			assert.Equal(t, p.out, pdu)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	msg := "this is a very long message that does not fit in a single SMS message, at least it will if I keep adding more to it as 160 characters is more than you might think"
+	tpdus, err := sms.Encode([]byte(msg), sms.To("1234"))
+	require.Nil(t, err)
+	require.Equal(t, 2, len(tpdus))
+	pdus := make([]*tpdu.TPDU, len(tpdus))
+	for i, tp := range tpdus {
+		p, err := smpp.FromTPDU(&tp)
+		require.Nil(t, err)
+		assert.True(t, p.ESMClass.UDHI())
+		b, err := p.MarshalBinary()
+		require.Nil(t, err)
+		rp := smpp.PDU{}
+		err = rp.UnmarshalBinary(b)
+		require.Nil(t, err)
+		pdus[i], err = rp.TPDU()
+		require.Nil(t, err)
+	}
+	m, err := sms.Decode(pdus)
+	require.Nil(t, err)
+	assert.Equal(t, msg, string(m))
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smsc
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smpp"
+)
+
+// Serve accepts SMPP connections on the listener and serves each with
+// ServeConn.
+//
+// Serve returns when the listener is closed.
+func (s *SMSC) Serve(l net.Listener) error {
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(nc)
+	}
+}
+
+// ServeConn serves an SMPP ESME on the connection until it unbinds or the
+// connection is closed.
+//
+// The system_id used to bind identifies the address of the ESME.
+// A transmitter or transceiver may submit messages with submit_sm, with the
+// source_addr defaulting to the system_id.
+// A receiver or transceiver is sent the messages queued for its address as
+// deliver_sm, with status reports sent as delivery receipts.
+func (s *SMSC) ServeConn(nc net.Conn) {
+	c := conn{nc: nc}
+	c.cond = sync.NewCond(&c.mu)
+	go c.send()
+	defer c.close()
+	for {
+		p, err := smpp.ReadPDU(nc)
+		if err != nil {
+			return
+		}
+		switch p.CommandID {
+		case smpp.BindTransmitter, smpp.BindReceiver, smpp.BindTransceiver:
+			c.bind(s, p)
+		case smpp.SubmitSM:
+			c.submit(s, p)
+		case smpp.EnquireLink:
+			c.write(p.Response(smpp.StatusOK))
+		case smpp.Unbind:
+			c.write(p.Response(smpp.StatusOK))
+			return
+		case smpp.DeliverSMResp, smpp.EnquireLinkResp, smpp.GenericNack:
+			// nothing to do
+		default:
+			c.write(&smpp.PDU{
+				CommandID: smpp.GenericNack,
+				Status:    smpp.StatusInvCmdID,
+				Sequence:  p.Sequence,
+			})
+		}
+	}
+}
+
+// conn is an SMPP connection served by the SMSC.
+type conn struct {
+	nc         net.Conn
+	addr       string
+	bound      smpp.CommandID
+	unregister func()
+
+	// outbound PDUs are queued and written by a separate goroutine so the
+	// reader never blocks on a write.
+	mu     sync.Mutex // covers the fields below
+	cond   *sync.Cond
+	seq    uint32
+	out    []*smpp.PDU
+	closed bool
+}
+
+func (c *conn) bind(s *SMSC, p *smpp.PDU) {
+	if c.bound != 0 {
+		c.write(p.Response(smpp.StatusAlyBnd))
+		return
+	}
+	c.bound = p.CommandID
+	c.addr = key(p.SystemID)
+	r := p.Response(smpp.StatusOK)
+	r.SystemID = "smsc"
+	c.write(r)
+	if p.CommandID != smpp.BindTransmitter {
+		c.unregister = s.Register(c.addr, c.deliver)
+	}
+}
+
+func (c *conn) submit(s *SMSC, p *smpp.PDU) {
+	if c.bound != smpp.BindTransmitter && c.bound != smpp.BindTransceiver {
+		c.write(p.Response(smpp.StatusInvBnd))
+		return
+	}
+	t, err := p.TPDU()
+	if err != nil {
+		c.write(p.Response(smpp.StatusSubmitFail))
+		return
+	}
+	oa := p.Source.Addr
+	if oa == "" {
+		oa = c.addr
+	}
+	id, err := s.Submit(oa, t)
+	if err != nil {
+		c.write(p.Response(smpp.StatusSubmitFail))
+		return
+	}
+	r := p.Response(smpp.StatusOK)
+	r.MessageID = id
+	c.write(r)
+}
+
+// deliver sends a message queued for the ESME as a deliver_sm.
+func (c *conn) deliver(m Message) error {
+	var p *smpp.PDU
+	var err error
+	if m.TPDU.SmsType() == tpdu.SmsStatusReport {
+		p, err = smpp.FromStatusReport(m.TPDU, m.ID)
+	} else {
+		p, err = smpp.FromTPDU(m.TPDU)
+	}
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.seq++
+	p.Sequence = c.seq
+	c.mu.Unlock()
+	return c.write(p)
+}
+
+// write queues the PDU to be sent to the ESME.
+func (c *conn) write(p *smpp.PDU) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errClosed
+	}
+	c.out = append(c.out, p)
+	c.cond.Signal()
+	return nil
+}
+
+// send writes queued PDUs to the ESME until the conn is closed.
+func (c *conn) send() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		for len(c.out) == 0 && !c.closed {
+			c.cond.Wait()
+		}
+		if len(c.out) == 0 {
+			break
+		}
+		p := c.out[0]
+		c.out = c.out[1:]
+		c.mu.Unlock()
+		err := smpp.WritePDU(c.nc, p)
+		c.mu.Lock()
+		if err != nil {
+			c.closed = true
+			c.out = nil
+		}
+	}
+	c.nc.Close()
+}
+
+// close unregisters the conn, and closes it once any queued PDUs have been
+// sent.
+func (c *conn) close() {
+	if c.unregister != nil {
+		c.unregister()
+	}
+	c.mu.Lock()
+	c.closed = true
+	c.cond.Signal()
+	c.mu.Unlock()
+}
+
+var errClosed = errors.New("smsc: connection closed")
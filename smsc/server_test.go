@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smsc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smpp"
+	"github.com/warthog618/sms/smpp/esme"
+	"github.com/warthog618/sms/smsc"
+)
+
+func pipeDialer(s *smsc.SMSC) esme.Dialer {
+	return func(ctx context.Context) (net.Conn, error) {
+		esmeSide, smscSide := net.Pipe()
+		go s.ServeConn(smscSide)
+		return esmeSide, nil
+	}
+}
+
+func TestServeConn(t *testing.T) {
+	s := smsc.New()
+	testEndToEnd(t, pipeDialer(s))
+}
+
+func TestServe(t *testing.T) {
+	s := smsc.New()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go s.Serve(l)
+	testEndToEnd(t, esme.TCPDialer(l.Addr().String()))
+}
+
+func testEndToEnd(t *testing.T, dial esme.Dialer) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	receipts := make(chan *smpp.PDU, 2)
+	tx := esme.New(dial,
+		esme.WithCredentials("1111", ""),
+		esme.WithPDUHandler(func(p *smpp.PDU) { receipts <- p }))
+	defer tx.Close()
+	msgs := make(chan []*tpdu.TPDU, 1)
+	rx := esme.New(dial,
+		esme.WithBindType(esme.Receiver),
+		esme.WithCredentials("2222", ""),
+		esme.WithDeliverHandler(func(segs []*tpdu.TPDU) { msgs <- segs }))
+	defer rx.Close()
+	require.Nil(t, rx.WaitBound(ctx))
+
+	msg := "this is a very long message that does not fit in a single SMS message, at least it will if I keep adding more to it as 160 characters is more than you might think"
+	e := sms.NewEncoder(sms.AsSubmit, sms.To("2222"))
+	tpdus, err := e.Encode([]byte(msg))
+	require.Nil(t, err)
+	for i := range tpdus {
+		tpdus[i].FirstOctet |= tpdu.FoSRR
+	}
+	ids, err := tx.Submit(ctx, tpdus...)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(ids))
+
+	select {
+	case segs := <-msgs:
+		assert.Equal(t, "1111", segs[0].OA.Addr)
+		m, err := sms.Decode(segs)
+		require.Nil(t, err)
+		assert.Equal(t, msg, string(m))
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for message")
+	}
+	for _, id := range ids {
+		select {
+		case r := <-receipts:
+			assert.Equal(t, smpp.EsmTypeDeliveryReceipt, r.ESMClass.MessageType())
+			tlv, ok := r.TLVs.Get(smpp.TagReceiptedMessageID)
+			require.True(t, ok)
+			assert.Equal(t, id, tlv.CString())
+			tlv, ok = r.TLVs.Get(smpp.TagMessageState)
+			require.True(t, ok)
+			assert.Equal(t, []byte{byte(smpp.StateDelivered)}, tlv.Value)
+			dr, err := smpp.ParseDeliveryReceipt(string(r.ShortMessage))
+			require.Nil(t, err)
+			assert.Equal(t, id, dr.ID)
+			assert.Equal(t, smpp.StateDelivered, dr.State)
+			sr, err := r.TPDU()
+			require.Nil(t, err)
+			assert.Equal(t, tpdu.SmsStatusReport, sr.SmsType())
+			assert.Equal(t, "2222", sr.RA.Addr)
+			assert.Equal(t, byte(smsc.StReceived), sr.ST)
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for receipt")
+		}
+	}
+
+	// receiver cannot submit
+	_, err = rx.Submit(ctx, tpdus[0])
+	assert.Equal(t, smpp.StatusInvBnd, err)
+}
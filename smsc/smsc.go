@@ -0,0 +1,443 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package smsc provides an in-memory SMSC simulator for testing.
+//
+// The SMSC accepts SMS-SUBMIT TPDUs, converts them to SMS-DELIVER TPDUs and
+// queues them for the destination, generating SMS-STATUS-REPORTs for the
+// originator if requested.
+package smsc
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Message is a TPDU queued by the SMSC for delivery.
+type Message struct {
+	// ID is the identifier assigned by the SMSC to the submitted message.
+	//
+	// For status reports this is the ID of the message reported on.
+	ID string
+
+	// TPDU is the SMS-DELIVER or SMS-STATUS-REPORT to be delivered.
+	TPDU *tpdu.TPDU
+}
+
+// Handler is called to deliver a message to a registered address.
+//
+// If the handler returns an error the message remains queued, and delivery
+// to the address is suspended until the next submission to it or the
+// handler is re-registered.
+type Handler func(m Message) error
+
+// SMSC is an in-memory SMSC.
+//
+// Addresses are identified by their number, with any '+' prefix ignored.
+type SMSC struct {
+	clock      func() time.Time
+	defaultVP  time.Duration
+	mu         sync.Mutex // covers the fields below
+	msgID      int
+	queues     map[string][]*message
+	handlers   map[string]*registration
+	delivering map[string]bool
+}
+
+type registration struct {
+	h Handler
+}
+
+// message is a Message with the additional state required by the SMSC.
+type message struct {
+	Message
+	oa     string
+	da     string
+	mr     byte
+	pid    byte
+	srr    bool
+	scts   tpdu.Timestamp
+	expiry time.Time
+	ra     tpdu.Address
+}
+
+// Option alters the behaviour of an SMSC.
+type Option func(*SMSC)
+
+// WithClock sets the source of time used for SCTS, discharge times and
+// validity period expiry.
+//
+// This is intended for testing expiry without waiting.
+// The default is time.Now.
+func WithClock(clock func() time.Time) Option {
+	return func(s *SMSC) {
+		s.clock = clock
+	}
+}
+
+// WithDefaultValidityPeriod sets the validity period applied to submissions
+// that do not specify one.
+//
+// The default is zero, meaning such messages never expire.
+func WithDefaultValidityPeriod(d time.Duration) Option {
+	return func(s *SMSC) {
+		s.defaultVP = d
+	}
+}
+
+// New creates an SMSC.
+func New(options ...Option) *SMSC {
+	s := SMSC{
+		clock:      time.Now,
+		queues:     make(map[string][]*message),
+		handlers:   make(map[string]*registration),
+		delivering: make(map[string]bool),
+	}
+	for _, option := range options {
+		option(&s)
+	}
+	return &s
+}
+
+// Submit accepts an SMS-SUBMIT from the originating address.
+//
+// The SMS-SUBMIT is converted to an SMS-DELIVER with the OA set and SCTS
+// stamped, and queued for the destination.
+//
+// If TP-RD is set and a message from the originator with the same TP-MR and
+// TP-DA is still queued then the submission is rejected as a duplicate.
+// If the TP-PID is a replace type, and a message from the originator with
+// the same TP-PID and TP-DA is still queued, then that message is replaced.
+//
+// Returns the ID assigned to the message.
+func (s *SMSC) Submit(oa string, t *tpdu.TPDU) (string, error) {
+	if st := t.SmsType(); st != tpdu.SmsSubmit {
+		return "", tpdu.ErrUnsupportedSmsType(st)
+	}
+	now := s.clock()
+	expiry, err := s.expiry(t.VP, now)
+	if err != nil {
+		return "", err
+	}
+	oa = key(oa)
+	da := key(t.DA.Addr)
+	d, _ := tpdu.NewDeliver(tpdu.WithOA(tpdu.NewAddress(tpdu.FromNumber(oa))))
+	d.FirstOctet |= t.FirstOctet & (tpdu.FoRP | tpdu.FoUDHI)
+	if t.FirstOctet.SRR() {
+		d.FirstOctet |= tpdu.FoSRI
+	}
+	// TP-MMS is inverted - this indicates no more messages are waiting
+	d.FirstOctet |= tpdu.FoMMS
+	d.SCTS = tpdu.Timestamp{Time: now.Truncate(time.Second)}
+	d.PID = t.PID
+	d.DCS = t.DCS
+	d.UDH = append(tpdu.UserDataHeader(nil), t.UDH...)
+	d.UD = append(tpdu.UserData(nil), t.UD...)
+	m := &message{
+		Message: Message{TPDU: d},
+		oa:      oa,
+		da:      da,
+		mr:      t.MR,
+		pid:     t.PID,
+		srr:     t.FirstOctet.SRR(),
+		scts:    d.SCTS,
+		expiry:  expiry,
+		ra:      t.DA,
+	}
+
+	s.mu.Lock()
+	s.expire(now)
+	q := s.queues[da]
+	idx := -1
+	for i, qm := range q {
+		if qm.oa != oa || qm.TPDU.SmsType() != tpdu.SmsDeliver {
+			continue
+		}
+		if t.FirstOctet.RD() && qm.mr == m.mr {
+			s.mu.Unlock()
+			return "", ErrRejected(FcsDuplicateSM)
+		}
+		if isReplacePID(m.pid) && qm.pid == m.pid {
+			idx = i
+		}
+	}
+	s.msgID++
+	m.ID = strconv.Itoa(s.msgID)
+	if idx >= 0 {
+		q[idx] = m
+	} else {
+		s.queues[da] = append(q, m)
+	}
+	s.mu.Unlock()
+	s.deliver(da)
+	return m.ID, nil
+}
+
+// Fetch removes and returns all messages queued for the address.
+//
+// Messages that have expired are discarded, and are not returned.
+func (s *SMSC) Fetch(addr string) []Message {
+	addr = key(addr)
+	s.mu.Lock()
+	now := s.clock()
+	s.expire(now)
+	q := s.queues[addr]
+	delete(s.queues, addr)
+	mm := make([]Message, len(q))
+	var notify []string
+	for i, m := range q {
+		mm[i] = m.Message
+		if s.delivered(m, now) {
+			notify = append(notify, m.oa)
+		}
+	}
+	s.mu.Unlock()
+	for _, oa := range notify {
+		s.deliver(oa)
+	}
+	return mm
+}
+
+// Queued returns the messages queued for the address, without removing them
+// from the queue.
+func (s *SMSC) Queued(addr string) []Message {
+	addr = key(addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(s.clock())
+	q := s.queues[addr]
+	mm := make([]Message, len(q))
+	for i, m := range q {
+		mm[i] = m.Message
+	}
+	return mm
+}
+
+// Register sets the handler for messages to the address.
+//
+// Any messages already queued for the address are passed to the handler, as
+// are any subsequently queued.
+//
+// Returns a function that removes the registration.
+func (s *SMSC) Register(addr string, h Handler) func() {
+	addr = key(addr)
+	r := &registration{h}
+	s.mu.Lock()
+	s.handlers[addr] = r
+	s.mu.Unlock()
+	s.deliver(addr)
+	return func() {
+		s.mu.Lock()
+		// only remove the handler if it hasn't since been replaced
+		if s.handlers[addr] == r {
+			delete(s.handlers, addr)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// deliver passes any messages queued for the address to the registered
+// handler.
+func (s *SMSC) deliver(addr string) {
+	s.mu.Lock()
+	if s.delivering[addr] {
+		// the active delivery will pick up any new messages
+		s.mu.Unlock()
+		return
+	}
+	s.delivering[addr] = true
+	for {
+		now := s.clock()
+		s.expire(now)
+		r := s.handlers[addr]
+		q := s.queues[addr]
+		if r == nil || len(q) == 0 {
+			break
+		}
+		m := q[0]
+		s.mu.Unlock()
+		err := r.h(m.Message)
+		s.mu.Lock()
+		if err != nil {
+			break
+		}
+		if s.remove(addr, m) && s.delivered(m, now) {
+			s.mu.Unlock()
+			s.deliver(m.oa)
+			s.mu.Lock()
+		}
+	}
+	delete(s.delivering, addr)
+	s.mu.Unlock()
+}
+
+// remove removes the message from the queue for the address.
+//
+// Returns false if the message is no longer in the queue.
+//
+// Must be called with the mutex held.
+func (s *SMSC) remove(addr string, m *message) bool {
+	q := s.queues[addr]
+	for i, qm := range q {
+		if qm == m {
+			s.queues[addr] = append(q[:i:i], q[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// delivered records the delivery of a message, and queues a status report to
+// the originator if one was requested.
+//
+// Returns true if a status report was queued.
+//
+// Must be called with the mutex held.
+func (s *SMSC) delivered(m *message, now time.Time) bool {
+	return s.report(m, StReceived, now)
+}
+
+// expire removes any expired messages from the queues, and queues status
+// reports to the originators if requested.
+//
+// Must be called with the mutex held.
+func (s *SMSC) expire(now time.Time) {
+	var expired []*message
+	for addr, q := range s.queues {
+		var live []*message
+		for _, m := range q {
+			if !m.expiry.IsZero() && now.After(m.expiry) {
+				expired = append(expired, m)
+				continue
+			}
+			live = append(live, m)
+		}
+		if len(live) == 0 {
+			delete(s.queues, addr)
+		} else if len(live) != len(q) {
+			s.queues[addr] = live
+		}
+	}
+	for _, m := range expired {
+		s.report(m, StValidityPeriodExpired, now)
+	}
+}
+
+// report queues an SMS-STATUS-REPORT for the message, if requested by the
+// originator.
+//
+// Must be called with the mutex held.
+func (s *SMSC) report(m *message, st byte, now time.Time) bool {
+	if !m.srr {
+		return false
+	}
+	r, _ := tpdu.New(tpdu.SmsStatusReport)
+	r.FirstOctet |= tpdu.FoMMS
+	r.MR = m.mr
+	r.RA = m.ra
+	r.SCTS = m.scts
+	r.DT = tpdu.Timestamp{Time: now.Truncate(time.Second)}
+	r.ST = st
+	sr := &message{
+		Message: Message{ID: m.ID, TPDU: r},
+		oa:      m.da,
+		da:      m.oa,
+	}
+	s.queues[m.oa] = append(s.queues[m.oa], sr)
+	return true
+}
+
+// expiry returns the time at which a message with the validity period will
+// expire, or zero if it will not.
+func (s *SMSC) expiry(vp tpdu.ValidityPeriod, now time.Time) (time.Time, error) {
+	switch vp.Format {
+	case tpdu.VpfNotPresent:
+		return s.defaultExpiry(now), nil
+	case tpdu.VpfAbsolute:
+		return vp.Time.Time, nil
+	case tpdu.VpfRelative:
+		return now.Add(relativeDuration(vp.Duration)), nil
+	case tpdu.VpfEnhanced:
+		switch tpdu.EnhancedFormat(vp.EFI) {
+		case tpdu.EvpfNotPresent:
+			return s.defaultExpiry(now), nil
+		case tpdu.EvpfRelative:
+			return now.Add(relativeDuration(vp.Duration)), nil
+		}
+		return now.Add(vp.Duration), nil
+	default:
+		return time.Time{}, ErrRejected(FcsVPFNotSupported)
+	}
+}
+
+// defaultExpiry returns the expiry of a message that has no validity period,
+// or zero if it will not expire.
+func (s *SMSC) defaultExpiry(now time.Time) time.Time {
+	if s.defaultVP == 0 {
+		return time.Time{}
+	}
+	return now.Add(s.defaultVP)
+}
+
+// relativeDuration returns the period of a relative TP-VP with duration d.
+//
+// The shortest relative TP-VP is 5 minutes, as encoded by a TP-VP of 0, so
+// shorter durations are rounded up to that.
+func relativeDuration(d time.Duration) time.Duration {
+	if d < 5*time.Minute {
+		return 5 * time.Minute
+	}
+	return d
+}
+
+// isReplacePID returns true if the PID is one of the Replace Short Message
+// Types, or Return Call Message, as defined in 3GPP TS 23.040 Section
+// 9.2.3.9.
+func isReplacePID(pid byte) bool {
+	return (pid >= 0x41 && pid <= 0x47) || pid == 0x5f
+}
+
+// key returns the normalised form of an address used to identify queues.
+func key(addr string) string {
+	if len(addr) > 0 && addr[0] == '+' {
+		return addr[1:]
+	}
+	return addr
+}
+
+// TP-ST values generated by the SMSC, as defined in 3GPP TS 23.040 Section
+// 9.2.3.15.
+const (
+	// StReceived indicates the short message was received by the SME.
+	StReceived = 0x00
+
+	// StValidityPeriodExpired indicates the short message expired before it
+	// could be delivered.
+	StValidityPeriodExpired = 0x46
+)
+
+// TP-FCS values generated by the SMSC, as defined in 3GPP TS 23.040 Section
+// 9.2.3.22.
+const (
+	// FcsVPFNotSupported indicates the validity period format is not
+	// supported.
+	FcsVPFNotSupported = 0xc6
+
+	// FcsDuplicateSM indicates the submission duplicates a message still held
+	// by the SMSC.
+	FcsDuplicateSM = 0xc5
+)
+
+// ErrRejected indicates the SMSC rejected a submission.
+//
+// The value is the TP-FCS indicating the reason.
+type ErrRejected byte
+
+func (e ErrRejected) Error() string {
+	return fmt.Sprintf("smsc: submission rejected: TP-FCS 0x%02x", byte(e))
+}
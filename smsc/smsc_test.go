@@ -0,0 +1,303 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smsc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/smsc"
+)
+
+// clock is a manually advanced time source.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newClock() *clock {
+	return &clock{time.Date(2020, time.April, 12, 10, 30, 15, 123, time.FixedZone("SCTS", 8*3600))}
+}
+
+func newSubmit(da string, ud string, options ...tpdu.Option) *tpdu.TPDU {
+	options = append([]tpdu.Option{tpdu.WithDA(tpdu.NewAddress(tpdu.FromNumber(da)))}, options...)
+	s, _ := tpdu.NewSubmit(options...)
+	s.UD = []byte(ud)
+	return s
+}
+
+func TestSubmit(t *testing.T) {
+	c := newClock()
+	s := smsc.New(smsc.WithClock(c.Now))
+	sub := newSubmit("+2222", "hello")
+	sub.PID = 0x12
+	sub.DCS = 0x04
+	sub.FirstOctet |= tpdu.FoRP
+	sub.SetUDH(tpdu.UserDataHeader{{ID: 0, Data: []byte{1, 2, 1}}})
+	id, err := s.Submit("1111", sub)
+	require.Nil(t, err)
+	assert.Equal(t, "1", id)
+
+	assert.Equal(t, 0, len(s.Fetch("1111")))
+	assert.Equal(t, 1, len(s.Queued("2222")))
+	mm := s.Fetch("+2222")
+	require.Equal(t, 1, len(mm))
+	assert.Equal(t, "1", mm[0].ID)
+	d := mm[0].TPDU
+	assert.Equal(t, tpdu.SmsDeliver, d.SmsType())
+	assert.Equal(t, "1111", d.OA.Addr)
+	assert.Equal(t, tpdu.TonInternational, d.OA.TypeOfNumber())
+	assert.Equal(t, c.now.Truncate(time.Second), d.SCTS.Time)
+	assert.Equal(t, byte(0x12), d.PID)
+	assert.Equal(t, tpdu.DCS(0x04), d.DCS)
+	assert.True(t, d.FirstOctet.RP())
+	assert.True(t, d.FirstOctet.UDHI())
+	assert.False(t, d.FirstOctet.SRI())
+	assert.Equal(t, sub.UDH, d.UDH)
+	assert.Equal(t, tpdu.UserData("hello"), d.UD)
+	_, err = d.MarshalBinary()
+	assert.Nil(t, err)
+
+	assert.Equal(t, 0, len(s.Fetch("2222")))
+	assert.Equal(t, 0, len(s.Fetch("1111")))
+}
+
+func TestSubmitUnsupported(t *testing.T) {
+	s := smsc.New()
+	d, _ := tpdu.NewDeliver()
+	_, err := s.Submit("1111", d)
+	assert.Equal(t, tpdu.ErrUnsupportedSmsType(tpdu.SmsDeliver), err)
+}
+
+func TestStatusReport(t *testing.T) {
+	c := newClock()
+	s := smsc.New(smsc.WithClock(c.Now))
+	sub := newSubmit("2222", "hello")
+	sub.FirstOctet |= tpdu.FoSRR
+	sub.MR = 42
+	id, err := s.Submit("1111", sub)
+	require.Nil(t, err)
+	c.Advance(time.Minute)
+
+	mm := s.Fetch("2222")
+	require.Equal(t, 1, len(mm))
+	assert.True(t, mm[0].TPDU.FirstOctet.SRI())
+
+	mm = s.Fetch("1111")
+	require.Equal(t, 1, len(mm))
+	assert.Equal(t, id, mm[0].ID)
+	r := mm[0].TPDU
+	assert.Equal(t, tpdu.SmsStatusReport, r.SmsType())
+	assert.Equal(t, byte(42), r.MR)
+	assert.Equal(t, sub.DA, r.RA)
+	assert.Equal(t, byte(smsc.StReceived), r.ST)
+	assert.Equal(t, c.now.Add(-time.Minute).Truncate(time.Second), r.SCTS.Time)
+	assert.Equal(t, c.now.Truncate(time.Second), r.DT.Time)
+	_, err = r.MarshalBinary()
+	assert.Nil(t, err)
+}
+
+func TestValidityPeriod(t *testing.T) {
+	abs := tpdu.ValidityPeriod{}
+	abs.SetAbsolute(tpdu.Timestamp{Time: newClock().now.Add(time.Hour)})
+	rel := tpdu.ValidityPeriod{}
+	rel.SetRelative(time.Hour)
+	enh := tpdu.ValidityPeriod{}
+	enh.SetEnhanced(time.Hour, 0x02)
+	patterns := []struct {
+		name    string
+		vp      tpdu.ValidityPeriod
+		options []smsc.Option
+		expires bool
+	}{
+		{"none", tpdu.ValidityPeriod{}, nil, false},
+		{"default", tpdu.ValidityPeriod{}, []smsc.Option{smsc.WithDefaultValidityPeriod(time.Hour)}, true},
+		{"absolute", abs, nil, true},
+		{"relative", rel, nil, true},
+		{"enhanced", enh, nil, true},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			c := newClock()
+			s := smsc.New(append(p.options, smsc.WithClock(c.Now))...)
+			sub := newSubmit("2222", "hello")
+			sub.FirstOctet |= tpdu.FoSRR
+			sub.SetVP(p.vp)
+			id, err := s.Submit("1111", sub)
+			require.Nil(t, err)
+
+			c.Advance(59 * time.Minute)
+			assert.Equal(t, 1, len(s.Queued("2222")))
+			c.Advance(2 * time.Minute)
+			mm := s.Queued("2222")
+			if !p.expires {
+				assert.Equal(t, 1, len(mm))
+				return
+			}
+			assert.Equal(t, 0, len(mm))
+			mm = s.Fetch("1111")
+			require.Equal(t, 1, len(mm))
+			assert.Equal(t, id, mm[0].ID)
+			assert.Equal(t, byte(smsc.StValidityPeriodExpired), mm[0].TPDU.ST)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestValidityPeriodZero(t *testing.T) {
+	// a relative TP-VP of 0 is 5 minutes, not absent
+	zero := tpdu.ValidityPeriod{}
+	zero.SetRelative(0)
+	b, err := zero.MarshalBinary()
+	require.Nil(t, err)
+	assert.Equal(t, []byte{0}, b)
+	rt := tpdu.ValidityPeriod{}
+	_, err = rt.UnmarshalBinary(b, tpdu.VpfRelative)
+	require.Nil(t, err)
+	enh := tpdu.ValidityPeriod{}
+	enh.SetEnhanced(0, byte(tpdu.EvpfRelative))
+	patterns := []struct {
+		name string
+		vp   tpdu.ValidityPeriod
+	}{
+		{"zero", zero},
+		{"decoded", rt},
+		{"enhanced", enh},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			c := newClock()
+			s := smsc.New(smsc.WithClock(c.Now))
+			sub := newSubmit("2222", "hello")
+			sub.SetVP(p.vp)
+			_, err := s.Submit("1111", sub)
+			require.Nil(t, err)
+
+			c.Advance(4 * time.Minute)
+			assert.Equal(t, 1, len(s.Queued("2222")))
+			c.Advance(2 * time.Minute)
+			assert.Equal(t, 0, len(s.Queued("2222")))
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestRejectDuplicates(t *testing.T) {
+	s := smsc.New()
+	sub := newSubmit("2222", "hello")
+	sub.MR = 1
+	_, err := s.Submit("1111", sub)
+	require.Nil(t, err)
+
+	// RD clear - accepted
+	_, err = s.Submit("1111", sub)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(s.Queued("2222")))
+
+	// RD set - rejected
+	sub.FirstOctet |= tpdu.FoRD
+	_, err = s.Submit("1111", sub)
+	assert.Equal(t, smsc.ErrRejected(smsc.FcsDuplicateSM), err)
+	assert.Equal(t, 2, len(s.Queued("2222")))
+
+	// different MR
+	sub.MR = 2
+	_, err = s.Submit("1111", sub)
+	assert.Nil(t, err)
+
+	// different originator
+	_, err = s.Submit("3333", sub)
+	assert.Nil(t, err)
+
+	// original delivered
+	s.Fetch("2222")
+	_, err = s.Submit("1111", sub)
+	assert.Nil(t, err)
+}
+
+func TestReplace(t *testing.T) {
+	s := smsc.New()
+	sub := newSubmit("2222", "one")
+	sub.PID = 0x41
+	id1, err := s.Submit("1111", sub)
+	require.Nil(t, err)
+	_, err = s.Submit("1111", newSubmit("2222", "two"))
+	require.Nil(t, err)
+
+	// different originator - not replaced
+	sub = newSubmit("2222", "three")
+	sub.PID = 0x41
+	_, err = s.Submit("3333", sub)
+	require.Nil(t, err)
+
+	// different replace type - not replaced
+	sub = newSubmit("2222", "four")
+	sub.PID = 0x42
+	_, err = s.Submit("1111", sub)
+	require.Nil(t, err)
+
+	// replaced
+	sub = newSubmit("2222", "five")
+	sub.PID = 0x41
+	id5, err := s.Submit("1111", sub)
+	require.Nil(t, err)
+	assert.NotEqual(t, id1, id5)
+
+	mm := s.Fetch("2222")
+	uds := []string{}
+	for _, m := range mm {
+		uds = append(uds, string(m.TPDU.UD))
+	}
+	assert.Equal(t, []string{"five", "two", "three", "four"}, uds)
+	assert.Equal(t, id5, mm[0].ID)
+}
+
+func TestRegister(t *testing.T) {
+	s := smsc.New()
+	_, err := s.Submit("1111", newSubmit("2222", "queued"))
+	require.Nil(t, err)
+
+	var got []string
+	fail := false
+	unregister := s.Register("2222", func(m smsc.Message) error {
+		if fail {
+			return errors.New("busy")
+		}
+		got = append(got, string(m.TPDU.UD))
+		return nil
+	})
+	assert.Equal(t, []string{"queued"}, got)
+
+	sub := newSubmit("2222", "direct")
+	sub.FirstOctet |= tpdu.FoSRR
+	_, err = s.Submit("1111", sub)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"queued", "direct"}, got)
+	assert.Equal(t, 0, len(s.Queued("2222")))
+	assert.Equal(t, 1, len(s.Queued("1111")))
+
+	fail = true
+	_, err = s.Submit("1111", newSubmit("2222", "retained"))
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(s.Queued("2222")))
+
+	fail = false
+	unregister()
+	_, err = s.Submit("1111", newSubmit("2222", "unregistered"))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"queued", "direct"}, got)
+	assert.Equal(t, 2, len(s.Queued("2222")))
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smsf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedContentType indicates a body is not multipart/related.
+type ErrUnsupportedContentType string
+
+func (e ErrUnsupportedContentType) Error() string {
+	return fmt.Sprintf("smsf: unsupported content type: '%s'", string(e))
+}
+
+// ErrMissingPart indicates a body does not contain the required part, which
+// is identified by its Content-ID or, for the JSON part, its Content-Type.
+type ErrMissingPart string
+
+func (e ErrMissingPart) Error() string {
+	return fmt.Sprintf("smsf: missing part: '%s'", string(e))
+}
+
+// ErrUnexpectedMessageClass indicates an N1 message is not of the SMS class.
+type ErrUnexpectedMessageClass string
+
+func (e ErrUnexpectedMessageClass) Error() string {
+	return fmt.Sprintf("smsf: unexpected N1 message class: '%s'", string(e))
+}
+
+// ErrMissingN1MessageContainer indicates an N1N2MessageTransfer request
+// contains no N1 message.
+var ErrMissingN1MessageContainer = errors.New("smsf: missing n1MessageContainer")
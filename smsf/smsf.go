@@ -0,0 +1,397 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package smsf provides encoding and decoding of the multipart/related HTTP
+// bodies used to transfer SMS between the AMF and SMSF in the 5G core.
+//
+// The uplink bodies are those of the Nsmsf_SMService UplinkSMS service
+// operation, defined in 3GPP TS 29.540, and the downlink bodies those of the
+// Namf_Communication N1N2MessageTransfer service operation, defined in 3GPP
+// TS 29.518, with an N1 message class of SMS.
+//
+// Each body contains a JSON part, referencing a binary part of type
+// application/vnd.3gpp.sms which contains the CP message, as defined in 3GPP
+// TS 24.011, with the CP-DATA carrying the RP message and TPDU.
+package smsf
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/cp"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+const (
+	// ContentType is the MIME type of the binary part carrying the CP
+	// message.
+	ContentType = "application/vnd.3gpp.sms"
+
+	// MultipartContentType is the MIME type of the uplink and downlink
+	// bodies.
+	MultipartContentType = "multipart/related"
+
+	// jsonContentType is the MIME type of the JSON part.
+	jsonContentType = "application/json"
+
+	// DefaultContentID is the Content-ID used for the binary part if none
+	// is specified.
+	DefaultContentID = "sms"
+
+	// AccessType3GPP is the AccessType for 3GPP access.
+	AccessType3GPP = "3GPP_ACCESS"
+
+	// AccessTypeNon3GPP is the AccessType for non-3GPP access.
+	AccessTypeNon3GPP = "NON_3GPP_ACCESS"
+
+	// N1MessageClassSMS is the N1MessageClass of N1 messages carrying SMS.
+	N1MessageClassSMS = "SMS"
+)
+
+// RefToBinaryData references a binary part of a multipart body by its
+// Content-ID.
+type RefToBinaryData struct {
+	ContentID string `json:"contentId"`
+}
+
+// SMSRecordData is the JSON part of an UplinkSMS body, as defined in 3GPP
+// TS 29.540.
+type SMSRecordData struct {
+	SMSRecordID string          `json:"smsRecordId"`
+	SMSPayload  RefToBinaryData `json:"smsPayload"`
+	AccessType  string          `json:"accessType,omitempty"`
+	GPSI        string          `json:"gpsi,omitempty"`
+	PEI         string          `json:"pei,omitempty"`
+	UETimeZone  string          `json:"ueTimeZone,omitempty"`
+}
+
+// Uplink is the body of an UplinkSMS request, carrying a CP message from the
+// UE to the SMSF.
+type Uplink struct {
+	Record SMSRecordData
+
+	// Payload is the CP message carried in the binary part.
+	Payload *cp.Message
+}
+
+// NewUplinkSubmit creates an Uplink carrying a CP-DATA containing an RP-DATA
+// containing the SMS-SUBMIT, addressed to the SC.
+//
+// The ti is the CP transaction identifier and the mr is the RP-Message
+// Reference.
+func NewUplinkSubmit(recordID string, ti, mr byte, sc pdumode.SMSCAddress, t *tpdu.TPDU) *Uplink {
+	return &Uplink{
+		Record: SMSRecordData{SMSRecordID: recordID, AccessType: AccessType3GPP},
+		Payload: &cp.Message{
+			TI:   ti,
+			Type: cp.Data,
+			UD:   &rp.Message{Type: rp.DataMSToN, MR: mr, DA: sc, UD: t},
+		},
+	}
+}
+
+// MarshalBody encodes the uplink into a multipart/related body, returning
+// the Content-Type and the body.
+func (u *Uplink) MarshalBody() (string, []byte, error) {
+	r := u.Record
+	if r.SMSPayload.ContentID == "" {
+		r.SMSPayload.ContentID = DefaultContentID
+	}
+	return marshalBody(&r, r.SMSPayload.ContentID, u.Payload)
+}
+
+// TPDU returns the TPDU carried in the payload.
+//
+// Returns nil if the payload does not contain an RP message carrying a
+// TPDU, such as a CP-ACK, or an RP-ACK with no RP-User-Data.
+func (u *Uplink) TPDU() *tpdu.TPDU {
+	return payloadTPDU(u.Payload)
+}
+
+// ParseUplink decodes an Uplink from the multipart/related body with the
+// given Content-Type.
+func ParseUplink(contentType string, body io.Reader) (*Uplink, error) {
+	u := Uplink{}
+	parts, err := parseBody(contentType, body, &u.Record)
+	if err != nil {
+		return nil, err
+	}
+	if u.Payload, err = parsePayload(parts, u.Record.SMSPayload.ContentID); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// NewUplinkRequest creates the HTTP request for the UplinkSMS service
+// operation, sending the uplink to the SMSF at the apiRoot, for the UE
+// identified by the SUPI.
+func NewUplinkRequest(apiRoot, supi string, u *Uplink) (*http.Request, error) {
+	path := "/nsmsf-sms/v2/ue-contexts/" + url.PathEscape(supi) + "/sendsms"
+	return newRequest(apiRoot+path, u)
+}
+
+// ReadUplink decodes the Uplink from the body of an HTTP request.
+func ReadUplink(r *http.Request) (*Uplink, error) {
+	return ParseUplink(r.Header.Get("Content-Type"), r.Body)
+}
+
+// Delivery status values of the SMSRecordDeliveryData.
+const (
+	DeliveryPending        = "SMS_DELIVERY_PENDING"
+	DeliveryCompleted      = "SMS_DELIVERY_COMPLETED"
+	DeliveryFailed         = "SMS_DELIVERY_FAILED"
+	DeliverySMSFAccepted   = "SMS_DELIVERY_SMSF_ACCEPTED"
+	DeliveryMemoryExceeded = "SMS_DELIVERY_MEMORY_EXCEEDED"
+)
+
+// SMSRecordDeliveryData is the JSON body of a successful response to an
+// UplinkSMS request, as defined in 3GPP TS 29.540.
+type SMSRecordDeliveryData struct {
+	SMSRecordID    string `json:"smsRecordId"`
+	DeliveryStatus string `json:"deliveryStatus"`
+}
+
+// N1MessageContainer is the N1 message carried in an N1N2MessageTransfer,
+// as defined in 3GPP TS 29.518.
+type N1MessageContainer struct {
+	N1MessageClass   string          `json:"n1MessageClass"`
+	N1MessageContent RefToBinaryData `json:"n1MessageContent"`
+}
+
+// N1N2MessageTransferReqData is the JSON part of an N1N2MessageTransfer
+// request body, as defined in 3GPP TS 29.518.
+//
+// Only the fields relevant to SMS are supported.
+type N1N2MessageTransferReqData struct {
+	N1MessageContainer *N1MessageContainer `json:"n1MessageContainer,omitempty"`
+	SUPI               string              `json:"supi,omitempty"`
+	LastMsgIndication  bool                `json:"lastMsgIndication,omitempty"`
+}
+
+// Downlink is the body of an N1N2MessageTransfer request, carrying a CP
+// message from the SMSF to the UE.
+type Downlink struct {
+	Transfer N1N2MessageTransferReqData
+
+	// Payload is the CP message carried in the binary part.
+	Payload *cp.Message
+}
+
+// NewDownlinkDeliver creates a Downlink carrying a CP-DATA containing an
+// RP-DATA containing the SMS-DELIVER or SMS-STATUS-REPORT, originating from
+// the SC.
+//
+// The ti is the CP transaction identifier and the mr is the RP-Message
+// Reference.
+func NewDownlinkDeliver(ti, mr byte, sc pdumode.SMSCAddress, t *tpdu.TPDU) *Downlink {
+	return &Downlink{
+		Payload: &cp.Message{
+			TI:   ti,
+			Type: cp.Data,
+			UD:   &rp.Message{Type: rp.DataNToMS, MR: mr, OA: sc, UD: t},
+		},
+	}
+}
+
+// MarshalBody encodes the downlink into a multipart/related body, returning
+// the Content-Type and the body.
+//
+// The N1MessageContainer is populated if not already set.
+func (d *Downlink) MarshalBody() (string, []byte, error) {
+	t := d.Transfer
+	c := N1MessageContainer{N1MessageClass: N1MessageClassSMS}
+	if t.N1MessageContainer != nil {
+		c = *t.N1MessageContainer
+	}
+	if c.N1MessageContent.ContentID == "" {
+		c.N1MessageContent.ContentID = DefaultContentID
+	}
+	t.N1MessageContainer = &c
+	return marshalBody(&t, c.N1MessageContent.ContentID, d.Payload)
+}
+
+// TPDU returns the TPDU carried in the payload.
+//
+// Returns nil if the payload does not contain an RP message carrying a
+// TPDU, such as a CP-ACK, or an RP-ACK with no RP-User-Data.
+func (d *Downlink) TPDU() *tpdu.TPDU {
+	return payloadTPDU(d.Payload)
+}
+
+// ParseDownlink decodes a Downlink from the multipart/related body with the
+// given Content-Type.
+func ParseDownlink(contentType string, body io.Reader) (*Downlink, error) {
+	d := Downlink{}
+	parts, err := parseBody(contentType, body, &d.Transfer)
+	if err != nil {
+		return nil, err
+	}
+	c := d.Transfer.N1MessageContainer
+	if c == nil {
+		return nil, ErrMissingN1MessageContainer
+	}
+	if c.N1MessageClass != N1MessageClassSMS {
+		return nil, ErrUnexpectedMessageClass(c.N1MessageClass)
+	}
+	if d.Payload, err = parsePayload(parts, c.N1MessageContent.ContentID); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// NewDownlinkRequest creates the HTTP request for the N1N2MessageTransfer
+// service operation, sending the downlink to the AMF at the apiRoot, for the
+// UE context.
+func NewDownlinkRequest(apiRoot, ueContextID string, d *Downlink) (*http.Request, error) {
+	path := "/namf-comm/v1/ue-contexts/" + url.PathEscape(ueContextID) + "/n1-n2-messages"
+	return newRequest(apiRoot+path, d)
+}
+
+// ReadDownlink decodes the Downlink from the body of an HTTP request.
+func ReadDownlink(r *http.Request) (*Downlink, error) {
+	return ParseDownlink(r.Header.Get("Content-Type"), r.Body)
+}
+
+// N1N2 message transfer cause values.
+const (
+	TransferInitiated = "N1_N2_TRANSFER_INITIATED"
+	AttemptingToReach = "ATTEMPTING_TO_REACH_UE"
+)
+
+// N1N2MessageTransferRspData is the JSON body of a successful response to an
+// N1N2MessageTransfer request, as defined in 3GPP TS 29.518.
+type N1N2MessageTransferRspData struct {
+	Cause string `json:"cause"`
+}
+
+// bodyMarshaler is implemented by the Uplink and Downlink.
+type bodyMarshaler interface {
+	MarshalBody() (string, []byte, error)
+}
+
+// newRequest creates a POST request to the url carrying the body.
+func newRequest(url string, m bodyMarshaler) (*http.Request, error) {
+	ct, body, err := m.MarshalBody()
+	if err != nil {
+		return nil, err
+	}
+	r, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", ct)
+	return r, nil
+}
+
+// marshalBody encodes the JSON value and the CP message into a
+// multipart/related body.
+func marshalBody(v interface{}, contentID string, p *cp.Message) (string, []byte, error) {
+	if p == nil {
+		return "", nil, ErrMissingPart(contentID)
+	}
+	pb, err := p.MarshalBinary()
+	if err != nil {
+		return "", nil, err
+	}
+	jb, err := json.Marshal(v)
+	if err != nil {
+		return "", nil, err
+	}
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Type", jsonContentType)
+	pw, err := w.CreatePart(h)
+	if err != nil {
+		return "", nil, err
+	}
+	pw.Write(jb)
+	h = textproto.MIMEHeader{}
+	h.Set("Content-Type", ContentType)
+	h.Set("Content-Id", contentID)
+	if pw, err = w.CreatePart(h); err != nil {
+		return "", nil, err
+	}
+	pw.Write(pb)
+	if err = w.Close(); err != nil {
+		return "", nil, err
+	}
+	ct := mime.FormatMediaType(MultipartContentType, map[string]string{
+		"boundary": w.Boundary(),
+		"type":     jsonContentType,
+	})
+	return ct, buf.Bytes(), nil
+}
+
+// parseBody decodes a multipart/related body, unmarshalling the JSON part
+// into v, and returning the binary parts indexed by Content-ID.
+func parseBody(contentType string, body io.Reader, v interface{}) (map[string][]byte, error) {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mt != MultipartContentType || params["boundary"] == "" {
+		return nil, ErrUnsupportedContentType(contentType)
+	}
+	mr := multipart.NewReader(body, params["boundary"])
+	var jb []byte
+	parts := map[string][]byte{}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(p)
+		if err != nil {
+			return nil, err
+		}
+		pt, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		switch {
+		case pt == jsonContentType && jb == nil:
+			jb = b
+		case pt == ContentType:
+			id := strings.Trim(p.Header.Get("Content-Id"), "<>")
+			parts[id] = b
+		}
+	}
+	if jb == nil {
+		return nil, ErrMissingPart(jsonContentType)
+	}
+	if err = json.Unmarshal(jb, v); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// parsePayload decodes the CP message from the binary part with the
+// Content-ID.
+func parsePayload(parts map[string][]byte, contentID string) (*cp.Message, error) {
+	b, ok := parts[contentID]
+	if !ok || contentID == "" {
+		return nil, ErrMissingPart(contentID)
+	}
+	m := cp.Message{}
+	if err := m.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// payloadTPDU returns the TPDU carried in the CP message, if any.
+func payloadTPDU(p *cp.Message) *tpdu.TPDU {
+	if p == nil || p.UD == nil {
+		return nil
+	}
+	return p.UD.UD
+}
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package smsf_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/cp"
+	"github.com/warthog618/sms/encoding/pdumode"
+	"github.com/warthog618/sms/encoding/rp"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/internal/fixture"
+	"github.com/warthog618/sms/smsf"
+)
+
+const (
+	// SMS-SUBMIT to 6391 containing "hello".
+	submitTPDU = "0142049136190000" + "05E8329BFD06"
+
+	// SMS-DELIVER from 6391 containing "Hahahaha".
+	deliverTPDU = "040491361900005150713220052308C8303A8C0EA3C3"
+
+	supi = "imsi-505011234567890"
+)
+
+var smsc = pdumode.SMSCAddress{
+	Address: tpdu.Address{Addr: "639170000293", TOA: 0x91},
+}
+
+// multipartBody builds a multipart/related body from the parts, each being
+// the Content-Type, Content-Id and content.
+func multipartBody(t *testing.T, parts ...[3]string) (string, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", p[0])
+		if p[1] != "" {
+			h.Set("Content-Id", p[1])
+		}
+		pw, err := w.CreatePart(h)
+		require.Nil(t, err)
+		pw.Write([]byte(p[2]))
+	}
+	require.Nil(t, w.Close())
+	return "multipart/related; boundary=" + w.Boundary(), buf.Bytes()
+}
+
+func TestUplink(t *testing.T) {
+	s := fixture.TPDU(t, tpdu.MO, submitTPDU)
+	u := smsf.NewUplinkSubmit("rec1", 1, 0x2a, smsc, s)
+	ct, body, err := u.MarshalBody()
+	require.Nil(t, err)
+
+	mt, params, err := mime.ParseMediaType(ct)
+	require.Nil(t, err)
+	assert.Equal(t, smsf.MultipartContentType, mt)
+	assert.Equal(t, "application/json", params["type"])
+	assert.Contains(t, string(body), `"smsRecordId":"rec1"`)
+	assert.Contains(t, string(body), `"smsPayload":{"contentId":"sms"}`)
+	assert.Contains(t, string(body), "Content-Type: "+smsf.ContentType)
+
+	pu, err := smsf.ParseUplink(ct, bytes.NewReader(body))
+	require.Nil(t, err)
+	assert.Equal(t, "rec1", pu.Record.SMSRecordID)
+	assert.Equal(t, smsf.DefaultContentID, pu.Record.SMSPayload.ContentID)
+	assert.Equal(t, smsf.AccessType3GPP, pu.Record.AccessType)
+	require.NotNil(t, pu.Payload)
+	assert.Equal(t, cp.Data, pu.Payload.Type)
+	assert.Equal(t, byte(1), pu.Payload.TI)
+	require.NotNil(t, pu.Payload.UD)
+	assert.Equal(t, rp.DataMSToN, pu.Payload.UD.Type)
+	assert.Equal(t, byte(0x2a), pu.Payload.UD.MR)
+	assert.Equal(t, smsc, pu.Payload.UD.DA)
+	assert.Equal(t, s, pu.TPDU())
+}
+
+func TestUplinkAck(t *testing.T) {
+	u := smsf.Uplink{
+		Record:  smsf.SMSRecordData{SMSRecordID: "rec2", SMSPayload: smsf.RefToBinaryData{ContentID: "ack"}},
+		Payload: &cp.Message{TI: 2, TIFlag: true, Type: cp.Ack},
+	}
+	ct, body, err := u.MarshalBody()
+	require.Nil(t, err)
+	pu, err := smsf.ParseUplink(ct, bytes.NewReader(body))
+	require.Nil(t, err)
+	assert.Equal(t, &u, pu)
+	assert.Nil(t, pu.TPDU())
+}
+
+func TestDownlink(t *testing.T) {
+	d := fixture.TPDU(t, tpdu.MT, deliverTPDU)
+	dl := smsf.NewDownlinkDeliver(3, 0x11, smsc, d)
+	dl.Transfer.LastMsgIndication = true
+	ct, body, err := dl.MarshalBody()
+	require.Nil(t, err)
+	assert.Nil(t, dl.Transfer.N1MessageContainer)
+	assert.Contains(t, string(body), `"n1MessageContainer":{"n1MessageClass":"SMS","n1MessageContent":{"contentId":"sms"}}`)
+
+	pd, err := smsf.ParseDownlink(ct, bytes.NewReader(body))
+	require.Nil(t, err)
+	assert.True(t, pd.Transfer.LastMsgIndication)
+	require.NotNil(t, pd.Payload)
+	assert.Equal(t, byte(3), pd.Payload.TI)
+	require.NotNil(t, pd.Payload.UD)
+	assert.Equal(t, rp.DataNToMS, pd.Payload.UD.Type)
+	assert.Equal(t, smsc, pd.Payload.UD.OA)
+	assert.Equal(t, d, pd.TPDU())
+}
+
+func TestMarshalBodyError(t *testing.T) {
+	u := smsf.Uplink{}
+	_, _, err := u.MarshalBody()
+	assert.Equal(t, smsf.ErrMissingPart("sms"), err)
+
+	dl := smsf.Downlink{Payload: &cp.Message{TI: 7, Type: cp.Ack}}
+	_, _, err = dl.MarshalBody()
+	assert.Equal(t, tpdu.EncodeError("ti", cp.ErrInvalidTI(7)), err)
+}
+
+func TestParseError(t *testing.T) {
+	dlJSON := `{"n1MessageContainer":{"n1MessageClass":"SMS","n1MessageContent":{"contentId":"sms"}}}`
+	patterns := []struct {
+		name  string
+		parts [][3]string
+		ct    string
+		err   error
+	}{
+		{
+			"content type",
+			nil,
+			"application/json",
+			smsf.ErrUnsupportedContentType("application/json"),
+		},
+		{
+			"no json",
+			[][3]string{{smsf.ContentType, "sms", "\x09\x04"}},
+			"",
+			smsf.ErrMissingPart("application/json"),
+		},
+		{
+			"no binary",
+			[][3]string{{"application/json", "", dlJSON}},
+			"",
+			smsf.ErrMissingPart("sms"),
+		},
+		{
+			"content id mismatch",
+			[][3]string{{"application/json", "", dlJSON}, {smsf.ContentType, "other", "\x09\x04"}},
+			"",
+			smsf.ErrMissingPart("sms"),
+		},
+		{
+			"no container",
+			[][3]string{{"application/json", "", `{}`}, {smsf.ContentType, "sms", "\x09\x04"}},
+			"",
+			smsf.ErrMissingN1MessageContainer,
+		},
+		{
+			"class",
+			[][3]string{
+				{"application/json", "", `{"n1MessageContainer":{"n1MessageClass":"SM","n1MessageContent":{"contentId":"sms"}}}`},
+				{smsf.ContentType, "sms", "\x09\x04"},
+			},
+			"",
+			smsf.ErrUnexpectedMessageClass("SM"),
+		},
+		{
+			"cp",
+			[][3]string{{"application/json", "", dlJSON}, {smsf.ContentType, "<sms>", "\x08\x04"}},
+			"",
+			tpdu.NewDecodeError("pd", 0, cp.ErrInvalidProtocolDiscriminator(8)),
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			ct, body := multipartBody(t, p.parts...)
+			if p.ct != "" {
+				ct = p.ct
+			}
+			_, err := smsf.ParseDownlink(ct, bytes.NewReader(body))
+			assert.Equal(t, p.err, err)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUplinkHTTP(t *testing.T) {
+	var path string
+	var rx *smsf.Uplink
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		u, err := smsf.ReadUplink(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rx = u
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(smsf.SMSRecordDeliveryData{
+			SMSRecordID:    u.Record.SMSRecordID,
+			DeliveryStatus: smsf.DeliverySMSFAccepted,
+		})
+	}))
+	defer ts.Close()
+
+	s := fixture.TPDU(t, tpdu.MO, submitTPDU)
+	req, err := smsf.NewUplinkRequest(ts.URL, supi, smsf.NewUplinkSubmit("rec1", 0, 1, smsc, s))
+	require.Nil(t, err)
+	resp, err := ts.Client().Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/nsmsf-sms/v2/ue-contexts/"+supi+"/sendsms", path)
+	require.NotNil(t, rx)
+	assert.Equal(t, s, rx.TPDU())
+
+	dd := smsf.SMSRecordDeliveryData{}
+	err = json.NewDecoder(resp.Body).Decode(&dd)
+	require.Nil(t, err)
+	assert.Equal(t, smsf.SMSRecordDeliveryData{SMSRecordID: "rec1", DeliveryStatus: smsf.DeliverySMSFAccepted}, dd)
+}
+
+func TestDownlinkHTTP(t *testing.T) {
+	var path string
+	var rx *smsf.Downlink
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		d, err := smsf.ReadDownlink(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rx = d
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(smsf.N1N2MessageTransferRspData{Cause: smsf.TransferInitiated})
+	}))
+	defer ts.Close()
+
+	d := fixture.TPDU(t, tpdu.MT, deliverTPDU)
+	req, err := smsf.NewDownlinkRequest(ts.URL, supi, smsf.NewDownlinkDeliver(0, 1, smsc, d))
+	require.Nil(t, err)
+	resp, err := ts.Client().Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/namf-comm/v1/ue-contexts/"+supi+"/n1-n2-messages", path)
+	require.NotNil(t, rx)
+	assert.Equal(t, d, rx.TPDU())
+
+	// not multipart
+	req, err = http.NewRequest(http.MethodPost, ts.URL, strings.NewReader("{}"))
+	require.Nil(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp2, err := ts.Client().Do(req)
+	require.Nil(t, err)
+	resp2.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp2.StatusCode)
+}
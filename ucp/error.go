@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ucp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedOT indicates the operation type of the message is not
+// supported.
+type ErrUnsupportedOT OT
+
+func (e ErrUnsupportedOT) Error() string {
+	return fmt.Sprintf("ucp: unsupported operation type: %02d", int(e))
+}
+
+// ErrInvalidLength indicates the LEN of a message does not match its actual
+// length.
+type ErrInvalidLength int
+
+func (e ErrInvalidLength) Error() string {
+	return fmt.Sprintf("ucp: invalid length: %d", int(e))
+}
+
+// ErrInvalidChecksum indicates the checksum of a message does not match its
+// contents.
+type ErrInvalidChecksum string
+
+func (e ErrInvalidChecksum) Error() string {
+	return fmt.Sprintf("ucp: invalid checksum: '%s'", string(e))
+}
+
+// ErrInvalidTime indicates a time field is not in the UCP time format.
+type ErrInvalidTime string
+
+func (e ErrInvalidTime) Error() string {
+	return fmt.Sprintf("ucp: invalid time: '%s'", string(e))
+}
+
+var (
+	// ErrUnsupportedSmsType indicates the TPDU cannot be converted to or from
+	// a UCP message.
+	ErrUnsupportedSmsType = errors.New("ucp: unsupported SMS type")
+)
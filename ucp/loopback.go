@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ucp
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Loopback is a minimal UCP SMSC, intended for testing, that delivers each
+// message submitted on a connection back to that connection.
+//
+// The zero value is ready for use.
+type Loopback struct {
+	// Clock, if set, provides the SCTS of submitted messages.
+	// Defaults to time.Now.
+	Clock func() time.Time
+}
+
+// Serve accepts UCP connections on the listener and serves each with
+// ServeConn.
+//
+// Serve returns when the listener is closed.
+func (l *Loopback) Serve(lis net.Listener) error {
+	for {
+		nc, err := lis.Accept()
+		if err != nil {
+			return err
+		}
+		go l.ServeConn(nc)
+	}
+}
+
+// ServeConn serves a UCP client on the connection until the connection is
+// closed.
+//
+// The client must open a session with a 60 operation before submitting
+// messages with 51 operations.  Each submitted message is returned to the
+// client as a 52 operation, originating from the OAdC of the submission, or
+// the OAdC of the session if that is empty.  If notification is requested
+// then a 53 operation reporting the delivery follows the 52.
+// Results returned by the client are ignored.
+func (l *Loopback) ServeConn(nc net.Conn) {
+	c := loopConn{nc: nc, clock: l.Clock}
+	if c.clock == nil {
+		c.clock = time.Now
+	}
+	c.cond = sync.NewCond(&c.mu)
+	go c.send()
+	defer c.close()
+	r := bufio.NewReader(nc)
+	for {
+		m, err := ReadMessage(r)
+		if err != nil {
+			if _, ok := err.(tpdu.DecodeError); ok {
+				// the malformed message has been consumed, so skip it
+				continue
+			}
+			return
+		}
+		if m.Result {
+			continue
+		}
+		switch m.OT {
+		case OTSession:
+			c.addr = m.OAdC
+			c.write(m.Response(""))
+		case OTSubmit:
+			c.submit(m)
+		default:
+			c.write(m.Response(ECNotSupported))
+		}
+	}
+}
+
+// loopConn is a UCP connection served by the Loopback.
+type loopConn struct {
+	nc    net.Conn
+	clock func() time.Time
+	addr  string
+
+	// outbound messages are queued and written by a separate goroutine so
+	// the reader never blocks on a write.
+	mu     sync.Mutex // covers the fields below
+	cond   *sync.Cond
+	trn    int
+	out    []*Message
+	closed bool
+}
+
+func (c *loopConn) submit(m *Message) {
+	if c.addr == "" {
+		c.write(m.Response(ECAuthFailure))
+		return
+	}
+	s, err := m.TPDU()
+	if err != nil {
+		c.write(m.Response(ECSyntax))
+		return
+	}
+	oadc, otoa := m.OAdC, m.OTOA
+	if oadc == "" {
+		oadc, otoa = c.addr, ""
+	}
+	oa, err := addressFromUCP(oadc, otoa)
+	if err != nil {
+		c.write(m.Response(ECSyntax))
+		return
+	}
+	scts := c.clock().Truncate(time.Second)
+	d, _ := tpdu.NewDeliver()
+	d.OA = oa
+	d.SCTS = tpdu.Timestamp{Time: scts}
+	d.FirstOctet |= s.FirstOctet & tpdu.FoRP
+	d.PID = s.PID
+	d.DCS = s.DCS
+	if len(s.UDH) > 0 {
+		d.SetUDH(s.UDH)
+	}
+	d.UD = s.UD
+	dm, err := FromTPDU(d)
+	if err != nil {
+		c.write(m.Response(ECSyntax))
+		return
+	}
+	r := m.Response("")
+	r.SM = m.AdC + ":" + FormatTime(scts)
+	c.write(r)
+	c.request(dm)
+	if !s.FirstOctet.SRR() {
+		return
+	}
+	c.request(&Message{
+		OT:    OTNotification,
+		AdC:   m.AdC,
+		OAdC:  oadc,
+		OTOA:  otoa,
+		SCTS:  FormatTime(scts),
+		Dst:   DstDelivered,
+		DSCTS: FormatTime(c.clock()),
+	})
+}
+
+// request assigns the next TRN to the operation and queues it.
+func (c *loopConn) request(m *Message) {
+	c.mu.Lock()
+	m.TRN = c.trn
+	c.trn = (c.trn + 1) % 100
+	c.mu.Unlock()
+	c.write(m)
+}
+
+// write queues the message to be sent to the client.
+func (c *loopConn) write(m *Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.out = append(c.out, m)
+	c.cond.Signal()
+}
+
+// send writes queued messages to the client until the conn is closed.
+func (c *loopConn) send() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		for len(c.out) == 0 && !c.closed {
+			c.cond.Wait()
+		}
+		if len(c.out) == 0 {
+			break
+		}
+		m := c.out[0]
+		c.out = c.out[1:]
+		c.mu.Unlock()
+		err := WriteMessage(c.nc, m)
+		c.mu.Lock()
+		if err != nil {
+			c.closed = true
+			c.out = nil
+		}
+	}
+	c.nc.Close()
+}
+
+// close closes the conn once any queued messages have been sent.
+func (c *loopConn) close() {
+	c.mu.Lock()
+	c.closed = true
+	c.cond.Signal()
+	c.mu.Unlock()
+}
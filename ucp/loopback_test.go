@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ucp_test
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/ucp"
+)
+
+// client is a minimal UCP client for exercising the Loopback.
+type client struct {
+	t  *testing.T
+	nc net.Conn
+	r  *bufio.Reader
+}
+
+func newClient(t *testing.T, nc net.Conn) *client {
+	nc.SetDeadline(time.Now().Add(time.Second))
+	return &client{t: t, nc: nc, r: bufio.NewReader(nc)}
+}
+
+func (c *client) write(m *ucp.Message) {
+	require.Nil(c.t, ucp.WriteMessage(c.nc, m))
+}
+
+func (c *client) read() *ucp.Message {
+	m, err := ucp.ReadMessage(c.r)
+	require.Nil(c.t, err)
+	return m
+}
+
+func TestLoopbackServeConn(t *testing.T) {
+	clock := func() time.Time {
+		return time.Date(2020, time.April, 12, 10, 30, 15, 0, time.UTC)
+	}
+	l := ucp.Loopback{Clock: clock}
+	cs, ss := net.Pipe()
+	defer cs.Close()
+	go l.ServeConn(ss)
+	c := newClient(t, cs)
+
+	// submit before login
+	s, _ := tpdu.NewSubmit(tpdu.WithDA(tpdu.NewAddress(tpdu.FromNumber("2222"))))
+	s.UD = []byte("hello")
+	m, err := ucp.FromTPDU(s)
+	require.Nil(t, err)
+	c.write(m)
+	r := c.read()
+	assert.Equal(t, &ucp.Message{OT: ucp.OTSubmit, Result: true, EC: ucp.ECAuthFailure}, r)
+
+	// login
+	c.write(&ucp.Message{
+		TRN:  1,
+		OT:   ucp.OTSession,
+		OAdC: "1111",
+		OTON: "6",
+		ONPI: "5",
+		STYP: "1",
+		PWD:  ucp.EncodeIRA([]byte("secret")),
+		VERS: "0100",
+	})
+	r = c.read()
+	assert.Equal(t, &ucp.Message{TRN: 1, OT: ucp.OTSession, Result: true, ACK: true}, r)
+
+	// unsupported
+	c.write(&ucp.Message{TRN: 2, OT: ucp.OTDeliver})
+	r = c.read()
+	assert.Equal(t, &ucp.Message{TRN: 2, OT: ucp.OTDeliver, Result: true, EC: ucp.ECNotSupported}, r)
+
+	// invalid
+	c.write(&ucp.Message{TRN: 3, OT: ucp.OTSubmit, MT: "9"})
+	r = c.read()
+	assert.Equal(t, &ucp.Message{TRN: 3, OT: ucp.OTSubmit, Result: true, EC: ucp.ECSyntax}, r)
+
+	// malformed messages are ignored
+	_, err = cs.Write([]byte("\x0201/00019/R/60/A//00\x03"))
+	require.Nil(t, err)
+
+	// submit with notification
+	msg := "this is a very long message that does not fit in a single SMS message, at least it will if I keep adding more to it as 160 characters is more than you might think"
+	e := sms.NewEncoder(sms.AsSubmit, sms.To("2222"))
+	tpdus, err := e.Encode([]byte(msg))
+	require.Nil(t, err)
+	require.Equal(t, 2, len(tpdus))
+	col := sms.NewCollector()
+	var segs []*tpdu.TPDU
+	for i := range tpdus {
+		tpdus[i].FirstOctet |= tpdu.FoSRR
+		m, err := ucp.FromTPDU(&tpdus[i])
+		require.Nil(t, err)
+		m.TRN = 10 + i
+		c.write(m)
+		r = c.read()
+		assert.Equal(t, &ucp.Message{
+			TRN:    10 + i,
+			OT:     ucp.OTSubmit,
+			Result: true,
+			ACK:    true,
+			SM:     "2222:120420103015",
+		}, r)
+
+		d := c.read()
+		require.Equal(t, ucp.OTDeliver, d.OT)
+		assert.False(t, d.Result)
+		c.write(d.Response(""))
+		dt, err := d.TPDU()
+		require.Nil(t, err)
+		assert.Equal(t, "1111", dt.OA.Addr)
+		assert.True(t, clock().Equal(dt.SCTS.Time))
+		segs, err = col.Collect(*dt)
+		require.Nil(t, err)
+
+		n := c.read()
+		require.Equal(t, ucp.OTNotification, n.OT)
+		assert.Equal(t, d.TRN+1, n.TRN)
+		c.write(n.Response(""))
+		assert.Equal(t, "1111", n.OAdC)
+		sr, err := n.TPDU()
+		require.Nil(t, err)
+		assert.Equal(t, tpdu.SmsStatusReport, sr.SmsType())
+		assert.Equal(t, "2222", sr.RA.Addr)
+		assert.Equal(t, byte(0), sr.ST)
+	}
+	require.NotNil(t, segs)
+	dmsg, err := sms.Decode(segs)
+	require.Nil(t, err)
+	assert.Equal(t, msg, string(dmsg))
+}
+
+func TestLoopbackServe(t *testing.T) {
+	l := ucp.Loopback{}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer lis.Close()
+	go l.Serve(lis)
+	nc, err := net.Dial("tcp", lis.Addr().String())
+	require.Nil(t, err)
+	defer nc.Close()
+	c := newClient(t, nc)
+
+	c.write(&ucp.Message{OT: ucp.OTSession, OAdC: "1111", STYP: "1"})
+	r := c.read()
+	assert.True(t, r.ACK)
+
+	s, _ := tpdu.NewSubmit(tpdu.WithDA(tpdu.NewAddress(tpdu.FromNumber("2222"))))
+	s.UD = []byte("hello")
+	m, err := ucp.FromTPDU(s)
+	require.Nil(t, err)
+	m.TRN = 1
+	m.OAdC = "3333"
+	c.write(m)
+	r = c.read()
+	assert.True(t, r.ACK)
+	d := c.read()
+	dt, err := d.TPDU()
+	require.Nil(t, err)
+	assert.Equal(t, "3333", dt.OA.Addr)
+	assert.Equal(t, []byte("hello"), []byte(dt.UD))
+}
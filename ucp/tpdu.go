@@ -0,0 +1,487 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ucp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warthog618/sms/encoding/gsm7"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/encoding/ucs2"
+)
+
+// UCP MT values, indicating the encoding of the Msg field.
+const (
+	// MTNumeric indicates the Msg contains numeric characters only.
+	MTNumeric = "2"
+
+	// MTAlphanumeric indicates the Msg contains IRA hex encoded text.
+	MTAlphanumeric = "3"
+
+	// MTTransparent indicates the Msg contains hex encoded data, with the
+	// number of bits provided in NB.
+	MTTransparent = "4"
+)
+
+// UCP OTOA values, indicating the type of the OAdC.
+const (
+	// OTOAInternational indicates the OAdC is an international number.
+	OTOAInternational = "1139"
+
+	// OTOAAlphanumeric indicates the OAdC is alphanumeric, encoded as the
+	// number of semi-octets followed by the packed GSM7 address, in hex.
+	OTOAAlphanumeric = "5039"
+)
+
+// UCP Dst values, indicating the status of the message in a notification.
+const (
+	// DstDelivered indicates the message was delivered.
+	DstDelivered = "0"
+
+	// DstBuffered indicates the message is buffered, pending delivery.
+	DstBuffered = "1"
+
+	// DstNotDelivered indicates the message could not be delivered.
+	DstNotDelivered = "2"
+)
+
+// UCP XSer service types.
+const (
+	// XSerUDH indicates the XSer contains the GSM UDH, including the UDHL.
+	XSerUDH = 0x01
+
+	// XSerDCS indicates the XSer contains the GSM DCS.
+	XSerDCS = 0x02
+)
+
+// timeFormat is the format of UCP time fields, such as SCTS.
+//
+// The VP and DDT omit the seconds.
+const timeFormat = "020106150405"
+
+// XSer is a single extra service contained in the XSer field.
+type XSer struct {
+	Type byte
+	Data []byte
+}
+
+// ParseXSer parses the hex encoded XSer field into its constituent services.
+func ParseXSer(s string) ([]XSer, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, tpdu.ErrInvalid
+	}
+	var xx []XSer
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, tpdu.ErrUnderflow
+		}
+		l := int(b[1])
+		if len(b) < 2+l {
+			return nil, tpdu.ErrUnderflow
+		}
+		xx = append(xx, XSer{Type: b[0], Data: append([]byte(nil), b[2:2+l]...)})
+		b = b[2+l:]
+	}
+	return xx, nil
+}
+
+// FormatXSer returns the hex encoded XSer field containing the services.
+func FormatXSer(xx []XSer) string {
+	var b []byte
+	for _, x := range xx {
+		b = append(b, x.Type, byte(len(x.Data)))
+		b = append(b, x.Data...)
+	}
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// EncodeIRA returns the IRA hex encoding of the text, as used by the Msg of
+// MT 3 and the PWD.
+//
+// The text is taken to be Latin1, of which IRA is a subset.
+func EncodeIRA(text []byte) string {
+	return strings.ToUpper(hex.EncodeToString(text))
+}
+
+// DecodeIRA returns the text contained in an IRA hex encoded field.
+func DecodeIRA(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, tpdu.ErrInvalid
+	}
+	return b, nil
+}
+
+// FormatTime returns the time in the UCP time format, DDMMYYhhmmss.
+//
+// The time is converted to UTC, as UCP times do not specify a timezone.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// FormatVP returns the time in the UCP validity period format, DDMMYYhhmm.
+//
+// The time is converted to UTC, as UCP times do not specify a timezone.
+func FormatVP(t time.Time) string {
+	return t.UTC().Format(timeFormat[:10])
+}
+
+// ParseTime parses a UCP time, either DDMMYYhhmmss or DDMMYYhhmm.
+//
+// The time is taken to be in UTC, as UCP times do not specify a timezone.
+func ParseTime(s string) (time.Time, error) {
+	switch len(s) {
+	case 10, 12:
+		t, err := time.ParseInLocation(timeFormat[:len(s)], s, time.UTC)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, ErrInvalidTime(s)
+}
+
+// ConversionOption modifies the behaviour of FromTPDU.
+type ConversionOption func(*conversionConfig)
+
+type conversionConfig struct {
+	clock func() time.Time
+}
+
+// WithClock sets the source of the current time, against which relative
+// validity periods are converted to absolute.
+//
+// The default is time.Now.
+func WithClock(clock func() time.Time) ConversionOption {
+	return func(c *conversionConfig) {
+		c.clock = clock
+	}
+}
+
+// FromTPDU creates the UCP operation corresponding to the TPDU.
+//
+// SMS-SUBMIT TPDUs are converted to 51 operations, SMS-DELIVER TPDUs to 52
+// operations, and SMS-STATUS-REPORT TPDUs to 53 operations.
+//
+// GSM7 UD that can be represented in IRA is provided as MT 3 text, while
+// other UD is provided as MT 4 transparent data, with 7bit UD packed.  The
+// UDH, if any, is provided in the XSer, as is the DCS if it is not the
+// default, or if the UD is transparent.
+//
+// Relative validity periods are converted to absolute relative to the
+// current time, as provided by WithClock.  The OAdC of a 51, and of a 53, is
+// not contained in the TPDU and must be set by the caller, as must the TRN.
+func FromTPDU(t *tpdu.TPDU, options ...ConversionOption) (*Message, error) {
+	cfg := conversionConfig{clock: time.Now}
+	for _, o := range options {
+		o(&cfg)
+	}
+	m := Message{}
+	switch t.SmsType() {
+	case tpdu.SmsSubmit:
+		m.OT = OTSubmit
+		m.AdC = t.DA.Addr
+		if t.FirstOctet.SRR() {
+			// request notification of both delivery and non-delivery
+			m.NRq = "1"
+			m.NT = "3"
+		}
+		switch t.VP.Format {
+		case tpdu.VpfNotPresent:
+		case tpdu.VpfAbsolute:
+			m.VP = FormatVP(t.VP.Time.Time)
+		default:
+			m.VP = FormatVP(cfg.clock().Add(t.VP.Duration))
+		}
+	case tpdu.SmsDeliver:
+		m.OT = OTDeliver
+		m.OAdC, m.OTOA = addressFromTPDU(t.OA)
+		m.SCTS = FormatTime(t.SCTS.Time)
+	case tpdu.SmsStatusReport:
+		m.OT = OTNotification
+		m.AdC = t.RA.Addr
+		m.SCTS = FormatTime(t.SCTS.Time)
+		m.DSCTS = FormatTime(t.DT.Time)
+		m.Dst = dstFromST(t.ST)
+		return &m, nil
+	default:
+		return nil, ErrUnsupportedSmsType
+	}
+	if t.PID != 0 {
+		m.RPID = fmt.Sprintf("%04d", t.PID)
+	}
+	if t.FirstOctet.RP() {
+		m.RPI = "1"
+	}
+	alpha, err := t.DCS.Alphabet()
+	if err != nil {
+		return nil, tpdu.EncodeError("dcs", err)
+	}
+	switch alpha {
+	case tpdu.Alpha7Bit:
+		if text, ok := septetsToIRA(t.UD); ok {
+			m.MT = MTAlphanumeric
+			m.Msg = EncodeIRA(text)
+			break
+		}
+		m.MT = MTTransparent
+		m.NB = strconv.Itoa(len(t.UD) * 7)
+		m.Msg = EncodeIRA(gsm7.Pack7Bit(t.UD, 0))
+	default:
+		m.MT = MTTransparent
+		m.NB = strconv.Itoa(len(t.UD) * 8)
+		m.Msg = EncodeIRA(t.UD)
+	}
+	var xx []XSer
+	if len(t.UDH) > 0 {
+		udh, err := t.UDH.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		xx = append(xx, XSer{Type: XSerUDH, Data: udh})
+	}
+	if t.DCS != 0 || m.MT == MTTransparent {
+		xx = append(xx, XSer{Type: XSerDCS, Data: []byte{byte(t.DCS)}})
+	}
+	m.XSer = FormatXSer(xx)
+	return &m, nil
+}
+
+// TPDU creates the TPDU corresponding to a 51, 52 or 53 operation.
+//
+// This is the reverse of FromTPDU.
+// MT 3 text is converted to GSM7 if possible, else UCS2.
+// MT 4 data is taken to be 8bit unless the XSer provides a DCS.
+func (m *Message) TPDU() (*tpdu.TPDU, error) {
+	if m.Result {
+		return nil, ErrUnsupportedOT(m.OT)
+	}
+	var t *tpdu.TPDU
+	switch m.OT {
+	case OTSubmit:
+		t, _ = tpdu.NewSubmit()
+		t.DA = tpdu.NewAddress(tpdu.FromNumber(m.AdC))
+		if m.NRq == "1" {
+			t.FirstOctet |= tpdu.FoSRR
+		}
+		if m.VP != "" {
+			vpt, err := ParseTime(m.VP)
+			if err != nil {
+				return nil, tpdu.NewDecodeError("vp", 0, err)
+			}
+			vp := tpdu.ValidityPeriod{}
+			vp.SetAbsolute(tpdu.Timestamp{Time: vpt})
+			t.SetVP(vp)
+		}
+	case OTDeliver:
+		t, _ = tpdu.NewDeliver()
+		oa, err := addressFromUCP(m.OAdC, m.OTOA)
+		if err != nil {
+			return nil, tpdu.NewDecodeError("oadc", 0, err)
+		}
+		t.OA = oa
+		scts, err := ParseTime(m.SCTS)
+		if err != nil {
+			return nil, tpdu.NewDecodeError("scts", 0, err)
+		}
+		t.SCTS = tpdu.Timestamp{Time: scts}
+	case OTNotification:
+		return m.statusReport()
+	default:
+		return nil, ErrUnsupportedOT(m.OT)
+	}
+	if m.RPID != "" {
+		pid, err := strconv.ParseUint(m.RPID, 10, 8)
+		if err != nil {
+			return nil, tpdu.NewDecodeError("rpid", 0, tpdu.ErrInvalid)
+		}
+		t.PID = byte(pid)
+	}
+	if m.RPI == "1" {
+		t.FirstOctet |= tpdu.FoRP
+	}
+	xx, err := ParseXSer(m.XSer)
+	if err != nil {
+		return nil, tpdu.NewDecodeError("xser", 0, err)
+	}
+	var dcs *tpdu.DCS
+	for _, x := range xx {
+		switch x.Type {
+		case XSerUDH:
+			var udh tpdu.UserDataHeader
+			if _, err := udh.UnmarshalBinary(x.Data); err != nil {
+				return nil, tpdu.NewDecodeError("xser", 0, err)
+			}
+			t.SetUDH(udh)
+		case XSerDCS:
+			if len(x.Data) != 1 {
+				return nil, tpdu.NewDecodeError("xser", 0, tpdu.ErrInvalid)
+			}
+			d := tpdu.DCS(x.Data[0])
+			dcs = &d
+		}
+	}
+	msg, err := DecodeIRA(m.Msg)
+	if err != nil {
+		return nil, tpdu.NewDecodeError("msg", 0, err)
+	}
+	switch m.MT {
+	case MTAlphanumeric:
+		var ud []byte
+		t.DCS, ud = textToUD(msg)
+		if dcs != nil && t.DCS == 0 {
+			// preserve any class provided with the GSM7 text
+			if alpha, _ := dcs.Alphabet(); alpha == tpdu.Alpha7Bit {
+				t.DCS = *dcs
+			}
+		}
+		msg = ud
+	case MTTransparent:
+		t.DCS = tpdu.Dcs8BitData
+		if dcs != nil {
+			t.DCS = *dcs
+		}
+		nb, err := strconv.Atoi(m.NB)
+		if err != nil || nb > len(msg)*8 {
+			return nil, tpdu.NewDecodeError("nb", 0, tpdu.ErrInvalid)
+		}
+		alpha, err := t.DCS.Alphabet()
+		if err != nil {
+			return nil, tpdu.NewDecodeError("xser", 0, err)
+		}
+		switch alpha {
+		case tpdu.Alpha7Bit:
+			msg = gsm7.Unpack7Bit(msg, 0)
+			if len(msg) > nb/7 {
+				msg = msg[:nb/7]
+			}
+		case tpdu.AlphaUCS2:
+			if len(msg)&0x01 == 0x01 {
+				return nil, tpdu.NewDecodeError("msg", 0, tpdu.ErrOddUCS2Length)
+			}
+		}
+	default:
+		return nil, tpdu.NewDecodeError("mt", 0, tpdu.ErrInvalid)
+	}
+	if len(msg) > 0 {
+		t.UD = msg
+	}
+	return t, nil
+}
+
+// statusReport returns the SMS-STATUS-REPORT corresponding to the 53
+// notification.
+func (m *Message) statusReport() (*tpdu.TPDU, error) {
+	t, _ := tpdu.New(tpdu.SmsStatusReport)
+	t.RA = tpdu.NewAddress(tpdu.FromNumber(m.AdC))
+	scts, err := ParseTime(m.SCTS)
+	if err != nil {
+		return nil, tpdu.NewDecodeError("scts", 0, err)
+	}
+	t.SCTS = tpdu.Timestamp{Time: scts}
+	dt, err := ParseTime(m.DSCTS)
+	if err != nil {
+		return nil, tpdu.NewDecodeError("dscts", 0, err)
+	}
+	t.DT = tpdu.Timestamp{Time: dt}
+	switch m.Dst {
+	case DstDelivered:
+		t.ST = 0x00 // received by the SME
+	case DstBuffered:
+		t.ST = 0x20 // congestion, SC still trying
+	case DstNotDelivered:
+		t.ST = 0x40 // remote procedure error
+	default:
+		return nil, tpdu.NewDecodeError("dst", 0, tpdu.ErrInvalid)
+	}
+	return t, nil
+}
+
+// dstFromST returns the Dst corresponding to a TP-ST, as defined in 3GPP TS
+// 23.040 Section 9.2.3.15.
+func dstFromST(st byte) string {
+	switch {
+	case st < 0x20:
+		return DstDelivered
+	case st < 0x40:
+		return DstBuffered
+	default:
+		return DstNotDelivered
+	}
+}
+
+// addressFromTPDU returns the OAdC and OTOA corresponding to the TPDU
+// Address.
+func addressFromTPDU(a tpdu.Address) (string, string) {
+	switch a.TypeOfNumber() {
+	case tpdu.TonAlphanumeric:
+		b, err := a.MarshalBinary()
+		if err != nil {
+			break
+		}
+		// drop the TOA
+		b = append(b[:1], b[2:]...)
+		return strings.ToUpper(hex.EncodeToString(b)), OTOAAlphanumeric
+	case tpdu.TonInternational:
+		return a.Addr, OTOAInternational
+	}
+	return a.Addr, ""
+}
+
+// addressFromUCP returns the TPDU Address corresponding to the OAdC and OTOA.
+func addressFromUCP(addr, otoa string) (tpdu.Address, error) {
+	if otoa != OTOAAlphanumeric {
+		return tpdu.NewAddress(tpdu.FromNumber(addr)), nil
+	}
+	b, err := hex.DecodeString(addr)
+	if err != nil || len(b) == 0 {
+		return tpdu.Address{}, tpdu.ErrInvalid
+	}
+	a := tpdu.NewAddress()
+	a.SetTypeOfNumber(tpdu.TonAlphanumeric)
+	// restore the TOA
+	b = append([]byte{b[0], a.TOA}, b[1:]...)
+	if _, err := a.UnmarshalBinary(b); err != nil {
+		return tpdu.Address{}, err
+	}
+	return a, nil
+}
+
+// septetsToIRA converts GSM7 septets to Latin1 text, returning false if that
+// is not possible.
+func septetsToIRA(ud []byte) ([]byte, bool) {
+	s, err := gsm7.Decode(ud)
+	if err != nil {
+		return nil, false
+	}
+	r := []rune(string(s))
+	text := make([]byte, len(r))
+	for i, c := range r {
+		if c > 0xff {
+			return nil, false
+		}
+		text[i] = byte(c)
+	}
+	return text, true
+}
+
+// textToUD converts Latin1 text into GSM7 septets, or UCS2 if that is not
+// possible.
+func textToUD(text []byte) (tpdu.DCS, []byte) {
+	r := make([]rune, len(text))
+	for i, c := range text {
+		// IRA is a subset of Latin1, which maps directly to Unicode.
+		r[i] = rune(c)
+	}
+	ud, err := gsm7.Encode([]byte(string(r)))
+	if err == nil {
+		return 0x00, ud
+	}
+	return tpdu.DcsUCS2Data, ucs2.Encode(r)
+}
@@ -0,0 +1,378 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ucp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/ucp"
+)
+
+func TestXSer(t *testing.T) {
+	xx := []ucp.XSer{
+		{Type: ucp.XSerUDH, Data: []byte{0x05, 0x00, 0x03, 0x2a, 0x02, 0x01}},
+		{Type: ucp.XSerDCS, Data: []byte{0x08}},
+	}
+	s := ucp.FormatXSer(xx)
+	assert.Equal(t, "01060500032A0201020108", s)
+	pxx, err := ucp.ParseXSer(s)
+	require.Nil(t, err)
+	assert.Equal(t, xx, pxx)
+
+	pxx, err = ucp.ParseXSer("")
+	assert.Nil(t, err)
+	assert.Nil(t, pxx)
+	_, err = ucp.ParseXSer("0X")
+	assert.Equal(t, tpdu.ErrInvalid, err)
+	_, err = ucp.ParseXSer("01")
+	assert.Equal(t, tpdu.ErrUnderflow, err)
+	_, err = ucp.ParseXSer("010201")
+	assert.Equal(t, tpdu.ErrUnderflow, err)
+}
+
+func TestIRA(t *testing.T) {
+	text := []byte{'H', 'e', 'l', 'l', 'o', ' ', 0xfc} // Latin1
+	s := ucp.EncodeIRA(text)
+	assert.Equal(t, "48656C6C6F20FC", s)
+	b, err := ucp.DecodeIRA(s)
+	require.Nil(t, err)
+	assert.Equal(t, text, b)
+	_, err = ucp.DecodeIRA("4")
+	assert.Equal(t, tpdu.ErrInvalid, err)
+}
+
+func TestTime(t *testing.T) {
+	tm := time.Date(2020, time.April, 12, 10, 30, 15, 0, time.UTC)
+	assert.Equal(t, "120420103015", ucp.FormatTime(tm))
+	assert.Equal(t, "1204201030", ucp.FormatVP(tm))
+	pt, err := ucp.ParseTime("120420103015")
+	require.Nil(t, err)
+	assert.Equal(t, tm, pt)
+	pt, err = ucp.ParseTime("1204201030")
+	require.Nil(t, err)
+	assert.Equal(t, tm.Truncate(time.Minute), pt)
+	_, err = ucp.ParseTime("12042010")
+	assert.Equal(t, ucp.ErrInvalidTime("12042010"), err)
+	_, err = ucp.ParseTime("320420103015")
+	assert.Equal(t, ucp.ErrInvalidTime("320420103015"), err)
+}
+
+func TestFromTPDU(t *testing.T) {
+	scts := tpdu.Timestamp{Time: time.Date(2020, time.April, 12, 10, 30, 15, 0, time.UTC)}
+	vp := tpdu.ValidityPeriod{}
+	vp.SetAbsolute(tpdu.Timestamp{Time: time.Date(2020, time.April, 13, 10, 30, 0, 0, time.UTC)})
+	patterns := []struct {
+		name string
+		in   func() *tpdu.TPDU
+		out  *ucp.Message
+	}{
+		{
+			"submit",
+			func() *tpdu.TPDU {
+				s, _ := tpdu.NewSubmit()
+				s.DA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				s.FirstOctet |= tpdu.FoSRR | tpdu.FoRP
+				s.SetVP(vp)
+				s.PID = 0x41
+				s.UD = []byte("hello")
+				return s
+			},
+			&ucp.Message{
+				OT:   ucp.OTSubmit,
+				AdC:  "1234",
+				NRq:  "1",
+				NT:   "3",
+				VP:   "1304201030",
+				RPID: "0065",
+				RPI:  "1",
+				MT:   ucp.MTAlphanumeric,
+				Msg:  "68656C6C6F",
+			},
+		},
+		{
+			"deliver",
+			func() *tpdu.TPDU {
+				d, _ := tpdu.NewDeliver()
+				d.OA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				d.SCTS = scts
+				d.UD = []byte("hello")
+				return d
+			},
+			&ucp.Message{
+				OT:   ucp.OTDeliver,
+				OAdC: "1234",
+				OTOA: ucp.OTOAInternational,
+				SCTS: "120420103015",
+				MT:   ucp.MTAlphanumeric,
+				Msg:  "68656C6C6F",
+			},
+		},
+		{
+			"alphanumeric",
+			func() *tpdu.TPDU {
+				d, _ := tpdu.NewDeliver()
+				d.OA = tpdu.NewAddress()
+				d.OA.SetTypeOfNumber(tpdu.TonAlphanumeric)
+				d.OA.Addr = "ALPHA"
+				d.SCTS = scts
+				return d
+			},
+			&ucp.Message{
+				OT:   ucp.OTDeliver,
+				OAdC: "094126141904",
+				OTOA: ucp.OTOAAlphanumeric,
+				SCTS: "120420103015",
+				MT:   ucp.MTAlphanumeric,
+			},
+		},
+		{
+			"ucs2",
+			func() *tpdu.TPDU {
+				s, _ := tpdu.NewSubmit()
+				s.DA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				s.DCS = tpdu.DcsUCS2Data
+				s.SetUDH(tpdu.UserDataHeader{{ID: 0, Data: []byte{0x2a, 0x02, 0x01}}})
+				s.UD = []byte{0x00, 0x68, 0x00, 0x69}
+				return s
+			},
+			&ucp.Message{
+				OT:   ucp.OTSubmit,
+				AdC:  "1234",
+				MT:   ucp.MTTransparent,
+				NB:   "32",
+				Msg:  "00680069",
+				XSer: "01060500032A0201020108",
+			},
+		},
+		{
+			"unrepresentable gsm7",
+			func() *tpdu.TPDU {
+				s, _ := tpdu.NewSubmit()
+				s.DA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				s.DCS = 0x10 // class 0
+				s.UD = []byte{0x10, 0x12}
+				return s
+			},
+			&ucp.Message{
+				OT:   ucp.OTSubmit,
+				AdC:  "1234",
+				MT:   ucp.MTTransparent,
+				NB:   "14",
+				Msg:  "1009",
+				XSer: "020110",
+			},
+		},
+		{
+			"status report",
+			func() *tpdu.TPDU {
+				r, _ := tpdu.New(tpdu.SmsStatusReport)
+				r.RA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				r.SCTS = scts
+				r.DT = scts
+				r.ST = 0x46
+				return r
+			},
+			&ucp.Message{
+				OT:    ucp.OTNotification,
+				AdC:   "1234",
+				SCTS:  "120420103015",
+				DSCTS: "120420103015",
+				Dst:   ucp.DstNotDelivered,
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			in := p.in()
+			m, err := ucp.FromTPDU(in)
+			require.Nil(t, err)
+			assert.Equal(t, p.out, m)
+
+			// and back again
+			b, err := m.MarshalBinary()
+			require.Nil(t, err)
+			rm := ucp.Message{}
+			require.Nil(t, rm.UnmarshalBinary(b))
+			rt, err := rm.TPDU()
+			require.Nil(t, err)
+			if in.SmsType() == tpdu.SmsStatusReport {
+				assert.Equal(t, byte(0x40), rt.ST)
+				in.ST = rt.ST
+			}
+			assert.Equal(t, in, rt)
+		}
+		t.Run(p.name, f)
+	}
+
+	_, err := ucp.FromTPDU(&tpdu.TPDU{FirstOctet: 0x03})
+	assert.Equal(t, ucp.ErrUnsupportedSmsType, err)
+}
+
+func TestFromTPDURelativeVP(t *testing.T) {
+	s, _ := tpdu.NewSubmit()
+	vp := tpdu.ValidityPeriod{}
+	vp.SetRelative(time.Hour)
+	s.SetVP(vp)
+	now := time.Date(2020, time.May, 17, 23, 2, 50, 0, time.UTC)
+	m, err := ucp.FromTPDU(s, ucp.WithClock(func() time.Time { return now }))
+	require.Nil(t, err)
+	assert.Equal(t, "1805200002", m.VP)
+	pvp, err := ucp.ParseTime(m.VP)
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2020, time.May, 18, 0, 2, 0, 0, time.UTC), pvp)
+}
+
+func TestFromTPDUTimeZone(t *testing.T) {
+	loc := time.FixedZone("SCTS", 8*3600)
+	s, _ := tpdu.NewSubmit()
+	vp := tpdu.ValidityPeriod{}
+	vp.SetRelative(time.Hour)
+	s.SetVP(vp)
+	now := time.Date(2020, time.May, 18, 7, 2, 50, 0, loc)
+	m, err := ucp.FromTPDU(s, ucp.WithClock(func() time.Time { return now }))
+	require.Nil(t, err)
+	assert.Equal(t, "1805200002", m.VP)
+	rt, err := m.TPDU()
+	require.Nil(t, err)
+	assert.True(t, now.Add(time.Hour).Truncate(time.Minute).Equal(rt.VP.Time.Time))
+
+	d, _ := tpdu.NewDeliver()
+	d.OA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+	d.SCTS = tpdu.Timestamp{Time: time.Date(2020, time.May, 18, 7, 2, 50, 0, loc)}
+	d.UD = []byte("hello")
+	m, err = ucp.FromTPDU(d)
+	require.Nil(t, err)
+	assert.Equal(t, "170520230250", m.SCTS)
+	rt, err = m.TPDU()
+	require.Nil(t, err)
+	assert.True(t, d.SCTS.Equal(rt.SCTS.Time))
+
+	sr, _ := tpdu.New(tpdu.SmsStatusReport)
+	sr.RA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+	sr.SCTS = d.SCTS
+	sr.DT = tpdu.Timestamp{Time: time.Date(2020, time.May, 18, 7, 3, 10, 0, loc)}
+	m, err = ucp.FromTPDU(sr)
+	require.Nil(t, err)
+	assert.Equal(t, "170520230250", m.SCTS)
+	assert.Equal(t, "170520230310", m.DSCTS)
+	rt, err = m.TPDU()
+	require.Nil(t, err)
+	assert.True(t, sr.SCTS.Equal(rt.SCTS.Time))
+	assert.True(t, sr.DT.Equal(rt.DT.Time))
+}
+
+func TestTPDU(t *testing.T) {
+	patterns := []struct {
+		name string
+		in   ucp.Message
+		out  func() *tpdu.TPDU
+	}{
+		{
+			"latin1",
+			ucp.Message{OT: ucp.OTSubmit, AdC: "1234", MT: ucp.MTAlphanumeric, Msg: "E9EA"},
+			func() *tpdu.TPDU {
+				s, _ := tpdu.NewSubmit()
+				s.DA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				s.DCS = tpdu.DcsUCS2Data
+				s.UD = []byte{0x00, 0xe9, 0x00, 0xea}
+				return s
+			},
+		},
+		{
+			"class",
+			ucp.Message{OT: ucp.OTSubmit, AdC: "1234", MT: ucp.MTAlphanumeric, Msg: "6869", XSer: "020111"},
+			func() *tpdu.TPDU {
+				s, _ := tpdu.NewSubmit()
+				s.DA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				s.DCS = 0x11
+				s.UD = []byte("hi")
+				return s
+			},
+		},
+		{
+			"transparent",
+			ucp.Message{OT: ucp.OTSubmit, AdC: "1234", MT: ucp.MTTransparent, NB: "16", Msg: "0102"},
+			func() *tpdu.TPDU {
+				s, _ := tpdu.NewSubmit()
+				s.DA = tpdu.NewAddress(tpdu.FromNumber("1234"))
+				s.DCS = tpdu.Dcs8BitData
+				s.UD = []byte{1, 2}
+				return s
+			},
+		},
+	}
+	for _, p := range patterns {
+		f := func(t *testing.T) {
+			tp, err := p.in.TPDU()
+			require.Nil(t, err)
+			assert.Equal(t, p.out(), tp)
+		}
+		t.Run(p.name, f)
+	}
+
+	errPatterns := []struct {
+		name string
+		in   ucp.Message
+		err  error
+	}{
+		{"result", ucp.Message{OT: ucp.OTSubmit, Result: true}, ucp.ErrUnsupportedOT(ucp.OTSubmit)},
+		{"session", ucp.Message{OT: ucp.OTSession}, ucp.ErrUnsupportedOT(ucp.OTSession)},
+		{"vp", ucp.Message{OT: ucp.OTSubmit, VP: "1"}, tpdu.NewDecodeError("vp", 0, ucp.ErrInvalidTime("1"))},
+		{"scts", ucp.Message{OT: ucp.OTDeliver}, tpdu.NewDecodeError("scts", 0, ucp.ErrInvalidTime(""))},
+		{"oadc", ucp.Message{OT: ucp.OTDeliver, OTOA: ucp.OTOAAlphanumeric, OAdC: "X"}, tpdu.NewDecodeError("oadc", 0, tpdu.ErrInvalid)},
+		{"rpid", ucp.Message{OT: ucp.OTSubmit, RPID: "0256"}, tpdu.NewDecodeError("rpid", 0, tpdu.ErrInvalid)},
+		{"xser", ucp.Message{OT: ucp.OTSubmit, XSer: "01"}, tpdu.NewDecodeError("xser", 0, tpdu.ErrUnderflow)},
+		{"xser dcs", ucp.Message{OT: ucp.OTSubmit, XSer: "0200"}, tpdu.NewDecodeError("xser", 0, tpdu.ErrInvalid)},
+		{"msg", ucp.Message{OT: ucp.OTSubmit, MT: ucp.MTAlphanumeric, Msg: "X"}, tpdu.NewDecodeError("msg", 0, tpdu.ErrInvalid)},
+		{"mt", ucp.Message{OT: ucp.OTSubmit, MT: ucp.MTNumeric}, tpdu.NewDecodeError("mt", 0, tpdu.ErrInvalid)},
+		{"nb", ucp.Message{OT: ucp.OTSubmit, MT: ucp.MTTransparent, NB: "16", Msg: "01"}, tpdu.NewDecodeError("nb", 0, tpdu.ErrInvalid)},
+		{"odd ucs2", ucp.Message{OT: ucp.OTSubmit, MT: ucp.MTTransparent, NB: "8", Msg: "01", XSer: "020108"}, tpdu.NewDecodeError("msg", 0, tpdu.ErrOddUCS2Length)},
+		{"dst", ucp.Message{OT: ucp.OTNotification, SCTS: "120420103015", DSCTS: "120420103015", Dst: "9"}, tpdu.NewDecodeError("dst", 0, tpdu.ErrInvalid)},
+	}
+	for _, p := range errPatterns {
+		f := func(t *testing.T) {
+			tp, err := p.in.TPDU()
+			assert.Equal(t, p.err, err)
+			assert.Nil(t, tp)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestEncoderCollector(t *testing.T) {
+	msgs := []string{
+		"this is a very long message that does not fit in a single SMS message, at least it will if I keep adding more to it as 160 characters is more than you might think",
+		"a long message with emoji 😁 that will be encoded as UCS2 and so requires less text to need segmentation, so this will do",
+	}
+	for _, msg := range msgs {
+		e := sms.NewEncoder(sms.AsDeliver, sms.From("1234"))
+		tpdus, err := e.Encode([]byte(msg))
+		require.Nil(t, err)
+		require.True(t, len(tpdus) > 1)
+		c := sms.NewCollector()
+		var segs []*tpdu.TPDU
+		for i := range tpdus {
+			m, err := ucp.FromTPDU(&tpdus[i])
+			require.Nil(t, err)
+			b, err := m.MarshalBinary()
+			require.Nil(t, err)
+			rm := ucp.Message{}
+			require.Nil(t, rm.UnmarshalBinary(b))
+			rt, err := rm.TPDU()
+			require.Nil(t, err)
+			segs, err = c.Collect(*rt)
+			require.Nil(t, err)
+		}
+		require.NotNil(t, segs)
+		m, err := sms.Decode(segs)
+		require.Nil(t, err)
+		assert.Equal(t, msg, string(m))
+	}
+}
@@ -0,0 +1,399 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+// Package ucp provides the UCP/EMI message type and conversions to and from
+// its binary form, and to and from SMS TPDUs.
+//
+// The operations supported are 51 (Submit Short Message), 52 (Delivery Short
+// Message), 53 (Delivery Notification) and 60 (Session Management), as
+// defined in the EMI - UCP Interface Specification v4.6.
+package ucp
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/warthog618/sms/encoding/tpdu"
+)
+
+// Message represents all UCP messages, both operations and results.
+//
+// The fields contain the raw field values, with any encoding, such as the
+// IRA hex encoding of Msg, intact.  Only the fields applicable to the OT, and
+// to operations or results, are encoded.
+type Message struct {
+	// TRN is the transaction reference number, 00-99.
+	TRN int
+
+	// Result indicates the message is a result rather than an operation.
+	Result bool
+
+	// OT is the operation type.
+	OT OT
+
+	// Operation fields for the 5x series (51, 52 and 53).
+	AdC   string
+	OAdC  string
+	AC    string
+	NRq   string
+	NAdC  string
+	NT    string
+	NPID  string
+	LRq   string
+	LRAd  string
+	LPID  string
+	DD    string
+	DDT   string
+	VP    string
+	RPID  string
+	SCTS  string
+	Dst   string
+	Rsn   string
+	DSCTS string
+	MT    string
+	NB    string
+	Msg   string
+	MMS   string
+	PR    string
+	DCs   string
+	MCLs  string
+	RPI   string
+	CPg   string
+	RPLy  string
+	OTOA  string
+	HPLMN string
+	XSer  string
+	RES4  string
+	RES5  string
+
+	// Operation fields for 60, other than OAdC.
+	OTON string
+	ONPI string
+	STYP string
+	PWD  string
+	NPWD string
+	VERS string
+	LAdC string
+	LTON string
+	LNPI string
+	OPID string
+	RES1 string
+
+	// Result fields.
+	//
+	// ACK indicates a positive acknowledgement, else a negative
+	// acknowledgement with the error code in EC.
+	ACK bool
+	MVP string
+	EC  string
+	SM  string
+}
+
+// OT is the UCP operation type.
+type OT int
+
+const (
+	// OTSubmit is the 51 Submit Short Message operation.
+	OTSubmit OT = 51
+
+	// OTDeliver is the 52 Delivery Short Message operation.
+	OTDeliver OT = 52
+
+	// OTNotification is the 53 Delivery Notification operation.
+	OTNotification OT = 53
+
+	// OTSession is the 60 Session Management operation.
+	OTSession OT = 60
+)
+
+// UCP error codes returned in the EC of a NACK.
+const (
+	// ECChecksum indicates a checksum error.
+	ECChecksum = "01"
+
+	// ECSyntax indicates a syntax error.
+	ECSyntax = "02"
+
+	// ECNotSupported indicates the operation is not supported by the system.
+	ECNotSupported = "03"
+
+	// ECNotAllowed indicates the operation is not allowed.
+	ECNotAllowed = "04"
+
+	// ECAdCInvalid indicates the AdC is invalid.
+	ECAdCInvalid = "06"
+
+	// ECAuthFailure indicates an authentication failure.
+	ECAuthFailure = "07"
+)
+
+const (
+	stx = 0x02
+	etx = 0x03
+
+	// headerLen is the length of the TRN/LEN/O/OT/ header.
+	headerLen = 14
+
+	// checksumLen is the length of the checksum.
+	checksumLen = 2
+
+	// MaxMessageLen is the maximum length of a message, as limited by the
+	// five digit LEN field, not including the STX and ETX.
+	MaxMessageLen = 99999
+)
+
+// Response returns a result for the operation.
+//
+// The result is a positive acknowledgement if ec is empty, else a negative
+// acknowledgement with the error code.  Any other result fields must be
+// filled in by the caller.
+func (m *Message) Response(ec string) *Message {
+	return &Message{
+		TRN:    m.TRN,
+		Result: true,
+		OT:     m.OT,
+		ACK:    ec == "",
+		EC:     ec,
+	}
+}
+
+// MarshalBinary marshals the Message into its binary form, including the
+// STX and ETX.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	if m.TRN < 0 || m.TRN > 99 {
+		return nil, tpdu.EncodeError("trn", tpdu.ErrInvalid)
+	}
+	ff, err := m.fields()
+	if err != nil {
+		return nil, err
+	}
+	var body strings.Builder
+	if m.Result {
+		if m.ACK {
+			body.WriteString("A/")
+		} else {
+			body.WriteString("N/")
+		}
+	}
+	for _, f := range ff {
+		if strings.ContainsAny(*f, "/\x02\x03") {
+			return nil, tpdu.EncodeError("fields", tpdu.ErrInvalid)
+		}
+		body.WriteString(*f)
+		body.WriteByte('/')
+	}
+	l := headerLen + body.Len() + checksumLen
+	if l > MaxMessageLen {
+		return nil, tpdu.EncodeError("len", tpdu.ErrOverlength)
+	}
+	or := "O"
+	if m.Result {
+		or = "R"
+	}
+	var b bytes.Buffer
+	b.WriteByte(stx)
+	fmt.Fprintf(&b, "%02d/%05d/%s/%02d/", m.TRN, l, or, int(m.OT))
+	b.WriteString(body.String())
+	fmt.Fprintf(&b, "%02X", checksum(b.Bytes()[1:]))
+	b.WriteByte(etx)
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary unmarshals a Message from its binary form, including the
+// STX and ETX.
+func (m *Message) UnmarshalBinary(src []byte) error {
+	if len(src) < headerLen+checksumLen+2 {
+		return tpdu.NewDecodeError("message", 0, tpdu.ErrUnderflow)
+	}
+	if src[0] != stx {
+		return tpdu.NewDecodeError("stx", 0, tpdu.ErrInvalid)
+	}
+	if src[len(src)-1] != etx {
+		return tpdu.NewDecodeError("etx", len(src)-1, tpdu.ErrInvalid)
+	}
+	b := src[1 : len(src)-1]
+	hdr := strings.Split(string(b[:headerLen]), "/")
+	if len(hdr) != 5 || hdr[4] != "" {
+		return tpdu.NewDecodeError("header", 1, tpdu.ErrInvalid)
+	}
+	trn, err := parseDigits(hdr[0], 2)
+	if err != nil {
+		return tpdu.NewDecodeError("trn", 1, err)
+	}
+	l, err := parseDigits(hdr[1], 5)
+	if err != nil {
+		return tpdu.NewDecodeError("len", 4, err)
+	}
+	if l != len(b) {
+		return tpdu.NewDecodeError("len", 4, ErrInvalidLength(l))
+	}
+	var result bool
+	switch hdr[2] {
+	case "O":
+	case "R":
+		result = true
+	default:
+		return tpdu.NewDecodeError("o/r", 10, tpdu.ErrInvalid)
+	}
+	ot, err := parseDigits(hdr[3], 2)
+	if err != nil {
+		return tpdu.NewDecodeError("ot", 12, err)
+	}
+	ri := len(b) - checksumLen
+	cs := string(b[ri:])
+	if fmt.Sprintf("%02X", checksum(b[:ri])) != strings.ToUpper(cs) {
+		return tpdu.NewDecodeError("checksum", ri+1, ErrInvalidChecksum(cs))
+	}
+	if b[ri-1] != '/' {
+		return tpdu.NewDecodeError("fields", ri, tpdu.ErrInvalid)
+	}
+	// drop the empty value following the final '/'
+	vv := strings.Split(string(b[headerLen:ri]), "/")
+	vv = vv[:len(vv)-1]
+	msg := Message{TRN: trn, Result: result, OT: OT(ot)}
+	if result {
+		if len(vv) == 0 {
+			return tpdu.NewDecodeError("ack", headerLen+1, tpdu.ErrUnderflow)
+		}
+		switch vv[0] {
+		case "A":
+			msg.ACK = true
+		case "N":
+		default:
+			return tpdu.NewDecodeError("ack", headerLen+1, tpdu.ErrInvalid)
+		}
+		vv = vv[1:]
+	}
+	ff, err := msg.fields()
+	if err != nil {
+		return tpdu.NewDecodeError("ot", 12, err)
+	}
+	if len(vv) < len(ff) {
+		return tpdu.NewDecodeError("fields", headerLen+1, tpdu.ErrUnderflow)
+	}
+	if len(vv) > len(ff) {
+		return tpdu.NewDecodeError("fields", headerLen+1, tpdu.ErrOverlength)
+	}
+	for i, v := range vv {
+		*ff[i] = v
+	}
+	*m = msg
+	return nil
+}
+
+// ReadMessage reads a single Message from the reader.
+//
+// Any bytes preceding the STX are discarded.
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == stx {
+			break
+		}
+	}
+	b := []byte{stx}
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		b = append(b, c)
+		if c == etx {
+			break
+		}
+		if len(b) > MaxMessageLen+1 {
+			return nil, ErrInvalidLength(len(b))
+		}
+	}
+	m := Message{}
+	err := m.UnmarshalBinary(b)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// WriteMessage marshals the Message and writes it to the writer.
+func WriteMessage(w io.Writer, m *Message) error {
+	b, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func parseDigits(s string, n int) (int, error) {
+	if len(s) != n {
+		return 0, tpdu.ErrInvalid
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, tpdu.ErrInvalid
+		}
+	}
+	return strconv.Atoi(s)
+}
+
+// checksum returns the UCP checksum of the bytes, being the sum of the bytes
+// modulo 256.
+func checksum(b []byte) byte {
+	var cs byte
+	for _, c := range b {
+		cs += c
+	}
+	return cs
+}
+
+// fields returns pointers to the fields applicable to the message, in the
+// order they are encoded.
+func (m *Message) fields() ([]*string, error) {
+	switch m.OT {
+	case OTSubmit, OTDeliver, OTNotification:
+		if m.Result {
+			return m.resultFields(true), nil
+		}
+		return []*string{
+			&m.AdC, &m.OAdC, &m.AC, &m.NRq, &m.NAdC, &m.NT, &m.NPID,
+			&m.LRq, &m.LRAd, &m.LPID, &m.DD, &m.DDT, &m.VP, &m.RPID,
+			&m.SCTS, &m.Dst, &m.Rsn, &m.DSCTS, &m.MT, &m.NB, &m.Msg,
+			&m.MMS, &m.PR, &m.DCs, &m.MCLs, &m.RPI, &m.CPg, &m.RPLy,
+			&m.OTOA, &m.HPLMN, &m.XSer, &m.RES4, &m.RES5,
+		}, nil
+	case OTSession:
+		if m.Result {
+			return m.resultFields(false), nil
+		}
+		return []*string{
+			&m.OAdC, &m.OTON, &m.ONPI, &m.STYP, &m.PWD, &m.NPWD, &m.VERS,
+			&m.LAdC, &m.LTON, &m.LNPI, &m.OPID, &m.RES1,
+		}, nil
+	default:
+		return nil, ErrUnsupportedOT(m.OT)
+	}
+}
+
+// resultFields returns pointers to the fields applicable to the result,
+// following the ACK or NACK.
+//
+// The 5x series ACK contains an MVP, while the 60 ACK does not.
+func (m *Message) resultFields(mvp bool) []*string {
+	if !m.ACK {
+		return []*string{&m.EC, &m.SM}
+	}
+	if mvp {
+		return []*string{&m.MVP, &m.SM}
+	}
+	return []*string{&m.SM}
+}
@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: MIT
+//
+// Copyright © 2020 Kent Gibson <warthog618@gmail.com>.
+
+package ucp_test
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/warthog618/sms/encoding/tpdu"
+	"github.com/warthog618/sms/ucp"
+)
+
+var messagePatterns = []struct {
+	name string
+	in   ucp.Message
+	out  []byte
+}{
+	{
+		"session",
+		ucp.Message{
+			TRN:  1,
+			OT:   ucp.OTSession,
+			OAdC: "1234",
+			OTON: "6",
+			ONPI: "5",
+			STYP: "1",
+			PWD:  "736563726574",
+			VERS: "0100",
+		},
+		[]byte("\x0201/00051/O/60/1234/6/5/1/736563726574//0100//////A0\x03"),
+	},
+	{
+		"session ack",
+		ucp.Message{TRN: 1, OT: ucp.OTSession, Result: true, ACK: true},
+		[]byte("\x0201/00019/R/60/A//6E\x03"),
+	},
+	{
+		"submit",
+		ucp.Message{
+			TRN:  2,
+			OT:   ucp.OTSubmit,
+			AdC:  "2222",
+			OAdC: "1111",
+			MT:   "3",
+			Msg:  "68656C6C6F",
+		},
+		[]byte("\x0202/00068/O/51/2222/1111/////////////////3//68656C6C6F/////////////E6\x03"),
+	},
+	{
+		"submit ack",
+		ucp.Message{TRN: 2, OT: ucp.OTSubmit, Result: true, ACK: true, SM: "2222:120420103000"},
+		[]byte("\x0202/00037/R/51/A//2222:120420103000/ED\x03"),
+	},
+	{
+		"submit nack",
+		ucp.Message{TRN: 2, OT: ucp.OTSubmit, Result: true, EC: "02", SM: "syntax"},
+		[]byte("\x0202/00028/R/51/N/02/syntax/B4\x03"),
+	},
+}
+
+func TestMarshalBinary(t *testing.T) {
+	for _, p := range messagePatterns {
+		f := func(t *testing.T) {
+			b, err := p.in.MarshalBinary()
+			require.Nil(t, err)
+			assert.Equal(t, p.out, b)
+		}
+		t.Run(p.name, f)
+	}
+
+	errPatterns := []struct {
+		name string
+		in   ucp.Message
+		err  error
+	}{
+		{"trn", ucp.Message{TRN: 100, OT: ucp.OTSession}, tpdu.EncodeError("trn", tpdu.ErrInvalid)},
+		{"ot", ucp.Message{OT: 31}, ucp.ErrUnsupportedOT(31)},
+		{"separator", ucp.Message{OT: ucp.OTSubmit, AdC: "12/34"}, tpdu.EncodeError("fields", tpdu.ErrInvalid)},
+	}
+	for _, p := range errPatterns {
+		f := func(t *testing.T) {
+			b, err := p.in.MarshalBinary()
+			assert.Equal(t, p.err, err)
+			assert.Nil(t, b)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	for _, p := range messagePatterns {
+		f := func(t *testing.T) {
+			m := ucp.Message{}
+			err := m.UnmarshalBinary(p.out)
+			require.Nil(t, err)
+			assert.Equal(t, p.in, m)
+		}
+		t.Run(p.name, f)
+	}
+
+	errPatterns := []struct {
+		name string
+		in   string
+		err  error
+	}{
+		{"short", "\x0201/00019/R/60\x03", tpdu.NewDecodeError("message", 0, tpdu.ErrUnderflow)},
+		{"stx", "01/00019/R/60/A//6E\x03\x03", tpdu.NewDecodeError("stx", 0, tpdu.ErrInvalid)},
+		{"etx", "\x0201/00019/R/60/A//6E\x02", tpdu.NewDecodeError("etx", 20, tpdu.ErrInvalid)},
+		{"trn", "\x02x1/00019/R/60/A//6E\x03", tpdu.NewDecodeError("trn", 1, tpdu.ErrInvalid)},
+		{"len", "\x0201/00020/R/60/A//6E\x03", tpdu.NewDecodeError("len", 4, ucp.ErrInvalidLength(20))},
+		{"o/r", "\x0201/00019/X/60/A//6E\x03", tpdu.NewDecodeError("o/r", 10, tpdu.ErrInvalid)},
+		{"checksum", "\x0201/00019/R/60/A//6F\x03", tpdu.NewDecodeError("checksum", 18, ucp.ErrInvalidChecksum("6F"))},
+		{"ack", "\x0201/00019/R/60/X//85\x03", tpdu.NewDecodeError("ack", 15, tpdu.ErrInvalid)},
+		{"ot", "\x0201/00019/R/31/A//6C\x03", tpdu.NewDecodeError("ot", 12, ucp.ErrUnsupportedOT(31))},
+		{"underflow", "\x0201/00019/R/60/N//7B\x03", tpdu.NewDecodeError("fields", 15, tpdu.ErrUnderflow)},
+		{"overflow", "\x0201/00020/R/60/A///95\x03", tpdu.NewDecodeError("fields", 15, tpdu.ErrOverlength)},
+	}
+	for _, p := range errPatterns {
+		f := func(t *testing.T) {
+			m := ucp.Message{}
+			err := m.UnmarshalBinary([]byte(p.in))
+			assert.Equal(t, p.err, err)
+		}
+		t.Run(p.name, f)
+	}
+}
+
+func TestResponse(t *testing.T) {
+	m := ucp.Message{TRN: 42, OT: ucp.OTSubmit, AdC: "1234"}
+	assert.Equal(t, &ucp.Message{TRN: 42, OT: ucp.OTSubmit, Result: true, ACK: true}, m.Response(""))
+	assert.Equal(t, &ucp.Message{TRN: 42, OT: ucp.OTSubmit, Result: true, EC: ucp.ECSyntax}, m.Response(ucp.ECSyntax))
+}
+
+func TestReadWriteMessage(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString("noise")
+	for _, p := range messagePatterns {
+		err := ucp.WriteMessage(&b, &p.in)
+		require.Nil(t, err)
+	}
+	r := bufio.NewReader(&b)
+	for _, p := range messagePatterns {
+		m, err := ucp.ReadMessage(r)
+		require.Nil(t, err)
+		assert.Equal(t, &p.in, m)
+	}
+	_, err := ucp.ReadMessage(r)
+	assert.Equal(t, io.EOF, err)
+
+	r = bufio.NewReader(bytes.NewReader([]byte("\x0201/000")))
+	_, err = ucp.ReadMessage(r)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	err = ucp.WriteMessage(&b, &ucp.Message{OT: 31})
+	assert.Equal(t, ucp.ErrUnsupportedOT(31), err)
+}